@@ -0,0 +1,74 @@
+// Command cancel-operation cancels a queued or running operation of the webhook
+// server through the durable queue (QUEUE_PROVIDER=postgres).
+// Usage:
+//
+//	cancel-operation <operation_id>
+//
+// A queued operation is finished as canceled by the next free worker; a
+// running one is canceled by its instance on the next lease heartbeat. With
+// the in-memory queue use DELETE /api/operations/{operation_id} instead.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/user/go-frontol-loader/pkg/config"
+	"github.com/user/go-frontol-loader/pkg/logger"
+	"github.com/user/go-frontol-loader/pkg/operations"
+)
+
+func main() {
+	flag.Usage = printUsage
+	flag.Parse()
+	args := flag.Args()
+	if len(args) != 1 || args[0] == "" {
+		printUsage()
+		os.Exit(1)
+	}
+	operationID := args[0]
+
+	// Загружаем только настройки БД
+	cfg, err := config.LoadDBConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	store := operations.NewStore(cfg, logger.New(logger.Config{Level: "warn", Output: os.Stderr}))
+	ctx := context.Background()
+
+	status, err := store.RequestCancel(ctx, operationID)
+	if err != nil {
+		store.Close()
+		log.Fatalf("Failed to cancel operation: %v", err)
+	}
+	if status != "" {
+		store.Close()
+		fmt.Printf("Отмена операции %s запрошена (статус в очереди: %s)\n", operationID, status)
+		return
+	}
+
+	record, err := store.Get(ctx, operationID)
+	store.Close()
+	if err != nil {
+		log.Fatalf("Failed to get operation: %v", err)
+	}
+	switch {
+	case record == nil:
+		fmt.Printf("Операция %s не найдена\n", operationID)
+	case record.Status.Finished():
+		fmt.Printf("Операция %s уже завершена со статусом %s\n", operationID, record.Status)
+	default:
+		fmt.Printf("Операции %s нет в durable-очереди (статус %s): отмените ее через DELETE /api/operations/%s\n", operationID, record.Status, operationID)
+	}
+	os.Exit(1)
+}
+
+func printUsage() {
+	fmt.Println("Usage: cancel-operation <operation_id>")
+	fmt.Println()
+	fmt.Println("Cancels a queued or running webhook-server operation through the durable queue (QUEUE_PROVIDER=postgres).")
+}
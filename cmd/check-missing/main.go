@@ -1,23 +1,75 @@
+// Command check-missing reports gaps in loaded kassa data: days without a
+// committed file load, loaded days without transactions and shifts with
+// receipts but no shift close (61) or Z-report (63).
+// Usage:
+//
+//	check-missing [flags]
+//
+// Flags:
+//
+//	-date-from 2024-12-01  - First day to check (default: GAP_CHECK_DAYS days before today)
+//	-date-to 2024-12-07    - Last day to check (default: date-from, or yesterday)
+//	-kassas P13,N22/N22    - Kassa codes or source folders (default: all of KASSA_STRUCTURE)
+//	-json                  - Print JSON instead of a table
+//
+// The command exits with status 2 when gaps are found.
 package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
-	"sort"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/user/go-frontol-loader/pkg/config"
 	"github.com/user/go-frontol-loader/pkg/db"
+	"github.com/user/go-frontol-loader/pkg/ftp"
 	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/repository"
 )
 
 func main() {
+	dateFrom := flag.String("date-from", "", "First day to check, YYYY-MM-DD (default: GAP_CHECK_DAYS days before today)")
+	dateTo := flag.String("date-to", "", "Last day to check, YYYY-MM-DD (default: date-from, or yesterday)")
+	kassasFlag := flag.String("kassas", "", "Comma-separated kassa codes or source folders (default: all)")
+	asJSON := flag.Bool("json", false, "Print JSON instead of a table")
+	flag.Parse()
+
 	// Загружаем конфигурацию
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	dates := models.RecentDays(time.Now(), cfg.EffectiveGapCheckDays())
+	if *dateFrom != "" {
+		if dates, err = models.ParseDateRange(*dateFrom, *dateTo); err != nil {
+			log.Fatalf("Invalid dates: %v", err)
+		}
+	} else if *dateTo != "" {
+		log.Fatalf("-date-to requires -date-from")
+	}
+
+	var kassas []string
+	for _, kassa := range strings.Split(*kassasFlag, ",") {
+		if kassa = strings.TrimSpace(kassa); kassa != "" {
+			kassas = append(kassas, kassa)
+		}
+	}
+	folders, err := ftp.SelectKassaFolders(cfg, kassas)
+	if err != nil {
+		log.Fatalf("Invalid -kassas: %v", err)
+	}
+	sourceFolders := make([]string, 0, len(folders))
+	for _, folder := range folders {
+		sourceFolders = append(sourceFolders, folder.KassaCode+"/"+folder.FolderName)
+	}
+
 	// Подключаемся к БД
 	database, err := db.NewPool(cfg)
 	if err != nil {
@@ -25,144 +77,46 @@ func main() {
 	}
 	defer database.Close()
 
-	ctx := context.Background()
-
-	// Проверяем транзакцию 730423
-	fmt.Println("=== Проверка транзакции 730423 ===")
-	checkTransaction(ctx, database, 730423)
-
-	// Проверяем транзакцию 2066483
-	fmt.Println("\n=== Проверка транзакции 2066483 ===")
-	checkTransaction(ctx, database, 2066483)
-
-	// Проверяем все записи типа 21 за дату 2025-12-21
-	fmt.Println("\n=== Статистика по типу 21 за 2025-12-21 ===")
-	checkType21Stats(ctx, database)
-}
-
-func checkTransaction(ctx context.Context, database *db.Pool, transactionID int64) {
-	// Проверяем в tx_bill_registration_21_23
-	query := `
-		SELECT 
-			transaction_id_unique,
-			source_folder,
-			transaction_date,
-			transaction_time,
-			transaction_type,
-			cash_register_code,
-			document_number
-		FROM tx_bill_registration_21_23
-		WHERE transaction_id_unique = $1
-	`
-
-	rows, err := database.Query(ctx, query, transactionID)
+	gaps, err := repository.NewLoader(database).FindDataGaps(context.Background(), sourceFolders, dates)
 	if err != nil {
-		log.Printf("Error querying bill_registrations: %v", err)
-		return
+		database.Close()
+		log.Fatalf("Failed to find data gaps: %v", err)
 	}
-	defer rows.Close()
-
-	found := false
-	for rows.Next() {
-		found = true
-		var id int64
-		var sourceFolder, date, time string
-		var txType, cashRegCode int64
-		var docNumber string
 
-		if err := rows.Scan(&id, &sourceFolder, &date, &time, &txType, &cashRegCode, &docNumber); err != nil {
-			log.Printf("Error scanning row: %v", err)
-			continue
-		}
-
-		fmt.Printf("Найдено в tx_bill_registration_21_23:\n")
-		fmt.Printf("  ID: %d\n", id)
-		fmt.Printf("  Source Folder: %s\n", sourceFolder)
-		fmt.Printf("  Date: %s\n", date)
-		fmt.Printf("  Time: %s\n", time)
-		fmt.Printf("  Type: %d\n", txType)
-		fmt.Printf("  Cash Register: %d\n", cashRegCode)
-		fmt.Printf("  Document: %s\n", docNumber)
+	if *asJSON {
+		printJSON(gaps)
+	} else {
+		fmt.Printf("Проверено касс: %d, дни: %s\n", len(sourceFolders), dates.String())
+		printGaps(gaps)
 	}
-
-	if !found {
-		fmt.Printf("Транзакция %d НЕ найдена в tx_bill_registration_21_23\n", transactionID)
-
-		// Проверяем в других таблицах
-		checkOtherTables(ctx, database, transactionID)
+	if len(gaps) > 0 {
+		database.Close()
+		os.Exit(2)
 	}
 }
 
-func checkOtherTables(ctx context.Context, database *db.Pool, transactionID int64) {
-	tables := make([]string, 0, len(models.TxSchemas))
-	for table := range models.TxSchemas {
-		tables = append(tables, table)
+func printGaps(gaps []models.DataGap) {
+	if len(gaps) == 0 {
+		fmt.Println("Пробелов в данных не найдено")
+		return
 	}
-	sort.Strings(tables)
-
-	for _, table := range tables {
-		query := fmt.Sprintf(`
-			SELECT 
-				transaction_id_unique,
-				source_folder,
-				transaction_date,
-				transaction_type
-			FROM %s
-			WHERE transaction_id_unique = $1
-			LIMIT 1
-		`, table)
-
-		rows, err := database.Query(ctx, query, transactionID)
-		if err != nil {
-			continue
-		}
-
-		if rows.Next() {
-			var id int64
-			var sourceFolder, date string
-			var txType int
-
-			if err := rows.Scan(&id, &sourceFolder, &date, &txType); err == nil {
-				fmt.Printf("Найдено в %s: ID=%d, source_folder=%s, date=%s, type=%d\n",
-					table, id, sourceFolder, date, txType)
-			}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "SOURCE_FOLDER\tDATE\tKIND\tFILES\tCASH_REGISTER\tSHIFT")
+	for _, gap := range gaps {
+		cashRegister, shift := "", ""
+		if gap.Kind == models.DataGapUnclosedShift {
+			cashRegister, shift = fmt.Sprint(gap.CashRegisterCode), fmt.Sprint(gap.ShiftNumber)
 		}
-		rows.Close()
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", gap.SourceFolder, gap.Date, gap.Kind, gap.FilesLoaded, cashRegister, shift)
 	}
+	_ = w.Flush()
+	fmt.Printf("Найдено пробелов: %d\n", len(gaps))
 }
 
-func checkType21Stats(ctx context.Context, database *db.Pool) {
-	query := `
-		SELECT 
-			source_folder,
-			COUNT(*) as count,
-			MIN(transaction_id_unique) as min_id,
-			MAX(transaction_id_unique) as max_id
-		FROM tx_bill_registration_21_23
-		WHERE transaction_date = '2025-12-21'::date
-		  AND transaction_type = 21
-		GROUP BY source_folder
-		ORDER BY source_folder
-	`
-
-	rows, err := database.Query(ctx, query)
-	if err != nil {
-		log.Printf("Error querying stats: %v", err)
-		return
-	}
-	defer rows.Close()
-
-	fmt.Println("Статистика по source_folder:")
-	for rows.Next() {
-		var sourceFolder string
-		var count, minID, maxID int64
-
-		if err := rows.Scan(&sourceFolder, &count, &minID, &maxID); err != nil {
-			log.Printf("Error scanning stats: %v", err)
-			continue
-		}
-
-		fmt.Printf("  %s: всего=%d, ID от %d до %d\n",
-			sourceFolder, count, minID, maxID)
+func printJSON(value interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		log.Fatalf("Failed to encode output: %v", err)
 	}
 }
@@ -5,12 +5,11 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"sort"
 	"time"
 
 	"github.com/user/go-frontol-loader/pkg/config"
 	"github.com/user/go-frontol-loader/pkg/db"
-	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/parser"
 )
 
 func main() {
@@ -46,11 +45,7 @@ func main() {
 	defer cancel()
 
 	// Список всех tx_* таблиц транзакций для очистки
-	tables := make([]string, 0, len(models.TxSchemas))
-	for table := range models.TxSchemas {
-		tables = append(tables, table)
-	}
-	sort.Strings(tables)
+	tables := parser.RegisteredTables()
 
 	// Отключаем проверку внешних ключей для ускорения
 	if _, err := database.Exec(ctx, "SET session_replication_role = 'replica'"); err != nil {
@@ -6,6 +6,7 @@ import (
 
 	"github.com/user/go-frontol-loader/pkg/config"
 	"github.com/user/go-frontol-loader/pkg/ftp"
+	"github.com/user/go-frontol-loader/pkg/models"
 )
 
 func main() {
@@ -17,7 +18,7 @@ func main() {
 	}
 
 	// Connect to FTP
-	client, err := ftp.NewClient(cfg)
+	client, err := ftp.Open(cfg, 1)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to connect to FTP: %v\n", err)
 		os.Exit(1)
@@ -29,8 +30,15 @@ func main() {
 	}()
 
 	fmt.Println("=== FTP Diagnostic Tool ===")
-	fmt.Printf("FTP Host: %s:%d\n", cfg.FTPHost, cfg.FTPPort)
-	fmt.Printf("FTP User: %s\n", cfg.FTPUser)
+	switch cfg.EffectiveExchangeBackend() {
+	case models.ExchangeBackendFile:
+		fmt.Printf("Exchange Dir: %s\n", cfg.ExchangeRoot)
+	case models.ExchangeBackendS3:
+		fmt.Printf("Exchange Bucket: s3://%s/%s (%s)\n", cfg.S3Bucket, cfg.ExchangeRoot, cfg.S3Endpoint)
+	default:
+		fmt.Printf("FTP Host: %s:%d (%s)\n", cfg.FTPHost, cfg.FTPPort, cfg.FTPProtocol)
+		fmt.Printf("FTP User: %s\n", cfg.FTPUser)
+	}
 	fmt.Printf("Request Dir: %s\n", cfg.FTPRequestDir)
 	fmt.Printf("Response Dir: %s\n", cfg.FTPResponseDir)
 	fmt.Println()
@@ -2,12 +2,16 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log/slog"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/user/go-frontol-loader/pkg/config"
+	"github.com/user/go-frontol-loader/pkg/ftp"
 	"github.com/user/go-frontol-loader/pkg/logger"
+	"github.com/user/go-frontol-loader/pkg/models"
 	"github.com/user/go-frontol-loader/pkg/operations"
 	"github.com/user/go-frontol-loader/pkg/pipeline"
 )
@@ -29,23 +33,39 @@ func main() {
 	defer func() { _ = defaultLogger.Close() }()
 	slog.SetDefault(defaultLogger.Logger)
 
-	// Parse command line arguments
-	var date string
-	if len(os.Args) > 1 {
-		date = os.Args[1]
-		// Validate date format
-		if _, err := time.Parse("2006-01-02", date); err != nil {
+	// Parse command line arguments: loader [-kassas P13,L32/L32_INTER] [date_from [date_to]]
+	kassaList := flag.String("kassas", "", "Comma-separated kassa codes or source folders to load (default: all)")
+	flag.Parse()
+	args := flag.Args()
+
+	var dates models.DateRange
+	if len(args) > 0 {
+		var dateTo string
+		if len(args) > 1 {
+			dateTo = args[1]
+		}
+		parsed, err := models.ParseDateRange(args[0], dateTo)
+		if err != nil {
 			// #nosec G706 -- invalid CLI date is logged for operator troubleshooting.
-			slog.Error("Invalid date format",
-				"date", date,
+			slog.Error("Invalid date range",
+				"date_from", args[0],
+				"date_to", dateTo,
 				"error", err.Error(),
 			)
 			os.Exit(1)
 		}
+		dates = parsed
 	} else {
 		// Use current date if not provided
-		date = time.Now().Format("2006-01-02")
+		dates = models.SingleDay(time.Now().Format(models.DateLayout))
 	}
+	var kassas []string
+	for _, kassa := range strings.Split(*kassaList, ",") {
+		if kassa = strings.TrimSpace(kassa); kassa != "" {
+			kassas = append(kassas, kassa)
+		}
+	}
+	date := dates.String()
 
 	// Load configuration
 	cfg, err := config.LoadConfig()
@@ -56,6 +76,14 @@ func main() {
 		os.Exit(1)
 	}
 
+	if _, err := ftp.SelectKassaFolders(cfg, kassas); err != nil {
+		slog.Error("Invalid kassas",
+			"kassas", kassas,
+			"error", err.Error(),
+		)
+		os.Exit(1)
+	}
+
 	// Create logger
 	loggerInstance := logger.New(logger.Config{
 		Level:   cfg.LogLevel,
@@ -86,6 +114,7 @@ func main() {
 		OperationType: "cli_load",
 		Status:        operations.StatusProcessing,
 		Date:          date,
+		SourceFolder:  strings.Join(kassas, ","),
 		Component:     "loader",
 		StartedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
@@ -94,9 +123,10 @@ func main() {
 	// Run ETL pipeline
 	log.InfoContext(ctx, "Starting ETL pipeline",
 		"date", date,
+		"kassas", kassas,
 		"event", "etl_start",
 	)
-	result, err := pipeline.Run(ctx, log.Logger, cfg, date)
+	result, err := pipeline.Run(ctx, log.Logger, cfg, dates, kassas)
 	if err != nil {
 		now := time.Now()
 		_ = opStore.Update(ctx, operations.Record{
@@ -104,11 +134,13 @@ func main() {
 			OperationType: "cli_load",
 			Status:        operations.StatusFailed,
 			Date:          date,
+			SourceFolder:  strings.Join(kassas, ","),
 			Component:     "loader",
 			UpdatedAt:     now,
 			FinishedAt:    &now,
 			ErrorMessage:  err.Error(),
 			FailedStage:   "pipeline",
+			Result:        result.Summary(),
 		})
 		log.ErrorContext(ctx, "ETL pipeline failed",
 			"error", err.Error(),
@@ -127,11 +159,13 @@ func main() {
 		OperationType: "cli_load",
 		Status:        status,
 		Date:          date,
+		SourceFolder:  strings.Join(kassas, ","),
 		Component:     "loader",
 		UpdatedAt:     now,
 		FinishedAt:    &now,
 		ErrorMessage:  result.ErrorMessage,
 		FailedStage:   firstIssueStage(result),
+		Result:        result.Summary(),
 	})
 
 	// Print results
@@ -0,0 +1,134 @@
+// Command rejected-lines lists and re-runs transaction lines quarantined in etl_rejected_lines.
+// Usage:
+//
+//	rejected-lines [flags] list       - Show rejected lines
+//	rejected-lines [flags] reprocess  - Parse still rejected lines again and load those that now parse
+//
+// Flags:
+//
+//	-source-folder P13/P13   - Only lines of this kassa folder
+//	-logical-key KEY         - Only lines of this file (<remote_path>|<requested_date>)
+//	-status rejected         - Only lines with this status (list only: rejected, reprocessed)
+//	-limit 100               - Maximum number of lines (max 1000)
+//	-json                    - Print JSON instead of a table
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/user/go-frontol-loader/pkg/config"
+	"github.com/user/go-frontol-loader/pkg/db"
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/repository"
+)
+
+func main() {
+	sourceFolder := flag.String("source-folder", "", "Only lines of this kassa folder, e.g. P13/P13")
+	logicalKey := flag.String("logical-key", "", "Only lines of this file (<remote_path>|<requested_date>)")
+	status := flag.String("status", "", "Only lines with this status (rejected, reprocessed)")
+	limit := flag.Int("limit", 100, "Maximum number of lines (max 1000)")
+	asJSON := flag.Bool("json", false, "Print JSON instead of a table")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) != 1 {
+		printUsage()
+		os.Exit(1)
+	}
+	if *status != "" && *status != models.RejectedLineStatusRejected && *status != models.RejectedLineStatusReprocessed {
+		log.Fatalf("Invalid -status %q: want %s or %s", *status, models.RejectedLineStatusRejected, models.RejectedLineStatusReprocessed)
+	}
+
+	// Загружаем только настройки БД
+	cfg, err := config.LoadDBConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	database, err := db.NewPool(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	loader := repository.NewLoader(database)
+	filter := repository.RejectedLineFilter{
+		SourceFolder: *sourceFolder,
+		LogicalKey:   *logicalKey,
+		Status:       *status,
+		Limit:        *limit,
+	}
+
+	switch args[0] {
+	case "list":
+		lines, err := loader.ListRejectedLines(ctx, filter)
+		if err != nil {
+			database.Close()
+			log.Fatalf("Failed to list rejected lines: %v", err)
+		}
+		if *asJSON {
+			printJSON(lines)
+			return
+		}
+		printLines(lines)
+
+	case "reprocess":
+		result, err := loader.ReprocessRejectedLines(ctx, filter)
+		if err != nil {
+			database.Close()
+			log.Fatalf("Failed to reprocess rejected lines: %v", err)
+		}
+		if *asJSON {
+			printJSON(result)
+			return
+		}
+		fmt.Printf("Выбрано строк: %d, загружено: %d, по-прежнему отклонено: %d\n", result.Selected, result.Reprocessed, result.StillRejected)
+		for tableName, count := range result.TableCounts {
+			fmt.Printf("  %s: %d\n", tableName, count)
+		}
+
+	default:
+		printUsage()
+		database.Close()
+		os.Exit(1)
+	}
+}
+
+func printLines(lines []models.RejectedLine) {
+	if len(lines) == 0 {
+		fmt.Println("Отклоненных строк не найдено")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tSOURCE_FOLDER\tLOGICAL_KEY\tLINE\tTYPE\tSTATUS\tERROR")
+	for _, line := range lines {
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%s\t%s\n", line.ID, line.SourceFolder, line.LogicalKey, line.LineNumber, line.TransactionType, line.Status, line.Error)
+	}
+	_ = w.Flush()
+}
+
+func printJSON(value interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		log.Fatalf("Failed to encode output: %v", err)
+	}
+}
+
+func printUsage() {
+	fmt.Println("Usage: rejected-lines [flags] <command>")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  list        Show rejected lines")
+	fmt.Println("  reprocess   Parse still rejected lines again and load those that now parse")
+	fmt.Println()
+	fmt.Println("Flags:")
+	flag.PrintDefaults()
+}
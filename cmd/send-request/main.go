@@ -16,7 +16,7 @@ func main() {
 	}
 
 	// Create FTP client
-	client, err := ftp.NewClient(cfg)
+	client, err := ftp.Open(cfg, 1)
 	if err != nil {
 		log.Fatalf("Failed to create FTP client: %v", err)
 	}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/operations"
+	"github.com/user/go-frontol-loader/pkg/tracing"
+)
+
+// cancelOutcome — результат отмены операции этим экземпляром.
+type cancelOutcome int
+
+const (
+	cancelNotFound cancelOutcome = iota // операция не ждет и не выполняется здесь
+	cancelQueued                        // операция снята с memory-очереди
+	cancelRunning                       // контекст выполняющейся операции отменен
+)
+
+// operationRegistry хранит операции этого экземпляра, которые можно отменить:
+// ожидающие в memory-очереди и выполняющиеся.
+type operationRegistry struct {
+	mu       sync.Mutex
+	queued   map[string]*QueueItem
+	canceled map[string]bool // сняты с memory-очереди, воркер должен их пропустить
+	running  map[string]context.CancelFunc
+}
+
+func (r *operationRegistry) addQueued(item *QueueItem) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.queued == nil {
+		r.queued = make(map[string]*QueueItem)
+	}
+	r.queued[item.OperationID] = item
+}
+
+func (r *operationRegistry) removeQueued(operationID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.queued, operationID)
+}
+
+// take отмечает, что воркер взял операцию из memory-очереди, и сразу
+// регистрирует ее как выполняющуюся, чтобы отмена не потерялась между
+// очередью и запуском. Возвращает false, если операцию отменили, пока она ждала.
+func (r *operationRegistry) take(operationID string) (context.Context, func(), bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.queued, operationID)
+	if r.canceled[operationID] {
+		delete(r.canceled, operationID)
+		return nil, nil, false
+	}
+	ctx, done := r.startLocked(operationID)
+	return ctx, done, true
+}
+
+// start регистрирует выполняющуюся операцию и возвращает ее контекст и функцию
+// снятия с учета.
+func (r *operationRegistry) start(operationID string) (context.Context, func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.startLocked(operationID)
+}
+
+func (r *operationRegistry) startLocked(operationID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if r.running == nil {
+		r.running = make(map[string]context.CancelFunc)
+	}
+	r.running[operationID] = cancel
+	return ctx, func() {
+		r.mu.Lock()
+		delete(r.running, operationID)
+		r.mu.Unlock()
+		cancel()
+	}
+}
+
+// runningCount возвращает число операций, выполняющихся на этом экземпляре.
+func (r *operationRegistry) runningCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.running)
+}
+
+// active сообщает, ждет ли операция в memory-очереди или выполняется.
+func (r *operationRegistry) active(operationID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, queued := r.queued[operationID]
+	_, running := r.running[operationID]
+	return queued || running
+}
+
+// cancel отменяет ожидающую или выполняющуюся операцию. Для снятой с очереди
+// операции возвращает ее элемент.
+func (r *operationRegistry) cancel(operationID string) (cancelOutcome, *QueueItem) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cancel, ok := r.running[operationID]; ok {
+		cancel()
+		return cancelRunning, nil
+	}
+	if item, ok := r.queued[operationID]; ok {
+		delete(r.queued, operationID)
+		if r.canceled == nil {
+			r.canceled = make(map[string]bool)
+		}
+		r.canceled[operationID] = true
+		return cancelQueued, item
+	}
+	return cancelNotFound, nil
+}
+
+// cancelOperation обрабатывает DELETE /api/operations/{operation_id}: снимает
+// операцию с очереди или отменяет контекст выполняющегося pipeline.
+func (s *Server) cancelOperation(w http.ResponseWriter, r *http.Request, operationID string) {
+	ctx := r.Context()
+	log := s.logger.WithRequestID(r.Header.Get("X-Request-ID")).WithOperationID(operationID)
+
+	outcome, item := s.activeOps.cancel(operationID)
+	switch outcome {
+	case cancelQueued:
+		log.InfoContext(ctx, "Queued operation canceled",
+			"event", "operation_cancel_queued",
+		)
+		s.finishCanceled(item)
+		writeCancelResponse(w, http.StatusOK, operationID, operations.StatusCanceled)
+		return
+	case cancelRunning:
+		log.InfoContext(ctx, "Running operation cancel requested",
+			"event", "operation_cancel_running",
+		)
+		writeCancelResponse(w, http.StatusAccepted, operationID, "cancel_requested")
+		return
+	}
+
+	if s.durableQueue() {
+		status, err := s.opStore.RequestCancel(ctx, operationID)
+		if err != nil {
+			log.ErrorContext(ctx, "Failed to request operation cancel",
+				"error", err.Error(),
+				"event", "operation_cancel_error",
+			)
+			http.Error(w, "Failed to cancel operation", http.StatusInternalServerError)
+			return
+		}
+		if status != "" {
+			// Ожидающую операцию завершит воркер, выполняющуюся — ее экземпляр
+			// при следующем продлении аренды
+			s.wakeQueueWorkers()
+			log.InfoContext(ctx, "Operation cancel requested in durable queue",
+				"queue_status", status,
+				"event", "operation_cancel_requested",
+			)
+			writeCancelResponse(w, http.StatusAccepted, operationID, "cancel_requested")
+			return
+		}
+	}
+
+	record, err := s.opStore.Get(ctx, operationID)
+	if err != nil {
+		log.ErrorContext(ctx, "Failed to get operation",
+			"error", err.Error(),
+			"event", "query_error",
+		)
+		http.Error(w, "Failed to retrieve operation", http.StatusInternalServerError)
+		return
+	}
+	if record == nil {
+		http.Error(w, "Operation not found", http.StatusNotFound)
+		return
+	}
+	if record.Status.Finished() {
+		http.Error(w, "Operation already finished with status "+string(record.Status), http.StatusConflict)
+		return
+	}
+	// Операция CLI или другой реплики с memory-очередью: отменить ее отсюда нельзя
+	http.Error(w, "Operation is not running on this instance", http.StatusConflict)
+}
+
+func writeCancelResponse(w http.ResponseWriter, code int, operationID string, status operations.Status) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"operation_id": operationID,
+		"status":       status,
+	})
+}
+
+// finishCanceled отмечает операцию, отмененную до запуска, и отправляет отчет.
+func (s *Server) finishCanceled(item *QueueItem) {
+	now := time.Now()
+	s.trackOperation(context.Background(), operations.Record{
+		OperationID:   item.OperationID,
+		RequestID:     item.RequestID,
+		OperationType: string(item.OperationType),
+		Status:        operations.StatusCanceled,
+		Date:          item.dates().String(),
+		SourceFolder:  item.SourceFolder,
+		Component:     "webhook-server",
+		UpdatedAt:     now,
+		FinishedAt:    &now,
+		ErrorMessage:  "operation canceled before start",
+		FailedStage:   "queue",
+	})
+	if len(s.reportSubscribers()) == 0 || item.OperationType != OperationTypeLoad {
+		return
+	}
+	report := &WebhookReport{
+		RequestID:     item.RequestID,
+		OperationType: string(item.OperationType),
+		Date:          item.Date,
+		DateTo:        item.DateTo,
+		Kassas:        item.Kassas,
+		Status:        string(operations.StatusCanceled),
+		StartTime:     now,
+		EndTime:       now,
+		Duration:      time.Duration(0).String(),
+		ErrorMessage:  "operation canceled before start",
+	}
+	ctx := tracing.WithTraceParent(context.Background(), item.TraceParent)
+	report.TraceID = tracing.TraceID(ctx)
+	s.sendWebhookReport(ctx, item.OperationID, report)
+}
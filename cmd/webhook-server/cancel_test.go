@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/pipeline"
+)
+
+// newReportRecorder возвращает сервер, который передает полученные webhook-отчеты в канал.
+func newReportRecorder(t *testing.T) (*httptest.Server, <-chan WebhookReport) {
+	t.Helper()
+	reports := make(chan WebhookReport, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		var report WebhookReport
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			t.Errorf("decode report: %v", err)
+		}
+		reports <- report
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server, reports
+}
+
+func TestCancelOperation_QueuedInMemory(t *testing.T) {
+	reportServer, reports := newReportRecorder(t)
+	s := newTestServer(t, "")
+	s.config.WebhookReportURL = reportServer.URL
+
+	item := &QueueItem{
+		RequestID:     "req-cancel",
+		OperationID:   "op-cancel",
+		Date:          "2024-12-01",
+		OperationType: OperationTypeLoad,
+		Logger:        s.logger,
+		CreatedAt:     time.Now(),
+	}
+	if err := s.enqueue(item); err != nil {
+		t.Fatalf("enqueue() unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/operations/op-cancel", nil)
+	rec := httptest.NewRecorder()
+	s.operationHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("DELETE = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	select {
+	case report := <-reports:
+		if report.Status != "canceled" || report.RequestID != "req-cancel" {
+			t.Fatalf("report = %+v, want canceled report for req-cancel", report)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("canceled report was not sent")
+	}
+	// Воркер пропускает снятую с очереди операцию
+	if _, _, ok := s.activeOps.take("op-cancel"); ok {
+		t.Fatal("take() = true, want false for canceled operation")
+	}
+}
+
+func TestCancelOperation_TakenBeforeStart(t *testing.T) {
+	s := newTestServer(t, "")
+	item := &QueueItem{
+		RequestID:     "req-taken",
+		OperationID:   "op-taken",
+		Date:          "2024-12-01",
+		OperationType: OperationTypeLoad,
+		Logger:        s.logger,
+		CreatedAt:     time.Now(),
+	}
+	if err := s.enqueue(item); err != nil {
+		t.Fatalf("enqueue() unexpected error: %v", err)
+	}
+
+	// Воркер взял операцию, но pipeline еще не запущен: отмена уже доходит до его контекста
+	runCtx, finish, ok := s.activeOps.take(item.OperationID)
+	if !ok {
+		t.Fatal("take() = false, want true for queued operation")
+	}
+	defer finish()
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/operations/op-taken", nil)
+	rec := httptest.NewRecorder()
+	s.operationHandler(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("DELETE = %d, want 202: %s", rec.Code, rec.Body.String())
+	}
+	if runCtx.Err() == nil {
+		t.Fatal("operation context was not canceled")
+	}
+}
+
+func TestCancelOperation_RunningPipeline(t *testing.T) {
+	reportServer, reports := newReportRecorder(t)
+	s := newTestServer(t, "")
+	s.config.WebhookReportURL = reportServer.URL
+	s.config.WebhookReportResultWaitTimeout = time.Second
+
+	oldRunPipeline := runPipelineFunc
+	defer func() { runPipelineFunc = oldRunPipeline }()
+	started := make(chan struct{})
+	runPipelineFunc = func(ctx context.Context, logger *slog.Logger, cfg *models.Config, dates models.DateRange, kassas []string) (*pipeline.PipelineResult, error) {
+		close(started)
+		<-ctx.Done()
+		return &pipeline.PipelineResult{Status: pipeline.PipelineStatusCanceled, FilesProcessed: 2, TransactionsLoaded: 40}, ctx.Err()
+	}
+
+	runCtx, finish, _ := s.activeOps.take("op-running")
+	done := make(chan struct{})
+	go func() {
+		defer finish()
+		s.processQueueItem(runCtx, &QueueItem{
+			RequestID:     "req-running",
+			OperationID:   "op-running",
+			Date:          "2024-12-01",
+			OperationType: OperationTypeLoad,
+			Logger:        s.logger,
+			CreatedAt:     time.Now(),
+		})
+		close(done)
+	}()
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("pipeline did not start")
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/operations/op-running", nil)
+	rec := httptest.NewRecorder()
+	s.operationHandler(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("DELETE = %d, want 202: %s", rec.Code, rec.Body.String())
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("canceled pipeline did not release the queue")
+	}
+	select {
+	case report := <-reports:
+		if report.Status != "canceled" || report.FilesProcessed != 2 || report.TransactionsLoaded != 40 {
+			t.Fatalf("report = %+v, want canceled report with partial stats", report)
+		}
+	default:
+		t.Fatal("canceled report was not sent")
+	}
+}
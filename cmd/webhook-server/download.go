@@ -45,7 +45,7 @@ func (s *Server) processDownloadRequest(ctx context.Context, log *logger.Logger,
 		"event", "download_processing_start",
 	)
 
-	database, err := db.NewPool(s.config)
+	database, err := db.NewPool(s.currentConfig())
 	if err != nil {
 		log.ErrorContext(ctx, "Failed to connect to database",
 			"error", err.Error(),
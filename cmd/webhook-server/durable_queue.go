@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/logger"
+	"github.com/user/go-frontol-loader/pkg/operations"
+)
+
+const (
+	queueProviderPostgres = "postgres"
+	queueProviderMemory   = "memory"
+
+	// maxQueueAttempts ограничивает число захватов одной операции: операция,
+	// которая роняет сервер, не должна перезапускаться бесконечно.
+	maxQueueAttempts = 3
+)
+
+// queueSnapshot — размеры очередей на момент запроса.
+type queueSnapshot struct {
+	sizes  map[OperationType]int // ожидающие запросы по типам операций
+	total  int
+	active int
+	err    error // ошибка чтения durable-очереди; размеры тогда нулевые
+}
+
+// queueProvider возвращает используемую очередь: postgres (etl_operation_queue,
+// общая для всех реплик) или memory (каналы внутри процесса).
+func (s *Server) queueProvider() string {
+	if s.opStore != nil && strings.EqualFold(s.currentConfig().QueueProvider, queueProviderPostgres) {
+		return queueProviderPostgres
+	}
+	return queueProviderMemory
+}
+
+func (s *Server) durableQueue() bool {
+	return s.queueProvider() == queueProviderPostgres
+}
+
+// queueSnapshot возвращает размеры очередей. Для postgres это один запрос к БД;
+// при ошибке возвращаются нули, а ошибка сохраняется в err.
+func (s *Server) queueSnapshot(ctx context.Context) queueSnapshot {
+	if !s.durableQueue() {
+		return queueSnapshot{
+			sizes: map[OperationType]int{
+				OperationTypeLoad:     s.queueManager.GetQueueSize(OperationTypeLoad),
+				OperationTypeDownload: s.queueManager.GetQueueSize(OperationTypeDownload),
+			},
+			total:  s.queueManager.GetTotalSize(),
+			active: s.activeOps.runningCount(),
+		}
+	}
+
+	snapshot := queueSnapshot{sizes: map[OperationType]int{}}
+	stats, err := s.opStore.QueueStats(ctx)
+	if err != nil {
+		s.logger.WarnContext(ctx, "Failed to read durable queue stats",
+			"error", err.Error(),
+			"event", "durable_queue_stats_error",
+		)
+		snapshot.err = err
+		return snapshot
+	}
+	for operationType, count := range stats.Queued {
+		snapshot.sizes[OperationType(operationType)] = count
+		snapshot.total += count
+	}
+	for _, count := range stats.Processing {
+		snapshot.active += count
+	}
+	return snapshot
+}
+
+// enqueueDurable сохраняет запрос в etl_operation_queue и будит воркеры.
+func (s *Server) enqueueDurable(item *QueueItem) error {
+	err := s.opStore.Enqueue(context.Background(), operations.Job{
+		OperationID:   item.OperationID,
+		RequestID:     item.RequestID,
+		OperationType: string(item.OperationType),
+		Date:          item.Date,
+		DateTo:        item.DateTo,
+		Kassas:        item.Kassas,
+		EnqueuedAt:    item.CreatedAt,
+		TraceParent:   item.TraceParent,
+	})
+	if err != nil {
+		return err
+	}
+	s.wakeQueueWorkers()
+	return nil
+}
+
+// wakeQueueWorkers будит один простаивающий воркер, не дожидаясь интервала опроса.
+func (s *Server) wakeQueueWorkers() {
+	select {
+	case s.queueWake <- struct{}{}:
+	default:
+	}
+}
+
+// startDurableQueue запускает воркеры durable-очереди. Операции, прерванные
+// рестартом, забираются после истечения аренды: у нового процесса другой
+// идентификатор. Воркеры останавливаются в Stop.
+func (s *Server) startDurableQueue() {
+	if !s.durableQueue() {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.queueCancel = cancel
+	workers := s.currentConfig().EffectiveQueueWorkers()
+	for i := 0; i < workers; i++ {
+		s.workerWg.Add(1)
+		go s.runDurableQueueWorker(ctx, i)
+	}
+	s.logger.Info("Durable queue workers started",
+		"queue_provider", queueProviderPostgres,
+		"workers", workers,
+		"poll_interval", s.currentConfig().EffectiveQueuePollInterval().String(),
+		"lease_timeout", s.currentConfig().EffectiveQueueLeaseTimeout().String(),
+		"event", "durable_queue_started",
+	)
+}
+
+// runDurableQueueWorker забирает операции из etl_operation_queue, пока ctx не отменен.
+func (s *Server) runDurableQueueWorker(ctx context.Context, worker int) {
+	defer s.workerWg.Done()
+
+	pollInterval := s.currentConfig().EffectiveQueuePollInterval()
+	leaseTimeout := s.currentConfig().EffectiveQueueLeaseTimeout()
+	for {
+		job, err := s.opStore.Claim(ctx, leaseTimeout)
+		if err != nil && ctx.Err() == nil {
+			s.logger.Warn("Failed to claim operation from durable queue",
+				"worker", worker,
+				"error", err.Error(),
+				"event", "durable_queue_claim_error",
+			)
+		}
+		if job != nil {
+			// Следующую операцию может взять другой простаивающий воркер
+			s.wakeQueueWorkers()
+			s.processDurableJob(job, worker)
+			continue
+		}
+
+		timer := time.NewTimer(pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.logger.Info("Durable queue worker stopped",
+				"worker", worker,
+				"event", "durable_queue_worker_stopped",
+			)
+			return
+		case <-s.queueWake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// processDurableJob выполняет захваченную операцию, продлевая ее аренду, и
+// удаляет ее из очереди.
+func (s *Server) processDurableJob(job *operations.Job, worker int) {
+	item := s.queueItemFromJob(job)
+	log := item.Logger
+
+	if job.CancelRequested {
+		log.Info("Finishing operation canceled in durable queue",
+			"worker", worker,
+			"event", "durable_queue_item_canceled",
+		)
+		s.finishCanceled(item)
+		s.completeDurableJob(item, log)
+		return
+	}
+
+	if job.Attempts > maxQueueAttempts {
+		now := time.Now()
+		s.trackOperation(context.Background(), operations.Record{
+			OperationID:   item.OperationID,
+			RequestID:     item.RequestID,
+			OperationType: string(item.OperationType),
+			Status:        operations.StatusFailed,
+			Date:          item.dates().String(),
+			SourceFolder:  item.SourceFolder,
+			Component:     "webhook-server",
+			UpdatedAt:     now,
+			FinishedAt:    &now,
+			ErrorMessage:  fmt.Sprintf("operation interrupted %d times, giving up", job.Attempts-1),
+			FailedStage:   "queue_dispatch",
+		})
+		log.Error("Operation exceeded durable queue attempts",
+			"attempts", job.Attempts,
+			"max_attempts", maxQueueAttempts,
+			"event", "durable_queue_attempts_exceeded",
+		)
+		s.completeDurableJob(item, log)
+		return
+	}
+
+	log.Info("Taking next request from operation queue",
+		"request_id", item.RequestID,
+		"operation_id", item.OperationID,
+		"operation_type", item.OperationType,
+		"date", item.Date,
+		"kassas", item.Kassas,
+		"worker", worker,
+		"attempt", job.Attempts,
+		"queue_provider", queueProviderPostgres,
+		"event", "operation_queue_item_taken",
+	)
+
+	stopHeartbeat := s.startQueueHeartbeat(item.OperationID, log)
+	runCtx, done := s.activeOps.start(item.OperationID)
+	s.processQueueItem(runCtx, item)
+	done()
+	stopHeartbeat()
+	s.completeDurableJob(item, log)
+}
+
+func (s *Server) completeDurableJob(item *QueueItem, log *logger.Logger) {
+	if err := s.opStore.Complete(context.Background(), item.OperationID); err != nil {
+		log.Warn("Failed to remove finished operation from durable queue",
+			"error", err.Error(),
+			"event", "durable_queue_complete_error",
+		)
+	}
+}
+
+// startQueueHeartbeat продлевает аренду операции, пока она выполняется, чтобы
+// другие реплики не забрали ее, и отменяет операцию, если ее отмену запросили
+// через другую реплику или CLI. Возвращает функцию остановки.
+func (s *Server) startQueueHeartbeat(operationID string, log *logger.Logger) func() {
+	interval := s.currentConfig().EffectiveQueueLeaseTimeout() / 3
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				held, cancelRequested, err := s.opStore.Heartbeat(ctx, operationID)
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Warn("Failed to extend durable queue lease",
+							"error", err.Error(),
+							"event", "durable_queue_heartbeat_error",
+						)
+					}
+					continue
+				}
+				if !held {
+					log.Warn("Durable queue lease lost, operation may be taken over by another instance",
+						"event", "durable_queue_lease_lost",
+					)
+				}
+				if cancelRequested {
+					if outcome, _ := s.activeOps.cancel(operationID); outcome == cancelRunning {
+						log.Info("Running operation canceled via durable queue",
+							"event", "operation_cancel_running",
+						)
+					}
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// queueItemFromJob восстанавливает элемент очереди из строки etl_operation_queue.
+func (s *Server) queueItemFromJob(job *operations.Job) *QueueItem {
+	return &QueueItem{
+		RequestID:     job.RequestID,
+		OperationID:   job.OperationID,
+		Date:          job.Date,
+		DateTo:        job.DateTo,
+		OperationType: OperationType(job.OperationType),
+		SourceFolder:  strings.Join(job.Kassas, ","),
+		Kassas:        job.Kassas,
+		Logger:        s.logger.WithRequestID(job.RequestID).WithOperationID(job.OperationID),
+		CreatedAt:     job.EnqueuedAt,
+		TraceParent:   job.TraceParent,
+	}
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/logger"
+	"github.com/user/go-frontol-loader/pkg/operations"
+	"github.com/user/go-frontol-loader/pkg/pipeline"
+	"github.com/user/go-frontol-loader/pkg/tracing"
+	"github.com/user/go-frontol-loader/pkg/webhook"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	eventTypeKassa     = "kassa"     // состояние кассы изменилось
+	eventTypeOperation = "operation" // операция завершилась, событий больше не будет
+	eventTypeMoved     = "moved"     // операцию выполняет другая реплика
+
+	// eventHeader — тип события в запросе к подписчику.
+	eventHeader = "X-Frontol-Event"
+
+	// maxStreamHistory — сколько последних событий операции получает новый
+	// SSE-клиент.
+	maxStreamHistory = 1000
+	// eventSubscriberBuffer — события, которые клиент может не успеть забрать;
+	// отставший дальше клиент отключается и переподключается с Last-Event-ID.
+	eventSubscriberBuffer = 64
+	// eventKeepAlive — интервал комментариев, не дающих прокси закрыть поток.
+	eventKeepAlive = 15 * time.Second
+)
+
+// OperationEvent — событие хода операции в GET /api/operations/{id}/events и
+// у подписчиков с kassa_events.
+type OperationEvent struct {
+	ID          int64                `json:"id"`
+	Type        string               `json:"type"`
+	OperationID string               `json:"operation_id"`
+	RequestID   string               `json:"request_id,omitempty"`
+	Time        time.Time            `json:"time"`
+	Kassa       *pipeline.KassaEvent `json:"kassa,omitempty"`
+	Status      string               `json:"status,omitempty"` // статус операции в событиях operation и moved
+}
+
+// eventStream хранит события одной выполняющейся операции и раздает их
+// подписчикам.
+type eventStream struct {
+	operationID string
+	requestID   string
+
+	mu          sync.Mutex
+	lastID      int64
+	history     []OperationEvent
+	subscribers map[chan OperationEvent]struct{}
+	closed      bool
+}
+
+// publish нумерует событие, сохраняет его в истории и раздает подписчикам.
+// Подписчик с переполненным буфером отключается: публикация не ждет.
+func (st *eventStream) publish(event OperationEvent) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.closed {
+		return
+	}
+	st.lastID++
+	event.ID = st.lastID
+	event.OperationID = st.operationID
+	event.RequestID = st.requestID
+	event.Time = time.Now()
+	st.history = append(st.history, event)
+	if len(st.history) > maxStreamHistory {
+		st.history = st.history[len(st.history)-maxStreamHistory:]
+	}
+	for ch := range st.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(st.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// publishKassa публикует событие кассы из pipeline.
+func (st *eventStream) publishKassa(event pipeline.KassaEvent) {
+	st.publish(OperationEvent{Type: eventTypeKassa, Kassa: &event})
+}
+
+// subscribe возвращает сохраненные события после afterID и канал следующих.
+// Канал закрывается после события operation или при отставании подписчика;
+// у закрытого потока канал nil.
+func (st *eventStream) subscribe(afterID int64, buffer int) ([]OperationEvent, chan OperationEvent) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var replay []OperationEvent
+	for _, event := range st.history {
+		if event.ID > afterID {
+			replay = append(replay, event)
+		}
+	}
+	if st.closed {
+		return replay, nil
+	}
+	ch := make(chan OperationEvent, buffer)
+	if st.subscribers == nil {
+		st.subscribers = make(map[chan OperationEvent]struct{})
+	}
+	st.subscribers[ch] = struct{}{}
+	return replay, ch
+}
+
+// unsubscribe отключает подписчика ch.
+func (st *eventStream) unsubscribe(ch chan OperationEvent) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if _, ok := st.subscribers[ch]; ok {
+		delete(st.subscribers, ch)
+		close(ch)
+	}
+}
+
+// finish публикует итоговый статус операции и закрывает поток.
+func (st *eventStream) finish(status string) {
+	st.publish(OperationEvent{Type: eventTypeOperation, Status: status})
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.closed = true
+	for ch := range st.subscribers {
+		close(ch)
+	}
+	st.subscribers = nil
+}
+
+// isClosed сообщает, завершена ли операция потока.
+func (st *eventStream) isClosed() bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.closed
+}
+
+// operationEvents хранит потоки событий операций, выполняющихся на этом
+// экземпляре. Завершенная операция убирается: ее итог берется из
+// etl_operation_runs.
+type operationEvents struct {
+	mu      sync.Mutex
+	streams map[string]*eventStream
+	stopped chan struct{} // закрывается при остановке сервера и завершает SSE-потоки
+}
+
+// open возвращает поток операции, создавая его при первом вызове.
+func (h *operationEvents) open(operationID, requestID string) *eventStream {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if stream, ok := h.streams[operationID]; ok {
+		return stream
+	}
+	if h.streams == nil {
+		h.streams = make(map[string]*eventStream)
+	}
+	stream := &eventStream{operationID: operationID, requestID: requestID}
+	h.streams[operationID] = stream
+	return stream
+}
+
+// get возвращает поток операции или nil, если она здесь не выполняется.
+func (h *operationEvents) get(operationID string) *eventStream {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.streams[operationID]
+}
+
+// close завершает поток операции итоговым статусом.
+func (h *operationEvents) close(operationID, status string) {
+	h.mu.Lock()
+	stream := h.streams[operationID]
+	delete(h.streams, operationID)
+	h.mu.Unlock()
+	if stream != nil {
+		stream.finish(status)
+	}
+}
+
+// done возвращает канал, который закрывается при остановке сервера.
+func (h *operationEvents) done() <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stopped == nil {
+		h.stopped = make(chan struct{})
+	}
+	return h.stopped
+}
+
+// stop завершает SSE-потоки, чтобы они не задерживали остановку HTTP-сервера.
+func (h *operationEvents) stop() {
+	done := h.done()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	select {
+	case <-done:
+	default:
+		close(h.stopped)
+	}
+}
+
+// openOperationEvents открывает поток событий загрузки и запускает их
+// доставку подписчикам с kassa_events. Возвращает контекст, через который
+// pipeline публикует события касс.
+func (s *Server) openOperationEvents(ctx context.Context, operationID, requestID string, log *logger.Logger) context.Context {
+	stream := s.events.open(operationID, requestID)
+	for _, sub := range s.reportSubscribers() {
+		if !sub.KassaEvents {
+			continue
+		}
+		_, events := stream.subscribe(0, maxStreamHistory)
+		go s.forwardOperationEvents(context.WithoutCancel(ctx), sub, stream, events, log)
+	}
+	return pipeline.WithKassaEvents(ctx, stream.publishKassa)
+}
+
+// forwardOperationEvents отправляет подписчику sub события касс, подходящие
+// под его фильтры, одной попыткой каждое и по порядку. События — живой
+// прогресс: потерянное событие не повторяется, итог операции приходит
+// отчетом через outbox.
+func (s *Server) forwardOperationEvents(ctx context.Context, sub *webhook.Subscriber, stream *eventStream, events chan OperationEvent, log *logger.Logger) {
+	if events == nil {
+		return
+	}
+	for event := range events {
+		if event.Type != eventTypeKassa || !sub.MatchesKassaEvent(string(OperationTypeLoad), event.Kassa.SourceFolder) {
+			continue
+		}
+		if err := s.postOperationEvent(ctx, sub, event); err != nil {
+			log.Warn("Webhook kassa event failed",
+				"subscriber", sub.Name,
+				"source_folder", event.Kassa.SourceFolder,
+				"status", event.Kassa.Status,
+				"error", err.Error(),
+				"event", "webhook_kassa_event_failed",
+			)
+		}
+	}
+	// Канал закрыт до завершения операции: подписчик отстал от загрузки
+	if !stream.isClosed() {
+		log.Warn("Webhook kassa events dropped: subscriber is too slow",
+			"subscriber", sub.Name,
+			"event", "webhook_kassa_events_dropped",
+		)
+	}
+}
+
+// postOperationEvent отправляет событие подписчику sub. Шаблон подписчика
+// относится к отчетам, событие уходит JSON как есть.
+func (s *Server) postOperationEvent(ctx context.Context, sub *webhook.Subscriber, event OperationEvent) (err error) {
+	ctx, span := tracing.Start(ctx, "webhook.event",
+		attribute.String("etl.subscriber", sub.Name),
+		attribute.String("etl.source_folder", event.Kassa.SourceFolder),
+		attribute.String("etl.kassa_status", event.Kassa.Status),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	header := make(http.Header)
+	header.Set(eventHeader, event.Type)
+	_, err = s.postWebhook(ctx, sub, sub.URL, "application/json", body, header)
+	return err
+}
+
+// streamOperationEvents обрабатывает GET /api/operations/{operation_id}/events:
+// Server-Sent Events хода операции. Выполняющаяся здесь операция отдает
+// историю событий (после Last-Event-ID, если он передан) и затем новые
+// события до итогового operation. Ожидающая в очереди операция ждет начала
+// выполнения; завершенная сразу отдает итоговое событие.
+func (s *Server) streamOperationEvents(w http.ResponseWriter, r *http.Request, operationID string) {
+	ctx := r.Context()
+	log := s.logger.WithRequestID(r.Header.Get("X-Request-ID")).WithOperationID(operationID)
+	lastEventID, _ := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+
+	sse := newSSEWriter(w)
+	poll := time.NewTicker(s.currentConfig().EffectiveQueuePollInterval())
+	defer poll.Stop()
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		if stream := s.events.get(operationID); stream != nil {
+			s.relayOperationEvents(ctx, sse, stream, lastEventID, keepAlive.C)
+			return
+		}
+		local := s.activeOps.active(operationID)
+		record, err := s.opStore.Get(ctx, operationID)
+		if err != nil && !local {
+			log.ErrorContext(ctx, "Failed to get operation",
+				"error", err.Error(),
+				"event", "query_error",
+			)
+			if !sse.started {
+				http.Error(w, "Failed to retrieve operation", http.StatusInternalServerError)
+			}
+			return
+		}
+		switch {
+		case record == nil && !local:
+			if !sse.started {
+				http.Error(w, "Operation not found", http.StatusNotFound)
+			}
+			return
+		case record != nil && record.Status.Finished():
+			sse.send(OperationEvent{
+				Type:        eventTypeOperation,
+				OperationID: operationID,
+				RequestID:   record.RequestID,
+				Time:        time.Now(),
+				Status:      string(record.Status),
+			})
+			return
+		case record != nil && record.Status != operations.StatusQueued && !local:
+			if !sse.started {
+				http.Error(w, "Operation is running on another instance", http.StatusConflict)
+				return
+			}
+			// Пока клиент ждал, операцию взяла другая реплика
+			sse.send(OperationEvent{
+				Type:        eventTypeMoved,
+				OperationID: operationID,
+				RequestID:   record.RequestID,
+				Time:        time.Now(),
+				Status:      string(record.Status),
+			})
+			return
+		}
+
+		// Операция ждет в очереди: поток начинается, события придут после старта
+		sse.comment("queued")
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.events.done():
+			return
+		case <-keepAlive.C:
+		case <-poll.C:
+		}
+	}
+}
+
+// relayOperationEvents пересылает клиенту события потока stream после
+// lastEventID, пока операция не завершится или клиент не отключится.
+func (s *Server) relayOperationEvents(ctx context.Context, sse *sseWriter, stream *eventStream, lastEventID int64, keepAlive <-chan time.Time) {
+	replay, events := stream.subscribe(lastEventID, eventSubscriberBuffer)
+	if events != nil {
+		defer stream.unsubscribe(events)
+	}
+	for _, event := range replay {
+		if !sse.send(event) {
+			return
+		}
+	}
+	if events == nil {
+		return
+	}
+	sse.start()
+	if !sse.flush() {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.events.done():
+			return
+		case <-keepAlive:
+			if !sse.comment("keep-alive") {
+				return
+			}
+		case event, ok := <-events:
+			// Закрытый канал: операция завершилась или клиент отстал и
+			// переподключится с Last-Event-ID
+			if !ok || !sse.send(event) {
+				return
+			}
+		}
+	}
+}
+
+// sseWriter пишет поток text/event-stream.
+type sseWriter struct {
+	w          http.ResponseWriter
+	controller *http.ResponseController
+	started    bool
+}
+
+func newSSEWriter(w http.ResponseWriter) *sseWriter {
+	return &sseWriter{w: w, controller: http.NewResponseController(w)}
+}
+
+// start отправляет заголовки потока. Поток живет дольше HTTP_WRITE_TIMEOUT,
+// поэтому дедлайн записи снимается.
+func (sw *sseWriter) start() {
+	if sw.started {
+		return
+	}
+	sw.started = true
+	_ = sw.controller.SetWriteDeadline(time.Time{})
+	header := sw.w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	sw.w.WriteHeader(http.StatusOK)
+}
+
+// send пишет событие и возвращает false, если клиент отключился.
+func (sw *sseWriter) send(event OperationEvent) bool {
+	sw.start()
+	data, err := json.Marshal(event)
+	if err != nil {
+		return false
+	}
+	if _, err := fmt.Fprintf(sw.w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+		return false
+	}
+	return sw.flush()
+}
+
+// comment пишет комментарий, который клиенты пропускают.
+func (sw *sseWriter) comment(text string) bool {
+	sw.start()
+	if _, err := fmt.Fprintf(sw.w, ": %s\n\n", text); err != nil {
+		return false
+	}
+	return sw.flush()
+}
+
+func (sw *sseWriter) flush() bool {
+	return sw.controller.Flush() == nil
+}
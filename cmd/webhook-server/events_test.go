@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/pipeline"
+)
+
+// readSSEEvent читает следующее событие потока, пропуская комментарии.
+func readSSEEvent(t *testing.T, reader *bufio.Reader) (string, OperationEvent) {
+	t.Helper()
+	var eventType string
+	var event OperationEvent
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && eventType != "":
+			return eventType, event
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				t.Fatalf("decode event data %q: %v", line, err)
+			}
+		}
+	}
+}
+
+func TestStreamOperationEvents_RelaysRunningOperation(t *testing.T) {
+	s := newTestServer(t, "")
+	stream := s.events.open("op-live", "req-live")
+	stream.publishKassa(pipeline.KassaEvent{SourceFolder: "P13/P13", Status: "request_sent"})
+	stream.publishKassa(pipeline.KassaEvent{SourceFolder: "P13/P13", Status: "waiting_response"})
+
+	server := httptest.NewServer(http.HandlerFunc(s.operationHandler))
+	defer server.Close()
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/operations/op-live/events", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() unexpected error: %v", err)
+	}
+	// Клиент переподключается после первого события
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET events unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("GET events = %d %q, want 200 text/event-stream", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	reader := bufio.NewReader(resp.Body)
+
+	eventType, event := readSSEEvent(t, reader)
+	if eventType != eventTypeKassa || event.ID != 2 || event.Kassa.Status != "waiting_response" || event.RequestID != "req-live" {
+		t.Fatalf("replayed event = %s %+v, want kassa event 2 waiting_response", eventType, event)
+	}
+
+	stream.publishKassa(pipeline.KassaEvent{SourceFolder: "P13/P13", Status: "loaded", FilesProcessed: 1, Done: true})
+	eventType, event = readSSEEvent(t, reader)
+	if eventType != eventTypeKassa || event.ID != 3 || !event.Kassa.Done || event.Kassa.FilesProcessed != 1 {
+		t.Fatalf("live event = %s %+v, want final kassa event 3", eventType, event)
+	}
+
+	s.events.close("op-live", "completed")
+	eventType, event = readSSEEvent(t, reader)
+	if eventType != eventTypeOperation || event.Status != "completed" || event.OperationID != "op-live" {
+		t.Fatalf("last event = %s %+v, want operation completed", eventType, event)
+	}
+	if rest, _ := io.ReadAll(reader); len(rest) != 0 {
+		t.Fatalf("stream continued after operation event: %q", rest)
+	}
+	if s.events.get("op-live") != nil {
+		t.Fatal("finished operation stream should be removed")
+	}
+}
+
+func TestRunETLPipeline_ForwardsKassaEventsToSubscribers(t *testing.T) {
+	events := make(chan OperationEvent, 8)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(eventHeader) == eventTypeKassa {
+			var event OperationEvent
+			if err := json.NewDecoder(r.Body).Decode(&event); err == nil {
+				events <- event
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	s := newTestServer(t, "")
+	s.config.KassaStructure = map[string][]string{"P13": {"P13"}, "L32": {"L32"}}
+	s.config.WebhookSubscribers = []models.WebhookSubscriber{
+		{Name: "dashboard", URL: receiver.URL, Kassas: []string{"P13"}, KassaEvents: true},
+	}
+	if err := s.loadSubscribers(); err != nil {
+		t.Fatalf("loadSubscribers() unexpected error: %v", err)
+	}
+
+	oldRunPipeline := runPipelineFunc
+	defer func() { runPipelineFunc = oldRunPipeline }()
+	runPipelineFunc = func(ctx context.Context, logger *slog.Logger, cfg *models.Config, dates models.DateRange, kassas []string) (*pipeline.PipelineResult, error) {
+		// Тестовый pipeline публикует события так же, как processFolderLoad
+		publish := s.events.get("op-events").publishKassa
+		publish(pipeline.KassaEvent{SourceFolder: "L32/L32", Status: "request_sent"})
+		publish(pipeline.KassaEvent{SourceFolder: "P13/P13", Status: "request_sent"})
+		publish(pipeline.KassaEvent{SourceFolder: "P13/P13", Status: "loaded", Done: true})
+		return &pipeline.PipelineResult{Status: pipeline.PipelineStatusCompleted, Success: true}, nil
+	}
+
+	s.runETLPipeline(context.Background(), "op-events", "req-events", models.SingleDay("2024-12-01"), nil, s.logger)
+
+	for _, want := range []string{"request_sent", "loaded"} {
+		select {
+		case event := <-events:
+			if event.Kassa == nil || event.Kassa.SourceFolder != "P13/P13" || event.Kassa.Status != want {
+				t.Fatalf("forwarded event = %+v, want P13/P13 %s", event, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("kassa event %s was not forwarded", want)
+		}
+	}
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected forwarded event %+v", event.Kassa)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
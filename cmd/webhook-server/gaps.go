@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/db"
+	"github.com/user/go-frontol-loader/pkg/ftp"
+	"github.com/user/go-frontol-loader/pkg/logger"
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/operations"
+	"github.com/user/go-frontol-loader/pkg/repository"
+	"github.com/user/go-frontol-loader/pkg/scheduler"
+)
+
+// gapBackfillRequestID — request_id всех загрузок автоматической дозагрузки:
+// по нему находится, какие дни уже дозагружались.
+const gapBackfillRequestID = "gap_backfill"
+
+// gapQuery разбирает дни и кассы проверки из query string GET /api/gaps. Без
+// дат проверяются GAP_CHECK_DAYS дней до сегодняшнего.
+func (s *Server) gapQuery(values url.Values, now time.Time) (models.DateRange, []string, error) {
+	cfg := s.currentConfig()
+	dates := models.RecentDays(now, cfg.EffectiveGapCheckDays())
+	if from := values.Get("date_from"); from != "" {
+		parsed, err := models.ParseDateRange(from, values.Get("date_to"))
+		if err != nil {
+			return dates, nil, err
+		}
+		dates = parsed
+	} else if values.Get("date_to") != "" {
+		return dates, nil, fmt.Errorf("date_to requires date_from")
+	}
+
+	var kassas []string
+	for _, kassa := range strings.Split(values.Get("kassas"), ",") {
+		if kassa = strings.TrimSpace(kassa); kassa != "" {
+			kassas = append(kassas, kassa)
+		}
+	}
+	folders, err := gapSourceFolders(cfg, kassas)
+	if err != nil {
+		return dates, nil, err
+	}
+	return dates, folders, nil
+}
+
+// gapSourceFolders возвращает source_folder проверяемых касс: kassas или все
+// кассы KASSA_STRUCTURE.
+func gapSourceFolders(cfg *models.Config, kassas []string) ([]string, error) {
+	folders, err := ftp.SelectKassaFolders(cfg, kassas)
+	if err != nil {
+		return nil, err
+	}
+	sourceFolders := make([]string, 0, len(folders))
+	for _, folder := range folders {
+		sourceFolders = append(sourceFolders, folder.KassaCode+"/"+folder.FolderName)
+	}
+	return sourceFolders, nil
+}
+
+// findGaps ищет пробелы в данных касс sourceFolders за dates.
+func (s *Server) findGaps(ctx context.Context, sourceFolders []string, dates models.DateRange) ([]models.DataGap, error) {
+	database, err := db.NewPool(s.currentConfig())
+	if err != nil {
+		return nil, fmt.Errorf("connect to database: %w", err)
+	}
+	defer database.Close()
+	return repository.NewLoader(database).FindDataGaps(ctx, sourceFolders, dates)
+}
+
+// gapsHandler обрабатывает GET /api/gaps: дни касс без загрузки, загруженные
+// дни без транзакций и смены без закрытия.
+func (s *Server) gapsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	log := s.logger.WithRequestID(r.Header.Get("X-Request-ID"))
+
+	dates, sourceFolders, err := s.gapQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	gaps, err := s.findGaps(ctx, sourceFolders, dates)
+	if err != nil {
+		log.ErrorContext(ctx, "Failed to find data gaps",
+			"error", err.Error(),
+			"event", "query_error",
+		)
+		http.Error(w, "Failed to find data gaps", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"gaps":           gaps,
+		"count":          len(gaps),
+		"date_from":      dates.From,
+		"date_to":        dates.To,
+		"source_folders": sourceFolders,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.ErrorContext(ctx, "Failed to encode response",
+			"error", err.Error(),
+			"event", "response_encode_error",
+		)
+	}
+}
+
+// runGapBackfill дозагружает пробелы по расписанию cron, пока ctx не отменен.
+func (s *Server) runGapBackfill(ctx context.Context, cron *scheduler.Cron) {
+	defer s.workerWg.Done()
+
+	for {
+		var wake <-chan time.Time
+		var timer *time.Timer
+		if next := cron.Next(time.Now().In(s.scheduleLocation)); !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			wake = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-wake:
+		}
+		s.backfillGaps(ctx, time.Now().In(s.scheduleLocation))
+	}
+}
+
+// backfillGaps ищет пробелы за GAP_CHECK_DAYS дней до now и ставит целевые
+// загрузки касс с пробелами. Каждый день дозагружается один раз: день без
+// продаж остается пустым и после перезагрузки. Возвращает число поставленных
+// загрузок.
+func (s *Server) backfillGaps(ctx context.Context, now time.Time) int {
+	log := s.logger.WithRequestID(gapBackfillRequestID)
+
+	// Пока идут загрузки, их дни выглядят пробелами
+	active, err := s.opStore.List(ctx, operations.ListFilter{OperationType: string(OperationTypeLoad), Active: true, Limit: 1})
+	if err != nil {
+		log.Warn("Failed to check running loads, gap backfill skipped",
+			"error", err.Error(),
+			"event", "gap_backfill_error",
+		)
+		return 0
+	}
+	if len(active.Operations) > 0 {
+		log.Info("Gap backfill postponed: loads are running",
+			"running_operation_id", active.Operations[0].OperationID,
+			"event", "gap_backfill_postponed",
+		)
+		return 0
+	}
+
+	cfg := s.currentConfig()
+	dates := models.RecentDays(now, cfg.EffectiveGapCheckDays())
+	sourceFolders, err := gapSourceFolders(cfg, nil)
+	var gaps []models.DataGap
+	if err == nil {
+		gaps, err = s.findGaps(ctx, sourceFolders, dates)
+	}
+	if err != nil {
+		log.Error("Failed to find data gaps, gap backfill skipped",
+			"date", dates.String(),
+			"error", err.Error(),
+			"event", "gap_backfill_error",
+		)
+		return 0
+	}
+	return s.enqueueGapBackfills(ctx, endedDayGaps(cfg, gaps, now), dates, log)
+}
+
+// endedDayGaps оставляет пробелы дней, которые уже закончились в часовом поясе
+// кассы: касса западнее сервера еще торгует днем, который на сервере прошел.
+func endedDayGaps(cfg *models.Config, gaps []models.DataGap, now time.Time) []models.DataGap {
+	ended := make([]models.DataGap, 0, len(gaps))
+	for _, gap := range gaps {
+		kassaCode, _, _ := strings.Cut(gap.SourceFolder, "/")
+		if gap.Date < now.In(cfg.KassaLocation(kassaCode)).Format(models.DateLayout) {
+			ended = append(ended, gap)
+		}
+	}
+	return ended
+}
+
+func (s *Server) enqueueGapBackfills(ctx context.Context, gaps []models.DataGap, dates models.DateRange, log *logger.Logger) int {
+	pending := make([]models.DataGap, 0, len(gaps))
+	for _, gap := range gaps {
+		previous, err := s.opStore.List(ctx, operations.ListFilter{
+			RequestID:    gapBackfillRequestID,
+			SourceFolder: gap.SourceFolder,
+			Date:         gap.Date,
+			Limit:        1,
+		})
+		if err != nil {
+			log.Warn("Failed to check previous gap backfills, gap backfill skipped",
+				"error", err.Error(),
+				"event", "gap_backfill_error",
+			)
+			return 0
+		}
+		if len(previous.Operations) == 0 {
+			pending = append(pending, gap)
+		}
+	}
+
+	queued := 0
+	for _, backfill := range models.PlanGapBackfills(pending) {
+		operationID := logger.NewOperationID()
+		item := &QueueItem{
+			RequestID:     gapBackfillRequestID,
+			OperationID:   operationID,
+			Date:          backfill.Dates.From,
+			OperationType: OperationTypeLoad,
+			SourceFolder:  backfill.SourceFolder,
+			Kassas:        []string{backfill.SourceFolder},
+			Logger:        log.WithOperationID(operationID),
+			CreatedAt:     time.Now(),
+		}
+		if !backfill.Dates.IsSingleDay() {
+			item.DateTo = backfill.Dates.To
+		}
+		if _, err := s.enqueueServerLoad(item, "gap-backfill", time.Now()); err != nil {
+			item.Logger.Error("Failed to queue gap backfill load",
+				"source_folder", backfill.SourceFolder,
+				"date", backfill.Dates.String(),
+				"error", err.Error(),
+				"event", "gap_backfill_enqueue_error",
+			)
+			continue
+		}
+		queued++
+		item.Logger.Info("Gap backfill load queued",
+			"log_kind", "loki_operational",
+			"source_folder", backfill.SourceFolder,
+			"date", backfill.Dates.String(),
+			"event", "gap_backfill_queued",
+		)
+	}
+
+	log.Info("Gap check finished",
+		"log_kind", "loki_operational",
+		"date", dates.String(),
+		"gaps", len(gaps),
+		"already_backfilled", len(gaps)-len(pending),
+		"queued_loads", queued,
+		"event", "gap_check_finished",
+	)
+	return queued
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+func TestGapQuery(t *testing.T) {
+	s := newTestServer(t, "")
+	s.config.KassaStructure = map[string][]string{"P13": {"P13", "P13_Inter"}, "N22": {"N22"}}
+	s.config.GapCheckDays = 3
+	now := time.Date(2024, 12, 8, 15, 0, 0, 0, time.UTC)
+
+	dates, folders, err := s.gapQuery(url.Values{}, now)
+	if err != nil {
+		t.Fatalf("gapQuery() unexpected error: %v", err)
+	}
+	if dates != (models.DateRange{From: "2024-12-05", To: "2024-12-07"}) {
+		t.Fatalf("default dates = %+v, want the 3 days before today", dates)
+	}
+	if len(folders) != 3 {
+		t.Fatalf("default source folders = %v, want every folder of KASSA_STRUCTURE", folders)
+	}
+
+	dates, folders, err = s.gapQuery(url.Values{"date_from": {"2024-11-01"}, "kassas": {"P13"}}, now)
+	if err != nil {
+		t.Fatalf("gapQuery() unexpected error: %v", err)
+	}
+	if dates != models.SingleDay("2024-11-01") || !reflect.DeepEqual(folders, []string{"P13/P13", "P13/P13_Inter"}) {
+		t.Fatalf("gapQuery() = %+v %v, want 2024-11-01 for the folders of P13", dates, folders)
+	}
+}
+
+func TestGapsHandlerRejectsInvalidQuery(t *testing.T) {
+	s := newTestServer(t, "")
+	s.config.KassaStructure = map[string][]string{"P13": {"P13"}}
+	mux := newTestMux(s)
+
+	tests := []struct {
+		method string
+		target string
+		want   int
+	}{
+		{http.MethodPost, "/api/gaps", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/api/gaps?date_from=2024-13-01", http.StatusBadRequest},
+		{http.MethodGet, "/api/gaps?date_to=2024-12-01", http.StatusBadRequest},
+		{http.MethodGet, "/api/gaps?kassas=X99", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Fatalf("%s %s = %d, want %d: %s", tt.method, tt.target, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+}
+
+func TestEndedDayGaps(t *testing.T) {
+	cfg := &models.Config{KassaTimezones: map[string]string{"K01": "Europe/Kaliningrad", "V01": "Asia/Vladivostok"}}
+	// 00:30 7 декабря во Владивостоке: в Калининграде еще 6 декабря
+	now := time.Date(2024, 12, 6, 14, 30, 0, 0, time.UTC)
+	gaps := []models.DataGap{
+		{SourceFolder: "K01/K01", Date: "2024-12-05"},
+		{SourceFolder: "K01/K01", Date: "2024-12-06"},
+		{SourceFolder: "V01/V01", Date: "2024-12-06"},
+	}
+	got := endedDayGaps(cfg, gaps, now)
+	want := []models.DataGap{gaps[0], gaps[2]}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("endedDayGaps() = %+v, want %+v", got, want)
+	}
+}
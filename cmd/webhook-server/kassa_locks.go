@@ -0,0 +1,59 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// kassaLocks не дает загрузкам с общими папками касс выполняться одновременно:
+// полная загрузка занимает все папки, целевая — только свои source_folder.
+// Целевые загрузки разных касс выполняются параллельно.
+type kassaLocks struct {
+	all     sync.RWMutex
+	mu      sync.Mutex
+	folders map[string]*sync.Mutex
+}
+
+// acquire блокирует папки sourceFolders (все папки, если список пуст) и
+// возвращает функцию освобождения.
+func (l *kassaLocks) acquire(sourceFolders []string) func() {
+	if len(sourceFolders) == 0 {
+		l.all.Lock()
+		return l.all.Unlock
+	}
+
+	// Папки блокируются в одном порядке, чтобы пересекающиеся загрузки не
+	// заблокировали друг друга
+	folders := append([]string(nil), sourceFolders...)
+	sort.Strings(folders)
+	l.all.RLock()
+	locked := make([]*sync.Mutex, 0, len(folders))
+	for i, folder := range folders {
+		if i > 0 && folder == folders[i-1] {
+			continue
+		}
+		lock := l.folderLock(folder)
+		lock.Lock()
+		locked = append(locked, lock)
+	}
+	return func() {
+		for i := len(locked) - 1; i >= 0; i-- {
+			locked[i].Unlock()
+		}
+		l.all.RUnlock()
+	}
+}
+
+func (l *kassaLocks) folderLock(sourceFolder string) *sync.Mutex {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.folders == nil {
+		l.folders = make(map[string]*sync.Mutex)
+	}
+	lock, ok := l.folders[sourceFolder]
+	if !ok {
+		lock = &sync.Mutex{}
+		l.folders[sourceFolder] = lock
+	}
+	return lock
+}
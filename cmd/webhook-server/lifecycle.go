@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/operations"
+)
+
+// lifecyclePersistTimeout ограничивает запись одного статуса операции вместе
+// с подключением к БД: недоступная БД не задерживает следующие записи.
+const lifecyclePersistTimeout = 5 * time.Second
+
+// lifecycleWriter записывает статусы операций в etl_operation_runs в фоне и
+// по порядку постановки: запуск pipeline и отчеты не ждут БД, а более ранний
+// статус не перезапишет поздний.
+type lifecycleWriter struct {
+	mu      sync.Mutex
+	pending []lifecycleUpdate
+	writing bool
+	wg      sync.WaitGroup
+}
+
+type lifecycleUpdate struct {
+	ctx    context.Context
+	record operations.Record
+}
+
+// add ставит запись в очередь и при необходимости запускает горутину записи,
+// которая завершается, когда очередь пустеет.
+func (w *lifecycleWriter) add(ctx context.Context, record operations.Record, write func(context.Context, operations.Record)) {
+	w.mu.Lock()
+	w.pending = append(w.pending, lifecycleUpdate{ctx: context.WithoutCancel(ctx), record: record})
+	if w.writing {
+		w.mu.Unlock()
+		return
+	}
+	w.writing = true
+	w.wg.Add(1)
+	w.mu.Unlock()
+
+	go func() {
+		defer w.wg.Done()
+		for {
+			w.mu.Lock()
+			if len(w.pending) == 0 {
+				w.writing = false
+				w.mu.Unlock()
+				return
+			}
+			update := w.pending[0]
+			w.pending = w.pending[1:]
+			w.mu.Unlock()
+
+			ctx, cancel := context.WithTimeout(update.ctx, lifecyclePersistTimeout)
+			write(ctx, update.record)
+			cancel()
+		}
+	}()
+}
+
+// wait дожидается записи поставленных статусов.
+func (w *lifecycleWriter) wait() {
+	w.wg.Wait()
+}
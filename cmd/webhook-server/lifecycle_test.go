@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/operations"
+)
+
+func TestLifecycleWriterKeepsOrder(t *testing.T) {
+	var (
+		w       lifecycleWriter
+		mu      sync.Mutex
+		written []string
+	)
+	release := make(chan struct{})
+	write := func(ctx context.Context, record operations.Record) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("lifecycle write without deadline")
+		}
+		<-release
+		mu.Lock()
+		written = append(written, record.OperationID)
+		mu.Unlock()
+	}
+
+	// Пока БД не отвечает, постановка не ждет записи
+	ctx, cancel := context.WithCancel(context.Background())
+	for i := 0; i < 3; i++ {
+		w.add(ctx, operations.Record{OperationID: fmt.Sprintf("op-%d", i)}, write)
+	}
+	cancel()
+	close(release)
+
+	waited := make(chan struct{})
+	go func() {
+		w.wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatal("wait() did not return after writes")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if fmt.Sprint(written) != "[op-0 op-1 op-2]" {
+		t.Fatalf("written = %v, want in order", written)
+	}
+}
@@ -1,31 +1,103 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"os"
+	"sort"
+	"strings"
 	"time"
+	_ "time/tzdata" // SCHEDULE_TIMEZONE в образе без системной базы часовых поясов
 
 	"github.com/user/go-frontol-loader/pkg/config"
+	"github.com/user/go-frontol-loader/pkg/ftp"
 	"github.com/user/go-frontol-loader/pkg/logger"
+	"github.com/user/go-frontol-loader/pkg/models"
 	"github.com/user/go-frontol-loader/pkg/operations"
+	"github.com/user/go-frontol-loader/pkg/tracing"
 	"github.com/user/go-frontol-loader/pkg/validation"
 )
 
 // WebhookRequest представляет запрос к webhook
 type WebhookRequest struct {
-	Date string `json:"date"`
+	Date     string `json:"date"`
+	DateFrom string `json:"date_from"`
+	DateTo   string `json:"date_to"`
+	// Kassas ограничивает загрузку кассами: код кассы или source_folder (KASSA/FOLDER)
+	Kassas []string `json:"kassas"`
 }
 
 // WebhookResponse представляет ответ webhook
 type WebhookResponse struct {
-	Status    string `json:"status"`
-	Date      string `json:"date"`
-	Message   string `json:"message,omitempty"`
-	RequestID string `json:"request_id,omitempty"`
+	Status    string   `json:"status"`
+	Date      string   `json:"date"`
+	DateTo    string   `json:"date_to,omitempty"`
+	Kassas    []string `json:"kassas,omitempty"`
+	Message   string   `json:"message,omitempty"`
+	RequestID string   `json:"request_id,omitempty"`
+}
+
+// loadDates возвращает диапазон дат запроса на загрузку: одиночный date или
+// date_from/date_to. Пустой запрос означает сегодняшний день.
+func (req WebhookRequest) loadDates() (models.DateRange, error) {
+	if req.Date != "" && (req.DateFrom != "" || req.DateTo != "") {
+		return models.DateRange{}, validation.NewValidationError("date", "cannot be combined with date_from/date_to")
+	}
+	from := req.DateFrom
+	if from == "" {
+		from = req.Date
+	}
+	if from == "" {
+		if req.DateTo != "" {
+			return models.DateRange{}, validation.NewValidationError("date_from", "is required with date_to")
+		}
+		return models.SingleDay(time.Now().Format(models.DateLayout)), nil
+	}
+
+	fields := []struct{ name, value string }{{"date_from", from}, {"date_to", req.DateTo}}
+	if req.Date != "" {
+		fields[0].name = "date"
+	}
+	for _, field := range fields {
+		if field.value == "" {
+			continue
+		}
+		dateValidator := validation.NewComposite(
+			validation.DateFormat(field.name, models.DateLayout),
+			validation.NotInFuture(field.name, models.DateLayout),
+		)
+		if err := dateValidator.Validate(field.value); err != nil {
+			return models.DateRange{}, err
+		}
+	}
+	return models.ParseDateRange(from, req.DateTo)
+}
+
+// loadKassas проверяет целевые кассы запроса и возвращает их source_folder в
+// отсортированном виде; пустой список означает загрузку всех касс.
+func (s *Server) loadKassas(targets []string) ([]string, error) {
+	return kassaSourceFolders(s.currentConfig(), targets)
+}
+
+// kassaSourceFolders разрешает целевые кассы по кассам cfg, как loadKassas.
+func kassaSourceFolders(cfg *models.Config, targets []string) ([]string, error) {
+	if len(targets) == 0 {
+		return nil, nil
+	}
+	folders, err := ftp.SelectKassaFolders(cfg, targets)
+	if err != nil {
+		return nil, validation.NewValidationError("kassas", err.Error())
+	}
+	sourceFolders := make([]string, 0, len(folders))
+	for _, folder := range folders {
+		sourceFolders = append(sourceFolders, folder.KassaCode+"/"+folder.FolderName)
+	}
+	sort.Strings(sourceFolders)
+	return sourceFolders, nil
 }
 
 func requestIDFromRequest(r *http.Request) string {
@@ -82,47 +154,76 @@ func (s *Server) webhookHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Валидируем дату используя validation framework
-	date := req.Date
-	if date == "" {
-		date = time.Now().Format("2006-01-02")
-	} else {
-		// Создаем композитный валидатор для даты
-		dateValidator := validation.NewComposite(
-			validation.DateFormat("date", "2006-01-02"),
-			validation.NotInFuture("date", "2006-01-02"),
+	// Валидируем дату или диапазон дат используя validation framework
+	dates, err := req.loadDates()
+	if err != nil {
+		log.ErrorContext(ctx, "Date validation failed",
+			"date", req.Date,
+			"date_from", req.DateFrom,
+			"date_to", req.DateTo,
+			"error", err.Error(),
+			"event", "date_validation_error",
 		)
+		logAPIRequestRejected(ctx, log, audit, http.StatusBadRequest, "invalid_date",
+			"date", req.Date,
+			"date_from", req.DateFrom,
+			"date_to", req.DateTo,
+		)
+		http.Error(w, fmt.Sprintf("Invalid date: %v", err), http.StatusBadRequest)
+		return
+	}
+	date := dates.String()
 
-		if err := dateValidator.Validate(date); err != nil {
-			log.ErrorContext(ctx, "Date validation failed",
-				"date", date,
-				"error", err.Error(),
-				"event", "date_validation_error",
-			)
-			logAPIRequestRejected(ctx, log, audit, http.StatusBadRequest, "invalid_date", "date", date)
-			http.Error(w, fmt.Sprintf("Invalid date: %v", err), http.StatusBadRequest)
-			return
-		}
+	kassas, err := s.loadKassas(req.Kassas)
+	if err != nil {
+		log.ErrorContext(ctx, "Kassa validation failed",
+			"kassas", req.Kassas,
+			"error", err.Error(),
+			"event", "kassa_validation_error",
+		)
+		logAPIRequestRejected(ctx, log, audit, http.StatusBadRequest, "invalid_kassas", "kassas", req.Kassas)
+		http.Error(w, fmt.Sprintf("Invalid kassas: %v", err), http.StatusBadRequest)
+		return
 	}
+	sourceFolder := strings.Join(kassas, ",")
 
 	// Добавляем запрос в очередь для типа операции "load"
 	queueItem := &QueueItem{
 		RequestID:     requestID,
 		OperationID:   operationID,
-		Date:          date,
+		Date:          dates.From,
+		DateTo:        dates.To,
 		OperationType: OperationTypeLoad,
+		SourceFolder:  sourceFolder,
+		Kassas:        kassas,
 		Logger:        log,
 		CreatedAt:     time.Now(),
+		TraceParent:   tracing.TraceParent(ctx),
 	}
 
+	// Статус queued фиксируется до постановки: с durable-очередью операцию сразу
+	// может взять другая реплика, и queued не должен затереть processing
+	s.trackOperation(ctx, operations.Record{
+		OperationID:   operationID,
+		RequestID:     requestID,
+		OperationType: string(OperationTypeLoad),
+		Status:        operations.StatusQueued,
+		Date:          date,
+		SourceFolder:  sourceFolder,
+		Component:     "webhook-server",
+		StartedAt:     queueItem.CreatedAt,
+		UpdatedAt:     time.Now(),
+	})
 	if err := s.enqueue(queueItem); err != nil {
 		now := time.Now()
+		queue := s.queueSnapshot(ctx)
 		s.trackOperation(ctx, operations.Record{
 			OperationID:   operationID,
 			RequestID:     requestID,
 			OperationType: string(OperationTypeLoad),
 			Status:        operations.StatusFailed,
 			Date:          date,
+			SourceFolder:  sourceFolder,
 			Component:     "webhook-server",
 			StartedAt:     queueItem.CreatedAt,
 			UpdatedAt:     now,
@@ -134,36 +235,31 @@ func (s *Server) webhookHandler(w http.ResponseWriter, r *http.Request) {
 			"error", err.Error(),
 			"operation_type", OperationTypeLoad,
 			"date", date,
-			"queue_size", s.queueManager.GetQueueSize(OperationTypeLoad),
+			"queue_size", queue.sizes[OperationTypeLoad],
 			"event", "queue_enqueue_error",
 		)
 		logAPIRequestRejected(ctx, log, audit, http.StatusServiceUnavailable, "queue_unavailable",
 			"date", date,
-			"queue_size_for_operation", s.queueManager.GetQueueSize(OperationTypeLoad),
-			"total_queue_size", s.queueManager.GetTotalSize(),
+			"queue_size_for_operation", queue.sizes[OperationTypeLoad],
+			"total_queue_size", queue.total,
 		)
 		http.Error(w, "Service unavailable: queue is full", http.StatusServiceUnavailable)
 		return
 	}
-	s.trackOperation(ctx, operations.Record{
-		OperationID:   operationID,
-		RequestID:     requestID,
-		OperationType: string(OperationTypeLoad),
-		Status:        operations.StatusQueued,
-		Date:          date,
-		Component:     "webhook-server",
-		StartedAt:     queueItem.CreatedAt,
-		UpdatedAt:     time.Now(),
-	})
 
 	// Отвечаем клиенту немедленно
 	response := WebhookResponse{
 		Status:    "queued",
-		Date:      date,
+		Date:      dates.From,
+		Kassas:    kassas,
 		Message:   "Request added to queue",
 		RequestID: requestID,
 	}
 
+	if !dates.IsSingleDay() {
+		response.DateTo = dates.To
+	}
+
 	w.Header().Set("X-Operation-ID", operationID)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
@@ -175,18 +271,21 @@ func (s *Server) webhookHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	queue := s.queueSnapshot(ctx)
 	log.InfoContext(ctx, "Request added to operation queue",
 		"log_kind", "loki_operational",
 		"operation_type", OperationTypeLoad,
 		"date", date,
-		"queue_size_for_operation", s.queueManager.GetQueueSize(OperationTypeLoad),
-		"total_queue_size", s.queueManager.GetTotalSize(),
+		"kassas", kassas,
+		"queue_size_for_operation", queue.sizes[OperationTypeLoad],
+		"total_queue_size", queue.total,
 		"event", "request_queued",
 	)
 	logAPIRequestCompleted(ctx, log, audit, http.StatusAccepted, "queued",
 		"date", date,
-		"queue_size_for_operation", s.queueManager.GetQueueSize(OperationTypeLoad),
-		"total_queue_size", s.queueManager.GetTotalSize(),
+		"kassas", kassas,
+		"queue_size_for_operation", queue.sizes[OperationTypeLoad],
+		"total_queue_size", queue.total,
 	)
 }
 
@@ -358,6 +457,19 @@ func main() {
 		os.Exit(1)
 	}
 
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		slog.Error("Failed to set up tracing",
+			"error", err.Error(),
+		)
+		os.Exit(1)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = shutdownTracing(ctx)
+	}()
+
 	// Создаем и запускаем сервер
 	server := NewServer(cfg)
 	if err := server.Run(); err != nil {
@@ -11,11 +11,13 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/user/go-frontol-loader/pkg/auth"
 	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/operations"
 	"github.com/user/go-frontol-loader/pkg/pipeline"
 )
 
@@ -40,6 +42,8 @@ func newTestServer(t *testing.T, token string) *Server {
 	}
 
 	s := NewServer(cfg)
+	// Без хранилища операций тесты не обращаются к БД и DNS
+	s.opStore = nil
 	// Prevent worker start in tests by marking queues active.
 	loadQueue := s.queueManager.GetOrCreateQueue(OperationTypeLoad)
 	loadQueue.workerStarted = true
@@ -56,8 +60,16 @@ func newTestMux(s *Server) *http.ServeMux {
 	mux.HandleFunc("/api/load", bearerAuth(s.webhookHandler))
 	mux.HandleFunc("/api/files", bearerAuth(s.downloadHandler))
 	mux.HandleFunc("/api/queue/status", bearerAuth(s.queueStatusHandler))
+	mux.HandleFunc("/api/operations", bearerAuth(s.listOperationsHandler))
+	mux.HandleFunc("/api/operations/", bearerAuth(s.operationHandler))
+	mux.HandleFunc("/api/schedules", bearerAuth(s.schedulesHandler))
+	mux.HandleFunc("/api/gaps", bearerAuth(s.gapsHandler))
+	mux.HandleFunc("/api/reports", bearerAuth(s.reportsHandler))
 	mux.HandleFunc("/api/kassas", bearerAuth(s.listKassasHandler))
+	mux.HandleFunc("/api/rejected-lines", bearerAuth(s.listRejectedLinesHandler))
+	mux.HandleFunc("/api/rejected-lines/reprocess", bearerAuth(s.reprocessRejectedLinesHandler))
 	mux.HandleFunc("/api/health", s.healthHandler)
+	mux.HandleFunc("/metrics", s.metricsHandler)
 	mux.HandleFunc("/api/docs", s.docsHandler)
 	mux.HandleFunc("/api/openapi.yaml", s.openAPIHandler)
 
@@ -202,6 +214,9 @@ func TestQueueStatusHandler_OK(t *testing.T) {
 	if shuttingDown, ok := payload["is_shutting_down"].(bool); !ok || shuttingDown {
 		t.Fatalf("expected is_shutting_down=false, got %v", payload["is_shutting_down"])
 	}
+	if payload["queue_provider"] != "memory" {
+		t.Fatalf("expected queue_provider=memory, got %v", payload["queue_provider"])
+	}
 }
 
 func TestDocsHandler_OK(t *testing.T) {
@@ -368,6 +383,16 @@ func TestStopDrainsLoadQueue(t *testing.T) {
 		WaitDelayMinutes:   time.Millisecond,
 		WebhookBearerToken: "token",
 	})
+	s.opStore = nil
+
+	// Pipeline без FTP и БД: тест проверяет только дренирование очереди
+	oldRunPipeline := runPipelineFunc
+	defer func() { runPipelineFunc = oldRunPipeline }()
+	var runs atomic.Int32
+	runPipelineFunc = func(ctx context.Context, logger *slog.Logger, cfg *models.Config, dates models.DateRange, kassas []string) (*pipeline.PipelineResult, error) {
+		runs.Add(1)
+		return &pipeline.PipelineResult{Status: pipeline.PipelineStatusCompleted, Success: true}, nil
+	}
 
 	queue := s.queueManager.GetOrCreateQueue(OperationTypeLoad)
 	queue.workerStarted = false
@@ -399,6 +424,9 @@ func TestStopDrainsLoadQueue(t *testing.T) {
 	if got := s.queueManager.GetQueueSize(OperationTypeLoad); got != 0 {
 		t.Fatalf("expected load queue to drain, got %d items", got)
 	}
+	if got := runs.Load(); got != 2 {
+		t.Fatalf("pipeline runs = %d, want 2", got)
+	}
 }
 
 func TestRunETLPipelineTimeoutDoesNotReleaseQueueEarly(t *testing.T) {
@@ -415,7 +443,7 @@ func TestRunETLPipelineTimeoutDoesNotReleaseQueueEarly(t *testing.T) {
 
 	pipelineStarted := make(chan struct{}, 1)
 	allowFinish := make(chan struct{})
-	runPipelineFunc = func(ctx context.Context, logger *slog.Logger, cfg *models.Config, date string) (*pipeline.PipelineResult, error) {
+	runPipelineFunc = func(ctx context.Context, logger *slog.Logger, cfg *models.Config, dates models.DateRange, kassas []string) (*pipeline.PipelineResult, error) {
 		pipelineStarted <- struct{}{}
 		<-allowFinish
 		return &pipeline.PipelineResult{Status: pipeline.PipelineStatusCompleted, Success: true}, nil
@@ -426,7 +454,7 @@ func TestRunETLPipelineTimeoutDoesNotReleaseQueueEarly(t *testing.T) {
 
 	done := make(chan struct{})
 	go func() {
-		s.runETLPipeline("op-timeout", "req-timeout", "2026-03-23", s.logger)
+		s.runETLPipeline(context.Background(), "op-timeout", "req-timeout", models.SingleDay("2026-03-23"), nil, s.logger)
 		close(done)
 	}()
 
@@ -483,7 +511,7 @@ func TestRunETLPipelineSendsFinalReportAfterTimeout(t *testing.T) {
 
 	pipelineStarted := make(chan struct{}, 1)
 	allowFinish := make(chan struct{})
-	runPipelineFunc = func(ctx context.Context, logger *slog.Logger, cfg *models.Config, date string) (*pipeline.PipelineResult, error) {
+	runPipelineFunc = func(ctx context.Context, logger *slog.Logger, cfg *models.Config, dates models.DateRange, kassas []string) (*pipeline.PipelineResult, error) {
 		pipelineStarted <- struct{}{}
 		<-allowFinish
 		return &pipeline.PipelineResult{Status: pipeline.PipelineStatusCompleted, Success: true}, nil
@@ -494,7 +522,7 @@ func TestRunETLPipelineSendsFinalReportAfterTimeout(t *testing.T) {
 
 	done := make(chan struct{})
 	go func() {
-		s.runETLPipeline("op-timeout-final", "req-timeout-final", "2026-03-23", s.logger)
+		s.runETLPipeline(context.Background(), "op-timeout-final", "req-timeout-final", models.SingleDay("2026-03-23"), nil, s.logger)
 		close(done)
 	}()
 
@@ -605,3 +633,187 @@ func TestWebhookHandler_DefaultDate(t *testing.T) {
 		t.Fatalf("expected YYYY-MM-DD date, got %s", payload.Date)
 	}
 }
+
+func TestWebhookHandler_DateRange(t *testing.T) {
+	s := newTestServer(t, "token")
+	mux := newTestMux(s)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/load", strings.NewReader(`{"date_from":"2024-12-01","date_to":"2024-12-31"}`))
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rec.Code)
+	}
+	var payload WebhookResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if payload.Date != "2024-12-01" || payload.DateTo != "2024-12-31" {
+		t.Fatalf("expected range 2024-12-01..2024-12-31, got %s..%s", payload.Date, payload.DateTo)
+	}
+}
+
+func TestWebhookHandler_InvalidDateRange(t *testing.T) {
+	s := newTestServer(t, "token")
+	mux := newTestMux(s)
+
+	bodies := []string{
+		`{"date":"2024-12-01","date_to":"2024-12-02"}`,
+		`{"date_to":"2024-12-02"}`,
+		`{"date_from":"2024-12-02","date_to":"2024-12-01"}`,
+		`{"date_from":"2024-01-01","date_to":"2024-12-31"}`,
+		`{"date_from":"2024-12-01","date_to":"2999-01-01"}`,
+	}
+	for _, body := range bodies {
+		req := httptest.NewRequest(http.MethodPost, "/api/load", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", body, rec.Code)
+		}
+	}
+}
+
+func TestWebhookHandler_InvalidKassas(t *testing.T) {
+	s := newTestServer(t, "token")
+	s.config.KassaStructure = map[string][]string{"P13": {"P13"}}
+	mux := newTestMux(s)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/load", strings.NewReader(`{"kassas":["P14"]}`))
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestTargetedLoadsForDifferentKassasRunInParallel(t *testing.T) {
+	s := NewServer(&models.Config{
+		FTPRequestDir:   "/request",
+		FTPResponseDir:  "/response",
+		ShutdownTimeout: 3 * time.Second,
+		KassaStructure:  map[string][]string{"P13": {"P13"}, "L32": {"L32", "L32_INTER"}},
+	})
+	s.opStore = nil
+	mux := newTestMux(s)
+
+	oldRunPipeline := runPipelineFunc
+	defer func() { runPipelineFunc = oldRunPipeline }()
+	started := make(chan string, 2)
+	fullStarted := make(chan int32, 1)
+	allowFinish := make(chan struct{})
+	var finished atomic.Int32
+	runPipelineFunc = func(ctx context.Context, logger *slog.Logger, cfg *models.Config, dates models.DateRange, kassas []string) (*pipeline.PipelineResult, error) {
+		if len(kassas) == 0 {
+			// Полная загрузка ждет папки целевых: к ее старту они завершены
+			fullStarted <- finished.Load()
+		} else {
+			started <- strings.Join(kassas, ",")
+			<-allowFinish
+			finished.Add(1)
+		}
+		return &pipeline.PipelineResult{Status: pipeline.PipelineStatusCompleted, Success: true}, nil
+	}
+
+	post := func(body string) {
+		req := httptest.NewRequest(http.MethodPost, "/api/load", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("%s: expected 202, got %d", body, rec.Code)
+		}
+	}
+	post(`{"kassas":["P13"]}`)
+	post(`{"kassas":["L32"]}`)
+
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case kassas := <-started:
+			got[kassas] = true
+		case <-time.After(time.Second):
+			t.Fatalf("targeted loads started = %v, want both without waiting for each other", got)
+		}
+	}
+	if !got["P13/P13"] || !got["L32/L32,L32/L32_INTER"] {
+		t.Fatalf("targeted loads started = %v", got)
+	}
+	post(`{}`)
+
+	close(allowFinish)
+	select {
+	case done := <-fullStarted:
+		if done != 2 {
+			t.Fatalf("full load started after %d targeted loads finished, want 2", done)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("full load did not start after targeted loads finished")
+	}
+	s.Stop()
+
+	// Опустевшие полосы удаляются вместе с воркерами
+	s.queueManager.mu.RLock()
+	lanes := len(s.queueManager.operationQueues)
+	s.queueManager.mu.RUnlock()
+	if lanes != 0 {
+		t.Fatalf("lanes after drain = %d, want 0", lanes)
+	}
+}
+
+func TestWebhookHandler_DurableQueueUnavailable(t *testing.T) {
+	s := newTestServer(t, "token")
+	s.config.QueueProvider = "postgres"
+	// БД на закрытом локальном порту отказывает сразу, без DNS
+	s.config.DBHost = "127.0.0.1"
+	s.config.DBPort = 1
+	s.opStore = operations.NewStore(s.config, s.logger)
+	t.Cleanup(s.lifecycle.wait)
+	mux := newTestMux(s)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/load", strings.NewReader(`{"date":"2024-12-01"}`))
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	// Без БД запрос не ставится в очередь, а не теряется в памяти процесса
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := s.queueManager.GetTotalSize(); got != 0 {
+		t.Fatalf("expected in-memory queue to stay empty, got %d", got)
+	}
+}
+
+func TestQueueItemFromJobRestoresRequest(t *testing.T) {
+	s := newTestServer(t, "")
+	enqueuedAt := time.Date(2024, 12, 2, 10, 0, 0, 0, time.UTC)
+	item := s.queueItemFromJob(&operations.Job{
+		OperationID:   "op-1",
+		RequestID:     "req-1",
+		OperationType: string(OperationTypeLoad),
+		Date:          "2024-12-01",
+		DateTo:        "2024-12-03",
+		Kassas:        []string{"L32/L32_INTER", "P13/P13"},
+		EnqueuedAt:    enqueuedAt,
+		Attempts:      1,
+	})
+
+	if item.OperationID != "op-1" || item.RequestID != "req-1" || item.OperationType != OperationTypeLoad {
+		t.Fatalf("queueItemFromJob() = %+v, want op-1/req-1/load", item)
+	}
+	if got := item.dates().String(); got != "2024-12-01..2024-12-03" {
+		t.Fatalf("dates = %q, want 2024-12-01..2024-12-03", got)
+	}
+	if item.SourceFolder != "L32/L32_INTER,P13/P13" || item.lane() != item.SourceFolder {
+		t.Fatalf("source folder = %q, lane = %q", item.SourceFolder, item.lane())
+	}
+	if !item.CreatedAt.Equal(enqueuedAt) || item.Logger == nil {
+		t.Fatalf("created_at = %v, logger = %v", item.CreatedAt, item.Logger)
+	}
+}
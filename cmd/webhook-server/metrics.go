@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/user/go-frontol-loader/pkg/metrics"
+)
+
+// metricsHandler обрабатывает GET /metrics: метрики Prometheus. Размеры очередей
+// снимаются в момент запроса, остальные метрики копятся pipeline, FTP-пулом,
+// загрузчиком БД и Loki writer. Если размеры durable-очереди прочитать не
+// удалось, gauge очередей сохраняют прошлые значения, а ошибка считается в
+// frontol_etl_queue_stats_errors_total.
+func (s *Server) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	queue := s.queueSnapshot(r.Context())
+	if queue.err != nil {
+		metrics.ObserveQueueStatsError()
+		metrics.Handler().ServeHTTP(w, r)
+		return
+	}
+	// Типы операций без ожидающих запросов экспортируются нулем, а не пропадают
+	sizes := map[string]int{
+		string(OperationTypeLoad):     0,
+		string(OperationTypeDownload): 0,
+	}
+	for operationType, size := range queue.sizes {
+		sizes[string(operationType)] = size
+	}
+	metrics.SetQueue(sizes, queue.active)
+
+	metrics.Handler().ServeHTTP(w, r)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/user/go-frontol-loader/pkg/metrics"
+	"github.com/user/go-frontol-loader/pkg/operations"
+)
+
+func TestMetricsHandlerExposesQueueGauges(t *testing.T) {
+	s := newTestServer(t, "token")
+	mux := newTestMux(s)
+
+	loadQueue := s.queueManager.GetOrCreateQueue(OperationTypeLoad)
+	if err := loadQueue.Enqueue(&QueueItem{RequestID: "1"}); err != nil {
+		t.Fatalf("enqueue load: %v", err)
+	}
+	// Ожидающий запрос не считается выполняющейся операцией
+	_, done := s.activeOps.start("op-metrics")
+	defer done()
+
+	// /metrics открыт без токена, как /api/health, чтобы Prometheus мог его опрашивать
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`frontol_etl_queue_size{operation_type="load"} 1`,
+		`frontol_etl_queue_size{operation_type="download"} 0`,
+		"frontol_etl_active_operations 1\n",
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics output missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsHandlerKeepsQueueGaugesWhenStatsFail(t *testing.T) {
+	s := newTestServer(t, "")
+	s.config.QueueProvider = "postgres"
+	// БД на закрытом локальном порту отказывает сразу, без DNS
+	s.config.DBHost = "127.0.0.1"
+	s.config.DBPort = 1
+	s.opStore = operations.NewStore(s.config, s.logger)
+	metrics.SetQueue(map[string]int{string(OperationTypeLoad): 3}, 2)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+	s.metricsHandler(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	// Ошибка БД не выдается за пустую очередь
+	for _, want := range []string{
+		`frontol_etl_queue_size{operation_type="load"} 3`,
+		"frontol_etl_active_operations 2\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics output missing %q:\n%s", want, body)
+		}
+	}
+	if !regexp.MustCompile(`(?m)^frontol_etl_queue_stats_errors_total [1-9]`).MatchString(body) {
+		t.Fatalf("metrics output missing queue stats errors:\n%s", body)
+	}
+}
+
+func TestMetricsHandler_MethodNotAllowed(t *testing.T) {
+	s := newTestServer(t, "")
+	req := httptest.NewRequest(http.MethodPost, "/metrics", nil)
+	rec := httptest.NewRecorder()
+	s.metricsHandler(rec, req)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/operations"
+)
+
+// operationStatuses — статусы etl_operation_runs, допустимые в фильтре status.
+var operationStatuses = map[operations.Status]bool{
+	operations.StatusStarted:         true,
+	operations.StatusQueued:          true,
+	operations.StatusProcessing:      true,
+	operations.StatusCompleted:       true,
+	operations.StatusPartial:         true,
+	operations.StatusFailed:          true,
+	operations.StatusTimeoutReported: true,
+	operations.StatusAbandoned:       true,
+	operations.StatusCanceled:        true,
+	operations.StatusSkipped:         true,
+}
+
+// operationFilterFromQuery разбирает фильтр из query string GET /api/operations.
+func operationFilterFromQuery(values url.Values) (operations.ListFilter, error) {
+	filter := operations.ListFilter{
+		Status:        operations.Status(values.Get("status")),
+		OperationType: values.Get("type"),
+		Date:          values.Get("date"),
+		SourceFolder:  values.Get("source_folder"),
+	}
+	if filter.Status != "" && !operationStatuses[filter.Status] {
+		return filter, fmt.Errorf("unknown status %q", filter.Status)
+	}
+	if filter.Date != "" {
+		if _, err := time.Parse(models.DateLayout, filter.Date); err != nil {
+			return filter, fmt.Errorf("date must be in YYYY-MM-DD format")
+		}
+	}
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > 1000 {
+			return filter, fmt.Errorf("limit must be an integer between 1 and 1000")
+		}
+		filter.Limit = limit
+	}
+	if raw := values.Get("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return filter, fmt.Errorf("offset must be a non-negative integer")
+		}
+		filter.Offset = offset
+	}
+	return filter, nil
+}
+
+// listOperationsHandler обрабатывает GET /api/operations: история операций
+// из etl_operation_runs, новые первыми.
+func (s *Server) listOperationsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	log := s.logger.WithRequestID(r.Header.Get("X-Request-ID"))
+
+	filter, err := operationFilterFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	page, err := s.opStore.List(ctx, filter)
+	if err != nil {
+		log.ErrorContext(ctx, "Failed to list operations",
+			"error", err.Error(),
+			"event", "query_error",
+		)
+		http.Error(w, "Failed to retrieve operations", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"operations": page.Operations,
+		"count":      len(page.Operations),
+		"total":      page.Total,
+		"limit":      page.Limit,
+		"offset":     page.Offset,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.ErrorContext(ctx, "Failed to encode response",
+			"error", err.Error(),
+			"event", "response_encode_error",
+		)
+	}
+}
+
+// operationHandler обрабатывает запросы к /api/operations/{operation_id} и
+// /api/operations/{operation_id}/events.
+func (s *Server) operationHandler(w http.ResponseWriter, r *http.Request) {
+	operationID := strings.TrimPrefix(r.URL.Path, "/api/operations/")
+	if eventsOperationID, ok := strings.CutSuffix(operationID, "/events"); ok {
+		if eventsOperationID == "" || strings.Contains(eventsOperationID, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.streamOperationEvents(w, r, eventsOperationID)
+		return
+	}
+	if operationID == "" || strings.Contains(operationID, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.getOperation(w, r, operationID)
+	case http.MethodDelete:
+		s.cancelOperation(w, r, operationID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getOperation возвращает статус операции и сохраненный итог pipeline.
+func (s *Server) getOperation(w http.ResponseWriter, r *http.Request, operationID string) {
+	ctx := r.Context()
+	log := s.logger.WithRequestID(r.Header.Get("X-Request-ID")).WithOperationID(operationID)
+
+	record, err := s.opStore.Get(ctx, operationID)
+	if err != nil {
+		log.ErrorContext(ctx, "Failed to get operation",
+			"error", err.Error(),
+			"event", "query_error",
+		)
+		http.Error(w, "Failed to retrieve operation", http.StatusInternalServerError)
+		return
+	}
+	if record == nil {
+		http.Error(w, "Operation not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(record); err != nil {
+		log.ErrorContext(ctx, "Failed to encode response",
+			"error", err.Error(),
+			"event", "response_encode_error",
+		)
+	}
+}
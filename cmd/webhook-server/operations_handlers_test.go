@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/user/go-frontol-loader/pkg/operations"
+)
+
+func TestOperationFilterFromQuery(t *testing.T) {
+	filter, err := operationFilterFromQuery(url.Values{
+		"status":        {"failed"},
+		"type":          {"load"},
+		"date":          {"2024-12-01"},
+		"source_folder": {"P13/P13"},
+		"limit":         {"20"},
+		"offset":        {"40"},
+	})
+	if err != nil {
+		t.Fatalf("operationFilterFromQuery() unexpected error: %v", err)
+	}
+	want := operations.ListFilter{Status: operations.StatusFailed, OperationType: "load", Date: "2024-12-01", SourceFolder: "P13/P13", Limit: 20, Offset: 40}
+	if filter != want {
+		t.Fatalf("filter = %+v, want %+v", filter, want)
+	}
+
+	for _, values := range []url.Values{
+		{"status": {"pending"}},
+		{"date": {"01.12.2024"}},
+		{"limit": {"0"}},
+		{"limit": {"1001"}},
+		{"offset": {"-1"}},
+		{"offset": {"next"}},
+	} {
+		if _, err := operationFilterFromQuery(values); err == nil {
+			t.Fatalf("operationFilterFromQuery(%v) expected error", values)
+		}
+	}
+}
+
+func TestListOperations_InvalidQuery(t *testing.T) {
+	s := newTestServer(t, "token")
+	mux := newTestMux(s)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/operations?status=pending", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestListOperations_RequiresAuth(t *testing.T) {
+	s := newTestServer(t, "token")
+	mux := newTestMux(s)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/operations", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+}
+
+func TestOperationHandler_RoutesByPath(t *testing.T) {
+	s := newTestServer(t, "")
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodGet, "/api/operations/", http.StatusNotFound},
+		{http.MethodGet, "/api/operations/op-1/extra", http.StatusNotFound},
+		{http.MethodPost, "/api/operations/op-1", http.StatusMethodNotAllowed},
+		// Без БД операция не читается
+		{http.MethodGet, "/api/operations/op-1", http.StatusInternalServerError},
+		{http.MethodDelete, "/api/operations/op-1", http.StatusInternalServerError},
+		{http.MethodPost, "/api/operations/op-1/events", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/api/operations//events", http.StatusNotFound},
+		{http.MethodGet, "/api/operations/op-1/events", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		rec := httptest.NewRecorder()
+		s.operationHandler(rec, req)
+		if rec.Code != tt.want {
+			t.Fatalf("%s %s = %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+		}
+	}
+}
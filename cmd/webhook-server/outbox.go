@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/metrics"
+	"github.com/user/go-frontol-loader/pkg/operations"
+	"github.com/user/go-frontol-loader/pkg/tracing"
+	"github.com/user/go-frontol-loader/pkg/webhook"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// reportSignatureHeader — подпись отчета: t=<unix>,v1=<hex HMAC-SHA256>.
+	reportSignatureHeader = "X-Frontol-Signature"
+	// reportIDHeader — id отчета в etl_webhook_outbox; одинаков во всех
+	// попытках доставки, по нему получатель отбрасывает повторы.
+	reportIDHeader = "X-Frontol-Report-ID"
+
+	// maxReportRetryDelay ограничивает экспоненциальную паузу между попытками.
+	maxReportRetryDelay = time.Hour
+)
+
+// signReport подписывает тело отчета: HMAC-SHA256 с ключом secret от строки
+// "<unix-время>.<тело>". Время входит в подпись, чтобы получатель мог
+// отклонять старые отчеты, отправленные повторно.
+func signReport(secret string, timestamp time.Time, body []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unix))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + unix + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// reportRetryDelay возвращает паузу перед следующей попыткой после attempt
+// неудачных: base, 2·base, 4·base... не больше maxReportRetryDelay.
+func reportRetryDelay(base time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < maxReportRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxReportRetryDelay {
+		return maxReportRetryDelay
+	}
+	return delay
+}
+
+// sendWebhookReport раздает отчет подписчикам, чьи фильтры ему
+// соответствуют: для каждого в etl_webhook_outbox сохраняется своя строка, и
+// доставка будится. Если БД недоступна, отчет отправляется сразу, одной
+// попыткой.
+func (s *Server) sendWebhookReport(ctx context.Context, operationID string, report *WebhookReport) {
+	event := webhook.Event{Status: report.Status, OperationType: report.OperationType, Kassas: report.Kassas}
+	for _, sub := range s.reportSubscribers() {
+		if !sub.Matches(event) {
+			continue
+		}
+		s.sendSubscriberReport(ctx, operationID, sub, reportForSubscriber(report, sub))
+	}
+}
+
+func (s *Server) sendSubscriberReport(ctx context.Context, operationID string, sub *webhook.Subscriber, report *WebhookReport) {
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		s.logger.Error("Error marshaling report",
+			"error", err.Error(),
+			"event", "report_marshal_error",
+		)
+		return
+	}
+
+	id, err := s.opStore.EnqueueReport(ctx, operations.OutboxReport{
+		OperationID: operationID,
+		RequestID:   report.RequestID,
+		Subscriber:  sub.Name,
+		URL:         sub.URL,
+		Payload:     reportJSON,
+		TraceParent: tracing.TraceParent(ctx),
+	})
+	if err == nil {
+		s.logger.Info("Webhook report queued for delivery",
+			"operation_id", operationID,
+			"report_id", id,
+			"subscriber", sub.Name,
+			"status", report.Status,
+			"event", "webhook_report_queued",
+		)
+		s.wakeReportOutbox()
+		return
+	}
+
+	s.logger.Warn("Failed to store webhook report in outbox, sending without retries",
+		"operation_id", operationID,
+		"subscriber", sub.Name,
+		"error", err.Error(),
+		"event", "webhook_outbox_enqueue_error",
+	)
+	statusCode, err := s.postReport(ctx, sub, sub.URL, reportJSON, 0, 1)
+	if err != nil {
+		s.logger.Warn("Webhook report failed",
+			"subscriber", sub.Name,
+			"status_code", statusCode,
+			"error", err.Error(),
+			"event", "webhook_report_failed",
+		)
+		return
+	}
+	s.logger.Info("Webhook report sent successfully",
+		"subscriber", sub.Name,
+		"status_code", statusCode,
+		"event", "webhook_report_sent",
+	)
+}
+
+// postReport отправляет отчет подписчику sub на url одной попыткой и
+// возвращает код ответа (0, если ответа нет). Тело строится по шаблону
+// подписчика. Ответ не из 2xx считается ошибкой. Контекст трассы ctx
+// передается получателю в заголовке traceparent.
+func (s *Server) postReport(ctx context.Context, sub *webhook.Subscriber, url string, report []byte, reportID int64, attempt int) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "webhook.report",
+		attribute.Int64("etl.report_id", reportID),
+		attribute.Int("etl.attempt", attempt),
+		attribute.String("etl.subscriber", sub.Name),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	body, err := sub.Render(report)
+	if err != nil {
+		return 0, err
+	}
+	header := make(http.Header)
+	if reportID > 0 {
+		header.Set(reportIDHeader, strconv.FormatInt(reportID, 10))
+	}
+	statusCode, err := s.postWebhook(ctx, sub, url, sub.ContentTypeOrDefault(), body, header)
+	if statusCode > 0 {
+		span.SetAttributes(attribute.Int("http.response.status_code", statusCode))
+	}
+	return statusCode, err
+}
+
+// postWebhook отправляет body подписчику sub на url одной попыткой: с
+// заголовком авторизации и подписью подписчика, дополнительными заголовками
+// header и traceparent из ctx. Возвращает код ответа (0, если ответа нет);
+// ответ не из 2xx считается ошибкой.
+func (s *Server) postWebhook(ctx context.Context, sub *webhook.Subscriber, url, contentType string, body []byte, header http.Header) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("create webhook request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "Frontol-ETL-Webhook/1.0")
+	tracing.InjectHTTP(ctx, req.Header)
+	if name, value := sub.AuthHeaderPair(); name != "" {
+		req.Header.Set(name, value)
+	}
+	secret := sub.SigningSecret
+	if secret == "" {
+		secret = s.currentConfig().WebhookSigningSecret
+	}
+	if secret != "" {
+		req.Header.Set(reportSignatureHeader, signReport(secret, time.Now(), body))
+	}
+
+	client := &http.Client{Timeout: s.currentConfig().EffectiveWebhookReportHTTPTimeout()}
+
+	// #nosec G704 -- webhook destination is operator-configured via environment.
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("send webhook request: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			s.logger.Warn("Failed to close webhook response body",
+				"error", err.Error(),
+				"event", "webhook_response_close_error",
+			)
+		}
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// wakeReportOutbox будит доставку отчетов, не дожидаясь интервала опроса.
+func (s *Server) wakeReportOutbox() {
+	select {
+	case s.outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// startReportOutbox запускает доставку отчетов из etl_webhook_outbox. Отчеты,
+// не доставленные до остановки, доставляются после следующего запуска любой
+// репликой.
+func (s *Server) startReportOutbox() {
+	if s.opStore == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.outboxCancel = cancel
+	s.outboxDone = make(chan struct{})
+	go s.runReportOutbox(ctx)
+	s.logger.Info("Webhook report outbox started",
+		"max_attempts", s.currentConfig().EffectiveWebhookReportMaxAttempts(),
+		"retry_delay", s.currentConfig().EffectiveWebhookReportRetryDelay().String(),
+		"signed", s.currentConfig().WebhookSigningSecret != "",
+		"event", "webhook_outbox_started",
+	)
+}
+
+// stopReportOutbox останавливает доставку после текущей попытки.
+func (s *Server) stopReportOutbox() {
+	if s.outboxCancel == nil {
+		return
+	}
+	s.outboxCancel()
+	<-s.outboxDone
+}
+
+// runReportOutbox доставляет отчеты, пока ctx не отменен.
+func (s *Server) runReportOutbox(ctx context.Context) {
+	defer close(s.outboxDone)
+
+	pollInterval := s.currentConfig().EffectiveQueuePollInterval()
+	lease := s.currentConfig().EffectiveWebhookReportHTTPTimeout() + time.Minute
+	for {
+		report, err := s.opStore.ClaimReport(ctx, lease)
+		if err != nil && ctx.Err() == nil {
+			s.logger.Warn("Failed to claim webhook report from outbox",
+				"error", err.Error(),
+				"event", "webhook_outbox_claim_error",
+			)
+		}
+		if report != nil {
+			s.deliverOutboxReport(ctx, report)
+			continue
+		}
+
+		timer := time.NewTimer(pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.outboxWake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// deliverOutboxReport выполняет одну попытку доставки захваченного отчета и
+// записывает ее итог: доставлен, повтор через паузу или dead-letter после
+// WEBHOOK_REPORT_MAX_ATTEMPTS попыток.
+func (s *Server) deliverOutboxReport(ctx context.Context, report *operations.OutboxReport) {
+	log := s.logger.WithRequestID(report.RequestID).WithOperationID(report.OperationID)
+	storeCtx := context.WithoutCancel(ctx)
+	sub := s.reportSubscriber(report.Subscriber)
+	if sub == nil {
+		// Подписчика удалили из конфигурации: повторять доставку некому
+		errMsg := fmt.Sprintf("subscriber %s is not configured", report.Subscriber)
+		metrics.ObserveReportDelivery(metrics.ReportDeadLettered)
+		log.Error("Webhook report dead-lettered: subscriber is not configured",
+			"report_id", report.ID,
+			"subscriber", report.Subscriber,
+			"event", "webhook_report_dead_lettered",
+		)
+		if err := s.opStore.DeadLetterReport(storeCtx, report.ID, 0, errMsg); err != nil {
+			log.Warn("Failed to record webhook report delivery attempt",
+				"report_id", report.ID,
+				"error", err.Error(),
+				"event", "webhook_outbox_update_error",
+			)
+		}
+		return
+	}
+	traceCtx := tracing.WithTraceParent(context.WithoutCancel(ctx), report.TraceParent)
+	statusCode, sendErr := s.postReport(traceCtx, sub, report.URL, report.Payload, report.ID, report.Attempts)
+
+	var err error
+	maxAttempts := s.currentConfig().EffectiveWebhookReportMaxAttempts()
+	switch {
+	case sendErr == nil:
+		err = s.opStore.MarkReportDelivered(storeCtx, report.ID, statusCode)
+		metrics.ObserveReportDelivery(metrics.ReportDelivered)
+		log.Info("Webhook report sent successfully",
+			"report_id", report.ID,
+			"subscriber", report.Subscriber,
+			"attempt", report.Attempts,
+			"status_code", statusCode,
+			"event", "webhook_report_sent",
+		)
+	case report.Attempts >= maxAttempts:
+		err = s.opStore.DeadLetterReport(storeCtx, report.ID, statusCode, sendErr.Error())
+		metrics.ObserveReportDelivery(metrics.ReportDeadLettered)
+		log.Error("Webhook report dead-lettered after last attempt",
+			"report_id", report.ID,
+			"subscriber", report.Subscriber,
+			"attempts", report.Attempts,
+			"status_code", statusCode,
+			"error", sendErr.Error(),
+			"event", "webhook_report_dead_lettered",
+		)
+	default:
+		delay := reportRetryDelay(s.currentConfig().EffectiveWebhookReportRetryDelay(), report.Attempts)
+		err = s.opStore.RetryReport(storeCtx, report.ID, delay, statusCode, sendErr.Error())
+		metrics.ObserveReportDelivery(metrics.ReportRetried)
+		log.Warn("Webhook report failed, will retry",
+			"report_id", report.ID,
+			"subscriber", report.Subscriber,
+			"attempt", report.Attempts,
+			"max_attempts", maxAttempts,
+			"retry_in", delay.String(),
+			"status_code", statusCode,
+			"error", sendErr.Error(),
+			"event", "webhook_report_failed",
+		)
+	}
+	if err != nil {
+		log.Warn("Failed to record webhook report delivery attempt",
+			"report_id", report.ID,
+			"error", err.Error(),
+			"event", "webhook_outbox_update_error",
+		)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignReport(t *testing.T) {
+	got := signReport("secret", time.Unix(1700000000, 0), []byte(`{"status":"completed"}`))
+	want := "t=1700000000,v1=971584cab362128b5ba196332f525eae9a94a374a9c8f072e78ca9ea3689fe22"
+	if got != want {
+		t.Fatalf("signReport() = %q, want %q", got, want)
+	}
+}
+
+func TestReportRetryDelay(t *testing.T) {
+	tests := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		4:  4 * time.Minute,
+		8:  time.Hour, // 64 минуты ограничены часом
+		50: time.Hour,
+	}
+	for attempt, want := range tests {
+		if got := reportRetryDelay(30*time.Second, attempt); got != want {
+			t.Errorf("reportRetryDelay(30s, %d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestSendWebhookReport_SignsDirectDeliveryWithoutOutbox(t *testing.T) {
+	type delivery struct {
+		body      string
+		signature string
+		reportID  string
+	}
+	deliveries := make(chan delivery, 1)
+	reportServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		deliveries <- delivery{body: string(body), signature: r.Header.Get(reportSignatureHeader), reportID: r.Header.Get(reportIDHeader)}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer reportServer.Close()
+
+	// БД тестового сервера недоступна: отчет уходит сразу, без outbox
+	s := newTestServer(t, "")
+	s.config.WebhookReportURL = reportServer.URL
+	s.config.WebhookSigningSecret = "secret"
+	s.sendWebhookReport(context.Background(), "op-signed", &WebhookReport{RequestID: "req-signed", Status: "completed"})
+
+	select {
+	case got := <-deliveries:
+		if got.reportID != "" {
+			t.Fatalf("%s = %q, want none for a report outside the outbox", reportIDHeader, got.reportID)
+		}
+		parts := strings.SplitN(got.signature, ",", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], "t=") {
+			t.Fatalf("signature = %q, want t=<unix>,v1=<hex>", got.signature)
+		}
+		unix, err := strconv.ParseInt(strings.TrimPrefix(parts[0], "t="), 10, 64)
+		if err != nil {
+			t.Fatalf("signature timestamp: %v", err)
+		}
+		if want := signReport("secret", time.Unix(unix, 0), []byte(got.body)); got.signature != want {
+			t.Fatalf("signature = %q, want %q for the delivered body", got.signature, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("report was not delivered")
+	}
+}
+
+func TestReportsHandler_Validation(t *testing.T) {
+	s := newTestServer(t, "")
+	mux := newTestMux(s)
+
+	tests := []struct {
+		method string
+		target string
+		want   int
+	}{
+		{http.MethodGet, "/api/reports?status=lost", http.StatusBadRequest},
+		{http.MethodGet, "/api/reports?limit=0", http.StatusBadRequest},
+		{http.MethodPost, "/api/reports", http.StatusBadRequest}, // тело ниже — не JSON
+		{http.MethodDelete, "/api/reports", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader("{"))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.target, rec.Code, tt.want)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -14,6 +15,10 @@ import (
 	"github.com/user/go-frontol-loader/pkg/logger"
 	"github.com/user/go-frontol-loader/pkg/models"
 	"github.com/user/go-frontol-loader/pkg/operations"
+	"github.com/user/go-frontol-loader/pkg/tracing"
+	"github.com/user/go-frontol-loader/pkg/webhook"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // OperationType представляет тип операции.
@@ -29,15 +34,37 @@ type QueueItem struct {
 	RequestID     string
 	OperationID   string
 	Date          string
+	DateTo        string // последний день диапазона загрузки; пусто для одного дня
 	OperationType OperationType
 	SourceFolder  string
+	Kassas        []string // source_folder целевой загрузки (отсортированы); пусто для всех касс
 	Logger        *logger.Logger
 	CreatedAt     time.Time
+	TraceParent   string // W3C traceparent запроса: обработка продолжает его трассу
 }
 
-// OperationQueue представляет очередь запросов для конкретного типа операции.
+// dates возвращает день или диапазон дней загрузки.
+func (item *QueueItem) dates() models.DateRange {
+	return models.DateRange{From: item.Date, To: item.DateTo}
+}
+
+// lane возвращает полосу очереди элемента: целевые загрузки разных касс
+// обрабатываются в своих полосах и не ждут друг друга.
+func (item *QueueItem) lane() string {
+	return strings.Join(item.Kassas, ",")
+}
+
+// queueKey идентифицирует очередь: тип операции и полоса внутри него.
+type queueKey struct {
+	operationType OperationType
+	lane          string
+}
+
+// OperationQueue представляет очередь запросов для конкретного типа операции
+// и полосы.
 type OperationQueue struct {
 	operationType OperationType
+	lane          string
 	queue         chan *QueueItem
 	mu            sync.Mutex
 	workerStarted bool
@@ -82,15 +109,19 @@ func (oq *OperationQueue) Stop() {
 	close(oq.queue)
 }
 
-// RequestQueueManager управляет очередями по типам операций.
+// RequestQueueManager управляет очередями по типам операций и полосам.
+// Полоса создается при постановке запроса и удаляется вместе со своим
+// воркером, когда ее очередь пустеет, поэтому целевые загрузки с
+// произвольными наборами касс не копят очереди и горутины.
 type RequestQueueManager struct {
-	operationQueues map[OperationType]*OperationQueue
+	operationQueues map[queueKey]*OperationQueue
 	mu              sync.RWMutex
 	queueSize       int
 }
 
 // Server представляет веб-сервер.
 type Server struct {
+	configMu     sync.RWMutex // защищает config и subscribers при перезагрузке конфигурации
 	config       *models.Config
 	logger       *logger.Logger
 	queueManager *RequestQueueManager
@@ -98,36 +129,92 @@ type Server struct {
 	httpServer   *http.Server
 	stopping     atomic.Bool
 	opStore      *operations.Store
+	kassaLocks   kassaLocks
+	activeOps    operationRegistry     // операции этого экземпляра, которые можно отменить
+	queueWake    chan struct{}         // будит воркеры durable-очереди после постановки запроса
+	queueCancel  context.CancelFunc    // останавливает захват операций из durable-очереди
+	outboxWake   chan struct{}         // будит доставку отчетов после постановки отчета в outbox
+	outboxCancel context.CancelFunc    // останавливает доставку отчетов
+	outboxDone   chan struct{}         // закрывается, когда доставка отчетов остановлена
+	subscribers  []*webhook.Subscriber // подписчики из WEBHOOK_SUBSCRIBERS_FILE
+	events       operationEvents       // события выполняющихся здесь операций
+	lifecycle    lifecycleWriter       // фоновая запись статусов операций
+
+	schedules        []*scheduledLoad // расписания встроенного планировщика
+	scheduleLocation *time.Location
+	schedulerCancel  context.CancelFunc
+
+	reloadCancel context.CancelFunc // останавливает перезагрузку конфигурации по SIGHUP и изменению файла
 }
 
 func NewRequestQueueManager(queueSize int) *RequestQueueManager {
 	return &RequestQueueManager{
-		operationQueues: make(map[OperationType]*OperationQueue),
+		operationQueues: make(map[queueKey]*OperationQueue),
 		queueSize:       queueSize,
 	}
 }
 
+// GetOrCreateQueue возвращает основную полосу очереди типа операции.
 func (rqm *RequestQueueManager) GetOrCreateQueue(operationType OperationType) *OperationQueue {
+	return rqm.getOrCreateLane(operationType, "")
+}
+
+func (rqm *RequestQueueManager) getOrCreateLane(operationType OperationType, lane string) *OperationQueue {
 	rqm.mu.Lock()
 	defer rqm.mu.Unlock()
+	return rqm.laneLocked(operationType, lane)
+}
 
-	if queue, exists := rqm.operationQueues[operationType]; exists {
+// laneLocked возвращает полосу, создавая ее при необходимости; вызывается под rqm.mu.
+func (rqm *RequestQueueManager) laneLocked(operationType OperationType, lane string) *OperationQueue {
+	key := queueKey{operationType: operationType, lane: lane}
+	if queue, exists := rqm.operationQueues[key]; exists {
 		return queue
 	}
 
 	queue := NewOperationQueue(operationType, rqm.queueSize)
-	rqm.operationQueues[operationType] = queue
+	queue.lane = lane
+	rqm.operationQueues[key] = queue
 	return queue
 }
 
 func (rqm *RequestQueueManager) Enqueue(item *QueueItem) error {
-	queue := rqm.GetOrCreateQueue(item.OperationType)
-	return queue.Enqueue(item)
+	// Постановка под rqm.mu не дает воркеру удалить полосу между ее поиском
+	// и записью в очередь
+	rqm.mu.Lock()
+	defer rqm.mu.Unlock()
+	return rqm.laneLocked(item.OperationType, item.lane()).Enqueue(item)
 }
 
-func (rqm *RequestQueueManager) StartWorkerForOperation(operationType OperationType, server *Server) {
+// next возвращает следующий запрос полосы. Когда очередь полосы пуста или
+// закрыта, полоса удаляется и next возвращает false: следующий запрос создаст
+// ее заново вместе с воркером.
+func (rqm *RequestQueueManager) next(queue *OperationQueue) (*QueueItem, bool) {
+	for {
+		select {
+		case item, ok := <-queue.queue:
+			if ok {
+				return item, true
+			}
+		default:
+		}
+
+		rqm.mu.Lock()
+		if len(queue.queue) == 0 {
+			key := queueKey{operationType: queue.operationType, lane: queue.lane}
+			if rqm.operationQueues[key] == queue {
+				delete(rqm.operationQueues, key)
+			}
+			rqm.mu.Unlock()
+			return nil, false
+		}
+		rqm.mu.Unlock()
+	}
+}
+
+func (rqm *RequestQueueManager) StartWorkerForOperation(operationType OperationType, lane string, server *Server) {
 	rqm.mu.Lock()
-	queue, exists := rqm.operationQueues[operationType]
+	queue, exists := rqm.operationQueues[queueKey{operationType: operationType, lane: lane}]
 	if !exists {
 		rqm.mu.Unlock()
 		return
@@ -146,36 +233,59 @@ func (rqm *RequestQueueManager) StartWorkerForOperation(operationType OperationT
 
 		server.logger.Info("Operation queue worker started",
 			"operation_type", operationType,
+			"lane", lane,
 			"event", "operation_queue_worker_started",
 		)
 
-		for item := range queue.queue {
+		for {
+			item, ok := rqm.next(queue)
+			if !ok {
+				break
+			}
+			runCtx, done, ok := server.activeOps.take(item.OperationID)
+			if !ok {
+				item.Logger.Info("Skipping canceled request from operation queue",
+					"request_id", item.RequestID,
+					"operation_id", item.OperationID,
+					"operation_type", operationType,
+					"lane", lane,
+					"event", "operation_queue_item_canceled",
+				)
+				continue
+			}
 			item.Logger.Info("Taking next request from operation queue",
 				"request_id", item.RequestID,
 				"operation_id", item.OperationID,
 				"operation_type", operationType,
 				"date", item.Date,
+				"lane", lane,
 				"queue_size", queue.Size(),
 				"event", "operation_queue_item_taken",
 			)
-			server.processQueueItem(item)
+			server.processQueueItem(runCtx, item)
+			done()
 		}
 
 		server.logger.Info("Operation queue worker stopped",
 			"operation_type", operationType,
+			"lane", lane,
 			"event", "operation_queue_worker_stopped",
 		)
 	}()
 }
 
+// GetQueueSize возвращает число ожидающих запросов типа операции во всех полосах.
 func (rqm *RequestQueueManager) GetQueueSize(operationType OperationType) int {
 	rqm.mu.RLock()
 	defer rqm.mu.RUnlock()
 
-	if queue, exists := rqm.operationQueues[operationType]; exists {
-		return queue.Size()
+	total := 0
+	for key, queue := range rqm.operationQueues {
+		if key.operationType == operationType {
+			total += queue.Size()
+		}
 	}
-	return 0
+	return total
 }
 
 func (rqm *RequestQueueManager) GetTotalSize() int {
@@ -189,12 +299,6 @@ func (rqm *RequestQueueManager) GetTotalSize() int {
 	return total
 }
 
-func (rqm *RequestQueueManager) GetActiveOperationsCount() int {
-	rqm.mu.RLock()
-	defer rqm.mu.RUnlock()
-	return len(rqm.operationQueues)
-}
-
 func (rqm *RequestQueueManager) StopAll() {
 	rqm.mu.Lock()
 	defer rqm.mu.Unlock()
@@ -208,10 +312,15 @@ func (s *Server) enqueue(item *QueueItem) error {
 	if s.stopping.Load() {
 		return fmt.Errorf("server is shutting down")
 	}
+	if s.durableQueue() {
+		return s.enqueueDurable(item)
+	}
+	s.activeOps.addQueued(item)
 	if err := s.queueManager.Enqueue(item); err != nil {
+		s.activeOps.removeQueued(item.OperationID)
 		return err
 	}
-	s.queueManager.StartWorkerForOperation(item.OperationType, s)
+	s.queueManager.StartWorkerForOperation(item.OperationType, item.lane(), s)
 	return nil
 }
 
@@ -229,13 +338,30 @@ func NewServer(cfg *models.Config) *Server {
 		logger:       loggerInstance.WithComponent("webhook-server"),
 		queueManager: NewRequestQueueManager(100),
 		opStore:      operations.NewStore(cfg, loggerInstance),
+		queueWake:    make(chan struct{}, 1),
+		outboxWake:   make(chan struct{}, 1),
 	}
 }
 
-func (s *Server) processQueueItem(item *QueueItem) {
-	ctx := context.Background()
-	log := item.Logger
+// processQueueItem выполняет операцию в runCtx: его отменяет
+// DELETE /api/operations/{operation_id}. Операция регистрируется в activeOps
+// до вызова.
+func (s *Server) processQueueItem(runCtx context.Context, item *QueueItem) {
 	processingStartTime := time.Now()
+	ctx := tracing.WithTraceParent(context.Background(), item.TraceParent)
+	tracing.Record(ctx, "queue.wait", item.CreatedAt, processingStartTime,
+		attribute.String("etl.operation_id", item.OperationID),
+		attribute.String("etl.operation_type", string(item.OperationType)),
+	)
+	ctx, span := tracing.Start(ctx, "operation.process",
+		attribute.String("etl.request_id", item.RequestID),
+		attribute.String("etl.operation_id", item.OperationID),
+		attribute.String("etl.operation_type", string(item.OperationType)),
+		attribute.String("etl.dates", item.dates().String()),
+	)
+	defer span.End()
+	log := item.Logger
+	queueBefore := s.queueSnapshot(ctx)
 
 	log.InfoContext(ctx, "=== STARTING REQUEST PROCESSING ===",
 		"request_id", item.RequestID,
@@ -243,16 +369,21 @@ func (s *Server) processQueueItem(item *QueueItem) {
 		"operation_type", item.OperationType,
 		"date", item.Date,
 		"queue_wait_time", time.Since(item.CreatedAt).String(),
-		"queue_size_before", s.queueManager.GetQueueSize(item.OperationType),
-		"total_queue_size", s.queueManager.GetTotalSize(),
+		"queue_size_before", queueBefore.sizes[item.OperationType],
+		"total_queue_size", queueBefore.total,
 		"event", "queue_item_processing_start",
 	)
+	if item.OperationType == OperationTypeLoad {
+		// Поток событий открывается до статуса processing, чтобы клиент
+		// /events не принял операцию за выполняющуюся на другой реплике
+		s.events.open(item.OperationID, item.RequestID)
+	}
 	s.trackOperation(ctx, operations.Record{
 		OperationID:   item.OperationID,
 		RequestID:     item.RequestID,
 		OperationType: string(item.OperationType),
 		Status:        operations.StatusProcessing,
-		Date:          item.Date,
+		Date:          item.dates().String(),
 		SourceFolder:  item.SourceFolder,
 		Component:     "webhook-server",
 		UpdatedAt:     time.Now(),
@@ -260,7 +391,11 @@ func (s *Server) processQueueItem(item *QueueItem) {
 
 	switch item.OperationType {
 	case OperationTypeLoad:
-		s.runETLPipeline(item.OperationID, item.RequestID, item.Date, log)
+		// Загрузки с общими папками выполняются по очереди, с разными — параллельно
+		runCtx = trace.ContextWithSpan(runCtx, span)
+		release := s.kassaLocks.acquire(item.Kassas)
+		defer release()
+		s.runETLPipeline(runCtx, item.OperationID, item.RequestID, item.dates(), item.Kassas, log)
 	default:
 		now := time.Now()
 		s.trackOperation(ctx, operations.Record{
@@ -268,7 +403,7 @@ func (s *Server) processQueueItem(item *QueueItem) {
 			RequestID:     item.RequestID,
 			OperationType: string(item.OperationType),
 			Status:        operations.StatusFailed,
-			Date:          item.Date,
+			Date:          item.dates().String(),
 			SourceFolder:  item.SourceFolder,
 			Component:     "webhook-server",
 			UpdatedAt:     now,
@@ -283,6 +418,7 @@ func (s *Server) processQueueItem(item *QueueItem) {
 	}
 
 	processingDuration := time.Since(processingStartTime)
+	queueAfter := s.queueSnapshot(ctx)
 
 	log.InfoContext(ctx, "=== REQUEST PROCESSING COMPLETED ===",
 		"request_id", item.RequestID,
@@ -290,8 +426,8 @@ func (s *Server) processQueueItem(item *QueueItem) {
 		"operation_type", item.OperationType,
 		"date", item.Date,
 		"processing_duration", processingDuration.String(),
-		"queue_size_after", s.queueManager.GetQueueSize(item.OperationType),
-		"total_queue_size", s.queueManager.GetTotalSize(),
+		"queue_size_after", queueAfter.sizes[item.OperationType],
+		"total_queue_size", queueAfter.total,
 		"event", "queue_item_processing_completed",
 	)
 
@@ -303,6 +439,7 @@ func (s *Server) processQueueItem(item *QueueItem) {
 	)
 }
 
+// trackOperation ставит смену статуса операции в фоновую запись
 func (s *Server) trackOperation(ctx context.Context, record operations.Record) {
 	if s == nil || s.opStore == nil {
 		return
@@ -310,6 +447,10 @@ func (s *Server) trackOperation(ctx context.Context, record operations.Record) {
 	if record.OperationID == "" {
 		return
 	}
+	s.lifecycle.add(ctx, record, s.persistOperation)
+}
+
+func (s *Server) persistOperation(ctx context.Context, record operations.Record) {
 	if err := s.opStore.Update(ctx, record); err != nil {
 		s.logger.WarnContext(ctx, "Failed to persist operation lifecycle update",
 			"operation_id", record.OperationID,
@@ -325,7 +466,7 @@ func (s *Server) Stop() {
 		return
 	}
 
-	shutdownTimeout := s.config.EffectiveShutdownTimeout()
+	shutdownTimeout := s.currentConfig().EffectiveShutdownTimeout()
 	s.logger.Info("Initiating graceful shutdown",
 		"shutdown_timeout", shutdownTimeout.String(),
 		"event", "server_stopping",
@@ -346,30 +487,53 @@ func (s *Server) Stop() {
 			}
 		}
 
-		totalQueueSize := s.queueManager.GetTotalSize()
-		if totalQueueSize > 0 {
+		queueBefore := s.queueSnapshot(ctx)
+		if queueBefore.total > 0 {
 			s.logger.Info("Draining queues before shutdown",
-				"total_queue_size", totalQueueSize,
-				"load_queue_size", s.queueManager.GetQueueSize(OperationTypeLoad),
-				"download_queue_size", s.queueManager.GetQueueSize(OperationTypeDownload),
+				"queue_provider", s.queueProvider(),
+				"total_queue_size", queueBefore.total,
+				"load_queue_size", queueBefore.sizes[OperationTypeLoad],
+				"download_queue_size", queueBefore.sizes[OperationTypeDownload],
 				"event", "queue_draining_start",
 			)
 		}
 
+		// Планировщик больше не ставит загрузки. Воркеры durable-очереди перестают
+		// брать операции и дожидаются текущих; ожидающие операции остаются в БД
+		// до следующего запуска
+		if s.schedulerCancel != nil {
+			s.schedulerCancel()
+		}
+		if s.reloadCancel != nil {
+			s.reloadCancel()
+		}
+		if s.queueCancel != nil {
+			s.queueCancel()
+		}
 		s.queueManager.StopAll()
 		s.workerWg.Wait()
+		// Отчеты завершенных операций остаются в outbox до следующего запуска
+		s.stopReportOutbox()
+
+		remainingQueueSize := s.queueSnapshot(ctx).total
+		s.lifecycle.wait()
 		if s.opStore != nil {
 			s.opStore.Close()
 		}
 		_ = s.logger.Close()
 
-		remainingQueueSize := s.queueManager.GetTotalSize()
-		if remainingQueueSize > 0 {
+		switch {
+		case remainingQueueSize > 0 && s.durableQueue():
+			s.logger.Info("Queued operations kept in durable queue for next start",
+				"remaining_queue_size", remainingQueueSize,
+				"event", "queue_persisted",
+			)
+		case remainingQueueSize > 0:
 			s.logger.Warn("Shutdown completed with items remaining in queue",
 				"remaining_queue_size", remainingQueueSize,
 				"event", "queue_not_fully_drained",
 			)
-		} else {
+		default:
 			s.logger.Info("All queues drained successfully",
 				"event", "queue_drained",
 			)
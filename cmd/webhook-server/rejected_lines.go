@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/user/go-frontol-loader/pkg/db"
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/repository"
+)
+
+// ReprocessRejectedLinesRequest представляет запрос к /api/rejected-lines/reprocess
+type ReprocessRejectedLinesRequest struct {
+	SourceFolder string `json:"source_folder,omitempty"`
+	LogicalKey   string `json:"logical_key,omitempty"`
+	Limit        int    `json:"limit,omitempty"`
+}
+
+// rejectedLineFilterFromQuery разбирает фильтр из query string GET /api/rejected-lines.
+func rejectedLineFilterFromQuery(values url.Values) (repository.RejectedLineFilter, error) {
+	filter := repository.RejectedLineFilter{
+		SourceFolder: values.Get("source_folder"),
+		LogicalKey:   values.Get("logical_key"),
+		Status:       values.Get("status"),
+	}
+	switch filter.Status {
+	case "", models.RejectedLineStatusRejected, models.RejectedLineStatusReprocessed:
+	default:
+		return filter, fmt.Errorf("status must be %s or %s", models.RejectedLineStatusRejected, models.RejectedLineStatusReprocessed)
+	}
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > 1000 {
+			return filter, fmt.Errorf("limit must be an integer between 1 and 1000")
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+// listRejectedLinesHandler обрабатывает GET /api/rejected-lines.
+func (s *Server) listRejectedLinesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	log := s.logger.WithRequestID(r.Header.Get("X-Request-ID"))
+
+	filter, err := rejectedLineFilterFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	database, err := db.NewPool(s.currentConfig())
+	if err != nil {
+		log.ErrorContext(ctx, "Failed to connect to database",
+			"error", err.Error(),
+			"event", "db_connection_error",
+		)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer database.Close()
+
+	lines, err := repository.NewLoader(database).ListRejectedLines(ctx, filter)
+	if err != nil {
+		log.ErrorContext(ctx, "Failed to list rejected lines",
+			"error", err.Error(),
+			"event", "query_error",
+		)
+		http.Error(w, "Failed to retrieve rejected lines", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"rejected_lines": lines,
+		"count":          len(lines),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.ErrorContext(ctx, "Failed to encode response",
+			"error", err.Error(),
+			"event", "response_encode_error",
+		)
+	}
+}
+
+// reprocessRejectedLinesHandler обрабатывает POST /api/rejected-lines/reprocess:
+// прогоняет отклоненные строки через парсер заново и загружает разобранные.
+func (s *Server) reprocessRejectedLinesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	log := s.logger.WithRequestID(r.Header.Get("X-Request-ID"))
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	var req ReprocessRejectedLinesRequest
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Limit < 0 || req.Limit > 1000 {
+		http.Error(w, "Invalid limit: must be between 1 and 1000", http.StatusBadRequest)
+		return
+	}
+
+	database, err := db.NewPool(s.currentConfig())
+	if err != nil {
+		log.ErrorContext(ctx, "Failed to connect to database",
+			"error", err.Error(),
+			"event", "db_connection_error",
+		)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer database.Close()
+
+	result, err := repository.NewLoader(database).ReprocessRejectedLines(ctx, repository.RejectedLineFilter{
+		SourceFolder: req.SourceFolder,
+		LogicalKey:   req.LogicalKey,
+		Limit:        req.Limit,
+	})
+	if err != nil {
+		log.ErrorContext(ctx, "Failed to reprocess rejected lines",
+			"source_folder", req.SourceFolder,
+			"logical_key", req.LogicalKey,
+			"error", err.Error(),
+			"event", "rejected_lines_reprocess_error",
+		)
+		http.Error(w, "Failed to reprocess rejected lines", http.StatusInternalServerError)
+		return
+	}
+
+	log.InfoContext(ctx, "Rejected lines reprocessed via API",
+		"log_kind", "loki_operational",
+		"source_folder", req.SourceFolder,
+		"logical_key", req.LogicalKey,
+		"selected", result.Selected,
+		"reprocessed", result.Reprocessed,
+		"still_rejected", result.StillRejected,
+		"event", "rejected_lines_reprocess",
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.ErrorContext(ctx, "Failed to encode response",
+			"error", err.Error(),
+			"event", "response_encode_error",
+		)
+	}
+}
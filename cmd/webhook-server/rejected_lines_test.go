@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRejectedLineFilterFromQuery(t *testing.T) {
+	filter, err := rejectedLineFilterFromQuery(url.Values{
+		"source_folder": {"P13/P13"},
+		"status":        {"reprocessed"},
+		"limit":         {"50"},
+	})
+	if err != nil {
+		t.Fatalf("rejectedLineFilterFromQuery() unexpected error: %v", err)
+	}
+	if filter.SourceFolder != "P13/P13" || filter.Status != "reprocessed" || filter.Limit != 50 {
+		t.Fatalf("filter = %+v", filter)
+	}
+
+	for _, values := range []url.Values{
+		{"status": {"pending"}},
+		{"limit": {"0"}},
+		{"limit": {"1001"}},
+		{"limit": {"many"}},
+	} {
+		if _, err := rejectedLineFilterFromQuery(values); err == nil {
+			t.Fatalf("rejectedLineFilterFromQuery(%v) expected error", values)
+		}
+	}
+}
+
+func TestListRejectedLines_InvalidStatus(t *testing.T) {
+	s := newTestServer(t, "token")
+	mux := newTestMux(s)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/rejected-lines?status=pending", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestListRejectedLines_RequiresAuth(t *testing.T) {
+	s := newTestServer(t, "token")
+	mux := newTestMux(s)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/rejected-lines", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+}
+
+func TestReprocessRejectedLines_MethodNotAllowed(t *testing.T) {
+	s := newTestServer(t, "")
+	req := httptest.NewRequest(http.MethodGet, "/api/rejected-lines/reprocess", nil)
+	rec := httptest.NewRecorder()
+	s.reprocessRejectedLinesHandler(rec, req)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rec.Code)
+	}
+}
+
+func TestReprocessRejectedLines_InvalidBody(t *testing.T) {
+	s := newTestServer(t, "")
+	for _, body := range []string{`{`, `{"limit": 5000}`} {
+		req := httptest.NewRequest(http.MethodPost, "/api/rejected-lines/reprocess", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		s.reprocessRejectedLinesHandler(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("body %s: expected 400, got %d", body, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/config"
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/webhook"
+)
+
+// loadConfigFunc читает конфигурацию при перезагрузке; подменяется в тестах
+var loadConfigFunc = config.LoadConfig
+
+// currentConfig возвращает действующую конфигурацию сервера. Операции берут
+// ее один раз при старте, поэтому перезагрузка не меняет выполняющиеся
+// операции и не трогает очередь.
+func (s *Server) currentConfig() *models.Config {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	return s.config
+}
+
+// startConfigReload перезагружает конфигурацию по SIGHUP и, если задан
+// CONFIG_FILE, при изменении файла. Останавливается в Stop.
+func (s *Server) startConfigReload() {
+	cfg := s.currentConfig()
+	ctx, cancel := context.WithCancel(context.Background())
+	s.reloadCancel = cancel
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	s.workerWg.Add(1)
+	go func() {
+		defer s.workerWg.Done()
+		defer signal.Stop(hangup)
+
+		var poll <-chan time.Time
+		if cfg.ConfigFile != "" && cfg.ConfigReloadInterval > 0 {
+			ticker := time.NewTicker(cfg.ConfigReloadInterval)
+			defer ticker.Stop()
+			poll = ticker.C
+		}
+		lastStat := statConfigFile(cfg.ConfigFile)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				_ = s.reloadConfig("sighup")
+			case <-poll:
+				// Недописанный файл не пройдет проверку; следующая запись
+				// снова изменит его и вызовет перезагрузку
+				stat := statConfigFile(cfg.ConfigFile)
+				if stat == lastStat {
+					continue
+				}
+				lastStat = stat
+				_ = s.reloadConfig("file_change")
+			}
+		}
+	}()
+
+	s.logger.Info("Configuration reload enabled",
+		"config_file", cfg.ConfigFile,
+		"reload_interval", cfg.ConfigReloadInterval.String(),
+		"event", "config_reload_enabled",
+	)
+}
+
+// configFileStat — время изменения и размер CONFIG_FILE, по которым
+// замечается его изменение
+type configFileStat struct {
+	modTime time.Time
+	size    int64
+}
+
+func statConfigFile(path string) configFileStat {
+	if path == "" {
+		return configFileStat{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return configFileStat{}
+	}
+	return configFileStat{modTime: info.ModTime(), size: info.Size()}
+}
+
+// reloadConfig заново читает конфигурацию и применяет ее к следующим
+// операциям. Неверная конфигурация не применяется: сервер продолжает работать
+// с прежней. Настройки, которые читаются только при запуске, остаются
+// прежними до перезапуска.
+func (s *Server) reloadConfig(trigger string) error {
+	current := s.currentConfig()
+	next, err := loadConfigFunc()
+	var subscribers []*webhook.Subscriber
+	if err == nil {
+		subscribers, err = s.checkReloadedConfig(next)
+	}
+	if err != nil {
+		s.logger.Error("Configuration reload failed, keeping current configuration",
+			"trigger", trigger,
+			"error", err.Error(),
+			"event", "config_reload_error",
+		)
+		return err
+	}
+
+	if changed := keepStartupSettings(current, next); len(changed) > 0 {
+		s.logger.Warn("Configuration changes that need a restart were not applied",
+			"settings", changed,
+			"event", "config_reload_restart_required",
+		)
+	}
+	s.configMu.Lock()
+	s.config = next
+	s.subscribers = subscribers
+	s.configMu.Unlock()
+	for _, load := range s.schedules {
+		kassas, _ := kassaSourceFolders(next, load.schedule.Kassas)
+		load.setTargetKassas(kassas)
+	}
+
+	s.logger.Info("Configuration reloaded",
+		"trigger", trigger,
+		"kassas", len(next.KassaStructure),
+		"subscribers", len(subscribers),
+		"event", "config_reloaded",
+	)
+	return nil
+}
+
+// checkReloadedConfig проверяет, что кассы расписаний есть в новой
+// конфигурации (расписания задаются при запуске и не перезагружаются), и
+// компилирует ее подписчиков.
+func (s *Server) checkReloadedConfig(next *models.Config) ([]*webhook.Subscriber, error) {
+	for _, load := range s.schedules {
+		if _, err := kassaSourceFolders(next, load.schedule.Kassas); err != nil {
+			return nil, fmt.Errorf("schedule %s: %w", load.schedule.Name, err)
+		}
+	}
+	return compileSubscribers(next)
+}
+
+// keepStartupSettings оставляет в next настройки current, которые сервер
+// читает только при запуске, и возвращает имена измененных среди них.
+func keepStartupSettings(current, next *models.Config) []string {
+	var changed []string
+	keepSetting(&changed, "DB_HOST", current.DBHost, &next.DBHost)
+	keepSetting(&changed, "DB_PORT", current.DBPort, &next.DBPort)
+	keepSetting(&changed, "DB_USER", current.DBUser, &next.DBUser)
+	keepSetting(&changed, "DB_PASSWORD", current.DBPassword, &next.DBPassword)
+	keepSetting(&changed, "DB_NAME", current.DBName, &next.DBName)
+	keepSetting(&changed, "DB_SSLMODE", current.DBSSLMode, &next.DBSSLMode)
+	keepSetting(&changed, "DB_CONNECT_TIMEOUT_SECONDS", current.DBConnectTimeout, &next.DBConnectTimeout)
+	keepSetting(&changed, "SERVER_PORT", current.ServerPort, &next.ServerPort)
+	keepSetting(&changed, "WEBHOOK_BEARER_TOKEN", current.WebhookBearerToken, &next.WebhookBearerToken)
+	keepSetting(&changed, "HTTP_READ_HEADER_TIMEOUT_SECONDS", current.HTTPReadHeaderTimeout, &next.HTTPReadHeaderTimeout)
+	keepSetting(&changed, "HTTP_READ_TIMEOUT_SECONDS", current.HTTPReadTimeout, &next.HTTPReadTimeout)
+	keepSetting(&changed, "HTTP_WRITE_TIMEOUT_SECONDS", current.HTTPWriteTimeout, &next.HTTPWriteTimeout)
+	keepSetting(&changed, "HTTP_IDLE_TIMEOUT_SECONDS", current.HTTPIdleTimeout, &next.HTTPIdleTimeout)
+	keepSetting(&changed, "QUEUE_PROVIDER", current.QueueProvider, &next.QueueProvider)
+	keepSetting(&changed, "QUEUE_WORKERS", current.QueueWorkers, &next.QueueWorkers)
+	keepSetting(&changed, "QUEUE_POLL_INTERVAL_SECONDS", current.QueuePollInterval, &next.QueuePollInterval)
+	keepSetting(&changed, "QUEUE_LEASE_TIMEOUT_SECONDS", current.QueueLeaseTimeout, &next.QueueLeaseTimeout)
+	keepSetting(&changed, "SCHEDULES", current.Schedules, &next.Schedules)
+	keepSetting(&changed, "SCHEDULE_TIMEZONE", current.ScheduleTimezone, &next.ScheduleTimezone)
+	keepSetting(&changed, "GAP_BACKFILL_SCHEDULE", current.GapBackfillSchedule, &next.GapBackfillSchedule)
+	keepSetting(&changed, "LOG_LEVEL", current.LogLevel, &next.LogLevel)
+	keepSetting(&changed, "LOG_FORMAT", current.LogFormat, &next.LogFormat)
+	keepSetting(&changed, "LOG_BACKEND", current.LogBackend, &next.LogBackend)
+	keepSetting(&changed, "TRACING_EXPORTER", current.TracingExporter, &next.TracingExporter)
+	keepSetting(&changed, "TRACING_OTLP_ENDPOINT", current.TracingOTLPEndpoint, &next.TracingOTLPEndpoint)
+	keepSetting(&changed, "TRACING_SERVICE_NAME", current.TracingServiceName, &next.TracingServiceName)
+	keepSetting(&changed, "CONFIG_FILE", current.ConfigFile, &next.ConfigFile)
+	keepSetting(&changed, "CONFIG_RELOAD_INTERVAL_SECONDS", current.ConfigReloadInterval, &next.ConfigReloadInterval)
+	return changed
+}
+
+func keepSetting[T any](changed *[]string, name string, current T, next *T) {
+	if !reflect.DeepEqual(current, *next) {
+		*changed = append(*changed, name)
+		*next = current
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+func TestReloadConfig(t *testing.T) {
+	s := newTestServer(t, "token")
+	s.config.KassaStructure = map[string][]string{"P13": {"P13"}}
+	s.schedules = []*scheduledLoad{{schedule: models.Schedule{Name: "nightly", Kassas: []string{"P13"}}, kassas: []string{"P13/P13"}}}
+
+	next := *s.config
+	next.KassaStructure = map[string][]string{"P13": {"P13", "P13_INTER"}, "L32": {"L32"}}
+	next.WebhookSubscribers = []models.WebhookSubscriber{{Name: "bi", URL: "http://bi/reports", Kassas: []string{"L32"}}}
+	next.BatchSize = 500
+	next.ServerPort = 9090
+	var loadErr error
+	originalLoadConfig := loadConfigFunc
+	t.Cleanup(func() { loadConfigFunc = originalLoadConfig })
+	loadConfigFunc = func() (*models.Config, error) {
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		cfg := next
+		return &cfg, nil
+	}
+
+	if err := s.reloadConfig("test"); err != nil {
+		t.Fatalf("reloadConfig() unexpected error: %v", err)
+	}
+	cfg := s.currentConfig()
+	if len(cfg.KassaStructure) != 2 || cfg.BatchSize != 500 {
+		t.Fatalf("reloaded config = %+v, want new kassas and batch size", cfg)
+	}
+	if cfg.ServerPort != 8080 {
+		t.Errorf("ServerPort = %d, want 8080 kept until restart", cfg.ServerPort)
+	}
+	if subs := s.reportSubscribers(); len(subs) != 1 || strings.Join(subs[0].Kassas, ",") != "L32/L32" {
+		t.Errorf("reportSubscribers() = %v, want bi for L32", subs)
+	}
+	if got := s.schedules[0].targetKassas(); !reflect.DeepEqual(got, []string{"P13/P13", "P13/P13_INTER"}) {
+		t.Errorf("schedule kassas = %v, want folders of reloaded P13", got)
+	}
+
+	// Неверная конфигурация не применяется
+	loadErr = errors.New("invalid CONFIG_FILE")
+	if err := s.reloadConfig("test"); err == nil {
+		t.Fatal("reloadConfig() expected load error")
+	}
+	loadErr = nil
+	next.KassaStructure = map[string][]string{"L32": {"L32"}}
+	if err := s.reloadConfig("test"); err == nil || !strings.Contains(err.Error(), "schedule nightly") {
+		t.Fatalf("reloadConfig() error = %v, want unknown kassa of schedule nightly", err)
+	}
+	if s.currentConfig() != cfg {
+		t.Error("failed reload replaced the configuration")
+	}
+}
@@ -1,16 +1,16 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/user/go-frontol-loader/pkg/logger"
+	"github.com/user/go-frontol-loader/pkg/models"
 	"github.com/user/go-frontol-loader/pkg/operations"
 	"github.com/user/go-frontol-loader/pkg/pipeline"
+	"github.com/user/go-frontol-loader/pkg/tracing"
 )
 
 var (
@@ -29,7 +29,10 @@ type TransactionTypeStats struct {
 // WebhookReport представляет отчет о выполнении ETL.
 type WebhookReport struct {
 	RequestID          string                          `json:"request_id"`
+	OperationType      string                          `json:"operation_type,omitempty"`
 	Date               string                          `json:"date"`
+	DateTo             string                          `json:"date_to,omitempty"`
+	Kassas             []string                        `json:"kassas,omitempty"`
 	Status             string                          `json:"status"`
 	StartTime          time.Time                       `json:"start_time"`
 	EndTime            time.Time                       `json:"end_time"`
@@ -45,17 +48,36 @@ type WebhookReport struct {
 	ErrorSamples       []pipeline.PipelineIssueSample  `json:"error_samples,omitempty"`
 	KassaDetails       []pipeline.KassaProcessingStats `json:"kassa_details,omitempty"`
 	TransactionDetails []TransactionTypeStats          `json:"transaction_details,omitempty"`
+	// TraceID — трасса запроса /api/load; тот же контекст уходит в заголовке traceparent
+	TraceID string `json:"trace_id,omitempty"`
 }
 
-// runETLPipeline запускает ETL pipeline и отправляет отчет.
-func (s *Server) runETLPipeline(operationID, requestID, date string, log *logger.Logger) {
+// runETLPipeline запускает ETL pipeline и отправляет отчет. Отмена runCtx
+// прерывает pipeline: операция получает статус canceled, а отчет — статистику
+// уже загруженных файлов. Ход загрузки касс публикуется в поток событий
+// операции.
+func (s *Server) runETLPipeline(runCtx context.Context, operationID, requestID string, dates models.DateRange, kassas []string, log *logger.Logger) {
+	// Операция работает с конфигурацией на момент старта: перезагрузка
+	// конфигурации действует на следующие операции
+	cfg := s.currentConfig()
 	ctx := context.Background()
+	runCtx = s.openOperationEvents(runCtx, operationID, requestID, log)
+	// Отчеты уходят и после отмены runCtx, но в его трассе
+	reportCtx := context.WithoutCancel(runCtx)
 	startTime := time.Now()
+	date := dates.String()
+	sourceFolder := strings.Join(kassas, ",")
 	report := &WebhookReport{
-		RequestID: requestID,
-		Date:      date,
-		StartTime: startTime,
-		Status:    "processing",
+		RequestID:     requestID,
+		OperationType: string(OperationTypeLoad),
+		Date:          dates.From,
+		Kassas:        kassas,
+		StartTime:     startTime,
+		Status:        "processing",
+		TraceID:       tracing.TraceID(runCtx),
+	}
+	if !dates.IsSingleDay() {
+		report.DateTo = dates.To
 	}
 
 	log.InfoContext(ctx, "Starting ETL pipeline",
@@ -75,10 +97,45 @@ func (s *Server) runETLPipeline(operationID, requestID, date string, log *logger
 			pipelineDone <- true
 		}()
 
-		result, err := runPipelineFunc(ctx, log.Logger, s.config, date)
+		result, err := runPipelineFunc(runCtx, log.Logger, cfg, dates, kassas)
 
 		reportMutex.Lock()
-		if err != nil {
+		if err != nil && runCtx.Err() != nil {
+			report.Status = string(operations.StatusCanceled)
+			report.Success = false
+			report.ErrorMessage = "operation canceled"
+			if result != nil {
+				report.FilesProcessed = result.FilesProcessed
+				report.FilesSkipped = result.FilesSkipped
+				report.FilesRecovered = result.FilesRecovered
+				report.TransactionsLoaded = result.TransactionsLoaded
+				report.Errors = result.Errors
+				report.ErrorBreakdown = result.ErrorBreakdown
+				report.ErrorSamples = result.ErrorSamples
+				report.KassaDetails = result.KassaDetails
+				report.TransactionDetails = transactionTypeStats(result)
+			}
+			log.WarnContext(ctx, "ETL pipeline canceled",
+				"log_kind", "loki_operational",
+				"files_processed", report.FilesProcessed,
+				"transactions_loaded", report.TransactionsLoaded,
+				"event", "etl_pipeline_canceled",
+			)
+			now := time.Now()
+			s.trackOperation(ctx, operations.Record{
+				OperationID:   operationID,
+				RequestID:     requestID,
+				OperationType: string(OperationTypeLoad),
+				Status:        operations.StatusCanceled,
+				Date:          date,
+				SourceFolder:  sourceFolder,
+				Component:     "webhook-server",
+				UpdatedAt:     now,
+				FinishedAt:    &now,
+				ErrorMessage:  report.ErrorMessage,
+				Result:        result.Summary(),
+			})
+		} else if err != nil {
 			report.Status = "failed"
 			report.ErrorMessage = err.Error()
 			report.Success = false
@@ -94,11 +151,13 @@ func (s *Server) runETLPipeline(operationID, requestID, date string, log *logger
 				OperationType: string(OperationTypeLoad),
 				Status:        operations.StatusFailed,
 				Date:          date,
+				SourceFolder:  sourceFolder,
 				Component:     "webhook-server",
 				UpdatedAt:     now,
 				FinishedAt:    &now,
 				ErrorMessage:  err.Error(),
 				FailedStage:   "pipeline",
+				Result:        result.Summary(),
 			})
 		} else {
 			report.Status = string(result.Status)
@@ -112,13 +171,7 @@ func (s *Server) runETLPipeline(operationID, requestID, date string, log *logger
 			report.ErrorBreakdown = result.ErrorBreakdown
 			report.ErrorSamples = result.ErrorSamples
 			report.KassaDetails = result.KassaDetails
-			report.TransactionDetails = make([]TransactionTypeStats, 0, len(result.TransactionDetails))
-			for _, detail := range result.TransactionDetails {
-				report.TransactionDetails = append(report.TransactionDetails, TransactionTypeStats{
-					TableName: detail.TableName,
-					Count:     detail.Count,
-				})
-			}
+			report.TransactionDetails = transactionTypeStats(result)
 			logLevel := log.InfoContext
 			if result.Status == pipeline.PipelineStatusPartial {
 				logLevel = log.WarnContext
@@ -143,11 +196,13 @@ func (s *Server) runETLPipeline(operationID, requestID, date string, log *logger
 				OperationType: string(OperationTypeLoad),
 				Status:        status,
 				Date:          date,
+				SourceFolder:  sourceFolder,
 				Component:     "webhook-server",
 				UpdatedAt:     now,
 				FinishedAt:    &now,
 				ErrorMessage:  result.ErrorMessage,
 				FailedStage:   firstIssueStage(result),
+				Result:        result.Summary(),
 			})
 		}
 		reportMutex.Unlock()
@@ -172,10 +227,10 @@ func (s *Server) runETLPipeline(operationID, requestID, date string, log *logger
 		}
 		r.EndTime = time.Now()
 		r.Duration = r.EndTime.Sub(r.StartTime).String()
-		webhookConfigured := s.config.WebhookReportURL != ""
+		webhookConfigured := len(s.reportSubscribers()) > 0
 		reportMutex.Unlock()
 
-		if s.config.WebhookReportURL != "" {
+		if webhookConfigured {
 			log.InfoContext(ctx, "Sending webhook report",
 				"log_kind", "loki_operational",
 				"request_id", requestID,
@@ -184,17 +239,9 @@ func (s *Server) runETLPipeline(operationID, requestID, date string, log *logger
 				"final", final,
 				"event", "webhook_report_sending",
 			)
-			s.sendWebhookReport(r)
-			log.InfoContext(ctx, "Webhook report sent",
-				"log_kind", "loki_operational",
-				"request_id", requestID,
-				"date", date,
-				"status", r.Status,
-				"final", final,
-				"event", "webhook_report_sent",
-			)
-		} else if !webhookConfigured {
-			log.InfoContext(ctx, "Webhook report URL not configured, skipping report",
+			s.sendWebhookReport(reportCtx, operationID, r)
+		} else {
+			log.InfoContext(ctx, "Webhook report subscribers not configured, skipping report",
 				"log_kind", "loki_operational",
 				"request_id", requestID,
 				"date", date,
@@ -204,7 +251,7 @@ func (s *Server) runETLPipeline(operationID, requestID, date string, log *logger
 		}
 	}
 
-	if s.config.WebhookTimeoutMinutes == 0 {
+	if cfg.WebhookTimeoutMinutes == 0 {
 		log.InfoContext(ctx, "Waiting for pipeline completion (no timeout configured)",
 			"log_kind", "loki_operational",
 			"request_id", requestID,
@@ -221,7 +268,7 @@ func (s *Server) runETLPipeline(operationID, requestID, date string, log *logger
 		select {
 		case r := <-reportReady:
 			sendReport(r, true)
-		case <-time.After(s.config.EffectiveWebhookReportResultWaitTimeout()):
+		case <-time.After(cfg.EffectiveWebhookReportResultWaitTimeout()):
 			log.WarnContext(ctx, "Timeout waiting for report",
 				"log_kind", "loki_operational",
 				"request_id", requestID,
@@ -230,7 +277,7 @@ func (s *Server) runETLPipeline(operationID, requestID, date string, log *logger
 			)
 		}
 	} else {
-		timeout := webhookTimeoutDurationFunc(s.config.WebhookTimeoutMinutes)
+		timeout := webhookTimeoutDurationFunc(cfg.WebhookTimeoutMinutes)
 		timeoutChan := time.After(timeout)
 
 		select {
@@ -244,7 +291,7 @@ func (s *Server) runETLPipeline(operationID, requestID, date string, log *logger
 			select {
 			case r := <-reportReady:
 				sendReport(r, true)
-			case <-time.After(s.config.EffectiveWebhookReportResultWaitTimeout()):
+			case <-time.After(cfg.EffectiveWebhookReportResultWaitTimeout()):
 				log.WarnContext(ctx, "Timeout waiting for report",
 					"log_kind", "loki_operational",
 					"request_id", requestID,
@@ -257,13 +304,16 @@ func (s *Server) runETLPipeline(operationID, requestID, date string, log *logger
 				"log_kind", "loki_operational",
 				"request_id", requestID,
 				"date", date,
-				"timeout_minutes", s.config.WebhookTimeoutMinutes,
+				"timeout_minutes", cfg.WebhookTimeoutMinutes,
 				"event", "webhook_timeout",
 			)
 			reportMutex.Lock()
 			timeoutReport := &WebhookReport{
 				RequestID:          requestID,
-				Date:               date,
+				OperationType:      report.OperationType,
+				Date:               report.Date,
+				DateTo:             report.DateTo,
+				Kassas:             report.Kassas,
 				StartTime:          startTime,
 				Status:             report.Status,
 				Success:            report.Success,
@@ -276,6 +326,7 @@ func (s *Server) runETLPipeline(operationID, requestID, date string, log *logger
 				ErrorBreakdown:     report.ErrorBreakdown,
 				ErrorSamples:       report.ErrorSamples,
 				KassaDetails:       report.KassaDetails,
+				TraceID:            report.TraceID,
 			}
 			if timeoutReport.Status == "processing" {
 				timeoutReport.Status = "timeout"
@@ -289,6 +340,7 @@ func (s *Server) runETLPipeline(operationID, requestID, date string, log *logger
 				OperationType:     string(OperationTypeLoad),
 				Status:            operations.StatusTimeoutReported,
 				Date:              date,
+				SourceFolder:      sourceFolder,
 				Component:         "webhook-server",
 				UpdatedAt:         time.Now(),
 				ErrorMessage:      timeoutReport.ErrorMessage,
@@ -307,7 +359,7 @@ func (s *Server) runETLPipeline(operationID, requestID, date string, log *logger
 			select {
 			case r := <-reportReady:
 				sendReport(r, true)
-			case <-time.After(s.config.EffectiveWebhookReportResultWaitTimeout()):
+			case <-time.After(cfg.EffectiveWebhookReportResultWaitTimeout()):
 				log.WarnContext(ctx, "Timeout waiting for final report after pipeline completion",
 					"log_kind", "loki_operational",
 					"request_id", requestID,
@@ -322,6 +374,7 @@ func (s *Server) runETLPipeline(operationID, requestID, date string, log *logger
 	status := report.Status
 	duration := time.Since(startTime).String()
 	reportMutex.Unlock()
+	s.events.close(operationID, status)
 
 	log.InfoContext(ctx, "ETL pipeline execution finished",
 		"log_kind", "loki_operational",
@@ -340,68 +393,20 @@ func (s *Server) runETLPipeline(operationID, requestID, date string, log *logger
 	)
 }
 
+func transactionTypeStats(result *pipeline.PipelineResult) []TransactionTypeStats {
+	stats := make([]TransactionTypeStats, 0, len(result.TransactionDetails))
+	for _, detail := range result.TransactionDetails {
+		stats = append(stats, TransactionTypeStats{
+			TableName: detail.TableName,
+			Count:     detail.Count,
+		})
+	}
+	return stats
+}
+
 func firstIssueStage(result *pipeline.PipelineResult) string {
 	if result == nil || len(result.ErrorSamples) == 0 {
 		return ""
 	}
 	return result.ErrorSamples[0].Stage
 }
-
-// sendWebhookReport отправляет отчет на указанный webhook URL.
-func (s *Server) sendWebhookReport(report *WebhookReport) {
-	reportJSON, err := json.Marshal(report)
-	if err != nil {
-		s.logger.Error("Error marshaling report",
-			"error", err.Error(),
-			"event", "report_marshal_error",
-		)
-		return
-	}
-
-	req, err := http.NewRequest("POST", s.config.WebhookReportURL, bytes.NewBuffer(reportJSON))
-	if err != nil {
-		s.logger.Error("Error creating webhook request",
-			"error", err.Error(),
-			"event", "webhook_request_error",
-		)
-		return
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "Frontol-ETL-Webhook/1.0")
-	if s.config.WebhookBearerToken != "" {
-		req.Header.Set("Authorization", "Bearer "+s.config.WebhookBearerToken)
-	}
-
-	client := &http.Client{Timeout: s.config.EffectiveWebhookReportHTTPTimeout()}
-
-	// #nosec G704 -- webhook destination is operator-configured via environment.
-	resp, err := client.Do(req)
-	if err != nil {
-		s.logger.Error("Error sending webhook report",
-			"error", err.Error(),
-			"event", "webhook_send_error",
-		)
-		return
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			s.logger.Warn("Failed to close webhook response body",
-				"error", err.Error(),
-				"event", "webhook_response_close_error",
-			)
-		}
-	}()
-
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		s.logger.Info("Webhook report sent successfully",
-			"status_code", resp.StatusCode,
-			"event", "webhook_report_sent",
-		)
-	} else {
-		s.logger.Warn("Webhook report failed",
-			"status_code", resp.StatusCode,
-			"event", "webhook_report_failed",
-		)
-	}
-}
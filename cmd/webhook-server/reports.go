@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/user/go-frontol-loader/pkg/operations"
+)
+
+// ReplayReportsRequest представляет запрос POST /api/reports. Без ids
+// повторяются все отчеты в dead-letter.
+type ReplayReportsRequest struct {
+	IDs []int64 `json:"ids,omitempty"`
+}
+
+// reportFilterFromQuery разбирает фильтр из query string GET /api/reports.
+func reportFilterFromQuery(values url.Values) (operations.ReportFilter, error) {
+	filter := operations.ReportFilter{
+		Status:      operations.ReportStatus(values.Get("status")),
+		OperationID: values.Get("operation_id"),
+		Subscriber:  values.Get("subscriber"),
+	}
+	switch filter.Status {
+	case "", operations.ReportPending, operations.ReportDelivered, operations.ReportDead:
+	default:
+		return filter, fmt.Errorf("status must be %s, %s or %s", operations.ReportPending, operations.ReportDelivered, operations.ReportDead)
+	}
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > 1000 {
+			return filter, fmt.Errorf("limit must be an integer between 1 and 1000")
+		}
+		filter.Limit = limit
+	}
+	if raw := values.Get("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return filter, fmt.Errorf("offset must be a non-negative integer")
+		}
+		filter.Offset = offset
+	}
+	return filter, nil
+}
+
+// reportsHandler обрабатывает /api/reports: GET возвращает отчеты из
+// etl_webhook_outbox, POST повторяет доставку отчетов из dead-letter.
+func (s *Server) reportsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listReports(w, r)
+	case http.MethodPost:
+		s.replayReports(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) listReports(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := s.logger.WithRequestID(r.Header.Get("X-Request-ID"))
+
+	filter, err := reportFilterFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	page, err := s.opStore.ListReports(ctx, filter)
+	if err != nil {
+		log.ErrorContext(ctx, "Failed to list webhook reports",
+			"error", err.Error(),
+			"event", "query_error",
+		)
+		http.Error(w, "Failed to retrieve reports", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"reports": page.Reports,
+		"count":   len(page.Reports),
+		"total":   page.Total,
+		"limit":   page.Limit,
+		"offset":  page.Offset,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.ErrorContext(ctx, "Failed to encode response",
+			"error", err.Error(),
+			"event", "response_encode_error",
+		)
+	}
+}
+
+func (s *Server) replayReports(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := s.logger.WithRequestID(r.Header.Get("X-Request-ID"))
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	var req ReplayReportsRequest
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	replayed, err := s.opStore.ReplayReports(ctx, req.IDs)
+	if err != nil {
+		log.ErrorContext(ctx, "Failed to replay webhook reports",
+			"error", err.Error(),
+			"event", "webhook_reports_replay_error",
+		)
+		http.Error(w, "Failed to replay reports", http.StatusInternalServerError)
+		return
+	}
+	if replayed > 0 {
+		s.wakeReportOutbox()
+	}
+
+	log.InfoContext(ctx, "Dead-lettered webhook reports replayed via API",
+		"log_kind", "loki_operational",
+		"ids", req.IDs,
+		"replayed", replayed,
+		"event", "webhook_reports_replay",
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]int{"replayed": replayed}); err != nil {
+		log.ErrorContext(ctx, "Failed to encode response",
+			"error", err.Error(),
+			"event", "response_encode_error",
+		)
+	}
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/operations"
+	"github.com/user/go-frontol-loader/pkg/scheduler"
+)
+
+// scheduledLoad — расписание встроенного планировщика и его состояние на этом
+// экземпляре.
+type scheduledLoad struct {
+	schedule models.Schedule
+	cron     *scheduler.Cron
+
+	mu              sync.Mutex
+	kassas          []string // source_folder целевой загрузки (отсортированы); пусто для всех касс
+	next            time.Time
+	lastFire        time.Time
+	lastFireStatus  operations.Status
+	lastFireID      string
+	lastOperationID string // последняя поставленная этим экземпляром загрузка
+}
+
+// scheduleStatus — расписание в ответе GET /api/schedules.
+type scheduleStatus struct {
+	Name            string            `json:"name"`
+	Cron            string            `json:"cron"`
+	Days            int               `json:"days"`
+	Kassas          []string          `json:"kassas,omitempty"`
+	NextRun         *time.Time        `json:"next_run,omitempty"`
+	LastRun         *time.Time        `json:"last_run,omitempty"`
+	LastOperationID string            `json:"last_operation_id,omitempty"`
+	LastStatus      operations.Status `json:"last_status,omitempty"`
+}
+
+// scheduleRequestID — request_id всех запусков расписания: по нему реплики
+// находят предыдущий запуск в etl_operation_runs.
+func scheduleRequestID(name string) string {
+	return "schedule_" + name
+}
+
+// scheduleOperationID одинаков на всех репликах, поэтому срабатывание ставит
+// загрузку только один раз.
+func scheduleOperationID(name string, fireAt time.Time) string {
+	return fmt.Sprintf("op_sched_%s_%d", name, fireAt.Unix())
+}
+
+// due возвращает время срабатывания, если оно наступило к now, и переходит к
+// следующему. Пропущенные, пока сервер не работал, срабатывания не догоняются.
+func (l *scheduledLoad) due(now time.Time) (time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.next.IsZero() || l.next.After(now) {
+		return time.Time{}, false
+	}
+	fireAt := l.next
+	l.next = l.cron.Next(now)
+	return fireAt, true
+}
+
+// targetKassas возвращает source_folder целевой загрузки
+func (l *scheduledLoad) targetKassas() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.kassas
+}
+
+// setTargetKassas заменяет source_folder целевой загрузки после перезагрузки
+// конфигурации
+func (l *scheduledLoad) setTargetKassas(kassas []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.kassas = kassas
+}
+
+func (l *scheduledLoad) markFired(fireAt time.Time, operationID string, status operations.Status) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastFire = fireAt
+	l.lastFireID = operationID
+	l.lastFireStatus = status
+	if status == operations.StatusQueued {
+		l.lastOperationID = operationID
+	}
+}
+
+// startScheduler запускает встроенный планировщик, если заданы SCHEDULES или
+// GAP_BACKFILL_SCHEDULE. Планировщик останавливается в Stop.
+func (s *Server) startScheduler() error {
+	cfg := s.currentConfig()
+	if len(cfg.Schedules) == 0 && cfg.GapBackfillSchedule == "" {
+		return nil
+	}
+	timezone := cfg.ScheduleTimezone
+	if timezone == "" {
+		timezone = "Local"
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return fmt.Errorf("invalid SCHEDULE_TIMEZONE: %w", err)
+	}
+
+	now := time.Now().In(location)
+	loads := make([]*scheduledLoad, 0, len(cfg.Schedules))
+	for _, schedule := range cfg.Schedules {
+		cron, err := scheduler.Parse(schedule.Cron)
+		if err != nil {
+			return fmt.Errorf("schedule %s: %w", schedule.Name, err)
+		}
+		kassas, err := s.loadKassas(schedule.Kassas)
+		if err != nil {
+			return fmt.Errorf("schedule %s: %w", schedule.Name, err)
+		}
+		loads = append(loads, &scheduledLoad{
+			schedule: schedule,
+			cron:     cron,
+			kassas:   kassas,
+			next:     cron.Next(now),
+		})
+	}
+	var backfillCron *scheduler.Cron
+	if spec := cfg.GapBackfillSchedule; spec != "" {
+		if backfillCron, err = scheduler.Parse(spec); err != nil {
+			return fmt.Errorf("invalid GAP_BACKFILL_SCHEDULE: %w", err)
+		}
+	}
+	s.schedules = loads
+	s.scheduleLocation = location
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.schedulerCancel = cancel
+	if len(loads) > 0 {
+		s.workerWg.Add(1)
+		go s.runScheduler(ctx)
+	}
+	if backfillCron != nil {
+		s.workerWg.Add(1)
+		go s.runGapBackfill(ctx, backfillCron)
+	}
+
+	s.logger.Info("Scheduler started",
+		"schedules", len(loads),
+		"gap_backfill_schedule", cfg.GapBackfillSchedule,
+		"timezone", location.String(),
+		"event", "scheduler_started",
+	)
+	return nil
+}
+
+// runScheduler ставит загрузки по расписаниям, пока ctx не отменен.
+func (s *Server) runScheduler(ctx context.Context) {
+	defer s.workerWg.Done()
+
+	for {
+		var wake <-chan time.Time
+		var timer *time.Timer
+		if next := s.nextScheduledFire(); !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			wake = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			s.logger.Info("Scheduler stopped",
+				"event", "scheduler_stopped",
+			)
+			return
+		case <-wake:
+		}
+
+		now := time.Now().In(s.scheduleLocation)
+		for _, load := range s.schedules {
+			if fireAt, ok := load.due(now); ok {
+				s.fireSchedule(load, fireAt)
+			}
+		}
+	}
+}
+
+// nextScheduledFire возвращает ближайшее срабатывание; нулевое время, если
+// ни одно расписание больше не сработает.
+func (s *Server) nextScheduledFire() time.Time {
+	var next time.Time
+	for _, load := range s.schedules {
+		load.mu.Lock()
+		if !load.next.IsZero() && (next.IsZero() || load.next.Before(next)) {
+			next = load.next
+		}
+		load.mu.Unlock()
+	}
+	return next
+}
+
+// fireSchedule ставит загрузку срабатывания fireAt в очередь. Срабатывание
+// пропускается, пока предыдущий запуск расписания не завершен.
+func (s *Server) fireSchedule(load *scheduledLoad, fireAt time.Time) {
+	ctx := context.Background()
+	name := load.schedule.Name
+	requestID := scheduleRequestID(name)
+	operationID := scheduleOperationID(name, fireAt)
+	dates := load.schedule.Dates(fireAt)
+	kassas := load.targetKassas()
+	sourceFolder := strings.Join(kassas, ",")
+	log := s.logger.WithRequestID(requestID).WithOperationID(operationID)
+
+	if previous := s.unfinishedScheduledRun(ctx, load); previous != "" {
+		if previous == operationID {
+			// Срабатывание уже поставила другая реплика
+			load.markFired(fireAt, operationID, operations.StatusQueued)
+			return
+		}
+		now := time.Now()
+		message := fmt.Sprintf("previous run %s is not finished", previous)
+		if _, err := s.opStore.Create(ctx, operations.Record{
+			OperationID:   operationID,
+			RequestID:     requestID,
+			OperationType: string(OperationTypeLoad),
+			Status:        operations.StatusSkipped,
+			Date:          dates.String(),
+			SourceFolder:  sourceFolder,
+			Component:     "scheduler",
+			StartedAt:     fireAt,
+			UpdatedAt:     now,
+			FinishedAt:    &now,
+			ErrorMessage:  message,
+			FailedStage:   "schedule_overlap",
+		}); err != nil {
+			log.Warn("Failed to record skipped scheduled load",
+				"schedule", name,
+				"error", err.Error(),
+				"event", "schedule_record_error",
+			)
+		}
+		load.markFired(fireAt, operationID, operations.StatusSkipped)
+		log.Warn("Scheduled load skipped: previous run is not finished",
+			"schedule", name,
+			"previous_operation_id", previous,
+			"date", dates.String(),
+			"event", "schedule_fire_skipped",
+		)
+		return
+	}
+
+	item := &QueueItem{
+		RequestID:     requestID,
+		OperationID:   operationID,
+		Date:          dates.From,
+		DateTo:        dates.To,
+		OperationType: OperationTypeLoad,
+		SourceFolder:  sourceFolder,
+		Kassas:        kassas,
+		Logger:        log,
+		CreatedAt:     time.Now(),
+	}
+	queued, err := s.enqueueServerLoad(item, "scheduler", fireAt)
+	if err != nil {
+		load.markFired(fireAt, operationID, operations.StatusFailed)
+		log.Error("Failed to queue scheduled load",
+			"schedule", name,
+			"error", err.Error(),
+			"event", "schedule_enqueue_error",
+		)
+		return
+	}
+	load.markFired(fireAt, operationID, operations.StatusQueued)
+	if !queued {
+		log.Info("Scheduled load already queued by another instance",
+			"schedule", name,
+			"event", "schedule_fire_duplicate",
+		)
+		return
+	}
+	log.Info("Scheduled load queued",
+		"log_kind", "loki_operational",
+		"schedule", name,
+		"date", dates.String(),
+		"kassas", kassas,
+		"event", "schedule_fired",
+	)
+}
+
+// enqueueServerLoad записывает загрузку, которую ставит сам сервер
+// (планировщик, дозагрузка пробелов), в etl_operation_runs со статусом queued
+// и ставит ее в очередь. Возвращает false без ошибки, если операция с таким
+// operation_id уже записана другой репликой.
+func (s *Server) enqueueServerLoad(item *QueueItem, component string, startedAt time.Time) (bool, error) {
+	ctx := context.Background()
+	record := operations.Record{
+		OperationID:   item.OperationID,
+		RequestID:     item.RequestID,
+		OperationType: string(item.OperationType),
+		Status:        operations.StatusQueued,
+		Date:          item.dates().String(),
+		SourceFolder:  item.SourceFolder,
+		Component:     component,
+		StartedAt:     startedAt,
+		UpdatedAt:     time.Now(),
+	}
+	created, err := s.opStore.Create(ctx, record)
+	switch {
+	case err != nil && s.durableQueue():
+		// Без БД durable-очередь все равно не примет загрузку
+		return false, fmt.Errorf("record operation: %w", err)
+	case err != nil:
+		item.Logger.Warn("Failed to record operation, queueing anyway",
+			"operation_component", component,
+			"error", err.Error(),
+			"event", "operation_record_error",
+		)
+	case !created:
+		return false, nil
+	}
+
+	if err := s.enqueue(item); err != nil {
+		now := time.Now()
+		record.Status = operations.StatusFailed
+		record.UpdatedAt = now
+		record.FinishedAt = &now
+		record.ErrorMessage = err.Error()
+		record.FailedStage = "enqueue"
+		s.trackOperation(ctx, record)
+		return false, err
+	}
+	return true, nil
+}
+
+// unfinishedScheduledRun возвращает operation_id незавершенного запуска
+// расписания. Без БД проверяется только последний запуск этого экземпляра.
+func (s *Server) unfinishedScheduledRun(ctx context.Context, load *scheduledLoad) string {
+	page, err := s.opStore.List(ctx, operations.ListFilter{
+		RequestID: scheduleRequestID(load.schedule.Name),
+		Active:    true,
+		Limit:     1,
+	})
+	if err == nil {
+		if len(page.Operations) > 0 {
+			return page.Operations[0].OperationID
+		}
+		return ""
+	}
+
+	load.mu.Lock()
+	last := load.lastOperationID
+	load.mu.Unlock()
+	if last != "" && s.activeOps.active(last) {
+		return last
+	}
+	return ""
+}
+
+// schedulesHandler обрабатывает GET /api/schedules: расписания, ближайшие и
+// последние запуски.
+func (s *Server) schedulesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	schedules := make([]scheduleStatus, 0, len(s.schedules))
+	for _, load := range s.schedules {
+		status := scheduleStatus{
+			Name:   load.schedule.Name,
+			Cron:   load.schedule.Cron,
+			Days:   load.schedule.Days,
+			Kassas: load.schedule.Kassas,
+		}
+		load.mu.Lock()
+		if !load.next.IsZero() {
+			next := load.next
+			status.NextRun = &next
+		}
+		if !load.lastFire.IsZero() {
+			lastFire := load.lastFire
+			status.LastRun = &lastFire
+			status.LastOperationID = load.lastFireID
+			status.LastStatus = load.lastFireStatus
+		}
+		load.mu.Unlock()
+
+		// Последний запуск мог сделать другой экземпляр; его статус — в etl_operation_runs
+		page, err := s.opStore.List(ctx, operations.ListFilter{RequestID: scheduleRequestID(load.schedule.Name), Limit: 1})
+		if err == nil && len(page.Operations) > 0 {
+			last := page.Operations[0]
+			status.LastRun = &last.StartedAt
+			status.LastOperationID = last.OperationID
+			status.LastStatus = last.Status
+		}
+		schedules = append(schedules, status)
+	}
+
+	timezone := ""
+	if s.scheduleLocation != nil {
+		timezone = s.scheduleLocation.String()
+	}
+	response := map[string]interface{}{
+		"schedules": schedules,
+		"count":     len(schedules),
+		"timezone":  timezone,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		s.logger.ErrorContext(ctx, "Failed to encode response",
+			"error", err.Error(),
+			"event", "response_encode_error",
+		)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/operations"
+	"github.com/user/go-frontol-loader/pkg/scheduler"
+)
+
+func newTestSchedule(t *testing.T, s *Server, schedule models.Schedule) *scheduledLoad {
+	t.Helper()
+	cron, err := scheduler.Parse(schedule.Cron)
+	if err != nil {
+		t.Fatalf("Parse(%q) unexpected error: %v", schedule.Cron, err)
+	}
+	load := &scheduledLoad{schedule: schedule, cron: cron}
+	s.schedules = append(s.schedules, load)
+	s.scheduleLocation = time.UTC
+	return load
+}
+
+func TestFireScheduleQueuesLoadAndSkipsOverlap(t *testing.T) {
+	s := newTestServer(t, "")
+	load := newTestSchedule(t, s, models.Schedule{Name: "nightly", Cron: "0 2 * * *", Days: 3})
+	queue := s.queueManager.GetOrCreateQueue(OperationTypeLoad)
+
+	fireAt := time.Date(2024, 12, 8, 2, 0, 0, 0, time.UTC)
+	s.fireSchedule(load, fireAt)
+
+	if got := queue.Size(); got != 1 {
+		t.Fatalf("queue size = %d, want 1", got)
+	}
+	item := <-queue.queue
+	if item.RequestID != "schedule_nightly" || item.OperationID != scheduleOperationID("nightly", fireAt) {
+		t.Fatalf("item ids = %s/%s, want schedule_nightly/%s", item.RequestID, item.OperationID, scheduleOperationID("nightly", fireAt))
+	}
+	if item.Date != "2024-12-05" || item.DateTo != "2024-12-07" {
+		t.Fatalf("item dates = %s..%s, want 2024-12-05..2024-12-07", item.Date, item.DateTo)
+	}
+
+	// Предыдущий запуск еще ждет в очереди: следующее срабатывание пропускается
+	s.activeOps.addQueued(item)
+	s.fireSchedule(load, fireAt.Add(24*time.Hour))
+	if got := queue.Size(); got != 0 {
+		t.Fatalf("queue size = %d, want 0 for overlapping firing", got)
+	}
+	if load.lastFireStatus != operations.StatusSkipped {
+		t.Fatalf("last fire status = %s, want %s", load.lastFireStatus, operations.StatusSkipped)
+	}
+
+	// После завершения предыдущего запуска расписание снова ставит загрузки
+	s.activeOps.removeQueued(item.OperationID)
+	s.fireSchedule(load, fireAt.Add(48*time.Hour))
+	if got := queue.Size(); got != 1 {
+		t.Fatalf("queue size = %d, want 1 after previous run finished", got)
+	}
+}
+
+func TestSchedulesHandler(t *testing.T) {
+	s := newTestServer(t, "")
+	load := newTestSchedule(t, s, models.Schedule{Name: "weekly", Cron: "0 3 * * 0", Days: 3, Kassas: []string{"P13"}})
+	load.next = time.Date(2024, 12, 8, 3, 0, 0, 0, time.UTC)
+	load.markFired(time.Date(2024, 12, 1, 3, 0, 0, 0, time.UTC), "op_sched_weekly_1", operations.StatusQueued)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/schedules", nil)
+	rec := httptest.NewRecorder()
+	newTestMux(s).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/schedules = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var response struct {
+		Schedules []scheduleStatus `json:"schedules"`
+		Count     int              `json:"count"`
+		Timezone  string           `json:"timezone"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if response.Count != 1 || response.Timezone != "UTC" {
+		t.Fatalf("response = %+v, want one schedule in UTC", response)
+	}
+	got := response.Schedules[0]
+	if got.Name != "weekly" || got.NextRun == nil || !got.NextRun.Equal(load.next) {
+		t.Fatalf("schedule = %+v, want weekly with next_run %s", got, load.next)
+	}
+	if got.LastOperationID != "op_sched_weekly_1" || got.LastStatus != operations.StatusQueued {
+		t.Fatalf("schedule last run = %s/%s, want op_sched_weekly_1/queued", got.LastOperationID, got.LastStatus)
+	}
+}
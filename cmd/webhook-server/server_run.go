@@ -15,14 +15,23 @@ import (
 
 // Run запускает веб-сервер.
 func (s *Server) Run() error {
-	bearerAuth := auth.BearerAuthMiddleware(s.logger.Logger, s.config.WebhookBearerToken)
+	cfg := s.currentConfig()
+	bearerAuth := auth.BearerAuthMiddleware(s.logger.Logger, cfg.WebhookBearerToken)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/load", bearerAuth(s.webhookHandler))
 	mux.HandleFunc("/api/files", bearerAuth(s.downloadHandler))
 	mux.HandleFunc("/api/queue/status", bearerAuth(s.queueStatusHandler))
+	mux.HandleFunc("/api/operations", bearerAuth(s.listOperationsHandler))
+	mux.HandleFunc("/api/operations/", bearerAuth(s.operationHandler))
+	mux.HandleFunc("/api/schedules", bearerAuth(s.schedulesHandler))
+	mux.HandleFunc("/api/gaps", bearerAuth(s.gapsHandler))
+	mux.HandleFunc("/api/reports", bearerAuth(s.reportsHandler))
 	mux.HandleFunc("/api/kassas", bearerAuth(s.listKassasHandler))
+	mux.HandleFunc("/api/rejected-lines", bearerAuth(s.listRejectedLinesHandler))
+	mux.HandleFunc("/api/rejected-lines/reprocess", bearerAuth(s.reprocessRejectedLinesHandler))
 	mux.HandleFunc("/api/health", s.healthHandler)
+	mux.HandleFunc("/metrics", s.metricsHandler)
 	mux.HandleFunc("/api/docs", s.docsHandler)
 	mux.HandleFunc("/api/openapi.yaml", s.openAPIHandler)
 	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
@@ -30,20 +39,21 @@ func (s *Server) Run() error {
 	handler := server.RequestIDMiddleware(mux)
 	handler = server.LoggingMiddleware(s.logger)(handler)
 	handler = server.RecoveryMiddleware(s.logger)(handler)
+	handler = withTracing(handler)
 
-	port := s.config.ServerPort
+	port := cfg.ServerPort
 	if port == 0 {
 		port = 8080
 	}
 	addr := fmt.Sprintf(":%d", port)
 
-	if s.config.WebhookBearerToken == "" {
+	if cfg.WebhookBearerToken == "" {
 		s.logger.Warn("Bearer token not configured - authorization is DISABLED",
 			"event", "auth_config_missing",
 		)
 	} else {
 		s.logger.Info("Bearer token configured - authorization is ENABLED",
-			"token_length", len(s.config.WebhookBearerToken),
+			"token_length", len(cfg.WebhookBearerToken),
 			"event", "auth_config_loaded",
 		)
 	}
@@ -53,21 +63,22 @@ func (s *Server) Run() error {
 		"event", "server_start",
 	)
 	s.logger.Info("Queue system: parallel processing for different operation types, sequential for same operation type",
+		"queue_provider", s.queueProvider(),
 		"event", "queue_system_info",
 	)
 	s.logger.Info("Runtime timeout configuration loaded",
 		"event", "timeout_config_loaded",
-		"db_connect_timeout", s.config.EffectiveDBConnectTimeout(),
-		"ftp_connect_timeout", s.config.EffectiveFTPConnectTimeout(),
-		"http_read_header_timeout", s.config.EffectiveHTTPReadHeaderTimeout(),
-		"http_read_timeout", s.config.EffectiveHTTPReadTimeout(),
-		"http_write_timeout", s.config.EffectiveHTTPWriteTimeout(),
-		"http_idle_timeout", s.config.EffectiveHTTPIdleTimeout(),
-		"pipeline_load_timeout", s.config.EffectivePipelineLoadTimeout(),
-		"operation_stale_timeout", s.config.EffectiveOperationStaleTimeout(),
-		"webhook_report_http_timeout", s.config.EffectiveWebhookReportHTTPTimeout(),
-		"webhook_report_result_wait_timeout", s.config.EffectiveWebhookReportResultWaitTimeout(),
-		"shutdown_timeout", s.config.EffectiveShutdownTimeout(),
+		"db_connect_timeout", cfg.EffectiveDBConnectTimeout(),
+		"ftp_connect_timeout", cfg.EffectiveFTPConnectTimeout(),
+		"http_read_header_timeout", cfg.EffectiveHTTPReadHeaderTimeout(),
+		"http_read_timeout", cfg.EffectiveHTTPReadTimeout(),
+		"http_write_timeout", cfg.EffectiveHTTPWriteTimeout(),
+		"http_idle_timeout", cfg.EffectiveHTTPIdleTimeout(),
+		"pipeline_load_timeout", cfg.EffectivePipelineLoadTimeout(),
+		"operation_stale_timeout", cfg.EffectiveOperationStaleTimeout(),
+		"webhook_report_http_timeout", cfg.EffectiveWebhookReportHTTPTimeout(),
+		"webhook_report_result_wait_timeout", cfg.EffectiveWebhookReportResultWaitTimeout(),
+		"shutdown_timeout", cfg.EffectiveShutdownTimeout(),
 	)
 	if s.opStore != nil {
 		abandoned, err := s.opStore.RecoverStale(context.Background())
@@ -83,13 +94,33 @@ func (s *Server) Run() error {
 			)
 		}
 	}
+	if err := s.loadSubscribers(); err != nil {
+		return err
+	}
+	s.startDurableQueue()
+	s.startReportOutbox()
+	if err := s.startScheduler(); err != nil {
+		return err
+	}
+	s.startConfigReload()
 	s.logger.Info("Available endpoints",
 		"endpoints", []string{
 			"POST /api/load - загрузка данных из FTP в БД",
 			"GET /api/files?source_folder=XXX&date=YYYY-MM-DD - выгрузка данных из БД в файл",
 			"GET /api/queue/status - статус очереди",
+			"GET /api/operations - история операций",
+			"GET /api/operations/{operation_id} - статус и итог операции",
+			"DELETE /api/operations/{operation_id} - отмена операции",
+			"GET /api/operations/{operation_id}/events - ход операции (Server-Sent Events)",
+			"GET /api/schedules - расписания встроенного планировщика",
+			"GET /api/gaps - пробелы в данных касс",
+			"GET /api/reports - webhook-отчеты из outbox",
+			"POST /api/reports - повторная доставка отчетов из dead-letter",
 			"GET /api/kassas - список касс",
+			"GET /api/rejected-lines - отклоненные парсером строки",
+			"POST /api/rejected-lines/reprocess - повторный разбор отклоненных строк",
 			"GET /api/health - health check",
+			"GET /metrics - метрики Prometheus",
 			"GET /api/docs - документация API (Scalar)",
 			"GET /api/openapi.yaml - OpenAPI спецификация",
 		},
@@ -99,11 +130,12 @@ func (s *Server) Run() error {
 	s.httpServer = &http.Server{
 		Addr:              addr,
 		Handler:           handler,
-		ReadHeaderTimeout: s.config.EffectiveHTTPReadHeaderTimeout(),
-		ReadTimeout:       s.config.EffectiveHTTPReadTimeout(),
-		WriteTimeout:      s.config.EffectiveHTTPWriteTimeout(),
-		IdleTimeout:       s.config.EffectiveHTTPIdleTimeout(),
+		ReadHeaderTimeout: cfg.EffectiveHTTPReadHeaderTimeout(),
+		ReadTimeout:       cfg.EffectiveHTTPReadTimeout(),
+		WriteTimeout:      cfg.EffectiveHTTPWriteTimeout(),
+		IdleTimeout:       cfg.EffectiveHTTPIdleTimeout(),
 	}
+	s.httpServer.RegisterOnShutdown(s.events.stop)
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
@@ -41,11 +41,13 @@ func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 		overallStatus = "degraded"
 	}
 
+	queue := s.queueSnapshot(ctx)
 	checks["queues"] = map[string]interface{}{
-		"load_queue_size":     s.queueManager.GetQueueSize(OperationTypeLoad),
-		"download_queue_size": s.queueManager.GetQueueSize(OperationTypeDownload),
-		"total_queue_size":    s.queueManager.GetTotalSize(),
-		"active_operations":   s.queueManager.GetActiveOperationsCount(),
+		"queue_provider":      s.queueProvider(),
+		"load_queue_size":     queue.sizes[OperationTypeLoad],
+		"download_queue_size": queue.sizes[OperationTypeDownload],
+		"total_queue_size":    queue.total,
+		"active_operations":   queue.active,
 		"is_shutting_down":    s.stopping.Load(),
 	}
 
@@ -69,7 +71,7 @@ func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) checkDatabase(ctx context.Context) (string, time.Duration) {
 	start := time.Now()
-	database, err := db.NewPool(s.config)
+	database, err := db.NewPool(s.currentConfig())
 	if err != nil {
 		return "unhealthy", time.Since(start)
 	}
@@ -79,7 +81,7 @@ func (s *Server) checkDatabase(ctx context.Context) (string, time.Duration) {
 
 func (s *Server) checkFTP(ctx context.Context) (string, time.Duration) {
 	start := time.Now()
-	ftpClient, err := ftp.NewClient(s.config)
+	ftpClient, err := ftp.Open(s.currentConfig(), 1)
 	if err != nil {
 		return "unhealthy", time.Since(start)
 	}
@@ -108,13 +110,14 @@ func (s *Server) queueStatusHandler(w http.ResponseWriter, r *http.Request) {
 		"event", "queue_status_request",
 	)
 
+	queue := s.queueSnapshot(ctx)
 	response := map[string]interface{}{
-		"queue_provider":      "memory",
-		"total_queue_size":    s.queueManager.GetTotalSize(),
+		"queue_provider":      s.queueProvider(),
+		"total_queue_size":    queue.total,
 		"timestamp":           time.Now().Format(time.RFC3339),
-		"load_queue_size":     s.queueManager.GetQueueSize(OperationTypeLoad),
-		"download_queue_size": s.queueManager.GetQueueSize(OperationTypeDownload),
-		"active_operations":   s.queueManager.GetActiveOperationsCount(),
+		"load_queue_size":     queue.sizes[OperationTypeLoad],
+		"download_queue_size": queue.sizes[OperationTypeDownload],
+		"active_operations":   queue.active,
 		"is_shutting_down":    s.stopping.Load(),
 	}
 
@@ -141,7 +144,7 @@ func (s *Server) listKassasHandler(w http.ResponseWriter, r *http.Request) {
 		"event", "list_source_folders_request",
 	)
 
-	database, err := db.NewPool(s.config)
+	database, err := db.NewPool(s.currentConfig())
 	if err != nil {
 		log.ErrorContext(ctx, "Failed to connect to database",
 			"error", err.Error(),
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/webhook"
+)
+
+// loadSubscribers проверяет подписчиков из WEBHOOK_SUBSCRIBERS_FILE при старте
+// сервера: фильтры по кассам разрешаются в source_folder, как кассы
+// расписаний, поэтому неизвестная касса не дает серверу запуститься.
+func (s *Server) loadSubscribers() error {
+	subscribers, err := compileSubscribers(s.currentConfig())
+	if err != nil {
+		return err
+	}
+	s.configMu.Lock()
+	s.subscribers = subscribers
+	s.configMu.Unlock()
+	if len(subscribers) > 0 {
+		s.logger.Info("Webhook report subscribers loaded",
+			"subscribers", len(subscribers),
+			"event", "webhook_subscribers_loaded",
+		)
+	}
+	return nil
+}
+
+// compileSubscribers разрешает кассы подписчиков cfg в source_folder и
+// компилирует их шаблоны.
+func compileSubscribers(cfg *models.Config) ([]*webhook.Subscriber, error) {
+	subscribers := make([]*webhook.Subscriber, 0, len(cfg.WebhookSubscribers))
+	for _, sub := range cfg.WebhookSubscribers {
+		kassas, err := kassaSourceFolders(cfg, sub.Kassas)
+		if err != nil {
+			return nil, fmt.Errorf("subscriber %s: %w", sub.Name, err)
+		}
+		sub.Kassas = kassas
+		compiled, err := webhook.Compile(sub)
+		if err != nil {
+			return nil, err
+		}
+		subscribers = append(subscribers, compiled)
+	}
+	return subscribers, nil
+}
+
+// reportSubscribers возвращает всех получателей отчетов: подписчика
+// WEBHOOK_REPORT_URL (default), если URL задан, и подписчиков из файла.
+func (s *Server) reportSubscribers() []*webhook.Subscriber {
+	s.configMu.RLock()
+	cfg, fileSubscribers := s.config, s.subscribers
+	s.configMu.RUnlock()
+
+	subscribers := make([]*webhook.Subscriber, 0, len(fileSubscribers)+1)
+	if cfg.WebhookReportURL != "" {
+		subscribers = append(subscribers, webhook.Default(cfg.WebhookReportURL, cfg.WebhookBearerToken))
+	}
+	return append(subscribers, fileSubscribers...)
+}
+
+// reportSubscriber возвращает получателя с именем name или nil, если такого
+// получателя больше нет в конфигурации.
+func (s *Server) reportSubscriber(name string) *webhook.Subscriber {
+	for _, sub := range s.reportSubscribers() {
+		if sub.Name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// reportForSubscriber возвращает отчет в том виде, в каком его получает sub:
+// подписчик с фильтром по кассам видит в kassas и kassa_details только свои
+// кассы. Итоговые счетчики отчета остаются общими для операции.
+func reportForSubscriber(report *WebhookReport, sub *webhook.Subscriber) *WebhookReport {
+	if !sub.HasKassaFilter() {
+		return report
+	}
+	narrowed := *report
+	narrowed.Kassas = nil
+	for _, sourceFolder := range report.Kassas {
+		if sub.MatchesKassa(sourceFolder) {
+			narrowed.Kassas = append(narrowed.Kassas, sourceFolder)
+		}
+	}
+	narrowed.KassaDetails = nil
+	for _, details := range report.KassaDetails {
+		if sub.MatchesKassa(details.SourceFolder) {
+			narrowed.KassaDetails = append(narrowed.KassaDetails, details)
+		}
+	}
+	return &narrowed
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/pipeline"
+)
+
+func TestLoadSubscribers_RejectsUnknownKassa(t *testing.T) {
+	s := newTestServer(t, "")
+	s.config.KassaStructure = map[string][]string{"P13": {"P13"}, "L32": {"L32", "L32_INTER"}}
+	s.config.WebhookSubscribers = []models.WebhookSubscriber{
+		{Name: "l32", URL: "http://receiver/reports", Kassas: []string{"L32"}},
+	}
+	if err := s.loadSubscribers(); err != nil {
+		t.Fatalf("loadSubscribers() unexpected error: %v", err)
+	}
+	if got := s.subscribers[0].Kassas; strings.Join(got, ",") != "L32/L32,L32/L32_INTER" {
+		t.Fatalf("subscriber kassas = %v, want source folders of L32", got)
+	}
+
+	s.config.WebhookSubscribers[0].Kassas = []string{"N45"}
+	if err := s.loadSubscribers(); err == nil || !strings.Contains(err.Error(), "subscriber l32") {
+		t.Fatalf("loadSubscribers() error = %v, want unknown kassa of subscriber l32", err)
+	}
+}
+
+func TestSendWebhookReport_FansOutToMatchingSubscribers(t *testing.T) {
+	type delivery struct {
+		path        string
+		body        string
+		contentType string
+		apiKey      string
+		auth        string
+	}
+	deliveries := make(chan delivery, 4)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		deliveries <- delivery{
+			path:        r.URL.Path,
+			body:        string(body),
+			contentType: r.Header.Get("Content-Type"),
+			apiKey:      r.Header.Get("X-Api-Key"),
+			auth:        r.Header.Get("Authorization"),
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	// БД тестового сервера недоступна: отчеты уходят сразу, без outbox
+	s := newTestServer(t, "default-token")
+	s.config.KassaStructure = map[string][]string{"P13": {"P13"}, "L32": {"L32"}}
+	s.config.WebhookReportURL = receiver.URL + "/default"
+	s.config.WebhookSubscribers = []models.WebhookSubscriber{
+		{Name: "failures", URL: receiver.URL + "/failures", Statuses: []string{"failed"}},
+		{
+			Name:        "p13",
+			URL:         receiver.URL + "/p13",
+			AuthHeader:  "X-Api-Key: p13-key",
+			Kassas:      []string{"P13"},
+			Template:    `kassas={{range .kassa_details}}{{.source_folder}};{{end}}`,
+			ContentType: "text/plain",
+		},
+		{Name: "downloads", URL: receiver.URL + "/downloads", OperationTypes: []string{"download"}},
+	}
+	if err := s.loadSubscribers(); err != nil {
+		t.Fatalf("loadSubscribers() unexpected error: %v", err)
+	}
+
+	s.sendWebhookReport(context.Background(), "op-fanout", &WebhookReport{
+		RequestID:     "req-fanout",
+		OperationType: string(OperationTypeLoad),
+		Status:        "completed",
+		Kassas:        []string{"L32/L32", "P13/P13"},
+		KassaDetails: []pipeline.KassaProcessingStats{
+			{KassaCode: "L32", SourceFolder: "L32/L32"},
+			{KassaCode: "P13", SourceFolder: "P13/P13"},
+		},
+	})
+
+	got := make(map[string]delivery)
+	for len(got) < 2 {
+		select {
+		case d := <-deliveries:
+			got[d.path] = d
+		case <-time.After(time.Second):
+			t.Fatalf("deliveries = %v, want /default and /p13", got)
+		}
+	}
+	select {
+	case d := <-deliveries:
+		t.Fatalf("unexpected delivery to %s", d.path)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	defaultDelivery, ok := got["/default"]
+	if !ok || defaultDelivery.auth != "Bearer default-token" {
+		t.Fatalf("default delivery = %+v, want bearer token", defaultDelivery)
+	}
+	var report WebhookReport
+	if err := json.Unmarshal([]byte(defaultDelivery.body), &report); err != nil || len(report.KassaDetails) != 2 {
+		t.Fatalf("default report = %s (%v), want both kassas", defaultDelivery.body, err)
+	}
+
+	p13 := got["/p13"]
+	if p13.body != "kassas=P13/P13;" || p13.contentType != "text/plain" || p13.apiKey != "p13-key" || p13.auth != "" {
+		t.Fatalf("p13 delivery = %+v, want templated P13 report with its own auth header", p13)
+	}
+}
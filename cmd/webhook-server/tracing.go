@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// withTracing оборачивает обработчик в span запроса. Span продолжает трассу из
+// входящего заголовка traceparent; опросы /metrics и /api/health не трассируются.
+func withTracing(handler http.Handler) http.Handler {
+	return otelhttp.NewHandler(handler, "webhook-server",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + httpSpanRoute(r.URL.Path)
+		}),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return r.URL.Path != "/metrics" && r.URL.Path != "/api/health"
+		}),
+	)
+}
+
+// httpSpanRoute заменяет operation_id в пути шаблоном, чтобы имена span не
+// зависели от конкретной операции.
+func httpSpanRoute(path string) string {
+	if strings.HasPrefix(path, "/api/operations/") {
+		if strings.HasSuffix(path, "/events") {
+			return "/api/operations/{operation_id}/events"
+		}
+		return "/api/operations/{operation_id}"
+	}
+	return path
+}
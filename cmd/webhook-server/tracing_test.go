@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/pipeline"
+	"github.com/user/go-frontol-loader/pkg/tracing"
+)
+
+const testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+func TestLoadTraceParentReachesQueueAndReport(t *testing.T) {
+	if _, err := tracing.Setup(context.Background(), &models.Config{TracingExporter: tracing.ExporterNone}); err != nil {
+		t.Fatalf("tracing setup: %v", err)
+	}
+
+	s := newTestServer(t, "token")
+	handler := withTracing(newTestMux(s))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/load", strings.NewReader(`{"date":"2024-12-01"}`))
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("traceparent", "00-"+testTraceID+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rec.Code)
+	}
+
+	var item *QueueItem
+	select {
+	case item = <-s.queueManager.GetOrCreateQueue(OperationTypeLoad).queue:
+	case <-time.After(time.Second):
+		t.Fatal("load was not queued")
+	}
+	// Очередь хранит traceparent запроса, а не только trace_id
+	runCtx := tracing.WithTraceParent(context.Background(), item.TraceParent)
+	if got := tracing.TraceID(runCtx); got != testTraceID {
+		t.Fatalf("queued trace id = %q (traceparent %q), want %q", got, item.TraceParent, testTraceID)
+	}
+
+	var gotHeader, gotBodyTraceID string
+	reportServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		var report WebhookReport
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			t.Errorf("decode report: %v", err)
+		}
+		gotHeader = r.Header.Get("traceparent")
+		gotBodyTraceID = report.TraceID
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer reportServer.Close()
+	s.config.WebhookReportURL = reportServer.URL
+	s.config.WebhookReportResultWaitTimeout = time.Second
+
+	oldRunPipeline := runPipelineFunc
+	defer func() { runPipelineFunc = oldRunPipeline }()
+	runPipelineFunc = func(ctx context.Context, logger *slog.Logger, cfg *models.Config, dates models.DateRange, kassas []string) (*pipeline.PipelineResult, error) {
+		return &pipeline.PipelineResult{Status: pipeline.PipelineStatusCompleted, Success: true}, nil
+	}
+
+	s.runETLPipeline(runCtx, item.OperationID, item.RequestID, item.dates(), nil, s.logger)
+
+	if gotBodyTraceID != testTraceID {
+		t.Fatalf("report trace_id = %q, want %q", gotBodyTraceID, testTraceID)
+	}
+	if !strings.Contains(gotHeader, testTraceID) {
+		t.Fatalf("report traceparent = %q, want trace %s", gotHeader, testTraceID)
+	}
+}
+
+func TestHTTPSpanRoute(t *testing.T) {
+	tests := map[string]string{
+		"/api/load":                       "/api/load",
+		"/api/operations":                 "/api/operations",
+		"/api/operations/op_12345":        "/api/operations/{operation_id}",
+		"/api/operations/op_12345/events": "/api/operations/{operation_id}/events",
+	}
+	for path, want := range tests {
+		if got := httpSpanRoute(path); got != want {
+			t.Fatalf("httpSpanRoute(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
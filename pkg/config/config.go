@@ -1,21 +1,33 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/parser"
+	"github.com/user/go-frontol-loader/pkg/scheduler"
+	"github.com/user/go-frontol-loader/pkg/webhook"
 )
 
 // LoadConfig loads configuration from .env file and environment variables
 func LoadConfig() (*models.Config, error) {
 	// Load .env file if it exists
 	_ = godotenv.Load() // .env file is optional, continue with environment variables
-	loader := newEnvLoader()
+	configFile := getEnv("CONFIG_FILE", "")
+	file, err := loadConfigFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	loader := newEnvLoader(file.settings)
 
 	dbPort, err := loader.getEnvAsIntStrict("DB_PORT", 5432)
 	if err != nil {
@@ -25,10 +37,25 @@ func LoadConfig() (*models.Config, error) {
 	if err != nil {
 		return nil, err
 	}
-	ftpPort, err := loader.getEnvAsIntStrict("FTP_PORT", 21)
+	exchange, err := parseExchangeURL("EXCHANGE_URL", loader.getEnv("EXCHANGE_URL", ""))
+	if err != nil {
+		return nil, err
+	}
+	ftpProtocol := strings.ToLower(strings.TrimSpace(loader.getEnv("FTP_PROTOCOL", models.FTPProtocolFTP)))
+	if exchange.protocol != "" {
+		ftpProtocol = exchange.protocol
+	}
+	ftpPort, err := loader.getEnvAsIntStrict("FTP_PORT", models.DefaultFTPPort(ftpProtocol))
 	if err != nil {
 		return nil, err
 	}
+	if exchange.port != 0 {
+		ftpPort = exchange.port
+	}
+	ftpHost := loader.getEnv("FTP_HOST", "localhost")
+	if exchange.host != "" {
+		ftpHost = exchange.host
+	}
 	ftpConnectTimeoutSeconds, err := loader.getEnvAsIntStrict("FTP_CONNECT_TIMEOUT_SECONDS", int(models.DefaultFTPConnectTimeout/time.Second))
 	if err != nil {
 		return nil, err
@@ -53,6 +80,14 @@ func LoadConfig() (*models.Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	responsePollIntervalSeconds, err := loader.getEnvAsIntStrict("RESPONSE_POLL_INTERVAL_SECONDS", int(models.DefaultResponsePollInterval/time.Second))
+	if err != nil {
+		return nil, err
+	}
+	responsePollMaxBackoffSeconds, err := loader.getEnvAsIntStrict("RESPONSE_POLL_MAX_BACKOFF_SECONDS", int(models.DefaultResponsePollMaxBackoff/time.Second))
+	if err != nil {
+		return nil, err
+	}
 	pipelineLoadTimeoutMinutes, err := loader.getEnvAsIntStrict("PIPELINE_LOAD_TIMEOUT_MINUTES", int(models.DefaultPipelineLoadTimeout/time.Minute))
 	if err != nil {
 		return nil, err
@@ -85,6 +120,14 @@ func LoadConfig() (*models.Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	webhookReportMaxAttempts, err := loader.getEnvAsIntStrict("WEBHOOK_REPORT_MAX_ATTEMPTS", models.DefaultWebhookReportMaxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	webhookReportRetryDelaySeconds, err := loader.getEnvAsIntStrict("WEBHOOK_REPORT_RETRY_DELAY_SECONDS", int(models.DefaultWebhookReportRetryDelay/time.Second))
+	if err != nil {
+		return nil, err
+	}
 	httpReadHeaderTimeoutSeconds, err := loader.getEnvAsIntStrict("HTTP_READ_HEADER_TIMEOUT_SECONDS", int(models.DefaultHTTPReadHeaderTimeout/time.Second))
 	if err != nil {
 		return nil, err
@@ -105,7 +148,64 @@ func LoadConfig() (*models.Config, error) {
 	if err != nil {
 		return nil, err
 	}
-	kassaStructure, err := parseKassaStructure(loader.getEnv("KASSA_STRUCTURE", ""))
+	queueWorkers, err := loader.getEnvAsIntStrict("QUEUE_WORKERS", models.DefaultQueueWorkers)
+	if err != nil {
+		return nil, err
+	}
+	queuePollIntervalSeconds, err := loader.getEnvAsIntStrict("QUEUE_POLL_INTERVAL_SECONDS", int(models.DefaultQueuePollInterval/time.Second))
+	if err != nil {
+		return nil, err
+	}
+	queueLeaseTimeoutSeconds, err := loader.getEnvAsIntStrict("QUEUE_LEASE_TIMEOUT_SECONDS", int(models.DefaultQueueLeaseTimeout/time.Second))
+	if err != nil {
+		return nil, err
+	}
+	gapCheckDays, err := loader.getEnvAsIntStrict("GAP_CHECK_DAYS", models.DefaultGapCheckDays)
+	if err != nil {
+		return nil, err
+	}
+	// KASSA_STRUCTURE, FTP_ENDPOINTS_FILE and WEBHOOK_SUBSCRIBERS_FILE replace
+	// the matching sections of CONFIG_FILE
+	kassaStructureEnv := loader.getEnv("KASSA_STRUCTURE", "")
+	useFileKassas := kassaStructureEnv == "" && file.kassaStructure != nil
+	kassaStructure, kassaEncodings, kassaTimezones := file.kassaStructure, file.kassaEncodings, file.kassaTimezones
+	if !useFileKassas {
+		kassaTimezones = nil
+		kassaStructure, kassaEncodings, err = parseKassaStructure(kassaStructureEnv)
+		if err != nil {
+			return nil, err
+		}
+	}
+	kassaResponseDeadlines, err := parseKassaResponseDeadlines(loader.getEnv("KASSA_RESPONSE_DEADLINES", ""))
+	if err != nil {
+		return nil, err
+	}
+	kassaFTPTransports, err := parseKassaFTPProtocols(loader.getEnv("KASSA_FTP_PROTOCOLS", ""))
+	if err != nil {
+		return nil, err
+	}
+	schedules, err := parseSchedules(loader.getEnv("SCHEDULES", ""))
+	if err != nil {
+		return nil, err
+	}
+	webhookSubscribers := file.webhookSubscribers
+	if path := loader.getEnv("WEBHOOK_SUBSCRIBERS_FILE", ""); path != "" {
+		if webhookSubscribers, err = loadWebhookSubscribers(path); err != nil {
+			return nil, err
+		}
+	}
+	ftpEndpoints := file.ftpEndpoints
+	if path := loader.getEnv("FTP_ENDPOINTS_FILE", ""); path != "" {
+		if ftpEndpoints, err = loadFTPEndpoints(path); err != nil {
+			return nil, err
+		}
+	}
+	if useFileKassas {
+		if ftpEndpoints, err = file.assignEndpoints(ftpEndpoints); err != nil {
+			return nil, err
+		}
+	}
+	configReloadIntervalSeconds, err := loader.getEnvAsIntStrict("CONFIG_RELOAD_INTERVAL_SECONDS", int(models.DefaultConfigReloadInterval/time.Second))
 	if err != nil {
 		return nil, err
 	}
@@ -121,29 +221,62 @@ func LoadConfig() (*models.Config, error) {
 		DBConnectTimeout: time.Duration(dbConnectTimeoutSeconds) * time.Second,
 
 		// FTP settings
-		FTPHost:           loader.getEnv("FTP_HOST", "localhost"),
-		FTPPort:           ftpPort,
-		FTPUser:           loader.getEnv("FTP_USER", ""),
-		FTPPassword:       loader.getEnv("FTP_PASSWORD", ""),
-		FTPRequestDir:     loader.getEnv("FTP_REQUEST_DIR", "/request"),
-		FTPResponseDir:    loader.getEnv("FTP_RESPONSE_DIR", "/response"),
-		FTPPoolSize:       ftpPoolSize,
-		FTPConnectTimeout: time.Duration(ftpConnectTimeoutSeconds) * time.Second,
-		KassaStructure:    kassaStructure,
+		FTPHost:            ftpHost,
+		FTPPort:            ftpPort,
+		FTPUser:            loader.getEnv("FTP_USER", ""),
+		FTPPassword:        loader.getEnv("FTP_PASSWORD", ""),
+		FTPRequestDir:      loader.getEnv("FTP_REQUEST_DIR", "/request"),
+		FTPResponseDir:     loader.getEnv("FTP_RESPONSE_DIR", "/response"),
+		FTPPoolSize:        ftpPoolSize,
+		FTPConnectTimeout:  time.Duration(ftpConnectTimeoutSeconds) * time.Second,
+		KassaStructure:     kassaStructure,
+		KassaEncodings:     kassaEncodings,
+		KassaTimezones:     kassaTimezones,
+		FTPProtocol:        ftpProtocol,
+		FTPTLSCAFile:       loader.getEnv("FTP_TLS_CA_FILE", ""),
+		FTPTLSCertFile:     loader.getEnv("FTP_TLS_CERT_FILE", ""),
+		FTPTLSKeyFile:      loader.getEnv("FTP_TLS_KEY_FILE", ""),
+		FTPTLSServerName:   loader.getEnv("FTP_TLS_SERVER_NAME", ""),
+		FTPSFTPKeyFile:     loader.getEnv("FTP_SFTP_KEY_FILE", ""),
+		FTPSFTPKnownHosts:  loader.getEnv("FTP_SFTP_KNOWN_HOSTS", ""),
+		KassaFTPTransports: kassaFTPTransports,
+
+		// Exchange backend settings
+		ExchangeBackend:   exchange.backend,
+		ExchangeRoot:      exchange.root,
+		S3Bucket:          exchange.bucket,
+		S3Endpoint:        exchange.endpoint,
+		S3Region:          exchange.region,
+		S3DisableTLS:      exchange.disableTLS,
+		S3AccessKeyID:     loader.getEnv("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey: loader.getEnv("S3_SECRET_ACCESS_KEY", ""),
+		FTPEndpoints:      ftpEndpoints,
+
+		// Configuration file settings
+		ConfigFile:           configFile,
+		ConfigReloadInterval: time.Duration(configReloadIntervalSeconds) * time.Second,
 
 		// Application settings
-		LocalDir:              loader.getEnv("LOCAL_DIR", "/tmp/frontol"),
-		BatchSize:             batchSize,
-		MaxRetries:            maxRetries,
-		RetryDelay:            time.Duration(retryDelaySeconds) * time.Second,
-		WaitDelayMinutes:      time.Duration(waitDelayMinutes) * time.Minute,
-		PipelineLoadTimeout:   time.Duration(pipelineLoadTimeoutMinutes) * time.Minute,
-		CLIRunTimeout:         time.Duration(cliRunTimeoutMinutes) * time.Minute,
-		OperationStaleTimeout: time.Duration(operationStaleTimeoutMinutes) * time.Minute,
-		WorkerPoolSize:        workerPoolSize,
-		LogLevel:              loader.getEnv("LOG_LEVEL", "info"),
-		LogFormat:             loader.getEnv("LOG_FORMAT", "json"),
-		LogBackend:            loader.getEnv("LOG_BACKEND", "zerolog"),
+		LocalDir:               loader.getEnv("LOCAL_DIR", "/tmp/frontol"),
+		BatchSize:              batchSize,
+		ParseMode:              loader.getEnv("PARSE_MODE", "lenient"),
+		LoadStrategy:           loader.getEnv("LOAD_STRATEGY", "batch"),
+		MaxRetries:             maxRetries,
+		RetryDelay:             time.Duration(retryDelaySeconds) * time.Second,
+		WaitDelayMinutes:       time.Duration(waitDelayMinutes) * time.Minute,
+		ResponsePollInterval:   time.Duration(responsePollIntervalSeconds) * time.Second,
+		ResponsePollMaxBackoff: time.Duration(responsePollMaxBackoffSeconds) * time.Second,
+		KassaResponseDeadlines: kassaResponseDeadlines,
+		PipelineLoadTimeout:    time.Duration(pipelineLoadTimeoutMinutes) * time.Minute,
+		CLIRunTimeout:          time.Duration(cliRunTimeoutMinutes) * time.Minute,
+		OperationStaleTimeout:  time.Duration(operationStaleTimeoutMinutes) * time.Minute,
+		WorkerPoolSize:         workerPoolSize,
+		LogLevel:               loader.getEnv("LOG_LEVEL", "info"),
+		LogFormat:              loader.getEnv("LOG_FORMAT", "json"),
+		LogBackend:             loader.getEnv("LOG_BACKEND", "zerolog"),
+		TracingExporter:        loader.getEnv("TRACING_EXPORTER", "none"),
+		TracingOTLPEndpoint:    loader.getEnv("TRACING_OTLP_ENDPOINT", ""),
+		TracingServiceName:     loader.getEnv("TRACING_SERVICE_NAME", "frontol-etl"),
 
 		// Webhook server settings
 		ServerPort:                     serverPort,
@@ -152,11 +285,23 @@ func LoadConfig() (*models.Config, error) {
 		WebhookReportHTTPTimeout:       time.Duration(webhookReportHTTPTimeoutSeconds) * time.Second,
 		WebhookReportResultWaitTimeout: time.Duration(webhookReportResultWaitSeconds) * time.Second,
 		WebhookBearerToken:             loader.getEnv("WEBHOOK_BEARER_TOKEN", ""),
+		WebhookSigningSecret:           loader.getEnv("WEBHOOK_SIGNING_SECRET", ""),
+		WebhookReportMaxAttempts:       webhookReportMaxAttempts,
+		WebhookReportRetryDelay:        time.Duration(webhookReportRetryDelaySeconds) * time.Second,
+		WebhookSubscribers:             webhookSubscribers,
 		HTTPReadHeaderTimeout:          time.Duration(httpReadHeaderTimeoutSeconds) * time.Second,
 		HTTPReadTimeout:                time.Duration(httpReadTimeoutSeconds) * time.Second,
 		HTTPWriteTimeout:               time.Duration(httpWriteTimeoutSeconds) * time.Second,
 		HTTPIdleTimeout:                time.Duration(httpIdleTimeoutSeconds) * time.Second,
 		ShutdownTimeout:                time.Duration(shutdownTimeoutSeconds) * time.Second,
+		QueueProvider:                  loader.getEnv("QUEUE_PROVIDER", "postgres"),
+		QueueWorkers:                   queueWorkers,
+		QueuePollInterval:              time.Duration(queuePollIntervalSeconds) * time.Second,
+		QueueLeaseTimeout:              time.Duration(queueLeaseTimeoutSeconds) * time.Second,
+		Schedules:                      schedules,
+		ScheduleTimezone:               loader.getEnv("SCHEDULE_TIMEZONE", "Local"),
+		GapCheckDays:                   gapCheckDays,
+		GapBackfillSchedule:            loader.getEnv("GAP_BACKFILL_SCHEDULE", ""),
 	}
 
 	// Validate configuration
@@ -173,10 +318,12 @@ func ValidateConfig(cfg *models.Config) error {
 	if cfg.DBPassword == "" {
 		return fmt.Errorf("DB_PASSWORD is required")
 	}
-	if cfg.FTPUser == "" {
+	// FTP_* settings are only needed while some kassa folder has no endpoint
+	usesFTP := cfg.EffectiveExchangeBackend() == models.ExchangeBackendFTP && usesDefaultFTPEndpoint(cfg)
+	if usesFTP && cfg.FTPUser == "" {
 		return fmt.Errorf("FTP_USER is required")
 	}
-	if cfg.FTPPassword == "" {
+	if usesFTP && cfg.FTPPassword == "" && cfg.FTPSFTPKeyFile == "" {
 		return fmt.Errorf("FTP_PASSWORD is required")
 	}
 
@@ -191,6 +338,45 @@ func ValidateConfig(cfg *models.Config) error {
 		return fmt.Errorf("SERVER_PORT must be between 1 and 65535, got %d", cfg.ServerPort)
 	}
 
+	// Validate exchange transport
+	if cfg.FTPProtocol == "" {
+		cfg.FTPProtocol = models.FTPProtocolFTP
+	}
+	if !models.ValidFTPProtocol(cfg.FTPProtocol) {
+		return fmt.Errorf("FTP_PROTOCOL must be one of: ftp, ftps, ftps-implicit, sftp; got %s", cfg.FTPProtocol)
+	}
+	if (cfg.FTPTLSCertFile == "") != (cfg.FTPTLSKeyFile == "") {
+		return fmt.Errorf("FTP_TLS_CERT_FILE and FTP_TLS_KEY_FILE must be set together")
+	}
+	usesSFTP := cfg.FTPProtocol == models.FTPProtocolSFTP
+	for _, transport := range cfg.KassaFTPTransports {
+		usesSFTP = usesSFTP || transport.Protocol == models.FTPProtocolSFTP
+	}
+	if usesFTP && usesSFTP && cfg.FTPSFTPKnownHosts == "" {
+		return fmt.Errorf("FTP_SFTP_KNOWN_HOSTS is required for sftp")
+	}
+
+	// Validate exchange backend
+	switch cfg.EffectiveExchangeBackend() {
+	case models.ExchangeBackendFTP:
+	case models.ExchangeBackendFile:
+		if !strings.HasPrefix(cfg.ExchangeRoot, "/") {
+			return fmt.Errorf("EXCHANGE_URL file:// path must be absolute, got %q", cfg.ExchangeRoot)
+		}
+	case models.ExchangeBackendS3:
+		if cfg.S3Bucket == "" {
+			return fmt.Errorf("EXCHANGE_URL s3:// must name a bucket")
+		}
+		if (cfg.S3AccessKeyID == "") != (cfg.S3SecretAccessKey == "") {
+			return fmt.Errorf("S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY must be set together")
+		}
+	default:
+		return fmt.Errorf("EXCHANGE_URL scheme must be one of: ftp, ftps, ftps-implicit, sftp, file, s3; got %s", cfg.ExchangeBackend)
+	}
+	if cfg.EffectiveExchangeBackend() != models.ExchangeBackendFTP && len(cfg.KassaFTPTransports) > 0 {
+		return fmt.Errorf("KASSA_FTP_PROTOCOLS requires an FTP exchange backend, EXCHANGE_URL is %s://", cfg.ExchangeBackend)
+	}
+
 	// Validate FTP pool size
 	if cfg.FTPPoolSize < 1 {
 		return fmt.Errorf("FTP_POOL_SIZE must be at least 1, got %d", cfg.FTPPoolSize)
@@ -230,6 +416,12 @@ func ValidateConfig(cfg *models.Config) error {
 	if cfg.WaitDelayMinutes < 0 {
 		return fmt.Errorf("WAIT_DELAY_MINUTES must be non-negative, got %v", cfg.WaitDelayMinutes)
 	}
+	if cfg.ResponsePollInterval <= 0 {
+		return fmt.Errorf("RESPONSE_POLL_INTERVAL_SECONDS must be greater than 0, got %v", cfg.ResponsePollInterval)
+	}
+	if cfg.ResponsePollMaxBackoff < cfg.ResponsePollInterval {
+		return fmt.Errorf("RESPONSE_POLL_MAX_BACKOFF_SECONDS must not be less than RESPONSE_POLL_INTERVAL_SECONDS, got %v", cfg.ResponsePollMaxBackoff)
+	}
 	if cfg.WebhookTimeoutMinutes < 0 {
 		return fmt.Errorf("WEBHOOK_TIMEOUT_MINUTES must be non-negative, got %d", cfg.WebhookTimeoutMinutes)
 	}
@@ -254,6 +446,12 @@ func ValidateConfig(cfg *models.Config) error {
 	if cfg.WebhookReportResultWaitTimeout <= 0 {
 		return fmt.Errorf("WEBHOOK_REPORT_RESULT_WAIT_SECONDS must be greater than 0, got %v", cfg.WebhookReportResultWaitTimeout)
 	}
+	if cfg.WebhookReportRetryDelay <= 0 {
+		return fmt.Errorf("WEBHOOK_REPORT_RETRY_DELAY_SECONDS must be greater than 0, got %v", cfg.WebhookReportRetryDelay)
+	}
+	if cfg.WebhookReportMaxAttempts < 1 {
+		return fmt.Errorf("WEBHOOK_REPORT_MAX_ATTEMPTS must be at least 1, got %d", cfg.WebhookReportMaxAttempts)
+	}
 	if cfg.HTTPReadHeaderTimeout <= 0 {
 		return fmt.Errorf("HTTP_READ_HEADER_TIMEOUT_SECONDS must be greater than 0, got %v", cfg.HTTPReadHeaderTimeout)
 	}
@@ -269,6 +467,20 @@ func ValidateConfig(cfg *models.Config) error {
 	if cfg.ShutdownTimeout <= 0 {
 		return fmt.Errorf("SHUTDOWN_TIMEOUT_SECONDS must be greater than 0, got %v", cfg.ShutdownTimeout)
 	}
+	if cfg.QueuePollInterval <= 0 {
+		return fmt.Errorf("QUEUE_POLL_INTERVAL_SECONDS must be greater than 0, got %v", cfg.QueuePollInterval)
+	}
+	if cfg.QueueLeaseTimeout <= 0 {
+		return fmt.Errorf("QUEUE_LEASE_TIMEOUT_SECONDS must be greater than 0, got %v", cfg.QueueLeaseTimeout)
+	}
+
+	// Validate durable queue workers
+	if cfg.QueueWorkers < 1 {
+		return fmt.Errorf("QUEUE_WORKERS must be at least 1, got %d", cfg.QueueWorkers)
+	}
+	if cfg.QueueWorkers > 50 {
+		return fmt.Errorf("QUEUE_WORKERS too large (max 50), got %d", cfg.QueueWorkers)
+	}
 
 	// Validate kassa structure is not empty
 	if len(cfg.KassaStructure) == 0 {
@@ -285,6 +497,11 @@ func ValidateConfig(cfg *models.Config) error {
 		}
 	}
 
+	// Validate FTP endpoints
+	if err := validateFTPEndpoints(cfg); err != nil {
+		return err
+	}
+
 	// Validate log level
 	validLogLevels := map[string]bool{
 		"debug": true,
@@ -319,6 +536,81 @@ func ValidateConfig(cfg *models.Config) error {
 		return fmt.Errorf("LOG_BACKEND must be one of: zerolog, slog; got %s", cfg.LogBackend)
 	}
 
+	parseMode := strings.ToLower(cfg.ParseMode)
+	if parseMode == "" {
+		parseMode = "lenient"
+	}
+	validParseModes := map[string]bool{
+		"lenient": true,
+		"strict":  true,
+	}
+	if !validParseModes[parseMode] {
+		return fmt.Errorf("PARSE_MODE must be one of: lenient, strict; got %s", cfg.ParseMode)
+	}
+	cfg.ParseMode = parseMode
+
+	loadStrategy := strings.ToLower(cfg.LoadStrategy)
+	if loadStrategy == "" {
+		loadStrategy = "batch"
+	}
+	validLoadStrategies := map[string]bool{
+		"batch": true,
+		"copy":  true,
+	}
+	if !validLoadStrategies[loadStrategy] {
+		return fmt.Errorf("LOAD_STRATEGY must be one of: batch, copy; got %s", cfg.LoadStrategy)
+	}
+	cfg.LoadStrategy = loadStrategy
+
+	queueProvider := strings.ToLower(cfg.QueueProvider)
+	if queueProvider == "" {
+		queueProvider = "postgres"
+	}
+	validQueueProviders := map[string]bool{
+		"postgres": true,
+		"memory":   true,
+	}
+	if !validQueueProviders[queueProvider] {
+		return fmt.Errorf("QUEUE_PROVIDER must be one of: postgres, memory; got %s", cfg.QueueProvider)
+	}
+	cfg.QueueProvider = queueProvider
+
+	tracingExporter := strings.ToLower(cfg.TracingExporter)
+	if tracingExporter == "" {
+		tracingExporter = "none"
+	}
+	validTracingExporters := map[string]bool{
+		"none":   true,
+		"otlp":   true,
+		"stdout": true,
+	}
+	if !validTracingExporters[tracingExporter] {
+		return fmt.Errorf("TRACING_EXPORTER must be one of: none, otlp, stdout; got %s", cfg.TracingExporter)
+	}
+	cfg.TracingExporter = tracingExporter
+
+	if cfg.ScheduleTimezone != "" {
+		if _, err := time.LoadLocation(cfg.ScheduleTimezone); err != nil {
+			return fmt.Errorf("SCHEDULE_TIMEZONE must be an IANA time zone, got %s", cfg.ScheduleTimezone)
+		}
+	}
+	if cfg.GapCheckDays < 1 || cfg.GapCheckDays > models.MaxDateRangeDays {
+		return fmt.Errorf("GAP_CHECK_DAYS must be between 1 and %d, got %d", models.MaxDateRangeDays, cfg.GapCheckDays)
+	}
+	if cfg.GapBackfillSchedule != "" {
+		if _, err := scheduler.Parse(cfg.GapBackfillSchedule); err != nil {
+			return fmt.Errorf("invalid GAP_BACKFILL_SCHEDULE: %w", err)
+		}
+	}
+	for kassaCode, timezone := range cfg.KassaTimezones {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return fmt.Errorf("time zone of kassa %s must be an IANA time zone, got %s", kassaCode, timezone)
+		}
+	}
+	if cfg.ConfigReloadInterval < 0 {
+		return fmt.Errorf("CONFIG_RELOAD_INTERVAL_SECONDS must be 0 or greater, got %v", cfg.ConfigReloadInterval)
+	}
+
 	return nil
 }
 
@@ -326,7 +618,7 @@ func ValidateConfig(cfg *models.Config) error {
 func LoadDBConfig() (*models.Config, error) {
 	// Load .env file if it exists
 	_ = godotenv.Load() // .env file is optional, continue with environment variables
-	loader := newEnvLoader()
+	loader := newEnvLoader(nil)
 	dbPort, err := loader.getEnvAsIntStrict("DB_PORT", 5432)
 	if err != nil {
 		return nil, err
@@ -361,14 +653,17 @@ func LoadDBConfig() (*models.Config, error) {
 	return config, nil
 }
 
-// parseKassaStructure parses kassa structure from environment variable
-func parseKassaStructure(kassaStr string) (map[string][]string, error) {
+// parseKassaStructure parses kassa structure from environment variable.
+// A folder may carry an encoding override ("P13@cp1251"), returned keyed by
+// source folder ("<kassa>/<folder>").
+func parseKassaStructure(kassaStr string) (map[string][]string, map[string]string, error) {
 	if kassaStr == "" {
-		return nil, fmt.Errorf("KASSA_STRUCTURE is required")
+		return nil, nil, fmt.Errorf("KASSA_STRUCTURE is required (or kassas in CONFIG_FILE)")
 	}
 
-	// Parse format: "001:folder1,folder2;002:folder1,folder2"
+	// Parse format: "001:folder1,folder2@cp1251;002:folder1,folder2"
 	structure := make(map[string][]string)
+	encodings := make(map[string]string)
 
 	// Split by semicolon to get kassa groups
 	kassaGroups := strings.Split(kassaStr, ";")
@@ -379,18 +674,28 @@ func parseKassaStructure(kassaStr string) (map[string][]string, error) {
 		}
 		parts := strings.Split(group, ":")
 		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid KASSA_STRUCTURE group %q", group)
+			return nil, nil, fmt.Errorf("invalid KASSA_STRUCTURE group %q", group)
 		}
 		kassaCode := strings.TrimSpace(parts[0])
 		if kassaCode == "" {
-			return nil, fmt.Errorf("empty kassa code in KASSA_STRUCTURE")
+			return nil, nil, fmt.Errorf("empty kassa code in KASSA_STRUCTURE")
 		}
 		folders := strings.Split(parts[1], ",")
 		cleanFolders := make([]string, 0, len(folders))
 		for _, folder := range folders {
+			folder, encodingName, hasEncoding := strings.Cut(strings.TrimSpace(folder), "@")
 			folder = strings.TrimSpace(folder)
 			if folder == "" {
-				return nil, fmt.Errorf("empty folder for kassa %s in KASSA_STRUCTURE", kassaCode)
+				return nil, nil, fmt.Errorf("empty folder for kassa %s in KASSA_STRUCTURE", kassaCode)
+			}
+			if hasEncoding {
+				encoding, err := parser.ParseEncoding(encodingName)
+				if err != nil || strings.TrimSpace(encodingName) == "" {
+					return nil, nil, fmt.Errorf("invalid encoding for folder %s/%s in KASSA_STRUCTURE: %q", kassaCode, folder, encodingName)
+				}
+				if encoding != parser.EncodingAuto {
+					encodings[kassaCode+"/"+folder] = string(encoding)
+				}
 			}
 			cleanFolders = append(cleanFolders, folder)
 		}
@@ -398,10 +703,421 @@ func parseKassaStructure(kassaStr string) (map[string][]string, error) {
 	}
 
 	if len(structure) == 0 {
-		return nil, fmt.Errorf("KASSA_STRUCTURE cannot be empty")
+		return nil, nil, fmt.Errorf("KASSA_STRUCTURE cannot be empty")
+	}
+
+	return structure, encodings, nil
+}
+
+// exchangeURL holds the settings given by EXCHANGE_URL
+type exchangeURL struct {
+	backend    string
+	protocol   string // FTP protocol of an ftp backend, empty to keep FTP_PROTOCOL
+	host       string
+	port       int
+	root       string
+	bucket     string
+	endpoint   string
+	region     string
+	disableTLS bool
+}
+
+// parseExchangeURL parses EXCHANGE_URL or the url of an FTP endpoint, named
+// by source in errors. Formats: "ftp://host[:port]" (also
+// ftps, ftps-implicit and sftp) overriding FTP_PROTOCOL, FTP_HOST and FTP_PORT;
+// "file:///path" for a local directory; "s3://bucket[/prefix]" with optional
+// endpoint, region and tls query parameters for S3-compatible storage.
+func parseExchangeURL(source, value string) (exchangeURL, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return exchangeURL{backend: models.ExchangeBackendFTP}, nil
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return exchangeURL{}, fmt.Errorf("invalid %s: %w", source, err)
+	}
+	if u.User != nil {
+		return exchangeURL{}, fmt.Errorf("%s must not contain credentials", source)
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	switch {
+	case models.ValidFTPProtocol(scheme):
+		exchange := exchangeURL{backend: models.ExchangeBackendFTP, protocol: scheme, host: u.Hostname()}
+		if u.Port() != "" {
+			port, err := strconv.Atoi(u.Port())
+			if err != nil || port < 1 || port > 65535 {
+				return exchangeURL{}, fmt.Errorf("invalid port in %s: %q", source, u.Port())
+			}
+			exchange.port = port
+		}
+		if strings.Trim(u.Path, "/") != "" || u.RawQuery != "" {
+			return exchangeURL{}, fmt.Errorf("%s %s:// takes only host and port, use FTP_REQUEST_DIR and FTP_RESPONSE_DIR for folders", source, scheme)
+		}
+		return exchange, nil
+	case scheme == models.ExchangeBackendFile:
+		if (u.Host != "" && u.Host != "localhost") || !strings.HasPrefix(u.Path, "/") {
+			return exchangeURL{}, fmt.Errorf("%s file:// must be an absolute path like file:///mnt/frontol, got %q", source, value)
+		}
+		return exchangeURL{backend: models.ExchangeBackendFile, root: u.Path}, nil
+	case scheme == models.ExchangeBackendS3:
+		if u.Host == "" {
+			return exchangeURL{}, fmt.Errorf("%s s3:// must name a bucket like s3://bucket/prefix", source)
+		}
+		exchange := exchangeURL{
+			backend:  models.ExchangeBackendS3,
+			bucket:   u.Host,
+			root:     strings.Trim(u.Path, "/"),
+			endpoint: models.DefaultS3Endpoint,
+		}
+		for key, values := range u.Query() {
+			param := values[len(values)-1]
+			switch key {
+			case "endpoint":
+				exchange.endpoint = param
+			case "region":
+				exchange.region = param
+			case "tls":
+				useTLS, err := strconv.ParseBool(param)
+				if err != nil {
+					return exchangeURL{}, fmt.Errorf("invalid tls in %s: %q", source, param)
+				}
+				exchange.disableTLS = !useTLS
+			default:
+				return exchangeURL{}, fmt.Errorf("unknown %s parameter %q, want endpoint, region or tls", source, key)
+			}
+		}
+		if exchange.endpoint == "" {
+			return exchangeURL{}, fmt.Errorf("%s endpoint must not be empty", source)
+		}
+		return exchange, nil
+	default:
+		return exchangeURL{}, fmt.Errorf("%s scheme must be one of: ftp, ftps, ftps-implicit, sftp, file, s3; got %q", source, u.Scheme)
+	}
+}
+
+// parseKassaFTPProtocols parses exchange protocols that override
+// FTP_PROTOCOL. Format: "kassa:protocol[:port];kassa/folder:protocol[:port]".
+func parseKassaFTPProtocols(value string) (map[string]models.FTPTransport, error) {
+	transports := make(map[string]models.FTPTransport)
+	for _, group := range strings.Split(value, ";") {
+		group = strings.TrimSpace(group)
+		if group == "" {
+			continue
+		}
+		parts := strings.Split(group, ":")
+		key := strings.TrimSpace(parts[0])
+		if len(parts) < 2 || len(parts) > 3 || key == "" || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") {
+			return nil, fmt.Errorf("invalid KASSA_FTP_PROTOCOLS entry %q, want kassa[/folder]:protocol[:port]", group)
+		}
+		transport := models.FTPTransport{Protocol: strings.ToLower(strings.TrimSpace(parts[1]))}
+		if !models.ValidFTPProtocol(transport.Protocol) {
+			return nil, fmt.Errorf("invalid protocol for %s in KASSA_FTP_PROTOCOLS: %q", key, parts[1])
+		}
+		if len(parts) == 3 {
+			port, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+			if err != nil || port < 1 || port > 65535 {
+				return nil, fmt.Errorf("invalid port for %s in KASSA_FTP_PROTOCOLS: %q", key, parts[2])
+			}
+			transport.Port = port
+		}
+		if _, duplicate := transports[key]; duplicate {
+			return nil, fmt.Errorf("duplicate KASSA_FTP_PROTOCOLS entry for %s", key)
+		}
+		transports[key] = transport
+	}
+	return transports, nil
+}
+
+// parseKassaResponseDeadlines parses response deadlines that override
+// WAIT_DELAY_MINUTES. Format: "kassa:minutes;kassa/folder:minutes" — a kassa
+// code applies to all its folders, a source folder only to itself.
+func parseKassaResponseDeadlines(value string) (map[string]time.Duration, error) {
+	deadlines := make(map[string]time.Duration)
+	for _, group := range strings.Split(value, ";") {
+		group = strings.TrimSpace(group)
+		if group == "" {
+			continue
+		}
+		key, minutesText, ok := strings.Cut(group, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") {
+			return nil, fmt.Errorf("invalid KASSA_RESPONSE_DEADLINES entry %q, want kassa[/folder]:minutes", group)
+		}
+		minutes, err := strconv.Atoi(strings.TrimSpace(minutesText))
+		if err != nil || minutes < 0 {
+			return nil, fmt.Errorf("invalid response deadline for %s in KASSA_RESPONSE_DEADLINES: %q", key, minutesText)
+		}
+		if _, duplicate := deadlines[key]; duplicate {
+			return nil, fmt.Errorf("duplicate KASSA_RESPONSE_DEADLINES entry for %s", key)
+		}
+		deadlines[key] = time.Duration(minutes) * time.Minute
+	}
+	return deadlines, nil
+}
+
+// parseSchedules parses the recurring loads of the built-in scheduler.
+// Format: "name:cron:days[:kassa1,kassa2];..." — every firing of cron loads
+// the days days before the firing day, for the listed kassas or all of them.
+func parseSchedules(value string) ([]models.Schedule, error) {
+	var schedules []models.Schedule
+	names := make(map[string]bool)
+	for _, group := range strings.Split(value, ";") {
+		group = strings.TrimSpace(group)
+		if group == "" {
+			continue
+		}
+		parts := strings.Split(group, ":")
+		if len(parts) < 3 || len(parts) > 4 {
+			return nil, fmt.Errorf("invalid SCHEDULES entry %q, want name:cron:days[:kassas]", group)
+		}
+		name := strings.TrimSpace(parts[0])
+		if !validScheduleName(name) {
+			return nil, fmt.Errorf("invalid schedule name %q in SCHEDULES: use letters, digits, '-' and '_'", name)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicate schedule name %q in SCHEDULES", name)
+		}
+		names[name] = true
+
+		spec := strings.TrimSpace(parts[1])
+		if _, err := scheduler.Parse(spec); err != nil {
+			return nil, fmt.Errorf("invalid cron for schedule %s in SCHEDULES: %w", name, err)
+		}
+		days, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+		if err != nil || days < 1 || days > models.MaxDateRangeDays {
+			return nil, fmt.Errorf("days for schedule %s in SCHEDULES must be between 1 and %d, got %q", name, models.MaxDateRangeDays, parts[2])
+		}
+		var kassas []string
+		if len(parts) == 4 {
+			for _, kassa := range strings.Split(parts[3], ",") {
+				if kassa = strings.TrimSpace(kassa); kassa != "" {
+					kassas = append(kassas, kassa)
+				}
+			}
+		}
+		schedules = append(schedules, models.Schedule{Name: name, Cron: spec, Days: days, Kassas: kassas})
+	}
+	return schedules, nil
+}
+
+// loadWebhookSubscribers reads the JSON array of webhook report subscribers
+// from path; an empty path means no subscribers. ${VAR} references in url,
+// auth_header and signing_secret are expanded from the environment, so
+// secrets can stay out of the file.
+func loadWebhookSubscribers(path string) ([]models.WebhookSubscriber, error) {
+	if path == "" {
+		return nil, nil
+	}
+	// #nosec G304 -- path is operator-configured via environment.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read WEBHOOK_SUBSCRIBERS_FILE: %w", err)
+	}
+	return parseWebhookSubscribers(data)
+}
+
+// parseWebhookSubscribers decodes and validates webhook report subscribers.
+func parseWebhookSubscribers(data []byte) ([]models.WebhookSubscriber, error) {
+	return decodeWebhookSubscribers("WEBHOOK_SUBSCRIBERS_FILE", data)
+}
+
+// decodeWebhookSubscribers decodes the JSON subscribers of source, which
+// names them in errors.
+func decodeWebhookSubscribers(source string, data []byte) ([]models.WebhookSubscriber, error) {
+	var subscribers []models.WebhookSubscriber
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&subscribers); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", source, err)
+	}
+	names := make(map[string]bool, len(subscribers))
+	for i := range subscribers {
+		sub := &subscribers[i]
+		sub.Name = strings.TrimSpace(sub.Name)
+		if !validScheduleName(sub.Name) {
+			return nil, fmt.Errorf("invalid subscriber name %q in %s: use letters, digits, '-' and '_'", sub.Name, source)
+		}
+		if sub.Name == models.DefaultWebhookSubscriber {
+			return nil, fmt.Errorf("subscriber name %q in %s is reserved for WEBHOOK_REPORT_URL", sub.Name, source)
+		}
+		if names[sub.Name] {
+			return nil, fmt.Errorf("duplicate subscriber name %q in %s", sub.Name, source)
+		}
+		names[sub.Name] = true
+
+		sub.URL = os.ExpandEnv(sub.URL)
+		sub.AuthHeader = os.ExpandEnv(sub.AuthHeader)
+		sub.SigningSecret = os.ExpandEnv(sub.SigningSecret)
+		if _, err := webhook.Compile(*sub); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", source, err)
+		}
+	}
+	return subscribers, nil
+}
+
+func loadFTPEndpoints(path string) ([]models.FTPEndpoint, error) {
+	if path == "" {
+		return nil, nil
+	}
+	// #nosec G304 -- path is operator-configured via environment.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read FTP_ENDPOINTS_FILE: %w", err)
+	}
+	return parseFTPEndpoints(data)
+}
+
+// parseFTPEndpoints decodes the exchange servers of kassa groups and parses
+// their URLs. Kassas are checked against KASSA_STRUCTURE by ValidateConfig.
+func parseFTPEndpoints(data []byte) ([]models.FTPEndpoint, error) {
+	return decodeFTPEndpoints("FTP_ENDPOINTS_FILE", data, true)
+}
+
+// decodeFTPEndpoints decodes the JSON endpoints of source, which names them
+// in errors. Without requireKassas an endpoint may list no kassas, for kassas
+// that name their endpoint themselves.
+func decodeFTPEndpoints(source string, data []byte, requireKassas bool) ([]models.FTPEndpoint, error) {
+	var endpoints []models.FTPEndpoint
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&endpoints); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", source, err)
+	}
+	names := make(map[string]bool, len(endpoints))
+	assigned := make(map[string]string)
+	for i := range endpoints {
+		endpoint := &endpoints[i]
+		endpoint.Name = strings.TrimSpace(endpoint.Name)
+		if !validScheduleName(endpoint.Name) {
+			return nil, fmt.Errorf("invalid endpoint name %q in %s: use letters, digits, '-' and '_'", endpoint.Name, source)
+		}
+		if endpoint.Name == models.DefaultFTPEndpoint {
+			return nil, fmt.Errorf("endpoint name %q in %s is reserved for FTP_HOST and EXCHANGE_URL", endpoint.Name, source)
+		}
+		if names[endpoint.Name] {
+			return nil, fmt.Errorf("duplicate endpoint name %q in %s", endpoint.Name, source)
+		}
+		names[endpoint.Name] = true
+
+		endpoint.URL = strings.TrimSpace(os.ExpandEnv(endpoint.URL))
+		if endpoint.URL == "" {
+			return nil, fmt.Errorf("endpoint %s in %s has no url", endpoint.Name, source)
+		}
+		exchange, err := parseExchangeURL(fmt.Sprintf("url of endpoint %s in %s", endpoint.Name, source), endpoint.URL)
+		if err != nil {
+			return nil, err
+		}
+		endpoint.Backend = exchange.backend
+		endpoint.Protocol = exchange.protocol
+		endpoint.Host = exchange.host
+		endpoint.Port = exchange.port
+		endpoint.Root = exchange.root
+		endpoint.S3Bucket = exchange.bucket
+		endpoint.S3Endpoint = exchange.endpoint
+		endpoint.S3Region = exchange.region
+		endpoint.S3DisableTLS = exchange.disableTLS
+		if endpoint.Protocol != "" && endpoint.Host == "" {
+			return nil, fmt.Errorf("url of endpoint %s in %s must name a host", endpoint.Name, source)
+		}
+
+		endpoint.User = os.ExpandEnv(endpoint.User)
+		endpoint.Password = os.ExpandEnv(endpoint.Password)
+		endpoint.S3AccessKeyID = os.ExpandEnv(endpoint.S3AccessKeyID)
+		endpoint.S3SecretAccessKey = os.ExpandEnv(endpoint.S3SecretAccessKey)
+
+		if requireKassas && len(endpoint.Kassas) == 0 {
+			return nil, fmt.Errorf("endpoint %s in %s has no kassas", endpoint.Name, source)
+		}
+		for j, kassa := range endpoint.Kassas {
+			kassa = strings.TrimSpace(kassa)
+			endpoint.Kassas[j] = kassa
+			if previous, ok := assigned[kassa]; ok {
+				return nil, fmt.Errorf("kassa %s is assigned to endpoints %s and %s in %s", kassa, previous, endpoint.Name, source)
+			}
+			assigned[kassa] = endpoint.Name
+		}
 	}
+	return endpoints, nil
+}
 
-	return structure, nil
+// usesDefaultFTPEndpoint reports whether some kassa folder is exchanged
+// through FTP_HOST or EXCHANGE_URL rather than an FTP endpoint.
+func usesDefaultFTPEndpoint(cfg *models.Config) bool {
+	if len(cfg.FTPEndpoints) == 0 {
+		return true
+	}
+	for kassaCode, folders := range cfg.KassaStructure {
+		for _, folderName := range folders {
+			if _, ok := cfg.FTPEndpointFor(kassaCode, folderName); !ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateFTPEndpoints checks that endpoint kassas exist, do not also have
+// a KASSA_FTP_PROTOCOLS override, and that every endpoint has the
+// credentials its backend needs once merged with the global settings.
+func validateFTPEndpoints(cfg *models.Config) error {
+	for _, endpoint := range cfg.FTPEndpoints {
+		for _, kassa := range endpoint.Kassas {
+			kassaCode, folderName, hasFolder := strings.Cut(kassa, "/")
+			folders, ok := cfg.KassaStructure[kassaCode]
+			if ok && hasFolder {
+				ok = slices.Contains(folders, folderName)
+			}
+			if !ok {
+				return fmt.Errorf("FTP endpoint %s lists kassa %s missing from KASSA_STRUCTURE", endpoint.Name, kassa)
+			}
+		}
+
+		scoped := cfg.ForFTPEndpoint(endpoint)
+		switch scoped.EffectiveExchangeBackend() {
+		case models.ExchangeBackendFTP:
+			if scoped.FTPUser == "" {
+				return fmt.Errorf("FTP endpoint %s needs user or FTP_USER", endpoint.Name)
+			}
+			if scoped.FTPPassword == "" && scoped.FTPSFTPKeyFile == "" {
+				return fmt.Errorf("FTP endpoint %s needs password or FTP_PASSWORD", endpoint.Name)
+			}
+			if scoped.FTPProtocol == models.FTPProtocolSFTP && scoped.FTPSFTPKnownHosts == "" {
+				return fmt.Errorf("FTP endpoint %s needs sftp_known_hosts or FTP_SFTP_KNOWN_HOSTS for sftp", endpoint.Name)
+			}
+		case models.ExchangeBackendS3:
+			if (scoped.S3AccessKeyID == "") != (scoped.S3SecretAccessKey == "") {
+				return fmt.Errorf("FTP endpoint %s needs both S3 access key and secret", endpoint.Name)
+			}
+		}
+	}
+
+	for kassaCode, folders := range cfg.KassaStructure {
+		for _, folderName := range folders {
+			endpoint, ok := cfg.FTPEndpointFor(kassaCode, folderName)
+			if !ok {
+				continue
+			}
+			for _, key := range []string{kassaCode + "/" + folderName, kassaCode} {
+				if _, overridden := cfg.KassaFTPTransports[key]; overridden {
+					return fmt.Errorf("KASSA_FTP_PROTOCOLS entry %s conflicts with FTP endpoint %s, set the protocol in the endpoint url", key, endpoint.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func validScheduleName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
 }
 
 // getEnv gets environment variable with default value
@@ -422,24 +1138,39 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
-type envLoader struct{}
+// envLoader reads settings from the environment, falling back to the
+// settings of CONFIG_FILE keyed by the same variable names
+type envLoader struct {
+	file map[string]string
+}
+
+func newEnvLoader(file map[string]string) envLoader {
+	return envLoader{file: file}
+}
 
-func newEnvLoader() envLoader {
-	return envLoader{}
+// lookup returns the value of key and the source it came from
+func (l envLoader) lookup(key string) (value, source string) {
+	if value := os.Getenv(key); value != "" {
+		return value, key
+	}
+	return l.file[key], fileSettingName(key) + " in CONFIG_FILE"
 }
 
-func (envLoader) getEnv(key, defaultValue string) string {
-	return getEnv(key, defaultValue)
+func (l envLoader) getEnv(key, defaultValue string) string {
+	if value, _ := l.lookup(key); value != "" {
+		return value
+	}
+	return defaultValue
 }
 
-func (envLoader) getEnvAsIntStrict(key string, defaultValue int) (int, error) {
-	value := os.Getenv(key)
+func (l envLoader) getEnvAsIntStrict(key string, defaultValue int) (int, error) {
+	value, source := l.lookup(key)
 	if value == "" {
 		return defaultValue, nil
 	}
 	parsed, err := strconv.Atoi(value)
 	if err != nil {
-		return 0, fmt.Errorf("%s must be a valid integer, got %q", key, value)
+		return 0, fmt.Errorf("%s must be a valid integer, got %q", source, value)
 	}
 	return parsed, nil
 }
@@ -460,7 +1191,7 @@ type FTPConfig struct {
 func LoadFTPConfig() (*FTPConfig, error) {
 	// Load .env file if it exists
 	_ = godotenv.Load() // .env file is optional, continue with environment variables
-	loader := newEnvLoader()
+	loader := newEnvLoader(nil)
 
 	ftpUser := loader.getEnv("FTP_USER", "frontol")
 
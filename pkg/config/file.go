@@ -0,0 +1,300 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/parser"
+	"gopkg.in/yaml.v3"
+)
+
+// fileSettings are the pipeline settings of CONFIG_FILE, named by the
+// environment variable that overrides them; true marks integers. In the file
+// a setting is the lower-case variable name, e.g. batch_size.
+var fileSettings = map[string]bool{
+	"LOCAL_DIR":                         false,
+	"BATCH_SIZE":                        true,
+	"WORKER_POOL_SIZE":                  true,
+	"FTP_POOL_SIZE":                     true,
+	"FTP_CONNECT_TIMEOUT_SECONDS":       true,
+	"MAX_RETRIES":                       true,
+	"RETRY_DELAY_SECONDS":               true,
+	"WAIT_DELAY_MINUTES":                true,
+	"RESPONSE_POLL_INTERVAL_SECONDS":    true,
+	"RESPONSE_POLL_MAX_BACKOFF_SECONDS": true,
+	"KASSA_RESPONSE_DEADLINES":          false,
+	"PIPELINE_LOAD_TIMEOUT_MINUTES":     true,
+	"PARSE_MODE":                        false,
+	"LOAD_STRATEGY":                     false,
+}
+
+func fileSettingName(key string) string {
+	return "pipeline." + strings.ToLower(key)
+}
+
+// configDocument is the layout of CONFIG_FILE. Endpoints and subscribers
+// keep the JSON format of FTP_ENDPOINTS_FILE and WEBHOOK_SUBSCRIBERS_FILE.
+type configDocument struct {
+	Pipeline           yaml.Node   `yaml:"pipeline"`
+	Kassas             []fileKassa `yaml:"kassas"`
+	FTPEndpoints       yaml.Node   `yaml:"ftp_endpoints"`
+	WebhookSubscribers yaml.Node   `yaml:"webhook_subscribers"`
+}
+
+// fileKassa is a kassa definition of CONFIG_FILE
+type fileKassa struct {
+	Code     string   `yaml:"code"`
+	Folders  []string `yaml:"folders"`
+	Encoding string   `yaml:"encoding"` // encoding of all folders, as @encoding in KASSA_STRUCTURE
+	Endpoint string   `yaml:"endpoint"` // FTP endpoint of the kassa; empty for FTP_HOST or EXCHANGE_URL
+	Timezone string   `yaml:"timezone"`
+	Enabled  *bool    `yaml:"enabled"` // false keeps the definition without loading the kassa (default: true)
+}
+
+// kassaEndpoint is the endpoint a kassa of CONFIG_FILE names
+type kassaEndpoint struct {
+	kassaCode string
+	endpoint  string
+	line      int
+}
+
+// fileConfig holds the settings read from CONFIG_FILE
+type fileConfig struct {
+	path               string
+	kassaCodes         map[string]bool   // codes of all kassa definitions, disabled ones too
+	settings           map[string]string // pipeline settings by environment variable
+	kassaStructure     map[string][]string
+	kassaEncodings     map[string]string
+	kassaTimezones     map[string]string
+	kassaEndpoints     []kassaEndpoint
+	ftpEndpoints       []models.FTPEndpoint
+	webhookSubscribers []models.WebhookSubscriber
+}
+
+// loadConfigFile reads CONFIG_FILE from path; an empty path means no file.
+// YAML and JSON are both accepted, as JSON is valid YAML.
+func loadConfigFile(path string) (*fileConfig, error) {
+	if path == "" {
+		return &fileConfig{}, nil
+	}
+	// #nosec G304 -- path is operator-configured via environment.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read CONFIG_FILE: %w", err)
+	}
+	return parseConfigFile(path, data)
+}
+
+// parseConfigFile decodes and validates the config file at path. Errors
+// name the line of the offending setting.
+func parseConfigFile(path string, data []byte) (*fileConfig, error) {
+	var doc configDocument
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid CONFIG_FILE %s: %w", path, err)
+	}
+	// Lines of the kassa definitions, which doc does not keep
+	var lines struct {
+		Kassas []yaml.Node `yaml:"kassas"`
+	}
+	_ = yaml.Unmarshal(data, &lines)
+
+	file := &fileConfig{path: path, kassaCodes: make(map[string]bool), settings: make(map[string]string)}
+	if err := file.parsePipeline(&doc.Pipeline); err != nil {
+		return nil, err
+	}
+	for i, kassa := range doc.Kassas {
+		line := 0
+		if i < len(lines.Kassas) {
+			line = lines.Kassas[i].Line
+		}
+		if err := file.addKassa(kassa, line); err != nil {
+			return nil, err
+		}
+	}
+	if len(doc.Kassas) > 0 && len(file.kassaStructure) == 0 {
+		return nil, fmt.Errorf("invalid CONFIG_FILE %s: no enabled kassas", path)
+	}
+
+	if doc.FTPEndpoints.Kind != 0 {
+		data, err := file.sectionJSON(&doc.FTPEndpoints)
+		if err == nil {
+			file.ftpEndpoints, err = decodeFTPEndpoints("ftp_endpoints", data, false)
+		}
+		if err != nil {
+			return nil, file.errorf(doc.FTPEndpoints.Line, "%v", err)
+		}
+	}
+	if doc.WebhookSubscribers.Kind != 0 {
+		data, err := file.sectionJSON(&doc.WebhookSubscribers)
+		if err == nil {
+			file.webhookSubscribers, err = decodeWebhookSubscribers("webhook_subscribers", data)
+		}
+		if err != nil {
+			return nil, file.errorf(doc.WebhookSubscribers.Line, "%v", err)
+		}
+	}
+	return file, nil
+}
+
+func (f *fileConfig) errorf(line int, format string, args ...any) error {
+	return fmt.Errorf("invalid CONFIG_FILE %s, line %d: %s", f.path, line, fmt.Sprintf(format, args...))
+}
+
+// parsePipeline reads the pipeline section: a mapping of fileSettings
+func (f *fileConfig) parsePipeline(node *yaml.Node) error {
+	if node.Kind == 0 {
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return f.errorf(node.Line, "pipeline must be a mapping of settings")
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		name := strings.ToUpper(key.Value)
+		isInt, ok := fileSettings[name]
+		if !ok || key.Value != strings.ToLower(key.Value) {
+			return f.errorf(key.Line, "unknown pipeline setting %q", key.Value)
+		}
+		if value.Kind != yaml.ScalarNode {
+			return f.errorf(value.Line, "%s must be a single value", fileSettingName(name))
+		}
+		if isInt {
+			var n int
+			if err := value.Decode(&n); err != nil {
+				return f.errorf(value.Line, "%s must be an integer, got %q", fileSettingName(name), value.Value)
+			}
+		}
+		f.settings[name] = value.Value
+	}
+	return nil
+}
+
+// addKassa checks a kassa definition and, unless it is disabled, adds it to
+// the kassa structure
+func (f *fileConfig) addKassa(kassa fileKassa, line int) error {
+	code := strings.TrimSpace(kassa.Code)
+	if code == "" {
+		return f.errorf(line, "kassa without code")
+	}
+	if strings.ContainsAny(code, "/:;,@") {
+		return f.errorf(line, "invalid kassa code %q", code)
+	}
+	if f.kassaCodes[code] {
+		return f.errorf(line, "duplicate kassa %s", code)
+	}
+	f.kassaCodes[code] = true
+	if len(kassa.Folders) == 0 {
+		return f.errorf(line, "kassa %s has no folders", code)
+	}
+	folders := make([]string, 0, len(kassa.Folders))
+	for _, folder := range kassa.Folders {
+		folder = strings.TrimSpace(folder)
+		if folder == "" || strings.ContainsAny(folder, "/:;,@") {
+			return f.errorf(line, "invalid folder %q of kassa %s", folder, code)
+		}
+		folders = append(folders, folder)
+	}
+	encoding := parser.EncodingAuto
+	if kassa.Encoding != "" {
+		var err error
+		if encoding, err = parser.ParseEncoding(kassa.Encoding); err != nil {
+			return f.errorf(line, "invalid encoding %q of kassa %s", kassa.Encoding, code)
+		}
+	}
+	timezone := strings.TrimSpace(kassa.Timezone)
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return f.errorf(line, "time zone of kassa %s must be an IANA time zone, got %s", code, timezone)
+		}
+	}
+	if kassa.Enabled != nil && !*kassa.Enabled {
+		return nil
+	}
+
+	if f.kassaStructure == nil {
+		f.kassaStructure = make(map[string][]string)
+		f.kassaEncodings = make(map[string]string)
+		f.kassaTimezones = make(map[string]string)
+	}
+	f.kassaStructure[code] = folders
+	if encoding != parser.EncodingAuto {
+		for _, folder := range folders {
+			f.kassaEncodings[code+"/"+folder] = string(encoding)
+		}
+	}
+	if timezone != "" {
+		f.kassaTimezones[code] = timezone
+	}
+	if endpoint := strings.TrimSpace(kassa.Endpoint); endpoint != "" {
+		f.kassaEndpoints = append(f.kassaEndpoints, kassaEndpoint{kassaCode: code, endpoint: endpoint, line: line})
+	}
+	return nil
+}
+
+// sectionJSON converts a section of the file to JSON for the decoders of
+// FTP_ENDPOINTS_FILE and WEBHOOK_SUBSCRIBERS_FILE
+func (f *fileConfig) sectionJSON(node *yaml.Node) ([]byte, error) {
+	var value any
+	if err := node.Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// assignEndpoints adds the kassas that name an endpoint to the kassas of
+// that endpoint in endpoints, which come from ftp_endpoints or
+// FTP_ENDPOINTS_FILE.
+func (f *fileConfig) assignEndpoints(endpoints []models.FTPEndpoint) ([]models.FTPEndpoint, error) {
+	if len(f.kassaEndpoints) == 0 {
+		return endpoints, nil
+	}
+	assigned := make(map[string]string)
+	index := make(map[string]int, len(endpoints))
+	for i, endpoint := range endpoints {
+		index[endpoint.Name] = i
+		for _, kassa := range endpoint.Kassas {
+			kassaCode, _, _ := strings.Cut(kassa, "/")
+			assigned[kassaCode] = endpoint.Name
+		}
+	}
+
+	result := make([]models.FTPEndpoint, len(endpoints))
+	copy(result, endpoints)
+	for _, ref := range f.kassaEndpoints {
+		i, ok := index[ref.endpoint]
+		if !ok {
+			return nil, f.errorf(ref.line, "kassa %s names unknown endpoint %s, known: %s", ref.kassaCode, ref.endpoint, endpointNames(endpoints))
+		}
+		if previous, ok := assigned[ref.kassaCode]; ok && previous != ref.endpoint {
+			return nil, f.errorf(ref.line, "kassa %s names endpoint %s but is listed by endpoint %s", ref.kassaCode, ref.endpoint, previous)
+		}
+		if _, ok := assigned[ref.kassaCode]; ok {
+			continue
+		}
+		assigned[ref.kassaCode] = ref.endpoint
+		result[i].Kassas = append(append([]string(nil), result[i].Kassas...), ref.kassaCode)
+	}
+	return result, nil
+}
+
+func endpointNames(endpoints []models.FTPEndpoint) string {
+	if len(endpoints) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		names = append(names, endpoint.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfigFile = `
+pipeline:
+  batch_size: 500
+  parse_mode: strict
+  kassa_response_deadlines: "P13:30"
+
+kassas:
+  - code: P13
+    folders: [P13, P13_INTER]
+    encoding: cp1251
+    timezone: Asia/Yekaterinburg
+  - code: L32
+    folders: [L32]
+    endpoint: north
+  - code: N45
+    folders: [N45]
+    enabled: false
+
+ftp_endpoints:
+  - name: north
+    url: sftp://ftp-north.example.com
+    user: frontol
+    password: ${FTP_NORTH_PASSWORD}
+    sftp_known_hosts: /etc/frontol/known_hosts
+
+webhook_subscribers:
+  - name: bi
+    url: https://bi.example.com/reports
+    kassas: [L32]
+`
+
+func TestParseConfigFile(t *testing.T) {
+	t.Setenv("FTP_NORTH_PASSWORD", "north-secret")
+	file, err := parseConfigFile("frontol.yaml", []byte(testConfigFile))
+	if err != nil {
+		t.Fatalf("parseConfigFile() unexpected error: %v", err)
+	}
+
+	wantStructure := map[string][]string{"P13": {"P13", "P13_INTER"}, "L32": {"L32"}}
+	if !reflect.DeepEqual(file.kassaStructure, wantStructure) {
+		t.Errorf("kassaStructure = %v, want %v", file.kassaStructure, wantStructure)
+	}
+	wantEncodings := map[string]string{"P13/P13": "cp1251", "P13/P13_INTER": "cp1251"}
+	if !reflect.DeepEqual(file.kassaEncodings, wantEncodings) {
+		t.Errorf("kassaEncodings = %v, want %v", file.kassaEncodings, wantEncodings)
+	}
+	if file.kassaTimezones["P13"] != "Asia/Yekaterinburg" {
+		t.Errorf("kassaTimezones = %v", file.kassaTimezones)
+	}
+	wantSettings := map[string]string{"BATCH_SIZE": "500", "PARSE_MODE": "strict", "KASSA_RESPONSE_DEADLINES": "P13:30"}
+	if !reflect.DeepEqual(file.settings, wantSettings) {
+		t.Errorf("settings = %v, want %v", file.settings, wantSettings)
+	}
+	if len(file.ftpEndpoints) != 1 || file.ftpEndpoints[0].Password != "north-secret" || file.ftpEndpoints[0].Host != "ftp-north.example.com" {
+		t.Errorf("ftpEndpoints = %+v", file.ftpEndpoints)
+	}
+	if len(file.webhookSubscribers) != 1 || file.webhookSubscribers[0].Name != "bi" {
+		t.Errorf("webhookSubscribers = %+v", file.webhookSubscribers)
+	}
+
+	endpoints, err := file.assignEndpoints(file.ftpEndpoints)
+	if err != nil {
+		t.Fatalf("assignEndpoints() unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(endpoints[0].Kassas, []string{"L32"}) || len(file.ftpEndpoints[0].Kassas) != 0 {
+		t.Errorf("assignEndpoints() kassas = %v, file endpoint kassas = %v", endpoints[0].Kassas, file.ftpEndpoints[0].Kassas)
+	}
+
+	json := `{"kassas": [{"code": "P13", "folders": ["P13"]}], "pipeline": {"worker_pool_size": 4}}`
+	file, err = parseConfigFile("frontol.json", []byte(json))
+	if err != nil {
+		t.Fatalf("parseConfigFile(json) unexpected error: %v", err)
+	}
+	if file.settings["WORKER_POOL_SIZE"] != "4" || len(file.kassaStructure["P13"]) != 1 {
+		t.Errorf("parseConfigFile(json) = %+v", file)
+	}
+}
+
+func TestParseConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errSub  string
+	}{
+		{"unknown section", "pipline:\n  batch_size: 1\n", "line 1: field pipline not found"},
+		{"unknown kassa field", "kassas:\n  - code: P13\n    folder: [P13]\n", "line 3: field folder not found"},
+		{"unknown setting", "pipeline:\n  batch_size: 1\n  db_password: x\n", "line 3: unknown pipeline setting \"db_password\""},
+		{"setting not an integer", "pipeline:\n  batch_size: many\n", "line 2: pipeline.batch_size must be an integer"},
+		{"kassa without code", "kassas:\n  - folders: [P13]\n", "line 2: kassa without code"},
+		{"duplicate kassa", "kassas:\n  - code: P13\n    folders: [P13]\n  - code: P13\n    folders: [P13_INTER]\n", "line 4: duplicate kassa P13"},
+		{"kassa without folders", "kassas:\n  - code: P13\n", "line 2: kassa P13 has no folders"},
+		{"bad folder", "kassas:\n  - code: P13\n    folders: [\"P13/IN\"]\n", "invalid folder \"P13/IN\" of kassa P13"},
+		{"bad encoding", "kassas:\n  - code: P13\n    folders: [P13]\n    encoding: koi8\n", "invalid encoding \"koi8\" of kassa P13"},
+		{"bad time zone", "kassas:\n  - code: P13\n    folders: [P13]\n    timezone: Mars/Olympus\n", "time zone of kassa P13"},
+		{"all disabled", "kassas:\n  - code: P13\n    folders: [P13]\n    enabled: false\n", "no enabled kassas"},
+		{"bad endpoint", "ftp_endpoints:\n  - name: default\n    url: ftp://ftp-north\n", "line 2: endpoint name \"default\" in ftp_endpoints is reserved"},
+		{"bad subscriber", "webhook_subscribers:\n  - name: bi\n    url: https://bi/reports\n    statuses: [done]\n", "invalid webhook_subscribers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseConfigFile("frontol.yaml", []byte(tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.errSub) {
+				t.Fatalf("parseConfigFile() error = %v, want to contain %q", err, tt.errSub)
+			}
+		})
+	}
+}
+
+func TestLoadConfigLayersEnvOverConfigFile(t *testing.T) {
+	t.Setenv("FTP_NORTH_PASSWORD", "north-secret")
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, testConfigFile))
+	t.Setenv("DB_PASSWORD", "pass")
+	t.Setenv("FTP_USER", "user")
+	t.Setenv("FTP_PASSWORD", "pass")
+	t.Setenv("KASSA_STRUCTURE", "")
+	t.Setenv("FTP_ENDPOINTS_FILE", "")
+	t.Setenv("WEBHOOK_SUBSCRIBERS_FILE", "")
+	t.Setenv("BATCH_SIZE", "")
+	t.Setenv("PARSE_MODE", "lenient")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() unexpected error: %v", err)
+	}
+	if cfg.BatchSize != 500 || cfg.ParseMode != "lenient" {
+		t.Errorf("BatchSize = %d, ParseMode = %s; want 500 from the file and lenient from the environment", cfg.BatchSize, cfg.ParseMode)
+	}
+	if cfg.KassaResponseDeadlines["P13"] != 30*time.Minute {
+		t.Errorf("KassaResponseDeadlines = %v", cfg.KassaResponseDeadlines)
+	}
+	if _, ok := cfg.KassaStructure["N45"]; ok || len(cfg.KassaStructure) != 2 {
+		t.Errorf("KassaStructure = %v, want enabled kassas of the file", cfg.KassaStructure)
+	}
+	if endpoint, ok := cfg.FTPEndpointFor("L32", "L32"); !ok || endpoint.Name != "north" {
+		t.Errorf("FTPEndpointFor(L32) = %+v, %v", endpoint, ok)
+	}
+	if cfg.KassaLocation("P13").String() != "Asia/Yekaterinburg" || len(cfg.WebhookSubscribers) != 1 {
+		t.Errorf("KassaTimezones = %v, WebhookSubscribers = %v", cfg.KassaTimezones, cfg.WebhookSubscribers)
+	}
+
+	// KASSA_STRUCTURE replaces the kassas of the file with their endpoints
+	t.Setenv("KASSA_STRUCTURE", "M01:M01")
+	cfg, err = LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() with KASSA_STRUCTURE unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(cfg.KassaStructure, map[string][]string{"M01": {"M01"}}) || len(cfg.KassaTimezones) != 0 {
+		t.Errorf("KassaStructure = %v, KassaTimezones = %v", cfg.KassaStructure, cfg.KassaTimezones)
+	}
+	if len(cfg.FTPEndpoints) != 1 || len(cfg.FTPEndpoints[0].Kassas) != 0 {
+		t.Errorf("FTPEndpoints = %+v, want north without kassas", cfg.FTPEndpoints)
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "frontol.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+// LoadStrategy selects how LoadTxTable writes rows into tx_* tables.
+type LoadStrategy string
+
+const (
+	// LoadStrategyBatch queues one INSERT ... ON CONFLICT per row in a pgx.Batch.
+	LoadStrategyBatch LoadStrategy = "batch"
+	// LoadStrategyCopy copies rows into a temporary staging table and upserts
+	// them with a single INSERT ... SELECT ... ON CONFLICT.
+	LoadStrategyCopy LoadStrategy = "copy"
+)
+
+// stagingRowColumn numbers staged rows so that, like in the batch path, the
+// last of several rows with the same key wins.
+const stagingRowColumn = "etl_stage_row"
+
+// ParseLoadStrategy converts a configured strategy name; empty means batch.
+func ParseLoadStrategy(value string) (LoadStrategy, error) {
+	switch LoadStrategy(strings.ToLower(strings.TrimSpace(value))) {
+	case "", LoadStrategyBatch:
+		return LoadStrategyBatch, nil
+	case LoadStrategyCopy:
+		return LoadStrategyCopy, nil
+	default:
+		return "", fmt.Errorf("unknown load strategy %q: want batch or copy", value)
+	}
+}
+
+// CopyData loads rows through a temporary staging table: CopyFrom into the
+// staging table, then one set-based upsert into tableName. The staging table
+// lives in tx and is dropped on commit or rollback.
+func (p *Pool) CopyData(ctx context.Context, tx pgx.Tx, tableName string, schema []models.TxColumnSpec, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	stagingTable := "etl_stage_" + tableName
+	createSQL, err := buildStagingTableSQL(stagingTable, schema)
+	if err != nil {
+		return fmt.Errorf("failed to build staging table for %s: %w", tableName, err)
+	}
+	if _, err := tx.Exec(ctx, createSQL); err != nil {
+		return fmt.Errorf("failed to create staging table for %s: %w", tableName, err)
+	}
+	// A streamed file loads the same table several times in one transaction
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`TRUNCATE %s`, stagingTable)); err != nil {
+		return fmt.Errorf("failed to truncate staging table for %s: %w", tableName, err)
+	}
+
+	columns := make([]string, len(schema))
+	for i, spec := range schema {
+		columns[i] = spec.Name
+	}
+	copied, err := tx.CopyFrom(ctx, pgx.Identifier{stagingTable}, columns, pgx.CopyFromRows(rows))
+	if err != nil {
+		return fmt.Errorf("failed to copy data into staging table for %s: %w", tableName, err)
+	}
+
+	if _, err := tx.Exec(ctx, buildStagingUpsertSQL(tableName, stagingTable, columns)); err != nil {
+		return fmt.Errorf("failed to upsert staged data into %s: %w", tableName, err)
+	}
+
+	slog.Info("Successfully loaded rows",
+		"table", tableName,
+		"rows", copied,
+		"strategy", LoadStrategyCopy,
+		"event", "db_load_complete",
+	)
+	return nil
+}
+
+// buildStagingTableSQL derives the staging table from the tx schema. Column
+// types only need to hold the Go values of each kind; INSERT ... SELECT casts
+// them to the exact target types.
+func buildStagingTableSQL(stagingTable string, schema []models.TxColumnSpec) (string, error) {
+	definitions := make([]string, 0, len(schema)+1)
+	definitions = append(definitions, fmt.Sprintf(`"%s" BIGSERIAL`, stagingRowColumn))
+	for _, spec := range schema {
+		columnType, err := stagingColumnType(spec.Kind)
+		if err != nil {
+			return "", fmt.Errorf("column %s: %w", spec.Name, err)
+		}
+		definitions = append(definitions, fmt.Sprintf(`"%s" %s`, spec.Name, columnType))
+	}
+	return fmt.Sprintf(`CREATE TEMP TABLE IF NOT EXISTS %s (%s) ON COMMIT DROP`, stagingTable, strings.Join(definitions, ", ")), nil
+}
+
+func stagingColumnType(kind models.TxColumnKind) (string, error) {
+	switch kind {
+	case models.TxColumnString, models.TxColumnSource:
+		return "TEXT", nil
+	case models.TxColumnInt64:
+		return "BIGINT", nil
+	case models.TxColumnFloat64:
+		return "NUMERIC", nil
+	case models.TxColumnDate:
+		return "DATE", nil
+	case models.TxColumnTime:
+		return "TIME", nil
+	default:
+		return "", fmt.Errorf("unsupported column kind %v", kind)
+	}
+}
+
+// buildStagingUpsertSQL mirrors the batch path: same conflict key and update
+// clause. DISTINCT ON keeps the last staged row per key, because one INSERT
+// may not update the same target row twice.
+func buildStagingUpsertSQL(tableName, stagingTable string, columns []string) string {
+	columnList := make([]string, len(columns))
+	updateClause := make([]string, 0, len(columns))
+	for i, col := range columns {
+		columnList[i] = fmt.Sprintf(`"%s"`, col)
+		if col != "transaction_id_unique" && col != "source_folder" {
+			updateClause = append(updateClause, fmt.Sprintf(`"%s" = EXCLUDED."%s"`, col, col))
+		}
+	}
+
+	return fmt.Sprintf(`
+		INSERT INTO %s (%s)
+		SELECT DISTINCT ON ("transaction_id_unique", "source_folder") %s
+		FROM %s
+		ORDER BY "transaction_id_unique", "source_folder", "%s" DESC
+		ON CONFLICT (transaction_id_unique, source_folder)
+		DO UPDATE SET %s
+	`, tableName,
+		strings.Join(columnList, ", "),
+		strings.Join(columnList, ", "),
+		stagingTable,
+		stagingRowColumn,
+		strings.Join(updateClause, ", "))
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+// copyTx records the statements and COPY issued by CopyData.
+type copyTx struct {
+	pgx.Tx
+	execSQL     []string
+	copyTable   pgx.Identifier
+	copyColumns []string
+	copyRows    [][]interface{}
+}
+
+func (tx *copyTx) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	tx.execSQL = append(tx.execSQL, sql)
+	return pgconn.CommandTag{}, nil
+}
+
+func (tx *copyTx) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	tx.copyTable = tableName
+	tx.copyColumns = columnNames
+	for rowSrc.Next() {
+		values, err := rowSrc.Values()
+		if err != nil {
+			return 0, err
+		}
+		tx.copyRows = append(tx.copyRows, values)
+	}
+	return int64(len(tx.copyRows)), nil
+}
+
+func TestParseLoadStrategy(t *testing.T) {
+	for value, want := range map[string]LoadStrategy{"": LoadStrategyBatch, "batch": LoadStrategyBatch, " COPY ": LoadStrategyCopy} {
+		got, err := ParseLoadStrategy(value)
+		if err != nil || got != want {
+			t.Fatalf("ParseLoadStrategy(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+	if _, err := ParseLoadStrategy("bulk"); err == nil {
+		t.Fatal("ParseLoadStrategy() expected error for unknown strategy")
+	}
+}
+
+func TestBuildStagingTableSQL(t *testing.T) {
+	schema := []models.TxColumnSpec{
+		{Name: "transaction_id_unique", Kind: models.TxColumnInt64},
+		{Name: "source_folder", Kind: models.TxColumnSource},
+		{Name: "transaction_date", Kind: models.TxColumnDate},
+		{Name: "transaction_time", Kind: models.TxColumnTime},
+		{Name: "price", Kind: models.TxColumnFloat64},
+		{Name: "item", Kind: models.TxColumnString},
+	}
+
+	got, err := buildStagingTableSQL("etl_stage_tx_test", schema)
+	if err != nil {
+		t.Fatalf("buildStagingTableSQL() unexpected error: %v", err)
+	}
+	want := `CREATE TEMP TABLE IF NOT EXISTS etl_stage_tx_test ("etl_stage_row" BIGSERIAL, "transaction_id_unique" BIGINT, "source_folder" TEXT, "transaction_date" DATE, "transaction_time" TIME, "price" NUMERIC, "item" TEXT) ON COMMIT DROP`
+	if got != want {
+		t.Fatalf("buildStagingTableSQL() =\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := buildStagingTableSQL("etl_stage_tx_test", []models.TxColumnSpec{{Name: "bad", Kind: models.TxColumnKind(99)}}); err == nil {
+		t.Fatal("buildStagingTableSQL() expected error for unsupported kind")
+	}
+}
+
+func TestBuildStagingUpsertSQLKeepsLastRowPerKey(t *testing.T) {
+	sql := buildStagingUpsertSQL("tx_test", "etl_stage_tx_test", []string{"transaction_id_unique", "source_folder", "item"})
+
+	for _, part := range []string{
+		`INSERT INTO tx_test ("transaction_id_unique", "source_folder", "item")`,
+		`SELECT DISTINCT ON ("transaction_id_unique", "source_folder")`,
+		`ORDER BY "transaction_id_unique", "source_folder", "etl_stage_row" DESC`,
+		`ON CONFLICT (transaction_id_unique, source_folder)`,
+		`DO UPDATE SET "item" = EXCLUDED."item"`,
+	} {
+		if !strings.Contains(sql, part) {
+			t.Fatalf("buildStagingUpsertSQL() = %s\nmissing %s", sql, part)
+		}
+	}
+	if strings.Contains(sql, `"source_folder" = EXCLUDED`) {
+		t.Fatalf("buildStagingUpsertSQL() updates a conflict key column: %s", sql)
+	}
+}
+
+func TestLoadTxTableUsesCopyStrategy(t *testing.T) {
+	tx := &copyTx{}
+	pool := &Pool{loadStrategy: LoadStrategyCopy}
+	rows := []models.TxItemRegistration1_11{
+		{TransactionIDUnique: 1, SourceFolder: "P13/P13", TransactionDate: time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC), ItemIdentifier: "Привет"},
+		{TransactionIDUnique: 2, SourceFolder: "P13/P13", TransactionDate: time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	if err := pool.LoadTxTable(context.Background(), tx, "tx_item_registration_1_11", rows); err != nil {
+		t.Fatalf("LoadTxTable() unexpected error: %v", err)
+	}
+
+	if len(tx.execSQL) != 3 {
+		t.Fatalf("Exec() = %d statements, want create, truncate and upsert", len(tx.execSQL))
+	}
+	if !strings.HasPrefix(tx.execSQL[0], "CREATE TEMP TABLE IF NOT EXISTS etl_stage_tx_item_registration_1_11") ||
+		tx.execSQL[1] != "TRUNCATE etl_stage_tx_item_registration_1_11" ||
+		!strings.Contains(tx.execSQL[2], "INSERT INTO tx_item_registration_1_11") {
+		t.Fatalf("Exec() = %v", tx.execSQL)
+	}
+	if len(tx.copyTable) != 1 || tx.copyTable[0] != "etl_stage_tx_item_registration_1_11" {
+		t.Fatalf("CopyFrom() table = %v", tx.copyTable)
+	}
+	if len(tx.copyRows) != 2 || len(tx.copyColumns) != len(tx.copyRows[0]) {
+		t.Fatalf("CopyFrom() columns = %d, rows = %v", len(tx.copyColumns), tx.copyRows)
+	}
+	for i, column := range tx.copyColumns {
+		if column == "item_identifier" {
+			if tx.copyRows[0][i] != "Привет" || tx.copyRows[1][i] != nil {
+				t.Fatalf("item_identifier values = %v, %v; want text and NULL for empty", tx.copyRows[0][i], tx.copyRows[1][i])
+			}
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/user/go-frontol-loader/pkg/models"
 )
 
 // DatabasePool defines the interface for database operations
@@ -17,6 +18,7 @@ type DatabasePool interface {
 	// Load methods used by the current schema-driven loader path.
 	LoadData(ctx context.Context, tx pgx.Tx, tableName string, columns []string, rows [][]interface{}) error
 	LoadTxTable(ctx context.Context, tx pgx.Tx, tableName string, data interface{}) error
+	CopyData(ctx context.Context, tx pgx.Tx, tableName string, schema []models.TxColumnSpec, rows [][]interface{}) error
 }
 
 // Ensure Pool implements DatabasePool interface
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/user/go-frontol-loader/pkg/models"
 )
 
 // MockPool is a mock implementation of DatabasePool for testing
@@ -13,6 +14,7 @@ type MockPool struct {
 	QueryRowFunc    func(ctx context.Context, sql string, args ...interface{}) pgx.Row
 	LoadDataFunc    func(ctx context.Context, tx pgx.Tx, tableName string, columns []string, rows [][]interface{}) error
 	LoadTxTableFunc func(ctx context.Context, tx pgx.Tx, tableName string, data interface{}) error
+	CopyDataFunc    func(ctx context.Context, tx pgx.Tx, tableName string, schema []models.TxColumnSpec, rows [][]interface{}) error
 }
 
 func (m *MockPool) Close() {}
@@ -51,3 +53,10 @@ func (m *MockPool) LoadTxTable(ctx context.Context, tx pgx.Tx, tableName string,
 	}
 	return nil
 }
+
+func (m *MockPool) CopyData(ctx context.Context, tx pgx.Tx, tableName string, schema []models.TxColumnSpec, rows [][]interface{}) error {
+	if m.CopyDataFunc != nil {
+		return m.CopyDataFunc(ctx, tx, tableName, schema, rows)
+	}
+	return nil
+}
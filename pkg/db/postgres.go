@@ -11,39 +11,21 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/user/go-frontol-loader/pkg/metrics"
 	"github.com/user/go-frontol-loader/pkg/models"
-	"golang.org/x/text/encoding/charmap"
-	"golang.org/x/text/transform"
+	"github.com/user/go-frontol-loader/pkg/parser"
 )
 
-// convertToUTF8 converts a string from Windows-1251 to UTF-8
-// If conversion fails, returns the original string
-func convertToUTF8(s string) string {
-	if s == "" {
-		return s
-	}
-
-	// Try to convert from Windows-1251 to UTF-8
-	decoder := charmap.Windows1251.NewDecoder()
-	result, _, err := transform.String(decoder, s)
-	if err != nil {
-		// If conversion fails, try to clean invalid UTF-8 bytes
-		// This handles cases where the string is already UTF-8 or mixed encoding
-		return strings.ToValidUTF8(s, "")
-	}
-	return result
-}
-
 // safeValue returns a safe value for database insertion, converting empty strings to nil
-// Also converts string values from Windows-1251 to UTF-8
+// Strings arrive already decoded to UTF-8 by the parser. A zero time is a date or
+// time the parser could not read and is stored as NULL
 func safeValue(value interface{}) interface{} {
 	switch v := value.(type) {
 	case string:
 		if v == "" {
 			return nil
 		}
-		// Convert from Windows-1251 to UTF-8 before inserting into database
-		return convertToUTF8(v)
+		return v
 	case float64:
 		if v == 0.0 {
 			return nil
@@ -59,25 +41,36 @@ func safeValue(value interface{}) interface{} {
 			return nil
 		}
 		return v
+	case time.Time:
+		if v.IsZero() {
+			return nil
+		}
+		return v
 	default:
 		return value
 	}
 }
 
 // safeValueAllowZero behaves like safeValue, but preserves numeric zero values.
+// Zero times are still stored as NULL.
 func safeValueAllowZero(value interface{}) interface{} {
 	switch v := value.(type) {
 	case string:
 		if v == "" {
 			return nil
 		}
-		return convertToUTF8(v)
+		return v
 	case float64:
 		return v
 	case int:
 		return v
 	case int64:
 		return v
+	case time.Time:
+		if v.IsZero() {
+			return nil
+		}
+		return v
 	default:
 		return value
 	}
@@ -86,10 +79,22 @@ func safeValueAllowZero(value interface{}) interface{} {
 // Pool represents the database connection pool
 type Pool struct {
 	*pgxpool.Pool
+	loadStrategy LoadStrategy
 }
 
 // NewPool creates a new database connection pool
 func NewPool(cfg *models.Config) (*Pool, error) {
+	return NewPoolContext(context.Background(), cfg)
+}
+
+// NewPoolContext creates a new database connection pool; ctx bounds the
+// connection check together with DB_CONNECT_TIMEOUT_SECONDS
+func NewPoolContext(ctx context.Context, cfg *models.Config) (*Pool, error) {
+	loadStrategy, err := ParseLoadStrategy(cfg.LoadStrategy)
+	if err != nil {
+		return nil, err
+	}
+
 	// Build connection string
 	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBSSLMode)
@@ -108,21 +113,21 @@ func NewPool(cfg *models.Config) (*Pool, error) {
 	config.MaxConnIdleTime = time.Minute * 30
 
 	// Create pool
-	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create connection pool: %w", err)
 	}
 
 	// Test connection
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.EffectiveDBConnectTimeout())
+	pingCtx, cancel := context.WithTimeout(ctx, cfg.EffectiveDBConnectTimeout())
 	defer cancel()
 
-	if err := pool.Ping(ctx); err != nil {
+	if err := pool.Ping(pingCtx); err != nil {
 		pool.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &Pool{pool}, nil
+	return &Pool{Pool: pool, loadStrategy: loadStrategy}, nil
 }
 
 // Close closes the connection pool
@@ -153,19 +158,6 @@ func (p *Pool) Exec(ctx context.Context, sql string, args ...interface{}) (pgcon
 	return p.Pool.Exec(ctx, sql, args...)
 }
 
-// convertRowValues converts all string values in a row from Windows-1251 to UTF-8
-func convertRowValues(row []interface{}) []interface{} {
-	converted := make([]interface{}, len(row))
-	for i, val := range row {
-		if str, ok := val.(string); ok {
-			converted[i] = convertToUTF8(str)
-		} else {
-			converted[i] = val
-		}
-	}
-	return converted
-}
-
 // LoadData loads data into the database using INSERT ... ON CONFLICT
 // Uses transaction for atomicity - all operations in a transaction are committed or rolled back together
 func (p *Pool) LoadData(ctx context.Context, tx pgx.Tx, tableName string, columns []string, rows [][]interface{}) error {
@@ -204,12 +196,9 @@ func (p *Pool) LoadData(ctx context.Context, tx pgx.Tx, tableName string, column
 		strings.Join(updateClause, ", "))
 
 	// Use batch insert for better performance
-	// Convert all rows to UTF-8 first, then add to batch
 	batch := &pgx.Batch{}
 	for _, row := range rows {
-		// Convert all string values from Windows-1251 to UTF-8
-		convertedRow := convertRowValues(row)
-		batch.Queue(query, convertedRow...)
+		batch.Queue(query, row...)
 	}
 
 	// Execute batch
@@ -237,12 +226,13 @@ func (p *Pool) LoadData(ctx context.Context, tx pgx.Tx, tableName string, column
 	return nil
 }
 
-// LoadTxTable loads data into a tx_* table using schema-based mapping.
+// LoadTxTable loads data into a tx_* table using schema-based mapping, with
+// the load strategy the pool was created with.
 func (p *Pool) LoadTxTable(ctx context.Context, tx pgx.Tx, tableName string, data interface{}) error {
 	if data == nil {
 		return nil
 	}
-	schema, ok := models.TxSchemas[tableName]
+	schema, ok := parser.TableSchema(tableName)
 	if !ok {
 		return fmt.Errorf("unknown tx table: %s", tableName)
 	}
@@ -269,10 +259,22 @@ func (p *Pool) LoadTxTable(ctx context.Context, tx pgx.Tx, tableName string, dat
 		rows[i] = row
 	}
 
-	return p.LoadData(ctx, tx, tableName, columns, rows)
+	started := time.Now()
+	var err error
+	if p.loadStrategy == LoadStrategyCopy {
+		err = p.CopyData(ctx, tx, tableName, schema, rows)
+	} else {
+		err = p.LoadData(ctx, tx, tableName, columns, rows)
+	}
+	metrics.ObserveDBLoad(tableName, len(rows), time.Since(started), err)
+	return err
 }
 
 func buildTxRow(schema []models.TxColumnSpec, value interface{}) ([]interface{}, error) {
+	if record, ok := value.(models.TxRecord); ok {
+		return buildTxRecordRow(schema, record)
+	}
+
 	rv := reflect.ValueOf(value)
 	if rv.Kind() == reflect.Pointer {
 		rv = rv.Elem()
@@ -321,3 +323,19 @@ func buildTxRow(schema []models.TxColumnSpec, value interface{}) ([]interface{},
 
 	return row, nil
 }
+
+// buildTxRecordRow converts a schema-ordered TxRecord into insert values
+func buildTxRecordRow(schema []models.TxColumnSpec, record models.TxRecord) ([]interface{}, error) {
+	if len(record.Values) != len(schema) {
+		return nil, fmt.Errorf("tx record has %d values, schema has %d columns", len(record.Values), len(schema))
+	}
+	row := make([]interface{}, 0, len(schema))
+	for i, spec := range schema {
+		if spec.AllowZero {
+			row = append(row, safeValueAllowZero(record.Values[i]))
+		} else {
+			row = append(row, safeValue(record.Values[i]))
+		}
+	}
+	return row, nil
+}
@@ -3,44 +3,15 @@ package db
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/user/go-frontol-loader/pkg/models"
 )
 
-func TestConvertToUTF8(t *testing.T) {
-	cp1251 := string([]byte{0xCF, 0xF0, 0xE8, 0xE2, 0xE5, 0xF2})
-
-	tests := []struct {
-		name  string
-		input string
-		want  string
-	}{
-		{name: "empty", input: "", want: ""},
-		{name: "ascii", input: "hello", want: "hello"},
-		{name: "cp1251_bytes", input: cp1251, want: "Привет"},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := convertToUTF8(tt.input); got != tt.want {
-				t.Fatalf("convertToUTF8() = %q, want %q", got, tt.want)
-			}
-		})
-	}
-
-	t.Run("utf8_string_is_corrupted", func(t *testing.T) {
-		input := "Привет"
-		got := convertToUTF8(input)
-		if got == input {
-			t.Fatalf("convertToUTF8() should corrupt valid UTF-8, got %q", got)
-		}
-	})
-}
+var testTime = time.Date(2024, 12, 1, 10, 30, 0, 0, time.UTC)
 
 func TestSafeValue(t *testing.T) {
-	cp1251 := string([]byte{0xCF, 0xF0, 0xE8, 0xE2, 0xE5, 0xF2})
-
 	tests := []struct {
 		name  string
 		input interface{}
@@ -48,13 +19,15 @@ func TestSafeValue(t *testing.T) {
 	}{
 		{name: "empty_string", input: "", want: nil},
 		{name: "ascii_string", input: "hello", want: "hello"},
-		{name: "cp1251_string", input: cp1251, want: "Привет"},
+		{name: "utf8_string", input: "Привет", want: "Привет"},
 		{name: "float_zero", input: 0.0, want: nil},
 		{name: "float_value", input: 1.5, want: 1.5},
 		{name: "int_zero", input: 0, want: nil},
 		{name: "int_value", input: 7, want: 7},
 		{name: "int64_zero", input: int64(0), want: nil},
 		{name: "int64_value", input: int64(9), want: int64(9)},
+		{name: "time_zero", input: time.Time{}, want: nil},
+		{name: "time_value", input: testTime, want: testTime},
 		{name: "passthrough", input: true, want: true},
 	}
 
@@ -68,8 +41,6 @@ func TestSafeValue(t *testing.T) {
 }
 
 func TestSafeValueAllowZero(t *testing.T) {
-	cp1251 := string([]byte{0xCF, 0xF0, 0xE8, 0xE2, 0xE5, 0xF2})
-
 	tests := []struct {
 		name  string
 		input interface{}
@@ -77,13 +48,15 @@ func TestSafeValueAllowZero(t *testing.T) {
 	}{
 		{name: "empty_string", input: "", want: nil},
 		{name: "ascii_string", input: "hello", want: "hello"},
-		{name: "cp1251_string", input: cp1251, want: "Привет"},
+		{name: "utf8_string", input: "Привет", want: "Привет"},
 		{name: "float_zero", input: 0.0, want: 0.0},
 		{name: "float_value", input: 2.5, want: 2.5},
 		{name: "int_zero", input: 0, want: 0},
 		{name: "int_value", input: 4, want: 4},
 		{name: "int64_zero", input: int64(0), want: int64(0)},
 		{name: "int64_value", input: int64(11), want: int64(11)},
+		{name: "time_zero", input: time.Time{}, want: nil},
+		{name: "time_value", input: testTime, want: testTime},
 		{name: "passthrough", input: false, want: false},
 	}
 
@@ -235,3 +208,34 @@ func TestBuildTxRowAllowZero(t *testing.T) {
 		t.Fatal("column item_type_code should preserve zero, got nil")
 	}
 }
+
+func TestBuildTxRowFromTxRecord(t *testing.T) {
+	schema := []models.TxColumnSpec{
+		{Name: "transaction_id_unique", Kind: models.TxColumnInt64},
+		{Name: "source_folder", Kind: models.TxColumnSource},
+		{Name: "amount", Kind: models.TxColumnFloat64},
+		{Name: "quantity", Kind: models.TxColumnFloat64, AllowZero: true},
+		{Name: "comment", Kind: models.TxColumnString},
+	}
+
+	row, err := buildTxRow(schema, models.TxRecord{
+		TransactionIDUnique: 7,
+		Values:              []interface{}{int64(7), "P13/P13", float64(0), float64(0), ""},
+	})
+	if err != nil {
+		t.Fatalf("buildTxRow() unexpected error: %v", err)
+	}
+	if row[0] != int64(7) || row[1] != "P13/P13" {
+		t.Fatalf("buildTxRow() key columns = %#v", row[:2])
+	}
+	if row[2] != nil || row[4] != nil {
+		t.Fatalf("buildTxRow() zero values should become nil, got %#v", row)
+	}
+	if row[3] != float64(0) {
+		t.Fatalf("buildTxRow() AllowZero column = %#v, want 0", row[3])
+	}
+
+	if _, err := buildTxRow(schema, models.TxRecord{Values: []interface{}{int64(1)}}); err == nil {
+		t.Fatal("buildTxRow() expected error for misaligned record, got nil")
+	}
+}
@@ -0,0 +1,77 @@
+package ftp
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/minio/minio-go/v7"
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+func TestClientFileBackend(t *testing.T) {
+	root := t.TempDir()
+	cfg := newTestExchangeConfig(t, models.FTPProtocolFTP, 21)
+	cfg.ExchangeBackend = models.ExchangeBackendFile
+	cfg.ExchangeRoot = root
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	exerciseExchange(t, client, cfg, "P13")
+
+	// Запрос лежит в смонтированной папке целиком, без временного файла
+	entries, err := os.ReadDir(filepath.Join(root, "request", "P13", "P13"))
+	if err != nil || len(entries) != 1 || entries[0].Name() != "request.txt" {
+		t.Fatalf("request folder on disk = %v, %v; want request.txt only", entries, err)
+	}
+
+	cfg.ExchangeRoot = filepath.Join(root, "missing")
+	if _, err := NewClient(cfg); err == nil {
+		t.Fatal("NewClient() succeeded with a missing exchange directory")
+	}
+}
+
+func TestClientS3Backend(t *testing.T) {
+	backend := s3mem.New()
+	if err := backend.CreateBucket("frontol"); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(gofakes3.New(backend).Server())
+	defer server.Close()
+
+	cfg := newTestExchangeConfig(t, models.FTPProtocolFTP, 21)
+	cfg.ExchangeBackend = models.ExchangeBackendS3
+	cfg.ExchangeRoot = "stores/msk"
+	cfg.S3Bucket = "frontol"
+	cfg.S3Endpoint = strings.TrimPrefix(server.URL, "http://")
+	cfg.S3Region = "us-east-1"
+	cfg.S3DisableTLS = true
+	cfg.S3AccessKeyID = "frontol"
+	cfg.S3SecretAccessKey = "secret"
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	exerciseExchange(t, client, cfg, "P13")
+
+	// Ключи объектов лежат под префиксом из EXCHANGE_URL
+	conn := client.conn.(*s3Conn)
+	if _, err := conn.client.StatObject(context.Background(), "frontol", "stores/msk/request/P13/P13/request.txt", minio.StatObjectOptions{}); err != nil {
+		t.Fatalf("request object not under the exchange prefix: %v", err)
+	}
+
+	cfg.S3Bucket = "missing"
+	if _, err := NewClient(cfg); err == nil {
+		t.Fatal("NewClient() succeeded with a missing bucket")
+	}
+}
@@ -0,0 +1,135 @@
+package ftp
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/spf13/afero"
+)
+
+// fsConn serves the FTP commands of serverConn from a filesystem, for
+// exchange folders synced to a local mount by SMB or rsync. Like sftpConn it
+// emulates the working directory.
+type fsConn struct {
+	fs  afero.Fs
+	cwd string
+}
+
+// openFSConn opens the exchange folders under root, which must be an
+// existing directory
+func openFSConn(root string) (serverConn, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("exchange directory %s: %w", root, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("exchange directory %s is not a directory", root)
+	}
+	return newFSConn(afero.NewBasePathFs(afero.NewOsFs(), root)), nil
+}
+
+func newFSConn(fs afero.Fs) *fsConn {
+	return &fsConn{fs: fs, cwd: "/"}
+}
+
+// Login is a no-op: access is governed by filesystem permissions
+func (c *fsConn) Login(user, password string) error {
+	return nil
+}
+
+func (c *fsConn) resolve(p string) string {
+	return resolvePath(c.cwd, p)
+}
+
+// List lists a directory, or returns the entry of a single file like LIST does
+func (c *fsConn) List(p string) ([]*ftp.Entry, error) {
+	p = c.resolve(p)
+	info, err := c.fs.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []*ftp.Entry{fileEntry(info)}, nil
+	}
+	infos, err := afero.ReadDir(c.fs, p)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*ftp.Entry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, fileEntry(info))
+	}
+	return entries, nil
+}
+
+// ChangeDir sets the directory relative paths are resolved against
+func (c *fsConn) ChangeDir(p string) error {
+	p = c.resolve(p)
+	info, err := c.fs.Stat(p)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", p)
+	}
+	c.cwd = p
+	return nil
+}
+
+// CurrentDir returns the directory set by ChangeDir
+func (c *fsConn) CurrentDir() (string, error) {
+	return c.cwd, nil
+}
+
+// Retr opens a file for reading
+func (c *fsConn) Retr(p string) (io.ReadCloser, error) {
+	return c.fs.Open(c.resolve(p))
+}
+
+// Stor writes r to a hidden temporary file next to p and renames it into
+// place, so a kassa polling the folder never reads a half-written file
+func (c *fsConn) Stor(p string, r io.Reader) error {
+	p = c.resolve(p)
+	tmp := path.Join(path.Dir(p), "."+path.Base(p)+".part")
+	file, err := c.fs.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		_ = file.Close()
+		_ = c.fs.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		_ = c.fs.Remove(tmp)
+		return err
+	}
+	if err := c.fs.Rename(tmp, p); err != nil {
+		_ = c.fs.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Rename renames a file
+func (c *fsConn) Rename(from, to string) error {
+	return c.fs.Rename(c.resolve(from), c.resolve(to))
+}
+
+// Delete removes a file
+func (c *fsConn) Delete(p string) error {
+	return c.fs.Remove(c.resolve(p))
+}
+
+// MakeDir creates a directory
+func (c *fsConn) MakeDir(p string) error {
+	return c.fs.Mkdir(c.resolve(p), 0750)
+}
+
+// Quit is a no-op: there is no connection to close
+func (c *fsConn) Quit() error {
+	return nil
+}
@@ -15,14 +15,16 @@ import (
 
 // Client represents FTP client
 type Client struct {
-	conn *ftp.ServerConn
+	conn serverConn
 	cfg  *models.Config
 }
 
-// NewClient creates a new FTP client
+// NewClient creates a new client of the exchange backend of EXCHANGE_URL: an
+// FTP server over FTP_PROTOCOL (plain FTP, FTPS or SFTP), a local directory or
+// S3-compatible storage
 func NewClient(cfg *models.Config) (*Client, error) {
 	// Connect to FTP server
-	conn, err := ftp.Dial(cfg.FTPHost+":"+fmt.Sprintf("%d", cfg.FTPPort), ftp.DialWithTimeout(cfg.EffectiveFTPConnectTimeout()))
+	conn, err := dialExchange(cfg, models.FTPTransport{Protocol: cfg.FTPProtocol, Port: cfg.FTPPort})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to FTP server: %w", err)
 	}
@@ -272,6 +274,40 @@ func GetAllKassaFolders(cfg *models.Config) []models.KassaFolder {
 	return folders
 }
 
+// SelectKassaFolders returns the folders of cfg matched by targets: a kassa
+// code selects all of its folders, a source folder (KASSA/FOLDER) selects one.
+// No targets select every folder; an unknown target is an error.
+func SelectKassaFolders(cfg *models.Config, targets []string) ([]models.KassaFolder, error) {
+	folders := GetAllKassaFolders(cfg)
+	if len(targets) == 0 {
+		return folders, nil
+	}
+
+	selected := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		target = strings.TrimSpace(target)
+		matched := false
+		for _, folder := range folders {
+			sourceFolder := folder.KassaCode + "/" + folder.FolderName
+			if target == folder.KassaCode || target == sourceFolder {
+				selected[sourceFolder] = true
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("unknown kassa code or source_folder %q", target)
+		}
+	}
+
+	result := make([]models.KassaFolder, 0, len(selected))
+	for _, folder := range folders {
+		if selected[folder.KassaCode+"/"+folder.FolderName] {
+			result = append(result, folder)
+		}
+	}
+	return result, nil
+}
+
 // UploadFile uploads a file to FTP server
 func (c *Client) UploadFile(localPath, remotePath string) error {
 	// Open local file
@@ -299,24 +335,32 @@ func (c *Client) UploadFile(localPath, remotePath string) error {
 	return nil
 }
 
-// CreateRequestFile creates a request.txt file with specified date range
+// CreateRequestFile creates a request.txt file for a single date
 func CreateRequestFile(localDir string, date string) (string, error) {
-	// Parse the date string (expected format: YYYY-MM-DD)
-	var requestDate string
-	if date == "" {
+	return CreateRequestFileForRange(localDir, models.SingleDay(date))
+}
+
+// CreateRequestFileForRange creates a request.txt file with specified date range
+func CreateRequestFileForRange(localDir string, dates models.DateRange) (string, error) {
+	// Parse the dates (expected format: YYYY-MM-DD)
+	var dateFrom, dateTo string
+	if dates.From == "" {
 		// Use current date if not provided
-		requestDate = time.Now().Format("02.01.2006")
+		dateFrom = time.Now().Format("02.01.2006")
+		dateTo = dateFrom
 	} else {
-		// Parse the provided date
-		parsedDate, err := time.Parse("2006-01-02", date)
+		parsed, err := models.ParseDateRange(dates.From, dates.To)
 		if err != nil {
-			return "", fmt.Errorf("invalid date format, expected YYYY-MM-DD: %w", err)
+			return "", fmt.Errorf("invalid date range: %w", err)
 		}
-		requestDate = parsedDate.Format("02.01.2006")
+		start, _ := time.Parse(models.DateLayout, parsed.From)
+		end, _ := time.Parse(models.DateLayout, parsed.To)
+		dateFrom = start.Format("02.01.2006")
+		dateTo = end.Format("02.01.2006")
 	}
 
 	// Create request content
-	requestContent := fmt.Sprintf("$$$TRANSACTIONSBYDATERANGE\n%s; %s", requestDate, requestDate)
+	requestContent := fmt.Sprintf("$$$TRANSACTIONSBYDATERANGE\n%s; %s", dateFrom, dateTo)
 
 	// Create local file path
 	requestPath := filepath.Join(localDir, "request.txt")
@@ -334,10 +378,10 @@ func CreateRequestFile(localDir string, date string) (string, error) {
 	return requestPath, nil
 }
 
-// SendRequestToKassa sends request.txt to a specific kassa folder
-func (c *Client) SendRequestToKassa(kassaFolder models.KassaFolder, date string) error {
+// SendRequestToKassa sends request.txt for the date range to a specific kassa folder
+func (c *Client) SendRequestToKassa(kassaFolder models.KassaFolder, dates models.DateRange) error {
 	// Create request file
-	requestPath, err := CreateRequestFile(c.cfg.LocalDir, date)
+	requestPath, err := CreateRequestFileForRange(c.cfg.LocalDir, dates)
 	if err != nil {
 		return fmt.Errorf("failed to create request file: %w", err)
 	}
@@ -542,7 +586,7 @@ func (c *Client) SendRequestsToAllKassasWithDate(date string) error {
 	}
 
 	for _, folder := range folders {
-		if err := c.SendRequestToKassa(folder, date); err != nil {
+		if err := c.SendRequestToKassa(folder, models.SingleDay(date)); err != nil {
 			slog.Warn("Failed to send request to kassa",
 				"kassa_code", folder.KassaCode,
 				"folder", folder.FolderName,
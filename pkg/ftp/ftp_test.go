@@ -4,6 +4,8 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -232,7 +234,7 @@ func TestMockClientAllMethods(t *testing.T) {
 	_ = mock.DeleteProcessedFiles("/test")
 	_ = mock.ClearAllKassaResponseProcessedFiles()
 	_ = mock.UploadFile("/local", "/remote")
-	_ = mock.SendRequestToKassa(models.KassaFolder{}, "2024-12-01")
+	_ = mock.SendRequestToKassa(models.KassaFolder{}, models.SingleDay("2024-12-01"))
 	_ = mock.ClearDirectory("/test")
 	_ = mock.ClearAllKassaRequestFolders()
 	_ = mock.ClearAllKassaResponseFolders()
@@ -318,6 +320,56 @@ func TestGetAllKassaFolders(t *testing.T) {
 	}
 }
 
+func TestSelectKassaFolders(t *testing.T) {
+	cfg := &models.Config{
+		KassaStructure: map[string][]string{
+			"P13": {"P13", "P13_INTER"},
+			"L32": {"L32"},
+		},
+		FTPRequestDir:  "/request",
+		FTPResponseDir: "/response",
+	}
+	sourceFolders := func(folders []models.KassaFolder) []string {
+		var names []string
+		for _, folder := range folders {
+			names = append(names, folder.KassaCode+"/"+folder.FolderName)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	tests := []struct {
+		name    string
+		targets []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "no targets select all", want: []string{"L32/L32", "P13/P13", "P13/P13_INTER"}},
+		{name: "kassa code", targets: []string{"P13"}, want: []string{"P13/P13", "P13/P13_INTER"}},
+		{name: "source folder", targets: []string{"P13/P13_INTER", "L32"}, want: []string{"L32/L32", "P13/P13_INTER"}},
+		{name: "overlapping targets", targets: []string{"P13", "P13/P13"}, want: []string{"P13/P13", "P13/P13_INTER"}},
+		{name: "unknown target", targets: []string{"P14"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SelectKassaFolders(cfg, tt.targets)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("SelectKassaFolders() = %v, want error", sourceFolders(got))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SelectKassaFolders() unexpected error: %v", err)
+			}
+			if names := sourceFolders(got); !reflect.DeepEqual(names, tt.want) {
+				t.Fatalf("SelectKassaFolders() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
 func TestRetryOperation(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -424,6 +476,24 @@ func TestRetryOperationNonPositiveDelay(t *testing.T) {
 	}
 }
 
+func TestCreateRequestFileForRange(t *testing.T) {
+	path, err := CreateRequestFileForRange(t.TempDir(), models.DateRange{From: "2024-12-01", To: "2024-12-31"})
+	if err != nil {
+		t.Fatalf("CreateRequestFileForRange() unexpected error: %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read created file: %v", err)
+	}
+	if want := "$$$TRANSACTIONSBYDATERANGE\n01.12.2024; 31.12.2024"; string(content) != want {
+		t.Fatalf("CreateRequestFileForRange() content = %q, want %q", string(content), want)
+	}
+
+	if _, err := CreateRequestFileForRange(t.TempDir(), models.DateRange{From: "2024-12-31", To: "2024-12-01"}); err == nil {
+		t.Fatal("CreateRequestFileForRange() expected error for reversed range")
+	}
+}
+
 // Benchmark for CreateRequestFile
 func BenchmarkCreateRequestFile(b *testing.B) {
 	tmpDir := b.TempDir()
@@ -16,7 +16,7 @@ type FTPClient interface {
 	DeleteProcessedFiles(path string) error
 	ClearAllKassaResponseProcessedFiles() error
 	UploadFile(localPath, remotePath string) error
-	SendRequestToKassa(kassaFolder models.KassaFolder, date string) error
+	SendRequestToKassa(kassaFolder models.KassaFolder, dates models.DateRange) error
 	ClearDirectory(path string) error
 	ClearAllKassaRequestFolders() error
 	ClearAllKassaResponseFolders() error
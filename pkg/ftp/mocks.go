@@ -15,7 +15,7 @@ type MockClient struct {
 	DeleteProcessedFilesFunc                func(path string) error
 	ClearAllKassaResponseProcessedFilesFunc func() error
 	UploadFileFunc                          func(localPath, remotePath string) error
-	SendRequestToKassaFunc                  func(kassaFolder models.KassaFolder, date string) error
+	SendRequestToKassaFunc                  func(kassaFolder models.KassaFolder, dates models.DateRange) error
 	ClearDirectoryFunc                      func(path string) error
 	ClearAllKassaRequestFoldersFunc         func() error
 	ClearAllKassaResponseFoldersFunc        func() error
@@ -82,9 +82,9 @@ func (m *MockClient) UploadFile(localPath, remotePath string) error {
 	return nil
 }
 
-func (m *MockClient) SendRequestToKassa(kassaFolder models.KassaFolder, date string) error {
+func (m *MockClient) SendRequestToKassa(kassaFolder models.KassaFolder, dates models.DateRange) error {
 	if m.SendRequestToKassaFunc != nil {
-		return m.SendRequestToKassaFunc(kassaFolder, date)
+		return m.SendRequestToKassaFunc(kassaFolder, dates)
 	}
 	return nil
 }
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"log/slog"
 	"sync"
+	"time"
 
 	"github.com/jlaffaye/ftp"
+	"github.com/user/go-frontol-loader/pkg/metrics"
 	"github.com/user/go-frontol-loader/pkg/models"
 )
 
@@ -50,6 +52,7 @@ func NewPool(cfg *models.Config, size int) (*Pool, error) {
 			return nil, fmt.Errorf("failed to create FTP connection %d: %w", i+1, err)
 		}
 		pool.connections <- client
+		metrics.AddFTPPoolConnections(1)
 		slog.Debug("Created FTP connection",
 			"connection_number", i+1,
 			"event", "ftp_connection_created",
@@ -74,17 +77,21 @@ func (p *Pool) Get() (*Client, error) {
 	p.mu.Unlock()
 
 	// Block until a connection is available
+	started := time.Now()
 	client := <-p.connections
+	metrics.FTPConnectionTaken(time.Since(started))
 	return client, nil
 }
 
 // Put returns a connection to the pool
 func (p *Pool) Put(client *Client) error {
+	metrics.FTPConnectionReturned()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	if p.closed {
 		// Pool is closed, close this connection
+		metrics.AddFTPPoolConnections(-1)
 		return client.Close()
 	}
 
@@ -125,6 +132,7 @@ func (p *Pool) Close() error {
 		count++
 	}
 
+	metrics.AddFTPPoolConnections(-count)
 	slog.Info("FTP connection pool closed",
 		"connections_closed", count,
 		"event", "ftp_pool_closed",
@@ -221,10 +229,10 @@ func (p *Pool) UploadFile(localPath, remotePath string) error {
 	})
 }
 
-// SendRequestToKassa sends request.txt to a specific kassa folder
-func (p *Pool) SendRequestToKassa(kassaFolder models.KassaFolder, date string) error {
+// SendRequestToKassa sends request.txt for the date range to a specific kassa folder
+func (p *Pool) SendRequestToKassa(kassaFolder models.KassaFolder, dates models.DateRange) error {
 	return p.WithConnection(func(client *Client) error {
-		return client.SendRequestToKassa(kassaFolder, date)
+		return client.SendRequestToKassa(kassaFolder, dates)
 	})
 }
 
@@ -0,0 +1,304 @@
+package ftp
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+// Open returns the exchange client for all kassas of cfg. When every folder
+// is reached the same way it is a single Pool; when FTP_ENDPOINTS_FILE or
+// KASSA_FTP_PROTOCOLS put folders on different endpoints or transports it is
+// a Router over one Pool per endpoint and transport.
+func Open(cfg *models.Config, size int) (FTPClient, error) {
+	groups := groupFolders(cfg)
+	switch len(groups) {
+	case 0:
+		return NewPool(cfg, size)
+	case 1:
+		return NewPool(groups[0].cfg, size)
+	}
+
+	router := &Router{}
+	for _, group := range groups {
+		pool, err := NewPool(group.cfg, size)
+		if err != nil {
+			_ = router.Close()
+			return nil, fmt.Errorf("endpoint %s (%s): %w", group.endpoint, group.describe(), err)
+		}
+		router.routes = append(router.routes, route{endpoint: group.endpoint, cfg: group.cfg, client: pool})
+	}
+	return router, nil
+}
+
+// folderGroup is the kassa folders reached through one endpoint and transport
+type folderGroup struct {
+	endpoint  string
+	transport models.FTPTransport
+	cfg       *models.Config
+}
+
+func (g folderGroup) describe() string {
+	switch backend := g.cfg.EffectiveExchangeBackend(); backend {
+	case models.ExchangeBackendFTP:
+		return fmt.Sprintf("%s on %s:%d", g.transport.Protocol, g.cfg.FTPHost, g.transport.Port)
+	default:
+		return backend
+	}
+}
+
+// groupFolders splits the kassa folders of cfg by endpoint and transport.
+// Each group gets a copy of cfg limited to its folders and connecting to its
+// endpoint over its transport, so Pool and Client work on it unchanged.
+func groupFolders(cfg *models.Config) []folderGroup {
+	type groupKey struct {
+		endpoint  string
+		transport models.FTPTransport
+	}
+	byKey := make(map[groupKey]*models.Config)
+	var keys []groupKey
+	for _, folder := range GetAllKassaFolders(cfg) {
+		key := groupKey{endpoint: models.DefaultFTPEndpoint}
+		base := cfg
+		if endpoint, ok := cfg.FTPEndpointFor(folder.KassaCode, folder.FolderName); ok {
+			key.endpoint = endpoint.Name
+			base = cfg.ForFTPEndpoint(endpoint)
+		}
+		key.transport = base.FTPTransportFor(folder.KassaCode, folder.FolderName)
+
+		scoped, ok := byKey[key]
+		if !ok {
+			copied := *base
+			copied.FTPProtocol = key.transport.Protocol
+			copied.FTPPort = key.transport.Port
+			copied.FTPEndpoints = nil
+			copied.KassaFTPTransports = nil
+			copied.KassaStructure = make(map[string][]string)
+			scoped = &copied
+			byKey[key] = scoped
+			keys = append(keys, key)
+		}
+		scoped.KassaStructure[folder.KassaCode] = append(scoped.KassaStructure[folder.KassaCode], folder.FolderName)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		if keys[i].transport.Protocol != keys[j].transport.Protocol {
+			return keys[i].transport.Protocol < keys[j].transport.Protocol
+		}
+		return keys[i].transport.Port < keys[j].transport.Port
+	})
+	groups := make([]folderGroup, 0, len(keys))
+	for _, key := range keys {
+		groups = append(groups, folderGroup{endpoint: key.endpoint, transport: key.transport, cfg: byKey[key]})
+	}
+	return groups
+}
+
+type route struct {
+	endpoint string
+	cfg      *models.Config
+	client   FTPClient
+}
+
+// owns reports whether remotePath is a kassa folder of the route or lies in one
+func (r route) owns(remotePath string) bool {
+	for _, folder := range GetAllKassaFolders(r.cfg) {
+		for _, dir := range []string{folder.RequestPath, folder.ResponsePath} {
+			if remotePath == dir || strings.HasPrefix(remotePath, dir+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasFolder reports whether the kassa folder is reached through the route
+func (r route) hasFolder(folder models.KassaFolder) bool {
+	return slices.Contains(r.cfg.KassaStructure[folder.KassaCode], folder.FolderName)
+}
+
+// Router is an FTPClient over kassas reached through different endpoints or
+// transports, holding one Pool per endpoint. Calls on a path go to the client
+// owning the kassa folder of the path, calls on all kassas go to every client.
+type Router struct {
+	routes []route
+}
+
+// FolderRouter is implemented by clients that reach kassa folders through
+// different endpoints
+type FolderRouter interface {
+	ClientForFolder(folder models.KassaFolder) (FTPClient, error)
+}
+
+// ClientForFolder returns the client of the endpoint a kassa folder is on
+func (r *Router) ClientForFolder(folder models.KassaFolder) (FTPClient, error) {
+	for _, route := range r.routes {
+		if route.hasFolder(folder) {
+			return route.client, nil
+		}
+	}
+	return nil, fmt.Errorf("no exchange endpoint configured for kassa folder %s/%s", folder.KassaCode, folder.FolderName)
+}
+
+// ForFolder returns the client that reaches a kassa folder: the routed
+// client of a FolderRouter, or client itself
+func ForFolder(client FTPClient, folder models.KassaFolder) (FTPClient, error) {
+	if router, ok := client.(FolderRouter); ok {
+		return router.ClientForFolder(folder)
+	}
+	return client, nil
+}
+
+func (r *Router) clientFor(remotePath string) (FTPClient, error) {
+	for _, route := range r.routes {
+		if route.owns(remotePath) {
+			return route.client, nil
+		}
+	}
+	return nil, fmt.Errorf("no exchange server configured for %s", remotePath)
+}
+
+// each calls fn for every client and joins their errors
+func (r *Router) each(fn func(FTPClient) error) error {
+	var errs []error
+	for _, route := range r.routes {
+		if err := fn(route.client); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the clients of all transports
+func (r *Router) Close() error {
+	return r.each(FTPClient.Close)
+}
+
+// ListFiles lists files in a kassa folder
+func (r *Router) ListFiles(path string) ([]*ftp.Entry, error) {
+	client, err := r.clientFor(path)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListFiles(path)
+}
+
+// DownloadFile downloads a file of a kassa folder
+func (r *Router) DownloadFile(remotePath, localPath string) error {
+	client, err := r.clientFor(remotePath)
+	if err != nil {
+		return err
+	}
+	return client.DownloadFile(remotePath, localPath)
+}
+
+// MarkFileAsProcessed marks a file of a kassa folder as processed
+func (r *Router) MarkFileAsProcessed(remotePath string) error {
+	client, err := r.clientFor(remotePath)
+	if err != nil {
+		return err
+	}
+	return client.MarkFileAsProcessed(remotePath)
+}
+
+// IsFileProcessed checks if a file of a kassa folder is already processed
+func (r *Router) IsFileProcessed(remotePath string) bool {
+	client, err := r.clientFor(remotePath)
+	if err != nil {
+		return false
+	}
+	return client.IsFileProcessed(remotePath)
+}
+
+// DeleteProcessedFiles deletes all .processed files from a kassa folder
+func (r *Router) DeleteProcessedFiles(path string) error {
+	client, err := r.clientFor(path)
+	if err != nil {
+		return err
+	}
+	return client.DeleteProcessedFiles(path)
+}
+
+// ClearAllKassaResponseProcessedFiles clears .processed files on every server
+func (r *Router) ClearAllKassaResponseProcessedFiles() error {
+	return r.each(FTPClient.ClearAllKassaResponseProcessedFiles)
+}
+
+// UploadFile uploads a file to a kassa folder
+func (r *Router) UploadFile(localPath, remotePath string) error {
+	client, err := r.clientFor(remotePath)
+	if err != nil {
+		return err
+	}
+	return client.UploadFile(localPath, remotePath)
+}
+
+// SendRequestToKassa sends request.txt over the transport of kassaFolder
+func (r *Router) SendRequestToKassa(kassaFolder models.KassaFolder, dates models.DateRange) error {
+	client, err := r.clientFor(kassaFolder.RequestPath)
+	if err != nil {
+		return err
+	}
+	return client.SendRequestToKassa(kassaFolder, dates)
+}
+
+// ClearDirectory removes all files from a kassa folder, or from the directory
+// on every server when it is not a kassa folder
+func (r *Router) ClearDirectory(path string) error {
+	client, err := r.clientFor(path)
+	if err != nil {
+		return r.each(func(client FTPClient) error { return client.ClearDirectory(path) })
+	}
+	return client.ClearDirectory(path)
+}
+
+// ClearAllKassaRequestFolders clears request folders on every server
+func (r *Router) ClearAllKassaRequestFolders() error {
+	return r.each(FTPClient.ClearAllKassaRequestFolders)
+}
+
+// ClearAllKassaResponseFolders clears response folders on every server
+func (r *Router) ClearAllKassaResponseFolders() error {
+	return r.each(FTPClient.ClearAllKassaResponseFolders)
+}
+
+// ClearAllKassaFolders clears request and response folders on every server
+func (r *Router) ClearAllKassaFolders() error {
+	return r.each(FTPClient.ClearAllKassaFolders)
+}
+
+// SendRequestsToAllKassas sends request.txt to all kassa folders
+func (r *Router) SendRequestsToAllKassas() error {
+	return r.each(FTPClient.SendRequestsToAllKassas)
+}
+
+// SendRequestsToAllKassasWithDate sends request.txt to all kassa folders with specified date
+func (r *Router) SendRequestsToAllKassasWithDate(date string) error {
+	return r.each(func(client FTPClient) error { return client.SendRequestsToAllKassasWithDate(date) })
+}
+
+// EnsureDirectoryExists creates a directory in a kassa folder, or on every
+// server when it is not in a kassa folder
+func (r *Router) EnsureDirectoryExists(path string) error {
+	client, err := r.clientFor(path)
+	if err != nil {
+		return r.each(func(client FTPClient) error { return client.EnsureDirectoryExists(path) })
+	}
+	return client.EnsureDirectoryExists(path)
+}
+
+// EnsureKassaFoldersExist creates the kassa folders on every server
+func (r *Router) EnsureKassaFoldersExist() error {
+	return r.each(FTPClient.EnsureKassaFoldersExist)
+}
+
+// Ensure Router implements FTPClient interface
+var _ FTPClient = (*Router)(nil)
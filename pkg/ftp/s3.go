@@ -0,0 +1,205 @@
+package ftp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+// s3Conn serves the FTP commands of serverConn from an S3-compatible bucket.
+// Folders are key prefixes under ExchangeRoot; MakeDir writes a "folder/"
+// marker object so empty kassa folders exist, as S3 consoles do. S3 has no
+// rename, so Rename copies the object and deletes the original.
+type s3Conn struct {
+	client *minio.Client
+	bucket string
+	root   string
+	cwd    string
+}
+
+// dialS3 connects to the bucket of cfg and checks it exists. Without
+// S3_ACCESS_KEY_ID the AWS_* and MINIO_* variables or the IAM role are used.
+func dialS3(cfg *models.Config) (serverConn, error) {
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.EnvMinio{},
+		&credentials.IAM{},
+	})
+	if cfg.S3AccessKeyID != "" {
+		creds = credentials.NewStaticV4(cfg.S3AccessKeyID, cfg.S3SecretAccessKey, "")
+	}
+	endpoint := cfg.S3Endpoint
+	if endpoint == "" {
+		endpoint = models.DefaultS3Endpoint
+	}
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: !cfg.S3DisableTLS,
+		Region: cfg.S3Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint %s: %w", endpoint, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.EffectiveFTPConnectTimeout())
+	defer cancel()
+	exists, err := client.BucketExists(ctx, cfg.S3Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach S3 bucket %s: %w", cfg.S3Bucket, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("S3 bucket %s does not exist", cfg.S3Bucket)
+	}
+	return &s3Conn{client: client, bucket: cfg.S3Bucket, root: cfg.ExchangeRoot, cwd: "/"}, nil
+}
+
+// Login is a no-op: requests are signed with the credentials of dialS3
+func (c *s3Conn) Login(user, password string) error {
+	return nil
+}
+
+// key returns the object key of path p
+func (c *s3Conn) key(p string) string {
+	return strings.TrimPrefix(path.Join(c.root, resolvePath(c.cwd, p)), "/")
+}
+
+// dirPrefix returns the key prefix of the objects in folder p
+func (c *s3Conn) dirPrefix(p string) string {
+	if key := c.key(p); key != "" {
+		return key + "/"
+	}
+	return ""
+}
+
+// listDir lists the objects and subfolders directly under prefix. found
+// reports whether the folder exists: it has a marker or any object.
+func (c *s3Conn) listDir(prefix string) (entries []*ftp.Entry, found bool, err error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for object := range c.client.ListObjects(ctx, c.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if object.Err != nil {
+			return nil, false, object.Err
+		}
+		found = true
+		name := strings.TrimPrefix(object.Key, prefix)
+		switch {
+		case name == "":
+			// Marker of the folder itself
+		case strings.HasSuffix(name, "/"):
+			entries = append(entries, &ftp.Entry{Name: strings.TrimSuffix(name, "/"), Type: ftp.EntryTypeFolder})
+		default:
+			entries = append(entries, objectEntry(name, object))
+		}
+	}
+	return entries, found || prefix == "", nil
+}
+
+func objectEntry(name string, object minio.ObjectInfo) *ftp.Entry {
+	return &ftp.Entry{
+		Name: name,
+		Type: ftp.EntryTypeFile,
+		Size: uint64(max(object.Size, 0)),
+		Time: object.LastModified,
+	}
+}
+
+// List lists a folder, or returns the entry of a single object like LIST does
+func (c *s3Conn) List(p string) ([]*ftp.Entry, error) {
+	entries, found, err := c.listDir(c.dirPrefix(p))
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return entries, nil
+	}
+	object, err := c.client.StatObject(context.Background(), c.bucket, c.key(p), minio.StatObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return []*ftp.Entry{objectEntry(path.Base(object.Key), object)}, nil
+}
+
+// ChangeDir sets the folder relative paths are resolved against
+func (c *s3Conn) ChangeDir(p string) error {
+	_, found, err := c.listDir(c.dirPrefix(p))
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("%s: %w", resolvePath(c.cwd, p), os.ErrNotExist)
+	}
+	c.cwd = resolvePath(c.cwd, p)
+	return nil
+}
+
+// CurrentDir returns the folder set by ChangeDir
+func (c *s3Conn) CurrentDir() (string, error) {
+	return c.cwd, nil
+}
+
+// Retr opens an object for reading. GetObject fails lazily, so the object
+// is checked first to report a missing file here like RETR does.
+func (c *s3Conn) Retr(p string) (io.ReadCloser, error) {
+	ctx := context.Background()
+	key := c.key(p)
+	if _, err := c.client.StatObject(ctx, c.bucket, key, minio.StatObjectOptions{}); err != nil {
+		return nil, err
+	}
+	return c.client.GetObject(ctx, c.bucket, key, minio.GetObjectOptions{})
+}
+
+// Stor writes r to an object, replacing it. S3 publishes the object only
+// once it is complete.
+func (c *s3Conn) Stor(p string, r io.Reader) error {
+	size := int64(-1)
+	if file, ok := r.(interface{ Stat() (os.FileInfo, error) }); ok {
+		if info, err := file.Stat(); err == nil {
+			size = info.Size()
+		}
+	}
+	_, err := c.client.PutObject(context.Background(), c.bucket, c.key(p), r, size, putOptions(size))
+	return err
+}
+
+// putOptions returns the upload options for an object of size bytes. Over
+// plain HTTP minio-go signs the payload in chunks, which sends an empty
+// object without Content-Length and is rejected; an empty payload is sent
+// unsigned instead.
+func putOptions(size int64) minio.PutObjectOptions {
+	return minio.PutObjectOptions{DisableContentSha256: size == 0}
+}
+
+// Rename copies an object to its new key and deletes the original
+func (c *s3Conn) Rename(from, to string) error {
+	ctx := context.Background()
+	src := minio.CopySrcOptions{Bucket: c.bucket, Object: c.key(from)}
+	dst := minio.CopyDestOptions{Bucket: c.bucket, Object: c.key(to)}
+	if _, err := c.client.CopyObject(ctx, dst, src); err != nil {
+		return err
+	}
+	return c.client.RemoveObject(ctx, c.bucket, src.Object, minio.RemoveObjectOptions{})
+}
+
+// Delete removes an object
+func (c *s3Conn) Delete(p string) error {
+	return c.client.RemoveObject(context.Background(), c.bucket, c.key(p), minio.RemoveObjectOptions{})
+}
+
+// MakeDir writes the marker object of a folder
+func (c *s3Conn) MakeDir(p string) error {
+	_, err := c.client.PutObject(context.Background(), c.bucket, c.dirPrefix(p), strings.NewReader(""), 0, putOptions(0))
+	return err
+}
+
+// Quit is a no-op: the S3 client keeps no session
+func (c *s3Conn) Quit() error {
+	return nil
+}
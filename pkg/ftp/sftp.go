@@ -0,0 +1,161 @@
+package ftp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/pkg/sftp"
+	"github.com/user/go-frontol-loader/pkg/models"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sftpConn serves the FTP commands of serverConn over SFTP. SFTP has no
+// working directory, so ChangeDir only remembers the path relative paths are
+// resolved against.
+type sftpConn struct {
+	ssh    *ssh.Client
+	client *sftp.Client
+	cwd    string
+}
+
+// dialSFTP connects and authenticates to an SFTP server. The host key must be
+// listed in FTPSFTPKnownHosts; FTPSFTPKeyFile is tried before FTPPassword.
+func dialSFTP(cfg *models.Config, addr string) (serverConn, error) {
+	hostKeyCallback, err := knownhosts.New(cfg.FTPSFTPKnownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load FTP_SFTP_KNOWN_HOSTS: %w", err)
+	}
+
+	var auth []ssh.AuthMethod
+	if cfg.FTPSFTPKeyFile != "" {
+		// #nosec G304 -- key file path is controlled by configuration.
+		key, err := os.ReadFile(cfg.FTPSFTPKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read FTP_SFTP_KEY_FILE: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse FTP_SFTP_KEY_FILE: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if cfg.FTPPassword != "" {
+		auth = append(auth, ssh.Password(cfg.FTPPassword))
+	}
+
+	sshClient, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            cfg.FTPUser,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         cfg.EffectiveFTPConnectTimeout(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		_ = sshClient.Close()
+		return nil, fmt.Errorf("failed to start SFTP session: %w", err)
+	}
+	return &sftpConn{ssh: sshClient, client: client, cwd: "/"}, nil
+}
+
+// Login is a no-op: the SSH handshake has already authenticated the user
+func (c *sftpConn) Login(user, password string) error {
+	return nil
+}
+
+func (c *sftpConn) resolve(p string) string {
+	return resolvePath(c.cwd, p)
+}
+
+// List lists a directory, or returns the entry of a single file like LIST does
+func (c *sftpConn) List(p string) ([]*ftp.Entry, error) {
+	p = c.resolve(p)
+	info, err := c.client.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []*ftp.Entry{fileEntry(info)}, nil
+	}
+	infos, err := c.client.ReadDir(p)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*ftp.Entry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, fileEntry(info))
+	}
+	return entries, nil
+}
+
+// ChangeDir sets the directory relative paths are resolved against
+func (c *sftpConn) ChangeDir(p string) error {
+	p = c.resolve(p)
+	info, err := c.client.Stat(p)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", p)
+	}
+	c.cwd = p
+	return nil
+}
+
+// CurrentDir returns the directory set by ChangeDir
+func (c *sftpConn) CurrentDir() (string, error) {
+	return c.cwd, nil
+}
+
+// Retr opens a remote file for reading
+func (c *sftpConn) Retr(p string) (io.ReadCloser, error) {
+	return c.client.Open(c.resolve(p))
+}
+
+// Stor writes r to a remote file, replacing it
+func (c *sftpConn) Stor(p string, r io.Reader) error {
+	file, err := c.client.Create(c.resolve(p))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Rename renames a remote file, replacing an existing target like FTP RNFR/RNTO.
+// A plain SFTP rename fails when the target exists, so servers without the
+// posix-rename extension get the target removed first.
+func (c *sftpConn) Rename(from, to string) error {
+	from, to = c.resolve(from), c.resolve(to)
+	if _, ok := c.client.HasExtension("posix-rename@openssh.com"); ok {
+		return c.client.PosixRename(from, to)
+	}
+	if err := c.client.Remove(to); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return c.client.Rename(from, to)
+}
+
+// Delete removes a remote file
+func (c *sftpConn) Delete(p string) error {
+	return c.client.Remove(c.resolve(p))
+}
+
+// MakeDir creates a remote directory
+func (c *sftpConn) MakeDir(p string) error {
+	return c.client.Mkdir(c.resolve(p))
+}
+
+// Quit closes the SFTP session and its SSH connection
+func (c *sftpConn) Quit() error {
+	return errors.Join(c.client.Close(), c.ssh.Close())
+}
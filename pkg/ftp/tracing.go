@@ -0,0 +1,60 @@
+package ftp
+
+import (
+	"context"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// tracedClient wraps an FTPClient and records list, download and upload calls
+// as spans under ctx. FTPClient methods take no context, so the parent span
+// is bound when the wrapper is created.
+type tracedClient struct {
+	FTPClient
+	ctx context.Context
+}
+
+// WithTracing returns client with its list, download and upload calls traced
+// as children of the span in ctx. Other calls are passed through.
+func WithTracing(ctx context.Context, client FTPClient) FTPClient {
+	return &tracedClient{FTPClient: client, ctx: ctx}
+}
+
+// ListFiles lists files in a directory inside an ftp.list span
+func (c *tracedClient) ListFiles(path string) ([]*ftp.Entry, error) {
+	_, span := tracing.Start(c.ctx, "ftp.list", attribute.String("ftp.path", path))
+	files, err := c.FTPClient.ListFiles(path)
+	span.SetAttributes(attribute.Int("ftp.files", len(files)))
+	tracing.End(span, err)
+	return files, err
+}
+
+// DownloadFile downloads a file inside an ftp.download span
+func (c *tracedClient) DownloadFile(remotePath, localPath string) error {
+	_, span := tracing.Start(c.ctx, "ftp.download", attribute.String("ftp.path", remotePath))
+	err := c.FTPClient.DownloadFile(remotePath, localPath)
+	tracing.End(span, err)
+	return err
+}
+
+// UploadFile uploads a file inside an ftp.upload span
+func (c *tracedClient) UploadFile(localPath, remotePath string) error {
+	_, span := tracing.Start(c.ctx, "ftp.upload", attribute.String("ftp.path", remotePath))
+	err := c.FTPClient.UploadFile(localPath, remotePath)
+	tracing.End(span, err)
+	return err
+}
+
+// SendRequestToKassa uploads request.txt inside an ftp.upload span
+func (c *tracedClient) SendRequestToKassa(kassaFolder models.KassaFolder, dates models.DateRange) error {
+	_, span := tracing.Start(c.ctx, "ftp.upload",
+		attribute.String("ftp.path", kassaFolder.RequestPath),
+		attribute.String("etl.dates", dates.String()),
+	)
+	err := c.FTPClient.SendRequestToKassa(kassaFolder, dates)
+	tracing.End(span, err)
+	return err
+}
@@ -0,0 +1,54 @@
+package ftp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/user/go-frontol-loader/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestWithTracingRecordsFTPCallsUnderParent(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	ctx, parent := tracing.Start(context.Background(), "pipeline.folder")
+	client := WithTracing(ctx, &MockClient{
+		DownloadFileFunc: func(remotePath, localPath string) error {
+			return errors.New("connection reset")
+		},
+	})
+	if _, err := client.ListFiles("/response/P13/P13"); err != nil {
+		t.Fatalf("ListFiles() error = %v", err)
+	}
+	if err := client.DownloadFile("/response/P13/P13/a.txt", "/tmp/a.txt"); err == nil {
+		t.Fatal("DownloadFile() error = nil, want mock error")
+	}
+	// Calls without a span are passed through
+	if err := client.ClearDirectory("/request/P13/P13"); err != nil {
+		t.Fatalf("ClearDirectory() error = %v", err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("ended spans = %d, want ftp.list, ftp.download and parent", len(spans))
+	}
+	list, download := spans[0], spans[1]
+	if list.Name() != "ftp.list" || download.Name() != "ftp.download" {
+		t.Fatalf("span names = %q, %q", list.Name(), download.Name())
+	}
+	if list.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatal("ftp.list is not a child of the bound span")
+	}
+	if download.Status().Code != codes.Error {
+		t.Fatalf("ftp.download status = %v, want error", download.Status().Code)
+	}
+}
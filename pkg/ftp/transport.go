@@ -0,0 +1,149 @@
+package ftp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+// serverConn is the set of server commands Client is built on. FTP and FTPS
+// connections implement it with jlaffaye/ftp, SFTP with an adapter.
+type serverConn interface {
+	Login(user, password string) error
+	List(path string) ([]*ftp.Entry, error)
+	ChangeDir(path string) error
+	CurrentDir() (string, error)
+	Retr(path string) (io.ReadCloser, error)
+	Stor(path string, r io.Reader) error
+	Rename(from, to string) error
+	Delete(path string) error
+	MakeDir(path string) error
+	Quit() error
+}
+
+// ftpConn adapts *ftp.ServerConn to serverConn
+type ftpConn struct {
+	*ftp.ServerConn
+}
+
+// Retr opens a remote file for reading
+func (c ftpConn) Retr(path string) (io.ReadCloser, error) {
+	return c.ServerConn.Retr(path)
+}
+
+// dialExchange connects to the exchange backend of cfg: a local directory,
+// S3-compatible storage, or the FTP server over transport
+func dialExchange(cfg *models.Config, transport models.FTPTransport) (serverConn, error) {
+	switch cfg.EffectiveExchangeBackend() {
+	case models.ExchangeBackendFile:
+		return openFSConn(cfg.ExchangeRoot)
+	case models.ExchangeBackendS3:
+		return dialS3(cfg)
+	default:
+		return dialTransport(cfg, transport)
+	}
+}
+
+// dialTransport connects to the exchange server of cfg over transport. FTP
+// and FTPS connections still need Login; SFTP authenticates while dialing.
+func dialTransport(cfg *models.Config, transport models.FTPTransport) (serverConn, error) {
+	addr := net.JoinHostPort(cfg.FTPHost, strconv.Itoa(transport.Port))
+	timeout := cfg.EffectiveFTPConnectTimeout()
+
+	switch transport.Protocol {
+	case "", models.FTPProtocolFTP:
+		conn, err := ftp.Dial(addr, ftp.DialWithTimeout(timeout))
+		if err != nil {
+			return nil, err
+		}
+		return ftpConn{conn}, nil
+	case models.FTPProtocolFTPS, models.FTPProtocolFTPSImplicit:
+		tlsConfig, err := newTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		option := ftp.DialWithExplicitTLS(tlsConfig)
+		if transport.Protocol == models.FTPProtocolFTPSImplicit {
+			option = ftp.DialWithTLS(tlsConfig)
+		}
+		conn, err := ftp.Dial(addr, ftp.DialWithTimeout(timeout), option)
+		if err != nil {
+			return nil, err
+		}
+		return ftpConn{conn}, nil
+	case models.FTPProtocolSFTP:
+		return dialSFTP(cfg, addr)
+	default:
+		return nil, fmt.Errorf("unsupported exchange protocol %q", transport.Protocol)
+	}
+}
+
+// newTLSConfig builds the FTPS client TLS settings: FTPTLSCAFile replaces the
+// system roots, so only servers signed by that CA are accepted, and
+// FTPTLSCertFile is presented to servers that require client certificates.
+func newTLSConfig(cfg *models.Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.FTPTLSServerName,
+		// Data connections resume the control connection session, which
+		// servers such as vsftpd with require_ssl_reuse insist on
+		ClientSessionCache: tls.NewLRUClientSessionCache(0),
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = cfg.FTPHost
+	}
+	if cfg.FTPTLSCAFile != "" {
+		// #nosec G304 -- CA file path is controlled by configuration.
+		pem, err := os.ReadFile(cfg.FTPTLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read FTP_TLS_CA_FILE: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in FTP_TLS_CA_FILE %s", cfg.FTPTLSCAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+	if cfg.FTPTLSCertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(cfg.FTPTLSCertFile, cfg.FTPTLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load FTPS client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// resolvePath resolves p against the emulated working directory cwd of
+// backends without server-side CWD
+func resolvePath(cwd, p string) string {
+	if strings.HasPrefix(p, "/") {
+		return path.Clean(p)
+	}
+	return path.Join(cwd, p)
+}
+
+// fileEntry converts file info of SFTP and local backends to a LIST entry
+func fileEntry(info os.FileInfo) *ftp.Entry {
+	entry := &ftp.Entry{
+		Name: info.Name(),
+		Type: ftp.EntryTypeFile,
+		Size: uint64(max(info.Size(), 0)),
+		Time: info.ModTime(),
+	}
+	if info.IsDir() {
+		entry.Type = ftp.EntryTypeFolder
+	} else if info.Mode()&os.ModeSymlink != 0 {
+		entry.Type = ftp.EntryTypeLink
+	}
+	return entry
+}
@@ -0,0 +1,482 @@
+package ftp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/pkg/sftp"
+	"github.com/spf13/afero"
+	"github.com/user/go-frontol-loader/pkg/models"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	testExchangeUser     = "frontol"
+	testExchangePassword = "secret"
+)
+
+// testFTPDriver serves an in-memory filesystem over FTP or FTPS
+type testFTPDriver struct {
+	fs          afero.Fs
+	password    string // testExchangePassword when empty
+	tlsConfig   *tls.Config
+	tlsRequired ftpserver.TLSRequirement
+}
+
+func (d *testFTPDriver) GetSettings() (*ftpserver.Settings, error) {
+	return &ftpserver.Settings{ListenAddr: "127.0.0.1:0", TLSRequired: d.tlsRequired}, nil
+}
+
+func (d *testFTPDriver) ClientConnected(ftpserver.ClientContext) (string, error) {
+	return "test server", nil
+}
+
+func (d *testFTPDriver) ClientDisconnected(ftpserver.ClientContext) {}
+
+func (d *testFTPDriver) AuthUser(_ ftpserver.ClientContext, user, pass string) (ftpserver.ClientDriver, error) {
+	password := d.password
+	if password == "" {
+		password = testExchangePassword
+	}
+	if user != testExchangeUser || pass != password {
+		return nil, errors.New("invalid credentials")
+	}
+	return d.fs, nil
+}
+
+func (d *testFTPDriver) GetTLSConfig() (*tls.Config, error) {
+	return d.tlsConfig, nil
+}
+
+// startTestFTPServer starts an in-process FTP server and returns its port
+func startTestFTPServer(t *testing.T, driver *testFTPDriver) int {
+	t.Helper()
+	server := ftpserver.NewFtpServer(driver)
+	if err := server.Listen(); err != nil {
+		t.Fatalf("listen FTP: %v", err)
+	}
+	go func() { _ = server.Serve() }()
+	t.Cleanup(func() { _ = server.Stop() })
+
+	_, port, err := net.SplitHostPort(server.Addr())
+	if err != nil {
+		t.Fatalf("FTP server address: %v", err)
+	}
+	portNumber, _ := strconv.Atoi(port)
+	return portNumber
+}
+
+// testPKI is a CA with a server and a client certificate issued by it
+type testPKI struct {
+	caFile     string
+	caPool     *x509.CertPool
+	server     tls.Certificate
+	clientCert string
+	clientKey  string
+}
+
+func newTestPKI(t *testing.T) testPKI {
+	t.Helper()
+	dir := t.TempDir()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, _ := x509.ParseCertificate(caDER)
+
+	issue := func(serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "127.0.0.1"},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	}
+
+	pki := testPKI{caFile: filepath.Join(dir, "ca.pem"), caPool: x509.NewCertPool()}
+	pki.caPool.AddCert(caCert)
+	writeTestFile(t, pki.caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}))
+
+	serverCert, serverKey := issue(2, x509.ExtKeyUsageServerAuth)
+	if pki.server, err = tls.X509KeyPair(serverCert, serverKey); err != nil {
+		t.Fatal(err)
+	}
+	clientCert, clientKey := issue(3, x509.ExtKeyUsageClientAuth)
+	pki.clientCert = filepath.Join(dir, "client.pem")
+	pki.clientKey = filepath.Join(dir, "client.key")
+	writeTestFile(t, pki.clientCert, clientCert)
+	writeTestFile(t, pki.clientKey, clientKey)
+	return pki
+}
+
+func writeTestFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startTestSFTPServer starts an in-process SSH server with an in-memory SFTP
+// subsystem and returns its port and a known_hosts file trusting it
+func startTestSFTPServer(t *testing.T) (int, string) {
+	t.Helper()
+	hostKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() != testExchangeUser || string(password) != testExchangePassword {
+				return nil, errors.New("invalid credentials")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	handlers := sftp.InMemHandler()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSFTP(conn, config, handlers)
+		}
+	}()
+
+	addr := listener.Addr().String()
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+	writeTestFile(t, knownHostsFile, []byte(knownhosts.Line([]string{addr}, signer.PublicKey())+"\n"))
+	return listener.Addr().(*net.TCPAddr).Port, knownHostsFile
+}
+
+func serveTestSFTP(conn net.Conn, config *ssh.ServerConfig, handlers sftp.Handlers) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "session only")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for request := range channelRequests {
+				_ = request.Reply(request.Type == "subsystem" && string(request.Payload[4:]) == "sftp", nil)
+			}
+		}()
+		go func() {
+			server := sftp.NewRequestServer(channel, handlers)
+			_ = server.Serve()
+			_ = server.Close()
+		}()
+	}
+}
+
+func newTestExchangeConfig(t *testing.T, protocol string, port int) *models.Config {
+	t.Helper()
+	return &models.Config{
+		FTPHost:           "127.0.0.1",
+		FTPPort:           port,
+		FTPProtocol:       protocol,
+		FTPUser:           testExchangeUser,
+		FTPPassword:       testExchangePassword,
+		FTPRequestDir:     "/request",
+		FTPResponseDir:    "/response",
+		FTPConnectTimeout: 5 * time.Second,
+		KassaStructure:    map[string][]string{"P13": {"P13"}},
+		LocalDir:          t.TempDir(),
+	}
+}
+
+// exerciseExchange runs the request/response cycle of the pipeline through client
+func exerciseExchange(t *testing.T, client FTPClient, cfg *models.Config, kassaCode string) {
+	t.Helper()
+	folder := models.KassaFolder{
+		KassaCode:    kassaCode,
+		FolderName:   kassaCode,
+		RequestPath:  cfg.FTPRequestDir + "/" + kassaCode + "/" + kassaCode,
+		ResponsePath: cfg.FTPResponseDir + "/" + kassaCode + "/" + kassaCode,
+	}
+	if err := client.SendRequestToKassa(folder, models.SingleDay("2024-12-01")); err != nil {
+		t.Fatalf("SendRequestToKassa() error = %v", err)
+	}
+	requests, err := client.ListFiles(folder.RequestPath)
+	if err != nil || len(requests) != 1 || requests[0].Name != "request.txt" {
+		t.Fatalf("request folder = %v, %v; want request.txt", requests, err)
+	}
+
+	// Касса отвечает файлом в папку ответа
+	local := filepath.Join(cfg.LocalDir, "upload.txt")
+	writeTestFile(t, local, []byte("0\nDB\nREPORT\n"))
+	if err := client.UploadFile(local, folder.ResponsePath+"/response.txt"); err != nil {
+		t.Fatalf("UploadFile() error = %v", err)
+	}
+	downloaded := filepath.Join(cfg.LocalDir, "download", "response.txt")
+	if err := client.DownloadFile(folder.ResponsePath+"/response.txt", downloaded); err != nil {
+		t.Fatalf("DownloadFile() error = %v", err)
+	}
+	if data, err := os.ReadFile(downloaded); err != nil || string(data) != "0\nDB\nREPORT\n" {
+		t.Fatalf("downloaded = %q, %v", data, err)
+	}
+
+	if err := client.MarkFileAsProcessed(folder.ResponsePath + "/response.txt"); err != nil {
+		t.Fatalf("MarkFileAsProcessed() error = %v", err)
+	}
+	responses, err := client.ListFiles(folder.ResponsePath)
+	if err != nil || len(responses) != 1 || responses[0].Name != "response.txt.processed" {
+		t.Fatalf("response folder = %v, %v; want response.txt.processed", responses, err)
+	}
+	if err := client.ClearDirectory(folder.ResponsePath); err != nil {
+		t.Fatalf("ClearDirectory() error = %v", err)
+	}
+	if files, err := client.ListFiles(folder.ResponsePath); err != nil || len(files) != 0 {
+		t.Fatalf("response folder after clear = %v, %v", files, err)
+	}
+}
+
+func TestClientFTPS(t *testing.T) {
+	pki := newTestPKI(t)
+
+	t.Run("explicit with client certificate", func(t *testing.T) {
+		port := startTestFTPServer(t, &testFTPDriver{
+			fs: afero.NewMemMapFs(),
+			tlsConfig: &tls.Config{
+				Certificates: []tls.Certificate{pki.server},
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				ClientCAs:    pki.caPool,
+			},
+			tlsRequired: ftpserver.MandatoryEncryption,
+		})
+		cfg := newTestExchangeConfig(t, models.FTPProtocolFTPS, port)
+		cfg.FTPTLSCAFile = pki.caFile
+		cfg.FTPTLSCertFile = pki.clientCert
+		cfg.FTPTLSKeyFile = pki.clientKey
+
+		client, err := NewClient(cfg)
+		if err != nil {
+			t.Fatalf("NewClient() error = %v", err)
+		}
+		defer func() { _ = client.Close() }()
+		exerciseExchange(t, client, cfg, "P13")
+	})
+
+	t.Run("implicit", func(t *testing.T) {
+		port := startTestFTPServer(t, &testFTPDriver{
+			fs:          afero.NewMemMapFs(),
+			tlsConfig:   &tls.Config{Certificates: []tls.Certificate{pki.server}},
+			tlsRequired: ftpserver.ImplicitEncryption,
+		})
+		cfg := newTestExchangeConfig(t, models.FTPProtocolFTPSImplicit, port)
+		cfg.FTPTLSCAFile = pki.caFile
+
+		client, err := NewClient(cfg)
+		if err != nil {
+			t.Fatalf("NewClient() error = %v", err)
+		}
+		defer func() { _ = client.Close() }()
+		exerciseExchange(t, client, cfg, "P13")
+	})
+
+	t.Run("rejects server outside pinned CA", func(t *testing.T) {
+		port := startTestFTPServer(t, &testFTPDriver{
+			fs:          afero.NewMemMapFs(),
+			tlsConfig:   &tls.Config{Certificates: []tls.Certificate{pki.server}},
+			tlsRequired: ftpserver.MandatoryEncryption,
+		})
+		cfg := newTestExchangeConfig(t, models.FTPProtocolFTPS, port)
+		cfg.FTPTLSCAFile = newTestPKI(t).caFile
+
+		if client, err := NewClient(cfg); err == nil {
+			_ = client.Close()
+			t.Fatal("NewClient() succeeded with a server certificate from another CA")
+		}
+	})
+}
+
+func TestClientSFTP(t *testing.T) {
+	port, knownHostsFile := startTestSFTPServer(t)
+	cfg := newTestExchangeConfig(t, models.FTPProtocolSFTP, port)
+	cfg.FTPSFTPKnownHosts = knownHostsFile
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	exerciseExchange(t, client, cfg, "P13")
+
+	// Повторная отметка файла заменяет оставшийся .processed, как RNFR/RNTO в FTP
+	responsePath := cfg.FTPResponseDir + "/P13/P13"
+	local := filepath.Join(cfg.LocalDir, "again.txt")
+	writeTestFile(t, local, []byte("1\nDB\nREPORT\n"))
+	for _, name := range []string{"response.txt", "response.txt.processed"} {
+		if err := client.UploadFile(local, responsePath+"/"+name); err != nil {
+			t.Fatalf("UploadFile(%s) error = %v", name, err)
+		}
+	}
+	if err := client.MarkFileAsProcessed(responsePath + "/response.txt"); err != nil {
+		t.Fatalf("MarkFileAsProcessed() over existing target error = %v", err)
+	}
+	if files, err := client.ListFiles(responsePath); err != nil || len(files) != 1 || files[0].Name != "response.txt.processed" {
+		t.Fatalf("response folder = %v, %v; want only response.txt.processed", files, err)
+	}
+
+	// Неизвестный ключ хоста отклоняется
+	cfg.FTPSFTPKnownHosts = filepath.Join(t.TempDir(), "empty_known_hosts")
+	writeTestFile(t, cfg.FTPSFTPKnownHosts, nil)
+	if client, err := NewClient(cfg); err == nil {
+		_ = client.Close()
+		t.Fatal("NewClient() succeeded with an unknown host key")
+	}
+}
+
+func TestOpenRoutesKassasByTransport(t *testing.T) {
+	ftpFS := afero.NewMemMapFs()
+	ftpPort := startTestFTPServer(t, &testFTPDriver{fs: ftpFS})
+	sftpPort, knownHostsFile := startTestSFTPServer(t)
+
+	cfg := newTestExchangeConfig(t, models.FTPProtocolFTP, ftpPort)
+	cfg.KassaStructure = map[string][]string{"P13": {"P13"}, "L32": {"L32"}}
+	cfg.FTPSFTPKnownHosts = knownHostsFile
+	cfg.KassaFTPTransports = map[string]models.FTPTransport{"L32": {Protocol: models.FTPProtocolSFTP, Port: sftpPort}}
+
+	client, err := Open(cfg, 2)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	if _, ok := client.(*Router); !ok {
+		t.Fatalf("Open() = %T, want *Router for mixed transports", client)
+	}
+
+	exerciseExchange(t, client, cfg, "P13")
+	exerciseExchange(t, client, cfg, "L32")
+
+	// Папки L32 живут только на SFTP-сервере
+	if _, err := ftpFS.Stat("/request/P13/P13/request.txt"); err != nil {
+		t.Fatalf("P13 request not on the FTP server: %v", err)
+	}
+	if _, err := ftpFS.Stat("/request/L32"); err == nil {
+		t.Fatal("L32 folders were created on the FTP server")
+	}
+	if _, err := client.ListFiles("/elsewhere"); err == nil {
+		t.Fatal("ListFiles() outside kassa folders succeeded")
+	}
+}
+
+func TestOpenRoutesKassasByEndpoint(t *testing.T) {
+	defaultFS := afero.NewMemMapFs()
+	defaultPort := startTestFTPServer(t, &testFTPDriver{fs: defaultFS})
+	southFS := afero.NewMemMapFs()
+	southPort := startTestFTPServer(t, &testFTPDriver{fs: southFS, password: "south-secret"})
+
+	cfg := newTestExchangeConfig(t, models.FTPProtocolFTP, defaultPort)
+	cfg.KassaStructure = map[string][]string{"P13": {"P13"}, "L32": {"L32"}}
+	cfg.FTPEndpoints = []models.FTPEndpoint{{
+		Name:     "south",
+		Password: "south-secret",
+		Kassas:   []string{"L32"},
+		Backend:  models.ExchangeBackendFTP,
+		Protocol: models.FTPProtocolFTP,
+		Host:     "127.0.0.1",
+		Port:     southPort,
+	}}
+
+	client, err := Open(cfg, 1)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	exerciseExchange(t, client, cfg, "P13")
+	exerciseExchange(t, client, cfg, "L32")
+
+	// Каждая касса живет только на сервере своего региона
+	for _, check := range []struct {
+		fs      afero.Fs
+		present string
+		absent  string
+	}{
+		{defaultFS, "/request/P13/P13/request.txt", "/request/L32"},
+		{southFS, "/request/L32/L32/request.txt", "/request/P13"},
+	} {
+		if _, err := check.fs.Stat(check.present); err != nil {
+			t.Errorf("%s missing: %v", check.present, err)
+		}
+		if _, err := check.fs.Stat(check.absent); err == nil {
+			t.Errorf("%s created on the wrong server", check.absent)
+		}
+	}
+
+	south, err := ForFolder(client, models.KassaFolder{KassaCode: "L32", FolderName: "L32"})
+	if err != nil {
+		t.Fatalf("ForFolder() error = %v", err)
+	}
+	if files, err := south.ListFiles("/request/L32/L32"); err != nil || len(files) != 1 {
+		t.Fatalf("south endpoint request folder = %v, %v", files, err)
+	}
+	if _, err := ForFolder(client, models.KassaFolder{KassaCode: "X01", FolderName: "X01"}); err == nil {
+		t.Fatal("ForFolder() routed a kassa folder without an endpoint")
+	}
+}
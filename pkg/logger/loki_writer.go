@@ -10,6 +10,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/user/go-frontol-loader/pkg/metrics"
 )
 
 var lokiDynamicLabelKeys = []string{"component", "log_kind", "level", "operation_type"}
@@ -88,6 +90,7 @@ func (w *lokiWriter) Write(p []byte) (int, error) {
 	case w.entries <- entry:
 	default:
 		// Drop on backpressure to avoid blocking the application.
+		metrics.LokiEntryDropped()
 	}
 	return len(p), nil
 }
@@ -109,7 +112,8 @@ func (w *lokiWriter) run() {
 		if len(batch) == 0 {
 			return
 		}
-		_, _ = w.flush(batch)
+		flushed, err := w.flush(batch)
+		metrics.ObserveLokiFlush(flushed, err)
 		batch = batch[:0]
 	}
 	for {
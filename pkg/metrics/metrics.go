@@ -0,0 +1,243 @@
+// Package metrics holds the Prometheus metrics of the ETL and the helpers the
+// pipeline, FTP pool, database loader and Loki writer record them with.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "frontol_etl"
+
+// File outcomes of the kassa files counter.
+const (
+	FileProcessed = "processed"
+	FileSkipped   = "skipped"
+	FileFailed    = "failed"
+)
+
+// Results of webhook report delivery attempts.
+const (
+	ReportDelivered    = "delivered"
+	ReportRetried      = "retried"
+	ReportDeadLettered = "dead_lettered"
+)
+
+// registry is separate from the default one so that only the metrics below
+// and the Go runtime and process collectors are exposed.
+var registry = prometheus.NewRegistry()
+
+var (
+	queueSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_size",
+		Help:      "Requests waiting in the operation queue by operation type.",
+	}, []string{"operation_type"})
+	activeOperations = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_operations",
+		Help:      "Operations currently running.",
+	})
+	queueStatsErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "queue_stats_errors_total",
+		Help:      "Failed reads of the durable queue sizes; the queue gauges keep their last values.",
+	})
+
+	pipelineRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pipeline_runs_total",
+		Help:      "Finished ETL pipeline runs by status.",
+	}, []string{"status"})
+	pipelineDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "pipeline_duration_seconds",
+		Help:      "Duration of ETL pipeline runs by status.",
+		// A run waits for kassa responses, so it takes minutes rather than seconds
+		Buckets: []float64{30, 60, 120, 300, 600, 900, 1200, 1800, 2700, 3600, 7200},
+	}, []string{"status"})
+
+	kassaFiles = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kassa_files_total",
+		Help:      "Response files per kassa folder by outcome: processed, skipped or failed.",
+	}, []string{"source_folder", "outcome"})
+	kassaResponseLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "kassa_response_latency_seconds",
+		Help:      "Time from sending the request to a kassa until its response files are ready.",
+		Buckets:   []float64{5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600},
+	}, []string{"source_folder"})
+
+	ftpPoolConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ftp_pool_connections",
+		Help:      "Connections held by open FTP connection pools.",
+	})
+	ftpPoolInUse = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ftp_pool_connections_in_use",
+		Help:      "FTP pool connections currently taken by callers.",
+	})
+	ftpPoolWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ftp_pool_wait_seconds",
+		Help:      "Time spent waiting for a free FTP pool connection.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	})
+
+	dbRowsLoaded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_rows_loaded_total",
+		Help:      "Rows written to tx_* tables by table.",
+	}, []string{"table"})
+	dbLoadDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_load_duration_seconds",
+		Help:      "Latency of loading one batch of rows into a tx_* table.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"table"})
+	dbLoadErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_load_errors_total",
+		Help:      "Failed loads into tx_* tables by table.",
+	}, []string{"table"})
+
+	webhookReports = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_report_deliveries_total",
+		Help:      "Webhook report delivery attempts by result: delivered, retried or dead_lettered.",
+	}, []string{"result"})
+
+	lokiDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "loki_entries_dropped_total",
+		Help:      "Log entries dropped by the Loki writer because its buffer was full.",
+	})
+	lokiFlushes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "loki_flushes_total",
+		Help:      "Loki push requests by result: success or error.",
+	}, []string{"result"})
+	lokiEntriesFlushed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "loki_entries_flushed_total",
+		Help:      "Log entries pushed to Loki.",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		queueSize,
+		activeOperations,
+		queueStatsErrors,
+		pipelineRuns,
+		pipelineDuration,
+		kassaFiles,
+		kassaResponseLatency,
+		ftpPoolConnections,
+		ftpPoolInUse,
+		ftpPoolWait,
+		dbRowsLoaded,
+		dbLoadDuration,
+		dbLoadErrors,
+		webhookReports,
+		lokiDropped,
+		lokiFlushes,
+		lokiEntriesFlushed,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// SetQueue replaces the queue gauges with the sizes of a queue snapshot;
+// operation types missing from sizes are dropped.
+func SetQueue(sizes map[string]int, active int) {
+	queueSize.Reset()
+	for operationType, size := range sizes {
+		queueSize.WithLabelValues(operationType).Set(float64(size))
+	}
+	activeOperations.Set(float64(active))
+}
+
+// ObserveQueueStatsError records a failed read of the queue sizes. The queue
+// gauges are left as they were.
+func ObserveQueueStatsError() {
+	queueStatsErrors.Inc()
+}
+
+// ObservePipelineRun records a finished pipeline run.
+func ObservePipelineRun(status string, duration time.Duration) {
+	pipelineRuns.WithLabelValues(status).Inc()
+	pipelineDuration.WithLabelValues(status).Observe(duration.Seconds())
+}
+
+// AddKassaFiles adds the file counts of one kassa folder run.
+func AddKassaFiles(sourceFolder string, processed, skipped, failed int) {
+	kassaFiles.WithLabelValues(sourceFolder, FileProcessed).Add(float64(processed))
+	kassaFiles.WithLabelValues(sourceFolder, FileSkipped).Add(float64(skipped))
+	kassaFiles.WithLabelValues(sourceFolder, FileFailed).Add(float64(failed))
+}
+
+// ObserveKassaResponse records how long a kassa folder took to answer.
+func ObserveKassaResponse(sourceFolder string, latency time.Duration) {
+	kassaResponseLatency.WithLabelValues(sourceFolder).Observe(latency.Seconds())
+}
+
+// AddFTPPoolConnections changes the number of connections held by pools:
+// positive when a pool is created, negative when it is closed.
+func AddFTPPoolConnections(delta int) {
+	ftpPoolConnections.Add(float64(delta))
+}
+
+// FTPConnectionTaken records a connection taken from a pool after waiting
+// for wait.
+func FTPConnectionTaken(wait time.Duration) {
+	ftpPoolInUse.Inc()
+	ftpPoolWait.Observe(wait.Seconds())
+}
+
+// FTPConnectionReturned records a connection given back to its pool.
+func FTPConnectionReturned() {
+	ftpPoolInUse.Dec()
+}
+
+// ObserveDBLoad records one load into tableName; rows count only when the
+// load succeeded.
+func ObserveDBLoad(tableName string, rows int, duration time.Duration, err error) {
+	dbLoadDuration.WithLabelValues(tableName).Observe(duration.Seconds())
+	if err != nil {
+		dbLoadErrors.WithLabelValues(tableName).Inc()
+		return
+	}
+	dbRowsLoaded.WithLabelValues(tableName).Add(float64(rows))
+}
+
+// ObserveReportDelivery records one delivery attempt of a webhook report.
+func ObserveReportDelivery(result string) {
+	webhookReports.WithLabelValues(result).Inc()
+}
+
+// LokiEntryDropped records a log entry dropped on backpressure.
+func LokiEntryDropped() {
+	lokiDropped.Inc()
+}
+
+// ObserveLokiFlush records one push of entries to Loki.
+func ObserveLokiFlush(entries int, err error) {
+	if err != nil {
+		lokiFlushes.WithLabelValues("error").Inc()
+		return
+	}
+	lokiFlushes.WithLabelValues("success").Inc()
+	lokiEntriesFlushed.Add(float64(entries))
+}
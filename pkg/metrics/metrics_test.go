@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSetQueueDropsMissingTypes(t *testing.T) {
+	SetQueue(map[string]int{"load": 2, "download": 1}, 1)
+	SetQueue(map[string]int{"load": 3}, 0)
+
+	if got := testutil.ToFloat64(queueSize.WithLabelValues("load")); got != 3 {
+		t.Fatalf("load queue = %v, want 3", got)
+	}
+	if got := testutil.CollectAndCount(queueSize); got != 1 {
+		t.Fatalf("queue series = %d, want 1", got)
+	}
+	if got := testutil.ToFloat64(activeOperations); got != 0 {
+		t.Fatalf("active operations = %v, want 0", got)
+	}
+}
+
+func TestObserveQueueStatsErrorKeepsQueueGauges(t *testing.T) {
+	SetQueue(map[string]int{"load": 4}, 2)
+	before := testutil.ToFloat64(queueStatsErrors)
+	ObserveQueueStatsError()
+
+	if got := testutil.ToFloat64(queueStatsErrors) - before; got != 1 {
+		t.Fatalf("queue stats errors increased by %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(queueSize.WithLabelValues("load")); got != 4 {
+		t.Fatalf("load queue size = %v, want 4", got)
+	}
+}
+
+func TestAddKassaFiles(t *testing.T) {
+	AddKassaFiles("P13/P13", 3, 1, 2)
+	AddKassaFiles("P13/P13", 1, 0, 0)
+
+	tests := map[string]float64{FileProcessed: 4, FileSkipped: 1, FileFailed: 2}
+	for outcome, want := range tests {
+		if got := testutil.ToFloat64(kassaFiles.WithLabelValues("P13/P13", outcome)); got != want {
+			t.Fatalf("%s files = %v, want %v", outcome, got, want)
+		}
+	}
+}
+
+func TestObserveDBLoadCountsRowsOnlyOnSuccess(t *testing.T) {
+	ObserveDBLoad("tx_test_metrics", 10, time.Millisecond, nil)
+	ObserveDBLoad("tx_test_metrics", 5, time.Millisecond, errors.New("boom"))
+
+	if got := testutil.ToFloat64(dbRowsLoaded.WithLabelValues("tx_test_metrics")); got != 10 {
+		t.Fatalf("rows loaded = %v, want 10", got)
+	}
+	if got := testutil.ToFloat64(dbLoadErrors.WithLabelValues("tx_test_metrics")); got != 1 {
+		t.Fatalf("load errors = %v, want 1", got)
+	}
+}
+
+func TestObserveLokiFlush(t *testing.T) {
+	before := testutil.ToFloat64(lokiEntriesFlushed)
+	ObserveLokiFlush(7, nil)
+	ObserveLokiFlush(0, errors.New("loki down"))
+
+	if got := testutil.ToFloat64(lokiEntriesFlushed) - before; got != 7 {
+		t.Fatalf("entries flushed = %v, want 7", got)
+	}
+	if got := testutil.ToFloat64(lokiFlushes.WithLabelValues("error")); got < 1 {
+		t.Fatalf("error flushes = %v, want at least 1", got)
+	}
+}
+
+func TestRegistryGathers(t *testing.T) {
+	if _, err := registry.Gather(); err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+}
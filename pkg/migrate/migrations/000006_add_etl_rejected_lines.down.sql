@@ -0,0 +1,4 @@
+-- Migration: 000006_add_etl_rejected_lines
+-- Description: Drop rejected transaction lines quarantine table
+
+DROP TABLE IF EXISTS etl_rejected_lines;
@@ -0,0 +1,22 @@
+-- Migration: 000006_add_etl_rejected_lines
+-- Description: Quarantine transaction lines that could not be parsed, written atomically with the file load
+
+CREATE TABLE etl_rejected_lines (
+  id BIGSERIAL PRIMARY KEY,
+  source_folder TEXT NOT NULL,
+  logical_key TEXT NOT NULL,
+  line_number INTEGER NOT NULL,
+  raw_line TEXT NOT NULL,
+  transaction_type INTEGER,
+  error TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'rejected',
+  rejected_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  reprocessed_at TIMESTAMPTZ,
+  UNIQUE (logical_key, line_number)
+);
+
+CREATE INDEX etl_rejected_lines_source_folder_status_idx
+  ON etl_rejected_lines (source_folder, status);
+
+CREATE INDEX etl_rejected_lines_transaction_type_idx
+  ON etl_rejected_lines (transaction_type);
@@ -0,0 +1,5 @@
+-- Migration: 000007_add_file_load_state_encoding
+-- Description: Drop the recorded file encoding
+
+ALTER TABLE etl_file_load_state
+  DROP COLUMN IF EXISTS encoding;
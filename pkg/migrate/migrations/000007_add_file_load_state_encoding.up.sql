@@ -0,0 +1,5 @@
+-- Migration: 000007_add_file_load_state_encoding
+-- Description: Record the character encoding a file was decoded with
+
+ALTER TABLE etl_file_load_state
+  ADD COLUMN encoding TEXT;
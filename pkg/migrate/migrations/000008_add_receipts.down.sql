@@ -0,0 +1,18 @@
+-- Migration: 000008_add_receipts
+-- Description: Drop receipts derived model and its document lookup indexes
+
+DROP INDEX IF EXISTS tx_document_cancel_56_document_idx;
+DROP INDEX IF EXISTS tx_document_close_55_document_idx;
+DROP INDEX IF EXISTS tx_fiscal_payment_43_document_idx;
+DROP INDEX IF EXISTS tx_fiscal_payment_40_document_idx;
+DROP INDEX IF EXISTS tx_document_discount_37_document_idx;
+DROP INDEX IF EXISTS tx_document_discount_35_document_idx;
+DROP INDEX IF EXISTS tx_position_discount_17_document_idx;
+DROP INDEX IF EXISTS tx_position_discount_15_document_idx;
+DROP INDEX IF EXISTS tx_item_storno_2_12_document_idx;
+DROP INDEX IF EXISTS tx_item_registration_1_11_document_idx;
+DROP INDEX IF EXISTS tx_document_open_42_document_idx;
+
+DROP TABLE IF EXISTS receipt_payments;
+DROP TABLE IF EXISTS receipt_lines;
+DROP TABLE IF EXISTS receipts;
@@ -0,0 +1,92 @@
+-- Migration: 000008_add_receipts
+-- Description: Receipts rebuilt from tx_* rows (open 42, items 1/11 and storno 2/12, discounts 15/17/35/37, payments 40/43, close 55, cancel 56)
+
+CREATE TABLE receipts (
+  source_folder TEXT NOT NULL,
+  cash_register_code BIGINT NOT NULL,
+  shift_number BIGINT NOT NULL,
+  document_number BIGINT NOT NULL,
+  transaction_date DATE,
+  open_time TIME,
+  close_time TIME,
+  status TEXT NOT NULL,
+  cashier_code BIGINT,
+  operation_type BIGINT,
+  document_type_code BIGINT,
+  customer_card_numbers TEXT,
+  open_transaction_id BIGINT,
+  close_transaction_id BIGINT,
+  line_count INTEGER NOT NULL DEFAULT 0,
+  gross_amount NUMERIC(18,6) NOT NULL DEFAULT 0,
+  position_discount_amount NUMERIC(18,6) NOT NULL DEFAULT 0,
+  document_discount_amount NUMERIC(18,6) NOT NULL DEFAULT 0,
+  net_amount NUMERIC(18,6) NOT NULL DEFAULT 0,
+  paid_amount NUMERIC(18,6) NOT NULL DEFAULT 0,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (source_folder, cash_register_code, shift_number, document_number)
+);
+
+CREATE INDEX receipts_transaction_date_idx
+  ON receipts (transaction_date, source_folder);
+
+CREATE TABLE receipt_lines (
+  source_folder TEXT NOT NULL,
+  cash_register_code BIGINT NOT NULL,
+  shift_number BIGINT NOT NULL,
+  document_number BIGINT NOT NULL,
+  line_number INTEGER NOT NULL,
+  transaction_id_unique BIGINT NOT NULL,
+  transaction_type BIGINT,
+  item_identifier TEXT,
+  article_sku TEXT,
+  registration_barcode TEXT,
+  price NUMERIC(18,6) NOT NULL DEFAULT 0,
+  quantity NUMERIC(18,6) NOT NULL DEFAULT 0,
+  storno_quantity NUMERIC(18,6) NOT NULL DEFAULT 0,
+  gross_amount NUMERIC(18,6) NOT NULL DEFAULT 0,
+  discount_amount NUMERIC(18,6) NOT NULL DEFAULT 0,
+  net_amount NUMERIC(18,6) NOT NULL DEFAULT 0,
+  PRIMARY KEY (source_folder, cash_register_code, shift_number, document_number, line_number),
+  FOREIGN KEY (source_folder, cash_register_code, shift_number, document_number)
+    REFERENCES receipts (source_folder, cash_register_code, shift_number, document_number) ON DELETE CASCADE
+);
+
+CREATE TABLE receipt_payments (
+  source_folder TEXT NOT NULL,
+  cash_register_code BIGINT NOT NULL,
+  shift_number BIGINT NOT NULL,
+  document_number BIGINT NOT NULL,
+  transaction_id_unique BIGINT NOT NULL,
+  transaction_type BIGINT,
+  payment_type_code TEXT,
+  payment_type_operation BIGINT,
+  card_number TEXT,
+  amount NUMERIC(18,6) NOT NULL DEFAULT 0,
+  PRIMARY KEY (source_folder, cash_register_code, shift_number, document_number, transaction_id_unique, transaction_type),
+  FOREIGN KEY (source_folder, cash_register_code, shift_number, document_number)
+    REFERENCES receipts (source_folder, cash_register_code, shift_number, document_number) ON DELETE CASCADE
+);
+
+-- Receipts are rebuilt per document, so the source tables are looked up by document key
+CREATE INDEX tx_document_open_42_document_idx
+  ON tx_document_open_42 (source_folder, COALESCE(cash_register_code, 0), COALESCE(shift_number, 0), COALESCE(document_number, 0));
+CREATE INDEX tx_item_registration_1_11_document_idx
+  ON tx_item_registration_1_11 (source_folder, COALESCE(cash_register_code, 0), COALESCE(shift_number, 0), COALESCE(document_number, 0));
+CREATE INDEX tx_item_storno_2_12_document_idx
+  ON tx_item_storno_2_12 (source_folder, COALESCE(cash_register_code, 0), COALESCE(shift_number, 0), COALESCE(document_number, 0));
+CREATE INDEX tx_position_discount_15_document_idx
+  ON tx_position_discount_15 (source_folder, COALESCE(cash_register_code, 0), COALESCE(shift_number, 0), COALESCE(document_number, 0));
+CREATE INDEX tx_position_discount_17_document_idx
+  ON tx_position_discount_17 (source_folder, COALESCE(cash_register_code, 0), COALESCE(shift_number, 0), COALESCE(document_number, 0));
+CREATE INDEX tx_document_discount_35_document_idx
+  ON tx_document_discount_35 (source_folder, COALESCE(cash_register_code, 0), COALESCE(shift_number, 0), COALESCE(document_number, 0));
+CREATE INDEX tx_document_discount_37_document_idx
+  ON tx_document_discount_37 (source_folder, COALESCE(cash_register_code, 0), COALESCE(shift_number, 0), COALESCE(document_number, 0));
+CREATE INDEX tx_fiscal_payment_40_document_idx
+  ON tx_fiscal_payment_40 (source_folder, COALESCE(cash_register_code, 0), COALESCE(shift_number, 0), COALESCE(document_number, 0));
+CREATE INDEX tx_fiscal_payment_43_document_idx
+  ON tx_fiscal_payment_43 (source_folder, COALESCE(cash_register_code, 0), COALESCE(shift_number, 0), COALESCE(document_number, 0));
+CREATE INDEX tx_document_close_55_document_idx
+  ON tx_document_close_55 (source_folder, COALESCE(cash_register_code, 0), COALESCE(shift_number, 0), COALESCE(document_number, 0));
+CREATE INDEX tx_document_cancel_56_document_idx
+  ON tx_document_cancel_56 (source_folder, COALESCE(cash_register_code, 0), COALESCE(shift_number, 0), COALESCE(document_number, 0));
@@ -0,0 +1,4 @@
+-- Migration: 000009_add_shift_reconciliation
+-- Description: Drop shift reconciliation results
+
+DROP TABLE IF EXISTS shift_reconciliation;
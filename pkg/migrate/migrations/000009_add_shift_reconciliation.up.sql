@@ -0,0 +1,25 @@
+-- Migration: 000009_add_shift_reconciliation
+-- Description: Per-shift comparison of receipts and payments with Z-report (63) / shift close (61) revenue
+
+CREATE TABLE shift_reconciliation (
+  source_folder TEXT NOT NULL,
+  cash_register_code BIGINT NOT NULL,
+  shift_number BIGINT NOT NULL,
+  transaction_date DATE,
+  status TEXT NOT NULL,
+  z_transaction_type BIGINT,
+  z_transaction_id BIGINT,
+  z_revenue NUMERIC(18,6),
+  receipt_count INTEGER NOT NULL DEFAULT 0,
+  sales_amount NUMERIC(18,6) NOT NULL DEFAULT 0,
+  returns_amount NUMERIC(18,6) NOT NULL DEFAULT 0,
+  items_revenue NUMERIC(18,6) NOT NULL DEFAULT 0,
+  payments_revenue NUMERIC(18,6) NOT NULL DEFAULT 0,
+  items_difference NUMERIC(18,6),
+  payments_difference NUMERIC(18,6),
+  checked_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (source_folder, cash_register_code, shift_number)
+);
+
+CREATE INDEX shift_reconciliation_status_idx
+  ON shift_reconciliation (status, transaction_date);
@@ -0,0 +1,4 @@
+-- Migration: 000010_add_etl_operation_queue
+-- Description: Drop durable webhook-server operation queue
+
+DROP TABLE IF EXISTS etl_operation_queue;
@@ -0,0 +1,20 @@
+-- Migration: 000010_add_etl_operation_queue
+-- Description: Durable webhook-server operation queue shared by all replicas
+
+CREATE TABLE etl_operation_queue (
+  operation_id TEXT PRIMARY KEY,
+  request_id TEXT,
+  operation_type TEXT NOT NULL,
+  date TEXT NOT NULL,
+  date_to TEXT,
+  kassas TEXT[] NOT NULL DEFAULT '{}',
+  status TEXT NOT NULL DEFAULT 'queued',
+  enqueued_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  locked_by TEXT,
+  locked_at TIMESTAMPTZ,
+  heartbeat_at TIMESTAMPTZ,
+  attempts INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX etl_operation_queue_status_enqueued_at_idx
+  ON etl_operation_queue (status, enqueued_at);
@@ -0,0 +1,5 @@
+-- Migration: 000011_add_operation_run_result
+-- Description: Drop the pipeline result summary of finished operations
+
+ALTER TABLE etl_operation_runs
+  DROP COLUMN IF EXISTS result;
@@ -0,0 +1,5 @@
+-- Migration: 000011_add_operation_run_result
+-- Description: Store the pipeline result summary of finished operations
+
+ALTER TABLE etl_operation_runs
+  ADD COLUMN result JSONB;
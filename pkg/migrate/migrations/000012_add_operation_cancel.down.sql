@@ -0,0 +1,5 @@
+-- Migration: 000012_add_operation_cancel
+-- Description: Drop the cancellation flag of queued operations
+
+ALTER TABLE etl_operation_queue
+  DROP COLUMN IF EXISTS cancel_requested;
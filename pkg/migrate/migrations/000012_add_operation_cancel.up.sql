@@ -0,0 +1,5 @@
+-- Migration: 000012_add_operation_cancel
+-- Description: Flag queued and processing operations for cancellation
+
+ALTER TABLE etl_operation_queue
+  ADD COLUMN cancel_requested BOOLEAN NOT NULL DEFAULT FALSE;
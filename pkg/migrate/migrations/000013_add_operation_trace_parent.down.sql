@@ -0,0 +1,5 @@
+-- Migration: 000013_add_operation_trace_parent
+-- Description: Drop the traceparent of queued operations
+
+ALTER TABLE etl_operation_queue
+  DROP COLUMN IF EXISTS trace_parent;
@@ -0,0 +1,5 @@
+-- Migration: 000013_add_operation_trace_parent
+-- Description: Keep the W3C traceparent of queued operations so their spans join the request trace
+
+ALTER TABLE etl_operation_queue
+  ADD COLUMN trace_parent TEXT;
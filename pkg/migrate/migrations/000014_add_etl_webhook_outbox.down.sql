@@ -0,0 +1,4 @@
+-- Migration: 000014_add_etl_webhook_outbox
+-- Description: Drop webhook report outbox
+
+DROP TABLE IF EXISTS etl_webhook_outbox;
@@ -0,0 +1,25 @@
+-- Migration: 000014_add_etl_webhook_outbox
+-- Description: Outbox of webhook reports with delivery retries and dead-lettering
+
+CREATE TABLE etl_webhook_outbox (
+  id BIGSERIAL PRIMARY KEY,
+  operation_id TEXT NOT NULL,
+  request_id TEXT,
+  url TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  trace_parent TEXT,
+  status TEXT NOT NULL DEFAULT 'pending',
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  last_status_code INTEGER,
+  last_error TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX etl_webhook_outbox_status_next_attempt_at_idx
+  ON etl_webhook_outbox (status, next_attempt_at);
+
+CREATE INDEX etl_webhook_outbox_operation_id_idx
+  ON etl_webhook_outbox (operation_id);
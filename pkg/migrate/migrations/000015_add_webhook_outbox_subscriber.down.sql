@@ -0,0 +1,5 @@
+-- Migration: 000015_add_webhook_outbox_subscriber
+-- Description: Drop subscriber from outbox reports
+
+ALTER TABLE etl_webhook_outbox
+  DROP COLUMN IF EXISTS subscriber;
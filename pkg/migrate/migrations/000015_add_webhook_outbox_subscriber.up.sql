@@ -0,0 +1,5 @@
+-- Migration: 000015_add_webhook_outbox_subscriber
+-- Description: Address outbox reports to a named webhook subscriber
+
+ALTER TABLE etl_webhook_outbox
+  ADD COLUMN subscriber TEXT NOT NULL DEFAULT 'default';
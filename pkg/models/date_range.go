@@ -0,0 +1,81 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// DateLayout is the YYYY-MM-DD layout of requested dates.
+const DateLayout = "2006-01-02"
+
+// MaxDateRangeDays caps how many days a single request file may span.
+const MaxDateRangeDays = 92
+
+// DateRange is an inclusive range of days requested from the kassas in one
+// request file. A single-day load has From == To.
+type DateRange struct {
+	From string `json:"date_from"`
+	To   string `json:"date_to"`
+}
+
+// SingleDay returns the range holding only date.
+func SingleDay(date string) DateRange {
+	return DateRange{From: date, To: date}
+}
+
+// ParseDateRange validates from and to (YYYY-MM-DD); an empty to means a
+// single day.
+func ParseDateRange(from, to string) (DateRange, error) {
+	if to == "" {
+		to = from
+	}
+	start, err := time.Parse(DateLayout, from)
+	if err != nil {
+		return DateRange{}, fmt.Errorf("invalid date_from, expected YYYY-MM-DD: %w", err)
+	}
+	end, err := time.Parse(DateLayout, to)
+	if err != nil {
+		return DateRange{}, fmt.Errorf("invalid date_to, expected YYYY-MM-DD: %w", err)
+	}
+	if end.Before(start) {
+		return DateRange{}, fmt.Errorf("date_to %s is before date_from %s", to, from)
+	}
+	if days := int(end.Sub(start).Hours()/24) + 1; days > MaxDateRangeDays {
+		return DateRange{}, fmt.Errorf("date range spans %d days, at most %d allowed", days, MaxDateRangeDays)
+	}
+	return DateRange{From: from, To: to}, nil
+}
+
+// IsSingleDay reports whether the range holds one day.
+func (r DateRange) IsSingleDay() bool {
+	return r.To == "" || r.From == r.To
+}
+
+// Days lists the days of the range in order. A range that does not parse
+// yields its From day only.
+func (r DateRange) Days() []string {
+	if r.IsSingleDay() {
+		return []string{r.From}
+	}
+	start, err := time.Parse(DateLayout, r.From)
+	if err != nil {
+		return []string{r.From}
+	}
+	end, err := time.Parse(DateLayout, r.To)
+	if err != nil {
+		return []string{r.From}
+	}
+	var days []string
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		days = append(days, day.Format(DateLayout))
+	}
+	return days
+}
+
+// String returns the day of a single-day range and "from..to" otherwise.
+func (r DateRange) String() string {
+	if r.IsSingleDay() {
+		return r.From
+	}
+	return r.From + ".." + r.To
+}
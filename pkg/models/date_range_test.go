@@ -0,0 +1,52 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDateRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		want    DateRange
+		wantErr bool
+	}{
+		{name: "single day when to is empty", from: "2024-12-01", want: DateRange{From: "2024-12-01", To: "2024-12-01"}},
+		{name: "month", from: "2024-12-01", to: "2024-12-31", want: DateRange{From: "2024-12-01", To: "2024-12-31"}},
+		{name: "to before from", from: "2024-12-02", to: "2024-12-01", wantErr: true},
+		{name: "invalid from", from: "01.12.2024", to: "2024-12-01", wantErr: true},
+		{name: "too long", from: "2024-01-01", to: "2024-12-31", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDateRange(tt.from, tt.to)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseDateRange() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("ParseDateRange() = %+v, %v; want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDateRangeDays(t *testing.T) {
+	r := DateRange{From: "2024-02-28", To: "2024-03-01"}
+	if got, want := r.Days(), []string{"2024-02-28", "2024-02-29", "2024-03-01"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Days() = %v, want %v", got, want)
+	}
+	if r.IsSingleDay() || r.String() != "2024-02-28..2024-03-01" {
+		t.Fatalf("IsSingleDay() = %v, String() = %q", r.IsSingleDay(), r.String())
+	}
+
+	day := SingleDay("2024-12-01")
+	if !day.IsSingleDay() || day.String() != "2024-12-01" || !reflect.DeepEqual(day.Days(), []string{"2024-12-01"}) {
+		t.Fatalf("SingleDay() = %+v, days %v", day, day.Days())
+	}
+}
@@ -0,0 +1,103 @@
+package models
+
+// Backends the kassa exchange folders are reached through.
+const (
+	ExchangeBackendFTP  = "ftp"  // FTP server over FTP_PROTOCOL
+	ExchangeBackendFile = "file" // local directory, e.g. an SMB or rsync mount
+	ExchangeBackendS3   = "s3"   // S3-compatible object storage
+)
+
+// DefaultFTPEndpoint names the FTP_HOST or EXCHANGE_URL server of the kassa
+// folders without an FTP_ENDPOINTS_FILE endpoint.
+const DefaultFTPEndpoint = "default"
+
+// DefaultS3Endpoint is used when the s3 exchange URL has no endpoint.
+const DefaultS3Endpoint = "s3.amazonaws.com"
+
+// EffectiveExchangeBackend returns ExchangeBackend, or ftp when it is unset.
+func (c *Config) EffectiveExchangeBackend() string {
+	if c.ExchangeBackend == "" {
+		return ExchangeBackendFTP
+	}
+	return c.ExchangeBackend
+}
+
+// FTPEndpoint is the exchange server of a group of kassas, e.g. the FTP
+// server of a region, from FTP_ENDPOINTS_FILE. Credentials left empty are
+// taken from the FTP_* and S3_* variables.
+type FTPEndpoint struct {
+	Name              string   `json:"name"`
+	URL               string   `json:"url"` // ftp://, ftps://, ftps-implicit://, sftp://, file:// or s3:// like EXCHANGE_URL
+	User              string   `json:"user,omitempty"`
+	Password          string   `json:"password,omitempty"`
+	TLSCAFile         string   `json:"tls_ca_file,omitempty"`
+	SFTPKeyFile       string   `json:"sftp_key_file,omitempty"`
+	SFTPKnownHosts    string   `json:"sftp_known_hosts,omitempty"`
+	S3AccessKeyID     string   `json:"s3_access_key_id,omitempty"`
+	S3SecretAccessKey string   `json:"s3_secret_access_key,omitempty"`
+	Kassas            []string `json:"kassas"` // kassa codes or source folders
+
+	// Exchange settings parsed from URL by the config loader
+	Backend      string `json:"-"`
+	Protocol     string `json:"-"`
+	Host         string `json:"-"`
+	Port         int    `json:"-"` // 0 means the protocol's default port
+	Root         string `json:"-"`
+	S3Bucket     string `json:"-"`
+	S3Endpoint   string `json:"-"`
+	S3Region     string `json:"-"`
+	S3DisableTLS bool   `json:"-"`
+}
+
+// FTPEndpointFor returns the endpoint a kassa folder is assigned to by its
+// source folder or kassa code; ok is false for folders on the FTP_* server.
+func (c *Config) FTPEndpointFor(kassaCode, folderName string) (endpoint FTPEndpoint, ok bool) {
+	for _, key := range []string{kassaCode + "/" + folderName, kassaCode} {
+		for _, endpoint := range c.FTPEndpoints {
+			for _, kassa := range endpoint.Kassas {
+				if kassa == key {
+					return endpoint, true
+				}
+			}
+		}
+	}
+	return FTPEndpoint{}, false
+}
+
+// ForFTPEndpoint returns a copy of c that connects to endpoint instead of
+// the FTP_* server and EXCHANGE_URL, keeping the global credentials the
+// endpoint does not override.
+func (c *Config) ForFTPEndpoint(endpoint FTPEndpoint) *Config {
+	scoped := *c
+	scoped.FTPEndpoints = nil
+	scoped.KassaFTPTransports = nil
+	scoped.ExchangeBackend = endpoint.Backend
+	scoped.ExchangeRoot = endpoint.Root
+	scoped.S3Bucket = endpoint.S3Bucket
+	scoped.S3Endpoint = endpoint.S3Endpoint
+	scoped.S3Region = endpoint.S3Region
+	scoped.S3DisableTLS = endpoint.S3DisableTLS
+	if endpoint.Protocol != "" {
+		scoped.FTPProtocol = endpoint.Protocol
+		scoped.FTPHost = endpoint.Host
+		scoped.FTPPort = endpoint.Port
+		if scoped.FTPPort == 0 {
+			scoped.FTPPort = DefaultFTPPort(endpoint.Protocol)
+		}
+		// The certificate of the endpoint is checked against its own host
+		scoped.FTPTLSServerName = ""
+	}
+	override := func(target *string, value string) {
+		if value != "" {
+			*target = value
+		}
+	}
+	override(&scoped.FTPUser, endpoint.User)
+	override(&scoped.FTPPassword, endpoint.Password)
+	override(&scoped.FTPTLSCAFile, endpoint.TLSCAFile)
+	override(&scoped.FTPSFTPKeyFile, endpoint.SFTPKeyFile)
+	override(&scoped.FTPSFTPKnownHosts, endpoint.SFTPKnownHosts)
+	override(&scoped.S3AccessKeyID, endpoint.S3AccessKeyID)
+	override(&scoped.S3SecretAccessKey, endpoint.S3SecretAccessKey)
+	return &scoped
+}
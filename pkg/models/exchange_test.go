@@ -0,0 +1,62 @@
+package models
+
+import "testing"
+
+func TestFTPEndpointFor(t *testing.T) {
+	cfg := &Config{FTPEndpoints: []FTPEndpoint{
+		{Name: "north", Kassas: []string{"L32"}},
+		{Name: "inter", Kassas: []string{"L32/L32_INTER"}},
+	}}
+
+	for _, tt := range []struct {
+		kassa, folder, want string
+		ok                  bool
+	}{
+		{"L32", "L32", "north", true},
+		{"L32", "L32_INTER", "inter", true},
+		{"P13", "P13", "", false},
+	} {
+		endpoint, ok := cfg.FTPEndpointFor(tt.kassa, tt.folder)
+		if ok != tt.ok || endpoint.Name != tt.want {
+			t.Errorf("FTPEndpointFor(%s, %s) = %q, %v; want %q, %v", tt.kassa, tt.folder, endpoint.Name, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestForFTPEndpoint(t *testing.T) {
+	cfg := &Config{
+		FTPHost:            "ftp.central",
+		FTPPort:            2121,
+		FTPProtocol:        FTPProtocolFTP,
+		FTPUser:            "frontol",
+		FTPPassword:        "central",
+		FTPTLSServerName:   "ftp.central",
+		KassaFTPTransports: map[string]FTPTransport{"P13": {Protocol: FTPProtocolSFTP}},
+		FTPEndpoints:       []FTPEndpoint{{Name: "north"}},
+	}
+
+	scoped := cfg.ForFTPEndpoint(FTPEndpoint{
+		Name:     "north",
+		Backend:  ExchangeBackendFTP,
+		Protocol: FTPProtocolFTPSImplicit,
+		Host:     "ftp.north",
+		Password: "north",
+	})
+	if scoped.FTPHost != "ftp.north" || scoped.FTPPort != 990 || scoped.FTPProtocol != FTPProtocolFTPSImplicit {
+		t.Fatalf("endpoint server = %s:%d %s, want ftp.north:990 ftps-implicit", scoped.FTPHost, scoped.FTPPort, scoped.FTPProtocol)
+	}
+	if scoped.FTPUser != "frontol" || scoped.FTPPassword != "north" || scoped.FTPTLSServerName != "" {
+		t.Fatalf("endpoint credentials = %s/%s (%q), want global user with endpoint password", scoped.FTPUser, scoped.FTPPassword, scoped.FTPTLSServerName)
+	}
+	if scoped.FTPEndpoints != nil || scoped.KassaFTPTransports != nil {
+		t.Fatal("endpoint config keeps routing settings of the global config")
+	}
+	if cfg.FTPHost != "ftp.central" || cfg.FTPPassword != "central" {
+		t.Fatal("ForFTPEndpoint() modified the global config")
+	}
+
+	files := cfg.ForFTPEndpoint(FTPEndpoint{Backend: ExchangeBackendFile, Root: "/mnt/south"})
+	if files.EffectiveExchangeBackend() != ExchangeBackendFile || files.ExchangeRoot != "/mnt/south" {
+		t.Fatalf("file endpoint = %s %s", files.EffectiveExchangeBackend(), files.ExchangeRoot)
+	}
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// Data gap kinds found by gap detection.
+const (
+	DataGapNoLoad        = "no_load"        // no committed file load of the day
+	DataGapNoRows        = "no_rows"        // the day was loaded but holds no transactions
+	DataGapUnclosedShift = "unclosed_shift" // a shift has receipts but no shift close (61) or Z-report (63)
+)
+
+// DataGap is a day of a source folder whose data is missing or incomplete.
+type DataGap struct {
+	SourceFolder     string `json:"source_folder"`
+	Date             string `json:"date"`
+	Kind             string `json:"kind"`
+	FilesLoaded      int    `json:"files_loaded"`
+	CashRegisterCode int64  `json:"cash_register_code,omitempty"` // unclosed_shift only
+	ShiftNumber      int64  `json:"shift_number,omitempty"`       // unclosed_shift only
+}
+
+// GapBackfill is a targeted reload of consecutive gap days of one source
+// folder.
+type GapBackfill struct {
+	SourceFolder string    `json:"source_folder"`
+	Dates        DateRange `json:"dates"`
+}
+
+// PlanGapBackfills groups gaps into reloads: one per source folder and run of
+// consecutive days, at most MaxDateRangeDays long. Reloads are ordered by
+// source folder and date.
+func PlanGapBackfills(gaps []DataGap) []GapBackfill {
+	days := make(map[string]map[string]bool)
+	for _, gap := range gaps {
+		if days[gap.SourceFolder] == nil {
+			days[gap.SourceFolder] = make(map[string]bool)
+		}
+		days[gap.SourceFolder][gap.Date] = true
+	}
+	folders := make([]string, 0, len(days))
+	for folder := range days {
+		folders = append(folders, folder)
+	}
+	sort.Strings(folders)
+
+	var backfills []GapBackfill
+	for _, folder := range folders {
+		dates := make([]string, 0, len(days[folder]))
+		for date := range days[folder] {
+			dates = append(dates, date)
+		}
+		sort.Strings(dates)
+
+		current := DateRange{From: dates[0], To: dates[0]}
+		span := 1
+		for _, date := range dates[1:] {
+			if span < MaxDateRangeDays && isNextDay(current.To, date) {
+				current.To = date
+				span++
+				continue
+			}
+			backfills = append(backfills, GapBackfill{SourceFolder: folder, Dates: current})
+			current = DateRange{From: date, To: date}
+			span = 1
+		}
+		backfills = append(backfills, GapBackfill{SourceFolder: folder, Dates: current})
+	}
+	return backfills
+}
+
+func isNextDay(day, next string) bool {
+	t, err := time.Parse(DateLayout, day)
+	if err != nil {
+		return false
+	}
+	return t.AddDate(0, 0, 1).Format(DateLayout) == next
+}
+
+// RecentDays returns the days days that ended before t's day: days=1 is the
+// day before t.
+func RecentDays(t time.Time, days int) DateRange {
+	if days < 1 {
+		days = 1
+	}
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return DateRange{
+		From: day.AddDate(0, 0, -days).Format(DateLayout),
+		To:   day.AddDate(0, 0, -1).Format(DateLayout),
+	}
+}
+
+// KassaLocation returns the time zone of kassaCode from KassaTimezones, or
+// time.Local when the kassa has none. Time zones are checked on load.
+func (c *Config) KassaLocation(kassaCode string) *time.Location {
+	if name := c.KassaTimezones[kassaCode]; name != "" {
+		if location, err := time.LoadLocation(name); err == nil {
+			return location
+		}
+	}
+	return time.Local
+}
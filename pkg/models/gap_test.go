@@ -0,0 +1,30 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanGapBackfills(t *testing.T) {
+	gaps := []DataGap{
+		{SourceFolder: "P13/P13", Date: "2024-12-03", Kind: DataGapNoLoad},
+		{SourceFolder: "P13/P13", Date: "2024-12-01", Kind: DataGapNoRows},
+		{SourceFolder: "P13/P13", Date: "2024-12-02", Kind: DataGapNoLoad},
+		{SourceFolder: "P13/P13", Date: "2024-12-02", Kind: DataGapUnclosedShift, CashRegisterCode: 1, ShiftNumber: 7},
+		{SourceFolder: "P13/P13", Date: "2024-12-05", Kind: DataGapNoLoad},
+		{SourceFolder: "N22/N22", Date: "2024-12-31", Kind: DataGapNoLoad},
+		{SourceFolder: "N22/N22", Date: "2025-01-01", Kind: DataGapNoLoad},
+	}
+
+	want := []GapBackfill{
+		{SourceFolder: "N22/N22", Dates: DateRange{From: "2024-12-31", To: "2025-01-01"}},
+		{SourceFolder: "P13/P13", Dates: DateRange{From: "2024-12-01", To: "2024-12-03"}},
+		{SourceFolder: "P13/P13", Dates: DateRange{From: "2024-12-05", To: "2024-12-05"}},
+	}
+	if got := PlanGapBackfills(gaps); !reflect.DeepEqual(got, want) {
+		t.Fatalf("PlanGapBackfills() = %+v, want %+v", got, want)
+	}
+	if got := PlanGapBackfills(nil); len(got) != 0 {
+		t.Fatalf("PlanGapBackfills(nil) = %+v, want none", got)
+	}
+}
@@ -16,29 +16,62 @@ type Config struct {
 	DBConnectTimeout time.Duration
 
 	// FTP settings
-	FTPHost           string
-	FTPPort           int
-	FTPUser           string
-	FTPPassword       string
-	FTPRequestDir     string
-	FTPResponseDir    string
-	FTPPoolSize       int // Number of FTP connections in pool (default: 5)
-	FTPConnectTimeout time.Duration
-	KassaStructure    map[string][]string
+	FTPHost            string
+	FTPPort            int
+	FTPUser            string
+	FTPPassword        string
+	FTPRequestDir      string
+	FTPResponseDir     string
+	FTPPoolSize        int // Number of FTP connections in pool (default: 5)
+	FTPConnectTimeout  time.Duration
+	KassaStructure     map[string][]string
+	KassaEncodings     map[string]string       // Encoding override per source folder ("<kassa>/<folder>"), from KASSA_STRUCTURE
+	KassaTimezones     map[string]string       // IANA time zone per kassa code, from kassas of CONFIG_FILE (default: Local)
+	FTPProtocol        string                  // ftp, ftps, ftps-implicit or sftp (default: ftp)
+	FTPTLSCAFile       string                  // PEM CA bundle trusted for FTPS instead of the system roots
+	FTPTLSCertFile     string                  // Client certificate for FTPS, with FTPTLSKeyFile
+	FTPTLSKeyFile      string                  // Private key of FTPTLSCertFile
+	FTPTLSServerName   string                  // Name checked in the FTPS server certificate (default: FTPHost)
+	FTPSFTPKeyFile     string                  // Private key for SFTP public key auth, tried before FTPPassword
+	FTPSFTPKnownHosts  string                  // known_hosts file the SFTP host key is checked against
+	KassaFTPTransports map[string]FTPTransport // Protocol per kassa code or source folder, from KASSA_FTP_PROTOCOLS
+
+	// Exchange backend settings, from EXCHANGE_URL
+	ExchangeBackend   string // ftp (FTP_* settings), file or s3 (default: ftp)
+	ExchangeRoot      string // Directory of the file backend, key prefix of the s3 backend
+	S3Bucket          string
+	S3Endpoint        string // host[:port] of the S3-compatible API (default: s3.amazonaws.com)
+	S3Region          string
+	S3DisableTLS      bool   // Plain HTTP to S3Endpoint, e.g. a MinIO on the local network
+	S3AccessKeyID     string // Static credentials; AWS_* and MINIO_* variables or IAM are used when empty
+	S3SecretAccessKey string
+	FTPEndpoints      []FTPEndpoint // Exchange servers of kassa groups, from FTP_ENDPOINTS_FILE or CONFIG_FILE
+
+	// Configuration file settings
+	ConfigFile           string        // YAML or JSON file with kassas, pipeline settings, endpoints and subscribers
+	ConfigReloadInterval time.Duration // How often the webhook server checks ConfigFile for changes (0 = only on SIGHUP)
 
 	// Application settings
-	LocalDir              string
-	BatchSize             int
-	MaxRetries            int
-	RetryDelay            time.Duration
-	WaitDelayMinutes      time.Duration
-	PipelineLoadTimeout   time.Duration
-	CLIRunTimeout         time.Duration
-	OperationStaleTimeout time.Duration
-	WorkerPoolSize        int // Number of concurrent file processing workers (default: 10)
-	LogLevel              string
-	LogFormat             string // json or text/console
-	LogBackend            string // slog or zerolog
+	LocalDir               string
+	BatchSize              int
+	ParseMode              string // lenient (coerce and report) or strict (fail the file)
+	LoadStrategy           string // batch (INSERT per row) or copy (COPY into a staging table)
+	MaxRetries             int
+	RetryDelay             time.Duration
+	WaitDelayMinutes       time.Duration            // Deadline for a kassa response after the request is sent
+	ResponsePollInterval   time.Duration            // First pause between response folder polls, doubled after each poll
+	ResponsePollMaxBackoff time.Duration            // Upper bound of the pause between response folder polls
+	KassaResponseDeadlines map[string]time.Duration // Response deadline per kassa code or source folder, from KASSA_RESPONSE_DEADLINES
+	PipelineLoadTimeout    time.Duration
+	CLIRunTimeout          time.Duration
+	OperationStaleTimeout  time.Duration
+	WorkerPoolSize         int // Number of concurrent file processing workers (default: 10)
+	LogLevel               string
+	LogFormat              string // json or text/console
+	LogBackend             string // slog or zerolog
+	TracingExporter        string // none, otlp or stdout
+	TracingOTLPEndpoint    string // OTLP/HTTP collector URL; empty falls back to OTEL_EXPORTER_OTLP_* variables
+	TracingServiceName     string // service.name of exported spans
 
 	// Webhook server settings
 	ServerPort                     int
@@ -46,12 +79,24 @@ type Config struct {
 	WebhookTimeoutMinutes          int // Timeout for sending webhook report (0 = no timeout, send only on completion)
 	WebhookReportHTTPTimeout       time.Duration
 	WebhookReportResultWaitTimeout time.Duration
-	WebhookBearerToken             string // Bearer token for webhook authorization
+	WebhookBearerToken             string              // Bearer token for webhook authorization
+	WebhookSigningSecret           string              // HMAC-SHA256 key of the report signature header; empty disables signing
+	WebhookReportMaxAttempts       int                 // Delivery attempts of a report before it is dead-lettered (default: 8)
+	WebhookReportRetryDelay        time.Duration       // Delay before the first redelivery, doubled on each further attempt
+	WebhookSubscribers             []WebhookSubscriber // Report subscribers from WEBHOOK_SUBSCRIBERS_FILE or CONFIG_FILE, besides WEBHOOK_REPORT_URL
 	HTTPReadHeaderTimeout          time.Duration
 	HTTPReadTimeout                time.Duration
 	HTTPWriteTimeout               time.Duration
 	HTTPIdleTimeout                time.Duration
 	ShutdownTimeout                time.Duration // Graceful shutdown timeout (default: 30 seconds)
+	QueueProvider                  string        // postgres (durable etl_operation_queue) or memory (in-process channels)
+	QueueWorkers                   int           // Number of durable queue workers per webhook-server instance (default: 4)
+	QueuePollInterval              time.Duration // How often idle durable queue workers look for new jobs
+	QueueLeaseTimeout              time.Duration // A claimed job without heartbeats this long is taken over by another instance
+	Schedules                      []Schedule    // Recurring loads of the built-in scheduler, from SCHEDULES
+	ScheduleTimezone               string        // Time zone of schedule cron expressions (default: Local)
+	GapCheckDays                   int           // Days before today checked for data gaps (default: 7)
+	GapBackfillSchedule            string        // Cron of the automatic gap backfill; empty disables it
 }
 
 // KassaFolder represents a kassa folder structure
@@ -312,16 +357,135 @@ type FileHeader struct {
 }
 
 // FileLoadState represents durable metadata about a successfully loaded logical file.
+// A file requested for a date range is stored as one state per day of the
+// range, keyed by FileLoadLogicalKey of that day.
 type FileLoadState struct {
 	LogicalKey          string             `json:"logical_key"`
 	RemotePath          string             `json:"remote_path"`
 	RequestedDate       string             `json:"requested_date,omitempty"`
+	RequestedDateTo     string             `json:"requested_date_to,omitempty"` // last day of a multi-day request; not stored, the loader splits the state per day
 	SourceFolder        string             `json:"source_folder"`
 	ContentHash         string             `json:"content_hash"`
 	TransactionManifest map[string][]int64 `json:"transaction_manifest,omitempty"`
+	Encoding            string             `json:"encoding,omitempty"`
 	UpdatedAt           time.Time          `json:"updated_at"`
 }
 
+// FileLoadLogicalKey identifies the load of the file at remotePath for one
+// requested day.
+func FileLoadLogicalKey(remotePath, requestedDate string) string {
+	return remotePath + "|" + requestedDate
+}
+
+// Rejected line statuses stored in etl_rejected_lines.status.
+const (
+	RejectedLineStatusRejected    = "rejected"
+	RejectedLineStatusReprocessed = "reprocessed"
+)
+
+// RejectedLine is a transaction line quarantined in etl_rejected_lines because
+// the parser could not turn it into a row.
+type RejectedLine struct {
+	ID              int64      `json:"id"`
+	SourceFolder    string     `json:"source_folder"`
+	LogicalKey      string     `json:"logical_key"`
+	LineNumber      int        `json:"line_number"`
+	RawLine         string     `json:"raw_line"`
+	TransactionType int        `json:"transaction_type,omitempty"` // 0 when field 4 is not a number
+	Error           string     `json:"error"`
+	Status          string     `json:"status"`
+	RejectedAt      time.Time  `json:"rejected_at"`
+	ReprocessedAt   *time.Time `json:"reprocessed_at,omitempty"`
+}
+
+// Receipt statuses stored in receipts.status.
+const (
+	ReceiptStatusOpen      = "open"
+	ReceiptStatusClosed    = "closed"
+	ReceiptStatusCancelled = "cancelled"
+)
+
+// Receipt is a Frontol document rebuilt from its tx_* rows. It is keyed by
+// source folder, cash register, shift and document number.
+type Receipt struct {
+	SourceFolder           string           `json:"source_folder"`
+	CashRegisterCode       int64            `json:"cash_register_code"`
+	ShiftNumber            int64            `json:"shift_number"`
+	DocumentNumber         int64            `json:"document_number"`
+	TransactionDate        time.Time        `json:"transaction_date"`
+	OpenTime               time.Time        `json:"open_time"`  // zero without document open (42)
+	CloseTime              time.Time        `json:"close_time"` // zero without document close (55)
+	Status                 string           `json:"status"`
+	CashierCode            int64            `json:"cashier_code"`
+	OperationType          int64            `json:"operation_type"`
+	DocumentTypeCode       int64            `json:"document_type_code"`
+	CustomerCardNumbers    string           `json:"customer_card_numbers,omitempty"`
+	OpenTransactionID      int64            `json:"open_transaction_id,omitempty"`
+	CloseTransactionID     int64            `json:"close_transaction_id,omitempty"`
+	GrossAmount            float64          `json:"gross_amount"`             // line amounts after storno, before discounts
+	PositionDiscountAmount float64          `json:"position_discount_amount"` // discounts 15/17
+	DocumentDiscountAmount float64          `json:"document_discount_amount"` // discounts 35/37
+	NetAmount              float64          `json:"net_amount"`
+	PaidAmount             float64          `json:"paid_amount"`
+	Lines                  []ReceiptLine    `json:"lines"`
+	Payments               []ReceiptPayment `json:"payments"`
+}
+
+// ReceiptLine is a registered item (1/11) net of its storno (2/12) rows and
+// position discounts (15/17).
+type ReceiptLine struct {
+	LineNumber          int     `json:"line_number"`
+	TransactionIDUnique int64   `json:"transaction_id_unique"`
+	TransactionType     int64   `json:"transaction_type"`
+	ItemIdentifier      string  `json:"item_identifier"`
+	ArticleSKU          string  `json:"article_sku,omitempty"`
+	RegistrationBarcode string  `json:"registration_barcode,omitempty"`
+	Price               float64 `json:"price"`
+	Quantity            float64 `json:"quantity"`
+	StornoQuantity      float64 `json:"storno_quantity"`
+	GrossAmount         float64 `json:"gross_amount"`
+	DiscountAmount      float64 `json:"discount_amount"`
+	NetAmount           float64 `json:"net_amount"`
+}
+
+// ReceiptPayment is a fiscal payment (40) or its distribution by print group (43).
+type ReceiptPayment struct {
+	TransactionIDUnique  int64   `json:"transaction_id_unique"`
+	TransactionType      int64   `json:"transaction_type"`
+	PaymentTypeCode      string  `json:"payment_type_code"`
+	PaymentTypeOperation int64   `json:"payment_type_operation"`
+	CardNumber           string  `json:"card_number,omitempty"`
+	Amount               float64 `json:"amount"`
+}
+
+// Shift reconciliation statuses stored in shift_reconciliation.status.
+const (
+	ShiftReconciliationMatched   = "matched"
+	ShiftReconciliationMismatch  = "mismatch"
+	ShiftReconciliationNoZReport = "no_z_report"
+)
+
+// ShiftReconciliation compares the revenue of a shift computed from its
+// closed receipts and their payments with the Z-report (63) or, without one,
+// the shift close (61) figure.
+type ShiftReconciliation struct {
+	SourceFolder       string    `json:"source_folder"`
+	CashRegisterCode   int64     `json:"cash_register_code"`
+	ShiftNumber        int64     `json:"shift_number"`
+	TransactionDate    time.Time `json:"transaction_date"`
+	Status             string    `json:"status"`
+	ZTransactionType   int64     `json:"z_transaction_type,omitempty"` // 63 or 61; 0 without Z data
+	ZTransactionID     int64     `json:"z_transaction_id,omitempty"`
+	ZRevenue           float64   `json:"z_revenue"`
+	ReceiptCount       int       `json:"receipt_count"`
+	SalesAmount        float64   `json:"sales_amount"`
+	ReturnsAmount      float64   `json:"returns_amount"`
+	ItemsRevenue       float64   `json:"items_revenue"`    // sales_amount - returns_amount
+	PaymentsRevenue    float64   `json:"payments_revenue"` // paid_amount of sales minus returns
+	ItemsDifference    float64   `json:"items_difference"`
+	PaymentsDifference float64   `json:"payments_difference"`
+}
+
 // ProcessingStats represents processing statistics
 type ProcessingStats struct {
 	StartTime          time.Time
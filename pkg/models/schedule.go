@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Schedule is a recurring load of the webhook server's built-in scheduler:
+// every time Cron fires it loads the Days days that ended before the firing
+// day, for Kassas or for every kassa when Kassas is empty.
+type Schedule struct {
+	Name   string   `json:"name"`
+	Cron   string   `json:"cron"`
+	Days   int      `json:"days"`
+	Kassas []string `json:"kassas,omitempty"` // kassa codes or source folders
+}
+
+// Dates returns the range loaded by a firing at t: Days=1 is the day before
+// t, Days=3 the three days before it.
+func (s Schedule) Dates(t time.Time) DateRange {
+	return RecentDays(t, s.Days)
+}
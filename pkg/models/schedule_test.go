@@ -0,0 +1,23 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleDates(t *testing.T) {
+	firing := time.Date(2024, 12, 1, 2, 0, 0, 0, time.UTC)
+	tests := []struct {
+		days int
+		want DateRange
+	}{
+		{1, DateRange{From: "2024-11-30", To: "2024-11-30"}},
+		{3, DateRange{From: "2024-11-28", To: "2024-11-30"}},
+		{0, DateRange{From: "2024-11-30", To: "2024-11-30"}},
+	}
+	for _, tt := range tests {
+		if got := (Schedule{Days: tt.days}).Dates(firing); got != tt.want {
+			t.Errorf("Schedule{Days: %d}.Dates() = %+v, want %+v", tt.days, got, tt.want)
+		}
+	}
+}
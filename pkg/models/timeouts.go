@@ -10,11 +10,20 @@ const (
 	DefaultOperationStaleTimeout          = 2 * time.Hour
 	DefaultWebhookReportHTTPTimeout       = 30 * time.Second
 	DefaultWebhookReportResultWaitTimeout = 5 * time.Second
+	DefaultWebhookReportRetryDelay        = 30 * time.Second
+	DefaultWebhookReportMaxAttempts       = 8
 	DefaultHTTPReadHeaderTimeout          = 5 * time.Second
 	DefaultHTTPReadTimeout                = 15 * time.Second
 	DefaultHTTPWriteTimeout               = 30 * time.Second
 	DefaultHTTPIdleTimeout                = 60 * time.Second
 	DefaultShutdownTimeout                = 30 * time.Second
+	DefaultQueuePollInterval              = 2 * time.Second
+	DefaultQueueLeaseTimeout              = 2 * time.Minute
+	DefaultQueueWorkers                   = 4
+	DefaultGapCheckDays                   = 7
+	DefaultResponsePollInterval           = 5 * time.Second
+	DefaultResponsePollMaxBackoff         = time.Minute
+	DefaultConfigReloadInterval           = 10 * time.Second
 )
 
 func (c *Config) EffectiveDBConnectTimeout() time.Duration {
@@ -66,6 +75,20 @@ func (c *Config) EffectiveWebhookReportResultWaitTimeout() time.Duration {
 	return c.WebhookReportResultWaitTimeout
 }
 
+func (c *Config) EffectiveWebhookReportRetryDelay() time.Duration {
+	if c == nil || c.WebhookReportRetryDelay <= 0 {
+		return DefaultWebhookReportRetryDelay
+	}
+	return c.WebhookReportRetryDelay
+}
+
+func (c *Config) EffectiveWebhookReportMaxAttempts() int {
+	if c == nil || c.WebhookReportMaxAttempts <= 0 {
+		return DefaultWebhookReportMaxAttempts
+	}
+	return c.WebhookReportMaxAttempts
+}
+
 func (c *Config) EffectiveHTTPReadHeaderTimeout() time.Duration {
 	if c == nil || c.HTTPReadHeaderTimeout <= 0 {
 		return DefaultHTTPReadHeaderTimeout
@@ -100,3 +123,61 @@ func (c *Config) EffectiveShutdownTimeout() time.Duration {
 	}
 	return c.ShutdownTimeout
 }
+
+func (c *Config) EffectiveQueuePollInterval() time.Duration {
+	if c == nil || c.QueuePollInterval <= 0 {
+		return DefaultQueuePollInterval
+	}
+	return c.QueuePollInterval
+}
+
+func (c *Config) EffectiveQueueLeaseTimeout() time.Duration {
+	if c == nil || c.QueueLeaseTimeout <= 0 {
+		return DefaultQueueLeaseTimeout
+	}
+	return c.QueueLeaseTimeout
+}
+
+func (c *Config) EffectiveQueueWorkers() int {
+	if c == nil || c.QueueWorkers <= 0 {
+		return DefaultQueueWorkers
+	}
+	return c.QueueWorkers
+}
+
+func (c *Config) EffectiveGapCheckDays() int {
+	if c == nil || c.GapCheckDays <= 0 {
+		return DefaultGapCheckDays
+	}
+	return c.GapCheckDays
+}
+
+func (c *Config) EffectiveResponsePollInterval() time.Duration {
+	if c == nil || c.ResponsePollInterval <= 0 {
+		return DefaultResponsePollInterval
+	}
+	return c.ResponsePollInterval
+}
+
+func (c *Config) EffectiveResponsePollMaxBackoff() time.Duration {
+	if c == nil || c.ResponsePollMaxBackoff <= 0 {
+		return DefaultResponsePollMaxBackoff
+	}
+	return c.ResponsePollMaxBackoff
+}
+
+// ResponseDeadline returns how long to wait for the response of a kassa
+// folder: the override of its source folder, then of its kassa code, then
+// WaitDelayMinutes.
+func (c *Config) ResponseDeadline(kassaCode, folderName string) time.Duration {
+	if c == nil {
+		return 0
+	}
+	if deadline, ok := c.KassaResponseDeadlines[kassaCode+"/"+folderName]; ok {
+		return deadline
+	}
+	if deadline, ok := c.KassaResponseDeadlines[kassaCode]; ok {
+		return deadline
+	}
+	return c.WaitDelayMinutes
+}
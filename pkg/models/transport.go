@@ -0,0 +1,62 @@
+package models
+
+// Protocols of the kassa exchange server.
+const (
+	FTPProtocolFTP          = "ftp"           // plain FTP
+	FTPProtocolFTPS         = "ftps"          // FTP upgraded with AUTH TLS (explicit FTPS)
+	FTPProtocolFTPSImplicit = "ftps-implicit" // FTP inside TLS from the first byte, usually port 990
+	FTPProtocolSFTP         = "sftp"          // SSH file transfer
+)
+
+// FTPTransport is the protocol and port a kassa folder is exchanged over.
+// Port 0 means FTP_PORT for the global protocol and the protocol's default
+// port otherwise.
+type FTPTransport struct {
+	Protocol string
+	Port     int
+}
+
+// DefaultFTPPort returns the well-known port of protocol.
+func DefaultFTPPort(protocol string) int {
+	switch protocol {
+	case FTPProtocolFTPSImplicit:
+		return 990
+	case FTPProtocolSFTP:
+		return 22
+	default:
+		return 21
+	}
+}
+
+// ValidFTPProtocol reports whether protocol is a supported exchange protocol.
+func ValidFTPProtocol(protocol string) bool {
+	switch protocol {
+	case FTPProtocolFTP, FTPProtocolFTPS, FTPProtocolFTPSImplicit, FTPProtocolSFTP:
+		return true
+	}
+	return false
+}
+
+// FTPTransportFor returns the transport of a kassa folder: the override of
+// its source folder, then of its kassa code, then FTP_PROTOCOL and FTP_PORT.
+func (c *Config) FTPTransportFor(kassaCode, folderName string) FTPTransport {
+	global := FTPTransport{Protocol: c.FTPProtocol, Port: c.FTPPort}
+	if global.Protocol == "" {
+		global.Protocol = FTPProtocolFTP
+	}
+	transport, ok := c.KassaFTPTransports[kassaCode+"/"+folderName]
+	if !ok {
+		transport, ok = c.KassaFTPTransports[kassaCode]
+	}
+	if !ok {
+		return global
+	}
+	if transport.Port == 0 {
+		if transport.Protocol == global.Protocol {
+			transport.Port = global.Port
+		} else {
+			transport.Port = DefaultFTPPort(transport.Protocol)
+		}
+	}
+	return transport
+}
@@ -941,3 +941,10 @@ type TxBonusPayment82 = TxBonusPayment32
 type TxBonusPayment33 = TxBonusPayment32
 type TxBonusPayment83 = TxBonusPayment32
 type TxPrepayment84 = TxPrepayment34
+
+// TxRecord is a schema-driven row for transaction types registered without a
+// dedicated struct. Values are aligned with the table's TxColumnSpec schema.
+type TxRecord struct {
+	TransactionIDUnique int64
+	Values              []interface{}
+}
@@ -0,0 +1,20 @@
+package models
+
+// DefaultWebhookSubscriber is the name of the subscriber built from
+// WEBHOOK_REPORT_URL and WEBHOOK_BEARER_TOKEN; it receives every report.
+const DefaultWebhookSubscriber = "default"
+
+// WebhookSubscriber receives the webhook reports that match its filters.
+// Empty filters match everything.
+type WebhookSubscriber struct {
+	Name           string   `json:"name"`
+	URL            string   `json:"url"`
+	AuthHeader     string   `json:"auth_header,omitempty"`     // "Name: value", e.g. "Authorization: Bearer token"
+	SigningSecret  string   `json:"signing_secret,omitempty"`  // overrides WEBHOOK_SIGNING_SECRET
+	Statuses       []string `json:"statuses,omitempty"`        // report statuses: completed, partial, failed, timeout, canceled
+	OperationTypes []string `json:"operation_types,omitempty"` // operation types, e.g. load
+	Kassas         []string `json:"kassas,omitempty"`          // kassa codes or source folders
+	Template       string   `json:"template,omitempty"`        // text/template of the body; empty sends the report JSON
+	ContentType    string   `json:"content_type,omitempty"`    // body content type (default: application/json)
+	KassaEvents    bool     `json:"kassa_events,omitempty"`    // also receive per-kassa progress events of running loads
+}
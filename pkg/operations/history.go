@@ -0,0 +1,197 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// unfinishedStatuses are the statuses of operations that may still run.
+var unfinishedStatuses = []string{string(StatusStarted), string(StatusQueued), string(StatusProcessing), string(StatusTimeoutReported)}
+
+// ListFilter selects rows of etl_operation_runs.
+type ListFilter struct {
+	Status        Status
+	OperationType string
+	RequestID     string
+	// Active keeps only operations that are not finished yet.
+	Active bool
+	// Date matches single-day operations of that day and date ranges
+	// ("from..to") that contain it.
+	Date string
+	// SourceFolder matches operations that listed this source folder; full
+	// loads of every kassa have no source folder.
+	SourceFolder string
+	// Limit defaults to 100 and is capped at 1000.
+	Limit  int
+	Offset int
+}
+
+// ListResult is one page of operations, newest first.
+type ListResult struct {
+	Operations []Record
+	Total      int
+	Limit      int
+	Offset     int
+}
+
+const recordColumns = `operation_id, COALESCE(request_id, ''), operation_type, status, COALESCE(date, ''),
+	COALESCE(source_folder, ''), component, instance_id, started_at, updated_at, finished_at,
+	COALESCE(error_message, ''), COALESCE(failed_stage, ''), timeout_report_sent, crash_suspected, result`
+
+// Get returns the operation with operationID, or nil when there is none.
+func (s *Store) Get(ctx context.Context, operationID string) (*Record, error) {
+	pool, err := s.poolOrErr(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := pool.Query(ctx, `SELECT `+recordColumns+` FROM etl_operation_runs WHERE operation_id = $1`, operationID)
+	if err != nil {
+		return nil, fmt.Errorf("query operation run: %w", err)
+	}
+	records, err := scanRecords(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return &records[0], nil
+}
+
+// List returns the page of operations matching filter and the number of all
+// matching operations.
+func (s *Store) List(ctx context.Context, filter ListFilter) (*ListResult, error) {
+	pool, err := s.poolOrErr(ctx)
+	if err != nil {
+		return nil, err
+	}
+	where, args := buildListConditions(filter)
+	limit, offset := listPage(filter)
+
+	countRows, err := pool.Query(ctx, `SELECT COUNT(*) FROM etl_operation_runs`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("count operation runs: %w", err)
+	}
+	total := 0
+	for countRows.Next() {
+		if err := countRows.Scan(&total); err != nil {
+			countRows.Close()
+			return nil, fmt.Errorf("scan operation run count: %w", err)
+		}
+	}
+	countRows.Close()
+	if countRows.Err() != nil {
+		return nil, fmt.Errorf("count operation runs: %w", countRows.Err())
+	}
+
+	pageArgs := append(append(make([]any, 0, len(args)+2), args...), limit, offset)
+	query := fmt.Sprintf(`SELECT %s FROM etl_operation_runs%s ORDER BY started_at DESC, operation_id LIMIT $%d OFFSET $%d`,
+		recordColumns, where, len(args)+1, len(args)+2)
+	rows, err := pool.Query(ctx, query, pageArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("query operation runs: %w", err)
+	}
+	records, err := scanRecords(rows)
+	if err != nil {
+		return nil, err
+	}
+	return &ListResult{Operations: records, Total: total, Limit: limit, Offset: offset}, nil
+}
+
+// buildListConditions returns the WHERE clause (empty without filters) and
+// its arguments.
+func buildListConditions(filter ListFilter) (string, []any) {
+	conditions := make([]string, 0, 4)
+	args := make([]any, 0, 4)
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.OperationType != "" {
+		args = append(args, filter.OperationType)
+		conditions = append(conditions, fmt.Sprintf("operation_type = $%d", len(args)))
+	}
+	if filter.RequestID != "" {
+		args = append(args, filter.RequestID)
+		conditions = append(conditions, fmt.Sprintf("request_id = $%d", len(args)))
+	}
+	if filter.Active {
+		args = append(args, unfinishedStatuses)
+		conditions = append(conditions, fmt.Sprintf("status = ANY($%d)", len(args)))
+	}
+	if filter.Date != "" {
+		args = append(args, filter.Date)
+		n := len(args)
+		conditions = append(conditions, fmt.Sprintf(
+			"(date = $%d OR (date LIKE '%%..%%' AND split_part(date, '..', 1) <= $%d AND split_part(date, '..', 2) >= $%d))", n, n, n))
+	}
+	if filter.SourceFolder != "" {
+		args = append(args, filter.SourceFolder)
+		conditions = append(conditions, fmt.Sprintf("$%d = ANY(string_to_array(source_folder, ','))", len(args)))
+	}
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func listPage(filter ListFilter) (int, int) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+	offset := filter.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+func scanRecords(rows pgx.Rows) ([]Record, error) {
+	defer rows.Close()
+
+	records := make([]Record, 0)
+	for rows.Next() {
+		var record Record
+		var result []byte
+		if err := rows.Scan(
+			&record.OperationID,
+			&record.RequestID,
+			&record.OperationType,
+			&record.Status,
+			&record.Date,
+			&record.SourceFolder,
+			&record.Component,
+			&record.InstanceID,
+			&record.StartedAt,
+			&record.UpdatedAt,
+			&record.FinishedAt,
+			&record.ErrorMessage,
+			&record.FailedStage,
+			&record.TimeoutReportSent,
+			&record.CrashSuspected,
+			&result,
+		); err != nil {
+			return nil, fmt.Errorf("scan operation run: %w", err)
+		}
+		if len(result) > 0 {
+			record.Result = result
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate operation runs: %w", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,55 @@
+package operations
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildListConditions(t *testing.T) {
+	where, args := buildListConditions(ListFilter{Status: StatusFailed, OperationType: "load", Date: "2024-12-02", SourceFolder: "P13/P13"})
+	for _, part := range []string{
+		"status = $1",
+		"operation_type = $2",
+		"(date = $3 OR (date LIKE '%..%' AND split_part(date, '..', 1) <= $3 AND split_part(date, '..', 2) >= $3))",
+		"$4 = ANY(string_to_array(source_folder, ','))",
+	} {
+		if !strings.Contains(where, part) {
+			t.Fatalf("where = %q, want to contain %q", where, part)
+		}
+	}
+	if !reflect.DeepEqual(args, []any{StatusFailed, "load", "2024-12-02", "P13/P13"}) {
+		t.Fatalf("args = %#v", args)
+	}
+
+	where, args = buildListConditions(ListFilter{RequestID: "schedule_nightly", Active: true})
+	if where != " WHERE request_id = $1 AND status = ANY($2)" {
+		t.Fatalf("where = %q, want request_id and active status conditions", where)
+	}
+	if !reflect.DeepEqual(args, []any{"schedule_nightly", unfinishedStatuses}) {
+		t.Fatalf("args = %#v", args)
+	}
+
+	where, args = buildListConditions(ListFilter{})
+	if where != "" || len(args) != 0 {
+		t.Fatalf("where = %q, args = %#v, want no conditions", where, args)
+	}
+}
+
+func TestListPageDefaultsAndCaps(t *testing.T) {
+	tests := []struct {
+		filter     ListFilter
+		wantLimit  int
+		wantOffset int
+	}{
+		{ListFilter{}, defaultListLimit, 0},
+		{ListFilter{Limit: 5000, Offset: 20}, maxListLimit, 20},
+		{ListFilter{Limit: 10, Offset: -1}, 10, 0},
+	}
+	for _, tt := range tests {
+		limit, offset := listPage(tt.filter)
+		if limit != tt.wantLimit || offset != tt.wantOffset {
+			t.Fatalf("listPage(%+v) = %d, %d; want %d, %d", tt.filter, limit, offset, tt.wantLimit, tt.wantOffset)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ReportStatus is the delivery state of a webhook report in etl_webhook_outbox.
+type ReportStatus string
+
+const (
+	ReportPending   ReportStatus = "pending"
+	ReportDelivered ReportStatus = "delivered"
+	ReportDead      ReportStatus = "dead" // attempts exhausted; waits for a replay
+)
+
+// OutboxReport is a webhook report stored for delivery. Reports survive
+// restarts and are retried until delivered or dead-lettered.
+type OutboxReport struct {
+	ID             int64           `json:"id"`
+	OperationID    string          `json:"operation_id"`
+	RequestID      string          `json:"request_id,omitempty"`
+	Subscriber     string          `json:"subscriber"` // name of the subscriber the report is addressed to
+	URL            string          `json:"url"`
+	Payload        json.RawMessage `json:"payload"`
+	TraceParent    string          `json:"-"`
+	Status         ReportStatus    `json:"status"`
+	Attempts       int             `json:"attempts"` // delivery attempts so far, including a claimed one
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// ReportFilter selects rows of etl_webhook_outbox.
+type ReportFilter struct {
+	Status      ReportStatus
+	OperationID string
+	Subscriber  string
+	// Limit defaults to 100 and is capped at 1000.
+	Limit  int
+	Offset int
+}
+
+// ReportList is one page of outbox reports, newest first.
+type ReportList struct {
+	Reports []OutboxReport
+	Total   int
+	Limit   int
+	Offset  int
+}
+
+const reportColumns = `id, operation_id, COALESCE(request_id, ''), subscriber, url, payload, COALESCE(trace_parent, ''), status,
+	attempts, next_attempt_at, COALESCE(last_status_code, 0), COALESCE(last_error, ''), created_at, updated_at, delivered_at`
+
+// EnqueueReport stores a report for delivery and returns its id.
+func (s *Store) EnqueueReport(ctx context.Context, report OutboxReport) (int64, error) {
+	if report.OperationID == "" {
+		return 0, fmt.Errorf("operation_id is required")
+	}
+	if report.URL == "" {
+		return 0, fmt.Errorf("url is required")
+	}
+	if report.Subscriber == "" {
+		return 0, fmt.Errorf("subscriber is required")
+	}
+	pool, err := s.poolOrErr(ctx)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := pool.Query(ctx, `
+		INSERT INTO etl_webhook_outbox (
+			operation_id,
+			request_id,
+			subscriber,
+			url,
+			payload,
+			trace_parent,
+			status
+		) VALUES ($1,NULLIF($2, ''),$3,$4,$5,NULLIF($6, ''),$7)
+		RETURNING id
+	`,
+		report.OperationID,
+		report.RequestID,
+		report.Subscriber,
+		report.URL,
+		[]byte(report.Payload),
+		report.TraceParent,
+		ReportPending,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("enqueue webhook report: %w", err)
+	}
+	defer rows.Close()
+	var id int64
+	for rows.Next() {
+		if err := rows.Scan(&id); err != nil {
+			return 0, fmt.Errorf("scan webhook report id: %w", err)
+		}
+	}
+	if rows.Err() != nil {
+		return 0, fmt.Errorf("enqueue webhook report: %w", rows.Err())
+	}
+	return id, nil
+}
+
+// ClaimReport takes the pending report that is due first and counts a
+// delivery attempt. The report is leased for lease: if the instance dies
+// mid-delivery, another instance retries it after the lease. Reports of one
+// operation to one subscriber are delivered in the order they were stored. It
+// returns nil when no report is due.
+func (s *Store) ClaimReport(ctx context.Context, lease time.Duration) (*OutboxReport, error) {
+	pool, err := s.poolOrErr(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := pool.Query(ctx, `
+		UPDATE etl_webhook_outbox r
+		SET attempts = r.attempts + 1,
+		    next_attempt_at = NOW() + $2 * INTERVAL '1 second',
+		    updated_at = NOW()
+		WHERE r.id = (
+			SELECT c.id
+			FROM etl_webhook_outbox c
+			WHERE c.status = $1
+			  AND c.next_attempt_at <= NOW()
+			  AND NOT EXISTS (
+				SELECT 1
+				FROM etl_webhook_outbox e
+				WHERE e.status = $1
+				  AND e.operation_id = c.operation_id
+				  AND e.subscriber = c.subscriber
+				  AND e.id < c.id
+			  )
+			ORDER BY c.next_attempt_at, c.id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+reportColumns, ReportPending, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook report: %w", err)
+	}
+	reports, err := scanReports(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(reports) == 0 {
+		return nil, nil
+	}
+	return &reports[0], nil
+}
+
+// MarkReportDelivered records a successful delivery.
+func (s *Store) MarkReportDelivered(ctx context.Context, id int64, statusCode int) error {
+	pool, err := s.poolOrErr(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := pool.Exec(ctx, `
+		UPDATE etl_webhook_outbox
+		SET status = $2,
+		    last_status_code = $3,
+		    last_error = NULL,
+		    delivered_at = NOW(),
+		    updated_at = NOW()
+		WHERE id = $1
+	`, id, ReportDelivered, statusCode); err != nil {
+		return fmt.Errorf("mark webhook report delivered: %w", err)
+	}
+	return nil
+}
+
+// RetryReport records a failed delivery and schedules the next attempt
+// after delay. statusCode is 0 when no response was received.
+func (s *Store) RetryReport(ctx context.Context, id int64, delay time.Duration, statusCode int, errMsg string) error {
+	pool, err := s.poolOrErr(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := pool.Exec(ctx, `
+		UPDATE etl_webhook_outbox
+		SET next_attempt_at = NOW() + $2 * INTERVAL '1 second',
+		    last_status_code = NULLIF($3, 0),
+		    last_error = $4,
+		    updated_at = NOW()
+		WHERE id = $1
+	`, id, delay.Seconds(), statusCode, errMsg); err != nil {
+		return fmt.Errorf("schedule webhook report retry: %w", err)
+	}
+	return nil
+}
+
+// DeadLetterReport records a failed last delivery attempt and moves the
+// report to the dead-letter state.
+func (s *Store) DeadLetterReport(ctx context.Context, id int64, statusCode int, errMsg string) error {
+	pool, err := s.poolOrErr(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := pool.Exec(ctx, `
+		UPDATE etl_webhook_outbox
+		SET status = $2,
+		    last_status_code = NULLIF($3, 0),
+		    last_error = $4,
+		    updated_at = NOW()
+		WHERE id = $1
+	`, id, ReportDead, statusCode, errMsg); err != nil {
+		return fmt.Errorf("dead-letter webhook report: %w", err)
+	}
+	return nil
+}
+
+// ReplayReports returns dead-lettered reports to delivery with a fresh set of
+// attempts: the reports with ids, or every dead report when ids is empty. It
+// returns the number of reports replayed; ids of reports that are not dead
+// are ignored.
+func (s *Store) ReplayReports(ctx context.Context, ids []int64) (int, error) {
+	pool, err := s.poolOrErr(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if ids == nil {
+		ids = []int64{}
+	}
+	tag, err := pool.Exec(ctx, `
+		UPDATE etl_webhook_outbox
+		SET status = $1,
+		    attempts = 0,
+		    next_attempt_at = NOW(),
+		    updated_at = NOW()
+		WHERE status = $2 AND (cardinality($3::BIGINT[]) = 0 OR id = ANY($3))
+	`, ReportPending, ReportDead, ids)
+	if err != nil {
+		return 0, fmt.Errorf("replay webhook reports: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// ListReports returns the page of outbox reports matching filter and the
+// number of all matching reports.
+func (s *Store) ListReports(ctx context.Context, filter ReportFilter) (*ReportList, error) {
+	pool, err := s.poolOrErr(ctx)
+	if err != nil {
+		return nil, err
+	}
+	where, args := buildReportConditions(filter)
+	limit, offset := listPage(ListFilter{Limit: filter.Limit, Offset: filter.Offset})
+
+	countRows, err := pool.Query(ctx, `SELECT COUNT(*) FROM etl_webhook_outbox`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("count webhook reports: %w", err)
+	}
+	total := 0
+	for countRows.Next() {
+		if err := countRows.Scan(&total); err != nil {
+			countRows.Close()
+			return nil, fmt.Errorf("scan webhook report count: %w", err)
+		}
+	}
+	countRows.Close()
+	if countRows.Err() != nil {
+		return nil, fmt.Errorf("count webhook reports: %w", countRows.Err())
+	}
+
+	pageArgs := append(append(make([]any, 0, len(args)+2), args...), limit, offset)
+	query := fmt.Sprintf(`SELECT %s FROM etl_webhook_outbox%s ORDER BY id DESC LIMIT $%d OFFSET $%d`,
+		reportColumns, where, len(args)+1, len(args)+2)
+	rows, err := pool.Query(ctx, query, pageArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("query webhook reports: %w", err)
+	}
+	reports, err := scanReports(rows)
+	if err != nil {
+		return nil, err
+	}
+	return &ReportList{Reports: reports, Total: total, Limit: limit, Offset: offset}, nil
+}
+
+// buildReportConditions returns the WHERE clause (empty without filters) and
+// its arguments.
+func buildReportConditions(filter ReportFilter) (string, []any) {
+	conditions := make([]string, 0, 3)
+	args := make([]any, 0, 3)
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.OperationID != "" {
+		args = append(args, filter.OperationID)
+		conditions = append(conditions, fmt.Sprintf("operation_id = $%d", len(args)))
+	}
+	if filter.Subscriber != "" {
+		args = append(args, filter.Subscriber)
+		conditions = append(conditions, fmt.Sprintf("subscriber = $%d", len(args)))
+	}
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func scanReports(rows pgx.Rows) ([]OutboxReport, error) {
+	defer rows.Close()
+
+	reports := make([]OutboxReport, 0)
+	for rows.Next() {
+		var report OutboxReport
+		var payload []byte
+		if err := rows.Scan(
+			&report.ID,
+			&report.OperationID,
+			&report.RequestID,
+			&report.Subscriber,
+			&report.URL,
+			&payload,
+			&report.TraceParent,
+			&report.Status,
+			&report.Attempts,
+			&report.NextAttemptAt,
+			&report.LastStatusCode,
+			&report.LastError,
+			&report.CreatedAt,
+			&report.UpdatedAt,
+			&report.DeliveredAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan webhook report: %w", err)
+		}
+		report.Payload = payload
+		reports = append(reports, report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate webhook reports: %w", err)
+	}
+	return reports, nil
+}
@@ -0,0 +1,74 @@
+package operations
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStoreEnqueueReportRequiresOperationURLAndSubscriber(t *testing.T) {
+	exec := &stubExecutor{rows: &stubRows{}}
+	store := newTestStore(t, exec)
+	for _, report := range []OutboxReport{
+		{URL: "http://receiver/reports", Subscriber: "default"},
+		{OperationID: "op_1", Subscriber: "default"},
+		{OperationID: "op_1", URL: "http://receiver/reports"},
+	} {
+		if _, err := store.EnqueueReport(context.Background(), report); err == nil {
+			t.Fatalf("EnqueueReport(%+v) expected error, got nil", report)
+		}
+	}
+	if exec.queryCalls != 0 {
+		t.Fatalf("queryCalls = %d, want 0", exec.queryCalls)
+	}
+}
+
+func TestStoreRetryAndDeadLetterReport(t *testing.T) {
+	exec := &stubExecutor{}
+	store := newTestStore(t, exec)
+
+	if err := store.RetryReport(context.Background(), 7, 2*time.Minute, 503, "status 503"); err != nil {
+		t.Fatalf("RetryReport() error = %v", err)
+	}
+	if !reflect.DeepEqual(exec.execArgs, []any{int64(7), float64(120), 503, "status 503"}) {
+		t.Fatalf("RetryReport() args = %#v", exec.execArgs)
+	}
+
+	if err := store.DeadLetterReport(context.Background(), 7, 0, "connection refused"); err != nil {
+		t.Fatalf("DeadLetterReport() error = %v", err)
+	}
+	if !reflect.DeepEqual(exec.execArgs, []any{int64(7), ReportDead, 0, "connection refused"}) {
+		t.Fatalf("DeadLetterReport() args = %#v", exec.execArgs)
+	}
+}
+
+func TestStoreReplayReportsWithoutIDsReplaysAllDead(t *testing.T) {
+	exec := &stubExecutor{}
+	store := newTestStore(t, exec)
+	if _, err := store.ReplayReports(context.Background(), nil); err != nil {
+		t.Fatalf("ReplayReports() error = %v", err)
+	}
+	// An empty array, not NULL, selects every dead report
+	if ids, ok := exec.execArgs[2].([]int64); !ok || len(ids) != 0 {
+		t.Fatalf("ids argument = %#v, want empty slice", exec.execArgs[2])
+	}
+	if exec.execArgs[0] != ReportPending || exec.execArgs[1] != ReportDead {
+		t.Fatalf("status arguments = %v, %v; want pending, dead", exec.execArgs[0], exec.execArgs[1])
+	}
+}
+
+func TestBuildReportConditions(t *testing.T) {
+	where, args := buildReportConditions(ReportFilter{Status: ReportDead, OperationID: "op_1", Subscriber: "bi"})
+	if where != " WHERE status = $1 AND operation_id = $2 AND subscriber = $3" {
+		t.Fatalf("where = %q", where)
+	}
+	if !reflect.DeepEqual(args, []any{ReportDead, "op_1", "bi"}) {
+		t.Fatalf("args = %#v", args)
+	}
+
+	where, args = buildReportConditions(ReportFilter{})
+	if where != "" || len(args) != 0 {
+		t.Fatalf("where = %q, args = %#v, want no conditions", where, args)
+	}
+}
@@ -0,0 +1,278 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// queueClaimLockKey is the transaction-level advisory lock that serializes
+// claims across webhook-server replicas. Claims are short, and serializing
+// them lets each claim see every job the previous claim took, so two
+// replicas never start overlapping loads at the same time.
+const queueClaimLockKey int64 = 0x65746c5f71756575 // "etl_queu"
+
+// Job is an operation waiting in or claimed from etl_operation_queue.
+type Job struct {
+	OperationID   string
+	RequestID     string
+	OperationType string
+	Date          string
+	DateTo        string
+	Kassas        []string // source folders; empty means every kassa
+	EnqueuedAt    time.Time
+	Attempts      int // claims so far, including the current one
+	// CancelRequested is set when the job was canceled while queued or by a
+	// dead instance; the worker that claims it finishes it as canceled.
+	CancelRequested bool
+	// TraceParent is the W3C traceparent of the request that enqueued the
+	// job; the worker continues that trace.
+	TraceParent string
+}
+
+// QueueStats counts queue rows per operation type.
+type QueueStats struct {
+	Queued     map[string]int
+	Processing map[string]int
+}
+
+// Enqueue stores job in the durable queue. The job stays there until a
+// worker completes it, so it survives restarts of the webhook server.
+func (s *Store) Enqueue(ctx context.Context, job Job) error {
+	if job.OperationID == "" {
+		return fmt.Errorf("operation_id is required")
+	}
+	pool, err := s.poolOrErr(ctx)
+	if err != nil {
+		return err
+	}
+	if job.EnqueuedAt.IsZero() {
+		job.EnqueuedAt = time.Now()
+	}
+	kassas := job.Kassas
+	if kassas == nil {
+		kassas = []string{}
+	}
+	_, err = pool.Exec(ctx, `
+		INSERT INTO etl_operation_queue (
+			operation_id,
+			request_id,
+			operation_type,
+			date,
+			date_to,
+			kassas,
+			status,
+			enqueued_at,
+			trace_parent
+		) VALUES ($1,$2,$3,$4,NULLIF($5, ''),$6,$7,$8,NULLIF($9, ''))
+	`,
+		job.OperationID,
+		job.RequestID,
+		job.OperationType,
+		job.Date,
+		job.DateTo,
+		kassas,
+		StatusQueued,
+		job.EnqueuedAt,
+		job.TraceParent,
+	)
+	if err != nil {
+		return fmt.Errorf("enqueue operation: %w", err)
+	}
+	return nil
+}
+
+// Claim takes the oldest job that may run now and marks it processing for
+// this instance. It returns nil when nothing can run.
+//
+// A job may run when it is queued, or processing with a heartbeat older than
+// leaseTimeout (its instance died). It waits while an overlapping job of the
+// same operation type is running or was enqueued earlier: jobs overlap when
+// they share a kassa or one of them targets every kassa. Jobs flagged for
+// cancellation never wait and are claimed first.
+func (s *Store) Claim(ctx context.Context, leaseTimeout time.Duration) (*Job, error) {
+	pool, err := s.poolOrErr(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := pool.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin claim transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, queueClaimLockKey); err != nil {
+		return nil, fmt.Errorf("lock operation queue: %w", err)
+	}
+
+	var job Job
+	var dateTo *string
+	err = tx.QueryRow(ctx, `
+		UPDATE etl_operation_queue q
+		SET status = $1,
+		    locked_by = $2,
+		    locked_at = NOW(),
+		    heartbeat_at = NOW(),
+		    attempts = q.attempts + 1
+		WHERE q.operation_id = (
+			SELECT c.operation_id
+			FROM etl_operation_queue c
+			WHERE (c.status = $3 OR c.heartbeat_at < NOW() - $4 * INTERVAL '1 second')
+			  AND (c.cancel_requested OR NOT EXISTS (
+				SELECT 1
+				FROM etl_operation_queue o
+				WHERE o.operation_id <> c.operation_id
+				  AND o.operation_type = c.operation_type
+				  AND (cardinality(o.kassas) = 0 OR cardinality(c.kassas) = 0 OR o.kassas && c.kassas)
+				  AND (
+					(o.status = $1 AND o.heartbeat_at >= NOW() - $4 * INTERVAL '1 second')
+					OR (o.enqueued_at, o.operation_id) < (c.enqueued_at, c.operation_id)
+				  )
+			  ))
+			ORDER BY c.cancel_requested DESC, c.enqueued_at, c.operation_id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING q.operation_id, COALESCE(q.request_id, ''), q.operation_type, q.date, q.date_to, q.kassas, q.enqueued_at, q.attempts, q.cancel_requested, COALESCE(q.trace_parent, '')
+	`, StatusProcessing, s.instanceID, StatusQueued, leaseTimeout.Seconds()).Scan(
+		&job.OperationID,
+		&job.RequestID,
+		&job.OperationType,
+		&job.Date,
+		&dateTo,
+		&job.Kassas,
+		&job.EnqueuedAt,
+		&job.Attempts,
+		&job.CancelRequested,
+		&job.TraceParent,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("claim operation: %w", err)
+	}
+	if dateTo != nil {
+		job.DateTo = *dateTo
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit claim transaction: %w", err)
+	}
+	return &job, nil
+}
+
+// Heartbeat extends the lease of a job claimed by this instance. It reports
+// whether the job is still held by this instance and whether its
+// cancellation was requested.
+func (s *Store) Heartbeat(ctx context.Context, operationID string) (bool, bool, error) {
+	pool, err := s.poolOrErr(ctx)
+	if err != nil {
+		return false, false, err
+	}
+	rows, err := pool.Query(ctx, `
+		UPDATE etl_operation_queue
+		SET heartbeat_at = NOW()
+		WHERE operation_id = $1 AND status = $2 AND locked_by = $3
+		RETURNING cancel_requested
+	`, operationID, StatusProcessing, s.instanceID)
+	if err != nil {
+		return false, false, fmt.Errorf("heartbeat operation: %w", err)
+	}
+	defer rows.Close()
+	held, cancelRequested := false, false
+	for rows.Next() {
+		held = true
+		if err := rows.Scan(&cancelRequested); err != nil {
+			return false, false, fmt.Errorf("scan heartbeat: %w", err)
+		}
+	}
+	if rows.Err() != nil {
+		return false, false, fmt.Errorf("heartbeat operation: %w", rows.Err())
+	}
+	return held, cancelRequested, nil
+}
+
+// RequestCancel flags a job for cancellation and returns its queue status,
+// or "" when the job is not in the queue. The instance running the job sees
+// the flag on its next heartbeat; a queued job is claimed ahead of the others
+// and finished as canceled without running.
+func (s *Store) RequestCancel(ctx context.Context, operationID string) (Status, error) {
+	pool, err := s.poolOrErr(ctx)
+	if err != nil {
+		return "", err
+	}
+	rows, err := pool.Query(ctx, `
+		UPDATE etl_operation_queue
+		SET cancel_requested = TRUE
+		WHERE operation_id = $1
+		RETURNING status
+	`, operationID)
+	if err != nil {
+		return "", fmt.Errorf("request operation cancel: %w", err)
+	}
+	defer rows.Close()
+	var status Status
+	for rows.Next() {
+		if err := rows.Scan(&status); err != nil {
+			return "", fmt.Errorf("scan operation queue status: %w", err)
+		}
+	}
+	if rows.Err() != nil {
+		return "", fmt.Errorf("request operation cancel: %w", rows.Err())
+	}
+	return status, nil
+}
+
+// Complete removes a finished job claimed by this instance from the queue.
+func (s *Store) Complete(ctx context.Context, operationID string) error {
+	pool, err := s.poolOrErr(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := pool.Exec(ctx, `
+		DELETE FROM etl_operation_queue
+		WHERE operation_id = $1 AND locked_by = $2
+	`, operationID, s.instanceID); err != nil {
+		return fmt.Errorf("complete operation: %w", err)
+	}
+	return nil
+}
+
+// QueueStats counts queued and processing jobs per operation type.
+func (s *Store) QueueStats(ctx context.Context) (QueueStats, error) {
+	stats := QueueStats{Queued: map[string]int{}, Processing: map[string]int{}}
+	pool, err := s.poolOrErr(ctx)
+	if err != nil {
+		return stats, err
+	}
+	rows, err := pool.Query(ctx, `
+		SELECT operation_type, status, COUNT(*)
+		FROM etl_operation_queue
+		GROUP BY operation_type, status
+	`)
+	if err != nil {
+		return stats, fmt.Errorf("query operation queue stats: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var operationType, status string
+		var count int
+		if err := rows.Scan(&operationType, &status, &count); err != nil {
+			return stats, fmt.Errorf("scan operation queue stats: %w", err)
+		}
+		if Status(status) == StatusProcessing {
+			stats.Processing[operationType] += count
+		} else {
+			stats.Queued[operationType] += count
+		}
+	}
+	if rows.Err() != nil {
+		return stats, fmt.Errorf("iterate operation queue stats: %w", rows.Err())
+	}
+	return stats, nil
+}
@@ -0,0 +1,94 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStoreEnqueueStoresJob(t *testing.T) {
+	exec := &stubExecutor{}
+	store := newTestStore(t, exec)
+	err := store.Enqueue(context.Background(), Job{OperationID: "op_1", RequestID: "req_1", OperationType: "load", Date: "2024-12-01"})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if exec.execCalls != 1 {
+		t.Fatalf("execCalls = %d, want 1", exec.execCalls)
+	}
+	// A load of every kassa is stored as an empty array, not NULL
+	if kassas, ok := exec.execArgs[5].([]string); !ok || !reflect.DeepEqual(kassas, []string{}) {
+		t.Fatalf("kassas argument = %#v, want empty slice", exec.execArgs[5])
+	}
+	if status := exec.execArgs[6]; status != StatusQueued {
+		t.Fatalf("status argument = %v, want %s", status, StatusQueued)
+	}
+	if traceParent := exec.execArgs[8]; traceParent != "" {
+		t.Fatalf("trace_parent argument = %#v, want empty string stored as NULL", traceParent)
+	}
+}
+
+func TestStoreEnqueueRequiresOperationID(t *testing.T) {
+	exec := &stubExecutor{}
+	store := newTestStore(t, exec)
+	if err := store.Enqueue(context.Background(), Job{OperationType: "load"}); err == nil {
+		t.Fatal("Enqueue() expected error, got nil")
+	}
+	if exec.execCalls != 0 {
+		t.Fatalf("execCalls = %d, want 0", exec.execCalls)
+	}
+}
+
+func TestStoreClaimReturnsBeginError(t *testing.T) {
+	exec := &stubExecutor{beginErr: errors.New("boom")}
+	store := newTestStore(t, exec)
+	job, err := store.Claim(context.Background(), time.Minute)
+	if err == nil {
+		t.Fatal("Claim() expected error, got nil")
+	}
+	if job != nil {
+		t.Fatalf("Claim() job = %+v, want nil", job)
+	}
+}
+
+func TestStoreCompleteDeletesOwnJob(t *testing.T) {
+	exec := &stubExecutor{}
+	store := newTestStore(t, exec)
+	store.instanceID = "replica-1"
+	if err := store.Complete(context.Background(), "op_1"); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if !reflect.DeepEqual(exec.execArgs, []any{"op_1", "replica-1"}) {
+		t.Fatalf("execArgs = %v, want [op_1 replica-1]", exec.execArgs)
+	}
+}
+
+func TestStoreRequestCancelReturnsEmptyStatusForUnknownJob(t *testing.T) {
+	exec := &stubExecutor{rows: &stubRows{}}
+	store := newTestStore(t, exec)
+	status, err := store.RequestCancel(context.Background(), "op_missing")
+	if err != nil {
+		t.Fatalf("RequestCancel() error = %v", err)
+	}
+	if status != "" {
+		t.Fatalf("RequestCancel() status = %q, want empty", status)
+	}
+}
+
+func TestStatusFinished(t *testing.T) {
+	for status, want := range map[Status]bool{
+		StatusQueued:          false,
+		StatusProcessing:      false,
+		StatusTimeoutReported: false,
+		StatusCompleted:       true,
+		StatusFailed:          true,
+		StatusCanceled:        true,
+		StatusSkipped:         true,
+	} {
+		if got := status.Finished(); got != want {
+			t.Errorf("%s.Finished() = %v, want %v", status, got, want)
+		}
+	}
+}
@@ -2,6 +2,10 @@ package operations
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"sync"
@@ -25,61 +29,104 @@ const (
 	StatusFailed          Status = "failed"
 	StatusTimeoutReported Status = "timeout_reported"
 	StatusAbandoned       Status = "abandoned"
+	StatusCanceled        Status = "canceled"
+	StatusSkipped         Status = "skipped" // scheduler firing skipped because the previous run was unfinished
 )
 
+// Finished reports whether an operation in this status is over and can no
+// longer be canceled.
+func (s Status) Finished() bool {
+	switch s {
+	case StatusCompleted, StatusPartial, StatusFailed, StatusAbandoned, StatusCanceled, StatusSkipped:
+		return true
+	}
+	return false
+}
+
 type executor interface {
 	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
 	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	BeginTx(ctx context.Context) (pgx.Tx, error)
 	Close()
 }
 
-type opener func(cfg *models.Config) (executor, error)
+type opener func(ctx context.Context, cfg *models.Config) (executor, error)
+
+// errNoStore is returned by a nil Store
+var errNoStore = errors.New("operation store is not configured")
+
+// reopenInterval is how long the store waits after a failed connection
+// attempt before trying again; the durable queue must recover from a
+// database that was unavailable at boot.
+const reopenInterval = 30 * time.Second
 
 type Store struct {
 	cfg        *models.Config
 	log        *logger.Logger
 	instanceID string
 
-	once    sync.Once
-	pool    executor
-	poolErr error
-	openFn  opener
+	mu        sync.Mutex
+	pool      executor
+	poolErr   error
+	poolErrAt time.Time
+	openFn    opener
 }
 
 type Record struct {
-	OperationID       string
-	RequestID         string
-	OperationType     string
-	Status            Status
-	Date              string
-	SourceFolder      string
-	Component         string
-	StartedAt         time.Time
-	UpdatedAt         time.Time
-	FinishedAt        *time.Time
-	ErrorMessage      string
-	FailedStage       string
-	TimeoutReportSent bool
-	CrashSuspected    bool
+	OperationID       string          `json:"operation_id"`
+	RequestID         string          `json:"request_id,omitempty"`
+	OperationType     string          `json:"operation_type"`
+	Status            Status          `json:"status"`
+	Date              string          `json:"date,omitempty"`
+	SourceFolder      string          `json:"source_folder,omitempty"`
+	Component         string          `json:"component"`             // who created the operation; updates keep it
+	InstanceID        string          `json:"instance_id,omitempty"` // read only: writes use the store's instance
+	StartedAt         time.Time       `json:"started_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+	FinishedAt        *time.Time      `json:"finished_at,omitempty"`
+	ErrorMessage      string          `json:"error_message,omitempty"`
+	FailedStage       string          `json:"failed_stage,omitempty"`
+	TimeoutReportSent bool            `json:"timeout_report_sent"`
+	CrashSuspected    bool            `json:"crash_suspected"`
+	Result            json.RawMessage `json:"result,omitempty"` // pipeline result summary of a finished run
 }
 
 func NewStore(cfg *models.Config, log *logger.Logger) *Store {
-	instanceID, err := os.Hostname()
-	if err != nil || instanceID == "" {
-		instanceID = "unknown"
-	}
 	return &Store{
 		cfg:        cfg,
 		log:        log.WithComponent("operation-store"),
-		instanceID: instanceID,
-		openFn: func(cfg *models.Config) (executor, error) {
-			return db.NewPool(cfg)
+		instanceID: newInstanceID(),
+		openFn: func(ctx context.Context, cfg *models.Config) (executor, error) {
+			pool, err := db.NewPoolContext(ctx, cfg)
+			if err != nil {
+				// Do not wrap a nil *db.Pool into a non-nil executor
+				return nil, err
+			}
+			return pool, nil
 		},
 	}
 }
 
+// newInstanceID identifies this process in queue locks. The hostname keeps it
+// readable; the pid and a random suffix keep replicas that share a hostname,
+// and a restarted process, apart.
+func newInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
 func (s *Store) Close() {
-	if s == nil || s.pool == nil {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pool == nil {
 		return
 	}
 	s.pool.Close()
@@ -98,6 +145,67 @@ func (s *Store) Start(ctx context.Context, record Record) error {
 	return s.upsert(ctx, record)
 }
 
+// Create inserts record unless an operation with its ID already exists and
+// reports whether it did. Replicas use it to agree on who starts an
+// operation with a deterministic ID, such as a scheduler firing.
+func (s *Store) Create(ctx context.Context, record Record) (bool, error) {
+	if record.OperationID == "" {
+		return false, fmt.Errorf("operation_id is required")
+	}
+	pool, err := s.poolOrErr(ctx)
+	if err != nil {
+		return false, err
+	}
+	if record.StartedAt.IsZero() {
+		record.StartedAt = time.Now()
+	}
+	if record.UpdatedAt.IsZero() {
+		record.UpdatedAt = record.StartedAt
+	}
+	if record.Status == "" {
+		record.Status = StatusStarted
+	}
+	if record.Component == "" {
+		record.Component = "etl"
+	}
+	tag, err := pool.Exec(ctx, `
+		INSERT INTO etl_operation_runs (
+			operation_id,
+			request_id,
+			operation_type,
+			status,
+			date,
+			source_folder,
+			component,
+			instance_id,
+			started_at,
+			updated_at,
+			finished_at,
+			error_message,
+			failed_stage
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)
+		ON CONFLICT (operation_id) DO NOTHING
+	`,
+		record.OperationID,
+		record.RequestID,
+		record.OperationType,
+		record.Status,
+		record.Date,
+		record.SourceFolder,
+		record.Component,
+		s.instanceID,
+		record.StartedAt,
+		record.UpdatedAt,
+		record.FinishedAt,
+		record.ErrorMessage,
+		record.FailedStage,
+	)
+	if err != nil {
+		return false, fmt.Errorf("create operation run: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
 func (s *Store) Update(ctx context.Context, record Record) error {
 	if record.OperationID == "" {
 		return fmt.Errorf("operation_id is required")
@@ -109,7 +217,7 @@ func (s *Store) Update(ctx context.Context, record Record) error {
 }
 
 func (s *Store) RecoverStale(ctx context.Context) (int, error) {
-	pool, err := s.poolOrErr()
+	pool, err := s.poolOrErr(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -124,6 +232,11 @@ func (s *Store) RecoverStale(ctx context.Context) (int, error) {
 		    failed_stage = COALESCE(NULLIF(failed_stage, ''), $3)
 		WHERE status = ANY($4)
 		  AND updated_at < $5
+		  -- Operations still in the durable queue run again after restart
+		  AND NOT EXISTS (
+			SELECT 1 FROM etl_operation_queue q
+			WHERE q.operation_id = etl_operation_runs.operation_id
+		  )
 		RETURNING operation_id
 	`, StatusAbandoned, "operation abandoned after restart or stale timeout", "stale_operation_recovery", []string{string(StatusStarted), string(StatusQueued), string(StatusProcessing), string(StatusTimeoutReported)}, cutoff)
 	if err != nil {
@@ -140,24 +253,42 @@ func (s *Store) RecoverStale(ctx context.Context) (int, error) {
 	return count, nil
 }
 
-func (s *Store) poolOrErr() (executor, error) {
-	s.once.Do(func() {
-		s.pool, s.poolErr = s.openFn(s.cfg)
-		if s.poolErr != nil {
+// poolOrErr returns the connection pool, opening it within ctx if needed.
+// A nil store has no database and always fails.
+func (s *Store) poolOrErr(ctx context.Context) (executor, error) {
+	if s == nil {
+		return nil, errNoStore
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pool != nil {
+		return s.pool, nil
+	}
+	if s.poolErr != nil && time.Since(s.poolErrAt) < reopenInterval {
+		return nil, s.poolErr
+	}
+	pool, err := s.openFn(ctx, s.cfg)
+	if err != nil {
+		if s.poolErr == nil {
 			s.log.Warn("Operation registry disabled: failed to open database connection",
-				"error", s.poolErr.Error(),
+				"error", err.Error(),
 				"event", "operation_registry_disabled",
 			)
 		}
-	})
+		s.poolErr, s.poolErrAt = err, time.Now()
+		return nil, err
+	}
 	if s.poolErr != nil {
-		return nil, s.poolErr
+		s.log.Info("Operation registry database connection restored",
+			"event", "operation_registry_restored",
+		)
 	}
-	return s.pool, nil
+	s.pool, s.poolErr = pool, nil
+	return pool, nil
 }
 
 func (s *Store) upsert(ctx context.Context, record Record) error {
-	pool, err := s.poolOrErr()
+	pool, err := s.poolOrErr(ctx)
 	if err != nil {
 		return err
 	}
@@ -183,22 +314,24 @@ func (s *Store) upsert(ctx context.Context, record Record) error {
 			error_message,
 			failed_stage,
 			timeout_report_sent,
-			crash_suspected
-		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15)
+			crash_suspected,
+			result
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)
 		ON CONFLICT (operation_id) DO UPDATE SET
 			request_id = COALESCE(NULLIF(EXCLUDED.request_id, ''), etl_operation_runs.request_id),
 			operation_type = COALESCE(NULLIF(EXCLUDED.operation_type, ''), etl_operation_runs.operation_type),
 			status = EXCLUDED.status,
 			date = COALESCE(NULLIF(EXCLUDED.date, ''), etl_operation_runs.date),
 			source_folder = COALESCE(NULLIF(EXCLUDED.source_folder, ''), etl_operation_runs.source_folder),
-			component = COALESCE(NULLIF(EXCLUDED.component, ''), etl_operation_runs.component),
+			component = etl_operation_runs.component,
 			instance_id = COALESCE(NULLIF(EXCLUDED.instance_id, ''), etl_operation_runs.instance_id),
 			updated_at = EXCLUDED.updated_at,
 			finished_at = COALESCE(EXCLUDED.finished_at, etl_operation_runs.finished_at),
 			error_message = COALESCE(NULLIF(EXCLUDED.error_message, ''), etl_operation_runs.error_message),
 			failed_stage = COALESCE(NULLIF(EXCLUDED.failed_stage, ''), etl_operation_runs.failed_stage),
 			timeout_report_sent = etl_operation_runs.timeout_report_sent OR EXCLUDED.timeout_report_sent,
-			crash_suspected = EXCLUDED.crash_suspected
+			crash_suspected = EXCLUDED.crash_suspected,
+			result = COALESCE(EXCLUDED.result, etl_operation_runs.result)
 	`,
 		record.OperationID,
 		record.RequestID,
@@ -215,9 +348,18 @@ func (s *Store) upsert(ctx context.Context, record Record) error {
 		record.FailedStage,
 		record.TimeoutReportSent,
 		record.CrashSuspected,
+		nullableJSON(record.Result),
 	)
 	if err != nil {
 		return fmt.Errorf("upsert operation run: %w", err)
 	}
 	return nil
 }
+
+// nullableJSON stores an empty result as NULL rather than an empty JSONB value.
+func nullableJSON(value json.RawMessage) any {
+	if len(value) == 0 {
+		return nil
+	}
+	return string(value)
+}
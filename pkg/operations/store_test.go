@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,11 +21,14 @@ type stubExecutor struct {
 	queryCalls int
 	execErr    error
 	queryErr   error
+	beginErr   error
 	rows       pgx.Rows
+	execArgs   []any
 }
 
 func (s *stubExecutor) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
 	s.execCalls++
+	s.execArgs = arguments
 	return pgconn.CommandTag{}, s.execErr
 }
 
@@ -31,6 +37,10 @@ func (s *stubExecutor) Query(ctx context.Context, sql string, args ...interface{
 	return s.rows, s.queryErr
 }
 
+func (s *stubExecutor) BeginTx(ctx context.Context) (pgx.Tx, error) {
+	return nil, s.beginErr
+}
+
 func (s *stubExecutor) Close() {}
 
 type stubRows struct {
@@ -57,7 +67,7 @@ func newTestStore(t *testing.T, exec executor) *Store {
 	t.Helper()
 	buf := &bytes.Buffer{}
 	store := NewStore(&models.Config{OperationStaleTimeout: 2 * time.Hour}, logger.New(logger.Config{Output: buf, Format: "json"}))
-	store.openFn = func(cfg *models.Config) (executor, error) {
+	store.openFn = func(ctx context.Context, cfg *models.Config) (executor, error) {
 		return exec, nil
 	}
 	return store
@@ -92,10 +102,66 @@ func TestStoreRecoverStaleCountsRows(t *testing.T) {
 func TestStoreUpdateReturnsOpenError(t *testing.T) {
 	buf := &bytes.Buffer{}
 	store := NewStore(&models.Config{OperationStaleTimeout: 2 * time.Hour}, logger.New(logger.Config{Output: buf, Format: "json"}))
-	store.openFn = func(cfg *models.Config) (executor, error) {
+	store.openFn = func(ctx context.Context, cfg *models.Config) (executor, error) {
 		return nil, errors.New("boom")
 	}
 	if err := store.Update(context.Background(), Record{OperationID: "op_1", Status: StatusFailed}); err == nil {
 		t.Fatal("Update() expected error, got nil")
 	}
 }
+
+func TestStoreRetriesOpenAfterReopenInterval(t *testing.T) {
+	buf := &bytes.Buffer{}
+	store := NewStore(&models.Config{OperationStaleTimeout: 2 * time.Hour}, logger.New(logger.Config{Output: buf, Format: "json"}))
+	exec := &stubExecutor{}
+	opens := 0
+	store.openFn = func(ctx context.Context, cfg *models.Config) (executor, error) {
+		opens++
+		if opens == 1 {
+			return nil, errors.New("boom")
+		}
+		return exec, nil
+	}
+
+	record := Record{OperationID: "op_1", Status: StatusQueued}
+	if err := store.Update(context.Background(), record); err == nil {
+		t.Fatal("Update() expected error, got nil")
+	}
+	if err := store.Update(context.Background(), record); err == nil {
+		t.Fatal("Update() within reopen interval expected cached error, got nil")
+	}
+	if opens != 1 {
+		t.Fatalf("opens = %d, want 1", opens)
+	}
+
+	store.poolErrAt = time.Now().Add(-reopenInterval)
+	if err := store.Update(context.Background(), record); err != nil {
+		t.Fatalf("Update() after reopen interval error = %v", err)
+	}
+	if opens != 2 || exec.execCalls != 1 {
+		t.Fatalf("opens = %d, execCalls = %d, want 2 and 1", opens, exec.execCalls)
+	}
+}
+
+func TestStoreCreateRequiresOperationID(t *testing.T) {
+	exec := &stubExecutor{}
+	store := newTestStore(t, exec)
+	if _, err := store.Create(context.Background(), Record{OperationType: "load"}); err == nil {
+		t.Fatal("Create() expected error, got nil")
+	}
+	if exec.execCalls != 0 {
+		t.Fatalf("execCalls = %d, want 0", exec.execCalls)
+	}
+}
+
+func TestNewStoreUsesUniqueInstanceID(t *testing.T) {
+	first := newTestStore(t, &stubExecutor{})
+	second := newTestStore(t, &stubExecutor{})
+	if first.instanceID == second.instanceID {
+		t.Fatalf("instanceID = %q for both stores, want unique per store", first.instanceID)
+	}
+	host, _ := os.Hostname()
+	if host != "" && !strings.HasPrefix(first.instanceID, fmt.Sprintf("%s-%d-", host, os.Getpid())) {
+		t.Fatalf("instanceID = %q, want hostname-pid-suffix", first.instanceID)
+	}
+}
@@ -0,0 +1,149 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParseMode controls how the parser treats field values it cannot decode.
+type ParseMode string
+
+const (
+	// ParseModeLenient substitutes a fallback value and records a Diagnostic.
+	ParseModeLenient ParseMode = "lenient"
+	// ParseModeStrict fails the file on the first value that would be coerced.
+	ParseModeStrict ParseMode = "strict"
+)
+
+// Reasons reported in Diagnostic.Reason.
+const (
+	ReasonInvalidInt   = "invalid_int"
+	ReasonInvalidFloat = "invalid_float"
+	ReasonInvalidDate  = "invalid_date"
+	ReasonInvalidTime  = "invalid_time"
+	ReasonEmptyDate    = "empty_date"
+	ReasonEmptyTime    = "empty_time"
+)
+
+const defaultMaxDiagnostics = 1000
+
+// ParseModeFromString parses a configured mode; empty means lenient.
+func ParseModeFromString(value string) (ParseMode, error) {
+	switch ParseMode(strings.ToLower(strings.TrimSpace(value))) {
+	case "", ParseModeLenient:
+		return ParseModeLenient, nil
+	case ParseModeStrict:
+		return ParseModeStrict, nil
+	default:
+		return "", fmt.Errorf("unknown parse mode %q (want %s or %s)", value, ParseModeStrict, ParseModeLenient)
+	}
+}
+
+// ParseOptions configures a parse run.
+type ParseOptions struct {
+	Mode ParseMode
+	// MaxDiagnostics caps how many diagnostics are kept in Diagnostics.Items;
+	// Total and ByReason still count all of them. Zero means 1000.
+	MaxDiagnostics int
+	// OnReject, when set, receives lenient-mode lines that cannot be parsed at
+	// all (unknown type, too few fields, bad strict column) instead of failing
+	// the file. Strict mode always fails the file.
+	OnReject RejectHandler
+	// Encoding of the file; empty or EncodingAuto detects it per file.
+	Encoding Encoding
+}
+
+// Diagnostic describes a single value that was coerced while parsing.
+type Diagnostic struct {
+	// Line is the 1-based line number in the file, header included.
+	Line int `json:"line"`
+	// Field is the 1-based position of the value in the ';'-separated line.
+	Field    int    `json:"field"`
+	Column   string `json:"column,omitempty"`
+	RawValue string `json:"raw_value"`
+	Reason   string `json:"reason"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("line %d field %d (%s): %s %q", d.Line, d.Field, d.Column, d.Reason, d.RawValue)
+}
+
+// DiagnosticError is returned in strict mode for a value lenient mode would coerce.
+type DiagnosticError struct {
+	Diagnostic Diagnostic
+}
+
+func (e *DiagnosticError) Error() string {
+	return fmt.Sprintf("%s for %s at field %d: %q", e.Diagnostic.Reason, e.Diagnostic.Column, e.Diagnostic.Field, e.Diagnostic.RawValue)
+}
+
+// Diagnostics collects the coercions made while parsing one file.
+type Diagnostics struct {
+	Items    []Diagnostic   `json:"items,omitempty"`
+	Total    int            `json:"total"`
+	ByReason map[string]int `json:"by_reason,omitempty"`
+
+	limit int
+}
+
+func newDiagnostics(limit int) *Diagnostics {
+	if limit <= 0 {
+		limit = defaultMaxDiagnostics
+	}
+	return &Diagnostics{limit: limit}
+}
+
+func (d *Diagnostics) add(diag Diagnostic) {
+	d.Total++
+	if d.ByReason == nil {
+		d.ByReason = make(map[string]int)
+	}
+	d.ByReason[diag.Reason]++
+	if len(d.Items) < d.limit {
+		d.Items = append(d.Items, diag)
+	}
+}
+
+// Reasons returns the recorded reasons sorted by name.
+func (d *Diagnostics) Reasons() []string {
+	if d == nil {
+		return nil
+	}
+	reasons := make([]string, 0, len(d.ByReason))
+	for reason := range d.ByReason {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// lineContext carries the parse mode and diagnostics sink for one line.
+// A nil context keeps the behaviour of callers without a parse mode, such as
+// ParseTransactionLine: a malformed transaction date or time is an error, other
+// columns fall back silently.
+type lineContext struct {
+	mode        ParseMode
+	line        int
+	diagnostics *Diagnostics
+}
+
+// coerce reports that the value at field (0-based) was replaced by a fallback.
+// In strict mode it returns an error instead.
+func (c *lineContext) coerce(field int, column string, raw string, reason string) error {
+	diag := Diagnostic{Field: field + 1, Column: column, RawValue: raw, Reason: reason}
+	if c == nil {
+		if _, required := requiredTimeColumns[column]; required && raw != "" {
+			return &DiagnosticError{Diagnostic: diag}
+		}
+		return nil
+	}
+	diag.Line = c.line
+	if c.mode == ParseModeStrict {
+		return &DiagnosticError{Diagnostic: diag}
+	}
+	if c.diagnostics != nil {
+		c.diagnostics.add(diag)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// cleanTestLine is streamTestLine with the employee code (field 28) and
+// Stoloto registration time (field 36) fixed, so it parses without coercions.
+func cleanTestLine() string {
+	fields := strings.Split(streamTestLine, ";")
+	fields[27] = "1"
+	fields[35] = ""
+	return strings.Join(fields, ";")
+}
+
+// coercedTestLine is cleanTestLine with a malformed cashier code (field 7)
+// and price (field 10); neither column is strict.
+func coercedTestLine() string {
+	fields := strings.Split(cleanTestLine(), ";")
+	fields[6] = "X1"
+	fields[9] = "abc"
+	return strings.Join(fields, ";")
+}
+
+func TestStreamFileWithOptionsRecordsCoercionsInLenientMode(t *testing.T) {
+	path := writeStreamTestFile(t, "0\nDB\nREPORT\n"+cleanTestLine()+"\n\n"+coercedTestLine()+"\n")
+
+	delivered := 0
+	diagnostics, err := StreamFileWithOptions(path, "test_folder", ParseOptions{Mode: ParseModeLenient}, nil, func(tx ParsedTransaction) error {
+		delivered++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamFileWithOptions() unexpected error: %v", err)
+	}
+	if delivered != 2 {
+		t.Fatalf("StreamFileWithOptions() delivered = %d, want 2", delivered)
+	}
+	if diagnostics.Total != 2 || diagnostics.ByReason[ReasonInvalidInt] != 1 || diagnostics.ByReason[ReasonInvalidFloat] != 1 {
+		t.Fatalf("diagnostics = %+v, want one invalid_int and one invalid_float", diagnostics)
+	}
+
+	want := []Diagnostic{
+		{Line: 6, Field: 7, Column: "cashier_code", RawValue: "X1", Reason: ReasonInvalidInt},
+		{Line: 6, Field: 10, Column: "price_without_discounts", RawValue: "abc", Reason: ReasonInvalidFloat},
+	}
+	if len(diagnostics.Items) != len(want) {
+		t.Fatalf("diagnostics.Items = %+v, want %+v", diagnostics.Items, want)
+	}
+	for i := range want {
+		if diagnostics.Items[i] != want[i] {
+			t.Fatalf("diagnostics.Items[%d] = %+v, want %+v", i, diagnostics.Items[i], want[i])
+		}
+	}
+}
+
+func TestStreamFileWithOptionsFailsOnCoercionInStrictMode(t *testing.T) {
+	path := writeStreamTestFile(t, "0\nDB\nREPORT\n"+cleanTestLine()+"\n"+coercedTestLine()+"\n")
+
+	delivered := 0
+	_, err := StreamFileWithOptions(path, "test_folder", ParseOptions{Mode: ParseModeStrict}, nil, func(tx ParsedTransaction) error {
+		delivered++
+		return nil
+	})
+	if !IsParseError(err) {
+		t.Fatalf("StreamFileWithOptions() error = %v, want ParseError", err)
+	}
+	var diagErr *DiagnosticError
+	if !errors.As(err, &diagErr) {
+		t.Fatalf("StreamFileWithOptions() error = %v, want DiagnosticError", err)
+	}
+	if diagErr.Diagnostic.Line != 5 || diagErr.Diagnostic.Field != 7 || diagErr.Diagnostic.Reason != ReasonInvalidInt {
+		t.Fatalf("DiagnosticError = %+v", diagErr.Diagnostic)
+	}
+	if !strings.Contains(err.Error(), "line 5") {
+		t.Fatalf("StreamFileWithOptions() error = %q, want physical line number", err)
+	}
+	if delivered != 1 {
+		t.Fatalf("StreamFileWithOptions() delivered = %d, want 1", delivered)
+	}
+}
+
+func TestParseFileWithOptionsCapsDiagnosticItems(t *testing.T) {
+	line := coercedTestLine()
+	path := writeStreamTestFile(t, "0\nDB\nREPORT\n"+line+"\n"+line+"\n"+line+"\n")
+
+	transactions, header, diagnostics, err := ParseFileWithOptions(path, "test_folder", ParseOptions{MaxDiagnostics: 2})
+	if err != nil {
+		t.Fatalf("ParseFileWithOptions() unexpected error: %v", err)
+	}
+	if header == nil || len(transactions) != 1 {
+		t.Fatalf("ParseFileWithOptions() header = %v, transactions = %v", header, transactions)
+	}
+	if diagnostics.Total != 6 || len(diagnostics.Items) != 2 {
+		t.Fatalf("diagnostics total = %d, items = %d, want 6 and 2", diagnostics.Total, len(diagnostics.Items))
+	}
+	if got := diagnostics.Reasons(); len(got) != 2 || got[0] != ReasonInvalidFloat || got[1] != ReasonInvalidInt {
+		t.Fatalf("Reasons() = %v", got)
+	}
+}
+
+func TestStreamFileWithOptionsCoercesBaseColumns(t *testing.T) {
+	fields := strings.Split(cleanTestLine(), ";")
+	fields[1] = ""         // transaction_date
+	fields[2] = "25:99:00" // transaction_time
+	fields[13] = "shift"   // shift_number
+	path := writeStreamTestFile(t, "0\nDB\nREPORT\n"+strings.Join(fields, ";")+"\n")
+
+	delivered := 0
+	diagnostics, err := StreamFileWithOptions(path, "test_folder", ParseOptions{Mode: ParseModeLenient}, nil, func(tx ParsedTransaction) error {
+		delivered++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamFileWithOptions() unexpected error: %v", err)
+	}
+	if delivered != 1 {
+		t.Fatalf("StreamFileWithOptions() delivered = %d, want 1", delivered)
+	}
+	want := map[string]int{ReasonEmptyDate: 1, ReasonInvalidTime: 1, ReasonInvalidInt: 1}
+	for reason, count := range want {
+		if diagnostics.ByReason[reason] != count {
+			t.Fatalf("ByReason = %v, want %v", diagnostics.ByReason, want)
+		}
+	}
+
+	_, err = StreamFileWithOptions(path, "test_folder", ParseOptions{Mode: ParseModeStrict}, nil, func(tx ParsedTransaction) error {
+		return nil
+	})
+	var diagErr *DiagnosticError
+	if !errors.As(err, &diagErr) || diagErr.Diagnostic.Field != 2 || diagErr.Diagnostic.Reason != ReasonEmptyDate {
+		t.Fatalf("StreamFileWithOptions() strict error = %v, want empty_date at field 2", err)
+	}
+}
+
+func TestParseModeFromString(t *testing.T) {
+	for value, want := range map[string]ParseMode{"": ParseModeLenient, "lenient": ParseModeLenient, " Strict ": ParseModeStrict} {
+		got, err := ParseModeFromString(value)
+		if err != nil || got != want {
+			t.Fatalf("ParseModeFromString(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+	if _, err := ParseModeFromString("loose"); err == nil {
+		t.Fatal("ParseModeFromString() expected error for unknown mode")
+	}
+}
@@ -12,324 +12,126 @@ import (
 type TransactionType struct {
 	Type        int
 	Description string
+	Table       string
 	Parser      func([]string, string) (interface{}, error)
 }
 
-// GetTransactionType returns the transaction type for a given type code
-// Based on Frontol 6 Integration documentation (frontol_6_integration.md)
-func GetTransactionType(typeCode int) (*TransactionType, error) {
-	switch typeCode {
+// builtinTransactionTypes maps Frontol 6 transaction codes to tx_* tables.
+// Based on Frontol 6 Integration documentation (frontol_6_integration.md).
+// Schemas come from models.TxSchemas, which mirrors the migrations.
+var builtinTransactionTypes = []TransactionTypeSpec{
 	// Регистрация товара (стр. 266-269)
-	case 1, 11:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Регистрация товара",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				return wrapParsed[models.TxItemRegistration1_11](fields, sourceFolder, "tx_item_registration_1_11")
-			},
-		}, nil
-	case 2, 12:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Сторно товара",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				return wrapParsed[models.TxItemStorno2_12](fields, sourceFolder, "tx_item_storno_2_12")
-			},
-		}, nil
-	case 4, 14:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Налог на товар",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				return wrapParsed[models.TxItemTax4_14](fields, sourceFolder, "tx_item_tax_4_14")
-			},
-		}, nil
-	case 6, 16:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "ККТ регистрация",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				return wrapParsed[models.TxItemKKT6_16](fields, sourceFolder, "tx_item_kkt_6_16")
-			},
-		}, nil
+	{Codes: []int{1, 11}, Description: "Регистрация товара", Table: "tx_item_registration_1_11", Model: models.TxItemRegistration1_11{}},
+	{Codes: []int{2, 12}, Description: "Сторно товара", Table: "tx_item_storno_2_12", Model: models.TxItemStorno2_12{}},
+	{Codes: []int{4, 14}, Description: "Налог на товар", Table: "tx_item_tax_4_14", Model: models.TxItemTax4_14{}},
+	{Codes: []int{6, 16}, Description: "ККТ регистрация", Table: "tx_item_kkt_6_16", Model: models.TxItemKKT6_16{}},
 
 	// Установка спеццены/цены из прайс-листа (стр. 271-272)
-	case 3:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Установка спеццены",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				return wrapParsed[models.TxSpecialPrice3](fields, sourceFolder, "tx_special_price_3")
-			},
-		}, nil
+	{Codes: []int{3}, Description: "Установка спеццены", Table: "tx_special_price_3", Model: models.TxSpecialPrice3{}},
 
 	// Начисление и возврат бонуса (стр. 273)
-	case 9, 10:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Начисление и возврат бонуса",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				table := "tx_bonus_accrual_9"
-				if typeCode == 10 {
-					table = "tx_bonus_refund_10"
-				}
-				return wrapParsed[models.TxBonusAccrual9](fields, sourceFolder, table)
-			},
-		}, nil
+	{Codes: []int{9}, Description: "Начисление бонуса", Table: "tx_bonus_accrual_9", Model: models.TxBonusAccrual9{}},
+	{Codes: []int{10}, Description: "Возврат бонуса", Table: "tx_bonus_refund_10", Model: models.TxBonusRefund10{}},
 
 	// Скидки на позицию (стр. 275)
-	case 15, 17:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Скидки на позицию",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				table := "tx_position_discount_15"
-				if typeCode == 17 {
-					table = "tx_position_discount_17"
-				}
-				return wrapParsed[models.TxPositionDiscount15](fields, sourceFolder, table)
-			},
-		}, nil
+	{Codes: []int{15}, Description: "Скидка на позицию", Table: "tx_position_discount_15", Model: models.TxPositionDiscount15{}},
+	{Codes: []int{17}, Description: "Скидка на позицию", Table: "tx_position_discount_17", Model: models.TxPositionDiscount17{}},
 
 	// Регистрация купюр (стр. 277)
-	case 21, 22, 23, 24:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Регистрация купюр",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				table := "tx_bill_registration_21_23"
-				if typeCode == 22 || typeCode == 24 {
-					table = "tx_bill_storno_22_24"
-				}
-				return wrapParsed[models.TxBillRegistration21_23](fields, sourceFolder, table)
-			},
-		}, nil
+	{Codes: []int{21, 23}, Description: "Регистрация купюр", Table: "tx_bill_registration_21_23", Model: models.TxBillRegistration21_23{}},
+	{Codes: []int{22, 24}, Description: "Сторно купюр", Table: "tx_bill_storno_22_24", Model: models.TxBillStorno22_24{}},
 
 	// Регистрация сотрудников в документе редактирования списка сотрудников (стр. 278)
-	case 25:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Регистрация сотрудников",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				return wrapParsed[models.TxEmployeeRegistration25](fields, sourceFolder, "tx_employee_registration_25")
-			},
-		}, nil
+	{Codes: []int{25}, Description: "Регистрация сотрудников", Table: "tx_employee_registration_25", Model: models.TxEmployeeRegistration25{}},
 
 	// Учет сотрудников по документу/позиции (стр. 279)
-	case 26, 29:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Учет сотрудников",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				table := "tx_employee_accounting_doc_26"
-				if typeCode == 29 {
-					table = "tx_employee_accounting_pos_29"
-				}
-				return wrapParsed[models.TxEmployeeAccountingDoc26](fields, sourceFolder, table)
-			},
-		}, nil
+	{Codes: []int{26}, Description: "Учет сотрудников по документу", Table: "tx_employee_accounting_doc_26", Model: models.TxEmployeeAccountingDoc26{}},
+	{Codes: []int{29}, Description: "Учет сотрудников по позиции", Table: "tx_employee_accounting_pos_29", Model: models.TxEmployeeAccountingPos29{}},
 
 	// Изменение статуса карты (стр. 281)
-	case 27:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Изменение статуса карты",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				return wrapParsed[models.TxCardStatusChange27](fields, sourceFolder, "tx_card_status_change_27")
-			},
-		}, nil
+	{Codes: []int{27}, Description: "Изменение статуса карты", Table: "tx_card_status_change_27", Model: models.TxCardStatusChange27{}},
 
 	// Регистрация/сторнирование модификаторов (стр. 283)
-	case 30, 31:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Модификаторы",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				table := "tx_modifier_registration_30"
-				if typeCode == 31 {
-					table = "tx_modifier_storno_31"
-				}
-				return wrapParsed[models.TxModifierRegistration30](fields, sourceFolder, table)
-			},
-		}, nil
+	{Codes: []int{30}, Description: "Регистрация модификатора", Table: "tx_modifier_registration_30", Model: models.TxModifierRegistration30{}},
+	{Codes: []int{31}, Description: "Сторно модификатора", Table: "tx_modifier_storno_31", Model: models.TxModifierStorno31{}},
 
 	// Оплата и возврат оплаты бонусом (стр. 284)
-	case 32, 33, 82, 83:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Оплата бонусом",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				table := "tx_bonus_payment_32"
-				switch typeCode {
-				case 33:
-					table = "tx_bonus_payment_33"
-				case 82:
-					table = "tx_bonus_payment_82"
-				case 83:
-					table = "tx_bonus_payment_83"
-				}
-				return wrapParsed[models.TxBonusPayment32](fields, sourceFolder, table)
-			},
-		}, nil
+	{Codes: []int{32}, Description: "Оплата бонусом", Table: "tx_bonus_payment_32", Model: models.TxBonusPayment32{}},
+	{Codes: []int{33}, Description: "Оплата бонусом", Table: "tx_bonus_payment_33", Model: models.TxBonusPayment33{}},
+	{Codes: []int{82}, Description: "Оплата бонусом", Table: "tx_bonus_payment_82", Model: models.TxBonusPayment82{}},
+	{Codes: []int{83}, Description: "Оплата бонусом", Table: "tx_bonus_payment_83", Model: models.TxBonusPayment83{}},
 
 	// Предоплата документом (стр. 286)
-	case 34, 84:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Предоплата документом",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				table := "tx_prepayment_34"
-				if typeCode == 84 {
-					table = "tx_prepayment_84"
-				}
-				return wrapParsed[models.TxPrepayment34](fields, sourceFolder, table)
-			},
-		}, nil
+	{Codes: []int{34}, Description: "Предоплата документом", Table: "tx_prepayment_34", Model: models.TxPrepayment34{}},
+	{Codes: []int{84}, Description: "Предоплата документом", Table: "tx_prepayment_84", Model: models.TxPrepayment84{}},
 
 	// Скидки на документ (стр. 288)
-	case 35, 37, 38, 85, 87:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Скидки на документ",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				table := "tx_document_discount_35"
-				switch typeCode {
-				case 37:
-					table = "tx_document_discount_37"
-				case 38:
-					table = "tx_document_rounding_38"
-				case 85:
-					table = "tx_document_discount_85"
-				case 87:
-					table = "tx_document_discount_87"
-				}
-				return wrapParsed[models.TxDocumentDiscount35](fields, sourceFolder, table)
-			},
-		}, nil
+	{Codes: []int{35}, Description: "Скидка на документ", Table: "tx_document_discount_35", Model: models.TxDocumentDiscount35{}},
+	{Codes: []int{37}, Description: "Скидка на документ", Table: "tx_document_discount_37", Model: models.TxDocumentDiscount37{}},
+	{Codes: []int{38}, Description: "Округление документа", Table: "tx_document_rounding_38", Model: models.TxDocumentRounding38{}},
+	{Codes: []int{85}, Description: "Скидка на документ", Table: "tx_document_discount_85", Model: models.TxDocumentDiscount85{}},
+	{Codes: []int{87}, Description: "Скидка на документ", Table: "tx_document_discount_87", Model: models.TxDocumentDiscount87{}},
 
 	// Нефискальная оплата (стр. 290)
-	case 36, 86:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Нефискальная оплата",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				table := "tx_non_fiscal_payment_36"
-				if typeCode == 86 {
-					table = "tx_non_fiscal_payment_86"
-				}
-				return wrapParsed[models.TxNonFiscalPayment36](fields, sourceFolder, table)
-			},
-		}, nil
+	{Codes: []int{36}, Description: "Нефискальная оплата", Table: "tx_non_fiscal_payment_36", Model: models.TxNonFiscalPayment36{}},
+	{Codes: []int{86}, Description: "Нефискальная оплата", Table: "tx_non_fiscal_payment_86", Model: models.TxNonFiscalPayment86{}},
 
 	// Фискальная оплата (стр. 292)
-	case 40, 43:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Фискальная оплата",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				table := "tx_fiscal_payment_40"
-				if typeCode == 43 {
-					table = "tx_fiscal_payment_43"
-				}
-				return wrapParsed[models.TxFiscalPayment40](fields, sourceFolder, table)
-			},
-		}, nil
+	{Codes: []int{40}, Description: "Фискальная оплата", Table: "tx_fiscal_payment_40", Model: models.TxFiscalPayment40{}},
+	{Codes: []int{43}, Description: "Фискальная оплата", Table: "tx_fiscal_payment_43", Model: models.TxFiscalPayment43{}},
 
 	// Открытие/закрытие документа (стр. 293)
-	case 42, 45, 49, 55, 56, 58, 65, 120:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Открытие/закрытие документа",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				table := "tx_document_open_42"
-				switch typeCode {
-				case 45:
-					table = "tx_document_close_kkt_45"
-				case 49:
-					table = "tx_document_close_gp_49"
-				case 55:
-					table = "tx_document_close_55"
-				case 56:
-					table = "tx_document_cancel_56"
-				case 58:
-					table = "tx_document_non_fin_close_58"
-				case 65:
-					table = "tx_document_clients_65"
-				case 120:
-					table = "tx_document_egais_120"
-				}
-				return wrapParsed[models.TxDocumentOpen42](fields, sourceFolder, table)
-			},
-		}, nil
+	{Codes: []int{42}, Description: "Открытие документа", Table: "tx_document_open_42", Model: models.TxDocumentOpen42{}},
+	{Codes: []int{45}, Description: "Закрытие документа в ККТ", Table: "tx_document_close_kkt_45", Model: models.TxDocumentCloseKKT45{}},
+	{Codes: []int{49}, Description: "Закрытие документа в ГП", Table: "tx_document_close_gp_49", Model: models.TxDocumentCloseGp49{}},
+	{Codes: []int{55}, Description: "Закрытие документа", Table: "tx_document_close_55", Model: models.TxDocumentClose55{}},
+	{Codes: []int{56}, Description: "Отмена документа", Table: "tx_document_cancel_56", Model: models.TxDocumentCancel56{}},
+	{Codes: []int{58}, Description: "Закрытие нефинансового документа", Table: "tx_document_non_fin_close_58", Model: models.TxDocumentNonFinClose58{}},
+	{Codes: []int{65}, Description: "Клиенты документа", Table: "tx_document_clients_65", Model: models.TxDocumentClients65{}},
+	{Codes: []int{120}, Description: "Документ ЕГАИС", Table: "tx_document_egais_120", Model: models.TxDocumentEGAIS120{}},
 
 	// НДС по чеку из ККТ (стр. 299)
-	case 88:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "НДС по чеку из ККТ",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				return wrapParsed[models.TxVATKKT88](fields, sourceFolder, "tx_vat_kkt_88")
-			},
-		}, nil
+	{Codes: []int{88}, Description: "НДС по чеку из ККТ", Table: "tx_vat_kkt_88", Model: models.TxVATKKT88{}},
 
 	// Дополнительные транзакции (Внесение/Выплата) (стр. 300)
-	case 50, 51:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Внесение/Выплата",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				table := "tx_cash_in_50"
-				if typeCode == 51 {
-					table = "tx_cash_out_51"
-				}
-				return wrapParsed[models.TxCashIn50](fields, sourceFolder, table)
-			},
-		}, nil
+	{Codes: []int{50}, Description: "Внесение", Table: "tx_cash_in_50", Model: models.TxCashIn50{}},
+	{Codes: []int{51}, Description: "Выплата", Table: "tx_cash_out_51", Model: models.TxCashOut51{}},
 
 	// Изменение счетчика (стр. 302)
-	case 57:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Изменение счетчика",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				return wrapParsed[models.TxCounterChange57](fields, sourceFolder, "tx_counter_change_57")
-			},
-		}, nil
+	{Codes: []int{57}, Description: "Изменение счетчика", Table: "tx_counter_change_57", Model: models.TxCounterChange57{}},
 
 	// Отчеты (стр. 304)
-	case 60, 61, 62, 63, 64:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Отчеты",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				table := "tx_report_zless_60"
-				switch typeCode {
-				case 61:
-					table = "tx_shift_close_61"
-				case 62:
-					table = "tx_shift_open_62"
-				case 63:
-					table = "tx_report_z_63"
-				case 64:
-					table = "tx_shift_open_doc_64"
-				}
-				return wrapParsed[models.TxReportZless60](fields, sourceFolder, table)
-			},
-		}, nil
+	{Codes: []int{60}, Description: "Отчет без гашения", Table: "tx_report_zless_60", Model: models.TxReportZless60{}},
+	{Codes: []int{61}, Description: "Закрытие смены", Table: "tx_shift_close_61", Model: models.TxShiftClose61{}},
+	{Codes: []int{62}, Description: "Открытие смены", Table: "tx_shift_open_62", Model: models.TxShiftOpen62{}},
+	{Codes: []int{63}, Description: "Отчет с гашением", Table: "tx_report_z_63", Model: models.TxReportZ63{}},
+	{Codes: []int{64}, Description: "Документ открытия смены", Table: "tx_shift_open_doc_64", Model: models.TxShiftOpenDoc64{}},
 
 	// Отправка данных во Frontol Mark Unit (стр. 306)
-	case 121:
-		return &TransactionType{
-			Type:        typeCode,
-			Description: "Frontol Mark Unit",
-			Parser: func(fields []string, sourceFolder string) (interface{}, error) {
-				return wrapParsed[models.TxMarkUnit121](fields, sourceFolder, "tx_mark_unit_121")
-			},
-		}, nil
-
-	default:
+	{Codes: []int{121}, Description: "Frontol Mark Unit", Table: "tx_mark_unit_121", Model: models.TxMarkUnit121{}},
+}
+
+func init() {
+	for _, spec := range builtinTransactionTypes {
+		spec.Schema = models.TxSchemas[spec.Table]
+		MustRegisterTransactionType(spec)
+	}
+}
+
+// GetTransactionType returns the transaction type for a given type code
+func GetTransactionType(typeCode int) (*TransactionType, error) {
+	entry, ok := lookupRegisteredType(typeCode)
+	if !ok {
 		return nil, fmt.Errorf("unhandled transaction type: %d", typeCode)
 	}
+	return &TransactionType{
+		Type:        typeCode,
+		Description: entry.spec.Description,
+		Table:       entry.spec.Table,
+		Parser: func(fields []string, sourceFolder string) (interface{}, error) {
+			return entry.parse(fields, sourceFolder, nil)
+		},
+	}, nil
 }
 
 // ParseTransactionLine parses a single transaction line using the dispatcher
@@ -355,79 +157,31 @@ func ParseTransactionLine(line string, sourceFolder string) (interface{}, error)
 	return txType.Parser(fields, sourceFolder)
 }
 
-// GetSupportedTransactionTypes returns a list of all supported transaction types
+// GetSupportedTransactionTypes returns all registered transaction types ordered by code
 func GetSupportedTransactionTypes() []TransactionType {
-	return []TransactionType{
-		{Type: 1, Description: "Регистрация товара"},
-		{Type: 2, Description: "Регистрация товара"},
-		{Type: 3, Description: "Установка спеццены"},
-		{Type: 4, Description: "Регистрация товара"},
-		{Type: 6, Description: "Регистрация товара"},
-		{Type: 9, Description: "Начисление и возврат бонуса"},
-		{Type: 10, Description: "Начисление и возврат бонуса"},
-		{Type: 11, Description: "Регистрация товара"},
-		{Type: 12, Description: "Регистрация товара"},
-		{Type: 14, Description: "Регистрация товара"},
-		{Type: 15, Description: "Скидки на позицию"},
-		{Type: 16, Description: "Регистрация товара"},
-		{Type: 17, Description: "Скидки на позицию"},
-		{Type: 21, Description: "Регистрация купюр"},
-		{Type: 22, Description: "Регистрация купюр"},
-		{Type: 23, Description: "Регистрация купюр"},
-		{Type: 24, Description: "Регистрация купюр"},
-		{Type: 25, Description: "Регистрация сотрудников"},
-		{Type: 26, Description: "Учет сотрудников"},
-		{Type: 27, Description: "Изменение статуса карты"},
-		{Type: 29, Description: "Учет сотрудников"},
-		{Type: 30, Description: "Модификаторы"},
-		{Type: 31, Description: "Модификаторы"},
-		{Type: 32, Description: "Оплата бонусом"},
-		{Type: 33, Description: "Оплата бонусом"},
-		{Type: 34, Description: "Предоплата документом"},
-		{Type: 35, Description: "Скидки на документ"},
-		{Type: 36, Description: "Нефискальная оплата"},
-		{Type: 37, Description: "Скидки на документ"},
-		{Type: 38, Description: "Скидки на документ"},
-		{Type: 40, Description: "Фискальная оплата"},
-		{Type: 42, Description: "Открытие/закрытие документа"},
-		{Type: 43, Description: "Фискальная оплата"},
-		{Type: 45, Description: "Открытие/закрытие документа"},
-		{Type: 49, Description: "Открытие/закрытие документа"},
-		{Type: 50, Description: "Внесение/Выплата"},
-		{Type: 51, Description: "Внесение/Выплата"},
-		{Type: 55, Description: "Открытие/закрытие документа"},
-		{Type: 56, Description: "Открытие/закрытие документа"},
-		{Type: 57, Description: "Изменение счетчика"},
-		{Type: 58, Description: "Открытие/закрытие документа"},
-		{Type: 60, Description: "Отчеты"},
-		{Type: 61, Description: "Отчеты"},
-		{Type: 62, Description: "Отчеты"},
-		{Type: 63, Description: "Отчеты"},
-		{Type: 64, Description: "Отчеты"},
-		{Type: 65, Description: "Открытие/закрытие документа"},
-		{Type: 82, Description: "Оплата бонусом"},
-		{Type: 83, Description: "Оплата бонусом"},
-		{Type: 84, Description: "Предоплата документом"},
-		{Type: 85, Description: "Скидки на документ"},
-		{Type: 86, Description: "Нефискальная оплата"},
-		{Type: 87, Description: "Скидки на документ"},
-		{Type: 88, Description: "НДС по чеку из ККТ"},
-		{Type: 120, Description: "Открытие/закрытие документа"},
-		{Type: 121, Description: "Frontol Mark Unit"},
+	codes := RegisteredCodes()
+	types := make([]TransactionType, 0, len(codes))
+	for _, code := range codes {
+		spec, ok := LookupTransactionType(code)
+		if !ok {
+			continue
+		}
+		types = append(types, TransactionType{Type: code, Description: spec.Description, Table: spec.Table})
 	}
+	return types
 }
 
 // ValidateTransactionType checks if a transaction type is supported
 func ValidateTransactionType(typeCode int) bool {
-	_, err := GetTransactionType(typeCode)
-	return err == nil
+	_, ok := LookupTransactionType(typeCode)
+	return ok
 }
 
 // GetTransactionTypeDescription returns the description for a transaction type
 func GetTransactionTypeDescription(typeCode int) string {
-	txType, err := GetTransactionType(typeCode)
-	if err != nil {
+	spec, ok := LookupTransactionType(typeCode)
+	if !ok {
 		return "Неизвестный тип транзакции"
 	}
-	return txType.Description
+	return spec.Description
 }
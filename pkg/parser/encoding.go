@@ -0,0 +1,148 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Encoding is the character encoding of a Frontol file.
+type Encoding string
+
+const (
+	// EncodingAuto detects the encoding of each file, see DetectFileEncoding.
+	EncodingAuto    Encoding = "auto"
+	EncodingUTF8    Encoding = "utf-8"
+	EncodingUTF8BOM Encoding = "utf-8-bom"
+	// EncodingCP1251 is Windows-1251, the Frontol default.
+	EncodingCP1251 Encoding = "cp1251"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// ParseEncoding converts a configured encoding name into an Encoding.
+// An empty value means EncodingAuto; windows-1251 and utf8 are accepted as aliases.
+func ParseEncoding(value string) (Encoding, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", string(EncodingAuto):
+		return EncodingAuto, nil
+	case string(EncodingUTF8), "utf8":
+		return EncodingUTF8, nil
+	case string(EncodingUTF8BOM), "utf8-bom":
+		return EncodingUTF8BOM, nil
+	case string(EncodingCP1251), "windows-1251":
+		return EncodingCP1251, nil
+	default:
+		return "", fmt.Errorf("unknown encoding %q: want auto, utf-8, utf-8-bom or cp1251", value)
+	}
+}
+
+// ResolveFileEncoding returns enc, or the detected encoding of filePath when
+// enc is empty or EncodingAuto.
+func ResolveFileEncoding(filePath string, enc Encoding) (Encoding, error) {
+	if enc != "" && enc != EncodingAuto {
+		return enc, nil
+	}
+	return DetectFileEncoding(filePath)
+}
+
+// DetectFileEncoding reports whether a file is UTF-8 with a BOM, valid UTF-8
+// or, failing both, Windows-1251. Files that are pure ASCII are UTF-8.
+func DetectFileEncoding(filePath string) (Encoding, error) {
+	// #nosec G304 -- filePath comes from configured input directories.
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	return detectEncoding(file)
+}
+
+// detectEncoding reads r to the end, so a file whose first non-ASCII bytes
+// come late is still classified correctly.
+func detectEncoding(r io.Reader) (Encoding, error) {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	if prefix, err := reader.Peek(len(utf8BOM)); err == nil && bytes.Equal(prefix, utf8BOM) {
+		return EncodingUTF8BOM, nil
+	}
+
+	buf := make([]byte, 64*1024)
+	var pending []byte
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			chunk := append(pending, buf[:n]...)
+			rest, ok := validUTF8Prefix(chunk)
+			if !ok {
+				return EncodingCP1251, nil
+			}
+			pending = append(pending[:0:0], rest...)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("error reading file: %w", err)
+		}
+	}
+	// A multi-byte sequence cut off by the end of the file is not UTF-8.
+	if len(pending) > 0 {
+		return EncodingCP1251, nil
+	}
+	return EncodingUTF8, nil
+}
+
+// validUTF8Prefix checks p for UTF-8 validity and returns the incomplete
+// sequence at its end, which continues in the next chunk.
+func validUTF8Prefix(p []byte) ([]byte, bool) {
+	for len(p) > 0 {
+		if p[0] < utf8.RuneSelf {
+			p = p[1:]
+			continue
+		}
+		r, size := utf8.DecodeRune(p)
+		if r == utf8.RuneError && size <= 1 {
+			if !utf8.FullRune(p) {
+				return p, true
+			}
+			return nil, false
+		}
+		p = p[size:]
+	}
+	return nil, true
+}
+
+// newDecodingReader converts r from enc to UTF-8. A UTF-8 BOM is dropped and
+// invalid UTF-8 sequences are replaced with U+FFFD.
+func newDecodingReader(r io.Reader, enc Encoding) io.Reader {
+	if enc == EncodingCP1251 {
+		return transform.NewReader(r, charmap.Windows1251.NewDecoder())
+	}
+	return transform.NewReader(r, unicode.UTF8BOM.NewDecoder())
+}
+
+// openDecoded opens filePath for reading as UTF-8 text. Callers must close the
+// returned file.
+func openDecoded(filePath string, enc Encoding) (*os.File, *bufio.Scanner, error) {
+	enc, err := ResolveFileEncoding(filePath, enc)
+	if err != nil {
+		return nil, nil, err
+	}
+	// #nosec G304 -- filePath comes from configured input directories.
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return file, newScanner(newDecodingReader(file, enc)), nil
+}
@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+// cp1251Privet is "Привет" in Windows-1251.
+var cp1251Privet = string([]byte{0xCF, 0xF0, 0xE8, 0xE2, 0xE5, 0xF2})
+
+func TestDetectEncoding(t *testing.T) {
+	// The first non-ASCII rune straddles the 64 KiB read boundary.
+	straddling := strings.Repeat("a", 64*1024-1) + "Привет"
+
+	tests := []struct {
+		name  string
+		input string
+		want  Encoding
+	}{
+		{name: "empty", input: "", want: EncodingUTF8},
+		{name: "ascii", input: "0\nDB\n1;2;3\n", want: EncodingUTF8},
+		{name: "utf8", input: "0\nDB\n1;Привет\n", want: EncodingUTF8},
+		{name: "utf8_bom", input: "\xEF\xBB\xBF0\nDB\n1;Привет\n", want: EncodingUTF8BOM},
+		{name: "cp1251", input: "0\nDB\n1;" + cp1251Privet + "\n", want: EncodingCP1251},
+		{name: "utf8_across_chunks", input: straddling, want: EncodingUTF8},
+		{name: "cp1251_late", input: strings.Repeat("a", 200*1024) + cp1251Privet, want: EncodingCP1251},
+		{name: "truncated_utf8", input: "abc\xD0", want: EncodingCP1251},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := detectEncoding(bytes.NewReader([]byte(tt.input)))
+			if err != nil {
+				t.Fatalf("detectEncoding() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("detectEncoding() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	for value, want := range map[string]Encoding{
+		"":             EncodingAuto,
+		"AUTO":         EncodingAuto,
+		"utf8":         EncodingUTF8,
+		" utf-8-bom ":  EncodingUTF8BOM,
+		"Windows-1251": EncodingCP1251,
+		"cp1251":       EncodingCP1251,
+	} {
+		got, err := ParseEncoding(value)
+		if err != nil || got != want {
+			t.Fatalf("ParseEncoding(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+	if _, err := ParseEncoding("koi8-r"); err == nil {
+		t.Fatal("ParseEncoding() expected error for unsupported encoding")
+	}
+}
+
+func TestStreamFileDecodesEveryEncodingToUTF8(t *testing.T) {
+	line := strings.Replace(streamTestLine, "ITEM001", "Привет", 1)
+	cp1251Line := strings.Replace(streamTestLine, "ITEM001", cp1251Privet, 1)
+
+	tests := []struct {
+		name     string
+		content  string
+		encoding Encoding
+	}{
+		{name: "cp1251_detected", content: "0\nDB\nREPORT\n" + cp1251Line + "\n"},
+		{name: "utf8_detected", content: "0\nDB\nREPORT\n" + line + "\n"},
+		{name: "utf8_bom_detected", content: "\xEF\xBB\xBF1\nDB\nREPORT\n" + line + "\n"},
+		{name: "cp1251_override", content: "0\nDB\nREPORT\n" + cp1251Line + "\n", encoding: EncodingCP1251},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeStreamTestFile(t, tt.content)
+
+			var header *models.FileHeader
+			var items []string
+			_, err := StreamFileWithOptions(path, "test_folder", ParseOptions{Encoding: tt.encoding}, func(h *models.FileHeader) error {
+				header = h
+				return nil
+			}, func(tx ParsedTransaction) error {
+				items = append(items, tx.Value.(models.TxItemRegistration1_11).ItemIdentifier)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("StreamFileWithOptions() unexpected error: %v", err)
+			}
+			if header == nil || header.DBID != "DB" {
+				t.Fatalf("StreamFileWithOptions() header = %#v", header)
+			}
+			if len(items) != 1 || items[0] != "Привет" {
+				t.Fatalf("StreamFileWithOptions() items = %q, want [Привет]", items)
+			}
+		})
+	}
+}
+
+func TestReadFileHeaderStripsBOM(t *testing.T) {
+	path := writeStreamTestFile(t, "\xEF\xBB\xBF1\nDB\nREPORT\n")
+
+	header, err := ReadFileHeader(path)
+	if err != nil {
+		t.Fatalf("ReadFileHeader() unexpected error: %v", err)
+	}
+	if !header.Processed {
+		t.Fatal("ReadFileHeader() Processed = false, want BOM ignored before the processed flag")
+	}
+}
@@ -137,6 +137,12 @@ func TestParseTransactionLine(t *testing.T) {
 			sourceFolder: "test_folder",
 			wantErr:      true,
 		},
+		{
+			name:         "invalid transaction date and time",
+			line:         "12345;32.12.2024;25:30:00;1;1;100;1;ITEM001;GRP01;1000.50;5;5025.50;1;10",
+			sourceFolder: "test_folder",
+			wantErr:      true,
+		},
 		{
 			name:         "empty line",
 			line:         "",
@@ -168,82 +174,6 @@ func TestParseTransactionLine(t *testing.T) {
 	}
 }
 
-func TestParseBaseTransactionData(t *testing.T) {
-	tests := []struct {
-		name         string
-		fields       []string
-		sourceFolder string
-		wantID       string
-		wantType     int
-		wantErr      bool
-	}{
-		{
-			name:         "valid base data",
-			fields:       []string{"12345", "01.12.2024", "10:30:00", "1", "001", "100", "1", "10", "1", "0", "", "0", "EMP001"},
-			sourceFolder: "test_folder",
-			wantID:       "12345",
-			wantType:     1,
-			wantErr:      false,
-		},
-		{
-			name:         "minimal fields",
-			fields:       []string{"12345", "01.12.2024", "10:30:00", "1"},
-			sourceFolder: "test_folder",
-			wantID:       "12345",
-			wantType:     1,
-			wantErr:      false,
-		},
-		{
-			name:         "insufficient fields",
-			fields:       []string{"12345", "01.12.2024", "10:30:00"},
-			sourceFolder: "test_folder",
-			wantErr:      true,
-		},
-		{
-			name:         "invalid transaction type",
-			fields:       []string{"12345", "01.12.2024", "10:30:00", "ABC"},
-			sourceFolder: "test_folder",
-			wantErr:      true,
-		},
-		{
-			name:         "empty date uses current",
-			fields:       []string{"12345", "", "", "1", "001"},
-			sourceFolder: "test_folder",
-			wantID:       "12345",
-			wantType:     1,
-			wantErr:      false,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result, err := parseBaseTransactionData(tt.fields, tt.sourceFolder)
-
-			if tt.wantErr {
-				if err == nil {
-					t.Error("parseBaseTransactionData() expected error, got nil")
-				}
-				return
-			}
-
-			if err != nil {
-				t.Errorf("parseBaseTransactionData() unexpected error: %v", err)
-				return
-			}
-
-			if result.ID != tt.wantID {
-				t.Errorf("parseBaseTransactionData() ID = %v, want %v", result.ID, tt.wantID)
-			}
-			if result.TransactionType != tt.wantType {
-				t.Errorf("parseBaseTransactionData() TransactionType = %v, want %v", result.TransactionType, tt.wantType)
-			}
-			if result.SourceFolder != tt.sourceFolder {
-				t.Errorf("parseBaseTransactionData() SourceFolder = %v, want %v", result.SourceFolder, tt.sourceFolder)
-			}
-		})
-	}
-}
-
 func TestParseFile(t *testing.T) {
 	// Create a complete test file
 	content := `1
@@ -320,15 +250,6 @@ func BenchmarkParseTransactionLine(b *testing.B) {
 	}
 }
 
-func BenchmarkParseBaseTransactionData(b *testing.B) {
-	fields := []string{"12345", "01.12.2024", "10:30:00", "1", "001", "100", "1", "10", "1", "0", "", "0", "EMP001"}
-
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		_, _ = parseBaseTransactionData(fields, "test_folder")
-	}
-}
-
 func TestParseTransactionLineTxTable(t *testing.T) {
 	line := "12345;01.12.2024;10:30:00;1;001;100;1;ITEM001;GRP01;1000.50;5;5025.50;1;10;100.10;500.50;1;SKU001;1234567890;1000.00;01;0;0;0;;info;1;EMP001;0;;0;0;;;0;0;0;;;0;;;;"
 
@@ -0,0 +1,315 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+// TransactionTypeSpec describes one tx_* table and the Frontol transaction
+// codes routed into it. Registering a spec is all the parser, loader and
+// exporter need to handle a new code; the table itself comes from a migration.
+type TransactionTypeSpec struct {
+	// Codes are the Frontol transaction type codes (field 4) stored in Table.
+	Codes       []int
+	Description string
+	Table       string
+	// Schema lists the table columns in file field order (source_folder is
+	// filled from the kassa folder and does not consume a field).
+	Schema []models.TxColumnSpec
+	// Model is an optional zero value of the struct rows are decoded into.
+	// When nil, rows are decoded into models.TxRecord.
+	Model interface{}
+}
+
+type transactionTypeRegistry struct {
+	mu      sync.RWMutex
+	byCode  map[int]*registeredType
+	byTable map[string]*registeredType
+}
+
+type registeredType struct {
+	spec      TransactionTypeSpec
+	modelType reflect.Type
+}
+
+var (
+	registry = &transactionTypeRegistry{
+		byCode:  make(map[int]*registeredType),
+		byTable: make(map[string]*registeredType),
+	}
+	tableNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+)
+
+// RegisterTransactionType adds a transaction type to the registry.
+// Codes and tables must be unique across all registered types.
+func RegisterTransactionType(spec TransactionTypeSpec) error {
+	entry, err := newRegisteredType(spec)
+	if err != nil {
+		return err
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, exists := registry.byTable[spec.Table]; exists {
+		return fmt.Errorf("transaction table %s is already registered", spec.Table)
+	}
+	for _, code := range spec.Codes {
+		if existing, exists := registry.byCode[code]; exists {
+			return fmt.Errorf("transaction type %d is already registered for %s", code, existing.spec.Table)
+		}
+	}
+
+	registry.byTable[spec.Table] = entry
+	for _, code := range spec.Codes {
+		registry.byCode[code] = entry
+	}
+	return nil
+}
+
+// MustRegisterTransactionType is like RegisterTransactionType but panics on error.
+// It is intended for package-level registrations.
+func MustRegisterTransactionType(spec TransactionTypeSpec) {
+	if err := RegisterTransactionType(spec); err != nil {
+		panic(err)
+	}
+}
+
+// LookupTransactionType returns the spec registered for a Frontol type code.
+func LookupTransactionType(code int) (TransactionTypeSpec, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	entry, ok := registry.byCode[code]
+	if !ok {
+		return TransactionTypeSpec{}, false
+	}
+	return entry.spec, true
+}
+
+// TableSchema returns the column schema of a registered tx_* table.
+func TableSchema(table string) ([]models.TxColumnSpec, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	entry, ok := registry.byTable[table]
+	if !ok {
+		return nil, false
+	}
+	return entry.spec.Schema, true
+}
+
+// RegisteredTables returns all registered tx_* tables sorted by name.
+func RegisteredTables() []string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	tables := make([]string, 0, len(registry.byTable))
+	for table := range registry.byTable {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// RegisteredCodes returns all registered transaction type codes in ascending order.
+func RegisteredCodes() []int {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	codes := make([]int, 0, len(registry.byCode))
+	for code := range registry.byCode {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	return codes
+}
+
+func lookupRegisteredType(code int) (*registeredType, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	entry, ok := registry.byCode[code]
+	return entry, ok
+}
+
+func lookupRegisteredTable(table string) (*registeredType, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	entry, ok := registry.byTable[table]
+	return entry, ok
+}
+
+func newRegisteredType(spec TransactionTypeSpec) (*registeredType, error) {
+	if !tableNamePattern.MatchString(spec.Table) {
+		return nil, fmt.Errorf("invalid transaction table name: %q", spec.Table)
+	}
+	if len(spec.Codes) == 0 {
+		return nil, fmt.Errorf("transaction table %s has no type codes", spec.Table)
+	}
+	if err := validateTxSchema(spec.Schema); err != nil {
+		return nil, fmt.Errorf("transaction table %s: %w", spec.Table, err)
+	}
+
+	entry := &registeredType{spec: spec}
+	if spec.Model != nil {
+		modelType := reflect.TypeOf(spec.Model)
+		if modelType.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("transaction table %s: model must be a struct, got %T", spec.Table, spec.Model)
+		}
+		for _, column := range spec.Schema {
+			fieldName := models.ColumnToFieldName(column.Name)
+			if _, ok := modelType.FieldByName(fieldName); !ok {
+				return nil, fmt.Errorf("transaction table %s: missing field %s on %s", spec.Table, fieldName, modelType.Name())
+			}
+		}
+		entry.modelType = modelType
+	}
+	return entry, nil
+}
+
+func validateTxSchema(schema []models.TxColumnSpec) error {
+	if len(schema) == 0 {
+		return fmt.Errorf("schema is empty")
+	}
+	seen := make(map[string]struct{}, len(schema))
+	hasID, hasSource := false, false
+	for _, column := range schema {
+		if !tableNamePattern.MatchString(column.Name) {
+			return fmt.Errorf("invalid column name: %q", column.Name)
+		}
+		if _, dup := seen[column.Name]; dup {
+			return fmt.Errorf("duplicate column %s", column.Name)
+		}
+		seen[column.Name] = struct{}{}
+		switch {
+		case column.Name == "transaction_id_unique" && column.Kind == models.TxColumnInt64:
+			hasID = true
+		case column.Name == "source_folder" && column.Kind == models.TxColumnSource:
+			hasSource = true
+		}
+	}
+	// Both columns form the upsert conflict key and the stale-row manifest.
+	if !hasID || !hasSource {
+		return fmt.Errorf("schema must contain int64 transaction_id_unique and source_folder columns")
+	}
+	return nil
+}
+
+// parse decodes fields into the registered model, or into models.TxRecord
+// when the type was registered without one.
+func (r *registeredType) parse(fields []string, sourceFolder string, lc *lineContext) (ParsedTransaction, error) {
+	if r.modelType == nil {
+		record, err := fillTxRecord(fields, sourceFolder, r.spec.Schema, lc)
+		if err != nil {
+			return ParsedTransaction{}, err
+		}
+		return ParsedTransaction{Table: r.spec.Table, Value: record}, nil
+	}
+
+	dest := reflect.New(r.modelType)
+	if err := decodeTxStruct(dest.Interface(), fields, sourceFolder, r.spec.Schema, lc); err != nil {
+		return ParsedTransaction{}, err
+	}
+	return ParsedTransaction{Table: r.spec.Table, Value: dest.Elem().Interface()}, nil
+}
+
+// rowType is the Go type of values produced for this table.
+func (r *registeredType) rowType() reflect.Type {
+	if r.modelType == nil {
+		return reflect.TypeOf(models.TxRecord{})
+	}
+	return r.modelType
+}
+
+func fillTxRecord(fields []string, sourceFolder string, schema []models.TxColumnSpec, lc *lineContext) (models.TxRecord, error) {
+	record := models.TxRecord{Values: make([]interface{}, len(schema))}
+	fieldIndex := 0
+	for i, spec := range schema {
+		if spec.Kind == models.TxColumnSource {
+			record.Values[i] = sourceFolder
+			continue
+		}
+
+		var raw string
+		if fieldIndex < len(fields) {
+			raw = fields[fieldIndex]
+		}
+		fieldIndex++
+
+		value, err := parseTxColumn(spec, raw, fieldIndex-1, lc)
+		if err != nil {
+			return models.TxRecord{}, err
+		}
+		record.Values[i] = value
+		if spec.Name == "transaction_id_unique" {
+			record.TransactionIDUnique, _ = value.(int64)
+		}
+	}
+	return record, nil
+}
+
+// parseTxColumn converts a raw field into the Go value for its column kind.
+// Strict columns always reject malformed values; other columns fall back to
+// zero and report the coercion through lc (which fails in strict mode). An
+// empty transaction date or time is reported the same way.
+func parseTxColumn(spec models.TxColumnSpec, raw string, field int, lc *lineContext) (interface{}, error) {
+	switch spec.Kind {
+	case models.TxColumnString:
+		return raw, nil
+	case models.TxColumnInt64:
+		if raw == "" {
+			return int64(0), nil
+		}
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			if isStrictSchemaColumn(spec.Name) {
+				return nil, fmt.Errorf("invalid int64 for %s: %q", spec.Name, raw)
+			}
+			return int64(0), lc.coerce(field, spec.Name, raw, ReasonInvalidInt)
+		}
+		return parsed, nil
+	case models.TxColumnFloat64:
+		if raw == "" {
+			return float64(0), nil
+		}
+		parsed, err := parseFloatWithComma(raw)
+		if err != nil {
+			if isStrictSchemaColumn(spec.Name) {
+				return nil, fmt.Errorf("invalid float64 for %s: %q", spec.Name, raw)
+			}
+			return float64(0), lc.coerce(field, spec.Name, raw, ReasonInvalidFloat)
+		}
+		return parsed, nil
+	case models.TxColumnDate:
+		return parseTxTime(spec.Name, "02.01.2006", raw, field, lc, ReasonEmptyDate, ReasonInvalidDate)
+	case models.TxColumnTime:
+		return parseTxTime(spec.Name, "15:04:05", raw, field, lc, ReasonEmptyTime, ReasonInvalidTime)
+	default:
+		return nil, fmt.Errorf("unsupported column kind for %s", spec.Name)
+	}
+}
+
+// parseTxTime parses a date or time column. Malformed values, and empty ones
+// in the transaction date and time columns, become the zero time.
+func parseTxTime(column, layout, raw string, field int, lc *lineContext, emptyReason, invalidReason string) (interface{}, error) {
+	if raw == "" {
+		if _, required := requiredTimeColumns[column]; required {
+			return time.Time{}, lc.coerce(field, column, raw, emptyReason)
+		}
+		return time.Time{}, nil
+	}
+	parsed, err := time.Parse(layout, raw)
+	if err != nil {
+		return time.Time{}, lc.coerce(field, column, raw, invalidReason)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,161 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+func registerTestType(t *testing.T, spec TransactionTypeSpec) {
+	t.Helper()
+	if err := RegisterTransactionType(spec); err != nil {
+		t.Fatalf("RegisterTransactionType() unexpected error: %v", err)
+	}
+	t.Cleanup(func() {
+		registry.mu.Lock()
+		defer registry.mu.Unlock()
+		delete(registry.byTable, spec.Table)
+		for _, code := range spec.Codes {
+			delete(registry.byCode, code)
+		}
+	})
+}
+
+func customTestSchema() []models.TxColumnSpec {
+	return []models.TxColumnSpec{
+		{Name: "transaction_id_unique", Kind: models.TxColumnInt64},
+		{Name: "source_folder", Kind: models.TxColumnSource},
+		{Name: "transaction_date", Kind: models.TxColumnDate},
+		{Name: "transaction_time", Kind: models.TxColumnTime},
+		{Name: "transaction_type", Kind: models.TxColumnInt64},
+		{Name: "amount", Kind: models.TxColumnFloat64},
+		{Name: "comment", Kind: models.TxColumnString},
+	}
+}
+
+func TestRegistryCoversAllTxSchemas(t *testing.T) {
+	for table, schema := range models.TxSchemas {
+		got, ok := TableSchema(table)
+		if !ok {
+			t.Fatalf("table %s is not registered", table)
+		}
+		if !reflect.DeepEqual(got, schema) {
+			t.Fatalf("TableSchema(%s) differs from models.TxSchemas", table)
+		}
+	}
+	if got := len(RegisteredTables()); got != len(models.TxSchemas) {
+		t.Fatalf("RegisteredTables() = %d, want %d", got, len(models.TxSchemas))
+	}
+}
+
+func TestRegisterTransactionTypeWithoutModelParsesTxRecord(t *testing.T) {
+	registerTestType(t, TransactionTypeSpec{
+		Codes:       []int{130},
+		Description: "Тестовый тип",
+		Table:       "tx_test_custom_130",
+		Schema:      customTestSchema(),
+	})
+
+	parsed, err := ParseTransactionLine("77;01.12.2024;10:30:00;130;12,5;hello", "P13/P13")
+	if err != nil {
+		t.Fatalf("ParseTransactionLine() unexpected error: %v", err)
+	}
+	tx := parsed.(ParsedTransaction)
+	if tx.Table != "tx_test_custom_130" {
+		t.Fatalf("Table = %q, want tx_test_custom_130", tx.Table)
+	}
+	record, ok := tx.Value.(models.TxRecord)
+	if !ok {
+		t.Fatalf("Value type = %T, want models.TxRecord", tx.Value)
+	}
+	if record.TransactionIDUnique != 77 {
+		t.Fatalf("TransactionIDUnique = %d, want 77", record.TransactionIDUnique)
+	}
+	if record.Values[1] != "P13/P13" || record.Values[5] != 12.5 || record.Values[6] != "hello" {
+		t.Fatalf("Values = %#v", record.Values)
+	}
+	if GetTransactionTypeDescription(130) != "Тестовый тип" {
+		t.Fatalf("GetTransactionTypeDescription(130) = %q", GetTransactionTypeDescription(130))
+	}
+
+	if _, err := ParseTransactionLine("bad;01.12.2024;10:30:00;130", "P13/P13"); err == nil {
+		t.Fatal("ParseTransactionLine() expected strict id error, got nil")
+	}
+}
+
+func TestRegisterTransactionTypeRejectsInvalidSpecs(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    TransactionTypeSpec
+		wantSub string
+	}{
+		{
+			name:    "duplicate code",
+			spec:    TransactionTypeSpec{Codes: []int{1}, Table: "tx_duplicate_code", Schema: customTestSchema()},
+			wantSub: "already registered",
+		},
+		{
+			name:    "duplicate table",
+			spec:    TransactionTypeSpec{Codes: []int{131}, Table: "tx_item_registration_1_11", Schema: customTestSchema()},
+			wantSub: "already registered",
+		},
+		{
+			name:    "unsafe table name",
+			spec:    TransactionTypeSpec{Codes: []int{131}, Table: "tx; DROP TABLE x", Schema: customTestSchema()},
+			wantSub: "invalid transaction table name",
+		},
+		{
+			name:    "no codes",
+			spec:    TransactionTypeSpec{Table: "tx_no_codes", Schema: customTestSchema()},
+			wantSub: "no type codes",
+		},
+		{
+			name:    "missing conflict key",
+			spec:    TransactionTypeSpec{Codes: []int{131}, Table: "tx_no_key", Schema: customTestSchema()[2:]},
+			wantSub: "transaction_id_unique",
+		},
+		{
+			name:    "model missing field",
+			spec:    TransactionTypeSpec{Codes: []int{131}, Table: "tx_bad_model", Schema: customTestSchema(), Model: models.TxItemTax4_14{}},
+			wantSub: "missing field",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := RegisterTransactionType(tt.spec)
+			if err == nil {
+				t.Fatal("RegisterTransactionType() expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantSub) {
+				t.Fatalf("RegisterTransactionType() error = %q, want substring %q", err.Error(), tt.wantSub)
+			}
+		})
+	}
+	if _, ok := LookupTransactionType(131); ok {
+		t.Fatal("failed registration must not leave code 131 registered")
+	}
+}
+
+func TestParseFileGroupsRegisteredRecordsIntoSlices(t *testing.T) {
+	registerTestType(t, TransactionTypeSpec{
+		Codes:  []int{130},
+		Table:  "tx_test_custom_130",
+		Schema: customTestSchema(),
+	})
+
+	path := writeStreamTestFile(t, "0\nDB\nREPORT\n1;01.12.2024;10:30:00;130;1;a\n2;01.12.2024;10:30:00;130;2;b\n"+streamTestLine+"\n")
+	transactions, _, err := ParseFile(path, "P13/P13")
+	if err != nil {
+		t.Fatalf("ParseFile() unexpected error: %v", err)
+	}
+	records, ok := transactions["tx_test_custom_130"].([]models.TxRecord)
+	if !ok || len(records) != 2 {
+		t.Fatalf("tx_test_custom_130 = %#v, want 2 TxRecord rows", transactions["tx_test_custom_130"])
+	}
+	if items, ok := transactions["tx_item_registration_1_11"].([]models.TxItemRegistration1_11); !ok || len(items) != 1 {
+		t.Fatalf("tx_item_registration_1_11 = %T, want typed slice", transactions["tx_item_registration_1_11"])
+	}
+}
@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+// HeaderHandler receives the file header before any transaction is delivered.
+type HeaderHandler func(header *models.FileHeader) error
+
+// TransactionHandler receives parsed transactions one at a time in file order.
+type TransactionHandler func(tx ParsedTransaction) error
+
+// LineRejection describes a transaction line that could not be parsed.
+type LineRejection struct {
+	// Line is the 1-based line number in the file, header included.
+	Line int
+	Raw  string
+	// TransactionType is the code from field 4, or 0 when it is not a number.
+	TransactionType int
+	Err             error
+}
+
+// RejectHandler receives lines skipped in lenient mode, see ParseOptions.OnReject.
+type RejectHandler func(rejection LineRejection) error
+
+// ParseError reports malformed file contents. Errors returned by stream
+// handlers are passed through unwrapped, so callers can tell a broken file
+// apart from a failing consumer with errors.As.
+type ParseError struct {
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// IsParseError reports whether err was caused by malformed file contents.
+func IsParseError(err error) bool {
+	var parseErr *ParseError
+	return errors.As(err, &parseErr)
+}
+
+// ReadFileHeader reads only the 3-line header of a Frontol file, detecting
+// its encoding.
+func ReadFileHeader(filePath string) (*models.FileHeader, error) {
+	return ReadFileHeaderWithEncoding(filePath, EncodingAuto)
+}
+
+// ReadFileHeaderWithEncoding is ReadFileHeader for a file in a known encoding.
+func ReadFileHeaderWithEncoding(filePath string, enc Encoding) (*models.FileHeader, error) {
+	file, scanner, err := openDecoded(filePath, enc)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	header, err := readFileHeader(scanner)
+	if err != nil {
+		return nil, &ParseError{Err: fmt.Errorf("failed to parse file header: %w", err)}
+	}
+	return header, nil
+}
+
+// StreamFile parses a Frontol file line by line without holding its
+// transactions in memory. onHeader (optional) is called once before the first
+// transaction; onTransaction is called for every transaction line.
+func StreamFile(filePath string, sourceFolder string, onHeader HeaderHandler, onTransaction TransactionHandler) error {
+	_, err := StreamFileWithOptions(filePath, sourceFolder, ParseOptions{Mode: ParseModeLenient}, onHeader, onTransaction)
+	return err
+}
+
+// StreamFileWithOptions is StreamFile with a configurable parse mode and
+// encoding; lines are decoded to UTF-8 before they are parsed. It
+// returns the coercions made while parsing, also when it fails part way.
+// In strict mode the first coercion aborts the stream with a *ParseError.
+func StreamFileWithOptions(filePath string, sourceFolder string, opts ParseOptions, onHeader HeaderHandler, onTransaction TransactionHandler) (*Diagnostics, error) {
+	file, scanner, err := openDecoded(filePath, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	header, err := readFileHeader(scanner)
+	if err != nil {
+		return nil, &ParseError{Err: fmt.Errorf("failed to parse file header: %w", err)}
+	}
+	if onHeader != nil {
+		if err := onHeader(header); err != nil {
+			return nil, err
+		}
+	}
+
+	diagnostics := newDiagnostics(opts.MaxDiagnostics)
+	return diagnostics, streamTransactions(scanner, sourceFolder, opts, diagnostics, onTransaction)
+}
+
+// streamTransactions parses the remaining lines of scanner and hands every
+// transaction to fn. Malformed input is reported as *ParseError (or to
+// opts.OnReject in lenient mode); line numbers count from the start of the
+// file, header included.
+func streamTransactions(scanner *bufio.Scanner, sourceFolder string, opts ParseOptions, diagnostics *Diagnostics, fn TransactionHandler) error {
+	lc := &lineContext{mode: opts.Mode, line: headerLines, diagnostics: diagnostics}
+	for scanner.Scan() {
+		lc.line++
+		line := strings.TrimSpace(scanner.Text())
+
+		// Skip empty lines
+		if line == "" {
+			continue
+		}
+
+		transaction, err := parseTransactionLineWithContext(line, sourceFolder, lc)
+		if err != nil {
+			if opts.OnReject != nil && opts.Mode != ParseModeStrict {
+				if err := opts.OnReject(LineRejection{Line: lc.line, Raw: line, TransactionType: lineTransactionType(line), Err: err}); err != nil {
+					return err
+				}
+				continue
+			}
+			return &ParseError{Err: fmt.Errorf("error parsing line %d: %w", lc.line, err)}
+		}
+
+		parsed, ok := transaction.(ParsedTransaction)
+		if !ok {
+			return &ParseError{Err: fmt.Errorf("unknown transaction type: %T", transaction)}
+		}
+
+		if err := fn(parsed); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return &ParseError{Err: fmt.Errorf("error reading file: %w", err)}
+	}
+
+	return nil
+}
+
+// lineTransactionType extracts the type code (field 4) of a raw line, or 0.
+func lineTransactionType(line string) int {
+	fields := strings.SplitN(line, ";", 5)
+	if len(fields) < 4 {
+		return 0
+	}
+	code, err := strconv.Atoi(fields[3])
+	if err != nil {
+		return 0
+	}
+	return code
+}
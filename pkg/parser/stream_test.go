@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+const streamTestLine = "12345;01.12.2024;10:30:00;1;001;100;1;ITEM001;GRP01;1000.50;5;5025.50;1;10;100.10;500.50;1;SKU001;1234567890;1000.00;01;0;0;0;;info;1;EMP001;0;;0;0;;;0;0;0;;;0;;;;"
+
+func writeStreamTestFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stream.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	return path
+}
+
+func TestStreamFileDeliversHeaderBeforeTransactions(t *testing.T) {
+	path := writeStreamTestFile(t, "0\nDB_TEST_123\nREPORT_001\n"+streamTestLine+"\n\n"+streamTestLine+"\n")
+
+	var header *models.FileHeader
+	tables := make([]string, 0, 2)
+	err := StreamFile(path, "test_folder", func(h *models.FileHeader) error {
+		if len(tables) != 0 {
+			t.Fatal("StreamFile() delivered header after transactions")
+		}
+		header = h
+		return nil
+	}, func(tx ParsedTransaction) error {
+		if header == nil {
+			t.Fatal("StreamFile() delivered transaction before header")
+		}
+		tables = append(tables, tx.Table)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamFile() unexpected error: %v", err)
+	}
+	if header.DBID != "DB_TEST_123" || header.ReportNum != "REPORT_001" || header.Processed {
+		t.Fatalf("StreamFile() header = %#v", header)
+	}
+	if len(tables) != 2 || tables[0] != "tx_item_registration_1_11" {
+		t.Fatalf("StreamFile() tables = %v, want 2x tx_item_registration_1_11", tables)
+	}
+}
+
+func TestStreamFileReportsMalformedLinesAsParseError(t *testing.T) {
+	path := writeStreamTestFile(t, "0\nDB\nREPORT\n"+streamTestLine+"\nnot-a-number;01.12.2024;10:30:00;1;1;100;1\n")
+
+	delivered := 0
+	err := StreamFile(path, "test_folder", nil, func(tx ParsedTransaction) error {
+		delivered++
+		return nil
+	})
+	if !IsParseError(err) {
+		t.Fatalf("StreamFile() error = %v, want *ParseError", err)
+	}
+	if delivered != 1 {
+		t.Fatalf("StreamFile() delivered = %d, want 1", delivered)
+	}
+}
+
+func TestStreamFilePassesHandlerErrorsThrough(t *testing.T) {
+	path := writeStreamTestFile(t, "0\nDB\nREPORT\n"+streamTestLine+"\n")
+	handlerErr := errors.New("database unavailable")
+
+	err := StreamFile(path, "test_folder", nil, func(tx ParsedTransaction) error {
+		return handlerErr
+	})
+	if !errors.Is(err, handlerErr) {
+		t.Fatalf("StreamFile() error = %v, want handler error", err)
+	}
+	if IsParseError(err) {
+		t.Fatal("StreamFile() wrapped handler error as ParseError")
+	}
+}
+
+func TestReadFileHeaderRejectsTruncatedHeader(t *testing.T) {
+	path := writeStreamTestFile(t, "0\nDB\n")
+
+	if _, err := ReadFileHeader(path); !IsParseError(err) {
+		t.Fatalf("ReadFileHeader() error = %v, want *ParseError", err)
+	}
+}
+
+func TestStreamFileWithOptionsHandsUnparseableLinesToOnReject(t *testing.T) {
+	path := writeStreamTestFile(t, "0\nDB\nREPORT\n"+streamTestLine+"\n1;01.12.2024;10:30:00;999;1\nbroken\n"+streamTestLine+"\n")
+
+	delivered := 0
+	var rejected []LineRejection
+	opts := ParseOptions{Mode: ParseModeLenient, OnReject: func(rejection LineRejection) error {
+		rejected = append(rejected, rejection)
+		return nil
+	}}
+	_, err := StreamFileWithOptions(path, "test_folder", opts, nil, func(tx ParsedTransaction) error {
+		delivered++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamFileWithOptions() unexpected error: %v", err)
+	}
+	if delivered != 2 {
+		t.Fatalf("StreamFileWithOptions() delivered = %d, want 2", delivered)
+	}
+	if len(rejected) != 2 {
+		t.Fatalf("rejected = %+v, want 2 lines", rejected)
+	}
+	if rejected[0].Line != 5 || rejected[0].TransactionType != 999 || rejected[0].Raw != "1;01.12.2024;10:30:00;999;1" || rejected[0].Err == nil {
+		t.Fatalf("rejected[0] = %+v", rejected[0])
+	}
+	if rejected[1].Line != 6 || rejected[1].TransactionType != 0 {
+		t.Fatalf("rejected[1] = %+v", rejected[1])
+	}
+
+	opts.Mode = ParseModeStrict
+	if _, err := StreamFileWithOptions(path, "test_folder", opts, nil, func(tx ParsedTransaction) error { return nil }); !IsParseError(err) {
+		t.Fatalf("StreamFileWithOptions() strict error = %v, want *ParseError", err)
+	}
+}
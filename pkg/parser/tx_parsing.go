@@ -3,22 +3,27 @@ package parser
 import (
 	"fmt"
 	"reflect"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/user/go-frontol-loader/pkg/models"
 )
 
+// strictSchemaColumns reject malformed values even in lenient mode: the
+// line cannot be stored without them.
 var strictSchemaColumns = map[string]struct{}{
 	"transaction_id_unique": {},
-	"transaction_date":      {},
-	"transaction_time":      {},
 	"transaction_type":      {},
 	"cash_register_code":    {},
 	"document_number":       {},
 }
 
+// requiredTimeColumns report an empty value as a coercion.
+var requiredTimeColumns = map[string]struct{}{
+	"transaction_date": {},
+	"transaction_time": {},
+}
+
 type ParsedTransaction struct {
 	Table string
 	Value interface{}
@@ -32,18 +37,9 @@ func wrapParsed[T any](fields []string, sourceFolder string, tableName string) (
 	return ParsedTransaction{Table: tableName, Value: val}, nil
 }
 
-func appendTx[T any](table string, value interface{}, dst *[]T) error {
-	typed, ok := value.(T)
-	if !ok {
-		return fmt.Errorf("invalid value for %s: %T", table, value)
-	}
-	*dst = append(*dst, typed)
-	return nil
-}
-
 func parseTxModel[T any](fields []string, sourceFolder string, tableName string) (T, error) {
 	var dest T
-	schema, ok := models.TxSchemas[tableName]
+	schema, ok := TableSchema(tableName)
 	if !ok {
 		return dest, fmt.Errorf("unknown tx schema: %s", tableName)
 	}
@@ -54,6 +50,11 @@ func parseTxModel[T any](fields []string, sourceFolder string, tableName string)
 }
 
 func fillTxStruct(dst interface{}, fields []string, sourceFolder string, schema []models.TxColumnSpec) error {
+	return decodeTxStruct(dst, fields, sourceFolder, schema, nil)
+}
+
+// decodeTxStruct fills dst from fields, reporting coerced values through lc.
+func decodeTxStruct(dst interface{}, fields []string, sourceFolder string, schema []models.TxColumnSpec, lc *lineContext) error {
 	val := reflect.ValueOf(dst)
 	if val.Kind() != reflect.Ptr {
 		return fmt.Errorf("destination must be pointer to struct")
@@ -91,73 +92,30 @@ func fillTxStruct(dst interface{}, fields []string, sourceFolder string, schema
 		}
 		fieldIndex++
 
+		value, err := parseTxColumn(spec, raw, fieldIndex-1, lc)
+		if err != nil {
+			return err
+		}
+
 		switch spec.Kind {
 		case models.TxColumnString:
 			if field.Kind() != reflect.String {
 				return fmt.Errorf("field %s is not string", fieldName)
 			}
-			field.SetString(raw)
 		case models.TxColumnInt64:
-			val := int64(0)
-			if raw != "" {
-				parsed, err := strconv.ParseInt(raw, 10, 64)
-				if err != nil {
-					if isStrictSchemaColumn(spec.Name) {
-						return fmt.Errorf("invalid int64 for %s: %q", spec.Name, raw)
-					}
-				} else {
-					val = parsed
-				}
-			}
 			if field.Kind() != reflect.Int64 {
 				return fmt.Errorf("field %s is not int64", fieldName)
 			}
-			field.SetInt(val)
 		case models.TxColumnFloat64:
-			val := float64(0)
-			if raw != "" {
-				parsed, err := parseFloatWithComma(raw)
-				if err != nil {
-					if isStrictSchemaColumn(spec.Name) {
-						return fmt.Errorf("invalid float64 for %s: %q", spec.Name, raw)
-					}
-				} else {
-					val = parsed
-				}
-			}
 			if field.Kind() != reflect.Float64 {
 				return fmt.Errorf("field %s is not float64", fieldName)
 			}
-			field.SetFloat(val)
-		case models.TxColumnDate:
-			parsed := time.Time{}
-			if raw != "" {
-				if t, err := time.Parse("02.01.2006", raw); err == nil {
-					parsed = t
-				} else if isStrictSchemaColumn(spec.Name) {
-					return fmt.Errorf("invalid date for %s: %q", spec.Name, raw)
-				}
-			}
-			if field.Type() != reflect.TypeOf(time.Time{}) {
-				return fmt.Errorf("field %s is not time.Time", fieldName)
-			}
-			field.Set(reflect.ValueOf(parsed))
-		case models.TxColumnTime:
-			parsed := time.Time{}
-			if raw != "" {
-				if t, err := time.Parse("15:04:05", raw); err == nil {
-					parsed = t
-				} else if isStrictSchemaColumn(spec.Name) {
-					return fmt.Errorf("invalid time for %s: %q", spec.Name, raw)
-				}
-			}
+		case models.TxColumnDate, models.TxColumnTime:
 			if field.Type() != reflect.TypeOf(time.Time{}) {
 				return fmt.Errorf("field %s is not time.Time", fieldName)
 			}
-			field.Set(reflect.ValueOf(parsed))
-		default:
-			return fmt.Errorf("unsupported column kind for %s", spec.Name)
 		}
+		field.Set(reflect.ValueOf(value))
 	}
 
 	return nil
@@ -208,6 +208,11 @@ func TestFillTxStructRejectsInvalidCriticalTxFields(t *testing.T) {
 			fields:  []string{"12345", "01.12.2024", "25:30:00", "1", "1", "100", "1"},
 			wantSub: "transaction_time",
 		},
+		{
+			name:    "invalid cash register",
+			fields:  []string{"12345", "01.12.2024", "10:30:00", "1", "X", "100", "1"},
+			wantSub: "cash_register_code",
+		},
 		{
 			name:    "invalid document number",
 			fields:  []string{"12345", "01.12.2024", "10:30:00", "1", "1", "doc", "1"},
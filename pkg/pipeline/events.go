@@ -0,0 +1,65 @@
+package pipeline
+
+import "context"
+
+// KassaEvent — снимок загрузки папки кассы: публикуется при каждой смене
+// этапа, после каждого файла ответа и один раз по завершении папки.
+type KassaEvent struct {
+	KassaCode          string `json:"kassa_code"`
+	FolderName         string `json:"folder_name"`
+	SourceFolder       string `json:"source_folder"`
+	Status             string `json:"status"`
+	File               string `json:"file,omitempty"` // файл ответа, обработка которого только что закончилась
+	FilesFound         int    `json:"files_found"`
+	FilesQueued        int    `json:"files_queued"`
+	FilesProcessed     int    `json:"files_processed"`
+	FilesFailed        int    `json:"files_failed"`
+	TransactionsLoaded int    `json:"transactions_loaded"`
+	LastIssueStage     string `json:"last_issue_stage,omitempty"`
+	LastIssueMessage   string `json:"last_issue_message,omitempty"`
+	// Done — папка обработана, событий по ней больше не будет
+	Done bool `json:"done"`
+}
+
+// KassaEventFunc получает события касс. Папки загружаются параллельно, поэтому
+// функция вызывается конкурентно; она не должна блокировать загрузку.
+type KassaEventFunc func(KassaEvent)
+
+type kassaEventsKey struct{}
+
+// WithKassaEvents возвращает контекст, с которым Run сообщает fn о ходе
+// загрузки каждой кассы.
+func WithKassaEvents(ctx context.Context, fn KassaEventFunc) context.Context {
+	return context.WithValue(ctx, kassaEventsKey{}, fn)
+}
+
+// kassaEventsFrom возвращает получателя событий из ctx или пустую функцию.
+func kassaEventsFrom(ctx context.Context) KassaEventFunc {
+	if fn, ok := ctx.Value(kassaEventsKey{}).(KassaEventFunc); ok && fn != nil {
+		return fn
+	}
+	return func(KassaEvent) {}
+}
+
+// kassaEvent возвращает текущее состояние папки как событие.
+func (r *folderRunResult) kassaEvent(file string, done bool) KassaEvent {
+	transactions := 0
+	for _, count := range r.TransactionDetails {
+		transactions += count
+	}
+	return KassaEvent{
+		KassaCode:          r.Detail.KassaCode,
+		FolderName:         r.Detail.FolderName,
+		SourceFolder:       r.Detail.SourceFolder,
+		Status:             r.Detail.Status,
+		File:               file,
+		FilesFound:         r.Detail.FilesFound,
+		FilesQueued:        r.Detail.FilesQueued,
+		FilesProcessed:     r.Detail.FilesProcessed,
+		FilesFailed:        r.Detail.FilesFailed,
+		TransactionsLoaded: transactions,
+		LastIssueStage:     r.Detail.LastIssueStage,
+		LastIssueMessage:   r.Detail.LastIssueMessage,
+		Done:               done,
+	}
+}
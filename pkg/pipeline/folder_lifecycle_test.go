@@ -6,6 +6,9 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -50,13 +53,13 @@ func TestProcessFolderLoadFailsIfResponseFolderStaysNonEmpty(t *testing.T) {
 			}
 		},
 		ClearDirectoryFunc: func(path string) error { return nil },
-		SendRequestToKassaFunc: func(models.KassaFolder, string) error {
+		SendRequestToKassaFunc: func(models.KassaFolder, models.DateRange) error {
 			requestSent = true
 			return nil
 		},
 	}
 
-	result := processFolderLoad(context.Background(), mock, &mockFileLoader{}, &models.Config{RetryDelay: time.Millisecond}, "2026-03-23", folder, logger)
+	result := processFolderLoad(context.Background(), mock, &mockFileLoader{}, &models.Config{RetryDelay: time.Millisecond}, models.SingleDay("2026-03-23"), folder, logger)
 	if requestSent {
 		t.Fatal("request should not be sent when response folder stays non-empty")
 	}
@@ -95,7 +98,7 @@ func TestProcessFolderLoadClassifiesEmptyResponse(t *testing.T) {
 			}
 		},
 		ClearDirectoryFunc:     func(path string) error { return nil },
-		SendRequestToKassaFunc: func(models.KassaFolder, string) error { return nil },
+		SendRequestToKassaFunc: func(models.KassaFolder, models.DateRange) error { return nil },
 		DownloadFileFunc: func(remotePath, localPath string) error {
 			if err := os.MkdirAll(filepath.Dir(localPath), 0750); err != nil {
 				return err
@@ -104,7 +107,7 @@ func TestProcessFolderLoadClassifiesEmptyResponse(t *testing.T) {
 		},
 	}
 
-	result := processFolderLoad(context.Background(), mock, &mockFileLoader{}, &models.Config{LocalDir: t.TempDir(), RetryDelay: time.Millisecond}, "2026-03-23", folder, logger)
+	result := processFolderLoad(context.Background(), mock, &mockFileLoader{}, &models.Config{LocalDir: t.TempDir(), RetryDelay: time.Millisecond}, models.SingleDay("2026-03-23"), folder, logger)
 	if result.Detail.LastIssueStage != "empty_response" {
 		t.Fatalf("last issue stage = %q, want empty_response", result.Detail.LastIssueStage)
 	}
@@ -138,10 +141,10 @@ func TestProcessFolderLoadMarksNoResponse(t *testing.T) {
 			}
 		},
 		ClearDirectoryFunc:     func(path string) error { return nil },
-		SendRequestToKassaFunc: func(models.KassaFolder, string) error { return nil },
+		SendRequestToKassaFunc: func(models.KassaFolder, models.DateRange) error { return nil },
 	}
 
-	result := processFolderLoad(context.Background(), mock, &mockFileLoader{}, &models.Config{RetryDelay: time.Millisecond}, "2026-03-23", folder, logger)
+	result := processFolderLoad(context.Background(), mock, &mockFileLoader{}, &models.Config{RetryDelay: time.Millisecond}, models.SingleDay("2026-03-23"), folder, logger)
 	if result.Detail.Status != "no_response" {
 		t.Fatalf("status = %q, want no_response", result.Detail.Status)
 	}
@@ -149,3 +152,327 @@ func TestProcessFolderLoadMarksNoResponse(t *testing.T) {
 		t.Fatalf("error breakdown = %+v", result.ErrorBreakdown)
 	}
 }
+
+func TestProcessFolderLoadReportsParseDiagnostics(t *testing.T) {
+	folder := models.KassaFolder{KassaCode: "P13", FolderName: "P13", RequestPath: "/request/P13", ResponsePath: "/response/P13"}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	// Цена (поле 10) не число: в lenient-режиме подставляется 0
+	content := "0\nDB\nREPORT\n12345;01.12.2024;10:30:00;1;001;100;1;ITEM001;GRP01;abc;5;5025.50;1;10;100.10;500.50;1;SKU001;1234567890;1000.00;01;0;0;0;;info;1;1;0;;0;0;;;0;;0;;;0;;;;\n"
+
+	newMock := func() *ftpclient.MockClient {
+		responseCalls := 0
+		return &ftpclient.MockClient{
+			ListFilesFunc: func(path string) ([]*ftplib.Entry, error) {
+				if path != folder.ResponsePath {
+					return nil, nil
+				}
+				responseCalls++
+				if responseCalls < 3 {
+					return nil, nil
+				}
+				return []*ftplib.Entry{{Name: "response.txt", Type: ftplib.EntryTypeFile, Size: uint64(len(content))}}, nil
+			},
+			ClearDirectoryFunc:     func(path string) error { return nil },
+			SendRequestToKassaFunc: func(models.KassaFolder, models.DateRange) error { return nil },
+			DownloadFileFunc: func(remotePath, localPath string) error {
+				if err := os.MkdirAll(filepath.Dir(localPath), 0750); err != nil {
+					return err
+				}
+				return os.WriteFile(localPath, []byte(content), 0600)
+			},
+		}
+	}
+	loader := &mockFileLoader{getTransactionCount: func(map[string]interface{}) int { return 1 }}
+
+	result := processFolderLoad(context.Background(), newMock(), loader, &models.Config{LocalDir: t.TempDir(), RetryDelay: time.Millisecond, ParseMode: "lenient"}, models.SingleDay("2024-12-01"), folder, logger)
+	if result.Detail.FilesProcessed != 1 || result.Detail.FilesFailed != 0 {
+		t.Fatalf("lenient detail = %+v", result.Detail)
+	}
+	if result.ErrorBreakdown["parse_invalid_float"] != 1 || result.Detail.ParseDiagnostics != 1 {
+		t.Fatalf("lenient error breakdown = %+v, parse diagnostics = %d", result.ErrorBreakdown, result.Detail.ParseDiagnostics)
+	}
+	if len(result.ErrorSamples) != 1 || result.ErrorSamples[0].Stage != "parse_invalid_float" {
+		t.Fatalf("lenient error samples = %+v", result.ErrorSamples)
+	}
+
+	result = processFolderLoad(context.Background(), newMock(), loader, &models.Config{LocalDir: t.TempDir(), RetryDelay: time.Millisecond, ParseMode: "strict"}, models.SingleDay("2024-12-01"), folder, logger)
+	if result.Detail.FilesProcessed != 0 || result.ErrorBreakdown["file_parse_error"] != 1 {
+		t.Fatalf("strict detail = %+v, error breakdown = %+v", result.Detail, result.ErrorBreakdown)
+	}
+}
+
+func TestProcessFolderLoadReportsShiftMismatches(t *testing.T) {
+	folder := models.KassaFolder{KassaCode: "P13", FolderName: "P13", RequestPath: "/request/P13", ResponsePath: "/response/P13"}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	content := "0\nDB\nREPORT\n12345;01.12.2024;10:30:00;1;001;100;1;ITEM001;GRP01;5;5;5025.50;1;10;100.10;500.50;1;SKU001;1234567890;1000.00;01;0;0;0;;info;1;1;0;;0;0;;;0;;0;;;0;;;;\n"
+	responseCalls := 0
+	mock := &ftpclient.MockClient{
+		ListFilesFunc: func(path string) ([]*ftplib.Entry, error) {
+			if path != folder.ResponsePath {
+				return nil, nil
+			}
+			responseCalls++
+			if responseCalls < 3 {
+				return nil, nil
+			}
+			return []*ftplib.Entry{{Name: "response.txt", Type: ftplib.EntryTypeFile, Size: uint64(len(content))}}, nil
+		},
+		ClearDirectoryFunc:     func(path string) error { return nil },
+		SendRequestToKassaFunc: func(models.KassaFolder, models.DateRange) error { return nil },
+		DownloadFileFunc: func(remotePath, localPath string) error {
+			if err := os.MkdirAll(filepath.Dir(localPath), 0750); err != nil {
+				return err
+			}
+			return os.WriteFile(localPath, []byte(content), 0600)
+		},
+	}
+	var reconciled []string
+	loader := &mockFileLoader{
+		reconcileShifts: func(ctx context.Context, sourceFolder string, dates models.DateRange) ([]models.ShiftReconciliation, error) {
+			reconciled = append(reconciled, sourceFolder+" "+dates.String())
+			return []models.ShiftReconciliation{
+				{SourceFolder: sourceFolder, CashRegisterCode: 1, ShiftNumber: 7, Status: models.ShiftReconciliationMatched},
+				{SourceFolder: sourceFolder, CashRegisterCode: 1, ShiftNumber: 8, Status: models.ShiftReconciliationMismatch, ZRevenue: 100, ItemsRevenue: 100, PaymentsRevenue: 90, PaymentsDifference: -10},
+				{SourceFolder: sourceFolder, CashRegisterCode: 1, ShiftNumber: 9, Status: models.ShiftReconciliationNoZReport},
+			}, nil
+		},
+	}
+
+	result := processFolderLoad(context.Background(), mock, loader, &models.Config{LocalDir: t.TempDir(), RetryDelay: time.Millisecond}, models.SingleDay("2024-12-01"), folder, logger)
+	if len(reconciled) != 1 || reconciled[0] != "P13/P13 2024-12-01" {
+		t.Fatalf("ReconcileShifts() calls = %v, want one after the folder load", reconciled)
+	}
+	if result.Detail.Status != "partial" || result.Detail.FilesProcessed != 1 {
+		t.Fatalf("detail = %+v, want partial with the file loaded", result.Detail)
+	}
+	if result.Detail.ShiftsReconciled != 3 || result.Detail.ShiftsNoZReport != 1 || len(result.Detail.ShiftMismatches) != 1 || result.Detail.ShiftMismatches[0].ShiftNumber != 8 {
+		t.Fatalf("shift stats = %d/%d/%+v", result.Detail.ShiftsReconciled, result.Detail.ShiftsNoZReport, result.Detail.ShiftMismatches)
+	}
+	if result.ErrorBreakdown["shift_mismatch"] != 1 || len(result.ErrorSamples) != 1 || result.ErrorSamples[0].Stage != "shift_mismatch" {
+		t.Fatalf("error breakdown = %+v, samples = %+v", result.ErrorBreakdown, result.ErrorSamples)
+	}
+}
+
+func TestProcessFolderLoadClearsRequestWhenCanceled(t *testing.T) {
+	folder := models.KassaFolder{KassaCode: "L32", FolderName: "L32_INTER", RequestPath: "/request/L32/L32_INTER", ResponsePath: "/response/L32/L32_INTER"}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	requestClears := 0
+	mock := &ftpclient.MockClient{
+		ListFilesFunc: func(path string) ([]*ftplib.Entry, error) { return nil, nil },
+		ClearDirectoryFunc: func(path string) error {
+			if path == folder.RequestPath {
+				requestClears++
+			}
+			return nil
+		},
+		SendRequestToKassaFunc: func(models.KassaFolder, models.DateRange) error {
+			// Загрузку отменяют, пока касса готовит ответ
+			cancel()
+			return nil
+		},
+	}
+
+	result := processFolderLoad(ctx, mock, &mockFileLoader{}, &models.Config{RetryDelay: time.Millisecond, WaitDelayMinutes: time.Minute}, models.SingleDay("2026-03-23"), folder, logger)
+	if result.Detail.LastIssueStage != "response_wait_canceled" {
+		t.Fatalf("last issue stage = %q, want response_wait_canceled", result.Detail.LastIssueStage)
+	}
+	// Первая очистка — перед отправкой запроса, вторая — после отмены
+	if requestClears != 2 {
+		t.Fatalf("request folder cleared %d times, want 2", requestClears)
+	}
+}
+
+func TestProcessFolderLoadPublishesKassaEvents(t *testing.T) {
+	folder := models.KassaFolder{KassaCode: "P13", FolderName: "P13", RequestPath: "/request/P13", ResponsePath: "/response/P13"}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	content := "0\nDB\nREPORT\n12345;01.12.2024;10:30:00;1;001;100;1;ITEM001;GRP01;5;5;5025.50;1;10;100.10;500.50;1;SKU001;1234567890;1000.00;01;0;0;0;;info;1;1;0;;0;0;;;0;;0;;;0;;;;\n"
+	responseCalls := 0
+	mock := &ftpclient.MockClient{
+		ListFilesFunc: func(path string) ([]*ftplib.Entry, error) {
+			if path != folder.ResponsePath {
+				return nil, nil
+			}
+			responseCalls++
+			if responseCalls < 3 {
+				return nil, nil
+			}
+			return []*ftplib.Entry{{Name: "response.txt", Type: ftplib.EntryTypeFile, Size: uint64(len(content))}}, nil
+		},
+		ClearDirectoryFunc:     func(path string) error { return nil },
+		SendRequestToKassaFunc: func(models.KassaFolder, models.DateRange) error { return nil },
+		DownloadFileFunc: func(remotePath, localPath string) error {
+			if err := os.MkdirAll(filepath.Dir(localPath), 0750); err != nil {
+				return err
+			}
+			return os.WriteFile(localPath, []byte(content), 0600)
+		},
+	}
+
+	var events []KassaEvent
+	ctx := WithKassaEvents(context.Background(), func(event KassaEvent) {
+		events = append(events, event)
+	})
+	processFolderLoad(ctx, mock, &mockFileLoader{}, &models.Config{LocalDir: t.TempDir(), RetryDelay: time.Millisecond}, models.SingleDay("2024-12-01"), folder, logger)
+
+	statuses := make([]string, 0, len(events))
+	for _, event := range events {
+		statuses = append(statuses, event.Status)
+	}
+	want := []string{"pending", "lock_acquired", "response_cleaned", "request_cleaned", "request_sent", "waiting_response", "processing_response", "processing_response", "loaded"}
+	if strings.Join(statuses, ",") != strings.Join(want, ",") {
+		t.Fatalf("event statuses = %v, want %v", statuses, want)
+	}
+	fileEvent := events[len(events)-2]
+	if fileEvent.File != "response.txt" || fileEvent.FilesFound != 1 || fileEvent.FilesProcessed != 1 || fileEvent.SourceFolder != "P13/P13" {
+		t.Fatalf("file event = %+v", fileEvent)
+	}
+	for i, event := range events {
+		if event.Done != (i == len(events)-1) {
+			t.Fatalf("event %d done = %v, want only the last event done", i, event.Done)
+		}
+	}
+}
+
+func TestProcessFolderLoadPollsUntilResponseSettles(t *testing.T) {
+	folder := models.KassaFolder{KassaCode: "P13", FolderName: "P13", RequestPath: "/request/P13", ResponsePath: "/response/P13"}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	content := "0\nDB\nREPORT\n12345;01.12.2024;10:30:00;1;001;100;1;ITEM001;GRP01;5;5;5025.50;1;10;100.10;500.50;1;SKU001;1234567890;1000.00;01;0;0;0;;info;1;1;0;;0;0;;;0;;0;;;0;;;;\n"
+	responseCalls := 0
+	mock := &ftpclient.MockClient{
+		ListFilesFunc: func(path string) ([]*ftplib.Entry, error) {
+			if path != folder.ResponsePath {
+				return nil, nil
+			}
+			responseCalls++
+			// Очистка, проверка пустой папки, пустой опрос, затем файл дописывается
+			switch responseCalls {
+			case 1, 2, 3:
+				return nil, nil
+			case 4:
+				return []*ftplib.Entry{{Name: "response.txt", Type: ftplib.EntryTypeFile, Size: 10}}, nil
+			default:
+				return []*ftplib.Entry{{Name: "response.txt", Type: ftplib.EntryTypeFile, Size: uint64(len(content))}}, nil
+			}
+		},
+		ClearDirectoryFunc:     func(path string) error { return nil },
+		SendRequestToKassaFunc: func(models.KassaFolder, models.DateRange) error { return nil },
+		DownloadFileFunc: func(remotePath, localPath string) error {
+			if err := os.MkdirAll(filepath.Dir(localPath), 0750); err != nil {
+				return err
+			}
+			return os.WriteFile(localPath, []byte(content), 0600)
+		},
+	}
+	cfg := &models.Config{
+		LocalDir:               t.TempDir(),
+		RetryDelay:             time.Millisecond,
+		ResponsePollInterval:   time.Millisecond,
+		ResponsePollMaxBackoff: 2 * time.Millisecond,
+		KassaResponseDeadlines: map[string]time.Duration{"P13": time.Minute},
+	}
+
+	result := processFolderLoad(context.Background(), mock, &mockFileLoader{}, cfg, models.SingleDay("2024-12-01"), folder, logger)
+	if result.Detail.Status != "loaded" || result.Detail.FilesProcessed != 1 {
+		t.Fatalf("detail = %+v, want loaded with the file processed", result.Detail)
+	}
+	// Файл готов на третьем опросе после появления: 10 байт, полный размер, тот же размер
+	if result.Detail.ResponsePolls != 4 {
+		t.Fatalf("response polls = %d, want 4", result.Detail.ResponsePolls)
+	}
+	if result.Detail.ResponseLatency == "" {
+		t.Fatal("response latency is not recorded")
+	}
+}
+
+func TestAwaitResponseFiles(t *testing.T) {
+	growing := func() *ftpclient.MockClient {
+		size := uint64(0)
+		return &ftpclient.MockClient{
+			ListFilesFunc: func(path string) ([]*ftplib.Entry, error) {
+				size++
+				return []*ftplib.Entry{{Name: "response.txt", Type: ftplib.EntryTypeFile, Size: size}}, nil
+			},
+		}
+	}
+
+	wait, err := awaitResponseFiles(context.Background(), growing(), "/response/P13", 20*time.Millisecond, time.Millisecond, 4*time.Millisecond)
+	if err != nil {
+		t.Fatalf("awaitResponseFiles() error = %v", err)
+	}
+	if wait.ready || len(wait.responseFiles) != 1 || wait.latency < 20*time.Millisecond {
+		t.Fatalf("wait = %+v, want unsettled file taken at the deadline", wait)
+	}
+
+	marked := &ftpclient.MockClient{
+		ListFilesFunc: func(path string) ([]*ftplib.Entry, error) {
+			return []*ftplib.Entry{
+				{Name: "response.txt", Type: ftplib.EntryTypeFile, Size: 10},
+				{Name: responseReadyMarker, Type: ftplib.EntryTypeFile, Size: 1},
+			}, nil
+		},
+	}
+	wait, err = awaitResponseFiles(context.Background(), marked, "/response/P13", time.Minute, time.Minute, time.Minute)
+	if err != nil {
+		t.Fatalf("awaitResponseFiles() error = %v", err)
+	}
+	if !wait.ready || wait.polls != 1 || len(wait.responseFiles) != 1 || wait.skipped != 1 {
+		t.Fatalf("wait = %+v, want response ready on the first poll by %s", wait, responseReadyMarker)
+	}
+}
+
+// endpointRouter отдает каждой кассе клиент ее сервера обмена
+type endpointRouter struct {
+	ftpclient.MockClient
+	clients map[string]ftpclient.FTPClient
+}
+
+func (r *endpointRouter) ClientForFolder(folder models.KassaFolder) (ftpclient.FTPClient, error) {
+	return r.clients[folder.KassaCode], nil
+}
+
+func TestProcessFilesFromFTPRoutesFoldersToEndpoints(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	requests := make(map[string][]string)
+	var requestsMu sync.Mutex
+	endpointClient := func(name string) *ftpclient.MockClient {
+		return &ftpclient.MockClient{
+			ListFilesFunc:      func(string) ([]*ftplib.Entry, error) { return nil, nil },
+			ClearDirectoryFunc: func(string) error { return nil },
+			SendRequestToKassaFunc: func(folder models.KassaFolder, _ models.DateRange) error {
+				requestsMu.Lock()
+				defer requestsMu.Unlock()
+				requests[name] = append(requests[name], folder.KassaCode)
+				return nil
+			},
+		}
+	}
+	router := &endpointRouter{clients: map[string]ftpclient.FTPClient{
+		"P13": endpointClient("default"),
+		"L32": endpointClient("south"),
+	}}
+	cfg := &models.Config{
+		FTPRequestDir:  "/request",
+		FTPResponseDir: "/response",
+		RetryDelay:     time.Millisecond,
+		KassaStructure: map[string][]string{"P13": {"P13"}, "L32": {"L32"}},
+		FTPEndpoints:   []models.FTPEndpoint{{Name: "south", Kassas: []string{"L32"}}},
+	}
+
+	stats, err := processFilesFromFTP(context.Background(), router, &mockFileLoader{}, cfg, models.SingleDay("2026-03-23"), nil, logger)
+	if err != nil {
+		t.Fatalf("processFilesFromFTP() error = %v", err)
+	}
+	if !reflect.DeepEqual(requests, map[string][]string{"default": {"P13"}, "south": {"L32"}}) {
+		t.Fatalf("requests by endpoint = %v, want each kassa on its own endpoint", requests)
+	}
+	endpoints := make(map[string]string)
+	for _, detail := range stats.KassaDetails {
+		endpoints[detail.KassaCode] = detail.Endpoint
+	}
+	if endpoints["L32"] != "south" || endpoints["P13"] != "" {
+		t.Fatalf("kassa endpoints = %v, want L32 on south", endpoints)
+	}
+}
@@ -2,11 +2,11 @@ package pipeline
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"os"
-	"reflect"
 	"sort"
 	"sync"
 	"time"
@@ -14,17 +14,18 @@ import (
 	ftplib "github.com/jlaffaye/ftp"
 	"github.com/user/go-frontol-loader/pkg/db"
 	"github.com/user/go-frontol-loader/pkg/ftp"
+	"github.com/user/go-frontol-loader/pkg/metrics"
 	"github.com/user/go-frontol-loader/pkg/models"
 	"github.com/user/go-frontol-loader/pkg/parser"
 	"github.com/user/go-frontol-loader/pkg/repository"
+	"github.com/user/go-frontol-loader/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type fileLoader interface {
-	GetTransactionCount(transactions map[string]interface{}) int
-	LoadFileData(ctx context.Context, transactions map[string]interface{}) error
-	LoadFileDataWithReconcile(ctx context.Context, fileState *models.FileLoadState, staleManifest map[string][]int64, transactions map[string]interface{}) error
+	LoadFileStream(ctx context.Context, fileState *models.FileLoadState, staleManifest map[string][]int64, batchSize int, source repository.TransactionSource) (*repository.StreamLoadResult, error)
 	GetFileLoadState(ctx context.Context, logicalKey string) (*models.FileLoadState, error)
-	GetTransactionDetails(transactions map[string]interface{}) []map[string]interface{}
+	ReconcileShifts(ctx context.Context, sourceFolder string, dates models.DateRange) ([]models.ShiftReconciliation, error)
 }
 
 type PipelineStatus string
@@ -33,11 +34,12 @@ const (
 	PipelineStatusCompleted PipelineStatus = "completed"
 	PipelineStatusPartial   PipelineStatus = "partial"
 	PipelineStatusFailed    PipelineStatus = "failed"
+	PipelineStatusCanceled  PipelineStatus = "canceled"
 )
 
 const maxErrorSamples = 5
 
-var processFilesFromFTPFunc func(context.Context, ftp.FTPClient, fileLoader, *models.Config, string, *slog.Logger) (*ProcessingStats, error) = processFilesFromFTP
+var processFilesFromFTPFunc func(context.Context, ftp.FTPClient, fileLoader, *models.Config, models.DateRange, []string, *slog.Logger) (*ProcessingStats, error) = processFilesFromFTP
 
 type PipelineIssueSample struct {
 	Stage string `json:"stage"`
@@ -56,6 +58,7 @@ type KassaProcessingStats struct {
 	KassaCode        string `json:"kassa_code"`
 	FolderName       string `json:"folder_name"`
 	SourceFolder     string `json:"source_folder"`
+	Endpoint         string `json:"endpoint,omitempty"` // сервер обмена из FTP_ENDPOINTS_FILE
 	Status           string `json:"status,omitempty"`
 	RequestPath      string `json:"request_path,omitempty"`
 	ResponsePath     string `json:"response_path"`
@@ -68,8 +71,16 @@ type KassaProcessingStats struct {
 	DeletedRequests  int    `json:"deleted_requests,omitempty"`
 	DeletedResponses int    `json:"deleted_responses,omitempty"`
 	LockWait         string `json:"lock_wait,omitempty"`
+	ResponseLatency  string `json:"response_latency,omitempty"` // от отправки запроса до готовности ответа
+	ResponsePolls    int    `json:"response_polls,omitempty"`
 	LastIssueStage   string `json:"last_issue_stage,omitempty"`
 	LastIssueMessage string `json:"last_issue_message,omitempty"`
+	ParseDiagnostics int    `json:"parse_diagnostics,omitempty"`
+	RejectedLines    int    `json:"rejected_lines,omitempty"`
+	// Сверка смен с Z-отчетом после загрузки папки
+	ShiftsReconciled int                          `json:"shifts_reconciled,omitempty"`
+	ShiftsNoZReport  int                          `json:"shifts_no_z_report,omitempty"`
+	ShiftMismatches  []models.ShiftReconciliation `json:"shift_mismatches,omitempty"`
 }
 
 // PipelineResult содержит результат выполнения ETL-конвейера
@@ -78,6 +89,8 @@ type PipelineResult struct {
 	EndTime            time.Time              `json:"end_time"`
 	Duration           string                 `json:"duration"`
 	Date               string                 `json:"date"`
+	DateTo             string                 `json:"date_to,omitempty"` // последний день, если запрошен диапазон
+	Kassas             []string               `json:"kassas,omitempty"`  // коды касс или source_folder целевой загрузки
 	Status             PipelineStatus         `json:"status"`
 	FilesProcessed     int                    `json:"files_processed"`
 	FilesSkipped       int                    `json:"files_skipped"`
@@ -92,20 +105,29 @@ type PipelineResult struct {
 	TransactionDetails []TransactionTypeStats `json:"transaction_details,omitempty"` // Детальная информация по типам транзакций
 }
 
-// Run выполняет полный ETL-конвейер для указанной даты
-func Run(ctx context.Context, logger *slog.Logger, cfg *models.Config, date string) (*PipelineResult, error) {
+// Run выполняет полный ETL-конвейер для указанного диапазона дат: на каждую
+// кассу уходит один request-файл на весь диапазон. Непустой kassas (коды касс
+// или source_folder) ограничивает загрузку этими папками, остальные кассы не
+// затрагиваются. При отмене ctx Run возвращает статистику уже загруженных
+// файлов со статусом canceled и ошибку, оборачивающую context.Canceled
+func Run(ctx context.Context, logger *slog.Logger, cfg *models.Config, dates models.DateRange, kassas []string) (*PipelineResult, error) {
 	result := &PipelineResult{
 		StartTime: time.Now(),
-		Date:      date,
+		Date:      dates.From,
+		Kassas:    kassas,
 		Status:    PipelineStatusFailed,
 		Success:   false,
 	}
+	if !dates.IsSingleDay() {
+		result.DateTo = dates.To
+	}
 	defer finalizeResult(result)
 
 	logger.InfoContext(ctx, "Starting ETL pipeline",
 		"log_kind", "loki_operational",
-		"date", date,
+		"date", dates.String(),
 		"kassa_count", len(cfg.KassaStructure),
+		"kassas", kassas,
 		"ftp_pool_size", cfg.FTPPoolSize,
 		"worker_pool_size", cfg.WorkerPoolSize,
 		"event", "etl_start",
@@ -124,7 +146,7 @@ func Run(ctx context.Context, logger *slog.Logger, cfg *models.Config, date stri
 	defer database.Close()
 
 	// Инициализация FTP connection pool
-	ftpClient, err := ftp.NewPool(cfg, cfg.FTPPoolSize)
+	ftpClient, err := ftp.Open(cfg, cfg.FTPPoolSize)
 	if err != nil {
 		result.ErrorMessage = fmt.Sprintf("Failed to create FTP pool: %v", err)
 		logger.ErrorContext(ctx, "Failed to create FTP pool",
@@ -145,11 +167,24 @@ func Run(ctx context.Context, logger *slog.Logger, cfg *models.Config, date stri
 	// Инициализация загрузчика
 	loader := repository.NewLoader(database)
 
-	return runWithClients(ctx, logger, cfg, date, ftpClient, loader, result)
+	return runWithClients(ctx, logger, cfg, dates, kassas, ftpClient, loader, result)
 
 }
 
-func runWithClients(ctx context.Context, logger *slog.Logger, cfg *models.Config, date string, ftpClient ftp.FTPClient, loader fileLoader, result *PipelineResult) (*PipelineResult, error) {
+func runWithClients(ctx context.Context, logger *slog.Logger, cfg *models.Config, dates models.DateRange, kassas []string, ftpClient ftp.FTPClient, loader fileLoader, result *PipelineResult) (_ *PipelineResult, err error) {
+	ctx, span := tracing.Start(ctx, "pipeline.run",
+		attribute.String("etl.dates", dates.String()),
+		attribute.StringSlice("etl.kassas", kassas),
+	)
+	defer func() {
+		span.SetAttributes(
+			attribute.String("etl.status", string(result.Status)),
+			attribute.Int("etl.files_processed", result.FilesProcessed),
+			attribute.Int("etl.transactions_loaded", result.TransactionsLoaded),
+			attribute.Int("etl.errors", result.Errors),
+		)
+		tracing.End(span, err)
+	}()
 	issues := newIssueCollector()
 
 	// Шаг 1: Координация загрузки по каждой папке отдельно.
@@ -162,7 +197,7 @@ func runWithClients(ctx context.Context, logger *slog.Logger, cfg *models.Config
 		"kassa_structure", fmt.Sprintf("%v", cfg.KassaStructure),
 	)
 
-	stats, err := processFilesFromFTPFunc(ctx, ftpClient, loader, cfg, date, logger)
+	stats, err := processFilesFromFTPFunc(ctx, ftpClient, loader, cfg, dates, kassas, logger)
 	if err != nil {
 		result.ErrorMessage = fmt.Sprintf("Failed to process files from FTP: %v", err)
 		issues.Merge(stats)
@@ -187,6 +222,19 @@ func runWithClients(ctx context.Context, logger *slog.Logger, cfg *models.Config
 	result.ErrorSamples = issues.CloneSamples()
 	result.KassaDetails = stats.KassaDetails
 	result.TransactionDetails = stats.TransactionDetails
+	if errors.Is(ctx.Err(), context.Canceled) {
+		// Отмена: возвращаем статистику уже загруженных файлов
+		result.Status = PipelineStatusCanceled
+		result.ErrorMessage = "pipeline canceled"
+		logger.WarnContext(ctx, "ETL pipeline canceled",
+			"log_kind", "loki_operational",
+			"date", dates.String(),
+			"files_processed", result.FilesProcessed,
+			"transactions_loaded", result.TransactionsLoaded,
+			"event", "etl_canceled",
+		)
+		return result, fmt.Errorf("pipeline canceled: %w", ctx.Err())
+	}
 	result.Status = PipelineStatusCompleted
 	if result.Errors > 0 {
 		result.Status = PipelineStatusPartial
@@ -202,7 +250,7 @@ func runWithClients(ctx context.Context, logger *slog.Logger, cfg *models.Config
 	}
 	logMethod(ctx, message,
 		"log_kind", "loki_operational",
-		"date", date,
+		"date", dates.String(),
 		"status", result.Status,
 		"files_processed", result.FilesProcessed,
 		"files_skipped", result.FilesSkipped,
@@ -215,7 +263,7 @@ func runWithClients(ctx context.Context, logger *slog.Logger, cfg *models.Config
 	durationMs := time.Since(result.StartTime).Milliseconds()
 	logger.InfoContext(ctx, "ETL run summary",
 		"log_kind", "loki_operational",
-		"date", date,
+		"date", dates.String(),
 		"status", result.Status,
 		"duration_ms", durationMs,
 		"files_processed", result.FilesProcessed,
@@ -232,7 +280,7 @@ func runWithClients(ctx context.Context, logger *slog.Logger, cfg *models.Config
 		}
 		logger.InfoContext(ctx, "ETL kassa summary",
 			"log_kind", "loki_operational",
-			"date", date,
+			"date", dates.String(),
 			"kassa_code", detail.KassaCode,
 			"folder_name", detail.FolderName,
 			"source_folder", detail.SourceFolder,
@@ -284,6 +332,8 @@ type fileProcessOutcome struct {
 	LoadedTransactions int
 	TransactionDetails []map[string]interface{}
 	Recovered          bool
+	Diagnostics        *parser.Diagnostics
+	RejectedLines      int
 }
 
 type stagedFileError struct {
@@ -314,9 +364,13 @@ func stageForFileError(err error) string {
 	return "file_process_error"
 }
 
-// processFilesFromFTP координирует полный цикл загрузки по каждой папке.
-func processFilesFromFTP(ctx context.Context, ftpClient ftp.FTPClient, loader fileLoader, cfg *models.Config, date string, logger *slog.Logger) (*ProcessingStats, error) {
-	folders := ftp.GetAllKassaFolders(cfg)
+// processFilesFromFTP координирует полный цикл загрузки по каждой папке,
+// выбранной kassas (все папки, если kassas пуст).
+func processFilesFromFTP(ctx context.Context, ftpClient ftp.FTPClient, loader fileLoader, cfg *models.Config, dates models.DateRange, kassas []string, logger *slog.Logger) (*ProcessingStats, error) {
+	folders, err := ftp.SelectKassaFolders(cfg, kassas)
+	if err != nil {
+		return nil, err
+	}
 
 	stats := &ProcessingStats{
 		TransactionDetails: make([]TransactionTypeStats, 0),
@@ -347,7 +401,9 @@ func processFilesFromFTP(ctx context.Context, ftpClient ftp.FTPClient, loader fi
 
 	logger.InfoContext(ctx, "Found kassa folders to process",
 		"count", len(folders),
+		"kassas", kassas,
 		"response_wait_delay", cfg.WaitDelayMinutes.String(),
+		"response_poll_interval", cfg.EffectiveResponsePollInterval().String(),
 		"lock_retry_delay", cfg.RetryDelay.String(),
 		"event", "ftp_folders_found",
 	)
@@ -357,7 +413,18 @@ func processFilesFromFTP(ctx context.Context, ftpClient ftp.FTPClient, loader fi
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			folderStats := processFolderLoad(ctx, ftpClient, loader, cfg, date, folder, logger)
+			// Папка работает с пулом своего сервера обмена
+			folderClient, err := ftp.ForFolder(ftpClient, folder)
+			if err != nil {
+				logger.WarnContext(ctx, "Kassa folder has no exchange endpoint, routing by path",
+					"kassa_code", folder.KassaCode,
+					"folder", folder.FolderName,
+					"error", err.Error(),
+					"event", "ftp_endpoint_not_found",
+				)
+				folderClient = ftpClient
+			}
+			folderStats := processFolderLoad(ctx, folderClient, loader, cfg, dates, folder, logger)
 
 			statsMutex.Lock()
 			defer statsMutex.Unlock()
@@ -420,13 +487,40 @@ func processFilesFromFTP(ctx context.Context, ftpClient ftp.FTPClient, loader fi
 	return stats, nil
 }
 
-func processFolderLoad(ctx context.Context, ftpClient ftp.FTPClient, loader fileLoader, cfg *models.Config, date string, folder models.KassaFolder, logger *slog.Logger) folderRunResult {
+// ftpEndpointName возвращает имя сервера обмена папки из FTP_ENDPOINTS_FILE,
+// пустое для папок на FTP_HOST
+func ftpEndpointName(cfg *models.Config, folder models.KassaFolder) string {
+	if endpoint, ok := cfg.FTPEndpointFor(folder.KassaCode, folder.FolderName); ok {
+		return endpoint.Name
+	}
+	return ""
+}
+
+func processFolderLoad(ctx context.Context, ftpClient ftp.FTPClient, loader fileLoader, cfg *models.Config, dates models.DateRange, folder models.KassaFolder, logger *slog.Logger) (result folderRunResult) {
 	sourceFolder := folder.KassaCode + "/" + folder.FolderName
-	result := folderRunResult{
+	ctx, span := tracing.Start(ctx, "pipeline.folder", attribute.String("etl.source_folder", sourceFolder))
+	emitEvent := kassaEventsFrom(ctx)
+	defer func() {
+		emitEvent(result.kassaEvent("", true))
+		span.SetAttributes(
+			attribute.String("etl.folder_status", result.Detail.Status),
+			attribute.Int("etl.files_processed", result.Detail.FilesProcessed),
+			attribute.Int("etl.files_failed", result.Detail.FilesFailed),
+		)
+		var issue error
+		if result.Detail.LastIssueMessage != "" {
+			issue = errors.New(result.Detail.LastIssueStage + ": " + result.Detail.LastIssueMessage)
+		}
+		tracing.End(span, issue)
+	}()
+	// Вызовы FTP уровня папки попадают в ее span, вызовы файла — в span файла
+	folderFTP := ftp.WithTracing(ctx, ftpClient)
+	result = folderRunResult{
 		Detail: KassaProcessingStats{
 			KassaCode:    folder.KassaCode,
 			FolderName:   folder.FolderName,
 			SourceFolder: sourceFolder,
+			Endpoint:     ftpEndpointName(cfg, folder),
 			Status:       "pending",
 			RequestPath:  folder.RequestPath,
 			ResponsePath: folder.ResponsePath,
@@ -434,15 +528,13 @@ func processFolderLoad(ctx context.Context, ftpClient ftp.FTPClient, loader file
 		ErrorBreakdown:     make(map[string]int),
 		TransactionDetails: make(map[string]int),
 	}
+	// notify публикует смену этапа или итог файла file
+	notify := func(file string) {
+		emitEvent(result.kassaEvent(file, false))
+	}
+	notify("")
 
-	recordError := func(stage, file, path string, err error) {
-		result.ErrorBreakdown[stage]++
-		result.Detail.FilesFailed++
-		result.Detail.Status = stage
-		result.Detail.LastIssueStage = stage
-		if err != nil {
-			result.Detail.LastIssueMessage = err.Error()
-		}
+	addSample := func(stage, file, path string, err error) {
 		if len(result.ErrorSamples) >= maxErrorSamples {
 			return
 		}
@@ -458,6 +550,31 @@ func processFolderLoad(ctx context.Context, ftpClient ftp.FTPClient, loader file
 		}
 		result.ErrorSamples = append(result.ErrorSamples, sample)
 	}
+	recordError := func(stage, file, path string, err error) {
+		result.ErrorBreakdown[stage]++
+		result.Detail.FilesFailed++
+		result.Detail.Status = stage
+		result.Detail.LastIssueStage = stage
+		if err != nil {
+			result.Detail.LastIssueMessage = err.Error()
+		}
+		addSample(stage, file, path, err)
+	}
+	// Подставленные в lenient-режиме значения файл не роняют, но попадают в
+	// error_breakdown как parse_<причина>
+	recordDiagnostics := func(file string, diagnostics *parser.Diagnostics) {
+		if diagnostics == nil || diagnostics.Total == 0 {
+			return
+		}
+		for _, reason := range diagnostics.Reasons() {
+			result.ErrorBreakdown["parse_"+reason] += diagnostics.ByReason[reason]
+		}
+		result.Detail.ParseDiagnostics += diagnostics.Total
+		if len(diagnostics.Items) > 0 {
+			first := diagnostics.Items[0]
+			addSample("parse_"+first.Reason, file, folder.ResponsePath, fmt.Errorf("%d values coerced, first at %s", diagnostics.Total, first))
+		}
+	}
 
 	releaseLock, lockWait, err := defaultFolderLocks.acquire(ctx, sourceFolder, cfg.RetryDelay, cfg.WaitDelayMinutes)
 	if err != nil {
@@ -468,16 +585,18 @@ func processFolderLoad(ctx context.Context, ftpClient ftp.FTPClient, loader file
 	defer releaseLock()
 	result.Detail.LockWait = lockWait.String()
 	result.Detail.Status = "lock_acquired"
+	notify("")
 
-	deletedResponses, err := cleanupFolderPath(ctx, ftpClient, folder.ResponsePath, sourceFolder, "response", logger)
+	deletedResponses, err := cleanupFolderPath(ctx, folderFTP, folder.ResponsePath, sourceFolder, "response", logger)
 	if err != nil {
 		recordError("response_cleanup_failed", "", folder.ResponsePath, err)
 		return result
 	}
 	result.Detail.DeletedResponses = deletedResponses
 	result.Detail.Status = "response_cleaned"
+	notify("")
 
-	remainingResponseFiles, err := ftpClient.ListFiles(folder.ResponsePath)
+	remainingResponseFiles, err := folderFTP.ListFiles(folder.ResponsePath)
 	if err != nil {
 		recordError("response_preflight_failed", "", folder.ResponsePath, err)
 		return result
@@ -487,50 +606,102 @@ func processFolderLoad(ctx context.Context, ftpClient ftp.FTPClient, loader file
 		return result
 	}
 
-	deletedRequests, err := cleanupFolderPath(ctx, ftpClient, folder.RequestPath, sourceFolder, "request", logger)
+	deletedRequests, err := cleanupFolderPath(ctx, folderFTP, folder.RequestPath, sourceFolder, "request", logger)
 	if err != nil {
 		recordError("request_cleanup_failed", "", folder.RequestPath, err)
 		return result
 	}
 	result.Detail.DeletedRequests = deletedRequests
 	result.Detail.Status = "request_cleaned"
+	notify("")
 
-	if err := ftpClient.SendRequestToKassa(folder, date); err != nil {
+	if err := folderFTP.SendRequestToKassa(folder, dates); err != nil {
 		recordError("request_send_failed", "", folder.RequestPath, err)
 		return result
 	}
 	result.Detail.Status = "request_sent"
+	notify("")
+	// Отмененная загрузка не оставляет запрос в папке: иначе касса выгрузит
+	// ответ, который никто не заберет
+	defer func() {
+		if ctx.Err() == nil {
+			return
+		}
+		cleanupCtx := context.WithoutCancel(ctx)
+		if _, err := cleanupFolderPath(cleanupCtx, folderFTP, folder.RequestPath, sourceFolder, "request", logger); err != nil {
+			addSample("request_cleanup_failed", "", folder.RequestPath, err)
+		}
+	}()
 
-	if err := waitForResponses(ctx, cfg.WaitDelayMinutes); err != nil {
-		recordError("response_wait_canceled", "", folder.ResponsePath, err)
-		return result
-	}
 	result.Detail.Status = "waiting_response"
-
-	allFiles, responseFiles, skippedFiles, err := listProcessableResponseFiles(ftpClient, folder.ResponsePath)
+	notify("")
+	deadline := cfg.ResponseDeadline(folder.KassaCode, folder.FolderName)
+	wait, err := awaitResponseFiles(ctx, folderFTP, folder.ResponsePath, deadline, cfg.EffectiveResponsePollInterval(), cfg.EffectiveResponsePollMaxBackoff())
+	result.Detail.ResponsePolls = wait.polls
 	if err != nil {
-		recordError("response_list_failed", "", folder.ResponsePath, err)
+		if ctx.Err() != nil {
+			recordError("response_wait_canceled", "", folder.ResponsePath, err)
+		} else {
+			recordError("response_list_failed", "", folder.ResponsePath, err)
+		}
 		return result
 	}
+	responseFiles := wait.responseFiles
 
-	result.Detail.FilesFound = len(allFiles)
-	result.Detail.FilesSkipped = skippedFiles
+	result.Detail.FilesFound = len(wait.allFiles)
+	result.Detail.FilesSkipped = wait.skipped
 	result.Detail.FilesQueued = len(responseFiles)
 
 	if len(responseFiles) == 0 {
-		recordError("no_response", "", folder.ResponsePath, fmt.Errorf("no processable response files found after wait"))
+		recordError("no_response", "", folder.ResponsePath, fmt.Errorf("no processable response files found within %s", deadline))
 		return result
 	}
+	result.Detail.ResponseLatency = wait.latency.String()
+	metrics.ObserveKassaResponse(sourceFolder, wait.latency)
+	if wait.ready {
+		logger.InfoContext(ctx, "Kassa response ready",
+			"source_folder", sourceFolder,
+			"response_latency", wait.latency.String(),
+			"response_polls", wait.polls,
+			"files_queued", len(responseFiles),
+			"event", "kassa_response_ready",
+		)
+	} else {
+		// Срок истек, а файлы еще меняются: берем их как есть, как раньше после
+		// фиксированной паузы
+		logger.WarnContext(ctx, "Kassa response not settled before deadline",
+			"source_folder", sourceFolder,
+			"response_deadline", deadline.String(),
+			"response_polls", wait.polls,
+			"files_queued", len(responseFiles),
+			"event", "kassa_response_unsettled",
+		)
+	}
 	result.Detail.Status = "processing_response"
+	notify("")
 
 	for _, file := range responseFiles {
-		outcome, err := processFile(ctx, ftpClient, loader, cfg, file.Name, folder, date, logger)
+		if ctx.Err() != nil {
+			result.Detail.Status = "canceled"
+			return result
+		}
+		fileCtx, fileSpan := tracing.Start(ctx, "pipeline.file", attribute.String("etl.file", file.Name))
+		outcome, err := processFile(fileCtx, ftp.WithTracing(fileCtx, ftpClient), loader, cfg, file.Name, folder, dates, logger)
+		fileSpan.SetAttributes(attribute.Int("etl.transactions_loaded", outcome.LoadedTransactions))
+		tracing.End(fileSpan, err)
 		if err != nil {
 			recordError(stageForFileError(err), file.Name, folder.ResponsePath, err)
+			notify(file.Name)
 			continue
 		}
 
 		result.Detail.FilesProcessed++
+		recordDiagnostics(file.Name, outcome.Diagnostics)
+		if outcome.RejectedLines > 0 {
+			result.ErrorBreakdown["rejected_line"] += outcome.RejectedLines
+			result.Detail.RejectedLines += outcome.RejectedLines
+			addSample("rejected_line", file.Name, folder.ResponsePath, fmt.Errorf("%d lines moved to etl_rejected_lines", outcome.RejectedLines))
+		}
 		if outcome.Recovered {
 			result.Detail.FilesRecovered++
 		}
@@ -546,6 +717,11 @@ func processFolderLoad(ctx context.Context, ftpClient ftp.FTPClient, loader file
 			"recovered", outcome.Recovered,
 			"event", "file_process_success",
 		)
+		notify(file.Name)
+	}
+
+	if result.Detail.FilesProcessed > 0 && ctx.Err() == nil {
+		reconcileFolderShifts(ctx, loader, dates, sourceFolder, &result, addSample, logger)
 	}
 
 	if len(result.ErrorBreakdown) == 0 {
@@ -557,6 +733,61 @@ func processFolderLoad(ctx context.Context, ftpClient ftp.FTPClient, loader file
 	return result
 }
 
+// reconcileFolderShifts сверяет смены папки за диапазон дат с Z-отчетами. Расхождения
+// попадают в error_breakdown как shift_mismatch и делают статус partial, смены
+// без Z-отчета (еще не закрытые) только считаются.
+func reconcileFolderShifts(ctx context.Context, loader fileLoader, dates models.DateRange, sourceFolder string, result *folderRunResult, addSample func(stage, file, path string, err error), logger *slog.Logger) {
+	shifts, err := loader.ReconcileShifts(ctx, sourceFolder, dates)
+	if err != nil {
+		result.ErrorBreakdown["shift_reconciliation_failed"]++
+		result.Detail.LastIssueStage = "shift_reconciliation_failed"
+		result.Detail.LastIssueMessage = err.Error()
+		addSample("shift_reconciliation_failed", "", "", err)
+		logger.ErrorContext(ctx, "Shift reconciliation failed",
+			"source_folder", sourceFolder,
+			"date", dates.String(),
+			"error", err.Error(),
+			"event", "shift_reconciliation_error",
+		)
+		return
+	}
+
+	result.Detail.ShiftsReconciled = len(shifts)
+	for _, shift := range shifts {
+		switch shift.Status {
+		case models.ShiftReconciliationNoZReport:
+			result.Detail.ShiftsNoZReport++
+		case models.ShiftReconciliationMismatch:
+			result.Detail.ShiftMismatches = append(result.Detail.ShiftMismatches, shift)
+			result.ErrorBreakdown["shift_mismatch"]++
+			addSample("shift_mismatch", "", "", fmt.Errorf("kassa %d shift %d: z_revenue %.2f, items_revenue %.2f, payments_revenue %.2f",
+				shift.CashRegisterCode, shift.ShiftNumber, shift.ZRevenue, shift.ItemsRevenue, shift.PaymentsRevenue))
+			logger.WarnContext(ctx, "Shift totals do not match Z-report",
+				"source_folder", sourceFolder,
+				"cash_register_code", shift.CashRegisterCode,
+				"shift_number", shift.ShiftNumber,
+				"z_transaction_type", shift.ZTransactionType,
+				"z_revenue", shift.ZRevenue,
+				"items_difference", shift.ItemsDifference,
+				"payments_difference", shift.PaymentsDifference,
+				"event", "shift_reconciliation_mismatch",
+			)
+		}
+	}
+	if len(result.Detail.ShiftMismatches) > 0 {
+		result.Detail.LastIssueStage = "shift_mismatch"
+		result.Detail.LastIssueMessage = fmt.Sprintf("%d shifts do not match Z-report", len(result.Detail.ShiftMismatches))
+	}
+	logger.InfoContext(ctx, "Shift reconciliation complete",
+		"source_folder", sourceFolder,
+		"date", dates.String(),
+		"shifts", len(shifts),
+		"mismatches", len(result.Detail.ShiftMismatches),
+		"without_z_report", result.Detail.ShiftsNoZReport,
+		"event", "shift_reconciliation_complete",
+	)
+}
+
 func cleanupFolderPath(ctx context.Context, ftpClient ftp.FTPClient, path, sourceFolder, folderKind string, logger *slog.Logger) (int, error) {
 	files, err := ftpClient.ListFiles(path)
 	if err != nil {
@@ -601,7 +832,7 @@ func listProcessableResponseFiles(ftpClient ftp.FTPClient, responsePath string)
 
 // processFile обрабатывает один файл из FTP
 // Возвращает количество транзакций, детальную статистику и ошибку
-func processFile(ctx context.Context, ftpClient ftp.FTPClient, loader fileLoader, cfg *models.Config, filename string, folder models.KassaFolder, requestedDate string, logger *slog.Logger) (fileProcessOutcome, error) {
+func processFile(ctx context.Context, ftpClient ftp.FTPClient, loader fileLoader, cfg *models.Config, filename string, folder models.KassaFolder, dates models.DateRange, logger *slog.Logger) (fileProcessOutcome, error) {
 	outcome := fileProcessOutcome{}
 	store := newFileLifecycleStore(cfg.LocalDir)
 
@@ -609,7 +840,10 @@ func processFile(ctx context.Context, ftpClient ftp.FTPClient, loader fileLoader
 	// Используем уникальный путь для локального файла, включая информацию о папке,
 	// чтобы избежать конфликтов при параллельной обработке файлов с одинаковыми именами из разных папок
 	remotePath := folder.ResponsePath + "/" + filename
-	logicalKey := remotePath + "|" + requestedDate
+	// Состояние файла за диапазон хранится по дням; локальный lifecycle и
+	// отклоненные строки привязаны к ключу первого дня
+	logicalKey := models.FileLoadLogicalKey(remotePath, dates.From)
+	requestedDate := dates.String()
 	// Создаем уникальный локальный путь: LocalDir/KassaCode/FolderName/filename
 	localPath := fmt.Sprintf("%s/%s/%s/%s", cfg.LocalDir, folder.KassaCode, folder.FolderName, filename)
 
@@ -640,7 +874,7 @@ func processFile(ctx context.Context, ftpClient ftp.FTPClient, loader fileLoader
 	if err != nil {
 		return outcome, newStagedFileError("file_hash_error", fmt.Errorf("failed to hash file: %w", err))
 	}
-	dbState, err := loader.GetFileLoadState(ctx, logicalKey)
+	dbState, err := loadRangeFileState(ctx, loader, remotePath, dates, contentHash)
 	if err != nil {
 		return outcome, newStagedFileError("file_state_load_error", fmt.Errorf("failed to load durable file state: %w", err))
 	}
@@ -680,19 +914,33 @@ func processFile(ctx context.Context, ftpClient ftp.FTPClient, loader fileLoader
 		return outcome, nil
 	}
 
-	// Парсим файл
-	transactions, header, err := parser.ParseFile(localPath, sourceFolder)
+	parseMode, err := parser.ParseModeFromString(cfg.ParseMode)
 	if err != nil {
-		record := newFileLifecycleRecord(store, logicalKey, remotePath, requestedDate, filename, sourceFolder, nil, contentHash, 0)
-		if saveErr := store.Save(record.withStage(fileLifecycleStageParseFailed, err.Error())); saveErr != nil {
-			return outcome, newStagedFileError("file_parse_error", fmt.Errorf("failed to parse file: %w (also failed to persist parse failure: %v)", err, saveErr))
-		}
-		return outcome, newStagedFileError("file_parse_error", fmt.Errorf("failed to parse file: %w", err))
+		return outcome, newStagedFileError("file_parse_error", err)
+	}
+	// Кодировка определяется один раз на файл (или берется из KASSA_STRUCTURE)
+	// и дальше используется для всех проходов по нему
+	configuredEncoding, err := parser.ParseEncoding(cfg.KassaEncodings[sourceFolder])
+	if err != nil {
+		return outcome, newStagedFileError("file_parse_error", err)
+	}
+	encoding, err := parser.ResolveFileEncoding(localPath, configuredEncoding)
+	if err != nil {
+		return outcome, newStagedFileError("file_parse_error", fmt.Errorf("failed to detect file encoding: %w", err))
+	}
+	parseOpts := parser.ParseOptions{Mode: parseMode, Encoding: encoding}
+
+	// Читаем только заголовок: транзакции разбираются потоково во время загрузки
+	header, err := parser.ReadFileHeaderWithEncoding(localPath, encoding)
+	if err != nil {
+		return outcome, quarantineParseFailure(store, logicalKey, remotePath, requestedDate, filename, sourceFolder, contentHash, err)
 	}
 
 	// Выводим информацию о заголовке файла
 	logger.DebugContext(ctx, "File header",
 		"file", filename,
+		"encoding", encoding,
+		"encoding_override", configuredEncoding != parser.EncodingAuto,
 		"processed", header.Processed,
 		"db_id", header.DBID,
 		"report_number", header.ReportNum,
@@ -701,8 +949,21 @@ func processFile(ctx context.Context, ftpClient ftp.FTPClient, loader fileLoader
 
 	// Проверяем, обработан ли файл уже
 	if header.Processed {
+		// Тело файла все равно проверяем, чтобы битый файл попал в карантин, а не был молча финализирован.
+		// Неразбираемые строки, как и при загрузке, не отправляют файл в карантин в lenient-режиме;
+		// в etl_rejected_lines они не пишутся, потому что файл не загружается
+		checkOpts := parseOpts
+		skippedLines := 0
+		checkOpts.OnReject = func(parser.LineRejection) error {
+			skippedLines++
+			return nil
+		}
+		if _, err := parser.StreamFileWithOptions(localPath, sourceFolder, checkOpts, nil, func(parser.ParsedTransaction) error { return nil }); err != nil {
+			return outcome, quarantineParseFailure(store, logicalKey, remotePath, requestedDate, filename, sourceFolder, contentHash, err)
+		}
 		logger.InfoContext(ctx, "File is already marked as processed in header, finalizing FTP state",
 			"file", filename,
+			"skipped_lines", skippedLines,
 			"event", "file_already_processed",
 		)
 		record := newFileLifecycleRecord(store, logicalKey, remotePath, requestedDate, filename, sourceFolder, header, contentHash, 0)
@@ -713,20 +974,6 @@ func processFile(ctx context.Context, ftpClient ftp.FTPClient, loader fileLoader
 		return outcome, nil
 	}
 
-	// Подсчитываем количество транзакций перед загрузкой
-	transactionCount := loader.GetTransactionCount(transactions)
-	manifest, err := buildTransactionManifest(transactions)
-	if err != nil {
-		return outcome, newStagedFileError("file_manifest_error", fmt.Errorf("failed to build transaction manifest: %w", err))
-	}
-	record := newFileLifecycleRecord(store, logicalKey, remotePath, requestedDate, filename, sourceFolder, header, contentHash, transactionCount).withManifest(manifest)
-
-	logger.InfoContext(ctx, "Found transactions in file",
-		"file", filename,
-		"transaction_count", transactionCount,
-		"event", "transactions_found",
-	)
-
 	staleManifest := map[string][]int64(nil)
 	if dbState != nil && dbState.ContentHash != contentHash {
 		staleManifest = dbState.TransactionManifest
@@ -739,30 +986,71 @@ func processFile(ctx context.Context, ftpClient ftp.FTPClient, loader fileLoader
 		)
 	}
 
-	// Загружаем данные в базу данных только если есть транзакции
-	if transactionCount > 0 || len(staleManifest) > 0 {
-		// Создаем отдельный контекст для загрузки данных с увеличенным таймаутом
-		// Сохраняем родительский контекст для корректной propagation отмены
-		loadCtx, loadCancel := context.WithTimeout(ctx, cfg.EffectivePipelineLoadTimeout())
-		defer loadCancel()
-
-		durableState := &models.FileLoadState{
-			LogicalKey:          logicalKey,
-			RemotePath:          remotePath,
-			RequestedDate:       requestedDate,
-			SourceFolder:        sourceFolder,
-			ContentHash:         contentHash,
-			TransactionManifest: manifest,
-		}
-		if err := loader.LoadFileDataWithReconcile(loadCtx, durableState, staleManifest, transactions); err != nil {
-			return outcome, newStagedFileError("file_load_error", fmt.Errorf("failed to load data: %w", err))
+	// Создаем отдельный контекст для загрузки данных с увеличенным таймаутом
+	// Сохраняем родительский контекст для корректной propagation отмены
+	loadCtx, loadCancel := context.WithTimeout(ctx, cfg.EffectivePipelineLoadTimeout())
+	defer loadCancel()
+
+	durableState := &models.FileLoadState{
+		LogicalKey:      logicalKey,
+		RemotePath:      remotePath,
+		RequestedDate:   dates.From,
+		RequestedDateTo: dates.To,
+		SourceFolder:    sourceFolder,
+		ContentHash:     contentHash,
+		Encoding:        string(encoding),
+	}
+	// Файл разбирается заново при каждой попытке транзакции, в памяти держатся
+	// только батчи по cfg.BatchSize строк на таблицу
+	// Диагностики берутся из последней (успешной) попытки.
+	// Неразбираемые строки в lenient-режиме уходят в etl_rejected_lines
+	var diagnostics *parser.Diagnostics
+	source := func(emit repository.EmitFunc, reject repository.RejectFunc) error {
+		opts := parseOpts
+		opts.OnReject = func(rejection parser.LineRejection) error {
+			return reject(models.RejectedLine{
+				LineNumber:      rejection.Line,
+				RawLine:         rejection.Raw,
+				TransactionType: rejection.TransactionType,
+				Error:           rejection.Err.Error(),
+			})
 		}
+		var err error
+		diagnostics, err = parser.StreamFileWithOptions(localPath, sourceFolder, opts, nil, func(tx parser.ParsedTransaction) error {
+			return emit(tx.Table, tx.Value)
+		})
+		return err
+	}
+	loadResult, err := loader.LoadFileStream(loadCtx, durableState, staleManifest, cfg.BatchSize, source)
+	if err != nil {
+		if parser.IsParseError(err) {
+			return outcome, quarantineParseFailure(store, logicalKey, remotePath, requestedDate, filename, sourceFolder, contentHash, err)
+		}
+		return outcome, newStagedFileError("file_load_error", fmt.Errorf("failed to load data: %w", err))
+	}
+
+	transactionCount := loadResult.TransactionCount
+	outcome.RejectedLines = loadResult.RejectedLines
+	if diagnostics != nil && diagnostics.Total > 0 {
+		logger.WarnContext(ctx, "File loaded with coerced values",
+			"file", filename,
+			"parse_mode", parseMode,
+			"diagnostics", diagnostics.Total,
+			"by_reason", diagnostics.ByReason,
+			"event", "file_parse_diagnostics",
+		)
+		outcome.Diagnostics = diagnostics
+	}
+	record := newFileLifecycleRecord(store, logicalKey, remotePath, requestedDate, filename, sourceFolder, header, contentHash, transactionCount).withManifest(loadResult.Manifest)
+
+	if transactionCount > 0 || len(staleManifest) > 0 {
 		logger.InfoContext(ctx, "Successfully loaded transactions into database",
+			"file", filename,
 			"transaction_count", transactionCount,
 			"event", "transactions_loaded",
 		)
 		outcome.LoadedTransactions = transactionCount
-		outcome.TransactionDetails = loader.GetTransactionDetails(transactions)
+		outcome.TransactionDetails = loadResult.TransactionDetails
 	} else {
 		logger.DebugContext(ctx, "No transactions to load (file contains only header)",
 			"event", "no_transactions",
@@ -781,31 +1069,14 @@ func processFile(ctx context.Context, ftpClient ftp.FTPClient, loader fileLoader
 	return outcome, nil
 }
 
-func buildTransactionManifest(transactions map[string]interface{}) (map[string][]int64, error) {
-	manifest := make(map[string][]int64)
-	for tableName, data := range transactions {
-		rv := reflect.ValueOf(data)
-		if !rv.IsValid() || rv.Kind() != reflect.Slice || rv.Len() == 0 {
-			continue
-		}
-		ids := make([]int64, 0, rv.Len())
-		for i := 0; i < rv.Len(); i++ {
-			row := rv.Index(i)
-			if row.Kind() == reflect.Pointer {
-				row = row.Elem()
-			}
-			if row.Kind() != reflect.Struct {
-				return nil, fmt.Errorf("table %s row %d is not struct", tableName, i)
-			}
-			field := row.FieldByName("TransactionIDUnique")
-			if !field.IsValid() || field.Kind() != reflect.Int64 {
-				return nil, fmt.Errorf("table %s row %d missing TransactionIDUnique", tableName, i)
-			}
-			ids = append(ids, field.Int())
-		}
-		manifest[tableName] = ids
+// quarantineParseFailure запоминает файл с ошибкой разбора, чтобы не пытаться
+// загружать его повторно при следующих запусках
+func quarantineParseFailure(store *fileLifecycleStore, logicalKey, remotePath, requestedDate, filename, sourceFolder, contentHash string, parseErr error) error {
+	record := newFileLifecycleRecord(store, logicalKey, remotePath, requestedDate, filename, sourceFolder, nil, contentHash, 0)
+	if saveErr := store.Save(record.withStage(fileLifecycleStageParseFailed, parseErr.Error())); saveErr != nil {
+		return newStagedFileError("file_parse_error", fmt.Errorf("failed to parse file: %w (also failed to persist parse failure: %v)", parseErr, saveErr))
 	}
-	return manifest, nil
+	return newStagedFileError("file_parse_error", fmt.Errorf("failed to parse file: %w", parseErr))
 }
 
 // removeFile удаляет файл, игнорируя ошибки
@@ -813,16 +1084,17 @@ func removeFile(path string) error {
 	return os.Remove(path)
 }
 
-func waitForResponses(ctx context.Context, delay time.Duration) error {
-	timer := time.NewTimer(delay)
-	defer timer.Stop()
-
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-timer.C:
+// Summary возвращает результат в JSON для etl_operation_runs.result; nil, если
+// результата нет.
+func (r *PipelineResult) Summary() json.RawMessage {
+	if r == nil {
+		return nil
+	}
+	summary, err := json.Marshal(r)
+	if err != nil {
 		return nil
 	}
+	return summary
 }
 
 func finalizeResult(result *PipelineResult) {
@@ -832,6 +1104,11 @@ func finalizeResult(result *PipelineResult) {
 		result.Status = PipelineStatusFailed
 	}
 	result.Success = result.Status == PipelineStatusCompleted
+
+	metrics.ObservePipelineRun(string(result.Status), result.EndTime.Sub(result.StartTime))
+	for _, detail := range result.KassaDetails {
+		metrics.AddKassaFiles(detail.SourceFolder, detail.FilesProcessed, detail.FilesSkipped, detail.FilesFailed)
+	}
 }
 
 type issueCollector struct {
@@ -913,3 +1190,57 @@ func (c *issueCollector) Summary() string {
 	}
 	return fmt.Sprintf("ETL completed partially with %d errors; first issue at %s: %s", c.Total(), c.samples[0].Stage, c.samples[0].Error)
 }
+
+// loadRangeFileState собирает durable-состояние файла по всем дням диапазона.
+// Хеш совпадает с contentHash, только если все дни загружены из этого же
+// содержимого; иначе в манифест попадают строки дней, загруженных из другого
+// содержимого, — их нужно сверить перед перезагрузкой.
+func loadRangeFileState(ctx context.Context, loader fileLoader, remotePath string, dates models.DateRange, contentHash string) (*models.FileLoadState, error) {
+	days := dates.Days()
+	if len(days) == 1 {
+		return loader.GetFileLoadState(ctx, models.FileLoadLogicalKey(remotePath, days[0]))
+	}
+
+	combined := &models.FileLoadState{
+		LogicalKey:          models.FileLoadLogicalKey(remotePath, dates.From),
+		RemotePath:          remotePath,
+		RequestedDate:       dates.From,
+		ContentHash:         contentHash,
+		TransactionManifest: make(map[string][]int64),
+	}
+	var found, stale []*models.FileLoadState
+	for _, day := range days {
+		state, err := loader.GetFileLoadState(ctx, models.FileLoadLogicalKey(remotePath, day))
+		if err != nil {
+			return nil, err
+		}
+		if state == nil {
+			continue
+		}
+		found = append(found, state)
+		combined.SourceFolder = state.SourceFolder
+		if state.ContentHash != contentHash {
+			stale = append(stale, state)
+		}
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	manifestStates := found
+	switch {
+	case len(stale) > 0:
+		combined.ContentHash = stale[0].ContentHash
+		manifestStates = stale
+	case len(found) < len(days):
+		// Часть дней еще не загружена: файл загружается заново
+		combined.ContentHash = ""
+		manifestStates = nil
+	}
+	for _, state := range manifestStates {
+		for table, ids := range state.TransactionManifest {
+			combined.TransactionManifest[table] = append(combined.TransactionManifest[table], ids...)
+		}
+	}
+	return combined, nil
+}
@@ -9,20 +9,25 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	ftplib "github.com/jlaffaye/ftp"
 	ftpclient "github.com/user/go-frontol-loader/pkg/ftp"
 	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/repository"
 )
 
 type mockFileLoader struct {
+	rejected                  []models.RejectedLine
 	getTransactionCount       func(map[string]interface{}) int
 	loadFileData              func(context.Context, map[string]interface{}) error
 	loadFileDataWithReconcile func(context.Context, *models.FileLoadState, map[string][]int64, map[string]interface{}) error
 	getFileLoadState          func(context.Context, string) (*models.FileLoadState, error)
 	getDetails                func(map[string]interface{}) []map[string]interface{}
+	reconcileShifts           func(context.Context, string, models.DateRange) ([]models.ShiftReconciliation, error)
 }
 
 func (m *mockFileLoader) GetTransactionCount(transactions map[string]interface{}) int {
@@ -46,6 +51,45 @@ func (m *mockFileLoader) LoadFileDataWithReconcile(ctx context.Context, fileStat
 	return m.LoadFileData(ctx, transactions)
 }
 
+// LoadFileStream drains the source into a per-table map and delegates to the
+// map-based hooks, so tests can keep asserting on them.
+func (m *mockFileLoader) LoadFileStream(ctx context.Context, fileState *models.FileLoadState, staleManifest map[string][]int64, batchSize int, source repository.TransactionSource) (*repository.StreamLoadResult, error) {
+	rows := make(map[string][]interface{})
+	manifest := make(map[string][]int64)
+	var rejected []models.RejectedLine
+	err := source(func(tableName string, row interface{}) error {
+		rows[tableName] = append(rows[tableName], row)
+		manifest[tableName] = append(manifest[tableName], reflect.ValueOf(row).FieldByName("TransactionIDUnique").Int())
+		return nil
+	}, func(line models.RejectedLine) error {
+		line.SourceFolder = fileState.SourceFolder
+		line.LogicalKey = fileState.LogicalKey
+		rejected = append(rejected, line)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	transactions := make(map[string]interface{}, len(rows))
+	for tableName, tableRows := range rows {
+		transactions[tableName] = tableRows
+	}
+
+	count := m.GetTransactionCount(transactions)
+	if count > 0 || len(staleManifest) > 0 {
+		if err := m.LoadFileDataWithReconcile(ctx, fileState, staleManifest, transactions); err != nil {
+			return nil, err
+		}
+	}
+	m.rejected = append(m.rejected, rejected...)
+	return &repository.StreamLoadResult{
+		TransactionCount:   count,
+		Manifest:           manifest,
+		TransactionDetails: m.GetTransactionDetails(transactions),
+		RejectedLines:      len(rejected),
+	}, nil
+}
+
 func (m *mockFileLoader) GetFileLoadState(ctx context.Context, logicalKey string) (*models.FileLoadState, error) {
 	if m.getFileLoadState != nil {
 		return m.getFileLoadState(ctx, logicalKey)
@@ -53,6 +97,13 @@ func (m *mockFileLoader) GetFileLoadState(ctx context.Context, logicalKey string
 	return nil, nil
 }
 
+func (m *mockFileLoader) ReconcileShifts(ctx context.Context, sourceFolder string, dates models.DateRange) ([]models.ShiftReconciliation, error) {
+	if m.reconcileShifts != nil {
+		return m.reconcileShifts(ctx, sourceFolder, dates)
+	}
+	return nil, nil
+}
+
 func (m *mockFileLoader) GetTransactionDetails(transactions map[string]interface{}) []map[string]interface{} {
 	if m.getDetails != nil {
 		return m.getDetails(transactions)
@@ -66,7 +117,7 @@ func TestRunWithClientsMarksPartialStatusOnOperationalIssues(t *testing.T) {
 		processFilesFromFTPFunc = oldProcess
 	}()
 
-	processFilesFromFTPFunc = func(ctx context.Context, ftpClient ftpclient.FTPClient, loader fileLoader, cfg *models.Config, date string, logger *slog.Logger) (*ProcessingStats, error) {
+	processFilesFromFTPFunc = func(ctx context.Context, ftpClient ftpclient.FTPClient, loader fileLoader, cfg *models.Config, dates models.DateRange, kassas []string, logger *slog.Logger) (*ProcessingStats, error) {
 		return &ProcessingStats{
 			FilesProcessed:     1,
 			TransactionsLoaded: 12,
@@ -91,7 +142,8 @@ func TestRunWithClientsMarksPartialStatusOnOperationalIssues(t *testing.T) {
 		context.Background(),
 		slog.New(slog.NewTextHandler(io.Discard, nil)),
 		&models.Config{WaitDelayMinutes: time.Millisecond},
-		"2026-04-13",
+		models.SingleDay("2026-04-13"),
+		nil,
 		ftpMock,
 		&mockFileLoader{},
 		&PipelineResult{StartTime: time.Now(), Date: "2026-04-13", Status: PipelineStatusFailed},
@@ -150,7 +202,7 @@ func TestProcessFilePersistsPendingFinalizationState(t *testing.T) {
 		},
 	}
 
-	_, err := processFile(context.Background(), ftpMock, loader, &models.Config{LocalDir: localDir}, "response.txt", folder, "2024-12-01", logger)
+	_, err := processFile(context.Background(), ftpMock, loader, &models.Config{LocalDir: localDir}, "response.txt", folder, models.SingleDay("2024-12-01"), logger)
 	if err == nil {
 		t.Fatal("processFile() expected error when mark processed fails")
 	}
@@ -231,7 +283,7 @@ func TestProcessFileRecoversPendingFinalizationWithoutReload(t *testing.T) {
 		},
 	}
 
-	outcome, err := processFile(context.Background(), ftpMock, loader, &models.Config{LocalDir: localDir}, "response.txt", folder, "2024-12-01", logger)
+	outcome, err := processFile(context.Background(), ftpMock, loader, &models.Config{LocalDir: localDir}, "response.txt", folder, models.SingleDay("2024-12-01"), logger)
 	if err != nil {
 		t.Fatalf("processFile() unexpected error: %v", err)
 	}
@@ -289,7 +341,9 @@ func TestProcessFileQuarantinesRepeatedParseFailures(t *testing.T) {
 		},
 	}
 
-	_, err := processFile(context.Background(), ftpMock, loader, &models.Config{LocalDir: localDir}, "broken.txt", folder, "2024-12-01", logger)
+	// В lenient-режиме битая строка уходит в rejected, в карантин файл отправляет strict
+	cfg := &models.Config{LocalDir: localDir, ParseMode: "strict"}
+	_, err := processFile(context.Background(), ftpMock, loader, cfg, "broken.txt", folder, models.SingleDay("2024-12-01"), logger)
 	if err == nil {
 		t.Fatal("processFile() expected parse error, got nil")
 	}
@@ -310,7 +364,7 @@ func TestProcessFileQuarantinesRepeatedParseFailures(t *testing.T) {
 		t.Fatalf("expected parse_failed lifecycle record, got %#v", record)
 	}
 
-	_, err = processFile(context.Background(), ftpMock, loader, &models.Config{LocalDir: localDir}, "broken.txt", folder, "2024-12-01", logger)
+	_, err = processFile(context.Background(), ftpMock, loader, cfg, "broken.txt", folder, models.SingleDay("2024-12-01"), logger)
 	if err == nil {
 		t.Fatal("processFile() expected quarantined error on repeated parse failure")
 	}
@@ -325,6 +379,40 @@ func TestProcessFileQuarantinesRepeatedParseFailures(t *testing.T) {
 	}
 }
 
+func TestProcessFileSkipsRejectedLinesInProcessedFile(t *testing.T) {
+	localDir := t.TempDir()
+	folder := models.KassaFolder{
+		KassaCode:    "P13",
+		FolderName:   "P13",
+		ResponsePath: "/response/P13",
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	content := []byte("1\nDB_TEST\nREPORT_001\nnot-a-number;01.12.2024;10:30:00;1;1;100;1\n")
+	marked := 0
+
+	ftpMock := &ftpclient.MockClient{
+		DownloadFileFunc: func(remotePath, localPath string) error {
+			if err := os.MkdirAll(filepath.Dir(localPath), 0750); err != nil {
+				return err
+			}
+			return os.WriteFile(localPath, content, 0640)
+		},
+		MarkFileAsProcessedFunc: func(remotePath string) error {
+			marked++
+			return nil
+		},
+	}
+
+	// Обработанный файл с битой строкой финализируется так же, как загружался бы необработанный
+	outcome, err := processFile(context.Background(), ftpMock, &mockFileLoader{}, &models.Config{LocalDir: localDir}, "processed.txt", folder, models.SingleDay("2024-12-01"), logger)
+	if err != nil {
+		t.Fatalf("processFile() unexpected error: %v", err)
+	}
+	if !outcome.Recovered || marked != 1 {
+		t.Fatalf("recovered = %v, marked = %d; want finalized processed file", outcome.Recovered, marked)
+	}
+}
+
 func TestProcessFileReconcilesCorrectedReupload(t *testing.T) {
 	localDir := t.TempDir()
 	folder := models.KassaFolder{
@@ -380,7 +468,7 @@ func TestProcessFileReconcilesCorrectedReupload(t *testing.T) {
 		},
 	}
 
-	_, err = processFile(context.Background(), ftpMock, loader, &models.Config{LocalDir: localDir}, "response.txt", folder, "2024-12-01", logger)
+	_, err = processFile(context.Background(), ftpMock, loader, &models.Config{LocalDir: localDir}, "response.txt", folder, models.SingleDay("2024-12-01"), logger)
 	if err != nil {
 		t.Fatalf("processFile() unexpected error: %v", err)
 	}
@@ -432,7 +520,7 @@ func TestProcessFileDoesNotReconcileDifferentRequestedDates(t *testing.T) {
 		},
 	}
 
-	_, err = processFile(context.Background(), ftpMock, loader, &models.Config{LocalDir: localDir}, "response.txt", folder, "2024-12-02", logger)
+	_, err = processFile(context.Background(), ftpMock, loader, &models.Config{LocalDir: localDir}, "response.txt", folder, models.SingleDay("2024-12-02"), logger)
 	if err != nil {
 		t.Fatalf("processFile() unexpected error: %v", err)
 	}
@@ -441,6 +529,67 @@ func TestProcessFileDoesNotReconcileDifferentRequestedDates(t *testing.T) {
 	}
 }
 
+func TestProcessFileDateRangeIsDuplicateOnlyWhenEveryDayLoaded(t *testing.T) {
+	folder := models.KassaFolder{
+		KassaCode:    "P13",
+		FolderName:   "P13",
+		ResponsePath: "/response/P13",
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	samplePath := filepath.Join(findRepoRoot(t), "data", "response.txt")
+	hash, err := hashLocalFile(samplePath)
+	if err != nil {
+		t.Fatalf("hashLocalFile() unexpected error: %v", err)
+	}
+	dates := models.DateRange{From: "2024-12-01", To: "2024-12-02"}
+	ftpMock := &ftpclient.MockClient{
+		DownloadFileFunc: func(remotePath, localPath string) error {
+			return copyFile(samplePath, localPath)
+		},
+		MarkFileAsProcessedFunc: func(remotePath string) error { return nil },
+	}
+
+	loadedDays := map[string]bool{"/response/P13/response.txt|2024-12-01": true}
+	var gotState *models.FileLoadState
+	loadCalls := 0
+	loader := &mockFileLoader{
+		getTransactionCount: func(transactions map[string]interface{}) int { return 1346 },
+		getFileLoadState: func(ctx context.Context, logicalKey string) (*models.FileLoadState, error) {
+			if !loadedDays[logicalKey] {
+				return nil, nil
+			}
+			return &models.FileLoadState{LogicalKey: logicalKey, SourceFolder: "P13/P13", ContentHash: hash}, nil
+		},
+		loadFileDataWithReconcile: func(ctx context.Context, fileState *models.FileLoadState, staleManifest map[string][]int64, transactions map[string]interface{}) error {
+			loadCalls++
+			gotState = fileState
+			if len(staleManifest) != 0 {
+				t.Fatalf("staleManifest = %+v, want empty when loaded days match the content", staleManifest)
+			}
+			return nil
+		},
+	}
+
+	if _, err := processFile(context.Background(), ftpMock, loader, &models.Config{LocalDir: t.TempDir()}, "response.txt", folder, dates, logger); err != nil {
+		t.Fatalf("processFile() unexpected error: %v", err)
+	}
+	if loadCalls != 1 {
+		t.Fatalf("load calls = %d, want 1 while 2024-12-02 is not loaded", loadCalls)
+	}
+	if gotState.LogicalKey != "/response/P13/response.txt|2024-12-01" || gotState.RequestedDate != "2024-12-01" || gotState.RequestedDateTo != "2024-12-02" {
+		t.Fatalf("file state = %+v, want first-day key spanning the range", gotState)
+	}
+
+	loadedDays["/response/P13/response.txt|2024-12-02"] = true
+	outcome, err := processFile(context.Background(), ftpMock, loader, &models.Config{LocalDir: t.TempDir()}, "response.txt", folder, dates, logger)
+	if err != nil {
+		t.Fatalf("processFile() unexpected error: %v", err)
+	}
+	if loadCalls != 1 || !outcome.Recovered {
+		t.Fatalf("load calls = %d, recovered = %v; want duplicate finalize once every day is loaded", loadCalls, outcome.Recovered)
+	}
+}
+
 func TestProcessFileUsesDurableStateWhenLocalStateSaveFails(t *testing.T) {
 	localDir := t.TempDir()
 	folder := models.KassaFolder{
@@ -493,7 +642,7 @@ func TestProcessFileUsesDurableStateWhenLocalStateSaveFails(t *testing.T) {
 	}
 
 	cfg := &models.Config{LocalDir: localDir}
-	_, err := processFile(context.Background(), ftpMock, loader, cfg, "response.txt", folder, "2024-12-01", logger)
+	_, err := processFile(context.Background(), ftpMock, loader, cfg, "response.txt", folder, models.SingleDay("2024-12-01"), logger)
 	if err == nil || stageForFileError(err) != "file_state_save_error" {
 		t.Fatalf("processFile() error = %v, want file_state_save_error", err)
 	}
@@ -501,7 +650,7 @@ func TestProcessFileUsesDurableStateWhenLocalStateSaveFails(t *testing.T) {
 		t.Fatalf("first processFile() load calls = %d, want 1", loadCalls)
 	}
 
-	_, err = processFile(context.Background(), ftpMock, loader, &models.Config{LocalDir: localDir}, "response.txt", folder, "2024-12-01", logger)
+	_, err = processFile(context.Background(), ftpMock, loader, &models.Config{LocalDir: localDir}, "response.txt", folder, models.SingleDay("2024-12-01"), logger)
 	if err != nil {
 		t.Fatalf("second processFile() unexpected error: %v", err)
 	}
@@ -551,3 +700,142 @@ func findRepoRoot(t *testing.T) string {
 var _ fileLoader = (*mockFileLoader)(nil)
 var _ ftpclient.FTPClient = (*ftpclient.MockClient)(nil)
 var _ = ftplib.Entry{}
+
+func TestProcessFileStoresUnparseableLinesAsRejected(t *testing.T) {
+	localDir := t.TempDir()
+	folder := models.KassaFolder{
+		KassaCode:    "P13",
+		FolderName:   "P13",
+		ResponsePath: "/response/P13",
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	content := []byte("0\nDB_TEST\nREPORT_001\n12345;01.12.2024;10:30:00;1;001;100;1;ITEM001;GRP01;1000.50;5;5025.50;1;10;100.10;500.50;1;SKU001;1234567890;1000.00;01;0;0;0;;info;1;1;0;;0;0;;;0;;0;;;0;;;;\n7;01.12.2024;10:31:00;999;1;100\n")
+
+	ftpMock := &ftpclient.MockClient{
+		DownloadFileFunc: func(remotePath, localPath string) error {
+			if err := os.MkdirAll(filepath.Dir(localPath), 0750); err != nil {
+				return err
+			}
+			return os.WriteFile(localPath, content, 0640)
+		},
+	}
+	loader := &mockFileLoader{
+		getTransactionCount: func(transactions map[string]interface{}) int { return 1 },
+	}
+
+	outcome, err := processFile(context.Background(), ftpMock, loader, &models.Config{LocalDir: localDir}, "response.txt", folder, models.SingleDay("2024-12-01"), logger)
+	if err != nil {
+		t.Fatalf("processFile() unexpected error: %v", err)
+	}
+	if outcome.RejectedLines != 1 || outcome.LoadedTransactions != 1 {
+		t.Fatalf("outcome = %+v, want 1 loaded and 1 rejected line", outcome)
+	}
+	if len(loader.rejected) != 1 {
+		t.Fatalf("rejected lines = %+v, want 1", loader.rejected)
+	}
+	rejected := loader.rejected[0]
+	if rejected.LineNumber != 5 || rejected.TransactionType != 999 || rejected.LogicalKey != "/response/P13/response.txt|2024-12-01" || rejected.SourceFolder != "P13/P13" {
+		t.Fatalf("rejected line = %+v", rejected)
+	}
+
+	_, err = processFile(context.Background(), ftpMock, loader, &models.Config{LocalDir: t.TempDir(), ParseMode: "strict"}, "response.txt", folder, models.SingleDay("2024-12-01"), logger)
+	if stage := stageForFileError(err); stage != "file_parse_error" {
+		t.Fatalf("strict stageForFileError() = %q, want file_parse_error", stage)
+	}
+}
+
+func TestProcessFileRecordsDecodedEncodingInFileState(t *testing.T) {
+	folder := models.KassaFolder{
+		KassaCode:    "P13",
+		FolderName:   "P13",
+		ResponsePath: "/response/P13",
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	// Товар "Привет" в Windows-1251
+	line := "12345;01.12.2024;10:30:00;1;001;100;1;\xCF\xF0\xE8\xE2\xE5\xF2;GRP01;1000.50;5;5025.50;1;10;100.10;500.50;1;SKU001;1234567890;1000.00;01;0;0;0;;info;1;1;0;;0;0;;;0;;0;;;0;;;;"
+	content := []byte("0\nDB_TEST\nREPORT_001\n" + line + "\n")
+
+	ftpMock := &ftpclient.MockClient{
+		DownloadFileFunc: func(remotePath, localPath string) error {
+			if err := os.MkdirAll(filepath.Dir(localPath), 0750); err != nil {
+				return err
+			}
+			return os.WriteFile(localPath, content, 0640)
+		},
+	}
+
+	tests := []struct {
+		name         string
+		encodings    map[string]string
+		wantEncoding string
+		wantItem     string
+	}{
+		{name: "detected", wantEncoding: "cp1251", wantItem: "Привет"},
+		// Неверная ручная настройка побеждает автоопределение
+		{name: "override", encodings: map[string]string{"P13/P13": "utf-8"}, wantEncoding: "utf-8", wantItem: strings.Repeat("\uFFFD", 6)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var state *models.FileLoadState
+			var item string
+			loader := &mockFileLoader{
+				getTransactionCount: func(transactions map[string]interface{}) int { return 1 },
+				loadFileDataWithReconcile: func(ctx context.Context, fileState *models.FileLoadState, staleManifest map[string][]int64, transactions map[string]interface{}) error {
+					state = fileState
+					rows := transactions["tx_item_registration_1_11"].([]interface{})
+					item = rows[0].(models.TxItemRegistration1_11).ItemIdentifier
+					return nil
+				},
+			}
+
+			cfg := &models.Config{LocalDir: t.TempDir(), KassaEncodings: tt.encodings}
+			if _, err := processFile(context.Background(), ftpMock, loader, cfg, "response.txt", folder, models.SingleDay("2024-12-01"), logger); err != nil {
+				t.Fatalf("processFile() unexpected error: %v", err)
+			}
+			if state == nil || state.Encoding != tt.wantEncoding {
+				t.Fatalf("file state = %+v, want encoding %s", state, tt.wantEncoding)
+			}
+			if item != tt.wantItem {
+				t.Fatalf("item identifier = %q, want %q", item, tt.wantItem)
+			}
+		})
+	}
+}
+
+func TestRunWithClientsReturnsPartialStatsWhenCanceled(t *testing.T) {
+	oldProcess := processFilesFromFTPFunc
+	defer func() {
+		processFilesFromFTPFunc = oldProcess
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	processFilesFromFTPFunc = func(ctx context.Context, ftpClient ftpclient.FTPClient, loader fileLoader, cfg *models.Config, dates models.DateRange, kassas []string, logger *slog.Logger) (*ProcessingStats, error) {
+		cancel()
+		return &ProcessingStats{
+			FilesProcessed:     1,
+			TransactionsLoaded: 7,
+			ErrorBreakdown:     map[string]int{"response_wait_canceled": 1},
+		}, nil
+	}
+
+	result, err := runWithClients(
+		ctx,
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		&models.Config{},
+		models.SingleDay("2026-04-13"),
+		nil,
+		&ftpclient.MockClient{},
+		&mockFileLoader{},
+		&PipelineResult{StartTime: time.Now(), Date: "2026-04-13", Status: PipelineStatusFailed},
+	)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("runWithClients() error = %v, want context.Canceled", err)
+	}
+	if result.Status != PipelineStatusCanceled {
+		t.Fatalf("runWithClients() status = %q, want %q", result.Status, PipelineStatusCanceled)
+	}
+	if result.FilesProcessed != 1 || result.TransactionsLoaded != 7 {
+		t.Fatalf("runWithClients() stats = %d files, %d transactions; want partial stats", result.FilesProcessed, result.TransactionsLoaded)
+	}
+}
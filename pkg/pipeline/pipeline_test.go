@@ -2,6 +2,7 @@ package pipeline
 
 import (
 	"context"
+	"encoding/json"
 	"log/slog"
 	"os"
 	"testing"
@@ -98,7 +99,7 @@ func TestPipelineRunWithInvalidConfig(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	result, err := Run(ctx, logger, invalidCfg, "2024-12-01")
+	result, err := Run(ctx, logger, invalidCfg, models.SingleDay("2024-12-01"), nil)
 
 	// Pipeline should fail with invalid config
 	if err == nil {
@@ -151,7 +152,7 @@ func TestPipelineRunWithMinimalConfig(t *testing.T) {
 	defer cancel()
 
 	// This will likely fail at connection stage, but tests the pipeline structure
-	result, err := Run(ctx, logger, cfg, "2024-12-01")
+	result, err := Run(ctx, logger, cfg, models.SingleDay("2024-12-01"), nil)
 
 	// We expect either success or connection error, not a panic
 	if err != nil {
@@ -312,3 +313,19 @@ func TestRemoveFile(t *testing.T) {
 		t.Logf("removeFile returned error for empty path (expected): %v", err)
 	}
 }
+
+func TestPipelineResultSummary(t *testing.T) {
+	var missing *PipelineResult
+	if summary := missing.Summary(); summary != nil {
+		t.Fatalf("nil result Summary() = %s, want nil", summary)
+	}
+
+	result := &PipelineResult{Date: "2024-12-01", DateTo: "2024-12-03", Status: PipelineStatusPartial, FilesProcessed: 2, Errors: 1}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(result.Summary(), &decoded); err != nil {
+		t.Fatalf("Summary() is not JSON: %v", err)
+	}
+	if decoded["status"] != "partial" || decoded["date_to"] != "2024-12-03" || decoded["files_processed"] != float64(2) {
+		t.Fatalf("Summary() = %v", decoded)
+	}
+}
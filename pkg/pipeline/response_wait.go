@@ -0,0 +1,99 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+
+	ftplib "github.com/jlaffaye/ftp"
+	"github.com/user/go-frontol-loader/pkg/ftp"
+)
+
+// responseReadyMarker — файл, который Frontol пишет после выгрузки ответа
+const responseReadyMarker = "SaveResult001.txt"
+
+// responseWait — итог ожидания ответа кассы
+type responseWait struct {
+	allFiles      []*ftplib.Entry
+	responseFiles []*ftplib.Entry
+	skipped       int
+	polls         int
+	// latency — от отправки запроса до готовности ответа; ноль, если файлов нет
+	latency time.Duration
+	// ready — ответ готов; false значит, что срок истек и файлы берутся как есть
+	ready bool
+}
+
+// awaitResponseFiles опрашивает папку ответа, пока ответ не будет готов или не
+// истечет deadline. Пауза между опросами начинается с interval и удваивается
+// до maxBackoff. Ответ готов, когда появился SaveResult001.txt или размеры
+// всех файлов ответа не изменились с прошлого опроса.
+func awaitResponseFiles(ctx context.Context, ftpClient ftp.FTPClient, responsePath string, deadline, interval, maxBackoff time.Duration) (responseWait, error) {
+	started := time.Now()
+	var wait responseWait
+	var previous map[string]uint64
+	for {
+		if err := ctx.Err(); err != nil {
+			return wait, err
+		}
+		allFiles, responseFiles, skipped, err := listProcessableResponseFiles(ftpClient, responsePath)
+		if err != nil {
+			return wait, err
+		}
+		wait.allFiles, wait.responseFiles, wait.skipped = allFiles, responseFiles, skipped
+		wait.polls++
+
+		elapsed := time.Since(started)
+		if responseReady(allFiles, responseFiles, previous) {
+			wait.ready = true
+			wait.latency = elapsed
+			return wait, nil
+		}
+		if elapsed >= deadline {
+			if len(responseFiles) > 0 {
+				wait.latency = elapsed
+			}
+			return wait, nil
+		}
+
+		previous = make(map[string]uint64, len(responseFiles))
+		for _, file := range responseFiles {
+			previous[file.Name] = file.Size
+		}
+		if err := sleepContext(ctx, min(interval, deadline-elapsed)); err != nil {
+			return wait, err
+		}
+		interval = min(interval*2, maxBackoff)
+	}
+}
+
+// responseReady сообщает, дописан ли ответ: касса оставила маркер или файлы
+// ответа есть и их размеры совпадают с предыдущим опросом.
+func responseReady(allFiles, responseFiles []*ftplib.Entry, previous map[string]uint64) bool {
+	for _, file := range allFiles {
+		if file.Name == responseReadyMarker {
+			return true
+		}
+	}
+	if len(responseFiles) == 0 || previous == nil {
+		return false
+	}
+	for _, file := range responseFiles {
+		size, seen := previous[file.Name]
+		if !seen || size != file.Size {
+			return false
+		}
+	}
+	return true
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// documentKey identifies a Frontol document within a source folder. Missing
+// (NULL) numbers are stored as 0.
+type documentKey struct {
+	SourceFolder     string
+	CashRegisterCode int64
+	ShiftNumber      int64
+	DocumentNumber   int64
+}
+
+// derivedModel rebuilds tables computed from tx_* rows. It runs inside the
+// load transaction after the tx_* tables are written, for every document
+// whose rows in sourceTables were loaded or removed as stale, so a reload or
+// reconcile of a file always leaves the derived tables consistent with it.
+type derivedModel interface {
+	name() string
+	sourceTables() []string
+	// rebuild replaces the derived rows of keys and returns how many
+	// documents it wrote.
+	rebuild(ctx context.Context, tx pgx.Tx, keys []documentKey) (int, error)
+}
+
+// derivedScope collects the documents touched during one load attempt.
+type derivedScope struct {
+	models []derivedModel
+	tables map[string]bool
+	keys   map[documentKey]struct{}
+}
+
+func (l *Loader) newDerivedScope() *derivedScope {
+	scope := &derivedScope{
+		models: l.derived,
+		tables: make(map[string]bool),
+		keys:   make(map[documentKey]struct{}),
+	}
+	for _, model := range l.derived {
+		for _, tableName := range model.sourceTables() {
+			scope.tables[tableName] = true
+		}
+	}
+	return scope
+}
+
+// trackRows records the documents of rows loaded into tableName; data is a
+// slice of tx models as passed to LoadTxTable.
+func (s *derivedScope) trackRows(tableName string, data interface{}) error {
+	if !s.tables[tableName] {
+		return nil
+	}
+	rv := reflect.ValueOf(data)
+	if rv.Kind() != reflect.Slice {
+		return fmt.Errorf("unexpected %s data type: %T", tableName, data)
+	}
+	for i := 0; i < rv.Len(); i++ {
+		key, err := rowDocumentKey(rv.Index(i))
+		if err != nil {
+			return fmt.Errorf("table %s row %d: %w", tableName, i, err)
+		}
+		s.keys[key] = struct{}{}
+	}
+	return nil
+}
+
+// trackStale records the documents of the stale rows listed in manifest. It
+// must run before the rows are deleted.
+func (s *derivedScope) trackStale(ctx context.Context, tx pgx.Tx, sourceFolder string, manifest map[string][]int64) error {
+	if sourceFolder == "" {
+		return nil
+	}
+	for _, tableName := range orderedManifestTables(manifest) {
+		if !s.tables[tableName] {
+			continue
+		}
+		rows, err := tx.Query(ctx, fmt.Sprintf(`
+			SELECT DISTINCT COALESCE(cash_register_code, 0), COALESCE(shift_number, 0), COALESCE(document_number, 0)
+			FROM %s
+			WHERE source_folder = $1 AND transaction_id_unique = ANY($2)
+		`, tableName), sourceFolder, manifest[tableName])
+		if err != nil {
+			return fmt.Errorf("query stale documents in %s: %w", tableName, err)
+		}
+		for rows.Next() {
+			key := documentKey{SourceFolder: sourceFolder}
+			if err := rows.Scan(&key.CashRegisterCode, &key.ShiftNumber, &key.DocumentNumber); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan stale document in %s: %w", tableName, err)
+			}
+			s.keys[key] = struct{}{}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("query stale documents in %s: %w", tableName, err)
+		}
+	}
+	return nil
+}
+
+// rebuild runs every derived model over the tracked documents.
+func (s *derivedScope) rebuild(ctx context.Context, tx pgx.Tx) error {
+	if len(s.keys) == 0 {
+		return nil
+	}
+	keys := make([]documentKey, 0, len(s.keys))
+	for key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return documentKeyLess(keys[i], keys[j]) })
+
+	for _, model := range s.models {
+		written, err := model.rebuild(ctx, tx, keys)
+		if err != nil {
+			return fmt.Errorf("failed to rebuild %s: %w", model.name(), err)
+		}
+		slog.DebugContext(ctx, "Derived model rebuilt",
+			"model", model.name(),
+			"documents", len(keys),
+			"written", written,
+			"event", "derived_model_rebuilt",
+		)
+	}
+	return nil
+}
+
+func rowDocumentKey(rv reflect.Value) (documentKey, error) {
+	for rv.Kind() == reflect.Interface || rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return documentKey{}, fmt.Errorf("row is not struct: %s", rv.Kind())
+	}
+	key := documentKey{}
+	fields := []struct {
+		name string
+		dst  *int64
+	}{
+		{"CashRegisterCode", &key.CashRegisterCode},
+		{"ShiftNumber", &key.ShiftNumber},
+		{"DocumentNumber", &key.DocumentNumber},
+	}
+	for _, f := range fields {
+		field := rv.FieldByName(f.name)
+		if !field.IsValid() || field.Kind() != reflect.Int64 {
+			return documentKey{}, fmt.Errorf("row %s missing %s", rv.Type().Name(), f.name)
+		}
+		*f.dst = field.Int()
+	}
+	source := rv.FieldByName("SourceFolder")
+	if !source.IsValid() || source.Kind() != reflect.String {
+		return documentKey{}, fmt.Errorf("row %s missing SourceFolder", rv.Type().Name())
+	}
+	key.SourceFolder = source.String()
+	return key, nil
+}
+
+func documentKeyLess(a, b documentKey) bool {
+	if a.SourceFolder != b.SourceFolder {
+		return a.SourceFolder < b.SourceFolder
+	}
+	if a.CashRegisterCode != b.CashRegisterCode {
+		return a.CashRegisterCode < b.CashRegisterCode
+	}
+	if a.ShiftNumber != b.ShiftNumber {
+		return a.ShiftNumber < b.ShiftNumber
+	}
+	return a.DocumentNumber < b.DocumentNumber
+}
+
+// documentKeyArrays splits keys into the column arrays used with unnest.
+func documentKeyArrays(keys []documentKey) ([]string, []int64, []int64, []int64) {
+	folders := make([]string, len(keys))
+	cashRegisters := make([]int64, len(keys))
+	shifts := make([]int64, len(keys))
+	documents := make([]int64, len(keys))
+	for i, key := range keys {
+		folders[i] = key.SourceFolder
+		cashRegisters[i] = key.CashRegisterCode
+		shifts[i] = key.ShiftNumber
+		documents[i] = key.DocumentNumber
+	}
+	return folders, cashRegisters, shifts, documents
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/parser"
+)
+
+// unclosedShiftsQuery selects the shifts of the source folders with receipts
+// within a date range and no shift close (61) or Z-report (63) on any date,
+// so a shift closed after midnight is not reported. A shift is dated by its
+// first receipt.
+const unclosedShiftsQuery = `
+	SELECT r.source_folder, MIN(r.transaction_date)::text, r.cash_register_code, r.shift_number
+	FROM receipts r
+	WHERE r.source_folder = ANY($1) AND r.transaction_date BETWEEN $2::date AND $3::date
+	  AND NOT EXISTS (
+		SELECT 1 FROM tx_shift_close_61 c
+		WHERE c.source_folder = r.source_folder
+		  AND COALESCE(c.cash_register_code, 0) = r.cash_register_code
+		  AND COALESCE(c.shift_number, 0) = r.shift_number
+	  )
+	  AND NOT EXISTS (
+		SELECT 1 FROM tx_report_z_63 z
+		WHERE z.source_folder = r.source_folder
+		  AND COALESCE(z.cash_register_code, 0) = r.cash_register_code
+		  AND COALESCE(z.shift_number, 0) = r.shift_number
+	  )
+	GROUP BY r.source_folder, r.cash_register_code, r.shift_number
+	ORDER BY r.source_folder, 2, r.cash_register_code, r.shift_number
+`
+
+// dataGapDaysQuery selects every day of a date range for each source folder
+// with its committed file loads and transaction rows across tables.
+func dataGapDaysQuery(tables []string) string {
+	selects := make([]string, 0, len(tables))
+	for _, table := range tables {
+		selects = append(selects, fmt.Sprintf(
+			"SELECT source_folder, transaction_date FROM %s WHERE source_folder = ANY($1) AND transaction_date BETWEEN $2::date AND $3::date", table))
+	}
+	return `
+	WITH days AS (
+		SELECT f.source_folder, d::date AS day
+		FROM unnest($1::text[]) AS f(source_folder)
+		CROSS JOIN generate_series($2::date, $3::date, INTERVAL '1 day') AS d
+	),
+	loads AS (
+		SELECT source_folder, requested_date AS day, COUNT(*) AS files
+		FROM etl_file_load_state
+		WHERE source_folder = ANY($1) AND requested_date BETWEEN $2::date AND $3::date
+		GROUP BY source_folder, requested_date
+	),
+	tx AS (
+		SELECT source_folder, transaction_date AS day, COUNT(*) AS row_count
+		FROM (` + strings.Join(selects, "\n\t\tUNION ALL\n\t\t") + `) t
+		GROUP BY source_folder, transaction_date
+	)
+	SELECT days.source_folder, days.day::text, COALESCE(loads.files, 0), COALESCE(tx.row_count, 0)
+	FROM days
+	LEFT JOIN loads USING (source_folder, day)
+	LEFT JOIN tx USING (source_folder, day)
+	ORDER BY days.source_folder, days.day
+`
+}
+
+// classifyGapDay returns the gap kind of a day with files committed file
+// loads and rows transaction rows, or "" when the day has data. Days loaded
+// before etl_file_load_state existed have rows and no loads; they are fine.
+func classifyGapDay(files, rows int) string {
+	switch {
+	case rows > 0:
+		return ""
+	case files == 0:
+		return models.DataGapNoLoad
+	default:
+		return models.DataGapNoRows
+	}
+}
+
+// FindDataGaps checks every day of dates for each of sourceFolders. It
+// reports days without a committed file load, loaded days without
+// transactions and shifts with receipts but no shift close or Z-report,
+// ordered by source folder and date.
+func (l *Loader) FindDataGaps(ctx context.Context, sourceFolders []string, dates models.DateRange) ([]models.DataGap, error) {
+	gaps := make([]models.DataGap, 0)
+	if len(sourceFolders) == 0 {
+		return gaps, nil
+	}
+	to := dates.To
+	if to == "" {
+		to = dates.From
+	}
+
+	rows, err := l.db.Query(ctx, dataGapDaysQuery(parser.RegisteredTables()), sourceFolders, dates.From, to)
+	if err != nil {
+		return nil, fmt.Errorf("query loaded days: %w", err)
+	}
+	for rows.Next() {
+		var gap models.DataGap
+		var rowCount int
+		if err := rows.Scan(&gap.SourceFolder, &gap.Date, &gap.FilesLoaded, &rowCount); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan loaded day: %w", err)
+		}
+		if gap.Kind = classifyGapDay(gap.FilesLoaded, rowCount); gap.Kind != "" {
+			gaps = append(gaps, gap)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query loaded days: %w", err)
+	}
+
+	rows, err = l.db.Query(ctx, unclosedShiftsQuery, sourceFolders, dates.From, to)
+	if err != nil {
+		return nil, fmt.Errorf("query unclosed shifts: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		gap := models.DataGap{Kind: models.DataGapUnclosedShift}
+		if err := rows.Scan(&gap.SourceFolder, &gap.Date, &gap.CashRegisterCode, &gap.ShiftNumber); err != nil {
+			return nil, fmt.Errorf("scan unclosed shift: %w", err)
+		}
+		gaps = append(gaps, gap)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query unclosed shifts: %w", err)
+	}
+
+	sortDataGaps(gaps)
+	return gaps, nil
+}
+
+// sortDataGaps orders gaps by source folder and date, day gaps before shifts.
+func sortDataGaps(gaps []models.DataGap) {
+	sort.SliceStable(gaps, func(i, j int) bool {
+		if gaps[i].SourceFolder != gaps[j].SourceFolder {
+			return gaps[i].SourceFolder < gaps[j].SourceFolder
+		}
+		if gaps[i].Date != gaps[j].Date {
+			return gaps[i].Date < gaps[j].Date
+		}
+		return gaps[i].Kind != models.DataGapUnclosedShift && gaps[j].Kind == models.DataGapUnclosedShift
+	})
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/parser"
+)
+
+func TestClassifyGapDay(t *testing.T) {
+	tests := []struct {
+		files, rows int
+		want        string
+	}{
+		{files: 0, rows: 0, want: models.DataGapNoLoad},
+		{files: 2, rows: 0, want: models.DataGapNoRows},
+		{files: 1, rows: 40, want: ""},
+		{files: 0, rows: 40, want: ""},
+	}
+	for _, tt := range tests {
+		if got := classifyGapDay(tt.files, tt.rows); got != tt.want {
+			t.Fatalf("classifyGapDay(%d, %d) = %q, want %q", tt.files, tt.rows, got, tt.want)
+		}
+	}
+}
+
+func TestDataGapDaysQueryCountsEveryTable(t *testing.T) {
+	tables := parser.RegisteredTables()
+	query := dataGapDaysQuery(tables)
+	for _, table := range tables {
+		if !strings.Contains(query, "FROM "+table+" WHERE") {
+			t.Fatalf("dataGapDaysQuery() does not count rows of %s", table)
+		}
+	}
+	if got := strings.Count(query, "UNION ALL"); got != len(tables)-1 {
+		t.Fatalf("dataGapDaysQuery() has %d UNION ALL, want %d", got, len(tables)-1)
+	}
+}
+
+func TestSortDataGaps(t *testing.T) {
+	gaps := []models.DataGap{
+		{SourceFolder: "P13/P13", Date: "2024-12-02", Kind: models.DataGapNoLoad},
+		{SourceFolder: "N22/N22", Date: "2024-12-03", Kind: models.DataGapNoRows},
+		{SourceFolder: "P13/P13", Date: "2024-12-01", Kind: models.DataGapUnclosedShift},
+		{SourceFolder: "P13/P13", Date: "2024-12-01", Kind: models.DataGapNoRows},
+	}
+	sortDataGaps(gaps)
+	got := make([]string, 0, len(gaps))
+	for _, gap := range gaps {
+		got = append(got, gap.SourceFolder+" "+gap.Date+" "+gap.Kind)
+	}
+	want := []string{
+		"N22/N22 2024-12-03 no_rows",
+		"P13/P13 2024-12-01 no_rows",
+		"P13/P13 2024-12-01 unclosed_shift",
+		"P13/P13 2024-12-02 no_load",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("sortDataGaps() = %v, want %v", got, want)
+	}
+}
@@ -17,6 +17,9 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/user/go-frontol-loader/pkg/db"
 	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/parser"
+	"github.com/user/go-frontol-loader/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -40,8 +43,9 @@ type retryPolicy struct {
 
 // Loader handles loading data into the database
 type Loader struct {
-	db     loaderDB
-	policy retryPolicy
+	db      loaderDB
+	policy  retryPolicy
+	derived []derivedModel
 }
 
 // NewLoader creates a new loader instance
@@ -53,6 +57,7 @@ func NewLoader(database *db.Pool) *Loader {
 			initialBackoff: defaultInitialRetryBackoff,
 			maxBackoff:     defaultMaxRetryBackoff,
 		},
+		derived: []derivedModel{receiptModel{}},
 	}
 }
 
@@ -87,7 +92,8 @@ func isRetryableError(err error) bool {
 }
 
 // LoadFileData loads all transaction data from a file into the database
-// Implements retry logic with exponential backoff for deadlock errors
+// Implements retry logic with exponential backoff for deadlock errors.
+// Derived models (receipts) are rebuilt for the touched documents in the same transaction
 func (l *Loader) LoadFileData(ctx context.Context, transactions map[string]interface{}) error {
 	return l.loadFileData(ctx, nil, nil, transactions)
 }
@@ -116,6 +122,42 @@ func (l *Loader) loadFileData(ctx context.Context, fileState *models.FileLoadSta
 		}
 	}
 
+	return l.runInTx(ctx, func(tx pgx.Tx) error {
+		sourceFolder := ""
+		if fileState != nil {
+			sourceFolder = fileState.SourceFolder
+		}
+		scope := l.newDerivedScope()
+		if err := scope.trackStale(ctx, tx, sourceFolder, staleManifest); err != nil {
+			return fmt.Errorf("failed to reconcile stale file rows: %w", err)
+		}
+		if err := l.deleteStaleRows(ctx, tx, sourceFolder, staleManifest); err != nil {
+			return fmt.Errorf("failed to reconcile stale file rows: %w", err)
+		}
+
+		for _, tableName := range orderedTransactionTables(transactions) {
+			data := transactions[tableName]
+			if err := l.loadTransactionType(ctx, tx, scope, tableName, data); err != nil {
+				return fmt.Errorf("failed to load %s: %w", tableName, err)
+			}
+		}
+		if err := scope.rebuild(ctx, tx); err != nil {
+			return err
+		}
+
+		if fileState != nil {
+			if err := l.upsertFileLoadState(ctx, tx, fileState); err != nil {
+				return fmt.Errorf("failed to persist file load state: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// runInTx runs fn inside a single database transaction for the entire file and
+// commits it. Deadlocks and serialization failures are retried with exponential
+// backoff, re-running fn from scratch on a fresh transaction.
+func (l *Loader) runInTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
 	var lastErr error
 	for attempt := 0; attempt < l.policy.maxRetries; attempt++ {
 		// Start a transaction for the entire file
@@ -124,29 +166,11 @@ func (l *Loader) loadFileData(ctx context.Context, fileState *models.FileLoadSta
 			return fmt.Errorf("failed to begin transaction: %w", err)
 		}
 
-		// Load each transaction type
 		loadErr := func() error {
 			defer func() { _ = tx.Rollback(ctx) }() // Always rollback on error
 
-			sourceFolder := ""
-			if fileState != nil {
-				sourceFolder = fileState.SourceFolder
-			}
-			if err := l.deleteStaleRows(ctx, tx, sourceFolder, staleManifest); err != nil {
-				return fmt.Errorf("failed to reconcile stale file rows: %w", err)
-			}
-
-			for _, tableName := range orderedTransactionTables(transactions) {
-				data := transactions[tableName]
-				if err := l.loadTransactionType(ctx, tx, tableName, data); err != nil {
-					return fmt.Errorf("failed to load %s: %w", tableName, err)
-				}
-			}
-
-			if fileState != nil {
-				if err := l.upsertFileLoadState(ctx, tx, fileState); err != nil {
-					return fmt.Errorf("failed to persist file load state: %w", err)
-				}
+			if err := fn(tx); err != nil {
+				return err
 			}
 
 			// Commit the transaction
@@ -209,10 +233,10 @@ func (l *Loader) GetFileLoadState(ctx context.Context, logicalKey string) (*mode
 	var state models.FileLoadState
 	var manifestBytes []byte
 	err := l.db.QueryRow(ctx, `
-		SELECT logical_key, remote_path, COALESCE(requested_date::text, ''), source_folder, content_hash, transaction_manifest, updated_at
+		SELECT logical_key, remote_path, COALESCE(requested_date::text, ''), source_folder, content_hash, transaction_manifest, COALESCE(encoding, ''), updated_at
 		FROM etl_file_load_state
 		WHERE logical_key = $1
-	`, logicalKey).Scan(&state.LogicalKey, &state.RemotePath, &state.RequestedDate, &state.SourceFolder, &state.ContentHash, &manifestBytes, &state.UpdatedAt)
+	`, logicalKey).Scan(&state.LogicalKey, &state.RemotePath, &state.RequestedDate, &state.SourceFolder, &state.ContentHash, &manifestBytes, &state.Encoding, &state.UpdatedAt)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
@@ -240,8 +264,9 @@ func (l *Loader) upsertFileLoadState(ctx context.Context, tx pgx.Tx, fileState *
 			source_folder,
 			content_hash,
 			transaction_manifest,
+			encoding,
 			updated_at
-		) VALUES ($1, $2, NULLIF($3, '')::date, $4, $5, $6::jsonb, NOW())
+		) VALUES ($1, $2, NULLIF($3, '')::date, $4, $5, $6::jsonb, NULLIF($7, ''), NOW())
 		ON CONFLICT (logical_key)
 		DO UPDATE SET
 			remote_path = EXCLUDED.remote_path,
@@ -249,20 +274,31 @@ func (l *Loader) upsertFileLoadState(ctx context.Context, tx pgx.Tx, fileState *
 			source_folder = EXCLUDED.source_folder,
 			content_hash = EXCLUDED.content_hash,
 			transaction_manifest = EXCLUDED.transaction_manifest,
+			encoding = EXCLUDED.encoding,
 			updated_at = NOW()
-	`, fileState.LogicalKey, fileState.RemotePath, fileState.RequestedDate, fileState.SourceFolder, fileState.ContentHash, string(manifestBytes))
+	`, fileState.LogicalKey, fileState.RemotePath, fileState.RequestedDate, fileState.SourceFolder, fileState.ContentHash, string(manifestBytes), fileState.Encoding)
 	if err != nil {
 		return fmt.Errorf("upsert etl_file_load_state: %w", err)
 	}
 	return nil
 }
 
-// loadTransactionType loads a specific transaction type
-func (l *Loader) loadTransactionType(ctx context.Context, tx pgx.Tx, tableName string, data interface{}) error {
-	if _, ok := models.TxSchemas[tableName]; !ok {
+// loadTransactionType loads a specific transaction type and records the
+// documents it touches in scope, inside a db.load span
+func (l *Loader) loadTransactionType(ctx context.Context, tx pgx.Tx, scope *derivedScope, tableName string, data interface{}) (err error) {
+	ctx, span := tracing.Start(ctx, "db.load",
+		attribute.String("db.table", tableName),
+		attribute.Int("db.rows", sliceLen(data)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if _, ok := parser.TableSchema(tableName); !ok {
 		return fmt.Errorf("unknown transaction type: %s", tableName)
 	}
-	return l.db.LoadTxTable(ctx, tx, tableName, data)
+	if err := l.db.LoadTxTable(ctx, tx, tableName, data); err != nil {
+		return err
+	}
+	return scope.trackRows(tableName, data)
 }
 
 // GetTransactionCount returns the total number of transactions
@@ -354,7 +390,7 @@ func (l *Loader) deleteStaleRows(ctx context.Context, tx pgx.Tx, sourceFolder st
 		return nil
 	}
 	for _, tableName := range orderedManifestTables(manifest) {
-		if _, ok := models.TxSchemas[tableName]; !ok {
+		if _, ok := parser.TableSchema(tableName); !ok {
 			return fmt.Errorf("unknown tx table in stale manifest: %s", tableName)
 		}
 		ids := manifest[tableName]
@@ -587,7 +623,7 @@ func toTime(val interface{}) (time.Time, bool) {
 // GetTransactionRegistrationsByKassaAndDate retrieves transaction registrations from database
 // filtered by cash register code and transaction date
 func (l *Loader) GetTransactionRegistrationsByKassaAndDate(ctx context.Context, cashRegisterCode int64, date string) ([]models.TxItemRegistration1_11, error) {
-	schema := models.TxSchemas["tx_item_registration_1_11"]
+	schema, _ := parser.TableSchema("tx_item_registration_1_11")
 	columns := schemaColumns(schema)
 	query := fmt.Sprintf(`
 		SELECT %s
@@ -671,7 +707,7 @@ func (l *Loader) GetTransactionRegistrationsBySourceFolderAndDate(ctx context.Co
 		args = []interface{}{sourceFolder + "/%", date}
 	}
 
-	schema := models.TxSchemas["tx_item_registration_1_11"]
+	schema, _ := parser.TableSchema("tx_item_registration_1_11")
 	columns := schemaColumns(schema)
 	query := fmt.Sprintf(`
 		SELECT %s
@@ -777,11 +813,7 @@ func (l *Loader) GetAllTransactionsBySourceFolderAndDate(ctx context.Context, so
 		)
 	}
 
-	tables := make([]string, 0, len(models.TxSchemas))
-	for name := range models.TxSchemas {
-		tables = append(tables, name)
-	}
-	sort.Strings(tables)
+	tables := parser.RegisteredTables()
 
 	slog.DebugContext(ctx, "Building UNION ALL query",
 		"table_count", len(tables),
@@ -792,7 +824,7 @@ func (l *Loader) GetAllTransactionsBySourceFolderAndDate(ctx context.Context, so
 	transactionTypesCount := make(map[int]int)
 
 	for _, table := range tables {
-		schema := models.TxSchemas[table]
+		schema, _ := parser.TableSchema(table)
 		query := fmt.Sprintf("SELECT %s FROM %s WHERE %s", schemaColumns(schema), table, whereCondition)
 		rows, err := l.db.Query(ctx, query, args...)
 		if err != nil {
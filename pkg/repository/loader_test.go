@@ -17,6 +17,7 @@ type fakeTx struct {
 	commitCalls   int
 	rollbackCalls int
 	execSQL       []string
+	execArgs      [][]any
 }
 
 func (f *fakeTx) Begin(ctx context.Context) (pgx.Tx, error) { return f, nil }
@@ -38,6 +39,7 @@ func (f *fakeTx) Prepare(ctx context.Context, name, sql string) (*pgconn.Stateme
 }
 func (f *fakeTx) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
 	f.execSQL = append(f.execSQL, sql)
+	f.execArgs = append(f.execArgs, arguments)
 	return pgconn.CommandTag{}, nil
 }
 func (f *fakeTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
@@ -623,6 +625,115 @@ func TestLoadFileDataWithReconcileDeletesStaleRowsDeterministically(t *testing.T
 	}
 }
 
+func TestLoadFileStreamFlushesPerTableBatches(t *testing.T) {
+	batches := make(map[string][]int)
+	tx := &fakeTx{}
+	loader := newLoaderWithDB(&loaderDBMock{
+		beginTxFunc: func(ctx context.Context) (pgx.Tx, error) {
+			return tx, nil
+		},
+		loadTxTableFunc: func(ctx context.Context, tx pgx.Tx, tableName string, data interface{}) error {
+			batches[tableName] = append(batches[tableName], sliceLen(data))
+			return nil
+		},
+	})
+	loader.policy = retryPolicy{maxRetries: 1, initialBackoff: 0, maxBackoff: 0}
+
+	source := func(emit EmitFunc, reject RejectFunc) error {
+		for i := int64(1); i <= 5; i++ {
+			if err := emit("tx_item_registration_1_11", models.TxItemRegistration1_11{TransactionIDUnique: i}); err != nil {
+				return err
+			}
+		}
+		return emit("tx_special_price_3", models.TxSpecialPrice3{TransactionIDUnique: 10})
+	}
+
+	state := &models.FileLoadState{LogicalKey: "/response/P13/response.txt|2024-12-01", SourceFolder: "P13/P13"}
+	result, err := loader.LoadFileStream(context.Background(), state, nil, 2, source)
+	if err != nil {
+		t.Fatalf("LoadFileStream() unexpected error: %v", err)
+	}
+	if got := batches["tx_item_registration_1_11"]; len(got) != 3 || got[0] != 2 || got[1] != 2 || got[2] != 1 {
+		t.Fatalf("tx_item_registration_1_11 batches = %v, want [2 2 1]", got)
+	}
+	if got := batches["tx_special_price_3"]; len(got) != 1 || got[0] != 1 {
+		t.Fatalf("tx_special_price_3 batches = %v, want [1]", got)
+	}
+	if result.TransactionCount != 6 {
+		t.Fatalf("TransactionCount = %d, want 6", result.TransactionCount)
+	}
+	if ids := result.Manifest["tx_item_registration_1_11"]; len(ids) != 5 || ids[4] != 5 {
+		t.Fatalf("Manifest = %v, want 5 item ids", result.Manifest)
+	}
+	if tx.commitCalls != 1 {
+		t.Fatalf("Commit() calls = %d, want 1", tx.commitCalls)
+	}
+	if len(tx.execSQL) != 2 || !strings.Contains(tx.execSQL[0], "DELETE FROM etl_rejected_lines") || !strings.Contains(tx.execSQL[1], "etl_file_load_state") {
+		t.Fatalf("Exec() = %v, want rejected lines cleanup and etl_file_load_state upsert", tx.execSQL)
+	}
+	if state.TransactionManifest != nil {
+		t.Fatal("LoadFileStream() mutated caller file state")
+	}
+}
+
+func TestLoadFileStreamRestartsSourceOnRetry(t *testing.T) {
+	runs := 0
+	attempts := 0
+	loader := newLoaderWithDB(&loaderDBMock{
+		loadTxTableFunc: func(ctx context.Context, tx pgx.Tx, tableName string, data interface{}) error {
+			attempts++
+			if attempts == 1 {
+				return &pgconn.PgError{Code: "40P01"}
+			}
+			return nil
+		},
+	})
+	loader.policy = retryPolicy{maxRetries: 2, initialBackoff: 0, maxBackoff: 0}
+
+	result, err := loader.LoadFileStream(context.Background(), nil, nil, 10, func(emit EmitFunc, reject RejectFunc) error {
+		runs++
+		return emit("tx_item_registration_1_11", models.TxItemRegistration1_11{TransactionIDUnique: 1})
+	})
+	if err != nil {
+		t.Fatalf("LoadFileStream() unexpected error: %v", err)
+	}
+	if runs != 2 {
+		t.Fatalf("source runs = %d, want 2", runs)
+	}
+	if result.TransactionCount != 1 || len(result.Manifest["tx_item_registration_1_11"]) != 1 {
+		t.Fatalf("result = %#v, want manifest from the successful attempt only", result)
+	}
+}
+
+func TestLoadFileStreamRollsBackOnSourceError(t *testing.T) {
+	tx := &fakeTx{}
+	sourceErr := errors.New("broken line")
+	loader := newLoaderWithDB(&loaderDBMock{
+		beginTxFunc: func(ctx context.Context) (pgx.Tx, error) {
+			return tx, nil
+		},
+	})
+	loader.policy = retryPolicy{maxRetries: 3, initialBackoff: 0, maxBackoff: 0}
+
+	_, err := loader.LoadFileStream(context.Background(), &models.FileLoadState{LogicalKey: "key"}, nil, 1, func(emit EmitFunc, reject RejectFunc) error {
+		if err := emit("tx_item_registration_1_11", models.TxItemRegistration1_11{TransactionIDUnique: 1}); err != nil {
+			return err
+		}
+		return sourceErr
+	})
+	if !errors.Is(err, sourceErr) {
+		t.Fatalf("LoadFileStream() error = %v, want source error", err)
+	}
+	if tx.commitCalls != 0 || tx.rollbackCalls != 1 {
+		t.Fatalf("commit/rollback calls = %d/%d, want 0/1", tx.commitCalls, tx.rollbackCalls)
+	}
+	for _, sql := range tx.execSQL {
+		if strings.Contains(sql, "etl_file_load_state") {
+			t.Fatalf("Exec() = %v, want no file state upsert", tx.execSQL)
+		}
+	}
+}
+
 // TestGetTransactionDetailsEdgeCases tests edge cases for GetTransactionDetails.
 func TestGetTransactionDetailsEdgeCases(t *testing.T) {
 	loader := &Loader{db: nil}
@@ -657,3 +768,42 @@ func TestGetTransactionDetailsEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitFileLoadStatePerRequestedDay(t *testing.T) {
+	state := models.FileLoadState{
+		LogicalKey:      "/response/P13/response.txt|2024-12-01",
+		RemotePath:      "/response/P13/response.txt",
+		RequestedDate:   "2024-12-01",
+		RequestedDateTo: "2024-12-03",
+		ContentHash:     "hash",
+	}
+	manifest := map[string][]int64{"tx_item_registration_1_11": {1, 2, 3, 4}}
+	days := map[string]map[string][]int64{
+		"2024-12-01": {"tx_item_registration_1_11": {1}},
+		"2024-12-02": {"tx_item_registration_1_11": {2}},
+		"2024-11-30": {"tx_item_registration_1_11": {3}},
+		"":           {"tx_item_registration_1_11": {4}},
+	}
+
+	states := splitFileLoadState(state, manifest, days)
+	if len(states) != 3 {
+		t.Fatalf("splitFileLoadState() = %d states, want one per requested day", len(states))
+	}
+	if states[0].LogicalKey != state.LogicalKey || states[0].RequestedDateTo != "" {
+		t.Fatalf("first state = %+v, want the first-day key", states[0])
+	}
+	if got := states[0].TransactionManifest["tx_item_registration_1_11"]; len(got) != 3 {
+		t.Fatalf("first day manifest = %v, want its rows plus rows outside the range", got)
+	}
+	if states[1].LogicalKey != "/response/P13/response.txt|2024-12-02" || states[1].RequestedDate != "2024-12-02" || len(states[1].TransactionManifest["tx_item_registration_1_11"]) != 1 {
+		t.Fatalf("second state = %+v", states[1])
+	}
+	if states[2].RequestedDate != "2024-12-03" || len(states[2].TransactionManifest) != 0 || states[2].ContentHash != "hash" {
+		t.Fatalf("empty day state = %+v, want a state without rows", states[2])
+	}
+
+	single := splitFileLoadState(models.FileLoadState{LogicalKey: "k", RequestedDate: "2024-12-01"}, manifest, days)
+	if len(single) != 1 || len(single[0].TransactionManifest["tx_item_registration_1_11"]) != 4 {
+		t.Fatalf("single-day split = %+v, want the full manifest", single)
+	}
+}
@@ -0,0 +1,603 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+// receiptRebuildChunk bounds how many documents are read and rewritten at once.
+const receiptRebuildChunk = 500
+
+// Source tables of the receipts model.
+const (
+	receiptOpenTable          = "tx_document_open_42"
+	receiptCloseTable         = "tx_document_close_55"
+	receiptCancelTable        = "tx_document_cancel_56"
+	receiptItemTable          = "tx_item_registration_1_11"
+	receiptStornoTable        = "tx_item_storno_2_12"
+	receiptPositionDiscount15 = "tx_position_discount_15"
+	receiptPositionDiscount17 = "tx_position_discount_17"
+	receiptDocumentDiscount35 = "tx_document_discount_35"
+	receiptDocumentDiscount37 = "tx_document_discount_37"
+	receiptPayment40          = "tx_fiscal_payment_40"
+	receiptPayment43          = "tx_fiscal_payment_43"
+)
+
+// receiptModel maintains receipts, receipt_lines and receipt_payments.
+type receiptModel struct{}
+
+func (receiptModel) name() string { return "receipts" }
+
+func (receiptModel) sourceTables() []string {
+	return []string{
+		receiptOpenTable, receiptCloseTable, receiptCancelTable,
+		receiptItemTable, receiptStornoTable,
+		receiptPositionDiscount15, receiptPositionDiscount17,
+		receiptDocumentDiscount35, receiptDocumentDiscount37,
+		receiptPayment40, receiptPayment43,
+	}
+}
+
+// receiptSources holds the tx rows of a set of documents.
+type receiptSources struct {
+	opens             []models.TxDocumentOpen42
+	closes            []models.TxDocumentClose55
+	cancels           []models.TxDocumentCancel56
+	items             []models.TxItemRegistration1_11
+	stornos           []models.TxItemStorno2_12
+	positionDiscounts []models.TxPositionDiscount15
+	documentDiscounts []models.TxDocumentDiscount35
+	payments          []models.TxFiscalPayment40
+}
+
+func (m receiptModel) rebuild(ctx context.Context, tx pgx.Tx, keys []documentKey) (int, error) {
+	written := 0
+	for start := 0; start < len(keys); start += receiptRebuildChunk {
+		end := start + receiptRebuildChunk
+		if end > len(keys) {
+			end = len(keys)
+		}
+		chunk := keys[start:end]
+		folders, cashRegisters, shifts, documents := documentKeyArrays(chunk)
+
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM receipts
+			WHERE (source_folder, cash_register_code, shift_number, document_number) IN (
+				SELECT * FROM unnest($1::text[], $2::bigint[], $3::bigint[], $4::bigint[])
+			)
+		`, folders, cashRegisters, shifts, documents); err != nil {
+			return written, fmt.Errorf("delete receipts: %w", err)
+		}
+
+		sources, err := queryReceiptSources(ctx, tx, chunk)
+		if err != nil {
+			return written, err
+		}
+		receipts := buildReceipts(sources)
+		if err := insertReceipts(ctx, tx, receipts); err != nil {
+			return written, err
+		}
+		written += len(receipts)
+	}
+	return written, nil
+}
+
+// buildReceipts groups tx rows by document. Storno rows cancel the latest
+// earlier registration of the same item, position discounts apply to the
+// registration they follow (scaled down by its reversed quantity), document
+// discounts are spread over the lines, and the paid amount comes from
+// payments 40, or from their distribution 43 when a document has no 40 rows.
+func buildReceipts(src receiptSources) []models.Receipt {
+	builders := make(map[documentKey]*receiptBuilder)
+	get := func(key documentKey) *receiptBuilder {
+		b, ok := builders[key]
+		if !ok {
+			b = &receiptBuilder{receipt: models.Receipt{
+				SourceFolder:     key.SourceFolder,
+				CashRegisterCode: key.CashRegisterCode,
+				ShiftNumber:      key.ShiftNumber,
+				DocumentNumber:   key.DocumentNumber,
+			}}
+			builders[key] = b
+		}
+		return b
+	}
+
+	for _, row := range sortedByID(src.opens, func(r models.TxDocumentOpen42) int64 { return r.TransactionIDUnique }) {
+		b := get(documentKey{row.SourceFolder, row.CashRegisterCode, row.ShiftNumber, row.DocumentNumber})
+		b.seen(row.TransactionIDUnique, row.TransactionDate, row.CashierCode, row.OperationType, row.DocumentTypeCode)
+		b.receipt.OpenTransactionID = row.TransactionIDUnique
+		b.receipt.OpenTime = row.TransactionTime
+		b.receipt.TransactionDate = row.TransactionDate
+		b.receipt.CashierCode = row.CashierCode
+		b.receipt.OperationType = row.OperationType
+		b.receipt.DocumentTypeCode = row.DocumentTypeCode
+		b.receipt.CustomerCardNumbers = row.CustomerCardNumbers
+		b.opened = true
+	}
+	for _, row := range sortedByID(src.closes, func(r models.TxDocumentClose55) int64 { return r.TransactionIDUnique }) {
+		b := get(documentKey{row.SourceFolder, row.CashRegisterCode, row.ShiftNumber, row.DocumentNumber})
+		b.seen(row.TransactionIDUnique, row.TransactionDate, row.CashierCode, row.OperationType, row.DocumentTypeCode)
+		b.receipt.CloseTransactionID = row.TransactionIDUnique
+		b.receipt.CloseTime = row.TransactionTime
+		b.closed = true
+	}
+	for _, row := range src.cancels {
+		b := get(documentKey{row.SourceFolder, row.CashRegisterCode, row.ShiftNumber, row.DocumentNumber})
+		b.seen(row.TransactionIDUnique, row.TransactionDate, row.CashierCode, row.OperationType, row.DocumentTypeCode)
+		b.cancelled = true
+	}
+
+	for _, row := range sortedByID(src.items, func(r models.TxItemRegistration1_11) int64 { return r.TransactionIDUnique }) {
+		b := get(documentKey{row.SourceFolder, row.CashRegisterCode, row.ShiftNumber, row.DocumentNumber})
+		b.seen(row.TransactionIDUnique, row.TransactionDate, row.CashierCode, row.OperationType, row.DocumentTypeCode)
+		b.receipt.Lines = append(b.receipt.Lines, models.ReceiptLine{
+			TransactionIDUnique: row.TransactionIDUnique,
+			TransactionType:     row.TransactionType,
+			ItemIdentifier:      row.ItemIdentifier,
+			ArticleSKU:          row.ArticleSKU,
+			RegistrationBarcode: row.RegistrationBarcode,
+			Price:               row.PriceWithoutDiscounts,
+			Quantity:            row.Quantity,
+			GrossAmount:         row.PositionAmountWithRounding,
+		})
+	}
+	for _, row := range sortedByID(src.stornos, func(r models.TxItemStorno2_12) int64 { return r.TransactionIDUnique }) {
+		b := get(documentKey{row.SourceFolder, row.CashRegisterCode, row.ShiftNumber, row.DocumentNumber})
+		b.seen(row.TransactionIDUnique, row.TransactionDate, row.CashierCode, row.OperationType, row.DocumentTypeCode)
+		b.applyStorno(row)
+	}
+
+	for _, row := range sortedByID(src.positionDiscounts, func(r models.TxPositionDiscount15) int64 { return r.TransactionIDUnique }) {
+		b := get(documentKey{row.SourceFolder, row.CashRegisterCode, row.ShiftNumber, row.DocumentNumber})
+		b.seen(row.TransactionIDUnique, row.TransactionDate, row.CashierCode, row.OperationType, row.DocumentTypeCode)
+		b.receipt.PositionDiscountAmount += row.DiscountAmountBase
+		if line := b.lineBefore(row.TransactionIDUnique); line != nil {
+			line.DiscountAmount += row.DiscountAmountBase
+		}
+	}
+	for _, row := range src.documentDiscounts {
+		b := get(documentKey{row.SourceFolder, row.CashRegisterCode, row.ShiftNumber, row.DocumentNumber})
+		b.seen(row.TransactionIDUnique, row.TransactionDate, row.CashierCode, row.OperationType, row.DocumentTypeCode)
+		b.receipt.DocumentDiscountAmount += row.DiscountAmountBase
+	}
+
+	for _, row := range sortedByID(src.payments, func(r models.TxFiscalPayment40) int64 { return r.TransactionIDUnique }) {
+		b := get(documentKey{row.SourceFolder, row.CashRegisterCode, row.ShiftNumber, row.DocumentNumber})
+		b.seen(row.TransactionIDUnique, row.TransactionDate, row.CashierCode, row.OperationType, row.DocumentTypeCode)
+		b.receipt.Payments = append(b.receipt.Payments, models.ReceiptPayment{
+			TransactionIDUnique:  row.TransactionIDUnique,
+			TransactionType:      row.TransactionType,
+			PaymentTypeCode:      row.PaymentTypeCode,
+			PaymentTypeOperation: int64(row.PaymentTypeOperation),
+			CardNumber:           row.CardNumber,
+			Amount:               row.CustomerAmountBaseCurrency,
+		})
+	}
+
+	keys := make([]documentKey, 0, len(builders))
+	for key := range builders {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return documentKeyLess(keys[i], keys[j]) })
+
+	receipts := make([]models.Receipt, 0, len(keys))
+	for _, key := range keys {
+		receipts = append(receipts, builders[key].finish())
+	}
+	return receipts
+}
+
+type receiptBuilder struct {
+	receipt   models.Receipt
+	firstID   int64
+	opened    bool
+	closed    bool
+	cancelled bool
+}
+
+// seen takes the document attributes from its earliest row until the
+// document open (42) row, if any, overrides them.
+func (b *receiptBuilder) seen(id int64, date time.Time, cashier, operation, documentType int64) {
+	if b.opened || (b.firstID != 0 && b.firstID <= id) {
+		return
+	}
+	b.firstID = id
+	b.receipt.TransactionDate = date
+	b.receipt.CashierCode = cashier
+	b.receipt.OperationType = operation
+	b.receipt.DocumentTypeCode = documentType
+}
+
+// applyStorno cancels the latest earlier registration of the same item that
+// still has quantity left. A storno without such a registration is kept as a
+// line of its own.
+func (b *receiptBuilder) applyStorno(row models.TxItemStorno2_12) {
+	quantity := math.Abs(row.Quantity)
+	amount := math.Abs(row.PositionAmountWithRounding)
+	for i := len(b.receipt.Lines) - 1; i >= 0; i-- {
+		line := &b.receipt.Lines[i]
+		if line.TransactionIDUnique > row.TransactionIDUnique || line.ItemIdentifier != row.ItemIdentifier {
+			continue
+		}
+		if line.Quantity-line.StornoQuantity <= 0 {
+			continue
+		}
+		line.StornoQuantity += quantity
+		line.GrossAmount -= amount
+		return
+	}
+	b.receipt.Lines = append(b.receipt.Lines, models.ReceiptLine{
+		TransactionIDUnique: row.TransactionIDUnique,
+		TransactionType:     row.TransactionType,
+		ItemIdentifier:      row.ItemIdentifier,
+		ArticleSKU:          row.ArticleSKU,
+		RegistrationBarcode: row.RegistrationBarcode,
+		Price:               math.Abs(row.PriceWithoutDiscounts),
+		StornoQuantity:      quantity,
+		GrossAmount:         -amount,
+	})
+}
+
+// lineBefore returns the registration line a position discount with id
+// belongs to: the last one registered before it.
+func (b *receiptBuilder) lineBefore(id int64) *models.ReceiptLine {
+	var found *models.ReceiptLine
+	for i := range b.receipt.Lines {
+		line := &b.receipt.Lines[i]
+		if line.TransactionIDUnique < id && (found == nil || line.TransactionIDUnique > found.TransactionIDUnique) {
+			found = line
+		}
+	}
+	return found
+}
+
+func (b *receiptBuilder) finish() models.Receipt {
+	r := b.receipt
+	switch {
+	case b.cancelled:
+		r.Status = models.ReceiptStatusCancelled
+	case b.closed:
+		r.Status = models.ReceiptStatusClosed
+	default:
+		r.Status = models.ReceiptStatusOpen
+	}
+
+	sort.SliceStable(r.Lines, func(i, j int) bool { return r.Lines[i].TransactionIDUnique < r.Lines[j].TransactionIDUnique })
+	r.GrossAmount = 0
+	for i := range r.Lines {
+		line := &r.Lines[i]
+		line.LineNumber = i + 1
+		// A reversed quantity takes its share of the position discount with it
+		if line.Quantity > 0 && line.StornoQuantity > 0 {
+			reversed := line.DiscountAmount * math.Min(line.StornoQuantity/line.Quantity, 1)
+			line.DiscountAmount -= reversed
+			r.PositionDiscountAmount -= reversed
+		}
+		line.GrossAmount = roundAmount(line.GrossAmount)
+		line.DiscountAmount = roundAmount(line.DiscountAmount)
+		r.GrossAmount += line.GrossAmount
+	}
+	r.DocumentDiscountAmount = roundAmount(r.DocumentDiscountAmount)
+	spreadDocumentDiscount(r.Lines, r.DocumentDiscountAmount)
+	for i := range r.Lines {
+		line := &r.Lines[i]
+		line.NetAmount = roundAmount(line.GrossAmount - line.DiscountAmount)
+	}
+
+	var paid40, paid43 float64
+	has40 := false
+	for _, payment := range r.Payments {
+		if payment.TransactionType == 43 {
+			paid43 += payment.Amount
+			continue
+		}
+		has40 = true
+		paid40 += payment.Amount
+	}
+	if has40 {
+		r.PaidAmount = paid40
+	} else {
+		r.PaidAmount = paid43
+	}
+
+	r.GrossAmount = roundAmount(r.GrossAmount)
+	r.PositionDiscountAmount = roundAmount(r.PositionDiscountAmount)
+	r.NetAmount = roundAmount(r.GrossAmount - r.PositionDiscountAmount - r.DocumentDiscountAmount)
+	r.PaidAmount = roundAmount(r.PaidAmount)
+	return r
+}
+
+// spreadDocumentDiscount adds a document discount to the line discounts in
+// proportion to the line gross amounts. The rounding remainder goes to the
+// last such line, so the line net amounts add up to the receipt net amount.
+// Without lines of positive gross the whole discount goes to the last line.
+func spreadDocumentDiscount(lines []models.ReceiptLine, amount float64) {
+	if amount == 0 || len(lines) == 0 {
+		return
+	}
+	base, last := 0.0, len(lines)-1
+	for i, line := range lines {
+		if line.GrossAmount > 0 {
+			base += line.GrossAmount
+			last = i
+		}
+	}
+	left := amount
+	for i := range lines {
+		line := &lines[i]
+		if i == last || line.GrossAmount <= 0 {
+			continue
+		}
+		share := roundAmount(amount * line.GrossAmount / base)
+		line.DiscountAmount = roundAmount(line.DiscountAmount + share)
+		left -= share
+	}
+	lines[last].DiscountAmount = roundAmount(lines[last].DiscountAmount + left)
+}
+
+// roundAmount rounds to the NUMERIC(18,6) scale of the receipt tables.
+func roundAmount(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
+
+func sortedByID[T any](rows []T, id func(T) int64) []T {
+	sorted := append([]T(nil), rows...)
+	sort.SliceStable(sorted, func(i, j int) bool { return id(sorted[i]) < id(sorted[j]) })
+	return sorted
+}
+
+// receiptSourceRow holds the columns shared by all receipt source tables.
+type receiptSourceRow struct {
+	TransactionIDUnique int64
+	SourceFolder        string
+	TransactionDate     *time.Time
+	TransactionTime     *time.Time
+	TransactionType     int64
+	CashRegisterCode    int64
+	ShiftNumber         int64
+	DocumentNumber      int64
+	CashierCode         int64
+	OperationType       int64
+	DocumentTypeCode    int64
+}
+
+const receiptSourceColumns = `t.transaction_id_unique, t.source_folder, t.transaction_date, t.transaction_time,
+	COALESCE(t.transaction_type, 0), COALESCE(t.cash_register_code, 0), COALESCE(t.shift_number, 0),
+	COALESCE(t.document_number, 0), COALESCE(t.cashier_code, 0), COALESCE(t.operation_type, 0),
+	COALESCE(t.document_type_code, 0)`
+
+func (r *receiptSourceRow) dest() []interface{} {
+	return []interface{}{
+		&r.TransactionIDUnique, &r.SourceFolder, &r.TransactionDate, &r.TransactionTime,
+		&r.TransactionType, &r.CashRegisterCode, &r.ShiftNumber,
+		&r.DocumentNumber, &r.CashierCode, &r.OperationType,
+		&r.DocumentTypeCode,
+	}
+}
+
+func (r *receiptSourceRow) date() time.Time {
+	if r.TransactionDate == nil {
+		return time.Time{}
+	}
+	return *r.TransactionDate
+}
+
+func (r *receiptSourceRow) clock() time.Time {
+	if r.TransactionTime == nil {
+		return time.Time{}
+	}
+	return *r.TransactionTime
+}
+
+// queryReceiptRows reads the rows of tableName that belong to keys; extra
+// lists the table specific columns and scan receives one row at a time.
+func queryReceiptRows(ctx context.Context, tx pgx.Tx, tableName, extra string, keys []documentKey, scan func(rows pgx.Rows) error) error {
+	folders, cashRegisters, shifts, documents := documentKeyArrays(keys)
+	rows, err := tx.Query(ctx, fmt.Sprintf(`
+		SELECT %s, %s
+		FROM %s t
+		JOIN unnest($1::text[], $2::bigint[], $3::bigint[], $4::bigint[]) AS k(source_folder, cash_register_code, shift_number, document_number)
+		  ON t.source_folder = k.source_folder
+		 AND COALESCE(t.cash_register_code, 0) = k.cash_register_code
+		 AND COALESCE(t.shift_number, 0) = k.shift_number
+		 AND COALESCE(t.document_number, 0) = k.document_number
+	`, receiptSourceColumns, extra, tableName), folders, cashRegisters, shifts, documents)
+	if err != nil {
+		return fmt.Errorf("query %s: %w", tableName, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return fmt.Errorf("scan %s: %w", tableName, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("query %s: %w", tableName, err)
+	}
+	return nil
+}
+
+func queryReceiptSources(ctx context.Context, tx pgx.Tx, keys []documentKey) (receiptSources, error) {
+	var src receiptSources
+
+	for _, table := range []struct {
+		name string
+		dst  *[]models.TxDocumentOpen42
+	}{
+		{receiptOpenTable, &src.opens},
+		{receiptCloseTable, &src.closes},
+		{receiptCancelTable, &src.cancels},
+	} {
+		err := queryReceiptRows(ctx, tx, table.name, `COALESCE(t.customer_card_numbers, '')`, keys, func(rows pgx.Rows) error {
+			var base receiptSourceRow
+			var row models.TxDocumentOpen42
+			if err := rows.Scan(append(base.dest(), &row.CustomerCardNumbers)...); err != nil {
+				return err
+			}
+			row.TransactionIDUnique, row.SourceFolder, row.TransactionDate, row.TransactionTime = base.TransactionIDUnique, base.SourceFolder, base.date(), base.clock()
+			row.TransactionType, row.CashRegisterCode, row.ShiftNumber, row.DocumentNumber = base.TransactionType, base.CashRegisterCode, base.ShiftNumber, base.DocumentNumber
+			row.CashierCode, row.OperationType, row.DocumentTypeCode = base.CashierCode, base.OperationType, base.DocumentTypeCode
+			*table.dst = append(*table.dst, row)
+			return nil
+		})
+		if err != nil {
+			return src, err
+		}
+	}
+
+	for _, table := range []struct {
+		name string
+		dst  *[]models.TxItemRegistration1_11
+	}{
+		{receiptItemTable, &src.items},
+		{receiptStornoTable, &src.stornos},
+	} {
+		err := queryReceiptRows(ctx, tx, table.name, `COALESCE(t.item_identifier, ''), COALESCE(t.article_sku, ''), COALESCE(t.registration_barcode, ''),
+			COALESCE(t.price_without_discounts, 0)::float8, COALESCE(t.quantity, 0)::float8, COALESCE(t.position_amount_with_rounding, 0)::float8`, keys, func(rows pgx.Rows) error {
+			var base receiptSourceRow
+			var row models.TxItemRegistration1_11
+			if err := rows.Scan(append(base.dest(), &row.ItemIdentifier, &row.ArticleSKU, &row.RegistrationBarcode,
+				&row.PriceWithoutDiscounts, &row.Quantity, &row.PositionAmountWithRounding)...); err != nil {
+				return err
+			}
+			row.TransactionIDUnique, row.SourceFolder, row.TransactionDate, row.TransactionTime = base.TransactionIDUnique, base.SourceFolder, base.date(), base.clock()
+			row.TransactionType, row.CashRegisterCode, row.ShiftNumber, row.DocumentNumber = base.TransactionType, base.CashRegisterCode, base.ShiftNumber, base.DocumentNumber
+			row.CashierCode, row.OperationType, row.DocumentTypeCode = base.CashierCode, base.OperationType, base.DocumentTypeCode
+			*table.dst = append(*table.dst, row)
+			return nil
+		})
+		if err != nil {
+			return src, err
+		}
+	}
+
+	for _, tableName := range []string{receiptPositionDiscount15, receiptPositionDiscount17} {
+		err := queryReceiptRows(ctx, tx, tableName, `COALESCE(t.discount_amount_base, 0)::float8`, keys, func(rows pgx.Rows) error {
+			var base receiptSourceRow
+			var row models.TxPositionDiscount15
+			if err := rows.Scan(append(base.dest(), &row.DiscountAmountBase)...); err != nil {
+				return err
+			}
+			row.TransactionIDUnique, row.SourceFolder, row.TransactionDate, row.TransactionTime = base.TransactionIDUnique, base.SourceFolder, base.date(), base.clock()
+			row.TransactionType, row.CashRegisterCode, row.ShiftNumber, row.DocumentNumber = base.TransactionType, base.CashRegisterCode, base.ShiftNumber, base.DocumentNumber
+			row.CashierCode, row.OperationType, row.DocumentTypeCode = base.CashierCode, base.OperationType, base.DocumentTypeCode
+			src.positionDiscounts = append(src.positionDiscounts, row)
+			return nil
+		})
+		if err != nil {
+			return src, err
+		}
+	}
+
+	for _, tableName := range []string{receiptDocumentDiscount35, receiptDocumentDiscount37} {
+		err := queryReceiptRows(ctx, tx, tableName, `COALESCE(t.discount_amount_base, 0)::float8`, keys, func(rows pgx.Rows) error {
+			var base receiptSourceRow
+			var row models.TxDocumentDiscount35
+			if err := rows.Scan(append(base.dest(), &row.DiscountAmountBase)...); err != nil {
+				return err
+			}
+			row.TransactionIDUnique, row.SourceFolder, row.TransactionDate, row.TransactionTime = base.TransactionIDUnique, base.SourceFolder, base.date(), base.clock()
+			row.TransactionType, row.CashRegisterCode, row.ShiftNumber, row.DocumentNumber = base.TransactionType, base.CashRegisterCode, base.ShiftNumber, base.DocumentNumber
+			row.CashierCode, row.OperationType, row.DocumentTypeCode = base.CashierCode, base.OperationType, base.DocumentTypeCode
+			src.documentDiscounts = append(src.documentDiscounts, row)
+			return nil
+		})
+		if err != nil {
+			return src, err
+		}
+	}
+
+	for _, tableName := range []string{receiptPayment40, receiptPayment43} {
+		err := queryReceiptRows(ctx, tx, tableName, `COALESCE(t.card_number, ''), COALESCE(t.payment_type_code, ''),
+			COALESCE(t.payment_type_operation, 0)::float8, COALESCE(t.customer_amount_base_currency, 0)::float8`, keys, func(rows pgx.Rows) error {
+			var base receiptSourceRow
+			var row models.TxFiscalPayment40
+			if err := rows.Scan(append(base.dest(), &row.CardNumber, &row.PaymentTypeCode, &row.PaymentTypeOperation, &row.CustomerAmountBaseCurrency)...); err != nil {
+				return err
+			}
+			row.TransactionIDUnique, row.SourceFolder, row.TransactionDate, row.TransactionTime = base.TransactionIDUnique, base.SourceFolder, base.date(), base.clock()
+			row.TransactionType, row.CashRegisterCode, row.ShiftNumber, row.DocumentNumber = base.TransactionType, base.CashRegisterCode, base.ShiftNumber, base.DocumentNumber
+			row.CashierCode, row.OperationType, row.DocumentTypeCode = base.CashierCode, base.OperationType, base.DocumentTypeCode
+			src.payments = append(src.payments, row)
+			return nil
+		})
+		if err != nil {
+			return src, err
+		}
+	}
+
+	return src, nil
+}
+
+func insertReceipts(ctx context.Context, tx pgx.Tx, receipts []models.Receipt) error {
+	if len(receipts) == 0 {
+		return nil
+	}
+	batch := &pgx.Batch{}
+	for _, r := range receipts {
+		batch.Queue(`
+			INSERT INTO receipts (
+				source_folder, cash_register_code, shift_number, document_number,
+				transaction_date, open_time, close_time, status,
+				cashier_code, operation_type, document_type_code, customer_card_numbers,
+				open_transaction_id, close_transaction_id, line_count,
+				gross_amount, position_discount_amount, document_discount_amount, net_amount, paid_amount,
+				updated_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), NULLIF($13, 0), NULLIF($14, 0), $15, $16, $17, $18, $19, $20, NOW())
+		`, r.SourceFolder, r.CashRegisterCode, r.ShiftNumber, r.DocumentNumber,
+			nullTime(r.TransactionDate), nullTime(r.OpenTime), nullTime(r.CloseTime), r.Status,
+			r.CashierCode, r.OperationType, r.DocumentTypeCode, r.CustomerCardNumbers,
+			r.OpenTransactionID, r.CloseTransactionID, len(r.Lines),
+			r.GrossAmount, r.PositionDiscountAmount, r.DocumentDiscountAmount, r.NetAmount, r.PaidAmount)
+
+		for _, line := range r.Lines {
+			batch.Queue(`
+				INSERT INTO receipt_lines (
+					source_folder, cash_register_code, shift_number, document_number, line_number,
+					transaction_id_unique, transaction_type, item_identifier, article_sku, registration_barcode,
+					price, quantity, storno_quantity, gross_amount, discount_amount, net_amount
+				) VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''), $11, $12, $13, $14, $15, $16)
+			`, r.SourceFolder, r.CashRegisterCode, r.ShiftNumber, r.DocumentNumber, line.LineNumber,
+				line.TransactionIDUnique, line.TransactionType, line.ItemIdentifier, line.ArticleSKU, line.RegistrationBarcode,
+				line.Price, line.Quantity, line.StornoQuantity, line.GrossAmount, line.DiscountAmount, line.NetAmount)
+		}
+
+		for _, payment := range r.Payments {
+			batch.Queue(`
+				INSERT INTO receipt_payments (
+					source_folder, cash_register_code, shift_number, document_number,
+					transaction_id_unique, transaction_type, payment_type_code, payment_type_operation, card_number, amount
+				) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, NULLIF($9, ''), $10)
+			`, r.SourceFolder, r.CashRegisterCode, r.ShiftNumber, r.DocumentNumber,
+				payment.TransactionIDUnique, payment.TransactionType, payment.PaymentTypeCode, payment.PaymentTypeOperation, payment.CardNumber, payment.Amount)
+		}
+	}
+
+	br := tx.SendBatch(ctx, batch)
+	for i := 0; i < batch.Len(); i++ {
+		if _, err := br.Exec(); err != nil {
+			if closeErr := br.Close(); closeErr != nil {
+				return fmt.Errorf("insert receipts (statement %d): %v; close batch: %w", i+1, err, closeErr)
+			}
+			return fmt.Errorf("insert receipts (statement %d): %w", i+1, err)
+		}
+	}
+	if err := br.Close(); err != nil {
+		return fmt.Errorf("close receipts batch: %w", err)
+	}
+	return nil
+}
+
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
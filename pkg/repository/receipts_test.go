@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+func TestBuildReceiptsNetsStornoAndDiscounts(t *testing.T) {
+	date := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minute int) time.Time { return time.Date(0, 1, 1, 10, minute, 0, 0, time.UTC) }
+	folder, kassa, shift, number := "P13/P13", int64(1), int64(7), int64(100)
+
+	src := receiptSources{
+		opens: []models.TxDocumentOpen42{{
+			TransactionIDUnique: 1, SourceFolder: folder, CashRegisterCode: kassa, ShiftNumber: shift, DocumentNumber: number,
+			TransactionDate: date, TransactionTime: at(0), TransactionType: 42, CashierCode: 5, OperationType: 1, CustomerCardNumbers: "CARD1",
+		}},
+		items: []models.TxItemRegistration1_11{
+			{TransactionIDUnique: 2, SourceFolder: folder, CashRegisterCode: kassa, ShiftNumber: shift, DocumentNumber: number, TransactionType: 11, ItemIdentifier: "A", PriceWithoutDiscounts: 50, Quantity: 2, PositionAmountWithRounding: 100},
+			{TransactionIDUnique: 4, SourceFolder: folder, CashRegisterCode: kassa, ShiftNumber: shift, DocumentNumber: number, TransactionType: 11, ItemIdentifier: "B", PriceWithoutDiscounts: 30, Quantity: 1, PositionAmountWithRounding: 30},
+		},
+		stornos: []models.TxItemStorno2_12{
+			{TransactionIDUnique: 5, SourceFolder: folder, CashRegisterCode: kassa, ShiftNumber: shift, DocumentNumber: number, TransactionType: 12, ItemIdentifier: "A", PriceWithoutDiscounts: -50, Quantity: 1, PositionAmountWithRounding: -50},
+		},
+		positionDiscounts: []models.TxPositionDiscount15{
+			{TransactionIDUnique: 3, SourceFolder: folder, CashRegisterCode: kassa, ShiftNumber: shift, DocumentNumber: number, TransactionType: 15, DiscountAmountBase: 5},
+		},
+		documentDiscounts: []models.TxDocumentDiscount35{
+			{TransactionIDUnique: 6, SourceFolder: folder, CashRegisterCode: kassa, ShiftNumber: shift, DocumentNumber: number, TransactionType: 37, DiscountAmountBase: 7.5},
+		},
+		payments: []models.TxFiscalPayment40{
+			{TransactionIDUnique: 7, SourceFolder: folder, CashRegisterCode: kassa, ShiftNumber: shift, DocumentNumber: number, TransactionType: 40, PaymentTypeCode: "1", CustomerAmountBaseCurrency: 100},
+			{TransactionIDUnique: 8, SourceFolder: folder, CashRegisterCode: kassa, ShiftNumber: shift, DocumentNumber: number, TransactionType: 40, PaymentTypeCode: "1", CustomerAmountBaseCurrency: -32.5},
+			{TransactionIDUnique: 9, SourceFolder: folder, CashRegisterCode: kassa, ShiftNumber: shift, DocumentNumber: number, TransactionType: 43, PaymentTypeCode: "1", CustomerAmountBaseCurrency: 67.5},
+		},
+		closes: []models.TxDocumentClose55{{
+			TransactionIDUnique: 10, SourceFolder: folder, CashRegisterCode: kassa, ShiftNumber: shift, DocumentNumber: number, TransactionType: 55, TransactionTime: at(3),
+		}},
+	}
+
+	receipts := buildReceipts(src)
+	if len(receipts) != 1 {
+		t.Fatalf("buildReceipts() = %d receipts, want 1", len(receipts))
+	}
+	r := receipts[0]
+	if r.Status != models.ReceiptStatusClosed || r.OpenTransactionID != 1 || r.CloseTransactionID != 10 {
+		t.Fatalf("receipt status/open/close = %s/%d/%d", r.Status, r.OpenTransactionID, r.CloseTransactionID)
+	}
+	if !r.TransactionDate.Equal(date) || !r.OpenTime.Equal(at(0)) || !r.CloseTime.Equal(at(3)) || r.CashierCode != 5 || r.CustomerCardNumbers != "CARD1" {
+		t.Fatalf("receipt header = %+v", r)
+	}
+	// Half of A is reversed, so half of its position discount is too
+	if r.GrossAmount != 80 || r.PositionDiscountAmount != 2.5 || r.DocumentDiscountAmount != 7.5 || r.NetAmount != 70 {
+		t.Fatalf("receipt amounts gross/position/document/net = %v/%v/%v/%v, want 80/2.5/7.5/70",
+			r.GrossAmount, r.PositionDiscountAmount, r.DocumentDiscountAmount, r.NetAmount)
+	}
+	if r.PaidAmount != 67.5 {
+		t.Fatalf("PaidAmount = %v, want 67.5 from payments 40 only", r.PaidAmount)
+	}
+	if len(r.Payments) != 3 {
+		t.Fatalf("Payments = %d, want 3", len(r.Payments))
+	}
+
+	if len(r.Lines) != 2 {
+		t.Fatalf("Lines = %+v, want 2", r.Lines)
+	}
+	a, b := r.Lines[0], r.Lines[1]
+	// The document discount 7.5 is spread 50:30 over the lines
+	if a.LineNumber != 1 || a.ItemIdentifier != "A" || a.StornoQuantity != 1 || a.GrossAmount != 50 || a.DiscountAmount != 7.1875 || a.NetAmount != 42.8125 {
+		t.Fatalf("line A = %+v", a)
+	}
+	if b.LineNumber != 2 || b.ItemIdentifier != "B" || b.StornoQuantity != 0 || b.DiscountAmount != 2.8125 || b.NetAmount != 27.1875 {
+		t.Fatalf("line B = %+v", b)
+	}
+	if a.NetAmount+b.NetAmount != r.NetAmount {
+		t.Fatalf("line net sum = %v, want receipt net %v", a.NetAmount+b.NetAmount, r.NetAmount)
+	}
+}
+
+func TestBuildReceiptsReversesDiscountOfFullyReversedLine(t *testing.T) {
+	folder, kassa, shift, number := "P13/P13", int64(1), int64(9), int64(4)
+	receipts := buildReceipts(receiptSources{
+		items: []models.TxItemRegistration1_11{
+			{TransactionIDUnique: 30, SourceFolder: folder, CashRegisterCode: kassa, ShiftNumber: shift, DocumentNumber: number, ItemIdentifier: "A", Quantity: 2, PositionAmountWithRounding: 20},
+			{TransactionIDUnique: 32, SourceFolder: folder, CashRegisterCode: kassa, ShiftNumber: shift, DocumentNumber: number, ItemIdentifier: "B", Quantity: 3, PositionAmountWithRounding: 10},
+		},
+		positionDiscounts: []models.TxPositionDiscount15{
+			{TransactionIDUnique: 31, SourceFolder: folder, CashRegisterCode: kassa, ShiftNumber: shift, DocumentNumber: number, DiscountAmountBase: 4},
+		},
+		stornos: []models.TxItemStorno2_12{
+			{TransactionIDUnique: 33, SourceFolder: folder, CashRegisterCode: kassa, ShiftNumber: shift, DocumentNumber: number, ItemIdentifier: "A", Quantity: 2, PositionAmountWithRounding: -20},
+		},
+		documentDiscounts: []models.TxDocumentDiscount35{
+			{TransactionIDUnique: 34, SourceFolder: folder, CashRegisterCode: kassa, ShiftNumber: shift, DocumentNumber: number, DiscountAmountBase: 1},
+		},
+	})
+
+	if len(receipts) != 1 || len(receipts[0].Lines) != 2 {
+		t.Fatalf("buildReceipts() = %+v, want one receipt with 2 lines", receipts)
+	}
+	r := receipts[0]
+	a, b := r.Lines[0], r.Lines[1]
+	if a.GrossAmount != 0 || a.DiscountAmount != 0 || a.NetAmount != 0 {
+		t.Fatalf("line A = %+v, want fully reversed with no discount", a)
+	}
+	if b.DiscountAmount != 1 || b.NetAmount != 9 {
+		t.Fatalf("line B = %+v, want the whole document discount", b)
+	}
+	if r.PositionDiscountAmount != 0 || r.NetAmount != 9 {
+		t.Fatalf("position discount/net = %v/%v, want 0/9", r.PositionDiscountAmount, r.NetAmount)
+	}
+}
+
+func TestBuildReceiptsWithoutOpenOrCloseRows(t *testing.T) {
+	date := time.Date(2024, 12, 2, 0, 0, 0, 0, time.UTC)
+	receipts := buildReceipts(receiptSources{
+		items: []models.TxItemRegistration1_11{
+			{TransactionIDUnique: 20, SourceFolder: "P13/P13", CashRegisterCode: 1, ShiftNumber: 8, DocumentNumber: 3, TransactionDate: date, CashierCode: 9, ItemIdentifier: "A", Quantity: 1, PositionAmountWithRounding: 10},
+		},
+		stornos: []models.TxItemStorno2_12{
+			{TransactionIDUnique: 21, SourceFolder: "P13/P13", CashRegisterCode: 1, ShiftNumber: 8, DocumentNumber: 3, ItemIdentifier: "Z", Quantity: 1, PositionAmountWithRounding: -4},
+		},
+		payments: []models.TxFiscalPayment40{
+			{TransactionIDUnique: 22, SourceFolder: "P13/P13", CashRegisterCode: 1, ShiftNumber: 8, DocumentNumber: 3, TransactionType: 43, CustomerAmountBaseCurrency: 6},
+		},
+		cancels: []models.TxDocumentCancel56{
+			{TransactionIDUnique: 23, SourceFolder: "P13/P13", CashRegisterCode: 1, ShiftNumber: 8, DocumentNumber: 3},
+		},
+	})
+
+	if len(receipts) != 1 {
+		t.Fatalf("buildReceipts() = %d receipts, want 1", len(receipts))
+	}
+	r := receipts[0]
+	if r.Status != models.ReceiptStatusCancelled {
+		t.Fatalf("Status = %s, want cancelled", r.Status)
+	}
+	if !r.TransactionDate.Equal(date) || r.CashierCode != 9 {
+		t.Fatalf("header from earliest row = %v/%d, want %v/9", r.TransactionDate, r.CashierCode, date)
+	}
+	if len(r.Lines) != 2 || r.Lines[1].GrossAmount != -4 || r.Lines[1].StornoQuantity != 1 {
+		t.Fatalf("Lines = %+v, want unmatched storno as its own line", r.Lines)
+	}
+	if r.GrossAmount != 6 || r.PaidAmount != 6 {
+		t.Fatalf("gross/paid = %v/%v, want 6/6 with payments 43 as fallback", r.GrossAmount, r.PaidAmount)
+	}
+}
+
+// recordingModel is a derived model that records the documents it is asked
+// to rebuild.
+type recordingModel struct {
+	tables []string
+	keys   [][]documentKey
+}
+
+func (m *recordingModel) name() string           { return "recording" }
+func (m *recordingModel) sourceTables() []string { return m.tables }
+func (m *recordingModel) rebuild(ctx context.Context, tx pgx.Tx, keys []documentKey) (int, error) {
+	m.keys = append(m.keys, keys)
+	return len(keys), nil
+}
+
+func TestLoadFileStreamRebuildsDerivedModelsForTouchedDocuments(t *testing.T) {
+	model := &recordingModel{tables: []string{"tx_item_registration_1_11"}}
+	loader := newLoaderWithDB(&loaderDBMock{})
+	loader.policy = retryPolicy{maxRetries: 1, initialBackoff: 0, maxBackoff: 0}
+	loader.derived = []derivedModel{model}
+
+	_, err := loader.LoadFileStream(context.Background(), nil, nil, 1, func(emit EmitFunc, reject RejectFunc) error {
+		rows := []models.TxItemRegistration1_11{
+			{TransactionIDUnique: 1, SourceFolder: "P13/P13", CashRegisterCode: 1, ShiftNumber: 2, DocumentNumber: 4},
+			{TransactionIDUnique: 2, SourceFolder: "P13/P13", CashRegisterCode: 1, ShiftNumber: 2, DocumentNumber: 3},
+			{TransactionIDUnique: 3, SourceFolder: "P13/P13", CashRegisterCode: 1, ShiftNumber: 2, DocumentNumber: 4},
+		}
+		for _, row := range rows {
+			if err := emit("tx_item_registration_1_11", row); err != nil {
+				return err
+			}
+		}
+		return emit("tx_special_price_3", models.TxSpecialPrice3{TransactionIDUnique: 10, SourceFolder: "P13/P13", DocumentNumber: 9})
+	})
+	if err != nil {
+		t.Fatalf("LoadFileStream() unexpected error: %v", err)
+	}
+
+	if len(model.keys) != 1 {
+		t.Fatalf("rebuild calls = %d, want 1 per load", len(model.keys))
+	}
+	want := []documentKey{{"P13/P13", 1, 2, 3}, {"P13/P13", 1, 2, 4}}
+	got := model.keys[0]
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("rebuilt documents = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,319 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/parser"
+)
+
+const (
+	defaultRejectedLinesLimit = 100
+	maxRejectedLinesLimit     = 1000
+)
+
+// RejectedLineFilter selects rows of etl_rejected_lines.
+type RejectedLineFilter struct {
+	SourceFolder string
+	LogicalKey   string
+	// Status is rejected or reprocessed; empty matches both.
+	Status string
+	// Limit defaults to 100 and is capped at 1000.
+	Limit int
+}
+
+// ReprocessResult summarises a re-run of rejected lines through the parser.
+type ReprocessResult struct {
+	Selected      int            `json:"selected"`
+	Reprocessed   int            `json:"reprocessed"`
+	StillRejected int            `json:"still_rejected"`
+	TableCounts   map[string]int `json:"table_counts,omitempty"`
+}
+
+// ListRejectedLines returns quarantined lines matching filter, oldest first.
+func (l *Loader) ListRejectedLines(ctx context.Context, filter RejectedLineFilter) ([]models.RejectedLine, error) {
+	query, args := buildRejectedLinesQuery(filter)
+	rows, err := l.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query etl_rejected_lines: %w", err)
+	}
+	return scanRejectedLines(rows)
+}
+
+// ReprocessRejectedLines parses the still rejected lines matching filter
+// again, loads those that now parse into their tx_* tables and marks them
+// reprocessed, all in one transaction. Lines that still fail keep status
+// rejected with the new error.
+func (l *Loader) ReprocessRejectedLines(ctx context.Context, filter RejectedLineFilter) (*ReprocessResult, error) {
+	filter.Status = models.RejectedLineStatusRejected
+	query, args := buildRejectedLinesQuery(filter)
+
+	var result *ReprocessResult
+	err := l.runInTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, query+" FOR UPDATE", args...)
+		if err != nil {
+			return fmt.Errorf("query etl_rejected_lines: %w", err)
+		}
+		lines, err := scanRejectedLines(rows)
+		if err != nil {
+			return err
+		}
+
+		plan := planReprocess(lines)
+		scope := l.newDerivedScope()
+		for _, tableName := range plan.tables() {
+			if err := l.loadTransactionType(ctx, tx, scope, tableName, plan.rows[tableName]); err != nil {
+				return fmt.Errorf("failed to load %s: %w", tableName, err)
+			}
+		}
+		if err := scope.rebuild(ctx, tx); err != nil {
+			return err
+		}
+		for _, logicalKey := range plan.logicalKeys() {
+			if err := appendFileManifest(ctx, tx, logicalKey, plan.manifests[logicalKey]); err != nil {
+				return err
+			}
+		}
+		if len(plan.reprocessed) > 0 {
+			if _, err := tx.Exec(ctx, `
+				UPDATE etl_rejected_lines
+				SET status = $2, reprocessed_at = NOW()
+				WHERE id = ANY($1)
+			`, plan.reprocessed, models.RejectedLineStatusReprocessed); err != nil {
+				return fmt.Errorf("mark rejected lines reprocessed: %w", err)
+			}
+		}
+		for _, failure := range plan.failures {
+			if _, err := tx.Exec(ctx, `UPDATE etl_rejected_lines SET error = $2 WHERE id = $1`, failure.id, failure.err); err != nil {
+				return fmt.Errorf("update rejected line %d: %w", failure.id, err)
+			}
+		}
+
+		result = &ReprocessResult{
+			Selected:      len(lines),
+			Reprocessed:   len(plan.reprocessed),
+			StillRejected: len(plan.failures),
+			TableCounts:   make(map[string]int, len(plan.rows)),
+		}
+		for tableName, tableRows := range plan.rows {
+			result.TableCounts[tableName] = len(tableRows)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Rejected lines reprocessed",
+		"selected", result.Selected,
+		"reprocessed", result.Reprocessed,
+		"still_rejected", result.StillRejected,
+		"event", "rejected_lines_reprocessed",
+	)
+	return result, nil
+}
+
+func buildRejectedLinesQuery(filter RejectedLineFilter) (string, []interface{}) {
+	conditions := make([]string, 0, 3)
+	args := make([]interface{}, 0, 4)
+	if filter.SourceFolder != "" {
+		args = append(args, filter.SourceFolder)
+		conditions = append(conditions, fmt.Sprintf("source_folder = $%d", len(args)))
+	}
+	if filter.LogicalKey != "" {
+		args = append(args, filter.LogicalKey)
+		conditions = append(conditions, fmt.Sprintf("logical_key = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultRejectedLinesLimit
+	}
+	if limit > maxRejectedLinesLimit {
+		limit = maxRejectedLinesLimit
+	}
+	args = append(args, limit)
+
+	query := `SELECT id, source_folder, logical_key, line_number, raw_line, COALESCE(transaction_type, 0), error, status, rejected_at, reprocessed_at
+		FROM etl_rejected_lines`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY id LIMIT $%d", len(args))
+	return query, args
+}
+
+func scanRejectedLines(rows pgx.Rows) ([]models.RejectedLine, error) {
+	defer rows.Close()
+
+	lines := make([]models.RejectedLine, 0)
+	for rows.Next() {
+		var line models.RejectedLine
+		if err := rows.Scan(&line.ID, &line.SourceFolder, &line.LogicalKey, &line.LineNumber, &line.RawLine, &line.TransactionType, &line.Error, &line.Status, &line.RejectedAt, &line.ReprocessedAt); err != nil {
+			return nil, fmt.Errorf("scan etl_rejected_lines: %w", err)
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate etl_rejected_lines: %w", err)
+	}
+	return lines, nil
+}
+
+func deleteRejectedLines(ctx context.Context, tx pgx.Tx, logicalKey string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM etl_rejected_lines WHERE logical_key = $1`, logicalKey); err != nil {
+		return fmt.Errorf("delete previous rejected lines: %w", err)
+	}
+	return nil
+}
+
+// insertRejectedLine stores a line read from a Frontol file, already decoded
+// to UTF-8 by the parser.
+func insertRejectedLine(ctx context.Context, tx pgx.Tx, line models.RejectedLine) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO etl_rejected_lines (
+			source_folder,
+			logical_key,
+			line_number,
+			raw_line,
+			transaction_type,
+			error
+		) VALUES ($1, $2, $3, $4, NULLIF($5, 0), $6)
+	`, line.SourceFolder, line.LogicalKey, line.LineNumber, line.RawLine, line.TransactionType, line.Error)
+	if err != nil {
+		return fmt.Errorf("insert rejected line %d: %w", line.LineNumber, err)
+	}
+	return nil
+}
+
+// appendFileManifest adds reprocessed rows to the manifest of their file, so a
+// later corrected reupload also removes them.
+func appendFileManifest(ctx context.Context, tx pgx.Tx, logicalKey string, additions map[string][]int64) error {
+	var manifestBytes []byte
+	err := tx.QueryRow(ctx, `SELECT transaction_manifest FROM etl_file_load_state WHERE logical_key = $1 FOR UPDATE`, logicalKey).Scan(&manifestBytes)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("query file load manifest: %w", err)
+	}
+
+	manifest := make(map[string][]int64)
+	if len(manifestBytes) > 0 {
+		if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+			return fmt.Errorf("decode file load manifest: %w", err)
+		}
+	}
+	mergeManifest(manifest, additions)
+
+	encoded, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("encode file load manifest: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE etl_file_load_state
+		SET transaction_manifest = $2::jsonb, updated_at = NOW()
+		WHERE logical_key = $1
+	`, logicalKey, string(encoded)); err != nil {
+		return fmt.Errorf("update file load manifest: %w", err)
+	}
+	return nil
+}
+
+func mergeManifest(manifest map[string][]int64, additions map[string][]int64) {
+	for tableName, ids := range additions {
+		seen := make(map[int64]struct{}, len(manifest[tableName]))
+		for _, id := range manifest[tableName] {
+			seen[id] = struct{}{}
+		}
+		for _, id := range ids {
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			manifest[tableName] = append(manifest[tableName], id)
+		}
+	}
+}
+
+type reprocessFailure struct {
+	id  int64
+	err string
+}
+
+type reprocessPlan struct {
+	rows        map[string][]interface{}
+	manifests   map[string]map[string][]int64
+	reprocessed []int64
+	failures    []reprocessFailure
+}
+
+// planReprocess parses stored lines again. Lines are stored as decoded by the
+// parser, so they go through exactly the same parse and insert path as lines
+// read from a file. Parsing is strict: a line is only reprocessed when every
+// value is read as is, otherwise it stays rejected.
+func planReprocess(lines []models.RejectedLine) reprocessPlan {
+	plan := reprocessPlan{
+		rows:      make(map[string][]interface{}),
+		manifests: make(map[string]map[string][]int64),
+	}
+	for _, line := range lines {
+		row, tableName, id, err := reparseRejectedLine(line)
+		if err != nil {
+			plan.failures = append(plan.failures, reprocessFailure{id: line.ID, err: err.Error()})
+			continue
+		}
+		plan.rows[tableName] = append(plan.rows[tableName], row)
+		if plan.manifests[line.LogicalKey] == nil {
+			plan.manifests[line.LogicalKey] = make(map[string][]int64)
+		}
+		plan.manifests[line.LogicalKey][tableName] = append(plan.manifests[line.LogicalKey][tableName], id)
+		plan.reprocessed = append(plan.reprocessed, line.ID)
+	}
+	return plan
+}
+
+func reparseRejectedLine(line models.RejectedLine) (interface{}, string, int64, error) {
+	transaction, err := parser.ParseTransactionLineWithMode(line.RawLine, line.SourceFolder, parser.ParseModeStrict)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	parsed, ok := transaction.(parser.ParsedTransaction)
+	if !ok {
+		return nil, "", 0, fmt.Errorf("unknown transaction type: %T", transaction)
+	}
+	id, err := transactionIDUnique(parsed.Value)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	return parsed.Value, parsed.Table, id, nil
+}
+
+func (p reprocessPlan) tables() []string {
+	tables := make([]string, 0, len(p.rows))
+	for tableName := range p.rows {
+		tables = append(tables, tableName)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+func (p reprocessPlan) logicalKeys() []string {
+	keys := make([]string, 0, len(p.manifests))
+	for key := range p.manifests {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
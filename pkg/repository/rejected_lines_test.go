@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+const rejectedTestLine = "12345;01.12.2024;10:30:00;1;001;100;1;ITEM001;GRP01;1000.50;5;5025.50;1;10;100.10;500.50;1;SKU001;1234567890;1000.00;01;0;0;0;;info;1;1;0;;0;0;;;0;;0;;;0;;;;"
+
+func TestLoadFileStreamStoresRejectedLinesInSameTransaction(t *testing.T) {
+	tx := &fakeTx{}
+	loader := newLoaderWithDB(&loaderDBMock{
+		beginTxFunc: func(ctx context.Context) (pgx.Tx, error) {
+			return tx, nil
+		},
+	})
+	loader.policy = retryPolicy{maxRetries: 1, initialBackoff: 0, maxBackoff: 0}
+
+	state := &models.FileLoadState{LogicalKey: "/response/P13/response.txt|2024-12-01", SourceFolder: "P13/P13"}
+	result, err := loader.LoadFileStream(context.Background(), state, nil, 10, func(emit EmitFunc, reject RejectFunc) error {
+		return reject(models.RejectedLine{LineNumber: 7, RawLine: "1;01.12.2024;10:30:00;999;Привет", TransactionType: 999, Error: "unhandled transaction type: 999"})
+	})
+	if err != nil {
+		t.Fatalf("LoadFileStream() unexpected error: %v", err)
+	}
+	if result.RejectedLines != 1 || result.TransactionCount != 0 {
+		t.Fatalf("result = %+v, want 1 rejected line and no transactions", result)
+	}
+	if len(tx.execSQL) != 3 {
+		t.Fatalf("Exec() = %v, want cleanup, insert and file state upsert", tx.execSQL)
+	}
+	if !strings.Contains(tx.execSQL[1], "INSERT INTO etl_rejected_lines") {
+		t.Fatalf("Exec()[1] = %q, want rejected line insert", tx.execSQL[1])
+	}
+	wantArgs := []any{"P13/P13", state.LogicalKey, 7, "1;01.12.2024;10:30:00;999;Привет", 999, "unhandled transaction type: 999"}
+	if !reflect.DeepEqual(tx.execArgs[1], wantArgs) {
+		t.Fatalf("insert args = %#v, want %#v", tx.execArgs[1], wantArgs)
+	}
+	if !strings.Contains(tx.execSQL[2], "etl_file_load_state") || tx.commitCalls != 1 {
+		t.Fatalf("Exec()[2] = %q, commits = %d, want file state upsert and one commit", tx.execSQL[2], tx.commitCalls)
+	}
+}
+
+func TestLoadFileStreamRejectRequiresFileState(t *testing.T) {
+	loader := newLoaderWithDB(&loaderDBMock{})
+	loader.policy = retryPolicy{maxRetries: 1, initialBackoff: 0, maxBackoff: 0}
+
+	_, err := loader.LoadFileStream(context.Background(), nil, nil, 10, func(emit EmitFunc, reject RejectFunc) error {
+		return reject(models.RejectedLine{LineNumber: 4})
+	})
+	if err == nil || !strings.Contains(err.Error(), "requires file load state") {
+		t.Fatalf("LoadFileStream() error = %v, want missing file state error", err)
+	}
+}
+
+func TestBuildRejectedLinesQuery(t *testing.T) {
+	query, args := buildRejectedLinesQuery(RejectedLineFilter{SourceFolder: "P13/P13", Status: models.RejectedLineStatusRejected, Limit: 5000})
+	if !strings.Contains(query, "WHERE source_folder = $1 AND status = $2") || !strings.HasSuffix(query, "ORDER BY id LIMIT $3") {
+		t.Fatalf("query = %q", query)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"P13/P13", models.RejectedLineStatusRejected, maxRejectedLinesLimit}) {
+		t.Fatalf("args = %#v", args)
+	}
+
+	query, args = buildRejectedLinesQuery(RejectedLineFilter{})
+	if strings.Contains(query, "WHERE") || !reflect.DeepEqual(args, []interface{}{defaultRejectedLinesLimit}) {
+		t.Fatalf("query = %q, args = %#v", query, args)
+	}
+}
+
+func TestPlanReprocessSplitsParsedAndFailedLines(t *testing.T) {
+	lines := []models.RejectedLine{
+		{ID: 1, SourceFolder: "P13/P13", LogicalKey: "key-a", RawLine: rejectedTestLine},
+		{ID: 2, SourceFolder: "P13/P13", LogicalKey: "key-a", RawLine: "1;01.12.2024;10:30:00;999;1"},
+		{ID: 3, SourceFolder: "P13/P13", LogicalKey: "key-b", RawLine: "日本"},
+		{ID: 4, SourceFolder: "P13/P13", LogicalKey: "key-b", RawLine: strings.Replace(rejectedTestLine, "01.12.2024", "32.12.2024", 1)},
+	}
+
+	plan := planReprocess(lines)
+	if !reflect.DeepEqual(plan.reprocessed, []int64{1}) {
+		t.Fatalf("reprocessed = %v, want [1]", plan.reprocessed)
+	}
+	if len(plan.failures) != 3 || plan.failures[0].id != 2 || !strings.Contains(plan.failures[0].err, "999") || plan.failures[1].id != 3 {
+		t.Fatalf("failures = %+v", plan.failures)
+	}
+	// A malformed date is not coerced to zero: the line stays rejected
+	if plan.failures[2].id != 4 || !strings.Contains(plan.failures[2].err, "transaction_date") {
+		t.Fatalf("failures = %+v, want line 4 rejected for transaction_date", plan.failures)
+	}
+	rows := plan.rows["tx_item_registration_1_11"]
+	if len(rows) != 1 {
+		t.Fatalf("rows = %v, want one tx_item_registration_1_11 row", plan.rows)
+	}
+	if row, ok := rows[0].(models.TxItemRegistration1_11); !ok || row.SourceFolder != "P13/P13" {
+		t.Fatalf("row = %#v", rows[0])
+	}
+	if got := plan.manifests["key-a"]["tx_item_registration_1_11"]; !reflect.DeepEqual(got, []int64{12345}) {
+		t.Fatalf("manifest additions = %v, want [12345]", plan.manifests)
+	}
+}
+
+func TestMergeManifestSkipsKnownIDs(t *testing.T) {
+	manifest := map[string][]int64{"tx_a": {1, 2}}
+	mergeManifest(manifest, map[string][]int64{"tx_a": {2, 3}, "tx_b": {4}})
+
+	want := map[string][]int64{"tx_a": {1, 2, 3}, "tx_b": {4}}
+	if !reflect.DeepEqual(manifest, want) {
+		t.Fatalf("manifest = %v, want %v", manifest, want)
+	}
+}
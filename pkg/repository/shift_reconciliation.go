@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+// shiftReconciliationTolerance is the largest difference still treated as a
+// match; it absorbs per-receipt rounding.
+const shiftReconciliationTolerance = 0.01
+
+// frontolOperationReturn is the value of field 13 "Операция" of a return
+// document; every other operation of a closed receipt counts as a sale.
+const frontolOperationReturn = 1
+
+// shiftReconciliationQuery selects the shifts of a source folder that have
+// receipts or Z data within a date range, with their closed receipt totals
+// and the latest Z-report (63), falling back to the latest shift close (61).
+const shiftReconciliationQuery = `
+	WITH shifts AS (
+		SELECT cash_register_code, shift_number
+		FROM receipts
+		WHERE source_folder = $1 AND transaction_date BETWEEN $2::date AND $3::date
+		UNION
+		SELECT COALESCE(cash_register_code, 0), COALESCE(shift_number, 0)
+		FROM tx_report_z_63
+		WHERE source_folder = $1 AND transaction_date BETWEEN $2::date AND $3::date
+		UNION
+		SELECT COALESCE(cash_register_code, 0), COALESCE(shift_number, 0)
+		FROM tx_shift_close_61
+		WHERE source_folder = $1 AND transaction_date BETWEEN $2::date AND $3::date
+	),
+	totals AS (
+		SELECT r.cash_register_code, r.shift_number,
+			COUNT(*) AS receipt_count,
+			MIN(r.transaction_date) AS transaction_date,
+			SUM(r.net_amount) FILTER (WHERE r.operation_type IS DISTINCT FROM $4) AS sales_amount,
+			SUM(r.net_amount) FILTER (WHERE r.operation_type = $4) AS returns_amount,
+			SUM(CASE WHEN r.operation_type = $4 THEN -r.paid_amount ELSE r.paid_amount END) AS payments_revenue
+		FROM receipts r
+		JOIN shifts USING (cash_register_code, shift_number)
+		WHERE r.source_folder = $1 AND r.status = 'closed'
+		GROUP BY r.cash_register_code, r.shift_number
+	),
+	z AS (
+		SELECT DISTINCT ON (cash_register_code, shift_number)
+			cash_register_code, shift_number, transaction_type, transaction_id_unique, transaction_date, shift_revenue
+		FROM (
+			SELECT COALESCE(cash_register_code, 0) AS cash_register_code, COALESCE(shift_number, 0) AS shift_number,
+				63 AS transaction_type, transaction_id_unique, transaction_date, shift_revenue, 0 AS priority
+			FROM tx_report_z_63
+			WHERE source_folder = $1
+			UNION ALL
+			SELECT COALESCE(cash_register_code, 0), COALESCE(shift_number, 0),
+				61, transaction_id_unique, transaction_date, shift_revenue, 1
+			FROM tx_shift_close_61
+			WHERE source_folder = $1
+		) report
+		JOIN shifts USING (cash_register_code, shift_number)
+		ORDER BY cash_register_code, shift_number, priority, transaction_id_unique DESC
+	)
+	SELECT s.cash_register_code, s.shift_number,
+		COALESCE(z.transaction_date, t.transaction_date),
+		COALESCE(z.transaction_type, 0), COALESCE(z.transaction_id_unique, 0), COALESCE(z.shift_revenue, 0)::float8,
+		COALESCE(t.receipt_count, 0), COALESCE(t.sales_amount, 0)::float8, COALESCE(t.returns_amount, 0)::float8,
+		COALESCE(t.payments_revenue, 0)::float8
+	FROM shifts s
+	LEFT JOIN totals t USING (cash_register_code, shift_number)
+	LEFT JOIN z USING (cash_register_code, shift_number)
+	ORDER BY s.cash_register_code, s.shift_number
+`
+
+// ReconcileShifts checks every shift of sourceFolder that has receipts or Z
+// data within dates: the revenue of its closed receipts (sales minus
+// returns) and of their payments must match the shift revenue of the Z-report.
+// Results replace the previous check of the shift in shift_reconciliation.
+func (l *Loader) ReconcileShifts(ctx context.Context, sourceFolder string, dates models.DateRange) ([]models.ShiftReconciliation, error) {
+	var result []models.ShiftReconciliation
+	err := l.runInTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, shiftReconciliationQuery, sourceFolder, dates.From, dates.To, int64(frontolOperationReturn))
+		if err != nil {
+			return fmt.Errorf("query shift totals: %w", err)
+		}
+		var shifts []models.ShiftReconciliation
+		for rows.Next() {
+			rec := models.ShiftReconciliation{SourceFolder: sourceFolder}
+			var transactionDate *time.Time
+			if err := rows.Scan(
+				&rec.CashRegisterCode, &rec.ShiftNumber, &transactionDate,
+				&rec.ZTransactionType, &rec.ZTransactionID, &rec.ZRevenue,
+				&rec.ReceiptCount, &rec.SalesAmount, &rec.ReturnsAmount, &rec.PaymentsRevenue,
+			); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan shift totals: %w", err)
+			}
+			if transactionDate != nil {
+				rec.TransactionDate = *transactionDate
+			}
+			reconcileShift(&rec)
+			shifts = append(shifts, rec)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("query shift totals: %w", err)
+		}
+
+		if err := upsertShiftReconciliation(ctx, tx, shifts); err != nil {
+			return err
+		}
+		result = shifts
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconcile shifts of %s: %w", sourceFolder, err)
+	}
+	return result, nil
+}
+
+// reconcileShift fills the derived revenue, differences and status of rec
+// from its scanned totals.
+func reconcileShift(rec *models.ShiftReconciliation) {
+	rec.SalesAmount = roundAmount(rec.SalesAmount)
+	rec.ReturnsAmount = roundAmount(rec.ReturnsAmount)
+	rec.PaymentsRevenue = roundAmount(rec.PaymentsRevenue)
+	rec.ItemsRevenue = roundAmount(rec.SalesAmount - rec.ReturnsAmount)
+	if rec.ZTransactionType == 0 {
+		rec.ZRevenue = 0
+		rec.ItemsDifference = 0
+		rec.PaymentsDifference = 0
+		rec.Status = models.ShiftReconciliationNoZReport
+		return
+	}
+	rec.ZRevenue = roundAmount(rec.ZRevenue)
+	rec.ItemsDifference = roundAmount(rec.ItemsRevenue - rec.ZRevenue)
+	rec.PaymentsDifference = roundAmount(rec.PaymentsRevenue - rec.ZRevenue)
+	if math.Abs(rec.ItemsDifference) > shiftReconciliationTolerance || math.Abs(rec.PaymentsDifference) > shiftReconciliationTolerance {
+		rec.Status = models.ShiftReconciliationMismatch
+		return
+	}
+	rec.Status = models.ShiftReconciliationMatched
+}
+
+func upsertShiftReconciliation(ctx context.Context, tx pgx.Tx, shifts []models.ShiftReconciliation) error {
+	if len(shifts) == 0 {
+		return nil
+	}
+	batch := &pgx.Batch{}
+	for _, rec := range shifts {
+		var zType, zID, zRevenue, itemsDifference, paymentsDifference interface{}
+		if rec.Status != models.ShiftReconciliationNoZReport {
+			zType, zID, zRevenue = rec.ZTransactionType, rec.ZTransactionID, rec.ZRevenue
+			itemsDifference, paymentsDifference = rec.ItemsDifference, rec.PaymentsDifference
+		}
+		batch.Queue(`
+			INSERT INTO shift_reconciliation (
+				source_folder, cash_register_code, shift_number, transaction_date, status,
+				z_transaction_type, z_transaction_id, z_revenue,
+				receipt_count, sales_amount, returns_amount, items_revenue, payments_revenue,
+				items_difference, payments_difference, checked_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NOW())
+			ON CONFLICT (source_folder, cash_register_code, shift_number) DO UPDATE SET
+				transaction_date = EXCLUDED.transaction_date,
+				status = EXCLUDED.status,
+				z_transaction_type = EXCLUDED.z_transaction_type,
+				z_transaction_id = EXCLUDED.z_transaction_id,
+				z_revenue = EXCLUDED.z_revenue,
+				receipt_count = EXCLUDED.receipt_count,
+				sales_amount = EXCLUDED.sales_amount,
+				returns_amount = EXCLUDED.returns_amount,
+				items_revenue = EXCLUDED.items_revenue,
+				payments_revenue = EXCLUDED.payments_revenue,
+				items_difference = EXCLUDED.items_difference,
+				payments_difference = EXCLUDED.payments_difference,
+				checked_at = EXCLUDED.checked_at
+		`, rec.SourceFolder, rec.CashRegisterCode, rec.ShiftNumber, nullTime(rec.TransactionDate), rec.Status,
+			zType, zID, zRevenue,
+			rec.ReceiptCount, rec.SalesAmount, rec.ReturnsAmount, rec.ItemsRevenue, rec.PaymentsRevenue,
+			itemsDifference, paymentsDifference)
+	}
+
+	br := tx.SendBatch(ctx, batch)
+	for i := 0; i < batch.Len(); i++ {
+		if _, err := br.Exec(); err != nil {
+			if closeErr := br.Close(); closeErr != nil {
+				return fmt.Errorf("upsert shift_reconciliation (statement %d): %v; close batch: %w", i+1, err, closeErr)
+			}
+			return fmt.Errorf("upsert shift_reconciliation (statement %d): %w", i+1, err)
+		}
+	}
+	if err := br.Close(); err != nil {
+		return fmt.Errorf("close shift_reconciliation batch: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+func TestReconcileShift(t *testing.T) {
+	tests := []struct {
+		name       string
+		rec        models.ShiftReconciliation
+		wantStatus string
+		wantItems  float64
+		wantPaid   float64
+	}{
+		{
+			name:       "matched within rounding",
+			rec:        models.ShiftReconciliation{ZTransactionType: 63, ZRevenue: 150, SalesAmount: 200.004, ReturnsAmount: 50, PaymentsRevenue: 149.995},
+			wantStatus: models.ShiftReconciliationMatched,
+			wantItems:  0.004,
+			wantPaid:   -0.005,
+		},
+		{
+			name:       "payments short of Z revenue",
+			rec:        models.ShiftReconciliation{ZTransactionType: 61, ZRevenue: 150, SalesAmount: 150, PaymentsRevenue: 120},
+			wantStatus: models.ShiftReconciliationMismatch,
+			wantItems:  0,
+			wantPaid:   -30,
+		},
+		{
+			name:       "no Z data",
+			rec:        models.ShiftReconciliation{ZRevenue: 99, SalesAmount: 150, PaymentsRevenue: 150},
+			wantStatus: models.ShiftReconciliationNoZReport,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := tt.rec
+			reconcileShift(&rec)
+			if rec.Status != tt.wantStatus {
+				t.Fatalf("Status = %s, want %s", rec.Status, tt.wantStatus)
+			}
+			if rec.ItemsRevenue != roundAmount(tt.rec.SalesAmount-tt.rec.ReturnsAmount) {
+				t.Fatalf("ItemsRevenue = %v, want sales minus returns", rec.ItemsRevenue)
+			}
+			if rec.ItemsDifference != tt.wantItems || rec.PaymentsDifference != tt.wantPaid {
+				t.Fatalf("differences items/payments = %v/%v, want %v/%v", rec.ItemsDifference, rec.PaymentsDifference, tt.wantItems, tt.wantPaid)
+			}
+			if tt.wantStatus == models.ShiftReconciliationNoZReport && rec.ZRevenue != 0 {
+				t.Fatalf("ZRevenue = %v, want 0 without Z data", rec.ZRevenue)
+			}
+		})
+	}
+}
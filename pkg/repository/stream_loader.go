@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/parser"
+)
+
+const defaultStreamBatchSize = 1000
+
+// EmitFunc hands a single parsed row for tableName to the loader.
+type EmitFunc func(tableName string, row interface{}) error
+
+// RejectFunc hands a line that could not be parsed to the loader, which stores
+// it in etl_rejected_lines.
+type RejectFunc func(line models.RejectedLine) error
+
+// TransactionSource produces the rows of one file by calling emit for each of
+// them in file order, and reject for each unparseable line. The loader may
+// invoke a source more than once when the database transaction is retried, so
+// it must be able to restart from scratch.
+type TransactionSource func(emit EmitFunc, reject RejectFunc) error
+
+// StreamLoadResult summarises a streamed file load.
+type StreamLoadResult struct {
+	TransactionCount   int
+	TableCounts        map[string]int
+	Manifest           map[string][]int64
+	TransactionDetails []map[string]interface{}
+	RejectedLines      int
+}
+
+// LoadFileStream loads rows produced by source inside a single database
+// transaction, flushing each table every batchSize rows instead of holding the
+// whole file in memory. Stale rows from a previous version of the file are
+// removed first; rejected lines and the file load state (with the manifest of
+// the streamed rows) are persisted in the same transaction, so a failure at
+// any point leaves the database untouched. Derived models such as receipts
+// are rebuilt for the touched documents before the transaction commits.
+//
+// When fileState requests several days (RequestedDateTo), one state is
+// stored per day with the rows of that transaction_date; fileState.LogicalKey
+// must then be the key of the first day, which also holds rejected lines and
+// rows dated outside the range.
+func (l *Loader) LoadFileStream(ctx context.Context, fileState *models.FileLoadState, staleManifest map[string][]int64, batchSize int, source TransactionSource) (*StreamLoadResult, error) {
+	if batchSize <= 0 {
+		batchSize = defaultStreamBatchSize
+	}
+
+	var result *StreamLoadResult
+	err := l.runInTx(ctx, func(tx pgx.Tx) error {
+		sourceFolder := ""
+		if fileState != nil {
+			sourceFolder = fileState.SourceFolder
+		}
+		scope := l.newDerivedScope()
+		if err := scope.trackStale(ctx, tx, sourceFolder, staleManifest); err != nil {
+			return fmt.Errorf("failed to reconcile stale file rows: %w", err)
+		}
+		if err := l.deleteStaleRows(ctx, tx, sourceFolder, staleManifest); err != nil {
+			return fmt.Errorf("failed to reconcile stale file rows: %w", err)
+		}
+		if fileState != nil {
+			if err := deleteRejectedLines(ctx, tx, fileState.LogicalKey); err != nil {
+				return err
+			}
+		}
+
+		batcher := newStreamBatcher(batchSize, func(tableName string, rows []interface{}) error {
+			if err := l.loadTransactionType(ctx, tx, scope, tableName, rows); err != nil {
+				return fmt.Errorf("failed to load %s: %w", tableName, err)
+			}
+			return nil
+		})
+		rejected := 0
+		reject := func(line models.RejectedLine) error {
+			if fileState == nil {
+				return fmt.Errorf("rejected line %d requires file load state", line.LineNumber)
+			}
+			line.SourceFolder = fileState.SourceFolder
+			line.LogicalKey = fileState.LogicalKey
+			if err := insertRejectedLine(ctx, tx, line); err != nil {
+				return err
+			}
+			rejected++
+			return nil
+		}
+		if err := source(batcher.add, reject); err != nil {
+			return err
+		}
+		if err := batcher.flushAll(); err != nil {
+			return err
+		}
+		if err := scope.rebuild(ctx, tx); err != nil {
+			return err
+		}
+
+		attempt := batcher.result()
+		attempt.RejectedLines = rejected
+		if fileState != nil && (attempt.TransactionCount > 0 || rejected > 0 || len(staleManifest) > 0) {
+			for _, state := range splitFileLoadState(*fileState, attempt.Manifest, batcher.days) {
+				state := state
+				if err := l.upsertFileLoadState(ctx, tx, &state); err != nil {
+					return fmt.Errorf("failed to persist file load state: %w", err)
+				}
+			}
+		}
+
+		result = attempt
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, detail := range result.TransactionDetails {
+		slog.InfoContext(ctx, "Transaction type found",
+			"table", detail["table_name"],
+			"count", detail["count"],
+			"event", "transaction_type_count",
+		)
+	}
+	if result.RejectedLines > 0 {
+		slog.WarnContext(ctx, "Unparseable lines moved to etl_rejected_lines",
+			"logical_key", fileState.LogicalKey,
+			"rejected_lines", result.RejectedLines,
+			"event", "rejected_lines_stored",
+		)
+	}
+	return result, nil
+}
+
+// streamBatcher buffers streamed rows per table and hands them to flush once
+// a table accumulates batchSize rows.
+type streamBatcher struct {
+	batchSize int
+	flush     func(tableName string, rows []interface{}) error
+	pending   map[string][]interface{}
+	counts    map[string]int
+	manifest  map[string][]int64
+	// days splits manifest by the transaction_date of the rows
+	days map[string]map[string][]int64
+}
+
+func newStreamBatcher(batchSize int, flush func(tableName string, rows []interface{}) error) *streamBatcher {
+	return &streamBatcher{
+		batchSize: batchSize,
+		flush:     flush,
+		pending:   make(map[string][]interface{}),
+		counts:    make(map[string]int),
+		manifest:  make(map[string][]int64),
+		days:      make(map[string]map[string][]int64),
+	}
+}
+
+func (b *streamBatcher) add(tableName string, row interface{}) error {
+	if _, ok := parser.TableSchema(tableName); !ok {
+		return fmt.Errorf("unknown transaction type: %s", tableName)
+	}
+	id, err := transactionIDUnique(row)
+	if err != nil {
+		return fmt.Errorf("table %s row %d: %w", tableName, b.counts[tableName], err)
+	}
+
+	b.pending[tableName] = append(b.pending[tableName], row)
+	b.manifest[tableName] = append(b.manifest[tableName], id)
+	day := transactionDay(row)
+	if b.days[day] == nil {
+		b.days[day] = make(map[string][]int64)
+	}
+	b.days[day][tableName] = append(b.days[day][tableName], id)
+	b.counts[tableName]++
+
+	if len(b.pending[tableName]) >= b.batchSize {
+		return b.flushTable(tableName)
+	}
+	return nil
+}
+
+func (b *streamBatcher) flushTable(tableName string) error {
+	rows := b.pending[tableName]
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := b.flush(tableName, rows); err != nil {
+		return err
+	}
+	b.pending[tableName] = rows[:0]
+	return nil
+}
+
+func (b *streamBatcher) flushAll() error {
+	tables := make([]string, 0, len(b.pending))
+	for tableName := range b.pending {
+		tables = append(tables, tableName)
+	}
+	sort.Strings(tables)
+	for _, tableName := range tables {
+		if err := b.flushTable(tableName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *streamBatcher) result() *StreamLoadResult {
+	result := &StreamLoadResult{
+		TableCounts:        b.counts,
+		Manifest:           b.manifest,
+		TransactionDetails: make([]map[string]interface{}, 0, len(b.counts)),
+	}
+	tables := make([]string, 0, len(b.counts))
+	for tableName := range b.counts {
+		tables = append(tables, tableName)
+	}
+	sort.Strings(tables)
+	for _, tableName := range tables {
+		count := b.counts[tableName]
+		result.TransactionCount += count
+		result.TransactionDetails = append(result.TransactionDetails, map[string]interface{}{
+			"table_name": tableName,
+			"count":      count,
+		})
+	}
+	return result
+}
+
+func transactionIDUnique(row interface{}) (int64, error) {
+	rv := reflect.ValueOf(row)
+	if rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return 0, fmt.Errorf("row is not struct: %T", row)
+	}
+	field := rv.FieldByName("TransactionIDUnique")
+	if !field.IsValid() || field.Kind() != reflect.Int64 {
+		return 0, fmt.Errorf("row %T missing TransactionIDUnique", row)
+	}
+	return field.Int(), nil
+}
+
+// transactionDay returns the transaction_date of row as YYYY-MM-DD, or an
+// empty string when the row has none.
+func transactionDay(row interface{}) string {
+	rv := reflect.ValueOf(row)
+	if rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return ""
+	}
+	field := rv.FieldByName("TransactionDate")
+	if !field.IsValid() {
+		return ""
+	}
+	date, ok := field.Interface().(time.Time)
+	if !ok || date.IsZero() {
+		return ""
+	}
+	return date.Format(models.DateLayout)
+}
+
+// splitFileLoadState returns the states to store for a loaded file: the state
+// itself for a single requested day, otherwise one state per day of the range
+// holding the rows of that day. Rows dated outside the range stay with the
+// first day.
+func splitFileLoadState(fileState models.FileLoadState, manifest map[string][]int64, days map[string]map[string][]int64) []models.FileLoadState {
+	dates := models.DateRange{From: fileState.RequestedDate, To: fileState.RequestedDateTo}
+	fileState.RequestedDateTo = ""
+	if fileState.RequestedDate == "" || dates.IsSingleDay() {
+		fileState.TransactionManifest = manifest
+		return []models.FileLoadState{fileState}
+	}
+
+	requested := dates.Days()
+	states := make([]models.FileLoadState, len(requested))
+	index := make(map[string]int, len(requested))
+	for i, day := range requested {
+		state := fileState
+		state.LogicalKey = models.FileLoadLogicalKey(fileState.RemotePath, day)
+		state.RequestedDate = day
+		state.TransactionManifest = make(map[string][]int64)
+		mergeManifest(state.TransactionManifest, days[day])
+		states[i] = state
+		index[day] = i
+	}
+	outside := make([]string, 0)
+	for day := range days {
+		if _, ok := index[day]; !ok {
+			outside = append(outside, day)
+		}
+	}
+	sort.Strings(outside)
+	for _, day := range outside {
+		mergeManifest(states[0].TransactionManifest, days[day])
+	}
+	return states
+}
@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	loc := time.FixedZone("MSK", 3*60*60)
+	base := time.Date(2024, 12, 4, 10, 30, 15, 0, loc) // Wednesday
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"0 2 * * *", base, time.Date(2024, 12, 5, 2, 0, 0, 0, loc)},
+		{"@daily", base, time.Date(2024, 12, 5, 0, 0, 0, 0, loc)},
+		{"*/15 * * * *", base, time.Date(2024, 12, 4, 10, 45, 0, 0, loc)},
+		{"0 3 * * 0", base, time.Date(2024, 12, 8, 3, 0, 0, 0, loc)},
+		{"0 3 * * 7", base, time.Date(2024, 12, 8, 3, 0, 0, 0, loc)},
+		{"30 10 * * 1-5", base, time.Date(2024, 12, 5, 10, 30, 0, 0, loc)},
+		{"0 0 1 1 *", base, time.Date(2025, 1, 1, 0, 0, 0, 0, loc)},
+		{"0 12 29 2 *", base, time.Date(2028, 2, 29, 12, 0, 0, 0, loc)},
+		// Both day fields restricted: either one matches
+		{"0 0 10 * 5", base, time.Date(2024, 12, 6, 0, 0, 0, 0, loc)},
+		{"0 9,18 * * *", base, time.Date(2024, 12, 4, 18, 0, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		cron, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatalf("Parse(%q) unexpected error: %v", tt.spec, err)
+		}
+		if got := cron.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("Parse(%q).Next(%s) = %s, want %s", tt.spec, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestCronNextNeverMatches(t *testing.T) {
+	cron, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("Parse() unexpected error: %v", err)
+	}
+	if next := cron.Next(time.Now()); !next.IsZero() {
+		t.Fatalf("Next() = %s, want zero time", next)
+	}
+}
+
+func TestParseRejectsInvalidExpressions(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"0 2 * *",
+		"0 2 * * * *",
+		"60 * * * *",
+		"0 24 * * *",
+		"0 0 0 * *",
+		"0 0 * 13 *",
+		"0 0 * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@yearly",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) expected error", spec)
+		}
+	}
+}
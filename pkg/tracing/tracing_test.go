@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+func TestTraceParentRoundTrip(t *testing.T) {
+	const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	ctx := WithTraceParent(context.Background(), traceParent)
+	if got := TraceParent(ctx); got != traceParent {
+		t.Fatalf("TraceParent() = %q, want %q", got, traceParent)
+	}
+	if got := TraceID(ctx); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("TraceID() = %q", got)
+	}
+}
+
+func TestWithTraceParentIgnoresInvalidValue(t *testing.T) {
+	for _, value := range []string{"", "not-a-traceparent"} {
+		ctx := WithTraceParent(context.Background(), value)
+		if got := TraceParent(ctx); got != "" {
+			t.Fatalf("TraceParent() after %q = %q, want empty", value, got)
+		}
+	}
+}
+
+func TestStartCreatesChildOfRemoteParent(t *testing.T) {
+	shutdown, err := Setup(context.Background(), &models.Config{TracingExporter: ExporterStdout})
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	defer func() { _ = shutdown(context.Background()) }()
+
+	parent := WithTraceParent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, span := Start(parent, "test.span")
+	defer End(span, nil)
+
+	if got := TraceID(ctx); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("child TraceID() = %q, want parent trace", got)
+	}
+	if !span.SpanContext().IsValid() || !span.IsRecording() {
+		t.Fatalf("span is not recording: %+v", span.SpanContext())
+	}
+}
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), &models.Config{TracingExporter: "jaeger"}); err == nil {
+		t.Fatal("Setup() error = nil, want unknown exporter error")
+	}
+}
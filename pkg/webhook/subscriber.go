@@ -0,0 +1,209 @@
+// Package webhook matches webhook reports to subscribers and renders the
+// request body each subscriber receives.
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+// Report statuses a subscriber may filter on.
+var reportStatuses = map[string]bool{
+	"completed": true,
+	"partial":   true,
+	"failed":    true,
+	"timeout":   true,
+	"canceled":  true,
+}
+
+// Operation types a subscriber may filter on.
+var operationTypes = map[string]bool{
+	"load":     true,
+	"download": true,
+	"cli_load": true,
+}
+
+const defaultContentType = "application/json"
+
+// templateFuncs are available in payload templates; json quotes a value, so
+// report fields can be placed inside a JSON template safely.
+var templateFuncs = template.FuncMap{
+	"json": func(value any) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+// Event describes a report for subscriber filters.
+type Event struct {
+	Status        string
+	OperationType string
+	// Kassas are the source folders of a targeted load; empty means every
+	// kassa.
+	Kassas []string
+}
+
+// Subscriber is a validated subscriber with its payload template compiled.
+type Subscriber struct {
+	models.WebhookSubscriber
+	template   *template.Template
+	authName   string
+	authValue  string
+	statuses   map[string]bool
+	types      map[string]bool
+	kassaCodes map[string]bool // kassa codes and source folders of the kassa filter
+}
+
+// Compile validates sub and compiles its template.
+func Compile(sub models.WebhookSubscriber) (*Subscriber, error) {
+	if sub.Name == "" {
+		return nil, fmt.Errorf("subscriber name is required")
+	}
+	parsed, err := url.Parse(sub.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("subscriber %s: url must be an absolute http or https URL, got %q", sub.Name, sub.URL)
+	}
+	compiled := &Subscriber{WebhookSubscriber: sub}
+	if sub.AuthHeader != "" {
+		name, value, ok := strings.Cut(sub.AuthHeader, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("subscriber %s: auth_header must be \"Name: value\"", sub.Name)
+		}
+		compiled.authName = strings.TrimSpace(name)
+		compiled.authValue = strings.TrimSpace(value)
+	}
+	if len(sub.Statuses) > 0 {
+		compiled.statuses = make(map[string]bool, len(sub.Statuses))
+		for _, status := range sub.Statuses {
+			status = strings.ToLower(strings.TrimSpace(status))
+			if !reportStatuses[status] {
+				return nil, fmt.Errorf("subscriber %s: status must be one of: completed, partial, failed, timeout, canceled; got %s", sub.Name, status)
+			}
+			compiled.statuses[status] = true
+		}
+	}
+	if len(sub.OperationTypes) > 0 {
+		compiled.types = make(map[string]bool, len(sub.OperationTypes))
+		for _, operationType := range sub.OperationTypes {
+			operationType = strings.ToLower(strings.TrimSpace(operationType))
+			if !operationTypes[operationType] {
+				return nil, fmt.Errorf("subscriber %s: operation type must be one of: load, download, cli_load; got %s", sub.Name, operationType)
+			}
+			compiled.types[operationType] = true
+		}
+	}
+	if len(sub.Kassas) > 0 {
+		compiled.kassaCodes = make(map[string]bool, len(sub.Kassas))
+		for _, kassa := range sub.Kassas {
+			if kassa = strings.TrimSpace(kassa); kassa != "" {
+				compiled.kassaCodes[kassa] = true
+			}
+		}
+	}
+	if sub.Template != "" {
+		compiled.template, err = template.New(sub.Name).Funcs(templateFuncs).Option("missingkey=zero").Parse(sub.Template)
+		if err != nil {
+			return nil, fmt.Errorf("subscriber %s: invalid template: %w", sub.Name, err)
+		}
+	}
+	return compiled, nil
+}
+
+// Default returns the subscriber of WEBHOOK_REPORT_URL: every report, sent
+// with the bearer token when one is set. The URL is not validated, as before
+// subscribers existed.
+func Default(url, bearerToken string) *Subscriber {
+	sub := &Subscriber{WebhookSubscriber: models.WebhookSubscriber{Name: models.DefaultWebhookSubscriber, URL: url}}
+	if bearerToken != "" {
+		sub.AuthHeader = "Authorization: Bearer " + bearerToken
+		sub.authName, sub.authValue = "Authorization", "Bearer "+bearerToken
+	}
+	return sub
+}
+
+// HasKassaFilter reports whether the subscriber receives reports of some
+// kassas only.
+func (s *Subscriber) HasKassaFilter() bool {
+	return s.kassaCodes != nil
+}
+
+// Matches reports whether the subscriber wants a report of event. A full load
+// of every kassa matches any kassa filter.
+func (s *Subscriber) Matches(event Event) bool {
+	if s.statuses != nil && !s.statuses[event.Status] {
+		return false
+	}
+	if s.types != nil && !s.types[event.OperationType] {
+		return false
+	}
+	if s.kassaCodes == nil || len(event.Kassas) == 0 {
+		return true
+	}
+	for _, sourceFolder := range event.Kassas {
+		if s.MatchesKassa(sourceFolder) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesKassaEvent reports whether the subscriber wants progress events of
+// sourceFolder in an operation of operationType. Status filters apply to
+// reports only.
+func (s *Subscriber) MatchesKassaEvent(operationType, sourceFolder string) bool {
+	if !s.KassaEvents {
+		return false
+	}
+	if s.types != nil && !s.types[operationType] {
+		return false
+	}
+	return s.MatchesKassa(sourceFolder)
+}
+
+// MatchesKassa reports whether sourceFolder passes the kassa filter, by its
+// source folder or its kassa code.
+func (s *Subscriber) MatchesKassa(sourceFolder string) bool {
+	if s.kassaCodes == nil {
+		return true
+	}
+	code, _, _ := strings.Cut(sourceFolder, "/")
+	return s.kassaCodes[sourceFolder] || s.kassaCodes[code]
+}
+
+// Render returns the request body for the report JSON: the report itself, or
+// the template executed with the report's JSON fields (.status,
+// .files_processed...).
+func (s *Subscriber) Render(report []byte) ([]byte, error) {
+	if s.template == nil {
+		return report, nil
+	}
+	var data map[string]any
+	if err := json.Unmarshal(report, &data); err != nil {
+		return nil, fmt.Errorf("decode report for template: %w", err)
+	}
+	var body bytes.Buffer
+	if err := s.template.Execute(&body, data); err != nil {
+		return nil, fmt.Errorf("render template of subscriber %s: %w", s.Name, err)
+	}
+	return body.Bytes(), nil
+}
+
+// ContentTypeOrDefault returns the content type of the rendered body.
+func (s *Subscriber) ContentTypeOrDefault() string {
+	if s.ContentType != "" {
+		return s.ContentType
+	}
+	return defaultContentType
+}
+
+// AuthHeaderPair returns the auth header name and value, or empty strings
+// when the subscriber has none.
+func (s *Subscriber) AuthHeaderPair() (string, string) {
+	return s.authName, s.authValue
+}
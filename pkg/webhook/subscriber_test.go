@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/user/go-frontol-loader/pkg/models"
+)
+
+func mustCompile(t *testing.T, sub models.WebhookSubscriber) *Subscriber {
+	t.Helper()
+	compiled, err := Compile(sub)
+	if err != nil {
+		t.Fatalf("Compile(%+v) unexpected error: %v", sub, err)
+	}
+	return compiled
+}
+
+func TestSubscriberMatches(t *testing.T) {
+	sub := mustCompile(t, models.WebhookSubscriber{
+		Name:           "alerts",
+		URL:            "https://alerts.example.com/hook",
+		Statuses:       []string{"failed", "Timeout"},
+		OperationTypes: []string{"load"},
+		Kassas:         []string{"P13", "L32/L32_INTER"},
+	})
+
+	tests := []struct {
+		name  string
+		event Event
+		want  bool
+	}{
+		{"matching status and kassa code", Event{Status: "failed", OperationType: "load", Kassas: []string{"P13/P13"}}, true},
+		{"matching source folder", Event{Status: "timeout", OperationType: "load", Kassas: []string{"L32/L32_INTER"}}, true},
+		{"full load matches kassa filter", Event{Status: "failed", OperationType: "load"}, true},
+		{"other status", Event{Status: "completed", OperationType: "load", Kassas: []string{"P13/P13"}}, false},
+		{"other operation type", Event{Status: "failed", OperationType: "download"}, false},
+		{"other kassas", Event{Status: "failed", OperationType: "load", Kassas: []string{"L32/L32_MAIN", "N45/N45"}}, false},
+	}
+	for _, tt := range tests {
+		if got := sub.Matches(tt.event); got != tt.want {
+			t.Errorf("%s: Matches(%+v) = %v, want %v", tt.name, tt.event, got, tt.want)
+		}
+	}
+
+	all := mustCompile(t, models.WebhookSubscriber{Name: "all", URL: "http://receiver/reports"})
+	if !all.Matches(Event{Status: "partial", OperationType: "load", Kassas: []string{"N45/N45"}}) {
+		t.Fatal("subscriber without filters should match every report")
+	}
+}
+
+func TestSubscriberRender(t *testing.T) {
+	report := []byte(`{"request_id":"req_1","status":"failed","files_processed":12,"error_message":"FTP \"down\""}`)
+
+	raw := mustCompile(t, models.WebhookSubscriber{Name: "raw", URL: "http://receiver/reports"})
+	if body, err := raw.Render(report); err != nil || string(body) != string(report) {
+		t.Fatalf("Render() without template = %s, %v; want the report unchanged", body, err)
+	}
+	if got := raw.ContentTypeOrDefault(); got != "application/json" {
+		t.Fatalf("ContentTypeOrDefault() = %q, want application/json", got)
+	}
+
+	chat := mustCompile(t, models.WebhookSubscriber{
+		Name:     "chat",
+		URL:      "http://chat.local/hook",
+		Template: `{"text": {{json (printf "%s: %s, files %v" .request_id .status .files_processed)}}, "error": {{json .error_message}}}`,
+	})
+	body, err := chat.Render(report)
+	if err != nil {
+		t.Fatalf("Render() unexpected error: %v", err)
+	}
+	want := `{"text": "req_1: failed, files 12", "error": "FTP \"down\""}`
+	if string(body) != want {
+		t.Fatalf("Render() = %s, want %s", body, want)
+	}
+}
+
+func TestCompileAuthHeader(t *testing.T) {
+	sub := mustCompile(t, models.WebhookSubscriber{Name: "bi", URL: "https://bi.example.com", AuthHeader: "X-Api-Key:  abc:def "})
+	if name, value := sub.AuthHeaderPair(); name != "X-Api-Key" || value != "abc:def" {
+		t.Fatalf("AuthHeaderPair() = %q, %q; want X-Api-Key, abc:def", name, value)
+	}
+	if _, err := Compile(models.WebhookSubscriber{Name: "bi", URL: "https://bi.example.com", AuthHeader: ": token"}); err == nil {
+		t.Fatal("Compile() with an unnamed auth header expected error")
+	}
+}
+
+func TestSubscriberMatchesKassaEvent(t *testing.T) {
+	sub := mustCompile(t, models.WebhookSubscriber{
+		Name:        "dashboard",
+		URL:         "https://dashboard.example.com/events",
+		Statuses:    []string{"failed"},
+		Kassas:      []string{"P13"},
+		KassaEvents: true,
+	})
+	if !sub.MatchesKassaEvent("load", "P13/P13") {
+		t.Fatal("MatchesKassaEvent(load, P13/P13) = false, want true regardless of status filter")
+	}
+	if sub.MatchesKassaEvent("load", "L32/L32") {
+		t.Fatal("MatchesKassaEvent(load, L32/L32) = true, want false for other kassa")
+	}
+
+	reportsOnly := mustCompile(t, models.WebhookSubscriber{Name: "bi", URL: "https://bi.example.com"})
+	if reportsOnly.MatchesKassaEvent("load", "P13/P13") {
+		t.Fatal("subscriber without kassa_events should not receive events")
+	}
+}
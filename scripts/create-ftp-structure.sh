@@ -82,7 +82,8 @@ for kassa_group in $KASSA_STRUCTURE; do
     # Create folders for each folder name
     IFS=','
     for folder_name in $folders; do
-        folder_name=$(echo "$folder_name" | tr -d ' ')
+        # Strip the optional encoding override (FOLDER@cp1251)
+        folder_name=$(echo "$folder_name" | tr -d ' ' | cut -d'@' -f1)
         if [ -n "$folder_name" ]; then
             request_path="$FTP_REQUEST_DIR/$kassa_code/$folder_name"
             response_path="$FTP_RESPONSE_DIR/$kassa_code/$folder_name"
@@ -0,0 +1,95 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/db"
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/repository"
+	"github.com/user/go-frontol-loader/tests/integration/framework"
+)
+
+// TestFindDataGaps loads a day whose shift has no close, a loaded day without
+// transactions and leaves a day unloaded.
+func TestFindDataGaps(t *testing.T) {
+	env := framework.SetupTestEnvironment(t)
+	env.Reset(t)
+	ctx := env.GetContext()
+
+	pool, err := db.NewPool(env.Postgres.Config)
+	if err != nil {
+		t.Fatalf("Failed to create pool: %v", err)
+	}
+	defer pool.Close()
+	loader := repository.NewLoader(pool)
+
+	folder := "P13/P13"
+	date := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	at := time.Date(0, 1, 1, 10, 0, 0, 0, time.UTC)
+	fileState := func(day string) *models.FileLoadState {
+		remotePath := "/response/P13/P13/response.txt"
+		return &models.FileLoadState{
+			LogicalKey:    models.FileLoadLogicalKey(remotePath, day),
+			RemotePath:    remotePath,
+			RequestedDate: day,
+			SourceFolder:  folder,
+			ContentHash:   "hash-" + day,
+		}
+	}
+
+	// 1 декабря: чек смены 7 без закрытия смены
+	transactions := map[string]interface{}{
+		"tx_document_open_42": []models.TxDocumentOpen42{
+			{TransactionIDUnique: 1, SourceFolder: folder, TransactionDate: date, TransactionTime: at, TransactionType: 42, CashRegisterCode: 1, ShiftNumber: 7, DocumentNumber: 100},
+		},
+		"tx_item_registration_1_11": []models.TxItemRegistration1_11{
+			{TransactionIDUnique: 2, SourceFolder: folder, TransactionDate: date, TransactionTime: at, TransactionType: 11, CashRegisterCode: 1, ShiftNumber: 7, DocumentNumber: 100, ItemIdentifier: "A", Quantity: 1, PositionAmountWithRounding: 100},
+		},
+		"tx_document_close_55": []models.TxDocumentClose55{
+			{TransactionIDUnique: 3, SourceFolder: folder, TransactionDate: date, TransactionTime: at, TransactionType: 55, CashRegisterCode: 1, ShiftNumber: 7, DocumentNumber: 100},
+		},
+	}
+	if err := loader.LoadFileDataWithReconcile(ctx, fileState("2024-12-01"), nil, transactions); err != nil {
+		t.Fatalf("LoadFileDataWithReconcile() unexpected error: %v", err)
+	}
+	// 2 декабря: файл загружен, транзакций нет
+	if err := loader.LoadFileDataWithReconcile(ctx, fileState("2024-12-02"), nil, map[string]interface{}{}); err != nil {
+		t.Fatalf("LoadFileDataWithReconcile() unexpected error: %v", err)
+	}
+
+	gaps, err := loader.FindDataGaps(ctx, []string{folder}, models.DateRange{From: "2024-12-01", To: "2024-12-03"})
+	if err != nil {
+		t.Fatalf("FindDataGaps() unexpected error: %v", err)
+	}
+	want := []models.DataGap{
+		{SourceFolder: folder, Date: "2024-12-01", Kind: models.DataGapUnclosedShift, CashRegisterCode: 1, ShiftNumber: 7},
+		{SourceFolder: folder, Date: "2024-12-02", Kind: models.DataGapNoRows, FilesLoaded: 1},
+		{SourceFolder: folder, Date: "2024-12-03", Kind: models.DataGapNoLoad},
+	}
+	if !reflect.DeepEqual(gaps, want) {
+		t.Fatalf("FindDataGaps() = %+v, want %+v", gaps, want)
+	}
+
+	// Закрытие смены на следующий день закрывает пробел смены
+	closeDate := date.AddDate(0, 0, 1)
+	if err := loader.LoadFileData(ctx, map[string]interface{}{
+		"tx_shift_close_61": []models.TxShiftClose61{
+			{TransactionIDUnique: 4, SourceFolder: folder, TransactionDate: closeDate, TransactionTime: at, TransactionType: 61, CashRegisterCode: 1, ShiftNumber: 7, ShiftRevenue: 100},
+		},
+	}); err != nil {
+		t.Fatalf("LoadFileData() unexpected error: %v", err)
+	}
+	gaps, err = loader.FindDataGaps(ctx, []string{folder}, models.DateRange{From: "2024-12-01", To: "2024-12-03"})
+	if err != nil {
+		t.Fatalf("FindDataGaps() unexpected error: %v", err)
+	}
+	want = []models.DataGap{{SourceFolder: folder, Date: "2024-12-03", Kind: models.DataGapNoLoad}}
+	if !reflect.DeepEqual(gaps, want) {
+		t.Fatalf("FindDataGaps() after shift close = %+v, want %+v", gaps, want)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
-	"sort"
 	"time"
 
 	"github.com/testcontainers/testcontainers-go"
@@ -15,6 +14,7 @@ import (
 	"github.com/user/go-frontol-loader/pkg/db"
 	"github.com/user/go-frontol-loader/pkg/migrate"
 	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/parser"
 )
 
 const (
@@ -124,11 +124,8 @@ func (pc *PostgresContainer) RunMigrations(ctx context.Context) error {
 
 // Truncate truncates all tables for clean test state
 func (pc *PostgresContainer) Truncate(ctx context.Context) error {
-	tables := make([]string, 0, len(models.TxSchemas))
-	for table := range models.TxSchemas {
-		tables = append(tables, table)
-	}
-	sort.Strings(tables)
+	// receipts cascades to receipt_lines and receipt_payments
+	tables := append(parser.RegisteredTables(), "receipts", "shift_reconciliation", "etl_operation_queue", "etl_operation_runs", "etl_webhook_outbox")
 
 	for _, table := range tables {
 		query := fmt.Sprintf("TRUNCATE TABLE %s CASCADE", table)
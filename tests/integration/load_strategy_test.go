@@ -0,0 +1,152 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/db"
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/repository"
+	"github.com/user/go-frontol-loader/tests/integration/framework"
+)
+
+const loadStrategyTable = "tx_item_registration_1_11"
+
+// loadStrategyRows builds n item registrations; every tenth row repeats the
+// key of the previous one with another item, so both paths must keep the last.
+func loadStrategyRows(n int) []models.TxItemRegistration1_11 {
+	rows := make([]models.TxItemRegistration1_11, n)
+	for i := range rows {
+		id := int64(i + 1)
+		if i > 0 && i%10 == 0 {
+			id = int64(i)
+		}
+		rows[i] = models.TxItemRegistration1_11{
+			TransactionIDUnique:        id,
+			SourceFolder:               "P13/P13",
+			TransactionDate:            time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC),
+			TransactionTime:            time.Date(0, 1, 1, 10, 30, i%60, 0, time.UTC),
+			TransactionType:            1,
+			CashRegisterCode:           1,
+			DocumentNumber:             int64(i/5 + 1),
+			CashierCode:                101,
+			ItemIdentifier:             fmt.Sprintf("Товар %d", i),
+			DimensionValueCodes:        "GROUP1",
+			PriceWithoutDiscounts:      float64(i%1000) + 0.5,
+			Quantity:                   float64(i%7 + 1),
+			PositionAmountWithRounding: float64(i%1000) * 1.25,
+			OperationType:              1,
+			ShiftNumber:                1,
+		}
+	}
+	return rows
+}
+
+func newStrategyPool(tb testing.TB, base *models.Config, strategy db.LoadStrategy) *db.Pool {
+	tb.Helper()
+	cfg := *base
+	cfg.LoadStrategy = string(strategy)
+	pool, err := db.NewPool(&cfg)
+	if err != nil {
+		tb.Fatalf("Failed to create %s pool: %v", strategy, err)
+	}
+	tb.Cleanup(pool.Close)
+	return pool
+}
+
+func dumpLoadStrategyTable(tb testing.TB, ctx context.Context, pool *db.Pool) []string {
+	tb.Helper()
+	rows, err := pool.Query(ctx, fmt.Sprintf(`SELECT to_jsonb(t)::text FROM %s t ORDER BY transaction_id_unique, source_folder`, loadStrategyTable))
+	if err != nil {
+		tb.Fatalf("Failed to dump %s: %v", loadStrategyTable, err)
+	}
+	defer rows.Close()
+	var dump []string
+	for rows.Next() {
+		var row string
+		if err := rows.Scan(&row); err != nil {
+			tb.Fatalf("Failed to scan %s: %v", loadStrategyTable, err)
+		}
+		dump = append(dump, row)
+	}
+	if err := rows.Err(); err != nil {
+		tb.Fatalf("Failed to read %s: %v", loadStrategyTable, err)
+	}
+	return dump
+}
+
+// TestLoadStrategiesProduceSameRows loads the same data, duplicate keys
+// included, through the batch and the copy path and compares the tables.
+func TestLoadStrategiesProduceSameRows(t *testing.T) {
+	env := framework.SetupTestEnvironment(t)
+	ctx := env.GetContext()
+	rows := loadStrategyRows(250)
+
+	dumps := make(map[db.LoadStrategy][]string)
+	for _, strategy := range []db.LoadStrategy{db.LoadStrategyBatch, db.LoadStrategyCopy} {
+		env.Reset(t)
+		pool := newStrategyPool(t, env.Postgres.Config, strategy)
+		loader := repository.NewLoader(pool)
+		// Второй прогон проверяет ветку ON CONFLICT DO UPDATE
+		for i := 0; i < 2; i++ {
+			if err := loader.LoadFileData(ctx, map[string]interface{}{loadStrategyTable: rows}); err != nil {
+				t.Fatalf("LoadFileData(%s) unexpected error: %v", strategy, err)
+			}
+		}
+		dumps[strategy] = dumpLoadStrategyTable(t, ctx, pool)
+	}
+
+	if len(dumps[db.LoadStrategyBatch]) != 250-24 {
+		t.Fatalf("batch rows = %d, want %d", len(dumps[db.LoadStrategyBatch]), 250-24)
+	}
+	if !reflect.DeepEqual(dumps[db.LoadStrategyBatch], dumps[db.LoadStrategyCopy]) {
+		t.Fatal("copy strategy produced different rows than batch strategy")
+	}
+}
+
+// BenchmarkLoadStrategies compares the batch and copy paths on a fresh table.
+// Run with: go test -tags=integration -run '^$' -bench LoadStrategies ./tests/integration/
+func BenchmarkLoadStrategies(b *testing.B) {
+	if os.Getenv("SKIP_INTEGRATION_TESTS") == "true" {
+		b.Skip("Skipping integration benchmark")
+	}
+
+	ctx := context.Background()
+	postgres, err := framework.NewPostgresContainer(ctx)
+	if err != nil {
+		b.Fatalf("Failed to start PostgreSQL container: %v", err)
+	}
+	b.Cleanup(func() { _ = postgres.Close(ctx) })
+	if err := postgres.RunMigrations(ctx); err != nil {
+		b.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	for _, size := range []int{1000, 10000, 100000} {
+		rows := loadStrategyRows(size)
+		transactions := map[string]interface{}{loadStrategyTable: rows}
+		for _, strategy := range []db.LoadStrategy{db.LoadStrategyBatch, db.LoadStrategyCopy} {
+			b.Run(fmt.Sprintf("%s/rows=%d", strategy, size), func(b *testing.B) {
+				loader := repository.NewLoader(newStrategyPool(b, postgres.Config, strategy))
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					b.StopTimer()
+					if err := postgres.Truncate(ctx); err != nil {
+						b.Fatalf("Failed to truncate tables: %v", err)
+					}
+					b.StartTimer()
+					if err := loader.LoadFileData(ctx, transactions); err != nil {
+						b.Fatalf("LoadFileData(%s) unexpected error: %v", strategy, err)
+					}
+				}
+				b.ReportMetric(float64(size)*float64(b.N)/b.Elapsed().Seconds(), "rows/s")
+			})
+		}
+	}
+}
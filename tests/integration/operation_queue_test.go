@@ -0,0 +1,172 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/logger"
+	"github.com/user/go-frontol-loader/pkg/operations"
+	"github.com/user/go-frontol-loader/tests/integration/framework"
+)
+
+// TestOperationQueueClaimsRespectOverlappingKassas enqueues a full load and
+// two targeted loads: the targeted loads wait for the full load, then run in
+// parallel because they share no kassa.
+func TestOperationQueueClaimsRespectOverlappingKassas(t *testing.T) {
+	env := framework.SetupTestEnvironment(t)
+	env.Reset(t)
+	ctx := env.GetContext()
+
+	store := operations.NewStore(env.Postgres.Config, logger.New(logger.Config{Output: &bytes.Buffer{}, Format: "json"}))
+	defer store.Close()
+
+	enqueuedAt := time.Now().Add(-time.Minute)
+	jobs := []operations.Job{
+		{OperationID: "op-all", OperationType: "load", Date: "2024-12-01", EnqueuedAt: enqueuedAt},
+		{OperationID: "op-p13", OperationType: "load", Date: "2024-12-01", Kassas: []string{"P13/P13"}, EnqueuedAt: enqueuedAt.Add(time.Second)},
+		{OperationID: "op-l32", OperationType: "load", Date: "2024-12-01", DateTo: "2024-12-02", Kassas: []string{"L32/L32"}, EnqueuedAt: enqueuedAt.Add(2 * time.Second),
+			TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+	}
+	for _, job := range jobs {
+		if err := store.Enqueue(ctx, job); err != nil {
+			t.Fatalf("Enqueue(%s) unexpected error: %v", job.OperationID, err)
+		}
+	}
+
+	first, err := store.Claim(ctx, time.Minute)
+	if err != nil || first == nil || first.OperationID != "op-all" {
+		t.Fatalf("Claim() = %+v, %v; want op-all", first, err)
+	}
+	// Полная загрузка пересекается со всеми целевыми
+	if blocked, err := store.Claim(ctx, time.Minute); err != nil || blocked != nil {
+		t.Fatalf("Claim() while full load runs = %+v, %v; want nil", blocked, err)
+	}
+	if err := store.Complete(ctx, first.OperationID); err != nil {
+		t.Fatalf("Complete() unexpected error: %v", err)
+	}
+
+	p13, err := store.Claim(ctx, time.Minute)
+	if err != nil || p13 == nil || p13.OperationID != "op-p13" {
+		t.Fatalf("Claim() = %+v, %v; want op-p13", p13, err)
+	}
+	l32, err := store.Claim(ctx, time.Minute)
+	if err != nil || l32 == nil || l32.OperationID != "op-l32" {
+		t.Fatalf("Claim() = %+v, %v; want op-l32 in parallel with op-p13", l32, err)
+	}
+	if l32.DateTo != "2024-12-02" || len(l32.Kassas) != 1 || l32.Kassas[0] != "L32/L32" {
+		t.Fatalf("claimed job = %+v, want date_to and kassas restored", l32)
+	}
+	if l32.TraceParent != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" || p13.TraceParent != "" {
+		t.Fatalf("claimed trace parents = %q, %q; want restored for op-l32 only", l32.TraceParent, p13.TraceParent)
+	}
+
+	stats, err := store.QueueStats(ctx)
+	if err != nil {
+		t.Fatalf("QueueStats() unexpected error: %v", err)
+	}
+	if stats.Processing["load"] != 2 || stats.Queued["load"] != 0 {
+		t.Fatalf("QueueStats() = %+v, want 2 processing loads", stats)
+	}
+}
+
+// TestOperationQueueRecoversClaimsAfterRestart checks that a restarted
+// process, which has a new instance ID, leaves a held job alone and takes it
+// over once its lease expired.
+func TestOperationQueueRecoversClaimsAfterRestart(t *testing.T) {
+	env := framework.SetupTestEnvironment(t)
+	env.Reset(t)
+	ctx := env.GetContext()
+
+	store := operations.NewStore(env.Postgres.Config, logger.New(logger.Config{Output: &bytes.Buffer{}, Format: "json"}))
+	defer store.Close()
+
+	if err := store.Enqueue(ctx, operations.Job{OperationID: "op-1", RequestID: "req-1", OperationType: "load", Date: "2024-12-01"}); err != nil {
+		t.Fatalf("Enqueue() unexpected error: %v", err)
+	}
+	claimed, err := store.Claim(ctx, time.Minute)
+	if err != nil || claimed == nil || claimed.Attempts != 1 {
+		t.Fatalf("Claim() = %+v, %v; want first attempt", claimed, err)
+	}
+
+	restarted := operations.NewStore(env.Postgres.Config, logger.New(logger.Config{Output: &bytes.Buffer{}, Format: "json"}))
+	defer restarted.Close()
+	if job, err := restarted.Claim(ctx, time.Minute); err != nil || job != nil {
+		t.Fatalf("Claim() while lease is held = %+v, %v; want nil", job, err)
+	}
+
+	// Без heartbeat аренда истекает, и операцию забирает следующий захват
+	time.Sleep(10 * time.Millisecond)
+	takenOver, err := restarted.Claim(ctx, time.Millisecond)
+	if err != nil || takenOver == nil || takenOver.OperationID != "op-1" || takenOver.Attempts != 2 {
+		t.Fatalf("Claim() after lease expiry = %+v, %v; want op-1 second attempt", takenOver, err)
+	}
+	if held, _, err := store.Heartbeat(ctx, "op-1"); err != nil || held {
+		t.Fatalf("Heartbeat() of the previous process = %v, %v; want lost lease", held, err)
+	}
+
+	// Операция в очереди не считается брошенной
+	if err := store.Start(ctx, operations.Record{OperationID: "op-1", OperationType: "load", Status: operations.StatusProcessing, UpdatedAt: time.Now().Add(-24 * time.Hour)}); err != nil {
+		t.Fatalf("Start() unexpected error: %v", err)
+	}
+	if abandoned, err := store.RecoverStale(ctx); err != nil || abandoned != 0 {
+		t.Fatalf("RecoverStale() = %d, %v; want 0 while operation is queued", abandoned, err)
+	}
+	if held, cancelRequested, err := restarted.Heartbeat(ctx, "op-1"); err != nil || !held || cancelRequested {
+		t.Fatalf("Heartbeat() = %v, %v, %v; want held without cancel", held, cancelRequested, err)
+	}
+	if err := restarted.Complete(ctx, "op-1"); err != nil {
+		t.Fatalf("Complete() unexpected error: %v", err)
+	}
+	if abandoned, err := store.RecoverStale(ctx); err != nil || abandoned != 1 {
+		t.Fatalf("RecoverStale() = %d, %v; want 1 once operation left the queue", abandoned, err)
+	}
+}
+
+// TestOperationQueueCancelRequests checks that a canceled queued job is
+// claimed ahead of a running overlapping load and that the running job sees
+// its cancellation on the next heartbeat.
+func TestOperationQueueCancelRequests(t *testing.T) {
+	env := framework.SetupTestEnvironment(t)
+	env.Reset(t)
+	ctx := env.GetContext()
+
+	store := operations.NewStore(env.Postgres.Config, logger.New(logger.Config{Output: &bytes.Buffer{}, Format: "json"}))
+	defer store.Close()
+
+	enqueuedAt := time.Now().Add(-time.Minute)
+	for _, job := range []operations.Job{
+		{OperationID: "op-running", OperationType: "load", Date: "2024-12-01", EnqueuedAt: enqueuedAt},
+		{OperationID: "op-waiting", OperationType: "load", Date: "2024-12-02", EnqueuedAt: enqueuedAt.Add(time.Second)},
+	} {
+		if err := store.Enqueue(ctx, job); err != nil {
+			t.Fatalf("Enqueue(%s) unexpected error: %v", job.OperationID, err)
+		}
+	}
+	running, err := store.Claim(ctx, time.Minute)
+	if err != nil || running == nil || running.OperationID != "op-running" {
+		t.Fatalf("Claim() = %+v, %v; want op-running", running, err)
+	}
+
+	if status, err := store.RequestCancel(ctx, "op-missing"); err != nil || status != "" {
+		t.Fatalf("RequestCancel(op-missing) = %q, %v; want empty status", status, err)
+	}
+	if status, err := store.RequestCancel(ctx, "op-waiting"); err != nil || status != operations.StatusQueued {
+		t.Fatalf("RequestCancel(op-waiting) = %q, %v; want queued", status, err)
+	}
+	// Отмененная операция не ждет пересекающуюся загрузку
+	canceled, err := store.Claim(ctx, time.Minute)
+	if err != nil || canceled == nil || canceled.OperationID != "op-waiting" || !canceled.CancelRequested {
+		t.Fatalf("Claim() = %+v, %v; want canceled op-waiting", canceled, err)
+	}
+
+	if status, err := store.RequestCancel(ctx, "op-running"); err != nil || status != operations.StatusProcessing {
+		t.Fatalf("RequestCancel(op-running) = %q, %v; want processing", status, err)
+	}
+	if held, cancelRequested, err := store.Heartbeat(ctx, "op-running"); err != nil || !held || !cancelRequested {
+		t.Fatalf("Heartbeat() = %v, %v, %v; want held with cancel requested", held, cancelRequested, err)
+	}
+}
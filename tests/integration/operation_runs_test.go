@@ -0,0 +1,83 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/logger"
+	"github.com/user/go-frontol-loader/pkg/operations"
+	"github.com/user/go-frontol-loader/tests/integration/framework"
+)
+
+// TestOperationRunsHistory stores finished runs with their result summary and
+// reads them back by id and through the list filters.
+func TestOperationRunsHistory(t *testing.T) {
+	env := framework.SetupTestEnvironment(t)
+	env.Reset(t)
+	ctx := env.GetContext()
+
+	store := operations.NewStore(env.Postgres.Config, logger.New(logger.Config{Output: &bytes.Buffer{}, Format: "json"}))
+	defer store.Close()
+
+	startedAt := time.Now().Add(-time.Hour)
+	runs := []operations.Record{
+		{OperationID: "op-day", OperationType: "load", Status: operations.StatusCompleted, Date: "2024-12-01", Component: "webhook-server", StartedAt: startedAt},
+		{OperationID: "op-range", OperationType: "load", Status: operations.StatusFailed, Date: "2024-11-30..2024-12-02", SourceFolder: "L32/L32,P13/P13", Component: "webhook-server", StartedAt: startedAt.Add(time.Minute)},
+		{OperationID: "op-cli", OperationType: "cli_load", Status: operations.StatusCompleted, Date: "2024-12-05", Component: "loader", StartedAt: startedAt.Add(2 * time.Minute)},
+	}
+	for _, run := range runs {
+		if err := store.Start(ctx, run); err != nil {
+			t.Fatalf("Start(%s) unexpected error: %v", run.OperationID, err)
+		}
+	}
+	finishedAt := time.Now()
+	if err := store.Update(ctx, operations.Record{
+		OperationID:  "op-range",
+		Status:       operations.StatusFailed,
+		FinishedAt:   &finishedAt,
+		ErrorMessage: "ftp unavailable",
+		FailedStage:  "pipeline",
+		Result:       json.RawMessage(`{"status":"failed","files_processed":1}`),
+	}); err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+
+	record, err := store.Get(ctx, "op-range")
+	if err != nil || record == nil {
+		t.Fatalf("Get() = %+v, %v", record, err)
+	}
+	if record.FailedStage != "pipeline" || record.FinishedAt == nil || record.SourceFolder != "L32/L32,P13/P13" {
+		t.Fatalf("Get() = %+v", record)
+	}
+	var summary map[string]interface{}
+	if err := json.Unmarshal(record.Result, &summary); err != nil || summary["files_processed"] != float64(1) {
+		t.Fatalf("result = %s, %v", record.Result, err)
+	}
+	if missing, err := store.Get(ctx, "op-missing"); err != nil || missing != nil {
+		t.Fatalf("Get(missing) = %+v, %v; want nil", missing, err)
+	}
+
+	// 2024-12-01 попадает в день op-day и в диапазон op-range
+	page, err := store.List(ctx, operations.ListFilter{Date: "2024-12-01", OperationType: "load"})
+	if err != nil {
+		t.Fatalf("List() unexpected error: %v", err)
+	}
+	if page.Total != 2 || len(page.Operations) != 2 || page.Operations[0].OperationID != "op-range" {
+		t.Fatalf("List(date) = %+v, want op-range then op-day", page)
+	}
+
+	page, err = store.List(ctx, operations.ListFilter{SourceFolder: "P13/P13"})
+	if err != nil || page.Total != 1 || page.Operations[0].OperationID != "op-range" {
+		t.Fatalf("List(source_folder) = %+v, %v; want op-range", page, err)
+	}
+
+	page, err = store.List(ctx, operations.ListFilter{Limit: 1, Offset: 1})
+	if err != nil || page.Total != 3 || len(page.Operations) != 1 || page.Operations[0].OperationID != "op-range" {
+		t.Fatalf("List(page 2) = %+v, %v; want second newest of 3", page, err)
+	}
+}
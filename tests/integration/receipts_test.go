@@ -0,0 +1,93 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/db"
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/repository"
+	"github.com/user/go-frontol-loader/tests/integration/framework"
+)
+
+// TestReceiptsRebuiltOnReload loads one receipt, reloads the file without its
+// storno row and checks that receipts follow both versions.
+func TestReceiptsRebuiltOnReload(t *testing.T) {
+	env := framework.SetupTestEnvironment(t)
+	env.Reset(t)
+	ctx := env.GetContext()
+
+	pool, err := db.NewPool(env.Postgres.Config)
+	if err != nil {
+		t.Fatalf("Failed to create pool: %v", err)
+	}
+	defer pool.Close()
+	loader := repository.NewLoader(pool)
+
+	date := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	at := time.Date(0, 1, 1, 10, 0, 0, 0, time.UTC)
+	folder := "P13/P13"
+	transactions := map[string]interface{}{
+		"tx_document_open_42": []models.TxDocumentOpen42{
+			{TransactionIDUnique: 1, SourceFolder: folder, TransactionDate: date, TransactionTime: at, TransactionType: 42, CashRegisterCode: 1, ShiftNumber: 7, DocumentNumber: 100, CashierCode: 5, OperationType: 1},
+		},
+		"tx_item_registration_1_11": []models.TxItemRegistration1_11{
+			{TransactionIDUnique: 2, SourceFolder: folder, TransactionDate: date, TransactionTime: at, TransactionType: 11, CashRegisterCode: 1, ShiftNumber: 7, DocumentNumber: 100, ItemIdentifier: "A", PriceWithoutDiscounts: 50, Quantity: 2, PositionAmountWithRounding: 100},
+		},
+		"tx_item_storno_2_12": []models.TxItemStorno2_12{
+			{TransactionIDUnique: 3, SourceFolder: folder, TransactionDate: date, TransactionTime: at, TransactionType: 12, CashRegisterCode: 1, ShiftNumber: 7, DocumentNumber: 100, ItemIdentifier: "A", PriceWithoutDiscounts: -50, Quantity: 1, PositionAmountWithRounding: -50},
+		},
+		"tx_fiscal_payment_40": []models.TxFiscalPayment40{
+			{TransactionIDUnique: 4, SourceFolder: folder, TransactionDate: date, TransactionTime: at, TransactionType: 40, CashRegisterCode: 1, ShiftNumber: 7, DocumentNumber: 100, PaymentTypeCode: "1", PaymentTypeOperation: 1, CustomerAmountBaseCurrency: 50},
+		},
+		"tx_document_close_55": []models.TxDocumentClose55{
+			{TransactionIDUnique: 5, SourceFolder: folder, TransactionDate: date, TransactionTime: at, TransactionType: 55, CashRegisterCode: 1, ShiftNumber: 7, DocumentNumber: 100},
+		},
+	}
+	state := &models.FileLoadState{LogicalKey: "/response/P13/response.txt|2024-12-01", RemotePath: "/response/P13/response.txt", SourceFolder: folder, ContentHash: "v1"}
+	if err := loader.LoadFileDataWithReconcile(ctx, state, nil, transactions); err != nil {
+		t.Fatalf("LoadFileDataWithReconcile() unexpected error: %v", err)
+	}
+
+	var status string
+	var lineCount int
+	var gross, net, paid float64
+	queryReceipt := func() {
+		t.Helper()
+		err := pool.QueryRow(ctx, `
+			SELECT status, line_count, gross_amount::float8, net_amount::float8, paid_amount::float8
+			FROM receipts
+			WHERE source_folder = $1 AND cash_register_code = 1 AND shift_number = 7 AND document_number = 100
+		`, folder).Scan(&status, &lineCount, &gross, &net, &paid)
+		if err != nil {
+			t.Fatalf("Failed to query receipt: %v", err)
+		}
+	}
+
+	queryReceipt()
+	if status != models.ReceiptStatusClosed || lineCount != 1 || gross != 50 || net != 50 || paid != 50 {
+		t.Fatalf("receipt = %s/%d/%v/%v/%v, want closed/1/50/50/50", status, lineCount, gross, net, paid)
+	}
+
+	// The new version of the file no longer has the storno row
+	delete(transactions, "tx_item_storno_2_12")
+	stale := map[string][]int64{"tx_item_storno_2_12": {3}}
+	if err := loader.LoadFileDataWithReconcile(ctx, state, stale, transactions); err != nil {
+		t.Fatalf("LoadFileDataWithReconcile() reload unexpected error: %v", err)
+	}
+	queryReceipt()
+	if lineCount != 1 || gross != 100 || net != 100 {
+		t.Fatalf("receipt after reload = %d/%v/%v, want 1/100/100", lineCount, gross, net)
+	}
+
+	var payments int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM receipt_payments WHERE source_folder = $1`, folder).Scan(&payments); err != nil {
+		t.Fatalf("Failed to count receipt payments: %v", err)
+	}
+	if payments != 1 {
+		t.Fatalf("receipt_payments = %d, want 1 after rebuild", payments)
+	}
+}
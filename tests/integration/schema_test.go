@@ -6,12 +6,11 @@ package integration
 import (
 	"context"
 	"errors"
-	"sort"
 	"testing"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/user/go-frontol-loader/pkg/db"
-	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/parser"
 	"github.com/user/go-frontol-loader/tests/integration/framework"
 )
 
@@ -19,11 +18,7 @@ func TestDatabaseSchemaMatchesMigrations(t *testing.T) {
 	env := framework.SetupTestEnvironment(t)
 	ctx := env.GetContext()
 
-	tables := make([]string, 0, len(models.TxSchemas))
-	for table := range models.TxSchemas {
-		tables = append(tables, table)
-	}
-	sort.Strings(tables)
+	tables := parser.RegisteredTables()
 
 	missing := make([]string, 0)
 	for _, table := range tables {
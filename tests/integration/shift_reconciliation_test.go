@@ -0,0 +1,98 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/db"
+	"github.com/user/go-frontol-loader/pkg/models"
+	"github.com/user/go-frontol-loader/pkg/repository"
+	"github.com/user/go-frontol-loader/tests/integration/framework"
+)
+
+// TestReconcileShiftsAgainstZReport loads a shift whose sale and return match
+// its Z-report and a shift whose shift close (61) disagrees with the payments.
+func TestReconcileShiftsAgainstZReport(t *testing.T) {
+	env := framework.SetupTestEnvironment(t)
+	env.Reset(t)
+	ctx := env.GetContext()
+
+	pool, err := db.NewPool(env.Postgres.Config)
+	if err != nil {
+		t.Fatalf("Failed to create pool: %v", err)
+	}
+	defer pool.Close()
+	loader := repository.NewLoader(pool)
+
+	date := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	at := time.Date(0, 1, 1, 10, 0, 0, 0, time.UTC)
+	folder := "P13/P13"
+	// Операция 0 — продажа, 1 — возврат
+	open := func(id, shift, number, operation int64) models.TxDocumentOpen42 {
+		return models.TxDocumentOpen42{TransactionIDUnique: id, SourceFolder: folder, TransactionDate: date, TransactionTime: at, TransactionType: 42, CashRegisterCode: 1, ShiftNumber: shift, DocumentNumber: number, OperationType: operation}
+	}
+	item := func(id, shift, number int64, amount float64) models.TxItemRegistration1_11 {
+		return models.TxItemRegistration1_11{TransactionIDUnique: id, SourceFolder: folder, TransactionDate: date, TransactionTime: at, TransactionType: 11, CashRegisterCode: 1, ShiftNumber: shift, DocumentNumber: number, ItemIdentifier: "A", Quantity: 1, PositionAmountWithRounding: amount}
+	}
+	payment := func(id, shift, number int64, amount float64) models.TxFiscalPayment40 {
+		return models.TxFiscalPayment40{TransactionIDUnique: id, SourceFolder: folder, TransactionDate: date, TransactionTime: at, TransactionType: 40, CashRegisterCode: 1, ShiftNumber: shift, DocumentNumber: number, PaymentTypeCode: "1", CustomerAmountBaseCurrency: amount}
+	}
+	closeDoc := func(id, shift, number int64) models.TxDocumentClose55 {
+		return models.TxDocumentClose55{TransactionIDUnique: id, SourceFolder: folder, TransactionDate: date, TransactionTime: at, TransactionType: 55, CashRegisterCode: 1, ShiftNumber: shift, DocumentNumber: number}
+	}
+	transactions := map[string]interface{}{
+		"tx_document_open_42":       []models.TxDocumentOpen42{open(1, 7, 100, 0), open(5, 7, 101, 1), open(9, 8, 200, 0)},
+		"tx_item_registration_1_11": []models.TxItemRegistration1_11{item(2, 7, 100, 100), item(6, 7, 101, 30), item(10, 8, 200, 40)},
+		"tx_fiscal_payment_40":      []models.TxFiscalPayment40{payment(3, 7, 100, 100), payment(7, 7, 101, 30), payment(11, 8, 200, 35)},
+		"tx_document_close_55":      []models.TxDocumentClose55{closeDoc(4, 7, 100), closeDoc(8, 7, 101), closeDoc(12, 8, 200)},
+		"tx_report_z_63": []models.TxReportZ63{
+			{TransactionIDUnique: 13, SourceFolder: folder, TransactionDate: date, TransactionTime: at, TransactionType: 63, CashRegisterCode: 1, ShiftNumber: 7, ShiftRevenue: 70},
+		},
+		"tx_shift_close_61": []models.TxShiftClose61{
+			{TransactionIDUnique: 14, SourceFolder: folder, TransactionDate: date, TransactionTime: at, TransactionType: 61, CashRegisterCode: 1, ShiftNumber: 8, ShiftRevenue: 40},
+		},
+	}
+	if err := loader.LoadFileData(ctx, transactions); err != nil {
+		t.Fatalf("LoadFileData() unexpected error: %v", err)
+	}
+
+	shifts, err := loader.ReconcileShifts(ctx, folder, models.SingleDay("2024-12-01"))
+	if err != nil {
+		t.Fatalf("ReconcileShifts() unexpected error: %v", err)
+	}
+	if len(shifts) != 2 {
+		t.Fatalf("ReconcileShifts() = %+v, want 2 shifts", shifts)
+	}
+	matched, mismatched := shifts[0], shifts[1]
+	if matched.Status != models.ShiftReconciliationMatched || matched.ZTransactionType != 63 || matched.ReceiptCount != 2 ||
+		matched.SalesAmount != 100 || matched.ReturnsAmount != 30 || matched.ItemsRevenue != 70 || matched.PaymentsRevenue != 70 {
+		t.Fatalf("shift 7 = %+v, want matched against Z-report", matched)
+	}
+	if mismatched.Status != models.ShiftReconciliationMismatch || mismatched.ZTransactionType != 61 ||
+		mismatched.ItemsDifference != 0 || mismatched.PaymentsDifference != -5 {
+		t.Fatalf("shift 8 = %+v, want payments 5 short of shift close", mismatched)
+	}
+
+	// Повторная сверка перезаписывает результат смены
+	if _, err := loader.ReconcileShifts(ctx, folder, models.SingleDay("2024-12-01")); err != nil {
+		t.Fatalf("ReconcileShifts() rerun unexpected error: %v", err)
+	}
+	var rows int
+	var status string
+	var difference float64
+	if err := pool.QueryRow(ctx, `
+		SELECT COUNT(*) OVER (), status, payments_difference::float8
+		FROM shift_reconciliation
+		WHERE source_folder = $1
+		ORDER BY shift_number DESC
+		LIMIT 1
+	`, folder).Scan(&rows, &status, &difference); err != nil {
+		t.Fatalf("Failed to query shift_reconciliation: %v", err)
+	}
+	if rows != 2 || status != models.ShiftReconciliationMismatch || difference != -5 {
+		t.Fatalf("shift_reconciliation = %d rows, last %s/%v; want 2 rows, mismatch/-5", rows, status, difference)
+	}
+}
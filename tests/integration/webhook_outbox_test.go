@@ -0,0 +1,123 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/user/go-frontol-loader/pkg/logger"
+	"github.com/user/go-frontol-loader/pkg/operations"
+	"github.com/user/go-frontol-loader/tests/integration/framework"
+)
+
+// TestWebhookOutboxRetriesDeadLettersAndReplays walks a report through the
+// outbox: ordered delivery per operation, retry, dead-letter and replay.
+func TestWebhookOutboxRetriesDeadLettersAndReplays(t *testing.T) {
+	env := framework.SetupTestEnvironment(t)
+	env.Reset(t)
+	ctx := env.GetContext()
+
+	store := operations.NewStore(env.Postgres.Config, logger.New(logger.Config{Output: &bytes.Buffer{}, Format: "json"}))
+	defer store.Close()
+
+	const url = "http://receiver.local/reports"
+	timeoutID, err := store.EnqueueReport(ctx, operations.OutboxReport{OperationID: "op-1", RequestID: "req-1", Subscriber: "default", URL: url,
+		Payload: []byte(`{"status":"timeout"}`), TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"})
+	if err != nil {
+		t.Fatalf("EnqueueReport(timeout) unexpected error: %v", err)
+	}
+	finalID, err := store.EnqueueReport(ctx, operations.OutboxReport{OperationID: "op-1", RequestID: "req-1", Subscriber: "default", URL: url, Payload: []byte(`{"status":"completed"}`)})
+	if err != nil {
+		t.Fatalf("EnqueueReport(final) unexpected error: %v", err)
+	}
+
+	first, err := store.ClaimReport(ctx, time.Minute)
+	if err != nil || first == nil || first.ID != timeoutID || first.Attempts != 1 {
+		t.Fatalf("ClaimReport() = %+v, %v; want first attempt of timeout report", first, err)
+	}
+	if first.TraceParent == "" || first.RequestID != "req-1" {
+		t.Fatalf("claimed report = %+v, want trace parent and request id restored", first)
+	}
+	// Итоговый отчет ждет, пока не доставлен отчет по таймауту
+	if blocked, err := store.ClaimReport(ctx, time.Minute); err != nil || blocked != nil {
+		t.Fatalf("ClaimReport() while earlier report pending = %+v, %v; want nil", blocked, err)
+	}
+
+	if err := store.RetryReport(ctx, timeoutID, 0, 503, "webhook report returned status 503"); err != nil {
+		t.Fatalf("RetryReport() unexpected error: %v", err)
+	}
+	retried, err := store.ClaimReport(ctx, time.Minute)
+	if err != nil || retried == nil || retried.ID != timeoutID || retried.Attempts != 2 || retried.LastStatusCode != 503 {
+		t.Fatalf("ClaimReport() after retry = %+v, %v; want second attempt with last status 503", retried, err)
+	}
+	if err := store.DeadLetterReport(ctx, timeoutID, 0, "connection refused"); err != nil {
+		t.Fatalf("DeadLetterReport() unexpected error: %v", err)
+	}
+
+	// Отчет в dead-letter больше не задерживает следующие
+	final, err := store.ClaimReport(ctx, time.Minute)
+	if err != nil || final == nil || final.ID != finalID {
+		t.Fatalf("ClaimReport() = %+v, %v; want final report", final, err)
+	}
+	if err := store.MarkReportDelivered(ctx, finalID, 200); err != nil {
+		t.Fatalf("MarkReportDelivered() unexpected error: %v", err)
+	}
+
+	dead, err := store.ListReports(ctx, operations.ReportFilter{Status: operations.ReportDead})
+	if err != nil || dead.Total != 1 || dead.Reports[0].ID != timeoutID || dead.Reports[0].LastError != "connection refused" {
+		t.Fatalf("ListReports(dead) = %+v, %v; want the timeout report", dead, err)
+	}
+	delivered, err := store.ListReports(ctx, operations.ReportFilter{Status: operations.ReportDelivered, OperationID: "op-1"})
+	if err != nil || delivered.Total != 1 || delivered.Reports[0].DeliveredAt == nil {
+		t.Fatalf("ListReports(delivered) = %+v, %v; want the final report with delivered_at", delivered, err)
+	}
+
+	// Доставленный отчет повтором не затрагивается
+	if replayed, err := store.ReplayReports(ctx, []int64{timeoutID, finalID}); err != nil || replayed != 1 {
+		t.Fatalf("ReplayReports() = %d, %v; want 1", replayed, err)
+	}
+	replayedReport, err := store.ClaimReport(ctx, time.Minute)
+	if err != nil || replayedReport == nil || replayedReport.ID != timeoutID || replayedReport.Attempts != 1 {
+		t.Fatalf("ClaimReport() after replay = %+v, %v; want timeout report with fresh attempts", replayedReport, err)
+	}
+}
+
+// TestWebhookOutboxOrdersReportsPerSubscriber checks that a pending report of
+// one subscriber does not hold back the reports of the same operation to
+// another subscriber.
+func TestWebhookOutboxOrdersReportsPerSubscriber(t *testing.T) {
+	env := framework.SetupTestEnvironment(t)
+	env.Reset(t)
+	ctx := env.GetContext()
+
+	store := operations.NewStore(env.Postgres.Config, logger.New(logger.Config{Output: &bytes.Buffer{}, Format: "json"}))
+	defer store.Close()
+
+	defaultID, err := store.EnqueueReport(ctx, operations.OutboxReport{OperationID: "op-1", Subscriber: "default",
+		URL: "http://receiver.local/reports", Payload: []byte(`{"status":"failed"}`)})
+	if err != nil {
+		t.Fatalf("EnqueueReport(default) unexpected error: %v", err)
+	}
+	alertsID, err := store.EnqueueReport(ctx, operations.OutboxReport{OperationID: "op-1", Subscriber: "alerts",
+		URL: "http://alerts.local/hook", Payload: []byte(`{"status":"failed"}`)})
+	if err != nil {
+		t.Fatalf("EnqueueReport(alerts) unexpected error: %v", err)
+	}
+
+	first, err := store.ClaimReport(ctx, time.Minute)
+	if err != nil || first == nil || first.ID != defaultID || first.Subscriber != "default" {
+		t.Fatalf("ClaimReport() = %+v, %v; want the default subscriber report", first, err)
+	}
+	second, err := store.ClaimReport(ctx, time.Minute)
+	if err != nil || second == nil || second.ID != alertsID || second.Subscriber != "alerts" {
+		t.Fatalf("ClaimReport() = %+v, %v; want the alerts report while the default one is pending", second, err)
+	}
+
+	alerts, err := store.ListReports(ctx, operations.ReportFilter{Subscriber: "alerts"})
+	if err != nil || alerts.Total != 1 || alerts.Reports[0].URL != "http://alerts.local/hook" {
+		t.Fatalf("ListReports(alerts) = %+v, %v; want the alerts report", alerts, err)
+	}
+}